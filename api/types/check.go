@@ -0,0 +1,274 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// CheckRequest represents the request body for blacklist check
+type CheckRequest struct {
+	Name       string     `json:"name"`
+	NIK        *string    `json:"nik,omitempty"`
+	BirthPlace *string    `json:"birth_place,omitempty"`
+	BirthDate  *time.Time `json:"birth_date,omitempty"`
+	// IDCountry selects the national ID validator for nik by ISO 3166-1
+	// alpha-2 code (ID, MY, PH); defaults to ID
+	IDCountry *string `json:"id_country,omitempty"`
+	// Lists to screen against (internal, sanctions, pep); defaults to all
+	// lists the server is configured to screen
+	Lists []string `json:"lists,omitempty"`
+	// Diagnostics reports the nearest records on lists that didn't match and
+	// why they were excluded. Requires the diagnostics role.
+	Diagnostics bool `json:"diagnostics,omitempty"`
+	// Profile is the name matching profile (see GET /api/v1/profiles);
+	// defaults to the one configured for the caller
+	Profile *string `json:"profile,omitempty"`
+	// CandidateBudget lowers the number of candidate names fuzzy matching
+	// considers per search; it can't raise the configured budget
+	CandidateBudget *int `json:"candidate_budget,omitempty"`
+	// RescreenConsent enrols the subject for re-screening when true and
+	// withdraws an earlier enrolment when false, where re-screening is enabled
+	RescreenConsent *bool `json:"rescreen_consent,omitempty"`
+}
+
+// UnmarshalJSON accepts birth_date as a date (YYYY-MM-DD) as well as an RFC
+// 3339 timestamp
+func (r *CheckRequest) UnmarshalJSON(data []byte) error {
+	type plain CheckRequest
+	aux := struct {
+		*plain
+		BirthDate *string `json:"birth_date,omitempty"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.BirthDate = nil
+	if aux.BirthDate == nil {
+		return nil
+	}
+	date, err := ParseBirthDate(*aux.BirthDate)
+	if err != nil {
+		return err
+	}
+	r.BirthDate = date
+	return nil
+}
+
+// ParseBirthDate parses a birth_date given as a date (YYYY-MM-DD) or an RFC
+// 3339 timestamp, nil when it is empty
+func ParseBirthDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return &date, nil
+		}
+	}
+	return nil, errors.New("birth_date must be formatted as YYYY-MM-DD or RFC 3339")
+}
+
+// CheckResponse represents the response body for blacklist check
+type CheckResponse struct {
+	Blacklisted bool   `json:"blacklisted"`
+	Details     string `json:"details,omitempty"`
+	MatchType   string `json:"match_type"`
+	ReasonCode  string `json:"reason_code,omitempty"`
+	// Confidence rates the match from 0 to 1; it is lowered when the matched
+	// record lacks a birth date or birth place
+	Confidence float64 `json:"confidence,omitempty"`
+	// MatchedAlias is the alias of the matched record the name matched,
+	// omitted when it matched the record's own name
+	MatchedAlias string `json:"matched_alias,omitempty"`
+	// Score is the highest risk score on a blocking list, from 0 to 1, and
+	// Decision its band: clear, review or hit, or unknown when a list wasn't
+	// screened in time. Blacklisted still follows the match rules.
+	Score    float64 `json:"score"`
+	Decision string  `json:"decision"`
+	// Outcome is hit when blacklisted, unknown when a blocking list could be
+	// neither matched nor cleared, and clear otherwise. UnknownReason says
+	// why: deadline_exceeded or insufficient_data.
+	Outcome       string `json:"outcome"`
+	UnknownReason string `json:"unknown_reason,omitempty"`
+	// Results holds the outcome on every screened list. The fields above
+	// summarize the first match on a blocking list; a PEP match alone
+	// doesn't set blacklisted.
+	Results []ListResult `json:"results,omitempty"`
+	// NationalID is decoded from the submitted national ID
+	NationalID *NationalID `json:"national_id,omitempty"`
+	// Sandbox is set when the caller was screened against the synthetic
+	// sandbox records rather than production data
+	Sandbox bool `json:"sandbox,omitempty"`
+	// Degraded is set when the X-Deadline-Ms deadline passed before every
+	// list was screened. Those lists have match type unknown, and unless a
+	// blocking list matched so do the fields above.
+	Degraded bool `json:"degraded,omitempty"`
+	// Warnings flag suspect input the check ran with regardless
+	Warnings []Warning `json:"warnings,omitempty"`
+	// Explanation says why the blocking list summarized above matched; it
+	// is only reported for checks sent with ?explain=true
+	Explanation *Explanation `json:"explanation,omitempty"`
+	// ReviewCaseID is the review case opened for a hit, or the open case of
+	// the same subject and record the hit was added to; omitted when review
+	// cases are disabled
+	ReviewCaseID int64 `json:"review_case_id,omitempty"`
+}
+
+// Warning flags suspect input a check ran with regardless, so the caller can
+// prompt for a correction
+type Warning struct {
+	// Code is nik_birth_date_mismatch, birth_date_improbable or
+	// birth_place_unrecognized
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NationalID is what the structure of a national ID reveals about its
+// holder. Fields the country's ID doesn't encode are omitted.
+type NationalID struct {
+	Country string `json:"country"`
+	// RegionCode and Region are where the holder was registered or born,
+	// e.g. the province of a NIK
+	RegionCode string     `json:"region_code,omitempty"`
+	Region     string     `json:"region,omitempty"`
+	BirthDate  *time.Time `json:"birth_date,omitempty"`
+	Sex        string     `json:"sex,omitempty"`
+	// Attributes holds country-specific fields, e.g. the regency of a NIK
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// BirthDateMismatch flags a birth_date that disagrees with the ID
+	BirthDateMismatch bool `json:"birth_date_mismatch,omitempty"`
+}
+
+// ListResult is the outcome of screening against a single list
+type ListResult struct {
+	List       string  `json:"list"`
+	Matched    bool    `json:"matched"`
+	Details    string  `json:"details,omitempty"`
+	MatchType  string  `json:"match_type"`
+	ReasonCode string  `json:"reason_code,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	// MatchedAlias is the alias the name matched, if any
+	MatchedAlias string `json:"matched_alias,omitempty"`
+	// SuppressedBy is the whitelist entry that cleared a match, reported
+	// with match_type suppressed_by_whitelist
+	SuppressedBy int64 `json:"suppressed_by,omitempty"`
+	// Outcome is hit, clear or unknown, with UnknownReason set on unknown
+	Outcome       string `json:"outcome"`
+	UnknownReason string `json:"unknown_reason,omitempty"`
+	// Score rates the matched record, or the closest candidate when none
+	// matched; Factors break it down
+	Score    float64       `json:"score"`
+	Decision string        `json:"decision"`
+	Factors  []ScoreFactor `json:"factors,omitempty"`
+	// NearMisses and Truncated are only reported for diagnostics requests.
+	// Truncated means fuzzy matching left candidates out for the candidate
+	// budget, so a closer record may have gone unconsidered.
+	NearMisses []NearMiss `json:"near_misses,omitempty"`
+	Truncated  bool       `json:"truncated,omitempty"`
+	// Explanation is only reported on matches of checks sent with
+	// ?explain=true
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// Explanation says why a list matched: the rule that matched the record and
+// how the subject compared against the record's fields
+type Explanation struct {
+	// Rule is the match rule that matched, as in match_type
+	Rule     string      `json:"rule"`
+	RecordID int64       `json:"record_id"`
+	Checks   []RuleCheck `json:"checks"`
+}
+
+// RuleCheck is one comparison of the subject against the matched record
+type RuleCheck struct {
+	// Check is nik, name_similarity, birth_date, birth_place or
+	// phonetic_code
+	Check string `json:"check"`
+	// Required is set when the rule that matched depends on the comparison;
+	// the others are reported for context
+	Required bool `json:"required"`
+	Passed   bool `json:"passed"`
+	// Subject and Record are the compared values, omitted when absent
+	Subject string `json:"subject,omitempty"`
+	Record  string `json:"record,omitempty"`
+	// SubjectPlace and RecordPlace are the places the compared birth places
+	// are names of, omitted for places the gazetteer doesn't know
+	SubjectPlace string `json:"subject_place,omitempty"`
+	RecordPlace  string `json:"record_place,omitempty"`
+	// Value is the similarity of the names or birth places, and Threshold
+	// the similarity the name had to exceed
+	Value     float64 `json:"value,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	// ToleranceDays is how many days apart birth dates may be and still agree
+	ToleranceDays int `json:"tolerance_days,omitempty"`
+}
+
+// ScoreFactor is one factor's part in a risk score
+type ScoreFactor struct {
+	// Factor is nik, name, birth_date or birth_place
+	Factor string `json:"factor"`
+	// Value is how well the factor agrees, from 0 to 1
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+// RecentCheck is a recorded production check
+type RecentCheck struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	NIK         string     `json:"nik,omitempty"`
+	BirthPlace  string     `json:"birth_place,omitempty"`
+	BirthDate   *time.Time `json:"birth_date,omitempty"`
+	Lists       []string   `json:"lists,omitempty"`
+	Blacklisted bool       `json:"blacklisted"`
+	MatchType   string     `json:"match_type"`
+	// PolicyVersion is the policy snapshot the decision was made under
+	PolicyVersion string    `json:"policy_version,omitempty"`
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
+// NearMiss is a record that came close to matching but was excluded
+type NearMiss struct {
+	NIK        string     `json:"nik"`
+	Name       string     `json:"name"`
+	BirthPlace string     `json:"birth_place"`
+	BirthDate  *time.Time `json:"birth_date,omitempty"`
+	Similarity float64    `json:"similarity"`
+	// Excluded lists the failed constraints: below_threshold,
+	// birth_date_mismatch, birth_place_mismatch, phonetic_mismatch or
+	// below_name_only_threshold
+	Excluded []string `json:"excluded"`
+}
+
+// MatchProfile describes a name matching profile
+type MatchProfile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// RecacheRequest represents the request body for purging the cached results
+// of specific subjects
+type RecacheRequest struct {
+	// NIKs drop only their exact-match entries
+	NIKs     []string       `json:"niks,omitempty"`
+	Subjects []CheckRequest `json:"subjects,omitempty"`
+	// Recheck screens each subject again once purged
+	Recheck bool `json:"recheck,omitempty"`
+}
+
+// RecacheResponse reports a purge and, with recheck, the fresh result of each
+// subject in the order given
+type RecacheResponse struct {
+	Purged  int64           `json:"purged"`
+	Results []RecacheResult `json:"results,omitempty"`
+}
+
+// RecacheResult is the fresh result of a rechecked subject, or why it couldn't
+// be rechecked
+type RecacheResult struct {
+	Result *CheckResponse `json:"result,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
@@ -0,0 +1,88 @@
+package types
+
+// CheckResponseV2 is the response body of a v2 blacklist check. It takes
+// the same request as v1. The score of the check and of each list is one
+// object, and the match the check is decided on is reported once, in Match,
+// rather than copied into top-level fields.
+type CheckResponseV2 struct {
+	// Outcome is hit when blacklisted, unknown when a blocking list could be
+	// neither matched nor cleared, and clear otherwise. UnknownReason says
+	// why: deadline_exceeded or insufficient_data.
+	Outcome       string `json:"outcome"`
+	UnknownReason string `json:"unknown_reason,omitempty"`
+	Blacklisted   bool   `json:"blacklisted"`
+	// Score is the highest risk score on a blocking list. Blacklisted still
+	// follows the match rules.
+	Score ScoreV2 `json:"score"`
+	// Match is the first match on a blocking list, omitted when none matched
+	Match *MatchV2 `json:"match,omitempty"`
+	// Results holds the outcome on every screened list
+	Results []ListResultV2 `json:"results,omitempty"`
+	// NationalID is decoded from the submitted national ID
+	NationalID *NationalID `json:"national_id,omitempty"`
+	// Sandbox is set when the caller was screened against the synthetic
+	// sandbox records rather than production data
+	Sandbox bool `json:"sandbox,omitempty"`
+	// Degraded is set when the X-Deadline-Ms deadline passed before every
+	// list was screened; those lists have outcome unknown
+	Degraded bool `json:"degraded,omitempty"`
+	// Warnings flag suspect input the check ran with regardless
+	Warnings []Warning `json:"warnings,omitempty"`
+	// ReviewCaseID is the review case opened for a hit, or the open case of
+	// the same subject and record the hit was added to; omitted when review
+	// cases are disabled
+	ReviewCaseID int64 `json:"review_case_id,omitempty"`
+}
+
+// ScoreV2 is a risk score and the decision band it falls into
+type ScoreV2 struct {
+	// Value rates the subject from 0 to 1
+	Value float64 `json:"value"`
+	// Decision is clear, review or hit, or unknown when a list wasn't
+	// screened in time
+	Decision string `json:"decision"`
+	// Factors break the score of a list down
+	Factors []ScoreFactor `json:"factors,omitempty"`
+}
+
+// MatchV2 is a match of the subject on a list
+type MatchV2 struct {
+	List string `json:"list"`
+	// MatchType is the match rule that matched
+	MatchType  string `json:"match_type"`
+	ReasonCode string `json:"reason_code,omitempty"`
+	Details    string `json:"details,omitempty"`
+	// Confidence rates the match from 0 to 1; it is lowered when the matched
+	// record lacks a birth date or birth place
+	Confidence float64 `json:"confidence"`
+	// MatchedAlias is the alias of the matched record the name matched,
+	// omitted when it matched the record's own name
+	MatchedAlias string `json:"matched_alias,omitempty"`
+	// Explanation is only reported for checks sent with ?explain=true
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// ListResultV2 is the outcome of screening against a single list
+type ListResultV2 struct {
+	List string `json:"list"`
+	// Outcome is hit, clear or unknown, with UnknownReason set on unknown
+	Outcome       string `json:"outcome"`
+	UnknownReason string `json:"unknown_reason,omitempty"`
+	// MatchType is the match rule that matched, or no_match,
+	// suppressed_by_whitelist or unknown
+	MatchType string `json:"match_type"`
+	Details   string `json:"details,omitempty"`
+	// Match is the match on the list, omitted when it didn't match
+	Match *MatchV2 `json:"match,omitempty"`
+	// SuppressedBy is the whitelist entry that cleared a match, reported
+	// with match_type suppressed_by_whitelist
+	SuppressedBy int64 `json:"suppressed_by,omitempty"`
+	// Score rates the matched record, or the closest candidate when none
+	// matched
+	Score ScoreV2 `json:"score"`
+	// NearMisses and Truncated are only reported for diagnostics requests.
+	// Truncated means fuzzy matching left candidates out for the candidate
+	// budget, so a closer record may have gone unconsidered.
+	NearMisses []NearMiss `json:"near_misses,omitempty"`
+	Truncated  bool       `json:"truncated,omitempty"`
+}
@@ -0,0 +1,4 @@
+// Package types holds the public request, response and event types of the
+// blacklist check API. It is a separate module with no dependencies so client
+// services can import it without pulling in the server's chi, sqlx or redis.
+package types
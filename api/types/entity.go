@@ -0,0 +1,10 @@
+package types
+
+// EntityCheckRequest represents the request body for company screening.
+// Country is an ISO 3166-1 alpha-2 code; registration numbers such as NPWP
+// may be sent with or without formatting.
+type EntityCheckRequest struct {
+	LegalName          string  `json:"legal_name"`
+	RegistrationNumber *string `json:"registration_number,omitempty"`
+	Country            *string `json:"country,omitempty"`
+}
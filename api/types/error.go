@@ -0,0 +1,33 @@
+package types
+
+// Error codes returned in ErrorResponse.Code
+const (
+	ErrCodeValidation       = "validation_error"
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeForbidden        = "forbidden"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeConflict         = "conflict"
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeInternal         = "internal_error"
+	// ErrCodeDependencyTimeout is returned when a dependency didn't answer in time
+	ErrCodeDependencyTimeout = "dependency_timeout"
+	// ErrCodeDependencyUnavailable is returned when a dependency is down and
+	// the request failed fast rather than wait on it
+	ErrCodeDependencyUnavailable = "dependency_unavailable"
+)
+
+// ErrorResponse is the envelope returned by every endpoint on failure
+type ErrorResponse struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// FieldError is one field of a request that failed validation. Validation
+// errors report every failing field as a list of these in details.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,3 @@
+module blacklist-check/api/types
+
+go 1.21
@@ -0,0 +1,7 @@
+package types
+
+// ReadinessResponse represents the response body for readiness checks
+type ReadinessResponse struct {
+	Status       string            `json:"status"`
+	Dependencies map[string]string `json:"dependencies"`
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RecordRequest represents the request body for creating or updating a
+// record. Birth place and birth date may be omitted when they are unknown.
+type RecordRequest struct {
+	// List defaults to "internal" and can't be changed by an update
+	List       string     `json:"list,omitempty"`
+	NIK        string     `json:"nik"`
+	Name       string     `json:"name"`
+	BirthPlace string     `json:"birth_place"`
+	BirthDate  *time.Time `json:"birth_date,omitempty"`
+	Reason     string     `json:"reason"`
+	Source     string     `json:"source,omitempty"`
+	// ReasonCode and ReasonParams select a reason template; Reason remains
+	// as free text for records without one
+	ReasonCode   string            `json:"reason_code,omitempty"`
+	ReasonParams map[string]string `json:"reason_params,omitempty"`
+	// Aliases are other names the person is known by, matched like the
+	// name. An update without aliases keeps those the record has; an empty
+	// list removes them.
+	Aliases []Alias `json:"aliases,omitempty"`
+}
+
+// Alias is another name a blacklisted person is known by. Type is aka (the
+// default), fka for a former name, or spelling for another spelling.
+type Alias struct {
+	Alias string `json:"alias"`
+	Type  string `json:"alias_type,omitempty"`
+}
+
+// TemporaryRecordRequest represents the request body for creating a
+// temporary record
+type TemporaryRecordRequest struct {
+	RecordRequest
+	// Days until the record expires unless confirmed; defaults to TEMPORARY_DEFAULT_DAYS
+	Days int `json:"days,omitempty"`
+}
+
+// ExtendRecordRequest represents the request body for extending a temporary record
+type ExtendRecordRequest struct {
+	// Days from now until the record expires; defaults to TEMPORARY_DEFAULT_DAYS
+	Days int `json:"days,omitempty"`
+}
+
+// Record represents a blacklist record in API responses
+type Record struct {
+	ID           int64             `json:"id"`
+	List         string            `json:"list"`
+	NIK          string            `json:"nik"`
+	Name         string            `json:"name"`
+	BirthPlace   string            `json:"birth_place"`
+	BirthDate    *time.Time        `json:"birth_date,omitempty"`
+	Reason       string            `json:"reason"`
+	ReasonCode   string            `json:"reason_code,omitempty"`
+	ReasonParams map[string]string `json:"reason_params,omitempty"`
+	Source       string            `json:"source"`
+	Aliases      []Alias           `json:"aliases,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	// DeletedAt and DeletedBy are set on soft-deleted records
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *string    `json:"deleted_by,omitempty"`
+	// ExpiresAt is set on temporary records
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// RecordPage is a page of a record listing. NextCursor is passed as ?cursor=
+// to fetch the next page and is absent on the last one.
+type RecordPage struct {
+	Records    []Record `json:"records"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// RecordChange is an entry of a record's audit history. Before and After are
+// full snapshots of the stored row; Before is absent for creations.
+type RecordChange struct {
+	ID        int64           `json:"id"`
+	NIK       string          `json:"nik"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	ChangedBy string          `json:"changed_by"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
@@ -0,0 +1,23 @@
+package types
+
+import "time"
+
+// ScreeningRequest represents the JSON request body for a bulk screening job
+type ScreeningRequest struct {
+	Subjects []CheckRequest `json:"subjects"`
+}
+
+// ScreeningJob represents a bulk screening job and its progress
+type ScreeningJob struct {
+	ID          int64      `json:"id"`
+	Status      string     `json:"status"`
+	Total       int        `json:"total"`
+	Processed   int        `json:"processed"`
+	Matched     int        `json:"matched"`
+	Error       string     `json:"error,omitempty"`
+	ResultsURL  string     `json:"results_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	StalledAt   *time.Time `json:"stalled_at,omitempty"`
+}
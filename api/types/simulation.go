@@ -0,0 +1,83 @@
+package types
+
+import "time"
+
+// MatchPolicy holds the tunable parts of individual matching
+type MatchPolicy struct {
+	MinSimilarity            float64  `json:"min_similarity"`
+	Rules                    []string `json:"rules"`
+	NameOnlySimilarity       float64  `json:"name_only_similarity"`
+	MissingBirthDatePenalty  float64  `json:"missing_birth_date_penalty"`
+	MissingBirthPlacePenalty float64  `json:"missing_birth_place_penalty"`
+	BirthDateToleranceDays   int      `json:"birth_date_tolerance_days"`
+	CandidateBudget          int      `json:"candidate_budget"`
+	RerankCandidates         int      `json:"rerank_candidates"`
+	RerankTrigramWeight      float64  `json:"rerank_trigram_weight"`
+	RerankJaroWinklerWeight  float64  `json:"rerank_jaro_winkler_weight"`
+}
+
+// ProposedPolicy overrides parts of the current match policy; unset fields
+// keep their current value
+type ProposedPolicy struct {
+	MinSimilarity            *float64 `json:"min_similarity,omitempty"`
+	Rules                    []string `json:"rules,omitempty"`
+	NameOnlySimilarity       *float64 `json:"name_only_similarity,omitempty"`
+	MissingBirthDatePenalty  *float64 `json:"missing_birth_date_penalty,omitempty"`
+	MissingBirthPlacePenalty *float64 `json:"missing_birth_place_penalty,omitempty"`
+	BirthDateToleranceDays   *int     `json:"birth_date_tolerance_days,omitempty"`
+	CandidateBudget          *int     `json:"candidate_budget,omitempty"`
+	RerankCandidates         *int     `json:"rerank_candidates,omitempty"`
+	RerankTrigramWeight      *float64 `json:"rerank_trigram_weight,omitempty"`
+	RerankJaroWinklerWeight  *float64 `json:"rerank_jaro_winkler_weight,omitempty"`
+}
+
+// SimulationRequest represents the request body for a decision simulation
+type SimulationRequest struct {
+	Check    CheckRequest   `json:"check"`
+	Proposed ProposedPolicy `json:"proposed"`
+}
+
+// SimulationResponse compares a check under the current and proposed policy
+type SimulationResponse struct {
+	Current        CheckResponse `json:"current"`
+	Proposed       CheckResponse `json:"proposed"`
+	CurrentPolicy  MatchPolicy   `json:"current_policy"`
+	ProposedPolicy MatchPolicy   `json:"proposed_policy"`
+	Changed        bool          `json:"changed"`
+}
+
+// DryRunRequest represents the request body for a dry-run check
+type DryRunRequest struct {
+	Check CheckRequest `json:"check"`
+	// Parameters override the current match policy for this request only;
+	// unset fields keep their current value
+	Parameters ProposedPolicy `json:"parameters"`
+}
+
+// DryRunResponse is a check evaluated under the requested parameters, with
+// every candidate record rated
+type DryRunResponse struct {
+	Result     CheckResponse `json:"result"`
+	Policy     MatchPolicy   `json:"policy"`
+	Candidates []Candidate   `json:"candidates"`
+}
+
+// Candidate is a record rated against the checked subject, highest score
+// first within each list
+type Candidate struct {
+	List       string     `json:"list"`
+	NIK        string     `json:"nik"`
+	Name       string     `json:"name"`
+	BirthPlace string     `json:"birth_place"`
+	BirthDate  *time.Time `json:"birth_date,omitempty"`
+	// Similarity is the trigram similarity of the names
+	Similarity float64       `json:"similarity"`
+	Score      float64       `json:"score"`
+	Decision   string        `json:"decision"`
+	Factors    []ScoreFactor `json:"factors"`
+	// Matched is set on the record the list matched under the parameters
+	Matched bool `json:"matched"`
+	// Excluded lists the constraints of the enabled rules the record fails,
+	// as for near misses
+	Excluded []string `json:"excluded,omitempty"`
+}
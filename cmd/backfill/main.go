@@ -0,0 +1,133 @@
+// Command backfill recomputes derived blacklist columns (phonetic codes and
+// normalized and token-sorted names) over existing rows in throttled
+// batches. With NIK_ENCRYPTION_KEY and NIK_HMAC_KEY it also stores the NIKs
+// of records, and those their history, whitelist entries, proposals and
+// review cases keep, encrypted and keyed instead of in plaintext; with
+// -force it rewrites them under the current keys after a rotation.
+//
+//	backfill -columns name_phonetic,name_sorted -batch-size 1000 -pause 200ms
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"blacklist-check/internal/backfill"
+	"blacklist-check/internal/cache"
+	"blacklist-check/internal/nikcrypt"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/tenant"
+	"blacklist-check/pkg/config"
+	"blacklist-check/pkg/log"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+func main() {
+	var (
+		columns   = flag.String("columns", "", "comma-separated columns to backfill (default: all)")
+		batchSize = flag.Int("batch-size", 1000, "rows updated per batch")
+		pause     = flag.Duration("pause", 200*time.Millisecond, "pause between batches")
+		force     = flag.Bool("force", false, "recompute every row, not just rows with empty columns")
+	)
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	if err := run(*columns, backfill.Options{BatchSize: *batchSize, Pause: *pause, Force: *force}); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(columnList string, opts backfill.Options) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	// The NIK columns can only be computed with the keys the server uses
+	keys, err := nikcrypt.FromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	columns := backfill.Available(keys)
+	if columnList != "" {
+		if columns, err = backfill.Lookup(columns, strings.Split(columnList, ",")); err != nil {
+			return err
+		}
+	}
+
+	logger, err := log.NewLogger(cfg.Server.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Database.Host, cfg.Database.Port, cfg.Database.User,
+		cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	backfiller := backfill.NewBackfiller(db, keys, logger)
+	progress, err := backfiller.Run(ctx, columns, opts)
+	if err != nil {
+		return err
+	}
+	if protectsNIKs(columns) {
+		if err := backfiller.Protect(ctx, opts); err != nil {
+			return err
+		}
+	}
+
+	// Derived columns feed matching, so cached name results may now be stale
+	if progress.Done > 0 {
+		rdb, err := cache.NewRedisClient(cfg)
+		if err != nil {
+			return err
+		}
+		defer rdb.Close()
+		tenancy, err := tenant.Tenancy(cfg)
+		if err != nil {
+			return err
+		}
+		svc, err := service.NewBlacklistService(service.Params{
+			Config: cfg,
+			Cache:  cache.NewRedis(rdb),
+			Store:  store.NewBlacklistStore(db, keys, tenancy),
+			Log:    logger,
+		})
+		if err != nil {
+			return err
+		}
+		if err := svc.InvalidateRecords(ctx); err != nil {
+			logger.Warn("Error invalidating name cache after backfill", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// protectsNIKs reports whether columns take the plaintext NIKs of records,
+// which the other tables then follow
+func protectsNIKs(columns []backfill.Column) bool {
+	for _, c := range columns {
+		if c.Name == "nik" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Command cachemigrate copies cached blacklist lookups to another Redis
+// instance with their remaining TTLs, warming a new cluster before traffic is
+// moved to it.
+//
+//	cachemigrate -target redis://:password@new-redis:6379/0 -rate 2000
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"blacklist-check/internal/cache"
+	"blacklist-check/internal/cachemigrate"
+	"blacklist-check/pkg/config"
+	"blacklist-check/pkg/log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func main() {
+	var (
+		source     = flag.String("source", "", "source Redis URL (default: the configured Redis)")
+		target     = flag.String("target", "", "target Redis URL, e.g. redis://:password@host:6379/0")
+		namespaces = flag.String("namespaces", "", "comma-separated namespaces to copy, nik or name (default: all)")
+		batchSize  = flag.Int("batch-size", 500, "keys scanned and copied per round trip")
+		rate       = flag.Int("rate", 2000, "keys scanned per second (0: unlimited)")
+		replace    = flag.Bool("replace", false, "overwrite keys the target already has")
+		dryRun     = flag.Bool("dry-run", false, "count the keys that would be copied without writing them")
+	)
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	opts := cachemigrate.Options{BatchSize: *batchSize, Rate: *rate, Replace: *replace, DryRun: *dryRun}
+	if *namespaces != "" {
+		opts.Namespaces = strings.Split(*namespaces, ",")
+	}
+	if err := run(*source, *target, opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(sourceURL, targetURL string, opts cachemigrate.Options) error {
+	if targetURL == "" {
+		return fmt.Errorf("-target is required")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	logger, err := log.NewLogger(cfg.Server.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	targetOpts, err := redis.ParseURL(targetURL)
+	if err != nil {
+		return fmt.Errorf("error parsing target URL: %w", err)
+	}
+
+	// The configured Redis may be a sentinel-managed master or a cluster, so
+	// it can only be told apart from the target when it is a single server
+	var source redis.UniversalClient
+	if sourceURL != "" {
+		sourceOpts, err := redis.ParseURL(sourceURL)
+		if err != nil {
+			return fmt.Errorf("error parsing source URL: %w", err)
+		}
+		if sourceOpts.Addr == targetOpts.Addr && sourceOpts.DB == targetOpts.DB {
+			return fmt.Errorf("source and target are the same instance")
+		}
+		source = redis.NewClient(sourceOpts)
+	} else {
+		if cfg.Redis.Mode == cache.ModeStandalone && fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port) == targetOpts.Addr && cfg.Redis.DB == targetOpts.DB {
+			return fmt.Errorf("source and target are the same instance")
+		}
+		if source, err = cache.NewRedisClient(cfg); err != nil {
+			return err
+		}
+	}
+	defer source.Close()
+	target := redis.NewClient(targetOpts)
+	defer target.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	_, err = cachemigrate.NewMigrator(source, target, logger).Run(ctx, opts)
+	return err
+}
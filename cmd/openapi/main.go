@@ -0,0 +1,39 @@
+// Command openapi writes the service's OpenAPI specification, the same
+// document served at /openapi.json, without starting the server. Client SDKs
+// are generated from it.
+//
+//	openapi -o sdk/build/openapi.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"blacklist-check/internal/api"
+)
+
+func main() {
+	output := flag.String("o", "", "file to write the specification to (default: stdout)")
+	flag.Parse()
+
+	if err := run(*output); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(output string) error {
+	spec, err := json.MarshalIndent(api.OpenAPISpec(), "", "  ")
+	if err != nil {
+		return err
+	}
+	spec = append(spec, '\n')
+
+	if output == "" {
+		_, err = os.Stdout.Write(spec)
+		return err
+	}
+	return os.WriteFile(output, spec, 0o644)
+}
@@ -0,0 +1,131 @@
+// Command pins pins blacklist records for investigation cases, so expiry,
+// deletion and list syncs leave them alone until the case closes. Results are
+// printed as JSON. Changes are attributed to -actor, or $USER. Under
+// TENANT_ISOLATION, -tenant names the tenant whose records are pinned.
+//
+//	pins pin -nik 3171234567890123 -case FRAUD-2041 -reason "disputed chargeback"
+//	pins list -case FRAUD-2041
+//	pins unpin -id 17
+//	pins close-case -case FRAUD-2041
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/nikcrypt"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/tenant"
+	"blacklist-check/pkg/config"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+const usage = `usage: pins [-actor name] [-tenant name] <command> [flags]
+
+commands:
+  pin         pin a record for a case
+  unpin       release a pin
+  list        list pins
+  close-case  release every pin of a closed case
+`
+
+func main() {
+	actor := flag.String("actor", os.Getenv("USER"), "who the changes are attributed to")
+	tenantName := flag.String("tenant", store.DefaultTenant, "tenant whose records are pinned")
+	config.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*actor, *tenantName, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(actor, tenantName, command string, args []string) error {
+	if actor == "" {
+		return fmt.Errorf("-actor is required when $USER is not set")
+	}
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	var (
+		list     = flags.String("list", lists.Internal, "list of the record")
+		nik      = flags.String("nik", "", "NIK of the record")
+		caseID   = flags.String("case", "", "investigation case ID")
+		reason   = flags.String("reason", "", "why the record is pinned")
+		id       = flags.Int64("id", 0, "pin ID")
+		released = flags.Bool("released", false, "include released pins")
+	)
+	flags.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Database.Host, cfg.Database.Port, cfg.Database.User,
+		cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx = store.WithTenant(store.WithActor(ctx, actor), tenantName)
+	tenancy, err := tenant.Tenancy(cfg)
+	if err != nil {
+		return err
+	}
+	keys, err := nikcrypt.FromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	pins := store.NewPinStore(db, keys, tenancy)
+
+	var result interface{}
+	switch command {
+	case "pin":
+		if *nik == "" || *caseID == "" {
+			return fmt.Errorf("pin needs -nik and -case")
+		}
+		result, err = pins.Pin(ctx, *list, *nik, *caseID, *reason)
+	case "unpin":
+		if *id <= 0 {
+			return fmt.Errorf("unpin needs -id")
+		}
+		result, err = pins.Unpin(ctx, *id)
+	case "list":
+		result, err = pins.List(ctx, store.PinFilter{CaseID: *caseID, NIK: *nik, IncludeReleased: *released})
+	case "close-case":
+		if *caseID == "" {
+			return fmt.Errorf("close-case needs -case")
+		}
+		result, err = pins.ReleaseCase(ctx, *caseID)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
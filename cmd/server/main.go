@@ -2,16 +2,53 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"blacklist-check/internal/admin"
 	"blacklist-check/internal/api"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/approval"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/breakglass"
+	"blacklist-check/internal/cache"
+	"blacklist-check/internal/cacheinsight"
+	"blacklist-check/internal/clock"
+	"blacklist-check/internal/database"
+	"blacklist-check/internal/deadline"
+	"blacklist-check/internal/dedup"
+	"blacklist-check/internal/events"
+	"blacklist-check/internal/expiry"
+	"blacklist-check/internal/format"
+	blacklistgrpc "blacklist-check/internal/grpc"
+	"blacklist-check/internal/idempotency"
+	"blacklist-check/internal/lifecycle"
+	"blacklist-check/internal/listsync"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/migrate"
+	"blacklist-check/internal/nikcrypt"
+	"blacklist-check/internal/nikfilter"
+	"blacklist-check/internal/payloadlog"
+	"blacklist-check/internal/profiling"
+	"blacklist-check/internal/recovery"
+	"blacklist-check/internal/reload"
+	"blacklist-check/internal/reqlog"
+	"blacklist-check/internal/rescreen"
+	"blacklist-check/internal/sandbox"
+	"blacklist-check/internal/screening"
+	"blacklist-check/internal/server"
 	"blacklist-check/internal/service"
+	"blacklist-check/internal/startup"
 	"blacklist-check/internal/store"
+	"blacklist-check/internal/tenant"
+	"blacklist-check/internal/watchdog"
+	"blacklist-check/migrations"
 	"blacklist-check/pkg/config"
 	"blacklist-check/pkg/log"
 
@@ -19,52 +56,41 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/dig"
 	"go.uber.org/zap"
 )
 
-var (
-	httpRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"method", "endpoint", "status"},
-	)
-
-	httpRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "endpoint"},
-	)
-
-	blacklistChecksTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "blacklist_checks_total",
-			Help: "Total number of blacklist checks",
-		},
-		[]string{"match_type", "result"},
-	)
-)
+func main() {
+	// Print example gRPC requests and exit
+	if len(os.Args) > 1 && os.Args[1] == "examples" {
+		addr := "localhost:9090"
+		if len(os.Args) > 2 {
+			addr = os.Args[2]
+		}
+		if err := blacklistgrpc.WriteExamples(os.Stdout, addr); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-func init() {
-	prometheus.MustRegister(httpRequestsTotal)
-	prometheus.MustRegister(httpRequestDuration)
-	prometheus.MustRegister(blacklistChecksTotal)
-}
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	// Load configuration before anything connects, so every problem with it is
+	// reported up front
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 
-func main() {
 	container := dig.New()
 
 	// Provide configuration
-	container.Provide(func() (*config.Config, error) {
-		return config.Load()
+	container.Provide(func() *config.Config {
+		return cfg
 	})
 
 	// Provide logger
@@ -72,82 +98,621 @@ func main() {
 		return log.NewLogger(cfg.Server.LogLevel)
 	})
 
-	// Provide database connection
-	container.Provide(func(cfg *config.Config) (*sqlx.DB, error) {
-		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			cfg.Database.Host, cfg.Database.Port, cfg.Database.User,
-			cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
-		return sqlx.Connect("postgres", dsn)
+	// Provide database connection for DB_DRIVER
+	container.Provide(database.Connect)
+	container.Provide(database.NewReplicas)
+	container.Provide(database.NewGuard)
+
+	// Provide Redis client for REDIS_MODE
+	container.Provide(cache.NewRedisClient)
+
+	// Provide cache over the Redis client
+	container.Provide(func(client redis.UniversalClient) cache.Cache {
+		return cache.NewRedis(client)
 	})
 
-	// Provide Redis client
-	container.Provide(func(cfg *config.Config) *redis.Client {
-		return redis.NewClient(&redis.Options{
-			Addr:     fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port),
-			Password: cfg.Redis.Password,
-			DB:       cfg.Redis.DB,
+	// Provide migrator
+	container.Provide(func(cfg *config.Config, db *sqlx.DB, log *zap.Logger) (*migrate.Migrator, error) {
+		fsys, err := migrations.For(cfg.Database.Driver)
+		if err != nil {
+			return nil, err
+		}
+		return migrate.NewMigrator(db, fsys, log)
+	})
+
+	// Provide client certificate authenticator
+	container.Provide(func(mappings store.CertMappingStore) *auth.CertAuthenticator {
+		return auth.NewCertAuthenticator(func(ctx context.Context) ([]auth.CertMapping, error) {
+			stored, err := mappings.List(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]auth.CertMapping, 0, len(stored))
+			for _, m := range stored {
+				result = append(result, auth.CertMapping{
+					Field:   m.Field,
+					Value:   m.Value,
+					Subject: m.Subject,
+					Tenant:  m.Tenant,
+					Roles:   m.Roles,
+				})
+			}
+			return result, nil
 		})
 	})
 
+	// Provide break-glass manager
+	container.Provide(breakglass.NewManager)
+
+	// Provide JWT authenticator; nil when no JWKS endpoint is configured
+	container.Provide(func(cfg *config.Config) (*auth.JWTAuthenticator, error) {
+		if cfg.Auth.JWTJWKSURL == "" {
+			return nil, nil
+		}
+		return auth.NewJWTAuthenticator(auth.JWTOptions{
+			JWKSURL:      cfg.Auth.JWTJWKSURL,
+			Issuer:       cfg.Auth.JWTIssuer,
+			Audience:     cfg.Auth.JWTAudience,
+			SubjectClaim: cfg.Auth.JWTSubjectClaim,
+			RolesClaim:   cfg.Auth.JWTRolesClaim,
+			TenantClaim:  cfg.Auth.JWTTenantClaim,
+		}, clock.Window{Tolerance: cfg.Clock.SkewTolerance})
+	})
+
+	// Provide auth policy
+	container.Provide(func(cfg *config.Config, log *zap.Logger, certs *auth.CertAuthenticator, breakGlass *breakglass.Manager, jwt *auth.JWTAuthenticator) (*auth.Policy, error) {
+		apiKeys, err := auth.NewAPIKeyAuthenticator(cfg.Auth.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		hmacKeys, err := auth.NewHMACAuthenticator(cfg.Auth.HMACKeys, clock.Window{Tolerance: cfg.Clock.SkewTolerance}, cfg.Auth.HMACMaxBodyBytes)
+		if err != nil {
+			return nil, err
+		}
+		authenticators := []auth.Authenticator{apiKeys, certs, hmacKeys, auth.NewBreakGlassAuthenticator(breakGlass.Lookup)}
+		if jwt != nil {
+			authenticators = append(authenticators, jwt)
+		}
+		return auth.NewPolicy(cfg.Auth.Policy, log, authenticators...)
+	})
+
+	// Provide payload logger
+	container.Provide(func(cfg *config.Config, log *zap.Logger) (*payloadlog.Logger, error) {
+		return payloadlog.NewLogger(cfg, log)
+	})
+
+	// Provide NIK Bloom filter
+	container.Provide(nikfilter.NewFilter)
+
+	// Provide cache insights; nil when disabled
+	container.Provide(cacheinsight.New)
+
+	// Provide deadline hint middleware
+	container.Provide(deadline.NewHints)
+
+	// Provide idempotency key replayer
+	container.Provide(idempotency.NewReplayer)
+
+	// Provide per-caller response formatter
+	container.Provide(format.NewFormatter)
+
+	// Provide panic recoverer
+	container.Provide(func(log *zap.Logger) *recovery.Recoverer {
+		return recovery.NewRecoverer(log)
+	})
+
+	// Provide tenancy
+	container.Provide(tenant.Tenancy)
+	container.Provide(tenant.NewResolver)
+
+	// Provide NIK keys; nil when NIKs are stored in plaintext
+	container.Provide(nikcrypt.FromConfig)
+
 	// Provide store
-	container.Provide(store.NewBlacklistStore)
+	container.Provide(func(cfg *config.Config, db *sqlx.DB, replicas *database.Replicas, guard *database.Guard, insights *cacheinsight.Insights, keys *nikcrypt.Keys, tenancy *store.Tenancy) (store.BlacklistStore, error) {
+		blacklistStore, err := store.NewBlacklistStoreFor(db, replicas, keys, tenancy)
+		if err != nil {
+			return nil, err
+		}
+		// Guarded under the NIK cache, so cached lookups are served while
+		// the breaker is open
+		blacklistStore = store.NewResilientBlacklistStore(blacklistStore, guard)
+		if cfg.Cache.LocalNIKEnabled {
+			return store.NewCachedBlacklistStore(blacklistStore, cfg.Cache.LocalNIKTTL, cfg.Cache.LocalNIKSize, insights, tenancy), nil
+		}
+		return blacklistStore, nil
+	})
+	container.Provide(store.NewQuarantineStore)
+	container.Provide(store.NewCertMappingStore)
+	container.Provide(store.NewScreeningStore)
+	container.Provide(store.NewEntityStore)
+	container.Provide(store.NewProposalStore)
+	container.Provide(store.NewCheckHistoryStore)
+	container.Provide(store.NewSyncStatusStore)
+	container.Provide(store.NewBreakGlassStore)
+	container.Provide(store.NewWhitelistStore)
+	container.Provide(store.NewPinStore)
+	container.Provide(store.NewRescreenStore)
+	container.Provide(store.NewCaseStore)
+	container.Provide(store.NewDuplicateStore)
+	container.Provide(store.NewActivityStore)
+	container.Provide(store.NewVersionStore)
+	container.Provide(store.NewIdempotencyStore)
+	container.Provide(store.NewPolicyStore)
+
+	// Provide screening event publisher; nil when no Kafka brokers are configured
+	container.Provide(events.NewPublisher)
 
 	// Provide service
 	container.Provide(service.NewBlacklistService)
+	container.Provide(func(s *service.BlacklistService) service.Service {
+		return s
+	})
+	container.Provide(service.NewEntityService)
+
+	// Provide syncer
+	container.Provide(listsync.NewSyncer)
+	container.Provide(listsync.NewDownloader)
+	container.Provide(listsync.NewConnector)
+
+	// Provide screening processor
+	container.Provide(screening.NewProcessor)
+	container.Provide(watchdog.NewWatchdog)
+	container.Provide(expiry.NewSweeper)
+	container.Provide(rescreen.NewScheduler)
+	container.Provide(dedup.NewDeduplicator)
 
 	// Provide handler
+	container.Provide(approval.NewWorkflow)
 	container.Provide(api.NewHandler)
+	container.Provide(api.NewSyncHandler)
+	container.Provide(api.NewMigrationHandler)
+	container.Provide(api.NewCertMappingHandler)
+	container.Provide(api.NewScreeningHandler)
+	container.Provide(api.NewEntityHandler)
+	container.Provide(api.NewBreakGlassHandler)
+	container.Provide(api.NewActivityHandler)
+	container.Provide(api.NewPolicyHandler)
+	container.Provide(api.NewStatsHandler)
+	container.Provide(api.NewCacheInsightHandler)
+	container.Provide(api.NewDuplicateHandler)
+	container.Provide(profiling.NewProfiler)
+	container.Provide(api.NewProfileHandler)
+	container.Provide(api.NewDeprecation)
+
+	// Provide reloader for the settings that can change without a restart
+	container.Provide(func(logger *zap.Logger, blacklistService *service.BlacklistService, downloader *listsync.Downloader) *reload.Reloader {
+		reloader := reload.NewReloader(logger)
+		reloader.Register("log", log.PrepareReload)
+		reloader.Register("service", blacklistService.PrepareReload)
+		reloader.Register("sync", downloader.PrepareReload)
+		return reloader
+	})
+
+	// Provide gRPC server
+	container.Provide(blacklistgrpc.NewServer)
+
+	// Provide lifecycle manager for the background components
+	container.Provide(lifecycle.NewManager)
 
 	// Start server
-	err := container.Invoke(func(
+	err = container.Invoke(func(
 		cfg *config.Config,
 		log *zap.Logger,
 		handler *api.Handler,
+		syncHandler *api.SyncHandler,
+		migrator *migrate.Migrator,
+		migrationHandler *api.MigrationHandler,
+		recoverer *recovery.Recoverer,
+		payloadLogger *payloadlog.Logger,
+		hints *deadline.Hints,
+		replayer *idempotency.Replayer,
+		formatter *format.Formatter,
+		authPolicy *auth.Policy,
+		tenantResolver *tenant.Resolver,
+		certAuthenticator *auth.CertAuthenticator,
+		jwtAuthenticator *auth.JWTAuthenticator,
+		certMappingHandler *api.CertMappingHandler,
+		grpcServer *blacklistgrpc.Server,
+		screeningProcessor *screening.Processor,
+		screeningHandler *api.ScreeningHandler,
+		blacklistStore store.BlacklistStore,
+		blacklistService *service.BlacklistService,
+		entityHandler *api.EntityHandler,
+		connector *listsync.Connector,
+		breakGlassHandler *api.BreakGlassHandler,
+		breakGlass *breakglass.Manager,
+		jobWatchdog *watchdog.Watchdog,
+		expirySweeper *expiry.Sweeper,
+		rescreenScheduler *rescreen.Scheduler,
+		deduplicator *dedup.Deduplicator,
+		activityHandler *api.ActivityHandler,
+		policyHandler *api.PolicyHandler,
+		statsHandler *api.StatsHandler,
+		cacheInsightHandler *api.CacheInsightHandler,
+		duplicateHandler *api.DuplicateHandler,
+		profileHandler *api.ProfileHandler,
+		deprecation *api.Deprecation,
+		policyStore store.PolicyStore,
+		reloader *reload.Reloader,
+		db *sqlx.DB,
+		replicas *database.Replicas,
+		redisClient redis.UniversalClient,
+		publisher *events.Publisher,
+		nikFilter *nikfilter.Filter,
+		insights *cacheinsight.Insights,
+		components *lifecycle.Manager,
 	) error {
-		r := chi.NewRouter()
-
-		// Middleware
-		r.Use(middleware.Logger)
-		r.Use(middleware.Recoverer)
-		r.Use(middleware.RequestID)
-		r.Use(middleware.RealIP)
-		r.Use(middleware.Timeout(60 * time.Second))
-
-		// Prometheus middleware
-		r.Use(func(next http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				start := time.Now()
-				ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-				next.ServeHTTP(ww, r)
-				duration := time.Since(start).Seconds()
-
-				httpRequestsTotal.WithLabelValues(r.Method, r.URL.Path, fmt.Sprintf("%d", ww.Status())).Inc()
-				httpRequestDuration.WithLabelValues(r.Method, r.URL.Path).Observe(duration)
+		// Apply pending migrations; the advisory lock serializes concurrent replicas
+		if cfg.Database.MigrateOnStart {
+			if err := migrator.Up(context.Background()); err != nil {
+				return fmt.Errorf("error running migrations: %w", err)
+			}
+		}
+
+		// Snapshot the effective policy so recorded decisions can be traced to
+		// it. The version is a digest of the settings, so a failure here is
+		// made good by the next instance started with them.
+		if err := blacklistService.SnapshotPolicy(context.Background(), policyStore); err != nil {
+			log.Error("Error snapshotting screening policy", zap.Error(err))
+		}
+
+		// Log what this instance is running with in one event for incident responders
+		startup.NewReporter(cfg, db, redisClient, migrator, blacklistService, connector, log).Log(context.Background())
+
+		// Keep client certificate mappings in sync with the admin API across
+		// replicas. They are kept in Postgres only, and the mtls method is
+		// refused on the other drivers.
+		if cfg.Database.Driver == database.Postgres {
+			components.Every("cert mapping refresh", cfg.Auth.CertRefreshInterval, func(ctx context.Context) {
+				if err := certAuthenticator.Refresh(ctx); err != nil {
+					log.Error("Error refreshing certificate mappings", zap.Error(err))
+				}
+			})
+		}
+
+		// Pick up keys the identity provider rotates in; unknown key IDs also
+		// trigger a refetch between ticks
+		if jwtAuthenticator != nil {
+			components.Every("jwks refresh", cfg.Auth.JWTJWKSRefresh, func(ctx context.Context) {
+				if err := jwtAuthenticator.Refresh(ctx); err != nil {
+					log.Error("Error refreshing JWKS", zap.Error(err))
+				}
 			})
+		}
+
+		// Warn when this host's clock drifts, as it eats into the skew tolerance.
+		// A SQLite database reads the host's own clock.
+		if cfg.Database.Driver != database.SQLite {
+			monitor := clock.NewDriftMonitor(db, cfg.Clock.DriftThreshold, log)
+			components.Every("clock drift", cfg.Clock.DriftInterval, func(ctx context.Context) {
+				if err := monitor.Check(ctx); err != nil {
+					log.Error("Error checking clock drift", zap.Error(err))
+				}
+			})
+		}
+
+		// Drop check history past its retention
+		components.Every("history prune", time.Hour, func(ctx context.Context) {
+			if err := blacklistService.PruneHistory(ctx); err != nil {
+				log.Error("Error pruning check history", zap.Error(err))
+			}
+		})
+
+		// Drop idempotency keys past their replay window
+		components.Every("idempotency prune", time.Hour, func(ctx context.Context) {
+			if err := replayer.Prune(ctx); err != nil {
+				log.Error("Error pruning idempotency keys", zap.Error(err))
+			}
+		})
+
+		// Sync external sanctions sources as they become due. A sync cut short
+		// by shutdown is picked up again once its claim lapses.
+		if connector.Enabled() {
+			components.Every("list sync", time.Minute, connector.RunDue)
+		}
+
+		// Mark jobs whose worker died or hung as stalled, alerting and reclaiming them,
+		// and alert on sources whose records went stale
+		components.Every("job watchdog", cfg.Watchdog.Interval, func(ctx context.Context) {
+			if _, err := jobWatchdog.Check(ctx); err != nil {
+				log.Error("Error checking for stalled jobs", zap.Error(err))
+			}
 		})
 
-		// Routes
-		r.Get("/healthz", handler.HealthCheck)
-		r.Post("/api/v1/blacklist", handler.CheckBlacklist)
-		r.Get("/metrics", promhttp.Handler())
+		// Notify owners of temporary records before they expire and retire them when they do
+		components.Every("expiry sweep", cfg.Temporary.SweepInterval, func(ctx context.Context) {
+			if _, err := expirySweeper.Sweep(ctx); err != nil {
+				log.Error("Error sweeping temporary records", zap.Error(err))
+			}
+		})
+
+		// Screen consenting subjects cleared earlier again as the lists change
+		if cfg.Rescreen.Enabled {
+			components.Every("rescreen", cfg.Rescreen.SweepInterval, func(ctx context.Context) {
+				if _, err := rescreenScheduler.Run(ctx); err != nil {
+					log.Error("Error re-screening subjects", zap.Error(err))
+				}
+			})
+		}
+
+		// Find the records bulk imports duplicated for review
+		if deduplicator != nil {
+			components.Every("duplicate detection", cfg.Dedup.Interval, func(ctx context.Context) {
+				if err := deduplicator.Run(ctx); err != nil {
+					log.Error("Error detecting duplicate records", zap.Error(err))
+				}
+			})
+		}
+
+		// Track the read replicas' lag, and read from the primary for a while
+		// after any record change so a lagging replica can't serve the old
+		// record to a check that caches it
+		if cfg.Database.ReplicaHosts != "" {
+			components.Every("replica health", cfg.Database.ReplicaCheckInterval, replicas.Check)
+			components.Go("replica fence", func(ctx context.Context) {
+				blacklistService.SubscribeChanges(ctx, func(...string) { replicas.Fence() })
+			})
+		}
+
+		// Evict locally cached NIK lookups when any replica changes a record
+		if cached, ok := blacklistStore.(*store.CachedBlacklistStore); ok {
+			components.Go("cache invalidation", func(ctx context.Context) {
+				blacklistService.SubscribeChanges(ctx, cached.Invalidate)
+			})
+		}
+
+		// Keep the NIK filter current between rebuilds
+		if nikFilter != nil {
+			components.Go("nik filter", nikFilter.Run)
+			components.Go("nik filter updates", func(ctx context.Context) {
+				blacklistService.SubscribeChanges(ctx, nikFilter.Add)
+			})
+		}
+
+		// Preload the most checked NIKs, failing readiness until done or
+		// out of time so no traffic arrives at cold caches
+		if cfg.Cache.WarmupEnabled {
+			handler.StartWarmingUp()
+			components.Go("cache warm-up", func(ctx context.Context) {
+				defer handler.FinishWarmingUp()
+				ctx, cancel := context.WithTimeout(ctx, cfg.Cache.WarmupTimeout)
+				defer cancel()
+				if err := blacklistService.WarmUp(ctx, cfg.Cache.WarmupNIKs); err != nil {
+					log.Error("Error warming up cache", zap.Error(err))
+				}
+			})
+		}
+
+		// Roll the cache insight window, logging the report of each one
+		components.Go("cache insights", insights.Run)
+
+		// Work through bulk screening jobs in the background. Interrupted jobs
+		// are released and resume on the next replica.
+		components.Go("screening workers", screeningProcessor.Run)
+
+		// Once the components have stopped, let the alerts and notices they
+		// raised reach their webhooks and flush the screening events queued by
+		// the last checks, which may wait out a batch first
+		components.OnStop("watchdog alerts", jobWatchdog.Wait)
+		components.OnStop("expiry notices", expirySweeper.Wait)
+		components.OnStop("re-screening alerts", rescreenScheduler.Wait)
+		components.OnStop("break-glass alerts", breakGlass.Wait)
+		components.OnStop("screening events", func(context.Context) error {
+			return publisher.Close()
+		}, lifecycle.Timeout(cfg.Server.ComponentStopTimeout+cfg.Events.BatchTimeout))
+
+		// Each listener gets its own middleware stack. The public one serves
+		// the check API; the internal one the admin API, metrics and
+		// profiling. Without an internal port one router serves everything.
+		newRouter := func(timeout time.Duration, policy *auth.Policy) chi.Router {
+			r := chi.NewRouter()
+
+			// Middleware
+			r.Use(middleware.RequestID)
+			r.Use(reqlog.Middleware(log, r))
+			r.Use(recoverer.Middleware)
+			r.Use(middleware.RealIP)
+			r.Use(middleware.Timeout(timeout))
+
+			// Prometheus middleware
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					start := time.Now()
+					ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+					next.ServeHTTP(ww, r)
+					duration := time.Since(start).Seconds()
+
+					metrics.HTTPRequestsTotal.WithLabelValues(r.Method, r.URL.Path, fmt.Sprintf("%d", ww.Status())).Inc()
+					metrics.HTTPRequestDuration.WithLabelValues(r.Method, r.URL.Path).Observe(duration)
+				})
+			})
+
+			// Redacted request and response bodies, logged before auth so rejected requests are covered
+			r.Use(payloadLogger.Middleware)
+
+			// Authentication policy, enforced centrally for every route
+			r.Use(policy.Middleware)
+			if cfg.Sandbox.Enabled {
+				// Sandbox callers may only screen, never reach production data
+				r.Use(sandbox.Middleware(cfg.Sandbox.Tenant))
+			}
+			// Tenant of the authenticated caller, and its rate limit
+			r.Use(tenantResolver.Middleware)
+			// Caller of each request, for its logs
+			r.Use(reqlog.Identify)
+
+			r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+				apierror.NotFound(w, r, "Route not found")
+			})
+			r.MethodNotAllowed(apierror.MethodNotAllowed)
+			return r
+		}
+		split := cfg.Server.InternalPort != 0
+		public := newRouter(cfg.Server.RequestTimeout, authPolicy)
+		internal := public
+		if split {
+			// The internal listener may hold its callers to a policy of its own
+			internalPolicy := authPolicy
+			if cfg.Server.InternalAuthPolicy != "" {
+				var err error
+				if internalPolicy, err = authPolicy.WithSpec(cfg.Server.InternalAuthPolicy); err != nil {
+					return fmt.Errorf("error loading internal auth policy: %w", err)
+				}
+			}
+			internal = newRouter(cfg.Server.InternalRequestTimeout, internalPolicy)
+		}
+
+		// Public routes
+		public.Get("/healthz", handler.HealthCheck)
+		// Why a dependency is down is only told inside the internal zone
+		public.Get("/readyz", handler.PublicReadinessCheck)
+		public.Get("/openapi.json", handler.OpenAPI)
+		public.Get("/docs", handler.Docs)
+		// Every API version serves the same routes, shaping check results
+		// its own way; v1 is deprecated in favour of v2
+		versionRoutes := func(r chi.Router, prefix string, check, checkQuery http.HandlerFunc) {
+			r.With(hints.Middleware, replayer.Middleware, formatter.Middleware).Post(prefix+"/blacklist", check)
+			if cfg.Server.CheckGetEnabled {
+				r.With(hints.Middleware, formatter.Middleware).Get(prefix+"/blacklist/check", checkQuery)
+			}
+			r.Get(prefix+"/profiles", handler.ListProfiles)
+			r.Post(prefix+"/blacklist/simulate", handler.DryRunCheck)
+			r.With(replayer.Middleware, formatter.Middleware).Post(prefix+"/blacklist/entity", entityHandler.CheckEntity)
+			r.With(replayer.Middleware, formatter.Middleware).Post(prefix+"/screenings", screeningHandler.CreateScreening)
+			r.With(formatter.Middleware).Get(prefix+"/screenings/{id}", screeningHandler.GetScreening)
+			r.With(formatter.Middleware).Get(prefix+"/screenings/{id}/results", screeningHandler.GetScreeningResults)
+		}
+		versionRoutes(public.With(deprecation.Middleware), api.PrefixV1, handler.CheckBlacklist, handler.CheckBlacklistQuery)
+		versionRoutes(public, api.PrefixV2, handler.CheckBlacklistV2, handler.CheckBlacklistQueryV2)
+
+		// Internal routes
+		if split {
+			internal.Get("/healthz", handler.HealthCheck)
+			internal.Get("/readyz", handler.ReadinessCheck)
+			internal.Get("/openapi.json", handler.OpenAPI)
+			internal.Get("/docs", handler.Docs)
+			if cfg.Server.InternalPprof {
+				internal.Mount("/debug", middleware.Profiler())
+				internal.Post("/api/v1/admin/runtime/profiles", profileHandler.CaptureProfile)
+			}
+		}
+		internal.Get("/api/v1/admin/readyz", handler.ReadinessCheck)
+		internal.Handle(admin.Prefix, admin.Handler())
+		internal.Handle(admin.Prefix+"/*", admin.Handler())
+		internal.Get("/api/v1/blacklist/records", handler.ListRecords)
+		internal.Get("/api/v1/blacklist/export", handler.ExportRecords)
+		internal.Post("/api/v1/admin/screenings/{id}/requeue", screeningHandler.RequeueScreening)
+		internal.Get("/api/v1/admin/records", handler.SearchRecords)
+		internal.Post("/api/v1/admin/records", handler.CreateRecord)
+		internal.Put("/api/v1/admin/records/{nik}", handler.UpdateRecord)
+		internal.Delete("/api/v1/admin/records/{nik}", handler.DeleteRecord)
+		internal.Post("/api/v1/admin/records/{nik}/restore", handler.RestoreRecord)
+		internal.Post("/api/v1/admin/records/temporary", handler.CreateTemporaryRecord)
+		internal.Post("/api/v1/admin/records/{nik}/confirm", handler.ConfirmRecord)
+		internal.Post("/api/v1/admin/records/{nik}/extend", handler.ExtendRecord)
+		internal.Get("/api/v1/admin/records/{nik}/history", handler.RecordHistory)
+		internal.Get("/api/v1/admin/proposals", handler.ListProposals)
+		internal.Get("/api/v1/admin/proposals/{id}", handler.GetProposal)
+		internal.Post("/api/v1/admin/proposals/{id}/approve", handler.ApproveProposal)
+		internal.Post("/api/v1/admin/proposals/{id}/reject", handler.RejectProposal)
+		internal.Get("/api/v1/admin/duplicates", duplicateHandler.ListDuplicates)
+		internal.Get("/api/v1/admin/duplicates/{id}", duplicateHandler.GetDuplicate)
+		internal.Post("/api/v1/admin/duplicates/{id}/merge", duplicateHandler.MergeDuplicate)
+		internal.Post("/api/v1/admin/duplicates/{id}/dismiss", duplicateHandler.DismissDuplicate)
+		internal.Get("/api/v1/admin/checks", handler.RecentChecks)
+		internal.Post("/api/v1/admin/cache/recache", handler.Recache)
+		internal.Get("/api/v1/admin/policies", policyHandler.ListPolicies)
+		internal.Get("/api/v1/admin/policies/{version}", policyHandler.GetPolicy)
+		internal.Get("/api/v1/admin/whitelist", handler.ListWhitelist)
+		internal.Post("/api/v1/admin/whitelist", handler.CreateWhitelistEntry)
+		internal.Post("/api/v1/admin/whitelist/{id}/revoke", handler.RevokeWhitelistEntry)
+		internal.Post("/api/v1/admin/records/{nik}/pins", handler.PinRecord)
+		internal.Get("/api/v1/admin/pins", handler.ListPins)
+		internal.Post("/api/v1/admin/pins/{id}/unpin", handler.UnpinRecord)
+		internal.Post("/api/v1/admin/cases/{caseID}/close", handler.CloseCase)
+		internal.Get("/api/v1/admin/review-cases", handler.ListCases)
+		internal.Get("/api/v1/admin/review-cases/{id}", handler.GetCase)
+		internal.Patch("/api/v1/admin/review-cases/{id}", handler.UpdateCase)
+		internal.Get("/api/v1/admin/sync/status", syncHandler.SyncStatus)
+		internal.Get("/api/v1/admin/sync/quarantine", syncHandler.ListQuarantined)
+		internal.Post("/api/v1/admin/sync/quarantine/{id}/approve", syncHandler.ApproveQuarantined)
+		internal.Post("/api/v1/admin/sync/quarantine/{id}/reject", syncHandler.RejectQuarantined)
+		internal.Post("/api/v1/breakglass", breakGlassHandler.IssueBreakGlass)
+		internal.Get("/api/v1/admin/breakglass", breakGlassHandler.ListBreakGlass)
+		internal.Post("/api/v1/admin/breakglass/{id}/revoke", breakGlassHandler.RevokeBreakGlass)
+		internal.Get("/api/v1/admin/breakglass/{id}/audit", breakGlassHandler.BreakGlassAudit)
+		internal.Get("/api/v1/admin/cert-mappings", certMappingHandler.ListCertMappings)
+		internal.Post("/api/v1/admin/cert-mappings", certMappingHandler.CreateCertMapping)
+		internal.Delete("/api/v1/admin/cert-mappings/{id}", certMappingHandler.DeleteCertMapping)
+		internal.Get("/api/v1/admin/activity", activityHandler.ListActivity)
+		internal.Get("/api/v1/audit/export", activityHandler.ExportActivity)
+		internal.Get("/api/v1/admin/migrations", migrationHandler.PendingMigrations)
+		internal.Get("/api/v1/admin/stats", statsHandler.Stats)
+		internal.Get("/api/v1/admin/cache/insights", cacheInsightHandler.CacheInsights)
+		internal.Post("/api/v1/admin/simulate", handler.Simulate)
+		internal.Method(http.MethodGet, "/metrics", promhttp.Handler())
 
-		// Start server
+		// Start servers
 		srv := &http.Server{
 			Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
-			Handler: r,
+			Handler: public,
+		}
+		var internalSrv *http.Server
+		if split {
+			internalSrv = &http.Server{
+				Addr:    fmt.Sprintf(":%d", cfg.Server.InternalPort),
+				Handler: internal,
+			}
 		}
 
+		// Start gRPC server
+		grpcSrv := grpcServer.Register(recoverer, authPolicy, tenantResolver, cfg.Server.GRPCReflection)
+		grpcLn, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.GRPCPort))
+		if err != nil {
+			return fmt.Errorf("error creating gRPC listener: %w", err)
+		}
+		go func() {
+			log.Info("Starting gRPC server",
+				zap.Int("port", cfg.Server.GRPCPort),
+				zap.Bool("reflection", cfg.Server.GRPCReflection))
+			if err := grpcSrv.Serve(grpcLn); err != nil {
+				log.Error("gRPC server stopped", zap.Error(err))
+			}
+		}()
+
 		// Server run context
 		serverCtx, serverStopCtx := context.WithCancel(context.Background())
 
+		// Reload the tunable settings on SIGHUP, snapshotting a changed policy
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := reloader.Reload(); err != nil {
+					log.Error("Error reloading configuration", zap.Error(err))
+					continue
+				}
+				if err := blacklistService.SnapshotPolicy(context.Background(), policyStore); err != nil {
+					log.Error("Error snapshotting screening policy", zap.Error(err))
+				}
+			}
+		}()
+
 		// Listen for syscall signals for process to interrupt/quit
 		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 		go func() {
 			<-sig
 
-			// Shutdown signal with grace period of 30 seconds
-			shutdownCtx, shutdownCancel := context.WithTimeout(serverCtx, 30*time.Second)
+			// Drain: fail readiness first so the load balancer stops sending
+			// new requests, then give it time to notice before closing the listener
+			handler.StartDraining()
+			log.Info("Draining before shutdown", zap.Duration("delay", cfg.Server.DrainDelay))
+			time.Sleep(cfg.Server.DrainDelay)
+
+			// Stop accepting and wait for in-flight requests within the grace period
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 			defer shutdownCancel()
 
 			go func() {
@@ -158,16 +723,60 @@ func main() {
 			}()
 
 			// Trigger graceful shutdown
+			go grpcSrv.GracefulStop()
+			internalDone := make(chan struct{})
+			go func() {
+				defer close(internalDone)
+				if internalSrv != nil {
+					if err := internalSrv.Shutdown(shutdownCtx); err != nil {
+						log.Error("Error shutting down internal server", zap.Error(err))
+					}
+				}
+			}()
 			err := srv.Shutdown(shutdownCtx)
 			if err != nil {
 				log.Fatal(err.Error())
 			}
+			<-internalDone
+			shutdownCancel()
+
+			// Stop the background components, each within its own timeout
+			if err := components.Shutdown(); err != nil {
+				log.Error("Error stopping background components", zap.Error(err))
+			} else {
+				log.Info("Background components stopped")
+			}
 			serverStopCtx()
 		}()
 
+		// Bind the listener (systemd socket, SO_REUSEPORT or plain)
+		ln, err := server.Listen(serverCtx, cfg, log)
+		if err != nil {
+			return fmt.Errorf("error creating listener: %w", err)
+		}
+
+		if internalSrv != nil {
+			internalLn, err := server.ListenInternal(serverCtx, cfg, log)
+			if err != nil {
+				return fmt.Errorf("error creating internal listener: %w", err)
+			}
+			go func() {
+				log.Info("Starting internal server",
+					zap.String("addr", internalLn.Addr().String()),
+					zap.Bool("tls", cfg.Server.InternalTLSCertFile != ""),
+					zap.Bool("pprof", cfg.Server.InternalPprof))
+				if err := internalSrv.Serve(internalLn); err != nil && err != http.ErrServerClosed {
+					log.Fatal(err.Error())
+				}
+			}()
+		}
+
 		// Run the server
-		log.Info("Starting server", zap.Int("port", cfg.Server.Port))
-		err := srv.ListenAndServe()
+		log.Info("Starting server",
+			zap.String("addr", ln.Addr().String()),
+			zap.Bool("reuse_port", cfg.Server.ReusePort),
+			zap.Bool("tls", cfg.Server.TLSCertFile != ""))
+		err = srv.Serve(ln)
 		if err != nil && err != http.ErrServerClosed {
 			log.Fatal(err.Error())
 		}
@@ -182,4 +791,4 @@ func main() {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,151 @@
+// Command whatif replays recent production checks under a proposed match
+// policy and reports the decisions that would flip. Flipped checks are written
+// as CSV; the summary is printed as JSON.
+//
+//	whatif -since 168h -sample 10000 -min-similarity 0.4 -output flips.csv
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"blacklist-check/internal/nikcrypt"
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/tenant"
+	"blacklist-check/internal/whatif"
+	"blacklist-check/pkg/config"
+	"blacklist-check/pkg/log"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	var (
+		since         = flag.Duration("since", 7*24*time.Hour, "replay checks made within this window")
+		sample        = flag.Int("sample", 0, "replay a random sample of at most this many checks (default: all)")
+		minSimilarity = flag.Float64("min-similarity", 0, "proposed MATCH_MIN_SIMILARITY (default: current)")
+		rules         = flag.String("rules", "", "proposed comma-separated MATCH_RULES (default: current)")
+		output        = flag.String("output", "whatif.csv", "file to write flipped checks to")
+	)
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	opts := whatif.Options{Since: *since, Sample: *sample}
+	if err := run(opts, *minSimilarity, *rules, *output); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts whatif.Options, minSimilarity float64, rules, output string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	logger, err := log.NewLogger(cfg.Server.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Database.Host, cfg.Database.Port, cfg.Database.User,
+		cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	keys, err := nikcrypt.FromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	tenancy, err := tenant.Tenancy(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Simulations bypass the cache and never record history, so neither Redis
+	// nor a history writer is needed
+	svc, err := service.NewBlacklistService(service.Params{
+		Config: cfg,
+		Store:  store.NewBlacklistStore(db, keys, tenancy),
+		Log:    logger,
+	})
+	if err != nil {
+		return err
+	}
+
+	proposed := svc.Policy()
+	if minSimilarity != 0 {
+		proposed.MinSimilarity = minSimilarity
+	}
+	if rules != "" {
+		proposed.Rules = strings.Split(rules, ",")
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"checked_at", "name", "nik", "birth_place", "birth_date",
+		"current_blacklisted", "current_match_type", "proposed_blacklisted", "proposed_match_type", "details"})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := whatif.NewAnalyzer(store.NewCheckHistoryStore(db, tenancy), svc, logger).Run(ctx, opts, proposed, func(flip whatif.Flip) error {
+		birthDate := ""
+		if flip.Entry.BirthDate != nil {
+			birthDate = flip.Entry.BirthDate.Format("2006-01-02")
+		}
+		// Show the reason of whichever side blacklists
+		details := flip.Proposed.Describe(reason.DefaultLocale)
+		if details == "" {
+			details = flip.Current.Describe(reason.DefaultLocale)
+		}
+		return w.Write([]string{
+			flip.Entry.CheckedAt.Format(time.RFC3339),
+			flip.Entry.Name,
+			flip.Entry.NIK,
+			flip.Entry.BirthPlace,
+			birthDate,
+			strconv.FormatBool(flip.Current.Blacklisted),
+			flip.Current.MatchType,
+			strconv.FormatBool(flip.Proposed.Blacklisted),
+			flip.Proposed.MatchType,
+			details,
+		})
+	})
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing output file: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		CurrentPolicy  service.MatchPolicy `json:"current_policy"`
+		ProposedPolicy service.MatchPolicy `json:"proposed_policy"`
+		*whatif.Report
+	}{svc.Policy(), proposed, report})
+}
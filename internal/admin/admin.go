@@ -0,0 +1,39 @@
+// Package admin serves the embedded blacklist management UI. The UI is
+// static: it holds no data and calls the admin API with the credentials the
+// operator enters, so the API's auth policy governs every action it takes.
+package admin
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Prefix is the path the UI is served under
+const Prefix = "/admin"
+
+// Handler serves the UI's assets under Prefix
+func Handler() http.Handler {
+	assets, err := fs.Sub(static, "static")
+	if err != nil {
+		// The embedded tree is fixed at build time
+		panic(err)
+	}
+	files := http.StripPrefix(Prefix, http.FileServer(http.FS(assets)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == Prefix {
+			http.Redirect(w, r, Prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+		h := w.Header()
+		h.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Cache-Control", "no-cache")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,407 @@
+"use strict";
+
+// Lists records can belong to, as defined in internal/lists
+const LISTS = ["internal", "sanctions", "pep"];
+const CREDENTIAL_KEY = "blacklist-admin-credential";
+
+const $ = (id) => document.getElementById(id);
+
+// el builds an element; children are appended as text unless they are nodes,
+// so values from the API are never interpreted as HTML
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key.startsWith("on")) {
+      node.addEventListener(key.slice(2), value);
+    } else {
+      node.setAttribute(key, value);
+    }
+  }
+  for (const child of children) {
+    node.append(child instanceof Node ? child : String(child ?? ""));
+  }
+  return node;
+}
+
+function credential() {
+  const stored = sessionStorage.getItem(CREDENTIAL_KEY);
+  return stored ? JSON.parse(stored) : null;
+}
+
+function notify(message, kind) {
+  const box = el("div", { class: kind || "info" }, message);
+  $("status").replaceChildren(box);
+  setTimeout(() => box.remove(), 5000);
+}
+
+// api calls the service with the operator's credential and returns the
+// decoded body, throwing the API error message on failure
+async function api(method, path, body) {
+  const cred = credential();
+  const headers = { [cred.type]: cred.value };
+  if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+  }
+  const resp = await fetch(path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  if (resp.status === 401) {
+    signOut();
+    throw new Error("Credential rejected, sign in again");
+  }
+  if (resp.status === 204) {
+    return null;
+  }
+  const data = await resp.json().catch(() => null);
+  if (!resp.ok) {
+    throw new Error((data && data.message) || resp.statusText);
+  }
+  return data;
+}
+
+const date = (value) => (value ? value.slice(0, 10) : "");
+const timestamp = (value) => (value ? new Date(value).toLocaleString() : "");
+const toRFC3339 = (value) => (value ? value + "T00:00:00Z" : undefined);
+
+// Sign-in
+
+function signIn(type, value) {
+  sessionStorage.setItem(CREDENTIAL_KEY, JSON.stringify({ type, value }));
+  showApp();
+}
+
+function signOut() {
+  sessionStorage.removeItem(CREDENTIAL_KEY);
+  $("app").classList.add("hidden");
+  $("sign-out").classList.add("hidden");
+  $("sign-in").classList.remove("hidden");
+  $("whoami").textContent = "";
+}
+
+function showApp() {
+  const cred = credential();
+  $("sign-in").classList.add("hidden");
+  $("app").classList.remove("hidden");
+  $("sign-out").classList.remove("hidden");
+  $("whoami").textContent = cred.type === "X-Break-Glass-Token" ? "Break-glass session" : "";
+  searchRecords();
+  loadProfiles();
+}
+
+// Tabs
+
+function showTab(name) {
+  for (const tab of document.querySelectorAll(".tab")) {
+    tab.classList.toggle("hidden", tab.id !== name);
+  }
+  for (const button of document.querySelectorAll("nav button")) {
+    button.classList.toggle("active", button.dataset.tab === name);
+  }
+  if (name === "checks") {
+    loadChecks();
+  }
+}
+
+// Records
+
+// Cursor of the next page when browsing without a search term
+let nextCursor = "";
+
+async function searchRecords() {
+  const form = $("search-form");
+  const params = new URLSearchParams({ list: form.elements.list.value });
+  if (form.elements.deleted.checked) {
+    params.set("deleted", "true");
+  }
+  try {
+    if (form.elements.q.value) {
+      // A search ranks by similarity and fits on one page
+      params.set("q", form.elements.q.value);
+      const records = await api("GET", "/api/v1/admin/records?" + params);
+      $("records-body").replaceChildren(...records.map(recordRow));
+      setNextCursor("");
+    } else {
+      params.set("sort", "-updated_at");
+      const page = await api("GET", "/api/v1/blacklist/records?" + params);
+      $("records-body").replaceChildren(...page.records.map(recordRow));
+      setNextCursor(page.next_cursor);
+    }
+  } catch (err) {
+    notify(err.message, "error");
+  }
+}
+
+async function loadMoreRecords() {
+  const form = $("search-form");
+  const params = new URLSearchParams({ list: form.elements.list.value, sort: "-updated_at", cursor: nextCursor });
+  if (form.elements.deleted.checked) {
+    params.set("deleted", "true");
+  }
+  try {
+    const page = await api("GET", "/api/v1/blacklist/records?" + params);
+    $("records-body").append(...page.records.map(recordRow));
+    setNextCursor(page.next_cursor);
+  } catch (err) {
+    notify(err.message, "error");
+  }
+}
+
+function setNextCursor(cursor) {
+  nextCursor = cursor || "";
+  $("records-more").classList.toggle("hidden", !nextCursor);
+}
+
+function recordRow(record) {
+  const actions = record.deleted_at
+    ? [el("button", { onclick: () => restoreRecord(record) }, "Restore")]
+    : [
+        el("button", { onclick: () => editRecord(record) }, "Edit"),
+        el("button", { onclick: () => deleteRecord(record) }, "Delete"),
+      ];
+  actions.push(el("button", { onclick: () => showHistory(record.nik) }, "History"));
+
+  return el("tr", record.deleted_at ? { class: "deleted" } : {},
+    el("td", {}, record.nik),
+    el("td", {}, record.name),
+    el("td", {}, record.birth_place),
+    el("td", {}, date(record.birth_date)),
+    el("td", {}, record.reason_code || record.reason),
+    el("td", {}, record.source),
+    el("td", {}, timestamp(record.updated_at)),
+    el("td", {}, ...actions));
+}
+
+async function deleteRecord(record) {
+  if (!confirm(`Delete ${record.name} (${record.nik}) from the ${record.list} list?`)) {
+    return;
+  }
+  try {
+    await api("DELETE", `/api/v1/admin/records/${encodeURIComponent(record.nik)}?list=${record.list}`);
+    notify("Record deleted");
+    searchRecords();
+  } catch (err) {
+    notify(err.message, "error");
+  }
+}
+
+async function restoreRecord(record) {
+  try {
+    await api("POST", `/api/v1/admin/records/${encodeURIComponent(record.nik)}/restore?list=${record.list}`);
+    notify("Record restored");
+    searchRecords();
+  } catch (err) {
+    notify(err.message, "error");
+  }
+}
+
+async function showHistory(nik) {
+  try {
+    const changes = await api("GET", `/api/v1/admin/records/${encodeURIComponent(nik)}/history`);
+    $("history-nik").textContent = nik;
+    $("history-body").replaceChildren(...changes.reverse().map((change) =>
+      el("tr", {},
+        el("td", {}, timestamp(change.changed_at)),
+        el("td", {}, change.action),
+        el("td", {}, change.changed_by),
+        el("td", {}, el("pre", {}, JSON.stringify(diff(change.before, change.after), null, 2))))));
+    $("history").classList.remove("hidden");
+  } catch (err) {
+    notify(err.message, "error");
+  }
+}
+
+// diff keeps the fields a change touched
+function diff(before, after) {
+  if (!before || !after) {
+    return after || before;
+  }
+  const changed = {};
+  for (const key of Object.keys(after)) {
+    if (JSON.stringify(before[key]) !== JSON.stringify(after[key])) {
+      changed[key] = { from: before[key], to: after[key] };
+    }
+  }
+  return changed;
+}
+
+// Editor
+
+let editing = null;
+
+function editRecord(record) {
+  editing = record;
+  const form = $("record-form");
+  form.elements.list.value = record.list;
+  form.elements.list.disabled = true;
+  form.elements.nik.value = record.nik;
+  form.elements.nik.readOnly = true;
+  form.elements.name.value = record.name;
+  form.elements.birth_place.value = record.birth_place;
+  form.elements.birth_date.value = date(record.birth_date);
+  form.elements.reason.value = record.reason;
+  form.elements.reason_code.value = record.reason_code || "";
+  form.elements.reason_params.value = record.reason_params ? JSON.stringify(record.reason_params) : "";
+  $("editor-title").textContent = `Edit ${record.nik}`;
+  showTab("editor");
+}
+
+function resetEditor() {
+  editing = null;
+  const form = $("record-form");
+  form.reset();
+  form.elements.list.disabled = false;
+  form.elements.nik.readOnly = false;
+  $("editor-title").textContent = "Add record";
+}
+
+async function saveRecord(form) {
+  let reasonParams;
+  if (form.elements.reason_params.value.trim()) {
+    try {
+      reasonParams = JSON.parse(form.elements.reason_params.value);
+    } catch (err) {
+      notify("Reason params must be a JSON object", "error");
+      return;
+    }
+  }
+  const body = {
+    list: form.elements.list.value,
+    nik: form.elements.nik.value,
+    name: form.elements.name.value,
+    birth_place: form.elements.birth_place.value,
+    birth_date: toRFC3339(form.elements.birth_date.value),
+    reason: form.elements.reason.value,
+    reason_code: form.elements.reason_code.value || undefined,
+    reason_params: reasonParams,
+  };
+  try {
+    if (editing) {
+      await api("PUT", `/api/v1/admin/records/${encodeURIComponent(editing.nik)}?list=${editing.list}`, body);
+      notify("Record updated");
+    } else {
+      await api("POST", "/api/v1/admin/records", body);
+      notify("Record created");
+    }
+    resetEditor();
+    showTab("records");
+    searchRecords();
+  } catch (err) {
+    notify(err.message, "error");
+  }
+}
+
+// Test check
+
+async function runCheck(form) {
+  const body = {
+    name: form.elements.name.value,
+    nik: form.elements.nik.value || undefined,
+    birth_place: form.elements.birth_place.value || undefined,
+    birth_date: toRFC3339(form.elements.birth_date.value),
+    profile: form.elements.profile.value || undefined,
+    diagnostics: form.elements.diagnostics.checked || undefined,
+  };
+  try {
+    const result = await api("POST", "/api/v1/blacklist", body);
+    $("check-result").replaceChildren(checkResult(result));
+  } catch (err) {
+    notify(err.message, "error");
+  }
+}
+
+function checkResult(result) {
+  const summary = el("p", {},
+    el("span", result.blacklisted ? { class: "matched" } : {}, result.blacklisted ? "Blacklisted" : "Not blacklisted"),
+    ` (${result.match_type}${result.confidence ? `, confidence ${result.confidence.toFixed(2)}` : ""})`,
+    result.details ? `: ${result.details}` : "");
+
+  const rows = (result.results || []).map((r) =>
+    el("tr", {},
+      el("td", {}, r.list),
+      el("td", r.matched ? { class: "matched" } : {}, r.matched ? "yes" : "no"),
+      el("td", {}, r.match_type),
+      el("td", {}, r.details || ""),
+      el("td", {}, ...(r.near_misses || []).map((miss) =>
+        el("div", {}, `${miss.name} (${miss.nik}), similarity ${miss.similarity.toFixed(2)}: ${(miss.excluded || []).join(", ")}`)))));
+  const table = el("table", {},
+    el("thead", {}, el("tr", {}, ...["List", "Matched", "Match type", "Details", "Near misses"].map((h) => el("th", {}, h)))),
+    el("tbody", {}, ...rows));
+
+  const id = result.national_id ? el("pre", {}, JSON.stringify(result.national_id, null, 2)) : "";
+  return el("div", {}, summary, table, id);
+}
+
+async function loadProfiles() {
+  try {
+    const profiles = await api("GET", "/api/v1/profiles");
+    $("check-form").elements.profile.replaceChildren(
+      el("option", { value: "" }, "Caller default"),
+      ...profiles.map((p) => el("option", { value: p.name, title: p.description }, p.name)));
+  } catch (err) {
+    notify(err.message, "error");
+  }
+}
+
+// Recent checks
+
+async function loadChecks() {
+  try {
+    const checks = await api("GET", "/api/v1/admin/checks?limit=100");
+    $("checks-body").replaceChildren(...checks.map((check) =>
+      el("tr", {},
+        el("td", {}, timestamp(check.checked_at)),
+        el("td", {}, check.name),
+        el("td", {}, check.nik || ""),
+        el("td", {}, date(check.birth_date)),
+        el("td", {}, (check.lists || []).join(", ")),
+        el("td", check.blacklisted ? { class: "matched" } : {}, check.blacklisted ? "yes" : "no"),
+        el("td", {}, check.match_type))));
+  } catch (err) {
+    notify(err.message, "error");
+  }
+}
+
+// Wiring
+
+for (const select of document.querySelectorAll("select[name=list]")) {
+  select.replaceChildren(...LISTS.map((list) => el("option", { value: list }, list)));
+}
+
+$("sign-in-form").addEventListener("submit", (e) => {
+  e.preventDefault();
+  signIn(e.target.elements.type.value, e.target.elements.credential.value);
+  e.target.reset();
+});
+$("sign-out").addEventListener("click", signOut);
+for (const button of document.querySelectorAll("nav button")) {
+  button.addEventListener("click", () => {
+    if (button.dataset.tab === "editor") {
+      resetEditor();
+    }
+    showTab(button.dataset.tab);
+  });
+}
+$("search-form").addEventListener("submit", (e) => {
+  e.preventDefault();
+  searchRecords();
+});
+$("record-form").addEventListener("submit", (e) => {
+  e.preventDefault();
+  saveRecord(e.target);
+});
+$("editor-cancel").addEventListener("click", () => {
+  resetEditor();
+  showTab("records");
+});
+$("check-form").addEventListener("submit", (e) => {
+  e.preventDefault();
+  runCheck(e.target);
+});
+$("checks-refresh").addEventListener("click", loadChecks);
+$("records-more").addEventListener("click", loadMoreRecords);
+
+if (credential()) {
+  showApp();
+}
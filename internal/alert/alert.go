@@ -0,0 +1,71 @@
+// Package alert posts JSON alerts to a webhook. Deliveries run in the
+// background so they never hold up the caller, and Wait lets shutdown wait
+// for the ones still in flight.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"blacklist-check/internal/lifecycle"
+
+	"go.uber.org/zap"
+)
+
+// deliveryTimeout bounds each webhook delivery
+const deliveryTimeout = 5 * time.Second
+
+// Poster delivers alerts of one kind to a webhook. A poster without a
+// webhook drops every alert, so callers can post unconditionally.
+type Poster struct {
+	webhook string
+	// kind names the alerts in logs, e.g. "break-glass alert"
+	kind   string
+	client *http.Client
+	log    *zap.Logger
+
+	deliveries lifecycle.Group
+}
+
+// NewPoster creates a poster for webhook, which may be empty
+func NewPoster(webhook, kind string, log *zap.Logger) *Poster {
+	return &Poster{
+		webhook: webhook,
+		kind:    kind,
+		client:  &http.Client{Timeout: deliveryTimeout},
+		log:     log,
+	}
+}
+
+// Post encodes alert and sends it to the webhook without holding up the
+// caller. Failures are logged.
+func (p *Poster) Post(alert interface{}) {
+	if p.webhook == "" {
+		return
+	}
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		p.log.Error("Error encoding alert", zap.String("kind", p.kind), zap.Error(err))
+		return
+	}
+
+	p.deliveries.Go(func() {
+		resp, err := p.client.Post(p.webhook, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			p.log.Error("Error sending alert", zap.String("kind", p.kind), zap.Error(err))
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			p.log.Error("Alert rejected", zap.String("kind", p.kind), zap.String("status", resp.Status))
+		}
+	})
+}
+
+// Wait waits for alerts still being delivered to the webhook, or until ctx is done
+func (p *Poster) Wait(ctx context.Context) error {
+	return p.deliveries.Wait(ctx)
+}
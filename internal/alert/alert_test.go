@@ -0,0 +1,52 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPostDelivers(t *testing.T) {
+	var mu sync.Mutex
+	var got []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+		mu.Lock()
+		got = append(got, body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	p := NewPoster(srv.URL, "test alert", zap.NewNop())
+	p.Post(map[string]string{"event": "one"})
+	p.Post(map[string]string{"event": "two"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Wait(ctx); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("delivered %d alerts, want 2", len(got))
+	}
+}
+
+func TestPostWithoutWebhook(t *testing.T) {
+	p := NewPoster("", "test alert", zap.NewNop())
+	p.Post(map[string]string{"event": "dropped"})
+	if err := p.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() = %v, want nil", err)
+	}
+}
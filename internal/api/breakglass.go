@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/breakglass"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// breakGlassListWindow is how far back grants are listed by default
+const breakGlassListWindow = 30 * 24 * time.Hour
+
+// BreakGlassHandler handles emergency access requests
+type BreakGlassHandler struct {
+	manager *breakglass.Manager
+	log     *zap.Logger
+}
+
+// NewBreakGlassHandler creates a new break-glass handler
+func NewBreakGlassHandler(manager *breakglass.Manager, log *zap.Logger) *BreakGlassHandler {
+	return &BreakGlassHandler{
+		manager: manager,
+		log:     log,
+	}
+}
+
+// breakGlassRequest represents the request body for a break-glass grant
+type breakGlassRequest struct {
+	Justification string `json:"justification"`
+	// Duration such as "30m"; defaults to BREAKGLASS_DEFAULT_TTL
+	Duration string `json:"duration,omitempty"`
+}
+
+// breakGlassResponse returns a new grant with its token, shown only once
+type breakGlassResponse struct {
+	ID        int64     `json:"id"`
+	Token     string    `json:"token"`
+	Roles     []string  `json:"roles"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IssueBreakGlass handles requests for emergency admin access
+func (h *BreakGlassHandler) IssueBreakGlass(w http.ResponseWriter, r *http.Request) {
+	var req breakGlassRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+
+	var ttl time.Duration
+	if req.Duration != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.Duration); err != nil {
+			apierror.Validation(w, r, "duration must be a duration such as 30m", nil)
+			return
+		}
+	}
+
+	issued, err := h.manager.Issue(r.Context(), auth.FromContext(r.Context()), req.Justification, ttl)
+	switch {
+	case errors.Is(err, breakglass.ErrIneligible):
+		apierror.Forbidden(w, r)
+		return
+	case errors.Is(err, breakglass.ErrJustification), errors.Is(err, breakglass.ErrDuration):
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error issuing break-glass grant", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(breakGlassResponse{
+		ID:        issued.Grant.ID,
+		Token:     issued.Token,
+		Roles:     issued.Grant.Roles,
+		ExpiresAt: issued.Grant.ExpiresAt,
+	})
+}
+
+// ListBreakGlass handles listing recent grants
+func (h *BreakGlassHandler) ListBreakGlass(w http.ResponseWriter, r *http.Request) {
+	grants, err := h.manager.List(r.Context(), time.Now().Add(-breakGlassListWindow))
+	if err != nil {
+		logger(r, h.log).Error("Error listing break-glass grants", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(grants)
+}
+
+// RevokeBreakGlass handles ending a grant early
+func (h *BreakGlassHandler) RevokeBreakGlass(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid grant ID", nil)
+		return
+	}
+
+	grant, err := h.manager.Revoke(r.Context(), id, callerSubject(r))
+	if errors.Is(err, store.ErrGrantNotFound) {
+		apierror.NotFound(w, r, "Active grant not found")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error revoking break-glass grant", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(grant)
+}
+
+// BreakGlassAudit handles listing every request made with a grant
+func (h *BreakGlassHandler) BreakGlassAudit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid grant ID", nil)
+		return
+	}
+
+	uses, err := h.manager.Uses(r.Context(), id)
+	if err != nil {
+		logger(r, h.log).Error("Error listing break-glass audit", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(uses)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/cacheinsight"
+
+	"go.uber.org/zap"
+)
+
+// CacheInsightHandler reports cache effectiveness by subject popularity
+type CacheInsightHandler struct {
+	insights *cacheinsight.Insights
+	log      *zap.Logger
+}
+
+// NewCacheInsightHandler creates a new cache insight handler
+func NewCacheInsightHandler(insights *cacheinsight.Insights, log *zap.Logger) *CacheInsightHandler {
+	return &CacheInsightHandler{insights: insights, log: log}
+}
+
+// cacheInsightResponse reports the current window so far and the last complete one
+type cacheInsightResponse struct {
+	WindowSeconds float64 `json:"window_seconds"`
+	// Current covers the window in progress, so its figures are still growing
+	Current []cacheinsight.Report `json:"current"`
+	// Previous is absent until a window completes
+	Previous []cacheinsight.Report `json:"previous,omitempty"`
+}
+
+// CacheInsights handles reporting cache effectiveness by subject popularity
+// for each cache tier of this instance
+func (h *CacheInsightHandler) CacheInsights(w http.ResponseWriter, r *http.Request) {
+	if h.insights == nil {
+		apierror.NotFound(w, r, "Cache insights are disabled")
+		return
+	}
+	current, previous := h.insights.Reports()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cacheInsightResponse{
+		WindowSeconds: h.insights.Window().Seconds(),
+		Current:       current,
+		Previous:      previous,
+	})
+}
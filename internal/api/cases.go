@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// caseUpdateRequest represents the request body for updating a review case.
+// Fields left out are left as they are.
+type caseUpdateRequest struct {
+	Status   *string `json:"status,omitempty"`
+	Assignee *string `json:"assignee,omitempty"`
+	Notes    *string `json:"notes,omitempty"`
+}
+
+// ListCases handles listing review cases, oldest first; ?status= and
+// ?assignee= narrow the listing and ?after= pages through it
+func (h *Handler) ListCases(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.CaseFilter{
+		Status:   q.Get("status"),
+		Assignee: q.Get("assignee"),
+	}
+	switch filter.Status {
+	case "", store.CaseOpen, store.CaseCleared, store.CaseConfirmed:
+	default:
+		apierror.Validation(w, r, service.ErrCaseStatus.Error(), nil)
+		return
+	}
+	if v := q.Get("after"); v != "" {
+		after, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || after < 0 {
+			apierror.Validation(w, r, "after must be a case ID", nil)
+			return
+		}
+		filter.After = after
+	}
+	limit, err := limitParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	filter.Limit = limit
+
+	cases, err := h.service.ListCases(r.Context(), filter)
+	if errors.Is(err, service.ErrCasesDisabled) {
+		apierror.NotFound(w, r, "Review cases are not enabled")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error listing review cases", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	if cases == nil {
+		cases = []*store.ReviewCase{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cases)
+}
+
+// GetCase handles looking up a review case
+func (h *Handler) GetCase(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid case ID", nil)
+		return
+	}
+
+	c, err := h.service.GetCase(r.Context(), id)
+	switch {
+	case errors.Is(err, service.ErrCasesDisabled):
+		apierror.NotFound(w, r, "Review cases are not enabled")
+		return
+	case errors.Is(err, store.ErrCaseNotFound):
+		apierror.NotFound(w, r, "Review case not found")
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error loading review case", zap.Int64("case_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// UpdateCase handles changing the status, assignee or notes of a review case
+func (h *Handler) UpdateCase(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid case ID", nil)
+		return
+	}
+	var req caseUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+
+	c, err := h.service.UpdateCase(actorContext(r), id, store.CaseUpdate(req))
+	switch {
+	case errors.Is(err, service.ErrCasesDisabled):
+		apierror.NotFound(w, r, "Review cases are not enabled")
+		return
+	case errors.Is(err, service.ErrCaseStatus), errors.Is(err, service.ErrCaseAssignee):
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	case errors.Is(err, store.ErrCaseNotFound):
+		apierror.NotFound(w, r, "Review case not found")
+		return
+	case errors.Is(err, store.ErrCaseOpen):
+		apierror.Conflict(w, r, "Subject already has an open case for the record")
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error updating review case", zap.Int64("case_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	recordActivity(r, h.activity, h.log, store.ActivityReviewCase, "update", strconv.FormatInt(id, 10), req)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
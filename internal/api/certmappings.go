@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// CertMappingHandler handles client certificate mapping administration requests
+type CertMappingHandler struct {
+	store         store.CertMappingStore
+	activity      store.ActivityStore
+	authenticator *auth.CertAuthenticator
+	log           *zap.Logger
+}
+
+// NewCertMappingHandler creates a new client certificate mapping handler
+func NewCertMappingHandler(store store.CertMappingStore, activity store.ActivityStore, authenticator *auth.CertAuthenticator, log *zap.Logger) *CertMappingHandler {
+	return &CertMappingHandler{
+		store:         store,
+		activity:      activity,
+		authenticator: authenticator,
+		log:           log,
+	}
+}
+
+// certMappingRequest represents the request body for creating a mapping
+type certMappingRequest struct {
+	Field   string   `json:"field"`
+	Value   string   `json:"value"`
+	Subject string   `json:"subject"`
+	Tenant  string   `json:"tenant,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+}
+
+// certMappingResponse represents a mapping in API responses
+type certMappingResponse struct {
+	ID        int64     `json:"id"`
+	Field     string    `json:"field"`
+	Value     string    `json:"value"`
+	Subject   string    `json:"subject"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newCertMappingResponse(m *store.CertMapping) certMappingResponse {
+	return certMappingResponse{
+		ID:        m.ID,
+		Field:     m.Field,
+		Value:     m.Value,
+		Subject:   m.Subject,
+		Tenant:    m.Tenant,
+		Roles:     m.Roles,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// ListCertMappings handles listing client certificate mappings
+func (h *CertMappingHandler) ListCertMappings(w http.ResponseWriter, r *http.Request) {
+	mappings, err := h.store.List(r.Context())
+	if err != nil {
+		logger(r, h.log).Error("Error listing certificate mappings", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	resp := make([]certMappingResponse, 0, len(mappings))
+	for _, m := range mappings {
+		resp = append(resp, newCertMappingResponse(m))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CreateCertMapping handles adding a client certificate mapping
+func (h *CertMappingHandler) CreateCertMapping(w http.ResponseWriter, r *http.Request) {
+	var req certMappingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+	if !auth.ValidCertField(req.Field) {
+		apierror.Validation(w, r, "field must be one of subject_cn, subject_dn, san_dns, san_uri, san_email", nil)
+		return
+	}
+	if req.Value == "" || req.Subject == "" {
+		apierror.Validation(w, r, "value and subject are required", nil)
+		return
+	}
+
+	mapping := &store.CertMapping{
+		Field:   req.Field,
+		Value:   req.Value,
+		Subject: req.Subject,
+		Tenant:  req.Tenant,
+		Roles:   req.Roles,
+	}
+	if err := h.store.Create(r.Context(), mapping); err != nil {
+		logger(r, h.log).Error("Error creating certificate mapping", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	h.refresh(r)
+	recordActivity(r, h.activity, h.log, store.ActivityCertMapping, "create", strconv.FormatInt(mapping.ID, 10), newCertMappingResponse(mapping))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newCertMappingResponse(mapping))
+}
+
+// DeleteCertMapping handles removing a client certificate mapping
+func (h *CertMappingHandler) DeleteCertMapping(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid mapping ID", nil)
+		return
+	}
+
+	err = h.store.Delete(r.Context(), id)
+	if errors.Is(err, store.ErrCertMappingNotFound) {
+		apierror.NotFound(w, r, "Mapping not found")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error deleting certificate mapping", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	h.refresh(r)
+	recordActivity(r, h.activity, h.log, store.ActivityCertMapping, "delete", strconv.FormatInt(id, 10), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// refresh applies mapping changes on this replica immediately; other replicas
+// pick them up on their next periodic refresh
+func (h *CertMappingHandler) refresh(r *http.Request) {
+	if err := h.authenticator.Refresh(r.Context()); err != nil {
+		logger(r, h.log).Error("Error refreshing certificate mappings", zap.Error(err))
+	}
+}
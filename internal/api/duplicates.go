@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/approval"
+	"blacklist-check/internal/dedup"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// DuplicateHandler handles the review of likely duplicate records
+type DuplicateHandler struct {
+	dedup *dedup.Deduplicator
+	// approvals holds merges for a second user to approve; nil when they
+	// apply straight away
+	approvals *approval.Workflow
+	log       *zap.Logger
+}
+
+// NewDuplicateHandler creates a new duplicate review handler
+func NewDuplicateHandler(dedup *dedup.Deduplicator, approvals *approval.Workflow, log *zap.Logger) *DuplicateHandler {
+	return &DuplicateHandler{
+		dedup:     dedup,
+		approvals: approvals,
+		log:       log,
+	}
+}
+
+// duplicateResponse is a duplicate pair with its records
+type duplicateResponse struct {
+	*store.DuplicatePair
+	Record    *types.Record `json:"record,omitempty"`
+	Duplicate *types.Record `json:"duplicate,omitempty"`
+}
+
+// mergeRequest represents the request body for merging a duplicate pair
+type mergeRequest struct {
+	// Keep is the ID of the record the pair is merged into
+	Keep int64 `json:"keep"`
+}
+
+// newDuplicateResponse converts a duplicate pair to its API representation
+func newDuplicateResponse(pair *store.DuplicatePair) duplicateResponse {
+	resp := duplicateResponse{DuplicatePair: pair}
+	if pair.Record != nil {
+		r := newRecordResponse(pair.Record)
+		resp.Record = &r
+	}
+	if pair.Duplicate != nil {
+		r := newRecordResponse(pair.Duplicate)
+		resp.Duplicate = &r
+	}
+	return resp
+}
+
+// ListDuplicates handles listing duplicate pairs; ?status= picks pending
+// (the default), merged or dismissed ones
+func (h *DuplicateHandler) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	if h.dedup == nil {
+		apierror.NotFound(w, r, "Duplicate detection is not enabled")
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = store.DuplicatePending
+	case store.DuplicatePending, store.DuplicateMerged, store.DuplicateDismissed:
+	default:
+		apierror.Validation(w, r, "status must be pending, merged or dismissed", nil)
+		return
+	}
+
+	pairs, err := h.dedup.List(r.Context(), status)
+	if err != nil {
+		logger(r, h.log).Error("Error listing duplicates", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	resp := make([]duplicateResponse, 0, len(pairs))
+	for _, pair := range pairs {
+		resp = append(resp, newDuplicateResponse(pair))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetDuplicate handles looking up a duplicate pair
+func (h *DuplicateHandler) GetDuplicate(w http.ResponseWriter, r *http.Request) {
+	if h.dedup == nil {
+		apierror.NotFound(w, r, "Duplicate detection is not enabled")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid duplicate ID", nil)
+		return
+	}
+
+	pair, err := h.dedup.Get(r.Context(), id)
+	if errors.Is(err, store.ErrDuplicateNotFound) {
+		apierror.NotFound(w, r, "Duplicate pair not found")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error loading duplicate pair", zap.Int64("duplicate_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDuplicateResponse(pair))
+}
+
+// MergeDuplicate handles merging a duplicate pair into one of its records
+func (h *DuplicateHandler) MergeDuplicate(w http.ResponseWriter, r *http.Request) {
+	if h.dedup == nil {
+		apierror.NotFound(w, r, "Duplicate detection is not enabled")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid duplicate ID", nil)
+		return
+	}
+	var req mergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+
+	if h.approvals != nil {
+		h.proposeMerge(w, r, id, req.Keep)
+		return
+	}
+	pair, err := h.dedup.Merge(actorContext(r), id, req.Keep)
+	switch {
+	case errors.Is(err, store.ErrDuplicateNotFound):
+		apierror.NotFound(w, r, "Duplicate pair not found")
+		return
+	case errors.Is(err, store.ErrDuplicateDecided):
+		apierror.Conflict(w, r, "Duplicate pair already decided")
+		return
+	case errors.Is(err, store.ErrDuplicateKeep):
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	case errors.Is(err, store.ErrRecordNotFound):
+		apierror.Conflict(w, r, "A record of the pair is no longer live")
+		return
+	case errors.Is(err, store.ErrRecordPinned):
+		apierror.Conflict(w, r, "Record is pinned by an open case")
+		return
+	case errors.Is(err, store.ErrDuplicateNIKs):
+		apierror.Conflict(w, r, differentNIKs)
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error merging duplicate pair", zap.Int64("duplicate_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDuplicateResponse(pair))
+}
+
+// differentNIKs answers the merge of a pair whose records have different NIKs
+const differentNIKs = "Records of the pair have different NIKs, so merging would unlist one; the pair can only be dismissed"
+
+// proposeMerge answers a merge with the proposal holding it for approval,
+// which merges the pair when approved. The proposal names the NIK the merge
+// unlists, or the kept one when the record merged away has none.
+func (h *DuplicateHandler) proposeMerge(w http.ResponseWriter, r *http.Request, id, keep int64) {
+	pair, err := h.dedup.Get(r.Context(), id)
+	switch {
+	case errors.Is(err, store.ErrDuplicateNotFound):
+		apierror.NotFound(w, r, "Duplicate pair not found")
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error loading duplicate pair", zap.Int64("duplicate_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	case pair.Status != store.DuplicatePending:
+		apierror.Conflict(w, r, "Duplicate pair already decided")
+		return
+	case keep != pair.RecordID && keep != pair.DuplicateID:
+		apierror.Validation(w, r, store.ErrDuplicateKeep.Error(), nil)
+		return
+	}
+	kept, removed := pair.Record, pair.Duplicate
+	if keep == pair.DuplicateID {
+		kept, removed = removed, kept
+	}
+	if kept == nil || removed == nil {
+		apierror.Conflict(w, r, "A record of the pair is no longer live")
+		return
+	}
+	nik := removed.NIK
+	if nik == "" {
+		nik = kept.NIK
+	}
+
+	proposal := &store.Proposal{Action: store.ChangeMerge, List: pair.List, NIK: nik, DuplicateID: id, KeepID: keep}
+	if err := h.approvals.Propose(actorContext(r), proposal, nil); err != nil {
+		logger(r, h.log).Error("Error proposing merge", zap.Int64("duplicate_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	resp, err := newProposalResponse(proposal)
+	if err != nil {
+		logger(r, h.log).Error("Error decoding proposal", zap.Int64("proposal_id", proposal.ID), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DismissDuplicate handles marking a duplicate pair as different records
+func (h *DuplicateHandler) DismissDuplicate(w http.ResponseWriter, r *http.Request) {
+	if h.dedup == nil {
+		apierror.NotFound(w, r, "Duplicate detection is not enabled")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid duplicate ID", nil)
+		return
+	}
+
+	pair, err := h.dedup.Dismiss(actorContext(r), id)
+	switch {
+	case errors.Is(err, store.ErrDuplicateNotFound):
+		apierror.NotFound(w, r, "Duplicate pair not found")
+		return
+	case errors.Is(err, store.ErrDuplicateDecided):
+		apierror.Conflict(w, r, "Duplicate pair already decided")
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error dismissing duplicate pair", zap.Int64("duplicate_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDuplicateResponse(pair))
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/service"
+
+	"go.uber.org/zap"
+)
+
+var countryRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// EntityHandler handles company screening requests
+type EntityHandler struct {
+	service *service.EntityService
+	log     *zap.Logger
+}
+
+// NewEntityHandler creates a new entity handler
+func NewEntityHandler(service *service.EntityService, log *zap.Logger) *EntityHandler {
+	return &EntityHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// CheckEntity handles company blacklist check requests
+func (h *EntityHandler) CheckEntity(w http.ResponseWriter, r *http.Request) {
+	var req types.EntityCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+
+	// Validate on what remains once legal forms are stripped, so "PT" alone is rejected
+	if len(normalize.EntityName(req.LegalName)) < 2 {
+		apierror.Validation(w, r, "Legal name must contain at least 2 characters besides the legal form", nil)
+		return
+	}
+
+	serviceReq := service.EntityCheckRequest{LegalName: req.LegalName}
+	if req.Country != nil {
+		serviceReq.Country = strings.ToUpper(*req.Country)
+		if !countryRegex.MatchString(serviceReq.Country) {
+			apierror.Validation(w, r, "Country must be an ISO 3166-1 alpha-2 code", nil)
+			return
+		}
+	}
+	if req.RegistrationNumber != nil {
+		serviceReq.RegistrationNumber = *req.RegistrationNumber
+		if serviceReq.Country == "" {
+			apierror.Validation(w, r, "Country is required with a registration number", nil)
+			return
+		}
+	}
+
+	result, err := h.service.CheckEntity(r.Context(), serviceReq)
+	if err != nil {
+		logger(r, h.log).Error("Error checking entity blacklist", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	metrics.BlacklistChecksTotal.WithLabelValues(result.MatchType, fmt.Sprintf("%v", result.Blacklisted)).Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types.CheckResponse{
+		Blacklisted: result.Blacklisted,
+		Details:     result.Describe(reason.Negotiate(r.Header.Get("Accept-Language"))),
+		MatchType:   result.MatchType,
+		Outcome:     result.Outcome(),
+	})
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// notModified sets the ETag of a listing of table and reports whether it
+// answered 304 Not Modified because the client's If-None-Match holds it. The
+// ETag hashes the table's change counter with the caller's tenant and the
+// request's path and query, so it changes with the data and the listing
+// asked for. The counter is read before the listing, so a change made
+// meanwhile goes out under the older ETag and is fetched again next time. A
+// counter that can't be read leaves the response without an ETag.
+func (h *Handler) notModified(w http.ResponseWriter, r *http.Request, table string) bool {
+	version, err := h.versions.Version(r.Context(), table)
+	if err != nil {
+		logger(r, h.log).Warn("Error reading table version", zap.String("table", table), zap.Error(err))
+		return false
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strconv.FormatInt(version, 10), store.Tenant(r.Context()), r.URL.Path, r.URL.RawQuery,
+	}, "\x00")))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header holds etag, comparing
+// weakly as RFC 9110 has it
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
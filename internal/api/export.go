@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// exportPageSize is how many rows an export reads, writes and flushes at a time
+const exportPageSize = 1000
+
+// Export formats, picked with ?format=
+const (
+	exportCSV   = "csv"
+	exportJSONL = "jsonl"
+)
+
+// exporter streams rows as CSV or JSON lines. Nothing is buffered past a
+// page, so the response goes out with chunked transfer encoding.
+type exporter struct {
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+	rc     *http.ResponseController
+}
+
+// newExporter picks the format from ?format= (default csv) and sends the
+// headers of a download named name
+func newExporter(w http.ResponseWriter, r *http.Request, name string) (*exporter, error) {
+	e := &exporter{format: r.URL.Query().Get("format"), rc: http.NewResponseController(w)}
+	if e.format == "" {
+		e.format = exportCSV
+	}
+	switch e.format {
+	case exportCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		e.csv = csv.NewWriter(w)
+	case exportJSONL:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		e.json = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("format must be %s or %s", exportCSV, exportJSONL)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().UTC().Format("20060102T150405Z"), e.format))
+	return e, nil
+}
+
+// header writes the CSV header row; JSON lines carry their own field names
+func (e *exporter) header(columns []string) error {
+	if e.csv == nil {
+		return nil
+	}
+	return e.csv.Write(columns)
+}
+
+// write writes a row, as v in JSONL and as row in CSV
+func (e *exporter) write(v interface{}, row []string) error {
+	if e.csv != nil {
+		return e.csv.Write(row)
+	}
+	return e.json.Encode(v)
+}
+
+// flush sends what has been written so far to the client
+func (e *exporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := e.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// exportContext detaches an export from the server's request timeout, which
+// a full dataset can outlast; a client that goes away fails the next write
+func exportContext(r *http.Request) context.Context {
+	return context.WithoutCancel(r.Context())
+}
+
+var recordColumns = []string{"id", "list", "nik", "name", "birth_place", "birth_date", "reason", "reason_code", "reason_params", "source", "created_at", "updated_at", "deleted_at", "deleted_by", "expires_at"}
+
+// ExportRecords handles streaming every record matching the ListRecords
+// filters as CSV or JSON lines (?format=), or 304 Not Modified when none has
+// changed since the export the client's If-None-Match names
+func (h *Handler) ExportRecords(w http.ResponseWriter, r *http.Request) {
+	filter, sort, err := recordQuery(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	e, err := newExporter(w, r, "records")
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	// A client holding the current export downloads nothing and isn't
+	// recorded as exporting again
+	if h.notModified(w, r, store.VersionRecords) {
+		return
+	}
+	recordActivity(r, h.activity, h.log, store.ActivityExport, "records", filter.List, map[string]string{"query": r.URL.RawQuery, "format": e.format})
+
+	ctx := exportContext(r)
+	rows := 0
+	err = e.header(recordColumns)
+	for cursor := ""; err == nil; {
+		var records []*store.BlacklistRecord
+		records, cursor, err = h.service.ListRecords(ctx, filter, sort, cursor, exportPageSize)
+		if err != nil {
+			break
+		}
+		for _, record := range records {
+			if err = e.write(newRecordResponse(record), recordRow(record)); err != nil {
+				break
+			}
+			rows++
+		}
+		if err == nil {
+			err = e.flush()
+		}
+		if cursor == "" {
+			break
+		}
+	}
+	if err != nil {
+		// Headers are already sent, so the truncated download is all we can signal
+		logger(r, h.log).Error("Error exporting records", zap.Int("rows", rows), zap.Error(err))
+	}
+}
+
+func recordRow(record *store.BlacklistRecord) []string {
+	row := []string{
+		strconv.FormatInt(record.ID, 10), record.List, record.NIK, record.Name, record.BirthPlace, "",
+		record.Reason, record.ReasonCode, "", record.Source,
+		record.CreatedAt.Format(time.RFC3339), record.UpdatedAt.Format(time.RFC3339), "", "", "",
+	}
+	if record.BirthDate != nil {
+		row[5] = record.BirthDate.Format("2006-01-02")
+	}
+	if len(record.ReasonParams) > 0 {
+		params, _ := json.Marshal(record.ReasonParams)
+		row[8] = string(params)
+	}
+	if record.DeletedAt != nil {
+		row[12] = record.DeletedAt.Format(time.RFC3339)
+	}
+	if record.DeletedBy != nil {
+		row[13] = *record.DeletedBy
+	}
+	if record.ExpiresAt != nil {
+		row[14] = record.ExpiresAt.Format(time.RFC3339)
+	}
+	return row
+}
+
+var activityColumns = []string{"event_id", "kind", "action", "actor", "target", "details", "occurred_at"}
+
+// ExportActivity handles streaming the admin activity matching the
+// ListActivity filters, newest first, as CSV or JSON lines (?format=)
+func (h *ActivityHandler) ExportActivity(w http.ResponseWriter, r *http.Request) {
+	filter, err := activityQuery(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	e, err := newExporter(w, r, "activity")
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	// Recorded before the walk so the export doesn't include itself
+	recordActivity(r, h.store, h.log, store.ActivityExport, "activity", "", map[string]string{"query": r.URL.RawQuery, "format": e.format})
+
+	ctx := exportContext(r)
+	rows := 0
+	err = e.header(activityColumns)
+	for cursor := ""; err == nil; {
+		var events []*store.Activity
+		events, cursor, err = h.store.List(ctx, filter, cursor, exportPageSize)
+		if err != nil {
+			break
+		}
+		for _, event := range events {
+			row := []string{event.EventID, event.Kind, event.Action, event.Actor, event.Target, string(event.Details), event.OccurredAt.Format(time.RFC3339)}
+			if err = e.write(event, row); err != nil {
+				break
+			}
+			rows++
+		}
+		if err == nil {
+			err = e.flush()
+		}
+		if cursor == "" {
+			break
+		}
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error exporting admin activity", zap.Int("rows", rows), zap.Error(err))
+	}
+}
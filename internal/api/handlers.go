@@ -1,121 +1,484 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"regexp"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/approval"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/cache"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nationalid"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/reqlog"
 	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/validate"
 
-	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
-var (
-	nikRegex = regexp.MustCompile(`^\d{16}$`)
-
-	blacklistChecksTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "blacklist_checks_total",
-			Help: "Total number of blacklist checks",
-		},
-		[]string{"match_type", "result"},
-	)
-)
-
-func init() {
-	prometheus.MustRegister(blacklistChecksTotal)
-}
+// diagnosticsRole is required to request no-match diagnostics on a check
+const diagnosticsRole = "diagnostics"
 
 // Handler handles HTTP requests
 type Handler struct {
-	service *service.BlacklistService
-	log     *zap.Logger
+	service  service.Service
+	store    store.BlacklistStore
+	activity store.ActivityStore
+	versions store.VersionStore
+	cache    cache.Cache
+	log      *zap.Logger
+
+	// approvals holds record changes for a second user to approve; nil when
+	// they apply straight away
+	approvals *approval.Workflow
+
+	draining atomic.Bool
+	warming  atomic.Bool
 }
 
 // NewHandler creates a new handler
-func NewHandler(service *service.BlacklistService, log *zap.Logger) *Handler {
+func NewHandler(service service.Service, store store.BlacklistStore, activity store.ActivityStore, versions store.VersionStore, cache cache.Cache, approvals *approval.Workflow, log *zap.Logger) *Handler {
 	return &Handler{
-		service: service,
-		log:     log,
+		service:   service,
+		store:     store,
+		activity:  activity,
+		versions:  versions,
+		cache:     cache,
+		approvals: approvals,
+		log:       log,
 	}
 }
 
-// checkRequest represents the request body for blacklist check
-type checkRequest struct {
-	Name       string     `json:"name"`
-	NIK        *string    `json:"nik,omitempty"`
-	BirthPlace *string    `json:"birth_place,omitempty"`
-	BirthDate  *time.Time `json:"birth_date,omitempty"`
+// logger returns the logger scoped to r, which carries its request ID and caller
+func logger(r *http.Request, log *zap.Logger) *zap.Logger {
+	return reqlog.From(r.Context(), log)
 }
 
-// checkResponse represents the response body for blacklist check
-type checkResponse struct {
-	Blacklisted bool   `json:"blacklisted"`
-	Details     string `json:"details,omitempty"`
-	MatchType   string `json:"match_type"`
+// checkRenderer renders a check result in locale for an API version
+type checkRenderer func(result *service.CheckResult, locale string) interface{}
+
+// renderV1 renders a check result in the v1 shape
+func renderV1(result *service.CheckResult, locale string) interface{} {
+	return checkResponse(result, locale)
 }
 
 // CheckBlacklist handles blacklist check requests
 func (h *Handler) CheckBlacklist(w http.ResponseWriter, r *http.Request) {
-	var req checkRequest
+	h.checkBody(w, r, renderV1)
+}
+
+// CheckBlacklistQuery handles blacklist checks sent as query parameters, for
+// callers that can only issue GETs. The parameters are the fields of the
+// request body, with lists comma-separated or repeated.
+func (h *Handler) CheckBlacklistQuery(w http.ResponseWriter, r *http.Request) {
+	h.checkQuery(w, r, renderV1)
+}
+
+// checkBody runs the check request in the body, answering in render's shape
+func (h *Handler) checkBody(w http.ResponseWriter, r *http.Request, render checkRenderer) {
+	var req types.CheckRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.log.Error("Error decoding request body", zap.Error(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", err.Error())
 		return
 	}
+	h.check(w, r, req, render)
+}
 
-	// Validate name
-	if len(req.Name) < 3 {
-		h.log.Error("Name too short", zap.String("name", req.Name))
-		http.Error(w, "Name must be at least 3 characters long", http.StatusBadRequest)
+// checkQuery runs the check request in the query, answering in render's shape
+func (h *Handler) checkQuery(w http.ResponseWriter, r *http.Request, render checkRenderer) {
+	req, err := checkRequestFromQuery(r.URL.Query())
+	if err != nil {
+		apierror.Validation(w, r, "Invalid query parameters", err.Error())
 		return
 	}
+	h.check(w, r, req, render)
+}
+
+// checkRequestFromQuery reads a check request from query parameters
+func checkRequestFromQuery(q url.Values) (types.CheckRequest, error) {
+	req := types.CheckRequest{Name: q.Get("name")}
+	optional := func(name string) *string {
+		if !q.Has(name) {
+			return nil
+		}
+		v := q.Get(name)
+		return &v
+	}
+	req.NIK = optional("nik")
+	req.BirthPlace = optional("birth_place")
+	req.IDCountry = optional("id_country")
+	req.Profile = optional("profile")
 
-	// Validate NIK if provided
-	if req.NIK != nil && !nikRegex.MatchString(*req.NIK) {
-		h.log.Error("Invalid NIK format", zap.String("nik", *req.NIK))
-		http.Error(w, "NIK must be a 16-digit number", http.StatusBadRequest)
+	var err error
+	if req.BirthDate, err = types.ParseBirthDate(q.Get("birth_date")); err != nil {
+		return req, err
+	}
+	for _, v := range q["lists"] {
+		for _, list := range strings.Split(v, ",") {
+			if list = strings.TrimSpace(list); list != "" {
+				req.Lists = append(req.Lists, list)
+			}
+		}
+	}
+	if v := q.Get("diagnostics"); v != "" {
+		if req.Diagnostics, err = strconv.ParseBool(v); err != nil {
+			return req, errors.New("diagnostics must be true or false")
+		}
+	}
+	if v := q.Get("rescreen_consent"); v != "" {
+		consent, err := strconv.ParseBool(v)
+		if err != nil {
+			return req, errors.New("rescreen_consent must be true or false")
+		}
+		req.RescreenConsent = &consent
+	}
+	if v := q.Get("candidate_budget"); v != "" {
+		budget, err := strconv.Atoi(v)
+		if err != nil {
+			return req, errors.New("candidate_budget must be a number")
+		}
+		req.CandidateBudget = &budget
+	}
+	return req, nil
+}
+
+// check validates and runs a check request, answering with its result
+// rendered by render
+func (h *Handler) check(w http.ResponseWriter, r *http.Request, req types.CheckRequest, render checkRenderer) {
+	// Validate and create service request
+	serviceReq, err := newServiceCheckRequest(req)
+	if err != nil {
+		logger(r, h.log).Error("Invalid blacklist check request", zap.Error(err))
+		apierror.Invalid(w, r, err)
 		return
 	}
+	serviceReq.Explain = r.URL.Query().Get("explain") == "true"
+
+	// Diagnostics expose records that didn't match, so they're reserved for privileged callers
+	if serviceReq.Diagnostics {
+		identity := auth.FromContext(r.Context())
+		if identity == nil || !identity.HasRole(diagnosticsRole) {
+			apierror.Forbidden(w, r)
+			return
+		}
+		logger(r, h.log).Info("Diagnostics requested for blacklist check",
+			zap.String("subject", identity.Subject))
+	}
+
+	// Check blacklist
+	result, err := h.service.CheckBlacklist(r.Context(), serviceReq)
+	var idErr *nationalid.Error
+	if errors.As(err, &idErr) {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	var timeoutErr *service.DependencyTimeoutError
+	if errors.As(err, &timeoutErr) {
+		logger(r, h.log).Warn("Blacklist check timed out", zap.String("dependency", timeoutErr.Dependency), zap.Error(err))
+		apierror.DependencyTimeout(w, r, timeoutErr.Dependency)
+		return
+	}
+	var unavailableErr *service.DependencyUnavailableError
+	if errors.As(err, &unavailableErr) {
+		logger(r, h.log).Warn("Blacklist check failed fast", zap.String("dependency", unavailableErr.Dependency), zap.Error(err))
+		apierror.DependencyUnavailable(w, r, unavailableErr.Dependency)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error checking blacklist", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	// Record metrics, keeping sandbox traffic out of the production ones
+	if result.Sandbox {
+		metrics.SandboxChecksTotal.WithLabelValues(result.MatchType, result.Decision).Inc()
+	} else {
+		metrics.BlacklistChecksTotal.WithLabelValues(result.MatchType, fmt.Sprintf("%v", result.Blacklisted)).Inc()
+		metrics.CheckDecisionsTotal.WithLabelValues(result.Decision).Inc()
+		if result.Degraded {
+			metrics.DegradedChecksTotal.Inc()
+		}
+		metrics.CheckOutcomesTotal.WithLabelValues(result.Outcome(), result.UnknownReason).Inc()
+	}
+
+	// Return response, rendering the reason in the caller's language
+	locale := reason.Negotiate(r.Header.Get("Accept-Language"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", locale)
+	json.NewEncoder(w).Encode(render(result, locale))
+}
+
+// explanationResponse renders a match explanation for the API, nil when
+// there is none
+func explanationResponse(explanation *service.Explanation) *types.Explanation {
+	if explanation == nil {
+		return nil
+	}
+	response := &types.Explanation{Rule: explanation.Rule, RecordID: explanation.RecordID}
+	for _, check := range explanation.Checks {
+		response.Checks = append(response.Checks, types.RuleCheck(check))
+	}
+	return response
+}
+
+// checkResponse renders a check result for the API in locale
+func checkResponse(result *service.CheckResult, locale string) types.CheckResponse {
+	response := types.CheckResponse{
+		Blacklisted:   result.Blacklisted,
+		Details:       result.Describe(locale),
+		MatchType:     result.MatchType,
+		ReasonCode:    result.ReasonCode,
+		Confidence:    result.Confidence,
+		MatchedAlias:  result.MatchedAlias,
+		Score:         result.Score,
+		Decision:      result.Decision,
+		Outcome:       result.Outcome(),
+		UnknownReason: result.UnknownReason,
+		Sandbox:       result.Sandbox,
+		Degraded:      result.Degraded,
+		ReviewCaseID:  result.CaseID,
+	}
+	for _, r := range result.Lists {
+		listResult := types.ListResult{
+			List:          r.List,
+			Matched:       r.Matched,
+			Details:       r.Describe(locale),
+			MatchType:     r.MatchType,
+			ReasonCode:    r.ReasonCode,
+			Confidence:    r.Confidence,
+			MatchedAlias:  r.MatchedAlias,
+			SuppressedBy:  r.SuppressedBy,
+			Outcome:       r.Outcome(),
+			UnknownReason: r.UnknownReason,
+			Score:         r.Score,
+			Decision:      r.Decision,
+			Truncated:     r.Truncated,
+		}
+		for _, f := range r.Factors {
+			listResult.Factors = append(listResult.Factors, types.ScoreFactor(f))
+		}
+		for _, miss := range r.NearMisses {
+			listResult.NearMisses = append(listResult.NearMisses, types.NearMiss{
+				NIK:        miss.Record.NIK,
+				Name:       miss.Record.Name,
+				BirthPlace: miss.Record.BirthPlace,
+				BirthDate:  miss.Record.BirthDate,
+				Similarity: miss.Similarity,
+				Excluded:   miss.Excluded,
+			})
+		}
+		listResult.Explanation = explanationResponse(r.Explanation)
+		response.Results = append(response.Results, listResult)
+	}
+	response.Explanation = explanationResponse(result.Explanation)
+	for _, warning := range result.Warnings {
+		response.Warnings = append(response.Warnings, types.Warning(warning))
+	}
+	response.NationalID = nationalIDResponse(result.ID)
+	return response
+}
+
+// nationalIDResponse renders what a national ID revealed, nil when the check
+// had none
+func nationalIDResponse(id *service.IDCheck) *types.NationalID {
+	if id == nil {
+		return nil
+	}
+	response := &types.NationalID{
+		Country:           id.Country,
+		RegionCode:        id.RegionCode,
+		Region:            id.Region,
+		Sex:               id.Sex,
+		Attributes:        id.Attributes,
+		BirthDateMismatch: id.BirthDateMismatch,
+	}
+	if !id.BirthDate.IsZero() {
+		response.BirthDate = &id.BirthDate
+	}
+	return response
+}
+
+// Longest names and birth places a check may give, as the records store them
+const (
+	maxNameLength       = 255
+	maxBirthPlaceLength = 100
+)
+
+// newServiceCheckRequest validates a check request and converts it for the
+// service. Every failing field is reported, as validate.Errors.
+func newServiceCheckRequest(req types.CheckRequest) (service.CheckRequest, error) {
+	var errs validate.Errors
+	errs.Length("name", req.Name, 3, maxNameLength)
+	if req.NIK != nil {
+		var country string
+		if req.IDCountry != nil {
+			country = *req.IDCountry
+		}
+		_, err := nationalid.Parse(country, *req.NIK)
+		errs.Check("nik", err)
+	}
+	if req.BirthPlace != nil {
+		errs.Length("birth_place", *req.BirthPlace, 0, maxBirthPlaceLength)
+	}
+	req.BirthDate = validate.Date(req.BirthDate)
+	errs.Check("lists", lists.Validate(req.Lists))
+	if req.Profile != nil {
+		_, err := normalize.LookupProfile(*req.Profile)
+		errs.Check("profile", err)
+	}
+	if req.CandidateBudget != nil && *req.CandidateBudget <= 0 {
+		errs.Add("candidate_budget", "candidate_budget must be positive")
+	}
+	if err := errs.Err(); err != nil {
+		return service.CheckRequest{}, err
+	}
 
-	// Create service request
 	serviceReq := service.CheckRequest{
-		Name: req.Name,
+		Name:            req.Name,
+		Lists:           req.Lists,
+		Diagnostics:     req.Diagnostics,
+		RescreenConsent: req.RescreenConsent,
 	}
 	if req.NIK != nil {
 		serviceReq.NIK = *req.NIK
 	}
+	if req.IDCountry != nil {
+		serviceReq.IDCountry = *req.IDCountry
+	}
 	if req.BirthPlace != nil {
 		serviceReq.BirthPlace = *req.BirthPlace
 	}
 	if req.BirthDate != nil {
 		serviceReq.BirthDate = *req.BirthDate
 	}
-
-	// Check blacklist
-	result, err := h.service.CheckBlacklist(r.Context(), serviceReq)
-	if err != nil {
-		h.log.Error("Error checking blacklist", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	if req.Profile != nil {
+		serviceReq.Profile = *req.Profile
 	}
+	if req.CandidateBudget != nil {
+		serviceReq.CandidateBudget = *req.CandidateBudget
+	}
+	return serviceReq, nil
+}
 
-	// Record metrics
-	blacklistChecksTotal.WithLabelValues(result.MatchType, fmt.Sprintf("%v", result.Blacklisted)).Inc()
+// ListProfiles lists the name matching profiles a check can select
+func (h *Handler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(normalize.Profiles))
+	for name := range normalize.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	// Return response
+	profiles := make([]types.MatchProfile, 0, len(names))
+	for _, name := range names {
+		profiles = append(profiles, types.MatchProfile{
+			Name:        name,
+			Description: normalize.Profiles[name].Description,
+		})
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(checkResponse{
-		Blacklisted: result.Blacklisted,
-		Details:     result.Details,
-		MatchType:   result.MatchType,
-	})
+	json.NewEncoder(w).Encode(profiles)
 }
 
-// HealthCheck handles health check requests
+// HealthCheck handles liveness probe requests. It never touches dependencies,
+// so a database or Redis outage doesn't get the process restarted.
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
-} 
\ No newline at end of file
+}
+
+// readinessTimeout bounds each dependency ping
+const readinessTimeout = 2 * time.Second
+
+// StartDraining makes the readiness probe fail so load balancers stop routing
+// new traffic here while in-flight requests finish
+func (h *Handler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// StartWarmingUp makes the readiness probe fail until FinishWarmingUp, so no
+// traffic is routed here before the caches are warm
+func (h *Handler) StartWarmingUp() {
+	h.warming.Store(true)
+}
+
+// FinishWarmingUp lets the readiness probe pass again once the caches are warm
+func (h *Handler) FinishWarmingUp() {
+	h.warming.Store(false)
+}
+
+// ReadinessCheck handles readiness probe requests by pinging Postgres and
+// Redis, reporting why a dependency is unavailable
+func (h *Handler) ReadinessCheck(w http.ResponseWriter, r *http.Request) {
+	h.readiness(w, r, true)
+}
+
+// PublicReadinessCheck handles readiness probes from outside the internal
+// network zone, which learn that a dependency is unavailable but not why
+func (h *Handler) PublicReadinessCheck(w http.ResponseWriter, r *http.Request) {
+	h.readiness(w, r, false)
+}
+
+func (h *Handler) readiness(w http.ResponseWriter, r *http.Request, verbose bool) {
+	if h.draining.Load() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(types.ReadinessResponse{Status: "draining"})
+		return
+	}
+	if h.warming.Load() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(types.ReadinessResponse{Status: "warming_up"})
+		return
+	}
+
+	checks := map[string]func(ctx context.Context) error{
+		"database": h.store.Ping,
+		"redis": func(ctx context.Context) error {
+			return h.cache.Ping(ctx)
+		},
+	}
+
+	resp := types.ReadinessResponse{
+		Status:       "ok",
+		Dependencies: make(map[string]string, len(checks)),
+	}
+	for name, check := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		err := check(ctx)
+		cancel()
+
+		if err != nil {
+			logger(r, h.log).Warn("Readiness check failed", zap.String("dependency", name), zap.Error(err))
+			resp.Status = "unavailable"
+			resp.Dependencies[name] = "unavailable"
+			if verbose {
+				resp.Dependencies[name] = err.Error()
+			}
+			continue
+		}
+		resp.Dependencies[name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
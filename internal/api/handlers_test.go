@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+func TestCheckBlacklist(t *testing.T) {
+	blacklisted := &service.CheckResult{
+		Blacklisted: true,
+		MatchType:   service.MatchExactNIK,
+		Decision:    "hit",
+		CaseID:      7,
+	}
+	tests := []struct {
+		name       string
+		body       string
+		result     *service.CheckResult
+		err        error
+		wantStatus int
+		// wantReq is the request the service is asked to check, when it is
+		wantReq *service.CheckRequest
+	}{
+		{
+			name:       "blacklisted",
+			body:       `{"name":"John Doe","nik":"3171011505900001","birth_date":"1990-05-15"}`,
+			result:     blacklisted,
+			wantStatus: http.StatusOK,
+			wantReq:    &service.CheckRequest{Name: "John Doe", NIK: "3171011505900001"},
+		},
+		{
+			name:       "malformed body",
+			body:       `{"name":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "name too short",
+			body:       `{"name":"Jo"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid NIK",
+			body:       `{"name":"John Doe","nik":"123"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown list",
+			body:       `{"name":"John Doe","lists":["nope"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "database timed out",
+			body:       `{"name":"John Doe"}`,
+			err:        &service.DependencyTimeoutError{Dependency: service.DependencyPostgres, Err: context.DeadlineExceeded},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:       "service failed",
+			body:       `{"name":"John Doe"}`,
+			err:        errors.New("boom"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *service.CheckRequest
+			svc := &testutil.Service{
+				CheckBlacklistFunc: func(ctx context.Context, req service.CheckRequest) (*service.CheckResult, error) {
+					got = &req
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					if tt.result != nil {
+						return tt.result, nil
+					}
+					return &service.CheckResult{MatchType: service.MatchNone, Decision: "clear"}, nil
+				},
+			}
+			h := NewHandler(svc, nil, nil, nil, testutil.NewCache(), nil, zap.NewNop())
+
+			w := httptest.NewRecorder()
+			h.CheckBlacklist(w, httptest.NewRequest(http.MethodPost, "/api/v1/check", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantReq != nil {
+				if got == nil || got.Name != tt.wantReq.Name || got.NIK != tt.wantReq.NIK {
+					t.Errorf("service asked to check %+v, want %+v", got, tt.wantReq)
+				}
+			}
+			if tt.result == nil {
+				return
+			}
+			var resp struct {
+				Blacklisted  bool   `json:"blacklisted"`
+				MatchType    string `json:"match_type"`
+				ReviewCaseID int64  `json:"review_case_id"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Blacklisted != tt.result.Blacklisted || resp.MatchType != tt.result.MatchType || resp.ReviewCaseID != tt.result.CaseID {
+				t.Errorf("response = %+v, want result %+v", resp, tt.result)
+			}
+		})
+	}
+}
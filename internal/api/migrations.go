@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/migrate"
+
+	"go.uber.org/zap"
+)
+
+// MigrationHandler handles schema migration reporting requests
+type MigrationHandler struct {
+	migrator *migrate.Migrator
+	log      *zap.Logger
+}
+
+// NewMigrationHandler creates a new migration handler
+func NewMigrationHandler(migrator *migrate.Migrator, log *zap.Logger) *MigrationHandler {
+	return &MigrationHandler{
+		migrator: migrator,
+		log:      log,
+	}
+}
+
+// PendingMigrations reports the current schema version and migrations not yet applied
+func (h *MigrationHandler) PendingMigrations(w http.ResponseWriter, r *http.Request) {
+	status, err := h.migrator.Status(r.Context())
+	if err != nil {
+		logger(r, h.log).Error("Error reading migration status", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/migrate"
+	"blacklist-check/internal/store"
+)
+
+// operation describes one documented endpoint. Schemas are derived from the
+// same structs the handlers decode and encode, so the spec can't drift. The
+// ID names the handler and becomes the method name in generated clients, so
+// it must not change once published.
+type operation struct {
+	id       string
+	method   string
+	path     string
+	summary  string
+	tag      string
+	request  interface{}
+	response interface{}
+	status   int
+}
+
+var operations = []operation{
+	{"checkBlacklist", http.MethodPost, "/api/v1/blacklist", "Check whether a person is blacklisted (?explain=true explains matches)", "screening", types.CheckRequest{}, types.CheckResponse{}, http.StatusOK},
+	{"checkBlacklistQuery", http.MethodGet, "/api/v1/blacklist/check", "Check whether a person is blacklisted, with the checkBlacklist fields as query parameters (?name=, ?nik=, ?birth_date=, ?lists=a,b, ...)", "screening", nil, types.CheckResponse{}, http.StatusOK},
+	{"listProfiles", http.MethodGet, "/api/v1/profiles", "List the name matching profiles a check can select", "screening", nil, []types.MatchProfile{}, http.StatusOK},
+	{"checkEntity", http.MethodPost, "/api/v1/blacklist/entity", "Check whether a company is blacklisted", "screening", types.EntityCheckRequest{}, types.CheckResponse{}, http.StatusOK},
+	{"createScreening", http.MethodPost, "/api/v1/screenings", "Submit a bulk screening job (JSON or text/csv)", "screening", types.ScreeningRequest{}, types.ScreeningJob{}, http.StatusAccepted},
+	{"getScreening", http.MethodGet, "/api/v1/screenings/{id}", "Get the status of a bulk screening job", "screening", nil, types.ScreeningJob{}, http.StatusOK},
+	{"getScreeningResults", http.MethodGet, "/api/v1/screenings/{id}/results", "Download the results of a completed screening job as CSV", "screening", nil, nil, http.StatusOK},
+	{"checkBlacklistV2", http.MethodPost, "/api/v2/blacklist", "Check whether a person is blacklisted, answering in the v2 shape (?explain=true explains matches)", "screening", types.CheckRequest{}, types.CheckResponseV2{}, http.StatusOK},
+	{"checkBlacklistQueryV2", http.MethodGet, "/api/v2/blacklist/check", "Check whether a person is blacklisted, with the checkBlacklistV2 fields as query parameters, answering in the v2 shape", "screening", nil, types.CheckResponseV2{}, http.StatusOK},
+	{"listProfilesV2", http.MethodGet, "/api/v2/profiles", "List the name matching profiles a check can select", "screening", nil, []types.MatchProfile{}, http.StatusOK},
+	{"checkEntityV2", http.MethodPost, "/api/v2/blacklist/entity", "Check whether a company is blacklisted", "screening", types.EntityCheckRequest{}, types.CheckResponse{}, http.StatusOK},
+	{"dryRunCheckV2", http.MethodPost, "/api/v2/blacklist/simulate", "Evaluate a check under per-request match parameters and rate every candidate record", "screening", types.DryRunRequest{}, types.DryRunResponse{}, http.StatusOK},
+	{"createScreeningV2", http.MethodPost, "/api/v2/screenings", "Submit a bulk screening job (JSON or text/csv)", "screening", types.ScreeningRequest{}, types.ScreeningJob{}, http.StatusAccepted},
+	{"getScreeningV2", http.MethodGet, "/api/v2/screenings/{id}", "Get the status of a bulk screening job", "screening", nil, types.ScreeningJob{}, http.StatusOK},
+	{"getScreeningResultsV2", http.MethodGet, "/api/v2/screenings/{id}/results", "Download the results of a completed screening job as CSV", "screening", nil, nil, http.StatusOK},
+	{"requeueScreening", http.MethodPost, "/api/v1/admin/screenings/{id}/requeue", "Put a stalled screening job back in the queue", "screening", nil, types.ScreeningJob{}, http.StatusOK},
+	{"listRecords", http.MethodGet, "/api/v1/blacklist/records", "Page through records with filters (?name=, ?nik=, ?list=, ?source=, ?created_after=, ?created_before=, ?deleted=true, ?temporary=true), ?sort= and ?cursor=; 304 when unchanged since If-None-Match", "records", nil, types.RecordPage{}, http.StatusOK},
+	{"exportRecords", http.MethodGet, "/api/v1/blacklist/export", "Stream every record matching the listRecords filters as CSV or JSON lines (?format=csv|jsonl); 304 when unchanged since If-None-Match", "records", nil, nil, http.StatusOK},
+	{"searchRecords", http.MethodGet, "/api/v1/admin/records", "Search the records of a list by NIK prefix or name (?q=, ?list=, ?deleted=true, ?limit=)", "records", nil, []types.Record{}, http.StatusOK},
+	{"createRecord", http.MethodPost, "/api/v1/admin/records", "Create a blacklist record", "records", types.RecordRequest{}, types.Record{}, http.StatusCreated},
+	{"updateRecord", http.MethodPut, "/api/v1/admin/records/{nik}", "Update a blacklist record (?list= selects the list, default internal)", "records", types.RecordRequest{}, types.Record{}, http.StatusOK},
+	{"deleteRecord", http.MethodDelete, "/api/v1/admin/records/{nik}", "Soft-delete a blacklist record unless an open case pins it (?list= selects the list, default internal)", "records", nil, nil, http.StatusNoContent},
+	{"restoreRecord", http.MethodPost, "/api/v1/admin/records/{nik}/restore", "Restore a deleted blacklist record (?list= selects the list, default internal)", "records", nil, types.Record{}, http.StatusOK},
+	{"createTemporaryRecord", http.MethodPost, "/api/v1/admin/records/temporary", "Create a blacklist record that expires after a number of days unless confirmed", "records", types.TemporaryRecordRequest{}, types.Record{}, http.StatusCreated},
+	{"confirmRecord", http.MethodPost, "/api/v1/admin/records/{nik}/confirm", "Make a temporary record permanent (?list= selects the list, default internal)", "records", nil, types.Record{}, http.StatusOK},
+	{"extendRecord", http.MethodPost, "/api/v1/admin/records/{nik}/extend", "Move the expiry of a temporary record (?list= selects the list, default internal)", "records", types.ExtendRecordRequest{}, types.Record{}, http.StatusOK},
+	{"recordHistory", http.MethodGet, "/api/v1/admin/records/{nik}/history", "List every change made to a blacklist record", "records", nil, []types.RecordChange{}, http.StatusOK},
+	{"recentChecks", http.MethodGet, "/api/v1/admin/checks", "List the latest recorded production checks (?limit=)", "records", nil, []types.RecentCheck{}, http.StatusOK},
+	{"recache", http.MethodPost, "/api/v1/admin/cache/recache", "Purge the cached results of specific NIKs and subjects, optionally rechecking the subjects", "records", types.RecacheRequest{}, types.RecacheResponse{}, http.StatusOK},
+	{"listPolicies", http.MethodGet, "/api/v1/admin/policies", "List the effective screening policies instances have run with, newest first", "records", nil, []store.PolicySnapshot{}, http.StatusOK},
+	{"getPolicy", http.MethodGet, "/api/v1/admin/policies/{version}", "Fetch the policy snapshot a recorded check was made under", "records", nil, store.PolicySnapshot{}, http.StatusOK},
+	{"listWhitelist", http.MethodGet, "/api/v1/admin/whitelist", "List active whitelist entries (?inactive=true includes expired and revoked ones)", "records", nil, []store.WhitelistEntry{}, http.StatusOK},
+	{"createWhitelistEntry", http.MethodPost, "/api/v1/admin/whitelist", "Clear a subject of matches on a record until the entry expires", "records", whitelistRequest{}, store.WhitelistEntry{}, http.StatusCreated},
+	{"revokeWhitelistEntry", http.MethodPost, "/api/v1/admin/whitelist/{id}/revoke", "Revoke an active whitelist entry", "records", nil, store.WhitelistEntry{}, http.StatusOK},
+	{"pinRecord", http.MethodPost, "/api/v1/admin/records/{nik}/pins", "Pin a record for an investigation case so expiry, deletion and list syncs leave it alone (?list= selects the list)", "records", pinRequest{}, store.Pin{}, http.StatusCreated},
+	{"listPins", http.MethodGet, "/api/v1/admin/pins", "List active record pins (?case_id= and ?nik= narrow the listing, ?released=true includes released pins)", "records", nil, []store.Pin{}, http.StatusOK},
+	{"unpinRecord", http.MethodPost, "/api/v1/admin/pins/{id}/unpin", "Release a pin before its case closes", "records", nil, store.Pin{}, http.StatusOK},
+	{"closeCase", http.MethodPost, "/api/v1/admin/cases/{caseID}/close", "Release every pin of a closed investigation case", "records", nil, closeCaseResponse{}, http.StatusOK},
+	{"listReviewCases", http.MethodGet, "/api/v1/admin/review-cases", "List the review cases opened by positive checks, oldest first (?status=open|cleared|confirmed, ?assignee=, ?after= and ?limit=)", "records", nil, []store.ReviewCase{}, http.StatusOK},
+	{"getReviewCase", http.MethodGet, "/api/v1/admin/review-cases/{id}", "Look up a review case", "records", nil, store.ReviewCase{}, http.StatusOK},
+	{"updateReviewCase", http.MethodPatch, "/api/v1/admin/review-cases/{id}", "Change the status, assignee or notes of a review case", "records", caseUpdateRequest{}, store.ReviewCase{}, http.StatusOK},
+	{"listProposals", http.MethodGet, "/api/v1/admin/proposals", "List record changes proposed for approval (?status=pending|approved|rejected, pending by default)", "records", nil, []proposalResponse{}, http.StatusOK},
+	{"getProposal", http.MethodGet, "/api/v1/admin/proposals/{id}", "Look up a record change proposed for approval", "records", nil, proposalResponse{}, http.StatusOK},
+	{"approveProposal", http.MethodPost, "/api/v1/admin/proposals/{id}/approve", "Approve a proposed record change, applying it; needs APPROVAL_ROLE and a caller other than the proposer", "records", proposalDecisionRequest{}, proposalResponse{}, http.StatusOK},
+	{"rejectProposal", http.MethodPost, "/api/v1/admin/proposals/{id}/reject", "Reject a proposed record change; needs APPROVAL_ROLE", "records", proposalDecisionRequest{}, proposalResponse{}, http.StatusOK},
+	{"listDuplicates", http.MethodGet, "/api/v1/admin/duplicates", "List likely duplicate record pairs (?status=pending|merged|dismissed, pending by default)", "records", nil, []duplicateResponse{}, http.StatusOK},
+	{"getDuplicate", http.MethodGet, "/api/v1/admin/duplicates/{id}", "Look up a likely duplicate record pair", "records", nil, duplicateResponse{}, http.StatusOK},
+	{"mergeDuplicate", http.MethodPost, "/api/v1/admin/duplicates/{id}/merge", "Merge a duplicate pair into the record keep, deleting the other and keeping its names as aliases", "records", mergeRequest{}, duplicateResponse{}, http.StatusOK},
+	{"dismissDuplicate", http.MethodPost, "/api/v1/admin/duplicates/{id}/dismiss", "Mark a likely duplicate pair as different people", "records", nil, duplicateResponse{}, http.StatusOK},
+	{"syncStatus", http.MethodGet, "/api/v1/admin/sync/status", "Report the latest sync of every external sanctions source", "sync", nil, []store.SyncStatus{}, http.StatusOK},
+	{"listQuarantined", http.MethodGet, "/api/v1/admin/sync/quarantine", "List quarantined sync change sets", "sync", nil, []store.QuarantineEntry{}, http.StatusOK},
+	{"approveQuarantined", http.MethodPost, "/api/v1/admin/sync/quarantine/{id}/approve", "Approve and apply a quarantined change set", "sync", decisionRequest{}, nil, http.StatusNoContent},
+	{"rejectQuarantined", http.MethodPost, "/api/v1/admin/sync/quarantine/{id}/reject", "Reject a quarantined change set", "sync", decisionRequest{}, nil, http.StatusNoContent},
+	{"issueBreakGlass", http.MethodPost, "/api/v1/breakglass", "Request time-boxed emergency admin access", "auth", breakGlassRequest{}, breakGlassResponse{}, http.StatusCreated},
+	{"listBreakGlass", http.MethodGet, "/api/v1/admin/breakglass", "List break-glass grants issued in the last 30 days", "auth", nil, []store.BreakGlassGrant{}, http.StatusOK},
+	{"revokeBreakGlass", http.MethodPost, "/api/v1/admin/breakglass/{id}/revoke", "Revoke an active break-glass grant", "auth", nil, store.BreakGlassGrant{}, http.StatusOK},
+	{"breakGlassAudit", http.MethodGet, "/api/v1/admin/breakglass/{id}/audit", "List every request made with a break-glass grant", "auth", nil, []store.BreakGlassUse{}, http.StatusOK},
+	{"listCertMappings", http.MethodGet, "/api/v1/admin/cert-mappings", "List client certificate mappings", "auth", nil, []certMappingResponse{}, http.StatusOK},
+	{"createCertMapping", http.MethodPost, "/api/v1/admin/cert-mappings", "Create a client certificate mapping", "auth", certMappingRequest{}, certMappingResponse{}, http.StatusCreated},
+	{"deleteCertMapping", http.MethodDelete, "/api/v1/admin/cert-mappings/{id}", "Delete a client certificate mapping", "auth", nil, nil, http.StatusNoContent},
+	{"dryRunCheck", http.MethodPost, "/api/v1/blacklist/simulate", "Evaluate a check under per-request match parameters and rate every candidate record", "screening", types.DryRunRequest{}, types.DryRunResponse{}, http.StatusOK},
+	{"simulate", http.MethodPost, "/api/v1/admin/simulate", "Compare a check under the current and a proposed match policy", "screening", types.SimulationRequest{}, types.SimulationResponse{}, http.StatusOK},
+	{"listActivity", http.MethodGet, "/api/v1/admin/activity", "Page through admin activity, newest first (?kind=, ?action=, ?actor=, ?target=, ?since=, ?until=, ?system=true, ?cursor=)", "operations", nil, activityPage{}, http.StatusOK},
+	{"exportActivity", http.MethodGet, "/api/v1/audit/export", "Stream admin activity matching the listActivity filters as CSV or JSON lines (?format=csv|jsonl)", "operations", nil, nil, http.StatusOK},
+	{"pendingMigrations", http.MethodGet, "/api/v1/admin/migrations", "Report pending schema migrations", "operations", nil, migrate.Status{}, http.StatusOK},
+	{"instanceStats", http.MethodGet, "/api/v1/admin/stats", "Report the build, uptime, runtime, connection pool and cache stats of the instance answering", "operations", nil, statsResponse{}, http.StatusOK},
+	{"captureProfile", http.MethodPost, "/api/v1/admin/runtime/profiles", "Capture a CPU or heap profile of the instance to INTERNAL_PROFILE_DIR (internal listener, INTERNAL_PPROF)", "operations", profileRequest{}, profileResponse{}, http.StatusOK},
+	{"cacheInsights", http.MethodGet, "/api/v1/admin/cache/insights", "Report cache hit ratios by subject popularity (hot, warm, cold), repeat misses and the subjects needed to serve each share of lookups, per cache tier", "operations", nil, cacheInsightResponse{}, http.StatusOK},
+	{"readinessCheck", http.MethodGet, "/readyz", "Readiness probe with dependency checks", "operations", nil, types.ReadinessResponse{}, http.StatusOK},
+	{"readinessDetails", http.MethodGet, "/api/v1/admin/readyz", "Readiness probe saying why each unavailable dependency is down", "operations", nil, types.ReadinessResponse{}, http.StatusOK},
+}
+
+// OpenAPISpec builds the OpenAPI 3 document for the service
+func OpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]map[string]interface{}{}
+
+	// v1 operations that v2 also serves are deprecated
+	succeeded := map[string]bool{}
+	for _, op := range operations {
+		if strings.HasPrefix(op.path, PrefixV2+"/") {
+			succeeded[op.method+" "+strings.TrimPrefix(op.path, PrefixV2)] = true
+		}
+	}
+
+	for _, op := range operations {
+		o := map[string]interface{}{
+			"operationId": op.id,
+			"summary":     op.summary,
+			"tags":        []string{op.tag},
+		}
+		if strings.HasPrefix(op.path, PrefixV1+"/") && succeeded[op.method+" "+strings.TrimPrefix(op.path, PrefixV1)] {
+			o["deprecated"] = true
+		}
+
+		var params []interface{}
+		for _, segment := range strings.Split(op.path, "/") {
+			if strings.HasPrefix(segment, "{") {
+				params = append(params, map[string]interface{}{
+					"name":     strings.Trim(segment, "{}"),
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]string{"type": "string"},
+				})
+			}
+		}
+		if params != nil {
+			o["parameters"] = params
+		}
+
+		if op.request != nil {
+			o["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemaFor(reflect.TypeOf(op.request), schemas),
+					},
+				},
+			}
+		}
+
+		response := map[string]interface{}{"description": http.StatusText(op.status)}
+		if op.response != nil {
+			response["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": schemaFor(reflect.TypeOf(op.response), schemas),
+				},
+			}
+		}
+		o["responses"] = map[string]interface{}{
+			strconv.Itoa(op.status): response,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemaFor(reflect.TypeOf(types.ErrorResponse{}), schemas),
+					},
+				},
+			},
+		}
+
+		if paths[op.path] == nil {
+			paths[op.path] = map[string]interface{}{}
+		}
+		paths[op.path][strings.ToLower(op.method)] = o
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":   "Blacklist Check Service",
+			"version": "v2",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]string{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []map[string][]string{{"apiKey": {}}},
+	}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor returns the JSON schema for t, registering named structs as components
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawType:
+		// Arbitrary embedded JSON
+		return map[string]interface{}{"type": "object"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case t.Kind() == reflect.Struct:
+		schema := structSchema(t, schemas)
+		if t.Name() == "" {
+			return schema
+		}
+		name := componentName(t.Name())
+		schemas[name] = schema
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		// Untagged embedded structs contribute their fields, like in encoding/json
+		if embedded := field.Type; field.Anonymous && name == "" {
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := structSchema(embedded, schemas)
+				for k, v := range inner["properties"].(map[string]interface{}) {
+					properties[k] = v
+				}
+				if innerRequired, ok := inner["required"].([]string); ok {
+					required = append(required, innerRequired...)
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaFor(field.Type, schemas)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// componentName turns an unexported Go type name into a schema name
+func componentName(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// OpenAPI serves the OpenAPI specification
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OpenAPISpec())
+}
+
+// swaggerUI renders Swagger UI against /openapi.json
+const swaggerUI = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Blacklist Check API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>`
+
+// Docs serves the Swagger UI
+func (h *Handler) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUI))
+}
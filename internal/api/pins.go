@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// pinRequest represents the request body for pinning a record to a case
+type pinRequest struct {
+	CaseID string `json:"case_id"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// closeCaseResponse lists the pins released by closing a case
+type closeCaseResponse struct {
+	CaseID   string       `json:"case_id"`
+	Released []*store.Pin `json:"released"`
+}
+
+// PinRecord handles pinning a record for an investigation case
+func (h *Handler) PinRecord(w http.ResponseWriter, r *http.Request) {
+	list, err := listParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	var req pinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+
+	pin, err := h.service.PinRecord(actorContext(r), list, chi.URLParam(r, "nik"), req.CaseID, req.Reason)
+	switch {
+	case errors.Is(err, service.ErrPinsUnavailable):
+		apierror.NotFound(w, r, "Record pins are not available")
+		return
+	case errors.Is(err, service.ErrCaseID):
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	case errors.Is(err, store.ErrRecordNotFound):
+		apierror.NotFound(w, r, "Record not found")
+		return
+	case errors.Is(err, store.ErrSharedList):
+		apierror.Forbidden(w, r)
+		return
+	case errors.Is(err, store.ErrPinExists):
+		apierror.Conflict(w, r, "Record is already pinned by the case")
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error pinning record", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pin)
+}
+
+// ListPins handles listing active pins; ?case_id= and ?nik= narrow the
+// listing and ?released=true includes released pins
+func (h *Handler) ListPins(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	pins, err := h.service.ListPins(r.Context(), store.PinFilter{
+		CaseID:          q.Get("case_id"),
+		NIK:             q.Get("nik"),
+		IncludeReleased: q.Get("released") == "true",
+	})
+	if errors.Is(err, service.ErrPinsUnavailable) {
+		apierror.NotFound(w, r, "Record pins are not available")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error listing pins", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	if pins == nil {
+		pins = []*store.Pin{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pins)
+}
+
+// UnpinRecord handles releasing a pin before its case closes
+func (h *Handler) UnpinRecord(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid pin ID", nil)
+		return
+	}
+
+	pin, err := h.service.UnpinRecord(actorContext(r), id)
+	if errors.Is(err, service.ErrPinsUnavailable) {
+		apierror.NotFound(w, r, "Record pins are not available")
+		return
+	}
+	if errors.Is(err, store.ErrPinNotFound) {
+		apierror.NotFound(w, r, "Active pin not found")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error unpinning record", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pin)
+}
+
+// CloseCase handles a case closing, releasing every pin it holds. Closing a
+// case that pins nothing, or was already closed, releases nothing.
+func (h *Handler) CloseCase(w http.ResponseWriter, r *http.Request) {
+	caseID := chi.URLParam(r, "caseID")
+	pins, err := h.service.CloseCase(actorContext(r), caseID)
+	if errors.Is(err, service.ErrPinsUnavailable) {
+		apierror.NotFound(w, r, "Record pins are not available")
+		return
+	}
+	if errors.Is(err, service.ErrCaseID) {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error closing case", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	if pins == nil {
+		pins = []*store.Pin{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(closeCaseResponse{CaseID: caseID, Released: pins})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// PolicyHandler handles screening policy snapshot requests
+type PolicyHandler struct {
+	store store.PolicyStore
+	log   *zap.Logger
+}
+
+// NewPolicyHandler creates a new policy snapshot handler
+func NewPolicyHandler(store store.PolicyStore, log *zap.Logger) *PolicyHandler {
+	return &PolicyHandler{
+		store: store,
+		log:   log,
+	}
+}
+
+// ListPolicies handles listing every policy snapshot, newest first
+func (h *PolicyHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.store.List(r.Context())
+	if err != nil {
+		logger(r, h.log).Error("Error listing policy snapshots", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	if snapshots == nil {
+		snapshots = []*store.PolicySnapshot{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// GetPolicy handles fetching the policy snapshot a recorded decision was made under
+func (h *PolicyHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := h.store.Get(r.Context(), chi.URLParam(r, "version"))
+	if errors.Is(err, store.ErrPolicyNotFound) {
+		apierror.NotFound(w, r, "Policy snapshot not found")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error loading policy snapshot", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/profiling"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// defaultProfileSeconds is how long a CPU profile samples unless asked otherwise
+const defaultProfileSeconds = 30
+
+// ProfileHandler captures runtime profiles on demand
+type ProfileHandler struct {
+	profiler *profiling.Profiler
+	activity store.ActivityStore
+	log      *zap.Logger
+}
+
+// NewProfileHandler creates a new profile handler
+func NewProfileHandler(profiler *profiling.Profiler, activity store.ActivityStore, log *zap.Logger) *ProfileHandler {
+	return &ProfileHandler{profiler: profiler, activity: activity, log: log}
+}
+
+// profileRequest asks for a profile of a kind; seconds only applies to CPU profiles
+type profileRequest struct {
+	Kind    string `json:"kind"`
+	Seconds int    `json:"seconds,omitempty"`
+}
+
+// profileResponse names the profile written to the profile directory
+type profileResponse struct {
+	Kind            string    `json:"kind"`
+	File            string    `json:"file"`
+	Bytes           int64     `json:"bytes"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// CaptureProfile handles capturing a CPU or heap profile of this instance to
+// the profile directory. A CPU profile holds the request for its duration.
+func (h *ProfileHandler) CaptureProfile(w http.ResponseWriter, r *http.Request) {
+	if h.profiler == nil {
+		apierror.NotFound(w, r, "Profiling is disabled")
+		return
+	}
+	var req profileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+	if req.Kind != profiling.KindCPU && req.Kind != profiling.KindHeap {
+		apierror.Validation(w, r, fmt.Sprintf("kind must be one of %s", strings.Join(profiling.Kinds, ", ")), nil)
+		return
+	}
+	if req.Seconds < 0 {
+		apierror.Validation(w, r, "seconds must be positive", nil)
+		return
+	}
+	if req.Seconds == 0 {
+		req.Seconds = defaultProfileSeconds
+	}
+	duration := time.Duration(req.Seconds) * time.Second
+	// A profile cut off by the request timeout would be lost
+	if deadline, ok := r.Context().Deadline(); ok && req.Kind == profiling.KindCPU && time.Until(deadline) <= duration {
+		apierror.Validation(w, r, "seconds must be shorter than the request timeout", nil)
+		return
+	}
+
+	profile, err := h.profiler.Capture(r.Context(), req.Kind, duration)
+	if errors.Is(err, profiling.ErrBusy) {
+		apierror.Conflict(w, r, "A profile is already being captured")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error capturing profile", zap.String("kind", req.Kind), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	logger(r, h.log).Info("Captured profile",
+		zap.String("kind", profile.Kind),
+		zap.String("file", profile.File),
+		zap.Int64("bytes", profile.Bytes))
+	recordActivity(r, h.activity, h.log, store.ActivityRuntime, "profile", profile.File, map[string]interface{}{
+		"kind":    profile.Kind,
+		"seconds": profile.Duration.Seconds(),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profileResponse{
+		Kind:            profile.Kind,
+		File:            profile.File,
+		Bytes:           profile.Bytes,
+		StartedAt:       profile.StartedAt,
+		DurationSeconds: profile.Duration.Seconds(),
+	})
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/approval"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// proposalResponse is a proposal with the record it proposes, if any
+type proposalResponse struct {
+	*store.Proposal
+	Record *types.Record `json:"record,omitempty"`
+}
+
+// proposalDecisionRequest represents the request body for deciding a proposal
+type proposalDecisionRequest struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+// newProposalResponse converts a proposal to its API representation
+func newProposalResponse(proposal *store.Proposal) (proposalResponse, error) {
+	resp := proposalResponse{Proposal: proposal}
+	record, err := proposal.Decode()
+	if err != nil {
+		return resp, err
+	}
+	if record != nil {
+		r := newRecordResponse(record)
+		resp.Record = &r
+	}
+	return resp, nil
+}
+
+// propose answers a record change with the proposal holding it for approval
+func (h *Handler) propose(w http.ResponseWriter, r *http.Request, proposal *store.Proposal, record *store.BlacklistRecord) {
+	if err := h.approvals.Propose(actorContext(r), proposal, record); err != nil {
+		logger(r, h.log).Error("Error proposing record change", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	h.writeProposal(w, r, http.StatusAccepted, proposal)
+}
+
+// writeProposal encodes a proposal with status
+func (h *Handler) writeProposal(w http.ResponseWriter, r *http.Request, status int, proposal *store.Proposal) {
+	resp, err := newProposalResponse(proposal)
+	if err != nil {
+		logger(r, h.log).Error("Error decoding proposal", zap.Int64("proposal_id", proposal.ID), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListProposals handles listing proposed record changes; ?status= picks
+// pending (the default), approved or rejected ones
+func (h *Handler) ListProposals(w http.ResponseWriter, r *http.Request) {
+	if h.approvals == nil {
+		apierror.NotFound(w, r, "Approvals are not enabled")
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = store.ProposalPending
+	case store.ProposalPending, store.ProposalApproved, store.ProposalRejected:
+	default:
+		apierror.Validation(w, r, "status must be pending, approved or rejected", nil)
+		return
+	}
+
+	proposals, err := h.approvals.List(r.Context(), status)
+	if err != nil {
+		logger(r, h.log).Error("Error listing proposals", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	resp := make([]proposalResponse, 0, len(proposals))
+	for _, proposal := range proposals {
+		p, err := newProposalResponse(proposal)
+		if err != nil {
+			logger(r, h.log).Error("Error decoding proposal", zap.Int64("proposal_id", proposal.ID), zap.Error(err))
+			apierror.Internal(w, r)
+			return
+		}
+		resp = append(resp, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetProposal handles looking up a proposed record change
+func (h *Handler) GetProposal(w http.ResponseWriter, r *http.Request) {
+	if h.approvals == nil {
+		apierror.NotFound(w, r, "Approvals are not enabled")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid proposal ID", nil)
+		return
+	}
+
+	proposal, err := h.approvals.Get(r.Context(), id)
+	if errors.Is(err, store.ErrProposalNotFound) {
+		apierror.NotFound(w, r, "Proposal not found")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error loading proposal", zap.Int64("proposal_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	h.writeProposal(w, r, http.StatusOK, proposal)
+}
+
+// ApproveProposal handles approving a proposed record change, which applies it
+func (h *Handler) ApproveProposal(w http.ResponseWriter, r *http.Request) {
+	h.decideProposal(w, r, "approving", h.approvals.Approve)
+}
+
+// RejectProposal handles rejecting a proposed record change
+func (h *Handler) RejectProposal(w http.ResponseWriter, r *http.Request) {
+	h.decideProposal(w, r, "rejecting", h.approvals.Reject)
+}
+
+func (h *Handler) decideProposal(w http.ResponseWriter, r *http.Request, verb string, decide func(ctx context.Context, id int64, comment string) (*store.Proposal, error)) {
+	if h.approvals == nil {
+		apierror.NotFound(w, r, "Approvals are not enabled")
+		return
+	}
+	// Only authenticated callers holding the approver role decide, so every
+	// decision names who made it
+	identity := auth.FromContext(r.Context())
+	if identity == nil || !identity.HasRole(h.approvals.Role()) {
+		apierror.Forbidden(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid proposal ID", nil)
+		return
+	}
+	var req proposalDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+
+	proposal, err := decide(actorContext(r), id, req.Comment)
+	switch {
+	case errors.Is(err, store.ErrProposalNotFound):
+		apierror.NotFound(w, r, "Proposal not found")
+		return
+	case errors.Is(err, store.ErrProposalDecided):
+		apierror.Conflict(w, r, "Proposal already decided")
+		return
+	case errors.Is(err, approval.ErrSelfApproval):
+		apierror.Write(w, r, http.StatusForbidden, types.ErrCodeForbidden, "A proposal can't be approved by its proposer", nil)
+		return
+	// The change itself can fail like it would have when made directly
+	case errors.Is(err, store.ErrRecordExists):
+		apierror.Conflict(w, r, "Record already exists")
+		return
+	case errors.Is(err, store.ErrRecordNotFound):
+		apierror.NotFound(w, r, "Record not found")
+		return
+	case errors.Is(err, store.ErrRecordPinned):
+		apierror.Conflict(w, r, "Record is pinned by an open case")
+		return
+	case errors.Is(err, store.ErrSharedList):
+		apierror.Forbidden(w, r)
+		return
+	case errors.Is(err, service.ErrTemporaryDuration):
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	case errors.Is(err, store.ErrDuplicateNotFound):
+		apierror.NotFound(w, r, "Duplicate pair not found")
+		return
+	case errors.Is(err, store.ErrDuplicateDecided):
+		apierror.Conflict(w, r, "Duplicate pair already decided")
+		return
+	case errors.Is(err, store.ErrDuplicateNIKs):
+		apierror.Conflict(w, r, differentNIKs)
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error "+verb+" proposal", zap.Int64("proposal_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	h.writeProposal(w, r, http.StatusOK, proposal)
+}
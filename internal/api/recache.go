@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// maxRecacheSubjects bounds a recache request, as rechecks run inline
+const maxRecacheSubjects = 100
+
+// Recache handles purging the cached results of specific subjects after their
+// data was corrected, optionally rechecking them to repopulate the cache
+func (h *Handler) Recache(w http.ResponseWriter, r *http.Request) {
+	var req types.RecacheRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+	if len(req.NIKs) == 0 && len(req.Subjects) == 0 {
+		apierror.Validation(w, r, "niks or subjects is required", nil)
+		return
+	}
+	if len(req.NIKs)+len(req.Subjects) > maxRecacheSubjects {
+		apierror.Validation(w, r, fmt.Sprintf("At most %d NIKs and subjects can be recached at once", maxRecacheSubjects), nil)
+		return
+	}
+	for _, nik := range req.NIKs {
+		if strings.TrimSpace(nik) == "" || strings.Contains(nik, ",") {
+			apierror.Validation(w, r, fmt.Sprintf("Invalid NIK %q", nik), nil)
+			return
+		}
+	}
+	subjects := make([]service.CheckRequest, 0, len(req.Subjects))
+	for i, subject := range req.Subjects {
+		serviceReq, err := newServiceCheckRequest(subject)
+		if err != nil {
+			apierror.Validation(w, r, fmt.Sprintf("subjects[%d]: %s", i, err.Error()), nil)
+			return
+		}
+		serviceReq.Diagnostics = false
+		subjects = append(subjects, serviceReq)
+	}
+
+	recache, err := h.service.Recache(r.Context(), req.NIKs, subjects, req.Recheck)
+	if err != nil {
+		logger(r, h.log).Error("Error recaching subjects", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	niks := append([]string(nil), req.NIKs...)
+	for _, subject := range subjects {
+		if subject.NIK != "" {
+			niks = append(niks, subject.NIK)
+		}
+	}
+	recordActivity(r, h.activity, h.log, store.ActivityCache, "recache", "", map[string]interface{}{
+		"niks":     niks,
+		"subjects": len(subjects),
+		"recheck":  req.Recheck,
+		"purged":   recache.Purged,
+	})
+
+	locale := reason.Negotiate(r.Header.Get("Accept-Language"))
+	response := types.RecacheResponse{Purged: recache.Purged}
+	for i, result := range recache.Results {
+		if err := recache.Errors[i]; err != nil {
+			logger(r, h.log).Error("Error rechecking subject", zap.Int("subject", i), zap.Error(err))
+			response.Results = append(response.Results, types.RecacheResult{Error: err.Error()})
+			continue
+		}
+		check := checkResponse(result, locale)
+		response.Results = append(response.Results, types.RecacheResult{Result: &check})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", locale)
+	json.NewEncoder(w).Encode(response)
+}
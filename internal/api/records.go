@@ -0,0 +1,511 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/nationalid"
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/validate"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// newRecordResponse converts a store record to its API representation
+func newRecordResponse(record *store.BlacklistRecord) types.Record {
+	return types.Record{
+		ID:           record.ID,
+		List:         record.List,
+		NIK:          record.NIK,
+		Name:         record.Name,
+		BirthPlace:   record.BirthPlace,
+		BirthDate:    record.BirthDate,
+		Reason:       record.Reason,
+		ReasonCode:   record.ReasonCode,
+		ReasonParams: record.ReasonParams,
+		Source:       record.Source,
+		CreatedAt:    record.CreatedAt,
+		UpdatedAt:    record.UpdatedAt,
+		DeletedAt:    record.DeletedAt,
+		DeletedBy:    record.DeletedBy,
+		ExpiresAt:    record.ExpiresAt,
+		Aliases:      aliasResponses(record.Aliases),
+	}
+}
+
+// aliasResponses converts store aliases to their API representation
+func aliasResponses(aliases []store.Alias) []types.Alias {
+	var out []types.Alias
+	for _, alias := range aliases {
+		out = append(out, types.Alias{Alias: alias.Alias, Type: alias.Type})
+	}
+	return out
+}
+
+// newAliases validates the aliases of a record request, recording failures
+// in errs. Omitted aliases stay nil so that an update keeps those the record
+// has.
+func newAliases(aliases []types.Alias, errs *validate.Errors) []store.Alias {
+	if aliases == nil {
+		return nil
+	}
+	out := make([]store.Alias, 0, len(aliases))
+	for i, alias := range aliases {
+		field := fmt.Sprintf("aliases[%d]", i)
+		if len(strings.TrimSpace(alias.Alias)) < 3 {
+			errs.Add(field+".alias", "Alias must be at least 3 characters long")
+		} else {
+			errs.Length(field+".alias", alias.Alias, 0, maxNameLength)
+		}
+		if alias.Type == "" {
+			alias.Type = store.AliasAKA
+		}
+		if !store.ValidAliasType(alias.Type) {
+			errs.Add(field+".alias_type", fmt.Sprintf("Alias type must be one of %s", strings.Join(store.AliasTypes, ", ")))
+		}
+		out = append(out, store.Alias{Alias: alias.Alias, Type: alias.Type})
+	}
+	return out
+}
+
+// Page sizes for admin listings
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// limitParam returns the page size requested by the "limit" query parameter
+func limitParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	return limit, nil
+}
+
+// callerSubject returns the authenticated subject of the request, or
+// "anonymous" on open routes
+func callerSubject(r *http.Request) string {
+	if identity := auth.FromContext(r.Context()); identity != nil {
+		return identity.Subject
+	}
+	return "anonymous"
+}
+
+// actorContext attributes record changes made by the request to its caller
+func actorContext(r *http.Request) context.Context {
+	return store.WithActor(r.Context(), callerSubject(r))
+}
+
+// listParam returns the list named by the "list" query parameter, defaulting
+// to the internal list
+func listParam(r *http.Request) (string, error) {
+	list := r.URL.Query().Get("list")
+	if list == "" {
+		return lists.Internal, nil
+	}
+	if err := lists.Validate([]string{list}); err != nil {
+		return "", err
+	}
+	return list, nil
+}
+
+// SearchRecords handles browsing the records of a list. ?q= matches a NIK
+// prefix or a name, ?deleted=true includes soft-deleted records.
+func (h *Handler) SearchRecords(w http.ResponseWriter, r *http.Request) {
+	list, err := listParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	limit, err := limitParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	includeDeleted := r.URL.Query().Get("deleted") == "true"
+
+	records, err := h.service.SearchRecords(r.Context(), list, query, includeDeleted, limit)
+	if err != nil {
+		logger(r, h.log).Error("Error searching records", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	response := make([]types.Record, 0, len(records))
+	for _, record := range records {
+		response = append(response, newRecordResponse(record))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// timeParam parses the named query parameter as an RFC 3339 time or a date
+func timeParam(r *http.Request, name string) (*time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("%s must be an RFC 3339 time or a YYYY-MM-DD date", name)
+}
+
+// recordQuery parses the record filters and ?sort= shared by the record
+// listing and export
+func recordQuery(r *http.Request) (store.RecordFilter, string, error) {
+	q := r.URL.Query()
+	filter := store.RecordFilter{
+		List:           q.Get("list"),
+		Name:           strings.TrimSpace(q.Get("name")),
+		NIK:            strings.TrimSpace(q.Get("nik")),
+		Source:         q.Get("source"),
+		IncludeDeleted: q.Get("deleted") == "true",
+		Temporary:      q.Get("temporary") == "true",
+	}
+	if filter.List != "" {
+		if err := lists.Validate([]string{filter.List}); err != nil {
+			return filter, "", err
+		}
+	}
+	var err error
+	if filter.CreatedAfter, err = timeParam(r, "created_after"); err != nil {
+		return filter, "", err
+	}
+	if filter.CreatedBefore, err = timeParam(r, "created_before"); err != nil {
+		return filter, "", err
+	}
+	sort := q.Get("sort")
+	if sort == "" {
+		sort = "id"
+	}
+	if !store.ValidRecordSort(sort) {
+		return filter, "", fmt.Errorf("sort must be one of %s, optionally prefixed with -", strings.Join(store.RecordSorts, ", "))
+	}
+	return filter, sort, nil
+}
+
+// ListRecords handles paging through the records matching ?name= (contained
+// in the name), ?nik= (NIK prefix), ?list=, ?source=, ?created_after= /
+// ?created_before= and ?temporary=true, ordered by ?sort= (default id) and continued with ?cursor=.
+// A page no record has changed under since the client's If-None-Match is
+// answered 304 Not Modified.
+func (h *Handler) ListRecords(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, sort, err := recordQuery(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	limit, err := limitParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	if h.notModified(w, r, store.VersionRecords) {
+		return
+	}
+
+	records, next, err := h.service.ListRecords(r.Context(), filter, sort, q.Get("cursor"), limit)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		apierror.Validation(w, r, "cursor is invalid or belongs to a different sort", nil)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error listing records", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	// A walk of the dataset is recorded once, when its first page is fetched
+	if q.Get("cursor") == "" {
+		recordActivity(r, h.activity, h.log, store.ActivityExport, "records", filter.List, map[string]string{"query": r.URL.RawQuery})
+	}
+
+	response := types.RecordPage{
+		Records:    make([]types.Record, 0, len(records)),
+		NextCursor: next,
+	}
+	for _, record := range records {
+		response.Records = append(response.Records, newRecordResponse(record))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RecentChecks handles listing the latest recorded production checks
+func (h *Handler) RecentChecks(w http.ResponseWriter, r *http.Request) {
+	limit, err := limitParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+
+	entries, err := h.service.RecentChecks(r.Context(), limit)
+	if err != nil {
+		logger(r, h.log).Error("Error loading recent checks", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	response := make([]types.RecentCheck, 0, len(entries))
+	for _, e := range entries {
+		response = append(response, types.RecentCheck{
+			ID:            e.ID,
+			Name:          e.Name,
+			NIK:           e.NIK,
+			BirthPlace:    e.BirthPlace,
+			BirthDate:     e.BirthDate,
+			Lists:         e.Lists,
+			Blacklisted:   e.Blacklisted,
+			MatchType:     e.MatchType,
+			PolicyVersion: e.PolicyVersion,
+			CheckedAt:     e.CheckedAt,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateRecord handles adding a record to the blacklist
+func (h *Handler) CreateRecord(w http.ResponseWriter, r *http.Request) {
+	var req types.RecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+	record, err := newRecord(req)
+	if err != nil {
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	if h.approvals != nil {
+		h.propose(w, r, &store.Proposal{Action: store.ChangeCreate, List: record.List, NIK: record.NIK}, record)
+		return
+	}
+	err = h.service.CreateRecord(actorContext(r), record)
+	if errors.Is(err, store.ErrRecordExists) {
+		apierror.Conflict(w, r, "Record already exists")
+		return
+	}
+	if errors.Is(err, store.ErrSharedList) {
+		apierror.Forbidden(w, r)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error creating record", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newRecordResponse(record))
+}
+
+// maxSourceLength is the longest source a record may name
+const maxSourceLength = 50
+
+// newRecord validates a request to create a record and converts it to a store
+// record. Every failing field is reported, as validate.Errors.
+func newRecord(req types.RecordRequest) (*store.BlacklistRecord, error) {
+	var errs validate.Errors
+	id, err := nationalid.Parse(nationalid.DefaultCountry, req.NIK)
+	errs.Check("nik", err)
+	if req.List == "" {
+		req.List = lists.Internal
+	}
+	errs.Check("list", lists.Validate([]string{req.List}))
+	record := recordFields(req, &errs)
+	errs.Length("source", req.Source, 0, maxSourceLength)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	record.List = req.List
+	record.NIK = id.Number
+	record.Source = req.Source
+	return record, nil
+}
+
+// recordFields validates the fields a record request may set on create and
+// update alike, recording failures in errs
+func recordFields(req types.RecordRequest, errs *validate.Errors) *store.BlacklistRecord {
+	errs.Length("name", req.Name, 3, maxNameLength)
+	errs.Length("birth_place", req.BirthPlace, 0, maxBirthPlaceLength)
+	birthDate := validate.Date(req.BirthDate)
+	if req.ReasonCode != "" {
+		errs.Check("reason_code", reason.Validate(req.ReasonCode, req.ReasonParams))
+	}
+	return &store.BlacklistRecord{
+		Name:         req.Name,
+		BirthPlace:   req.BirthPlace,
+		BirthDate:    birthDate,
+		Reason:       req.Reason,
+		ReasonCode:   req.ReasonCode,
+		ReasonParams: req.ReasonParams,
+		Aliases:      newAliases(req.Aliases, errs),
+	}
+}
+
+// UpdateRecord handles modifying a blacklist record
+func (h *Handler) UpdateRecord(w http.ResponseWriter, r *http.Request) {
+	list, err := listParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+
+	var req types.RecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+	var errs validate.Errors
+	record := recordFields(req, &errs)
+	if err := errs.Err(); err != nil {
+		apierror.Invalid(w, r, err)
+		return
+	}
+	record.List = list
+	record.NIK = chi.URLParam(r, "nik")
+
+	if h.approvals != nil {
+		h.propose(w, r, &store.Proposal{Action: store.ChangeUpdate, List: list, NIK: record.NIK}, record)
+		return
+	}
+	err = h.service.UpdateRecord(actorContext(r), record)
+	if errors.Is(err, store.ErrRecordNotFound) {
+		apierror.NotFound(w, r, "Record not found")
+		return
+	}
+	if errors.Is(err, store.ErrSharedList) {
+		apierror.Forbidden(w, r)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error updating record", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRecordResponse(record))
+}
+
+// DeleteRecord handles removing a record from the blacklist
+func (h *Handler) DeleteRecord(w http.ResponseWriter, r *http.Request) {
+	list, err := listParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+
+	if h.approvals != nil {
+		h.propose(w, r, &store.Proposal{Action: store.ChangeDelete, List: list, NIK: chi.URLParam(r, "nik")}, nil)
+		return
+	}
+	err = h.service.DeleteRecord(actorContext(r), list, chi.URLParam(r, "nik"))
+	if errors.Is(err, store.ErrRecordNotFound) {
+		apierror.NotFound(w, r, "Record not found")
+		return
+	}
+	if errors.Is(err, store.ErrRecordPinned) {
+		apierror.Conflict(w, r, "Record is pinned by an open case")
+		return
+	}
+	if errors.Is(err, store.ErrSharedList) {
+		apierror.Forbidden(w, r)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error deleting record", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreRecord handles reversing the deletion of a blacklist record
+func (h *Handler) RestoreRecord(w http.ResponseWriter, r *http.Request) {
+	list, err := listParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+
+	if h.approvals != nil {
+		h.propose(w, r, &store.Proposal{Action: store.ChangeRestore, List: list, NIK: chi.URLParam(r, "nik")}, nil)
+		return
+	}
+	record, err := h.service.RestoreRecord(actorContext(r), list, chi.URLParam(r, "nik"))
+	if errors.Is(err, store.ErrRecordNotFound) {
+		apierror.NotFound(w, r, "Deleted record not found")
+		return
+	}
+	if errors.Is(err, store.ErrSharedList) {
+		apierror.Forbidden(w, r)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error restoring record", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRecordResponse(record))
+}
+
+// RecordHistory handles listing every change made to a blacklist record
+func (h *Handler) RecordHistory(w http.ResponseWriter, r *http.Request) {
+	changes, err := h.service.RecordHistory(r.Context(), chi.URLParam(r, "nik"))
+	if err != nil {
+		logger(r, h.log).Error("Error loading record history", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	if len(changes) == 0 {
+		apierror.NotFound(w, r, "Record not found")
+		return
+	}
+
+	response := make([]types.RecordChange, 0, len(changes))
+	for _, c := range changes {
+		response = append(response, types.RecordChange{
+			ID:        c.ID,
+			NIK:       c.NIK,
+			Action:    c.Action,
+			Before:    c.Before,
+			After:     c.After,
+			ChangedBy: c.ChangedBy,
+			ChangedAt: c.ChangedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,327 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/format"
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/screening"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// maxSubjectErrors caps the per-subject validation errors reported back
+const maxSubjectErrors = 100
+
+// ScreeningHandler handles bulk screening job requests
+type ScreeningHandler struct {
+	processor *screening.Processor
+	service   service.Service
+	activity  store.ActivityStore
+	log       *zap.Logger
+}
+
+// NewScreeningHandler creates a new screening handler
+func NewScreeningHandler(processor *screening.Processor, service service.Service, activity store.ActivityStore, log *zap.Logger) *ScreeningHandler {
+	return &ScreeningHandler{
+		processor: processor,
+		service:   service,
+		activity:  activity,
+		log:       log,
+	}
+}
+
+// subjectError describes an invalid subject in a screening request
+type subjectError struct {
+	Index   int    `json:"index"`
+	Message string `json:"message"`
+}
+
+func newScreeningJobResponse(job *store.ScreeningJob, prefix string) types.ScreeningJob {
+	resp := types.ScreeningJob{
+		ID:          job.ID,
+		Status:      job.Status,
+		Total:       job.Total,
+		Processed:   job.Processed,
+		Matched:     job.Matched,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+		CompletedAt: job.CompletedAt,
+		StalledAt:   job.StalledAt,
+	}
+	if job.Error != nil && (job.Status == store.ScreeningFailed || job.Status == store.ScreeningStalled) {
+		resp.Error = *job.Error
+	}
+	if job.Status == store.ScreeningCompleted {
+		resp.ResultsURL = fmt.Sprintf("%s/screenings/%d/results", prefix, job.ID)
+	}
+	return resp
+}
+
+// CreateScreening handles submitting a bulk screening job. Subjects are sent
+// either as JSON or as a CSV file with a name,nik,birth_place,birth_date header.
+func (h *ScreeningHandler) CreateScreening(w http.ResponseWriter, r *http.Request) {
+	var (
+		subjects []*store.ScreeningSubject
+		err      error
+	)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		subjects, err = decodeSubjectsCSV(r.Body)
+	} else {
+		subjects, err = decodeSubjectsJSON(r.Body)
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error decoding screening request", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", err.Error())
+		return
+	}
+
+	if len(subjects) == 0 {
+		apierror.Validation(w, r, "At least one subject is required", nil)
+		return
+	}
+	if len(subjects) > h.processor.MaxSubjects() {
+		apierror.Validation(w, r, fmt.Sprintf("A screening job may contain at most %d subjects", h.processor.MaxSubjects()), nil)
+		return
+	}
+	if errs := h.validateSubjects(subjects); len(errs) > 0 {
+		apierror.Validation(w, r, "Invalid subjects", errs)
+		return
+	}
+
+	var submittedBy string
+	if identity := auth.FromContext(r.Context()); identity != nil {
+		submittedBy = identity.Subject
+	}
+
+	job, err := h.processor.Submit(r.Context(), submittedBy, subjects)
+	if err != nil {
+		logger(r, h.log).Error("Error submitting screening job", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("%s/screenings/%d", apiPrefix(r), job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newScreeningJobResponse(job, apiPrefix(r)))
+}
+
+// GetScreening handles retrieving the status of a screening job
+func (h *ScreeningHandler) GetScreening(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newScreeningJobResponse(job, apiPrefix(r)))
+}
+
+// GetScreeningResults handles downloading the results of a completed job as CSV
+func (h *ScreeningHandler) GetScreeningResults(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != store.ScreeningCompleted {
+		apierror.Conflict(w, r, "Screening job is not completed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="screening-%d.csv"`, job.ID))
+
+	// Dates and NIKs follow the caller's response format, if it has one
+	f := format.FromContext(r.Context())
+	locale := reason.Negotiate(r.Header.Get("Accept-Language"))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"seq", "name", "nik", "birth_place", "birth_date", "blacklisted", "match_type", "details", "outcome"})
+	err := h.processor.Results(r.Context(), job.ID, func(s *store.ScreeningSubject) error {
+		row := []string{strconv.Itoa(s.Seq), s.Name, s.NIK, s.BirthPlace, "", "", "", "", ""}
+		if f != nil {
+			row[2] = f.MaskID(s.NIK)
+		}
+		if s.BirthDate != nil {
+			if f != nil {
+				row[4] = f.FormatDate(*s.BirthDate, locale)
+			} else {
+				row[4] = s.BirthDate.Format("2006-01-02")
+			}
+		}
+		if s.Blacklisted != nil {
+			row[5] = strconv.FormatBool(*s.Blacklisted)
+		}
+		if s.MatchType != nil {
+			row[6] = *s.MatchType
+		}
+		if s.Details != nil {
+			row[7] = *s.Details
+		}
+		// blacklisted is false for subjects left unknown too; outcome tells them apart
+		if s.Blacklisted != nil && s.MatchType != nil {
+			row[8] = service.OutcomeOf(*s.Blacklisted, *s.MatchType)
+		}
+		return cw.Write(row)
+	})
+	cw.Flush()
+	if err != nil {
+		// Headers are already sent, so the truncated download is all we can signal
+		logger(r, h.log).Error("Error streaming screening results", zap.Int64("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// RequeueScreening handles putting a stalled screening job back in the queue
+func (h *ScreeningHandler) RequeueScreening(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid screening job ID", nil)
+		return
+	}
+
+	job, err := h.processor.Requeue(r.Context(), id)
+	switch {
+	case errors.Is(err, screening.ErrJobNotFound):
+		apierror.NotFound(w, r, "Screening job not found")
+		return
+	case errors.Is(err, screening.ErrJobNotStalled):
+		apierror.Conflict(w, r, "Screening job is not stalled")
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error requeueing screening job", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	recordActivity(r, h.activity, h.log, store.ActivityScreening, "requeue", strconv.FormatInt(id, 10), nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newScreeningJobResponse(job, apiPrefix(r)))
+}
+
+// job loads the job named in the URL, writing the error response if it can't
+func (h *ScreeningHandler) job(w http.ResponseWriter, r *http.Request) (*store.ScreeningJob, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid screening job ID", nil)
+		return nil, false
+	}
+
+	job, err := h.processor.Get(r.Context(), id)
+	if errors.Is(err, screening.ErrJobNotFound) {
+		apierror.NotFound(w, r, "Screening job not found")
+		return nil, false
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error getting screening job", zap.Error(err))
+		apierror.Internal(w, r)
+		return nil, false
+	}
+	return job, true
+}
+
+func decodeSubjectsJSON(body io.Reader) ([]*store.ScreeningSubject, error) {
+	var req types.ScreeningRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return nil, err
+	}
+
+	subjects := make([]*store.ScreeningSubject, 0, len(req.Subjects))
+	for _, s := range req.Subjects {
+		subject := &store.ScreeningSubject{Name: s.Name, BirthDate: s.BirthDate}
+		if s.NIK != nil {
+			subject.NIK = *s.NIK
+		}
+		if s.BirthPlace != nil {
+			subject.BirthPlace = *s.BirthPlace
+		}
+		subjects = append(subjects, subject)
+	}
+	return subjects, nil
+}
+
+func decodeSubjectsCSV(body io.Reader) ([]*store.ScreeningSubject, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("CSV header must include a name column")
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var subjects []*store.ScreeningSubject
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		subject := &store.ScreeningSubject{
+			Name:       field(row, "name"),
+			NIK:        field(row, "nik"),
+			BirthPlace: field(row, "birth_place"),
+		}
+		if v := field(row, "birth_date"); v != "" {
+			birthDate, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: birth_date must be formatted as YYYY-MM-DD", line)
+			}
+			subject.BirthDate = &birthDate
+		}
+		subjects = append(subjects, subject)
+	}
+	return subjects, nil
+}
+
+// validateSubjects applies the single-check validation rules to every subject
+func (h *ScreeningHandler) validateSubjects(subjects []*store.ScreeningSubject) []subjectError {
+	var errs []subjectError
+	for i, s := range subjects {
+		req := service.CheckRequest{NIK: s.NIK}
+		if s.BirthDate != nil {
+			req.BirthDate = *s.BirthDate
+		}
+		if len(s.Name) < 3 {
+			errs = append(errs, subjectError{Index: i, Message: "Name must be at least 3 characters long"})
+		} else if _, err := h.service.CheckID(req); err != nil {
+			errs = append(errs, subjectError{Index: i, Message: err.Error()})
+		}
+		if len(errs) == maxSubjectErrors {
+			break
+		}
+	}
+	return errs
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// Simulate handles decision simulation requests. The check is evaluated under
+// the current and a proposed match policy side by side; nothing is cached and
+// no check metrics are recorded.
+func (h *Handler) Simulate(w http.ResponseWriter, r *http.Request) {
+	var req types.SimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+
+	check, err := newServiceCheckRequest(req.Check)
+	if err != nil {
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	current := h.service.Policy()
+	proposed := applyProposed(current, req.Proposed)
+
+	sim, err := h.service.Simulate(r.Context(), check, proposed)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPolicy) {
+			apierror.Validation(w, r, err.Error(), nil)
+			return
+		}
+		logger(r, h.log).Error("Error simulating blacklist check", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	locale := reason.Negotiate(r.Header.Get("Accept-Language"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", locale)
+	json.NewEncoder(w).Encode(types.SimulationResponse{
+		Current:        checkResponse(sim.Current, locale),
+		Proposed:       checkResponse(sim.Proposed, locale),
+		CurrentPolicy:  types.MatchPolicy(current),
+		ProposedPolicy: types.MatchPolicy(proposed),
+		Changed:        sim.Changed,
+	})
+}
+
+// DryRunCheck handles dry-run check requests. The check is evaluated under
+// parameters given for this request only, and every candidate record is
+// rated. Like diagnostics it exposes records that didn't match, so it needs
+// the diagnostics role; nothing is cached, counted or kept as a decision.
+func (h *Handler) DryRunCheck(w http.ResponseWriter, r *http.Request) {
+	identity := auth.FromContext(r.Context())
+	if identity == nil || !identity.HasRole(diagnosticsRole) {
+		apierror.Forbidden(w, r)
+		return
+	}
+
+	var req types.DryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+
+	check, err := newServiceCheckRequest(req.Check)
+	if err != nil {
+		apierror.Invalid(w, r, err)
+		return
+	}
+	policy := applyProposed(h.service.Policy(), req.Parameters)
+
+	run, err := h.service.DryRun(r.Context(), check, policy)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPolicy) {
+			apierror.Validation(w, r, err.Error(), nil)
+			return
+		}
+		logger(r, h.log).Error("Error running dry-run check", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+	logger(r, h.log).Info("Dry-run check requested",
+		zap.String("subject", identity.Subject),
+		zap.Int("candidates", len(run.Candidates)))
+
+	locale := reason.Negotiate(r.Header.Get("Accept-Language"))
+	response := types.DryRunResponse{
+		Result:     checkResponse(run.Result, locale),
+		Policy:     types.MatchPolicy(policy),
+		Candidates: []types.Candidate{},
+	}
+	for _, c := range run.Candidates {
+		candidate := types.Candidate{
+			List:       c.List,
+			NIK:        c.Record.NIK,
+			Name:       c.Record.Name,
+			BirthPlace: c.Record.BirthPlace,
+			BirthDate:  c.Record.BirthDate,
+			Similarity: c.Similarity,
+			Score:      c.Score,
+			Decision:   c.Decision,
+			Matched:    c.Matched,
+			Excluded:   c.Excluded,
+		}
+		for _, f := range c.Factors {
+			candidate.Factors = append(candidate.Factors, types.ScoreFactor(f))
+		}
+		response.Candidates = append(response.Candidates, candidate)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", locale)
+	json.NewEncoder(w).Encode(response)
+}
+
+// applyProposed overrides the parts of policy the proposal sets
+func applyProposed(policy service.MatchPolicy, proposed types.ProposedPolicy) service.MatchPolicy {
+	if proposed.MinSimilarity != nil {
+		policy.MinSimilarity = *proposed.MinSimilarity
+	}
+	if proposed.Rules != nil {
+		policy.Rules = proposed.Rules
+	}
+	if proposed.NameOnlySimilarity != nil {
+		policy.NameOnlySimilarity = *proposed.NameOnlySimilarity
+	}
+	if proposed.MissingBirthDatePenalty != nil {
+		policy.MissingBirthDatePenalty = *proposed.MissingBirthDatePenalty
+	}
+	if proposed.MissingBirthPlacePenalty != nil {
+		policy.MissingBirthPlacePenalty = *proposed.MissingBirthPlacePenalty
+	}
+	if proposed.BirthDateToleranceDays != nil {
+		policy.BirthDateToleranceDays = *proposed.BirthDateToleranceDays
+	}
+	if proposed.CandidateBudget != nil {
+		policy.CandidateBudget = *proposed.CandidateBudget
+	}
+	if proposed.RerankCandidates != nil {
+		policy.RerankCandidates = *proposed.RerankCandidates
+	}
+	if proposed.RerankTrigramWeight != nil {
+		policy.RerankTrigramWeight = *proposed.RerankTrigramWeight
+	}
+	if proposed.RerankJaroWinklerWeight != nil {
+		policy.RerankJaroWinklerWeight = *proposed.RerankJaroWinklerWeight
+	}
+	return policy
+}
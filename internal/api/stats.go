@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"blacklist-check/internal/buildinfo"
+	"blacklist-check/internal/cache"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// statsTimeout bounds the Redis lookups made for the stats, counting cached
+// keys above all, so a large cache can't hold up the request for long
+const statsTimeout = 5 * time.Second
+
+// StatsHandler reports what a running instance is doing, for on-call debugging
+type StatsHandler struct {
+	db             *sqlx.DB
+	redis          redis.UniversalClient
+	service        service.Service
+	blacklistStore store.BlacklistStore
+	started        time.Time
+	log            *zap.Logger
+}
+
+// NewStatsHandler creates a new stats handler. Uptime is counted from when it is created.
+func NewStatsHandler(db *sqlx.DB, redis redis.UniversalClient, service service.Service, blacklistStore store.BlacklistStore, log *zap.Logger) *StatsHandler {
+	return &StatsHandler{
+		db:             db,
+		redis:          redis,
+		service:        service,
+		blacklistStore: blacklistStore,
+		started:        time.Now(),
+		log:            log,
+	}
+}
+
+// statsResponse is a snapshot of the instance answering the request
+type statsResponse struct {
+	Build         buildinfo.Info `json:"build"`
+	StartedAt     time.Time      `json:"started_at"`
+	UptimeSeconds float64        `json:"uptime_seconds"`
+	Runtime       runtimeStats   `json:"runtime"`
+	Database      databaseStats  `json:"database"`
+	Redis         redisStats     `json:"redis"`
+	Cache         cacheStats     `json:"cache"`
+	// Errors lists the stats that couldn't be read; the rest are still reported
+	Errors []string `json:"errors,omitempty"`
+}
+
+// runtimeStats are the Go runtime's view of the process
+type runtimeStats struct {
+	Goroutines          int        `json:"goroutines"`
+	CPUs                int        `json:"cpus"`
+	GOMAXPROCS          int        `json:"gomaxprocs"`
+	HeapAllocBytes      uint64     `json:"heap_alloc_bytes"`
+	HeapInuseBytes      uint64     `json:"heap_inuse_bytes"`
+	HeapObjects         uint64     `json:"heap_objects"`
+	SysBytes            uint64     `json:"sys_bytes"`
+	GCCycles            uint32     `json:"gc_cycles"`
+	GCPauseTotalSeconds float64    `json:"gc_pause_total_seconds"`
+	LastGCAt            *time.Time `json:"last_gc_at,omitempty"`
+}
+
+// databaseStats are the Postgres connection pool's statistics
+type databaseStats struct {
+	MaxOpenConnections int     `json:"max_open_connections"`
+	OpenConnections    int     `json:"open_connections"`
+	InUse              int     `json:"in_use"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"wait_count"`
+	WaitSeconds        float64 `json:"wait_seconds"`
+	MaxIdleClosed      int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64   `json:"max_lifetime_closed"`
+}
+
+// redisStats are the Redis connection pool's statistics
+type redisStats struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+}
+
+// cacheStats counts cached lookups
+type cacheStats struct {
+	// RedisKeys is every key in the Redis database, cached lookups or not
+	RedisKeys int64 `json:"redis_keys"`
+	// Namespaces counts the cached lookups in Redis by namespace; the counts
+	// come from SCAN and are approximate while keys are written or expire
+	Namespaces map[string]int64 `json:"namespaces"`
+	// ListVersion is the current name namespace version
+	ListVersion int64 `json:"list_version"`
+	// LocalNIKEntries is the size of the in-process NIK lookup cache, absent
+	// when it is disabled
+	LocalNIKEntries *int `json:"local_nik_entries,omitempty"`
+}
+
+// Stats handles reporting build, runtime, pool and cache stats of this instance
+func (h *StatsHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), statsTimeout)
+	defer cancel()
+
+	now := time.Now()
+	resp := statsResponse{
+		Build:         buildinfo.Get(),
+		StartedAt:     h.started,
+		UptimeSeconds: now.Sub(h.started).Seconds(),
+		Runtime:       readRuntimeStats(),
+		Database:      readDatabaseStats(h.db),
+		Redis:         readRedisStats(h.redis),
+	}
+	resp.Cache, resp.Errors = h.cacheStats(ctx)
+	for _, problem := range resp.Errors {
+		logger(r, h.log).Warn("Error collecting stats", zap.String("error", problem))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// readRuntimeStats reads the Go runtime's stats. ReadMemStats stops the
+// world briefly, which is fine for an admin request.
+func readRuntimeStats() runtimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := runtimeStats{
+		Goroutines:          runtime.NumGoroutine(),
+		CPUs:                runtime.NumCPU(),
+		GOMAXPROCS:          runtime.GOMAXPROCS(0),
+		HeapAllocBytes:      mem.HeapAlloc,
+		HeapInuseBytes:      mem.HeapInuse,
+		HeapObjects:         mem.HeapObjects,
+		SysBytes:            mem.Sys,
+		GCCycles:            mem.NumGC,
+		GCPauseTotalSeconds: time.Duration(mem.PauseTotalNs).Seconds(),
+	}
+	if mem.LastGC > 0 {
+		last := time.Unix(0, int64(mem.LastGC))
+		stats.LastGCAt = &last
+	}
+	return stats
+}
+
+// readDatabaseStats reads the Postgres pool's stats
+func readDatabaseStats(db *sqlx.DB) databaseStats {
+	pool := db.Stats()
+	return databaseStats{
+		MaxOpenConnections: pool.MaxOpenConnections,
+		OpenConnections:    pool.OpenConnections,
+		InUse:              pool.InUse,
+		Idle:               pool.Idle,
+		WaitCount:          pool.WaitCount,
+		WaitSeconds:        pool.WaitDuration.Seconds(),
+		MaxIdleClosed:      pool.MaxIdleClosed,
+		MaxIdleTimeClosed:  pool.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  pool.MaxLifetimeClosed,
+	}
+}
+
+// readRedisStats reads the Redis pool's stats
+func readRedisStats(client redis.UniversalClient) redisStats {
+	pool := client.PoolStats()
+	return redisStats{
+		Hits:       pool.Hits,
+		Misses:     pool.Misses,
+		Timeouts:   pool.Timeouts,
+		TotalConns: pool.TotalConns,
+		IdleConns:  pool.IdleConns,
+		StaleConns: pool.StaleConns,
+	}
+}
+
+// cacheStats counts the cached lookups in Redis and in process. Counts that
+// can't be read are noted in the returned errors.
+func (h *StatsHandler) cacheStats(ctx context.Context) (cacheStats, []string) {
+	var errs []string
+	stats := cacheStats{
+		ListVersion: h.service.ListVersion(ctx),
+		Namespaces:  make(map[string]int64),
+	}
+	if cached, ok := h.blacklistStore.(*store.CachedBlacklistStore); ok {
+		entries := cached.Len()
+		stats.LocalNIKEntries = &entries
+	}
+
+	keys, err := h.redis.DBSize(ctx).Result()
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("error reading Redis key count: %v", err))
+	}
+	stats.RedisKeys = keys
+
+	for _, ns := range service.CacheNamespaces(stats.ListVersion) {
+		count, err := countKeys(ctx, h.redis, ns.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("error counting %s keys: %v", ns.Name, err))
+		}
+		stats.Namespaces[ns.Name] = count
+	}
+	return stats, errs
+}
+
+// countKeys counts the keys matching pattern. On error it returns the count
+// so far, which undercounts.
+func countKeys(ctx context.Context, client redis.UniversalClient, pattern string) (int64, error) {
+	var count int64
+	err := cache.ScanKeys(ctx, client, pattern, 1000, func(keys []string) error {
+		count += int64(len(keys))
+		return nil
+	})
+	return count, err
+}
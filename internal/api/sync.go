@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/listsync"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// SyncHandler handles list sync administration requests
+type SyncHandler struct {
+	syncer    *listsync.Syncer
+	connector *listsync.Connector
+	log       *zap.Logger
+}
+
+// NewSyncHandler creates a new sync handler
+func NewSyncHandler(syncer *listsync.Syncer, connector *listsync.Connector, log *zap.Logger) *SyncHandler {
+	return &SyncHandler{
+		syncer:    syncer,
+		connector: connector,
+		log:       log,
+	}
+}
+
+// SyncStatus handles reporting the latest run of every external source
+func (h *SyncHandler) SyncStatus(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.connector.Statuses(r.Context())
+	if err != nil {
+		logger(r, h.log).Error("Error listing sync status", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// decisionRequest represents the request body for a quarantine decision
+type decisionRequest struct {
+	DecidedBy string `json:"decided_by"`
+}
+
+// ListQuarantined handles listing change sets awaiting approval
+func (h *SyncHandler) ListQuarantined(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.syncer.ListQuarantined(r.Context())
+	if err != nil {
+		logger(r, h.log).Error("Error listing quarantined change sets", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// ApproveQuarantined handles approving a quarantined change set
+func (h *SyncHandler) ApproveQuarantined(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.syncer.Approve)
+}
+
+// RejectQuarantined handles rejecting a quarantined change set
+func (h *SyncHandler) RejectQuarantined(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.syncer.Reject)
+}
+
+func (h *SyncHandler) decide(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, id int64, decidedBy string) error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid quarantine ID", nil)
+		return
+	}
+
+	var req decisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+	// Authenticated callers decide as themselves and can't name someone else
+	if identity := auth.FromContext(r.Context()); identity != nil {
+		if req.DecidedBy != "" && req.DecidedBy != identity.Subject {
+			logger(r, h.log).Warn("Rejected quarantine decision on behalf of another user",
+				zap.String("subject", identity.Subject),
+				zap.String("decided_by", req.DecidedBy))
+			apierror.Forbidden(w, r)
+			return
+		}
+		req.DecidedBy = identity.Subject
+	}
+	if req.DecidedBy == "" {
+		apierror.Validation(w, r, "decided_by is required", nil)
+		return
+	}
+
+	err = decide(r.Context(), id, req.DecidedBy)
+	switch {
+	case errors.Is(err, listsync.ErrQuarantineNotFound):
+		apierror.NotFound(w, r, "Quarantine entry not found")
+		return
+	case errors.Is(err, listsync.ErrQuarantineDecided):
+		apierror.Conflict(w, r, "Quarantine entry already decided")
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error deciding quarantined change set", zap.Int64("quarantine_id", id), zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
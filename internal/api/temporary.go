@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// CreateTemporaryRecord handles adding a record that expires after a number
+// of days unless it is confirmed
+func (h *Handler) CreateTemporaryRecord(w http.ResponseWriter, r *http.Request) {
+	var req types.TemporaryRecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+	record, err := newRecord(req.RecordRequest)
+	if err != nil {
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	if h.approvals != nil {
+		h.propose(w, r, &store.Proposal{Action: store.ChangeCreateTemporary, List: record.List, NIK: record.NIK, Days: req.Days}, record)
+		return
+	}
+	err = h.service.CreateTemporaryRecord(actorContext(r), record, req.Days)
+	if errors.Is(err, service.ErrTemporaryDuration) {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	if errors.Is(err, store.ErrRecordExists) {
+		apierror.Conflict(w, r, "Record already exists")
+		return
+	}
+	if errors.Is(err, store.ErrSharedList) {
+		apierror.Forbidden(w, r)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error creating temporary record", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newRecordResponse(record))
+}
+
+// ConfirmRecord handles making a temporary record permanent
+func (h *Handler) ConfirmRecord(w http.ResponseWriter, r *http.Request) {
+	list, err := listParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+
+	if h.approvals != nil {
+		h.propose(w, r, &store.Proposal{Action: store.ChangeConfirm, List: list, NIK: chi.URLParam(r, "nik")}, nil)
+		return
+	}
+	record, err := h.service.ConfirmRecord(actorContext(r), list, chi.URLParam(r, "nik"))
+	if errors.Is(err, store.ErrRecordNotFound) {
+		apierror.NotFound(w, r, "Temporary record not found")
+		return
+	}
+	if errors.Is(err, store.ErrSharedList) {
+		apierror.Forbidden(w, r)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error confirming record", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRecordResponse(record))
+}
+
+// ExtendRecord handles moving the expiry of a temporary record
+func (h *Handler) ExtendRecord(w http.ResponseWriter, r *http.Request) {
+	list, err := listParam(r)
+	if err != nil {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	var req types.ExtendRecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+
+	if h.approvals != nil {
+		h.propose(w, r, &store.Proposal{Action: store.ChangeExtend, List: list, NIK: chi.URLParam(r, "nik"), Days: req.Days}, nil)
+		return
+	}
+	record, err := h.service.ExtendRecord(actorContext(r), list, chi.URLParam(r, "nik"), req.Days)
+	if errors.Is(err, service.ErrTemporaryDuration) {
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	}
+	if errors.Is(err, store.ErrRecordNotFound) {
+		apierror.NotFound(w, r, "Temporary record not found")
+		return
+	}
+	if errors.Is(err, store.ErrSharedList) {
+		apierror.Forbidden(w, r)
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error extending record", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRecordResponse(record))
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/service"
+	"blacklist-check/pkg/config"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Path prefixes of the API versions. v2 serves every public v1 route and
+// differs only in the shape of check results.
+const (
+	PrefixV1 = "/api/v1"
+	PrefixV2 = "/api/v2"
+)
+
+// v1DeprecatedAt is when v2 was released, deprecating v1
+var v1DeprecatedAt = time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+
+// CheckBlacklistV2 handles v2 blacklist check requests, which take the v1
+// request body
+func (h *Handler) CheckBlacklistV2(w http.ResponseWriter, r *http.Request) {
+	h.checkBody(w, r, renderV2)
+}
+
+// CheckBlacklistQueryV2 handles v2 blacklist checks sent as query parameters
+func (h *Handler) CheckBlacklistQueryV2(w http.ResponseWriter, r *http.Request) {
+	h.checkQuery(w, r, renderV2)
+}
+
+// renderV2 renders a check result in the v2 shape
+func renderV2(result *service.CheckResult, locale string) interface{} {
+	return checkResponseV2(result, locale)
+}
+
+// checkResponseV2 renders a check result for v2 of the API in locale
+func checkResponseV2(result *service.CheckResult, locale string) types.CheckResponseV2 {
+	response := types.CheckResponseV2{
+		Outcome:       result.Outcome(),
+		UnknownReason: result.UnknownReason,
+		Blacklisted:   result.Blacklisted,
+		Score:         types.ScoreV2{Value: result.Score, Decision: result.Decision},
+		NationalID:    nationalIDResponse(result.ID),
+		Sandbox:       result.Sandbox,
+		Degraded:      result.Degraded,
+		ReviewCaseID:  result.CaseID,
+	}
+	for i := range result.Lists {
+		r := &result.Lists[i]
+		listResult := types.ListResultV2{
+			List:          r.List,
+			Outcome:       r.Outcome(),
+			UnknownReason: r.UnknownReason,
+			MatchType:     r.MatchType,
+			SuppressedBy:  r.SuppressedBy,
+			Score:         types.ScoreV2{Value: r.Score, Decision: r.Decision},
+			Truncated:     r.Truncated,
+		}
+		if r.Matched {
+			listResult.Match = matchResponseV2(r, locale)
+			// The check is decided on the first match on a blocking list
+			if response.Match == nil && lists.Blocking(r.List) {
+				response.Match = listResult.Match
+			}
+		} else {
+			listResult.Details = r.Describe(locale)
+		}
+		for _, f := range r.Factors {
+			listResult.Score.Factors = append(listResult.Score.Factors, types.ScoreFactor(f))
+		}
+		for _, miss := range r.NearMisses {
+			listResult.NearMisses = append(listResult.NearMisses, types.NearMiss{
+				NIK:        miss.Record.NIK,
+				Name:       miss.Record.Name,
+				BirthPlace: miss.Record.BirthPlace,
+				BirthDate:  miss.Record.BirthDate,
+				Similarity: miss.Similarity,
+				Excluded:   miss.Excluded,
+			})
+		}
+		response.Results = append(response.Results, listResult)
+	}
+	for _, warning := range result.Warnings {
+		response.Warnings = append(response.Warnings, types.Warning(warning))
+	}
+	return response
+}
+
+// matchResponseV2 renders the match on a list for v2 of the API in locale
+func matchResponseV2(r *service.ListResult, locale string) *types.MatchV2 {
+	return &types.MatchV2{
+		List:         r.List,
+		MatchType:    r.MatchType,
+		ReasonCode:   r.ReasonCode,
+		Details:      r.Describe(locale),
+		Confidence:   r.Confidence,
+		MatchedAlias: r.MatchedAlias,
+		Explanation:  explanationResponse(r.Explanation),
+	}
+}
+
+// apiPrefix returns the prefix of the API version r was sent to
+func apiPrefix(r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, PrefixV2+"/") {
+		return PrefixV2
+	}
+	return PrefixV1
+}
+
+// Deprecation marks the responses of deprecated routes with a Deprecation
+// header (RFC 9745), a Sunset header (RFC 8594) once a sunset is announced,
+// and a Link to the same route in the version succeeding them
+type Deprecation struct {
+	// sunset is when v1 stops being served; zero until announced
+	sunset time.Time
+}
+
+// NewDeprecation creates the deprecation of v1, sunsetting on API_V1_SUNSET
+func NewDeprecation(cfg *config.Config) *Deprecation {
+	// API_V1_SUNSET is validated at startup
+	sunset, _ := time.Parse("2006-01-02", cfg.Server.APIV1Sunset)
+	return &Deprecation{sunset: sunset}
+}
+
+// Middleware marks the responses of v1 routes deprecated in favour of v2,
+// counting the requests by route and caller so the callers still to migrate
+// can be found
+func (d *Deprecation) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(v1DeprecatedAt.Unix(), 10))
+		if !d.sunset.IsZero() {
+			w.Header().Set("Sunset", d.sunset.Format(http.TimeFormat))
+		}
+		successor := PrefixV2 + strings.TrimPrefix(r.URL.Path, PrefixV1)
+		w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+
+		subject := "anonymous"
+		if identity := auth.FromContext(r.Context()); identity != nil {
+			subject = identity.Subject
+		}
+		endpoint := r.URL.Path
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			endpoint = rctx.RoutePattern()
+		}
+		metrics.DeprecatedRequestsTotal.WithLabelValues(endpoint, subject).Inc()
+		next.ServeHTTP(w, r)
+	})
+}
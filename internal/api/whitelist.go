@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/nationalid"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// whitelistRequest represents the request body for clearing a false positive.
+// The subject is identified by nik, or by name and birth_date without one.
+type whitelistRequest struct {
+	RecordID  int64      `json:"record_id"`
+	NIK       string     `json:"nik,omitempty"`
+	IDCountry string     `json:"id_country,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	BirthDate *time.Time `json:"birth_date,omitempty"`
+	Reason    string     `json:"reason"`
+	// Duration such as "720h"; defaults to WHITELIST_DEFAULT_TTL
+	Duration string `json:"duration,omitempty"`
+}
+
+// CreateWhitelistEntry handles clearing a subject of matches on a record
+func (h *Handler) CreateWhitelistEntry(w http.ResponseWriter, r *http.Request) {
+	var req whitelistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger(r, h.log).Error("Error decoding request body", zap.Error(err))
+		apierror.Validation(w, r, "Invalid request body", nil)
+		return
+	}
+	if req.RecordID <= 0 {
+		apierror.Validation(w, r, "record_id is required", nil)
+		return
+	}
+	if req.Reason == "" {
+		apierror.Validation(w, r, "reason is required", nil)
+		return
+	}
+	if req.NIK != "" {
+		id, err := nationalid.Parse(req.IDCountry, req.NIK)
+		if err != nil {
+			apierror.Validation(w, r, err.Error(), nil)
+			return
+		}
+		req.NIK = id.Number
+	} else if len(req.Name) < 3 {
+		apierror.Validation(w, r, "Either nik or a name of at least 3 characters is required", nil)
+		return
+	}
+
+	var ttl time.Duration
+	if req.Duration != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.Duration); err != nil {
+			apierror.Validation(w, r, "duration must be a duration such as 720h", nil)
+			return
+		}
+	}
+
+	entry := &store.WhitelistEntry{
+		RecordID:  req.RecordID,
+		NIK:       req.NIK,
+		Name:      req.Name,
+		BirthDate: req.BirthDate,
+		Reason:    req.Reason,
+	}
+	err := h.service.CreateWhitelistEntry(actorContext(r), entry, ttl)
+	switch {
+	case errors.Is(err, service.ErrWhitelistUnavailable):
+		apierror.NotFound(w, r, "The whitelist is not available")
+		return
+	case errors.Is(err, service.ErrWhitelistDuration):
+		apierror.Validation(w, r, err.Error(), nil)
+		return
+	case errors.Is(err, store.ErrRecordNotFound):
+		apierror.Validation(w, r, "record_id doesn't refer to a blacklist record", nil)
+		return
+	case err != nil:
+		logger(r, h.log).Error("Error creating whitelist entry", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// ListWhitelist handles listing whitelist entries; ?inactive=true includes
+// expired and revoked ones
+func (h *Handler) ListWhitelist(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.service.ListWhitelist(r.Context(), r.URL.Query().Get("inactive") == "true")
+	if errors.Is(err, service.ErrWhitelistUnavailable) {
+		apierror.NotFound(w, r, "The whitelist is not available")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error listing whitelist", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// RevokeWhitelistEntry handles ending a whitelist entry early
+func (h *Handler) RevokeWhitelistEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Validation(w, r, "Invalid whitelist entry ID", nil)
+		return
+	}
+
+	entry, err := h.service.RevokeWhitelistEntry(actorContext(r), id)
+	if errors.Is(err, service.ErrWhitelistUnavailable) {
+		apierror.NotFound(w, r, "The whitelist is not available")
+		return
+	}
+	if errors.Is(err, store.ErrWhitelistEntryNotFound) {
+		apierror.NotFound(w, r, "Active whitelist entry not found")
+		return
+	}
+	if err != nil {
+		logger(r, h.log).Error("Error revoking whitelist entry", zap.Error(err))
+		apierror.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
@@ -0,0 +1,88 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/validate"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Write sends an error envelope with the given status, code and message
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(types.ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
+
+// Validation sends a 400 validation error
+func Validation(w http.ResponseWriter, r *http.Request, message string, details interface{}) {
+	Write(w, r, http.StatusBadRequest, types.ErrCodeValidation, message, details)
+}
+
+// Invalid sends a 400 validation error for err, listing the failing fields
+// in details when err is a validate.Errors
+func Invalid(w http.ResponseWriter, r *http.Request, err error) {
+	var fields validate.Errors
+	if errors.As(err, &fields) {
+		Validation(w, r, err.Error(), fields)
+		return
+	}
+	Validation(w, r, err.Error(), nil)
+}
+
+// Unauthorized sends a 401 error
+func Unauthorized(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusUnauthorized, types.ErrCodeUnauthorized, "Authentication required", nil)
+}
+
+// Forbidden sends a 403 error
+func Forbidden(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusForbidden, types.ErrCodeForbidden, "Insufficient permissions", nil)
+}
+
+// NotFound sends a 404 error
+func NotFound(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, http.StatusNotFound, types.ErrCodeNotFound, message, nil)
+}
+
+// MethodNotAllowed sends a 405 error
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusMethodNotAllowed, types.ErrCodeMethodNotAllowed, "Method not allowed", nil)
+}
+
+// Conflict sends a 409 error
+func Conflict(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, http.StatusConflict, types.ErrCodeConflict, message, nil)
+}
+
+// RateLimited sends a 429 error
+func RateLimited(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusTooManyRequests, types.ErrCodeRateLimited, "Rate limit exceeded", nil)
+}
+
+// Internal sends a 500 error without leaking the cause
+func Internal(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusInternalServerError, types.ErrCodeInternal, "Internal server error", nil)
+}
+
+// DependencyTimeout sends a 504 naming the dependency that didn't answer in time
+func DependencyTimeout(w http.ResponseWriter, r *http.Request, dependency string) {
+	Write(w, r, http.StatusGatewayTimeout, types.ErrCodeDependencyTimeout,
+		fmt.Sprintf("Timed out waiting for %s", dependency), map[string]string{"dependency": dependency})
+}
+
+// DependencyUnavailable sends a 503 naming the dependency that is down
+func DependencyUnavailable(w http.ResponseWriter, r *http.Request, dependency string) {
+	Write(w, r, http.StatusServiceUnavailable, types.ErrCodeDependencyUnavailable,
+		fmt.Sprintf("%s is unavailable", dependency), map[string]string{"dependency": dependency})
+}
@@ -0,0 +1,181 @@
+// Package approval holds record changes made through the admin API, and
+// merges of duplicate records, for four-eyes approval. Each change becomes a
+// proposal, which a second user
+// holding the approver role approves, applying it to the live records, or
+// rejects. Proposals and decisions are recorded in the activity feed.
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"blacklist-check/internal/dedup"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// ErrSelfApproval is returned when the proposer of a change approves it
+var ErrSelfApproval = errors.New("proposal can't be approved by its proposer")
+
+// Merger merges duplicate pairs, as dedup.Deduplicator does
+type Merger interface {
+	Merge(ctx context.Context, id, keep int64) (*store.DuplicatePair, error)
+}
+
+// Workflow proposes record changes and applies those approved
+type Workflow struct {
+	store   store.ProposalStore
+	service service.Service
+	// merger applies approved merges; nil when duplicate detection is disabled
+	merger Merger
+	role   string
+	log    *zap.Logger
+}
+
+// NewWorkflow creates the approval workflow, or returns nil when
+// APPROVAL_ENABLED is off and record changes apply straight away
+func NewWorkflow(cfg *config.Config, proposals store.ProposalStore, service service.Service, duplicates *dedup.Deduplicator, log *zap.Logger) *Workflow {
+	if !cfg.Approval.Enabled {
+		return nil
+	}
+	w := &Workflow{
+		store:   proposals,
+		service: service,
+		role:    cfg.Approval.Role,
+		log:     log,
+	}
+	if duplicates != nil {
+		w.merger = duplicates
+	}
+	return w
+}
+
+// Role returns the role required to decide proposals
+func (w *Workflow) Role() string {
+	return w.role
+}
+
+// Propose holds a change, with the proposed record of creates and updates,
+// as a pending proposal of the actor in ctx
+func (w *Workflow) Propose(ctx context.Context, proposal *store.Proposal, record *store.BlacklistRecord) error {
+	if err := w.store.Create(ctx, proposal, record); err != nil {
+		return fmt.Errorf("error creating proposal: %w", err)
+	}
+	w.log.Info("Record change proposed",
+		zap.Int64("proposal_id", proposal.ID),
+		zap.String("action", proposal.Action),
+		zap.String("list", proposal.List),
+		zap.String("proposed_by", proposal.ProposedBy))
+	return nil
+}
+
+// Get returns a proposal, or store.ErrProposalNotFound
+func (w *Workflow) Get(ctx context.Context, id int64) (*store.Proposal, error) {
+	return w.store.Get(ctx, id)
+}
+
+// List returns the proposals with status, oldest first
+func (w *Workflow) List(ctx context.Context, status string) ([]*store.Proposal, error) {
+	return w.store.List(ctx, status)
+}
+
+// Approve applies a pending proposal as the actor in ctx, who must not be
+// its proposer, and records the approval. The proposal is claimed and the
+// change made in one transaction, so concurrent approvals apply it once. A
+// change that fails to apply, such as the creation of a record that exists
+// by now, leaves the proposal pending to be rejected.
+func (w *Workflow) Approve(ctx context.Context, id int64, comment string) (*store.Proposal, error) {
+	proposal, err := w.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != store.ProposalPending {
+		return nil, store.ErrProposalDecided
+	}
+	if store.Actor(ctx) == proposal.ProposedBy {
+		return nil, ErrSelfApproval
+	}
+
+	proposal, err = w.store.Approve(ctx, id, comment, func(ctx context.Context, proposal *store.Proposal) error {
+		return w.apply(ctx, proposal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The change was cached away before it committed, so a check in between
+	// may have cached the old record again
+	if err := w.service.InvalidateRecords(ctx, proposal.NIK); err != nil {
+		w.log.Error("Error invalidating cache after approval", zap.Int64("proposal_id", id), zap.Error(err))
+	}
+
+	w.log.Info("Record change approved",
+		zap.Int64("proposal_id", id),
+		zap.String("action", proposal.Action),
+		zap.String("list", proposal.List),
+		zap.String("approver", store.Actor(ctx)))
+	return proposal, nil
+}
+
+// Reject discards a pending proposal as the actor in ctx, who may be its
+// proposer withdrawing it
+func (w *Workflow) Reject(ctx context.Context, id int64, comment string) (*store.Proposal, error) {
+	proposal, err := w.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != store.ProposalPending {
+		return nil, store.ErrProposalDecided
+	}
+	proposal, err = w.store.Decide(ctx, id, store.ProposalRejected, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	w.log.Info("Record change rejected",
+		zap.Int64("proposal_id", id),
+		zap.String("action", proposal.Action),
+		zap.String("list", proposal.List),
+		zap.String("rejected_by", store.Actor(ctx)))
+	return proposal, nil
+}
+
+// apply makes the proposed change to the live records, attributed to the
+// actor in ctx
+func (w *Workflow) apply(ctx context.Context, proposal *store.Proposal) error {
+	record, err := proposal.Decode()
+	if err != nil {
+		return err
+	}
+	if record == nil && (proposal.Action == store.ChangeCreate || proposal.Action == store.ChangeUpdate || proposal.Action == store.ChangeCreateTemporary) {
+		return fmt.Errorf("proposal %d has no record to %s", proposal.ID, proposal.Action)
+	}
+
+	switch proposal.Action {
+	case store.ChangeCreate:
+		return w.service.CreateRecord(ctx, record)
+	case store.ChangeUpdate:
+		return w.service.UpdateRecord(ctx, record)
+	case store.ChangeDelete:
+		return w.service.DeleteRecord(ctx, proposal.List, proposal.NIK)
+	case store.ChangeRestore:
+		_, err = w.service.RestoreRecord(ctx, proposal.List, proposal.NIK)
+	case store.ChangeCreateTemporary:
+		return w.service.CreateTemporaryRecord(ctx, record, proposal.Days)
+	case store.ChangeConfirm:
+		_, err = w.service.ConfirmRecord(ctx, proposal.List, proposal.NIK)
+	case store.ChangeExtend:
+		_, err = w.service.ExtendRecord(ctx, proposal.List, proposal.NIK, proposal.Days)
+	case store.ChangeMerge:
+		if w.merger == nil {
+			return fmt.Errorf("proposal %d merges duplicates, which are not enabled", proposal.ID)
+		}
+		_, err = w.merger.Merge(ctx, proposal.DuplicateID, proposal.KeepID)
+	default:
+		err = fmt.Errorf("proposal %d has unknown action %q", proposal.ID, proposal.Action)
+	}
+	return err
+}
@@ -0,0 +1,213 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/testutil"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// proposalStore holds one proposal, claimed under a lock like the row lock
+// the conditional update takes, and put back pending when apply fails like a
+// rolled back transaction
+type proposalStore struct {
+	store.ProposalStore
+	mu       sync.Mutex
+	proposal store.Proposal
+}
+
+func (s *proposalStore) Get(ctx context.Context, id int64) (*store.Proposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id != s.proposal.ID {
+		return nil, store.ErrProposalNotFound
+	}
+	proposal := s.proposal
+	return &proposal, nil
+}
+
+func (s *proposalStore) Approve(ctx context.Context, id int64, comment string, apply func(ctx context.Context, proposal *store.Proposal) error) (*store.Proposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.proposal.Status != store.ProposalPending {
+		return nil, store.ErrProposalDecided
+	}
+	decided := s.proposal
+	decided.Status = store.ProposalApproved
+	decided.Comment = comment
+	if err := apply(ctx, &decided); err != nil {
+		return nil, err
+	}
+	s.proposal = decided
+	return &decided, nil
+}
+
+func newWorkflow(proposals store.ProposalStore, svc *testutil.Service) *Workflow {
+	cfg := &config.Config{}
+	cfg.Approval.Enabled = true
+	cfg.Approval.Role = "approver"
+	svc.InvalidateRecordsFunc = func(ctx context.Context, niks ...string) error { return nil }
+	return NewWorkflow(cfg, proposals, svc, nil, zap.NewNop())
+}
+
+func pendingDelete() store.Proposal {
+	return store.Proposal{
+		ID:         1,
+		Action:     store.ChangeDelete,
+		List:       "internal",
+		NIK:        "3171234567890123",
+		Status:     store.ProposalPending,
+		ProposedBy: "alice",
+	}
+}
+
+func TestApproveAppliesOnceUnderConcurrentApprovals(t *testing.T) {
+	proposals := &proposalStore{proposal: pendingDelete()}
+	var applied atomic.Int32
+	w := newWorkflow(proposals, &testutil.Service{
+		DeleteRecordFunc: func(ctx context.Context, list, nik string) error {
+			applied.Add(1)
+			return nil
+		},
+	})
+
+	const approvers = 8
+	var (
+		wg       sync.WaitGroup
+		approved atomic.Int32
+		errs     = make(chan error, approvers)
+	)
+	ctx := store.WithActor(context.Background(), "bob")
+	for i := 0; i < approvers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := w.Approve(ctx, 1, ""); err != nil {
+				errs <- err
+				return
+			}
+			approved.Add(1)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	if got := applied.Load(); got != 1 {
+		t.Errorf("change applied %d times, want once", got)
+	}
+	if got := approved.Load(); got != 1 {
+		t.Errorf("%d approvals succeeded, want 1", got)
+	}
+	for err := range errs {
+		if !errors.Is(err, store.ErrProposalDecided) {
+			t.Errorf("Approve() error = %v, want %v", err, store.ErrProposalDecided)
+		}
+	}
+}
+
+func TestApprove(t *testing.T) {
+	errApply := errors.New("record exists")
+	tests := []struct {
+		name       string
+		approver   string
+		status     string
+		applyErr   error
+		wantErr    error
+		wantStatus string
+	}{
+		{
+			name:       "approved",
+			approver:   "bob",
+			status:     store.ProposalPending,
+			wantStatus: store.ProposalApproved,
+		},
+		{
+			name:       "by its proposer",
+			approver:   "alice",
+			status:     store.ProposalPending,
+			wantErr:    ErrSelfApproval,
+			wantStatus: store.ProposalPending,
+		},
+		{
+			name:       "already decided",
+			approver:   "bob",
+			status:     store.ProposalRejected,
+			wantErr:    store.ErrProposalDecided,
+			wantStatus: store.ProposalRejected,
+		},
+		{
+			name:       "change fails to apply",
+			approver:   "bob",
+			status:     store.ProposalPending,
+			applyErr:   errApply,
+			wantErr:    errApply,
+			wantStatus: store.ProposalPending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposal := pendingDelete()
+			proposal.Status = tt.status
+			proposals := &proposalStore{proposal: proposal}
+			w := newWorkflow(proposals, &testutil.Service{
+				DeleteRecordFunc: func(ctx context.Context, list, nik string) error { return tt.applyErr },
+			})
+
+			_, err := w.Approve(store.WithActor(context.Background(), tt.approver), 1, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Approve() error = %v, want %v", err, tt.wantErr)
+			}
+			if proposals.proposal.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", proposals.proposal.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// mergerFunc merges duplicate pairs with a function
+type mergerFunc func(ctx context.Context, id, keep int64) (*store.DuplicatePair, error)
+
+func (f mergerFunc) Merge(ctx context.Context, id, keep int64) (*store.DuplicatePair, error) {
+	return f(ctx, id, keep)
+}
+
+func TestApproveMerge(t *testing.T) {
+	proposal := pendingDelete()
+	proposal.Action, proposal.DuplicateID, proposal.KeepID = store.ChangeMerge, 6, 3
+
+	t.Run("merges the pair", func(t *testing.T) {
+		proposals := &proposalStore{proposal: proposal}
+		w := newWorkflow(proposals, &testutil.Service{})
+		var merged [2]int64
+		w.merger = mergerFunc(func(ctx context.Context, id, keep int64) (*store.DuplicatePair, error) {
+			merged = [2]int64{id, keep}
+			return &store.DuplicatePair{ID: id}, nil
+		})
+
+		if _, err := w.Approve(store.WithActor(context.Background(), "bob"), 1, ""); err != nil {
+			t.Fatalf("Approve() error = %v", err)
+		}
+		if merged != [2]int64{6, 3} {
+			t.Errorf("merged pair %d keeping %d, want pair 6 keeping 3", merged[0], merged[1])
+		}
+	})
+
+	t.Run("without duplicate detection", func(t *testing.T) {
+		proposals := &proposalStore{proposal: proposal}
+		w := newWorkflow(proposals, &testutil.Service{})
+
+		if _, err := w.Approve(store.WithActor(context.Background(), "bob"), 1, ""); err == nil {
+			t.Fatal("Approve() succeeded, want an error")
+		}
+		if proposals.proposal.Status != store.ProposalPending {
+			t.Errorf("status = %q, want %q", proposals.proposal.Status, store.ProposalPending)
+		}
+	})
+}
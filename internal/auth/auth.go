@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidCredentials is returned when credentials are present but not valid
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Identity describes an authenticated caller
+type Identity struct {
+	Subject string
+	Method  string
+	Tenant  string
+	Roles   []string
+}
+
+// HasRole reports whether the identity holds the given role, directly or
+// through a role that implies it
+func (i *Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if implies(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator resolves the caller identity for a single auth method.
+// It returns (nil, nil) when the request carries no credentials for that method.
+type Authenticator interface {
+	Method() string
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity stored in ctx, or nil for anonymous requests
+func FromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(contextKey{}).(*Identity)
+	return identity
+}
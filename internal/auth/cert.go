@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"sync/atomic"
+)
+
+// MethodMTLS authenticates callers by their verified client certificate
+const MethodMTLS = "mtls"
+
+// Certificate fields a mapping can match on
+const (
+	CertFieldCommonName = "subject_cn"
+	CertFieldSubject    = "subject_dn"
+	CertFieldDNSName    = "san_dns"
+	CertFieldURI        = "san_uri"
+	CertFieldEmail      = "san_email"
+)
+
+// CertMapping maps a certificate field value to an identity
+type CertMapping struct {
+	Field   string
+	Value   string
+	Subject string
+	Tenant  string
+	Roles   []string
+}
+
+// CertMappingLoader loads the current set of certificate mappings
+type CertMappingLoader func(ctx context.Context) ([]CertMapping, error)
+
+// CertAuthenticator maps verified client certificates to identities
+type CertAuthenticator struct {
+	load     CertMappingLoader
+	mappings atomic.Pointer[map[string]CertMapping]
+}
+
+// NewCertAuthenticator creates a certificate authenticator backed by load
+func NewCertAuthenticator(load CertMappingLoader) *CertAuthenticator {
+	a := &CertAuthenticator{load: load}
+	empty := map[string]CertMapping{}
+	a.mappings.Store(&empty)
+	return a
+}
+
+// Method returns the auth method name
+func (a *CertAuthenticator) Method() string {
+	return MethodMTLS
+}
+
+// Refresh reloads the mappings, swapping them in atomically
+func (a *CertAuthenticator) Refresh(ctx context.Context) error {
+	mappings, err := a.load(ctx)
+	if err != nil {
+		return err
+	}
+	index := make(map[string]CertMapping, len(mappings))
+	for _, m := range mappings {
+		index[mappingKey(m.Field, m.Value)] = m
+	}
+	a.mappings.Store(&index)
+	return nil
+}
+
+// Authenticate resolves the identity for the request's verified client
+// certificate. A certificate without a mapping carries no credentials for
+// this method, so the route's other methods still get to authenticate the
+// request.
+func (a *CertAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	// Only chains verified against the client CA count; PeerCertificates alone
+	// may hold an unverified certificate when client auth is optional
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+
+	mappings := *a.mappings.Load()
+	for _, candidate := range certCandidates(cert) {
+		if m, ok := mappings[candidate]; ok {
+			return &Identity{
+				Subject: m.Subject,
+				Method:  MethodMTLS,
+				Tenant:  m.Tenant,
+				Roles:   m.Roles,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// certCandidates lists the lookup keys for a certificate, most specific first
+func certCandidates(cert *x509.Certificate) []string {
+	var keys []string
+	for _, uri := range cert.URIs {
+		keys = append(keys, mappingKey(CertFieldURI, uri.String()))
+	}
+	for _, name := range cert.DNSNames {
+		keys = append(keys, mappingKey(CertFieldDNSName, name))
+	}
+	for _, email := range cert.EmailAddresses {
+		keys = append(keys, mappingKey(CertFieldEmail, email))
+	}
+	keys = append(keys,
+		mappingKey(CertFieldSubject, cert.Subject.String()),
+		mappingKey(CertFieldCommonName, cert.Subject.CommonName))
+	return keys
+}
+
+func mappingKey(field, value string) string {
+	return field + "\x00" + value
+}
+
+// ValidCertField reports whether field is a supported mapping field
+func ValidCertField(field string) bool {
+	switch field {
+	case CertFieldCommonName, CertFieldSubject, CertFieldDNSName, CertFieldURI, CertFieldEmail:
+		return true
+	}
+	return false
+}
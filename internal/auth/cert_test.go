@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCertAuthenticator(t *testing.T) {
+	a := NewCertAuthenticator(func(ctx context.Context) ([]CertMapping, error) {
+		return []CertMapping{
+			{Field: CertFieldURI, Value: "spiffe://dc1/screening", Subject: "screening", Roles: []string{RoleChecker}},
+			{Field: CertFieldCommonName, Value: "ops", Subject: "ops", Roles: []string{RoleAdmin}},
+		}, nil
+	})
+	if err := a.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spiffe, _ := url.Parse("spiffe://dc1/screening")
+	tests := []struct {
+		name        string
+		cert        *x509.Certificate
+		verified    bool
+		wantSubject string
+	}{
+		{"mapped URI", &x509.Certificate{URIs: []*url.URL{spiffe}, Subject: pkix.Name{CommonName: "ops"}}, true, "screening"},
+		{"mapped common name", &x509.Certificate{Subject: pkix.Name{CommonName: "ops"}}, true, "ops"},
+		{"unverified", &x509.Certificate{Subject: pkix.Name{CommonName: "ops"}}, false, ""},
+		// Not an error, so the next authenticator gets to run
+		{"unmapped", &x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}}, true, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.cert}}
+		if tt.verified {
+			r.TLS.VerifiedChains = [][]*x509.Certificate{{tt.cert}}
+		}
+		identity, err := a.Authenticate(r)
+		if err != nil {
+			t.Errorf("%s: Authenticate() error = %v", tt.name, err)
+			continue
+		}
+		var subject string
+		if identity != nil {
+			subject = identity.Subject
+		}
+		if subject != tt.wantSubject {
+			t.Errorf("%s: subject = %q, want %q", tt.name, subject, tt.wantSubject)
+		}
+	}
+}
+
+func TestUnmappedCertFallsBackToOtherMethods(t *testing.T) {
+	certs := NewCertAuthenticator(func(ctx context.Context) ([]CertMapping, error) { return nil, nil })
+	keys := stubAuthenticator{method: "api_key", identities: map[string]*Identity{
+		"checker": {Subject: "checker", Roles: []string{RoleChecker}},
+	}}
+	p, err := NewPolicy("/api/v1=mtls|api_key@checker", zap.NewNop(), certs, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}}
+	r := httptest.NewRequest("POST", "/api/v1/check", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	r.Header.Set("X-Test-Key", "checker")
+	identity, status := p.Authorize(r)
+	if status != 0 || identity == nil || identity.Subject != "checker" {
+		t.Errorf("Authorize() = %+v, %d, want the API key's identity", identity, status)
+	}
+}
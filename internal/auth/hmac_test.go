@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/clock"
+)
+
+// signedRequest builds a request signed with secret at ts, signing
+// signedURI, or the request's own URI when it is empty
+func signedRequest(t *testing.T, method, target, body, secret, nonce, signedURI string, ts time.Time) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if signedURI == "" {
+		signedURI = r.RequestURI
+	}
+	bodyHash := sha256.Sum256([]byte(body))
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", timestamp, method, signedURI, hex.EncodeToString(bodyHash[:]))
+	if nonce != "" {
+		fmt.Fprintf(mac, "\n%s", nonce)
+		r.Header.Set(hmacNonceHeader, nonce)
+	}
+	r.Header.Set(hmacKeyIDHeader, "partner")
+	r.Header.Set(hmacTimestampHeader, timestamp)
+	r.Header.Set(hmacSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	const secret = "s3cret"
+	now := time.Now()
+	tests := []struct {
+		name      string
+		request   func() *http.Request
+		wantValid bool
+	}{
+		{
+			name: "signed body",
+			request: func() *http.Request {
+				return signedRequest(t, "POST", "/api/v1/blacklist/check", `{"name":"John Doe"}`, secret, "", "", now)
+			},
+			wantValid: true,
+		},
+		{
+			name: "signed query",
+			request: func() *http.Request {
+				return signedRequest(t, "GET", "/api/v1/blacklist/check?name=John%20Doe", "", secret, "", "", now)
+			},
+			wantValid: true,
+		},
+		{
+			name: "query signed with other escaping",
+			request: func() *http.Request {
+				return signedRequest(t, "GET", "/api/v1/blacklist/check?name=John%20Doe", "", secret, "", "/api/v1/blacklist/check?name=John+Doe", now)
+			},
+		},
+		{
+			name: "path only signed",
+			request: func() *http.Request {
+				return signedRequest(t, "GET", "/api/v1/blacklist/check?name=John", "", secret, "", "/api/v1/blacklist/check", now)
+			},
+		},
+		{
+			name: "wrong secret",
+			request: func() *http.Request {
+				return signedRequest(t, "POST", "/api/v1/blacklist/check", "{}", "guess", "", "", now)
+			},
+		},
+		{
+			name: "stale timestamp",
+			request: func() *http.Request {
+				return signedRequest(t, "POST", "/api/v1/blacklist/check", "{}", secret, "", "", now.Add(-time.Hour))
+			},
+		},
+		{
+			name: "tampered nonce",
+			request: func() *http.Request {
+				r := signedRequest(t, "POST", "/api/v1/blacklist/check", "{}", secret, "n1", "", now)
+				r.Header.Set(hmacNonceHeader, "n2")
+				return r
+			},
+		},
+		{
+			name: "body over the limit",
+			request: func() *http.Request {
+				return signedRequest(t, "POST", "/api/v1/blacklist/check", strings.Repeat("x", 65), secret, "", "", now)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewHMACAuthenticator("partner:"+secret+":checker", clock.Window{Tolerance: time.Minute}, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := tt.request()
+			identity, err := a.Authenticate(r)
+			if valid := err == nil && identity != nil; valid != tt.wantValid {
+				t.Fatalf("Authenticate() = %v, %v, want valid %t", identity, err, tt.wantValid)
+			}
+			if tt.wantValid && r.Body != nil {
+				// The body is put back for the handler
+				if _, err := io.ReadAll(r.Body); err != nil {
+					t.Errorf("reading body after Authenticate: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHMACAuthenticatorRejectsReplays(t *testing.T) {
+	const secret = "s3cret"
+	a, err := NewHMACAuthenticator("partner:"+secret+":checker", clock.Window{Tolerance: time.Minute}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	send := func(nonce string) error {
+		_, err := a.Authenticate(signedRequest(t, "POST", "/api/v1/blacklist/check", "{}", secret, nonce, "", now))
+		return err
+	}
+	if err := send(""); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	if err := send(""); err == nil {
+		t.Error("replayed request was accepted")
+	}
+	// A nonce makes an otherwise identical request a new one
+	if err := send("n1"); err != nil {
+		t.Errorf("request with a nonce: %v", err)
+	}
+	if err := send("n1"); err == nil {
+		t.Error("replayed request with a nonce was accepted")
+	}
+}
+
+func TestReplayCacheForgetsExpiredSignatures(t *testing.T) {
+	c := &replayCache{seen: make(map[string]time.Time)}
+	now := time.Now()
+	if c.replayed("sig", now.Add(time.Second), now) {
+		t.Fatal("first use reported as a replay")
+	}
+	if !c.replayed("sig", now.Add(time.Second), now) {
+		t.Error("second use within the window not reported as a replay")
+	}
+	later := now.Add(2 * time.Minute)
+	if c.replayed("other", later.Add(time.Second), later); len(c.seen) != 1 {
+		t.Errorf("cache holds %d signatures after the sweep, want 1", len(c.seen))
+	}
+}
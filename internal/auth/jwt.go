@@ -0,0 +1,422 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"blacklist-check/internal/clock"
+)
+
+// MethodJWT authenticates callers by an OIDC bearer token
+const MethodJWT = "jwt"
+
+// jwksTimeout bounds each key set fetch
+const jwksTimeout = 10 * time.Second
+
+// jwksMinRefresh rate-limits the refetches triggered by unknown key IDs, so
+// tokens with made-up key IDs can't hammer the identity provider
+const jwksMinRefresh = 30 * time.Second
+
+// JWTOptions configures a JWT authenticator
+type JWTOptions struct {
+	JWKSURL  string
+	Issuer   string
+	Audience string
+	// SubjectClaim, RolesClaim and TenantClaim name the claims mapped to the
+	// identity. Nested claims are addressed with dots, e.g. realm_access.roles.
+	SubjectClaim string
+	RolesClaim   string
+	TenantClaim  string
+}
+
+// jwtAlgorithm is a supported signing algorithm
+type jwtAlgorithm struct {
+	hash crypto.Hash
+	// size is the byte length of each of r and s in an ECDSA signature
+	size int
+}
+
+var jwtAlgorithms = map[string]jwtAlgorithm{
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"ES256": {hash: crypto.SHA256, size: 32},
+	"ES384": {hash: crypto.SHA384, size: 48},
+	"ES512": {hash: crypto.SHA512, size: 66},
+}
+
+// curveAlgorithms is the one ECDSA algorithm each curve signs with
+var curveAlgorithms = map[string]string{
+	"P-256": "ES256",
+	"P-384": "ES384",
+	"P-521": "ES512",
+}
+
+// signingKey is a published key with the algorithms it may verify
+type signingKey struct {
+	key crypto.PublicKey
+	// alg is the key's own alg parameter; empty allows any algorithm of the
+	// key's type
+	alg string
+}
+
+// allows reports whether the key may verify a token signed with name. The
+// token header is attacker-controlled, so it must agree with the key set.
+func (k signingKey) allows(name string) bool {
+	if k.alg != "" && k.alg != name {
+		return false
+	}
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(name, "RS")
+	case *ecdsa.PublicKey:
+		return curveAlgorithms[key.Curve.Params().Name] == name
+	default:
+		return false
+	}
+}
+
+// JWTAuthenticator validates bearer tokens signed by a key of the configured
+// JWKS endpoint. The issuer and audience must match and the token must be
+// current within the clock window. Only asymmetric algorithms are accepted.
+type JWTAuthenticator struct {
+	opts   JWTOptions
+	window clock.Window
+	client *http.Client
+	keys   atomic.Pointer[map[string]signingKey]
+
+	mu          sync.Mutex
+	lastRefresh time.Time
+}
+
+// NewJWTAuthenticator creates a JWT authenticator. Keys are fetched by Refresh.
+func NewJWTAuthenticator(opts JWTOptions, window clock.Window) (*JWTAuthenticator, error) {
+	if opts.JWKSURL == "" || opts.Issuer == "" || opts.Audience == "" {
+		return nil, fmt.Errorf("JWT authentication requires a JWKS URL, issuer and audience")
+	}
+	if opts.SubjectClaim == "" {
+		opts.SubjectClaim = "sub"
+	}
+	a := &JWTAuthenticator{
+		opts:   opts,
+		window: window,
+		client: &http.Client{Timeout: jwksTimeout},
+	}
+	empty := map[string]signingKey{}
+	a.keys.Store(&empty)
+	return a, nil
+}
+
+// Method returns the auth method name
+func (a *JWTAuthenticator) Method() string {
+	return MethodJWT
+}
+
+// Refresh refetches the key set, swapping it in atomically. Keys the provider
+// has rotated out stop validating once it no longer publishes them.
+func (a *JWTAuthenticator) Refresh(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.refresh(ctx)
+}
+
+func (a *JWTAuthenticator) refresh(ctx context.Context) error {
+	a.lastRefresh = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.opts.JWKSURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating JWKS request: %w", err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching JWKS: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("error decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]signingKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// One malformed or unsupported key shouldn't take down the others
+			continue
+		}
+		keys[k.Kid] = signingKey{key: key, alg: k.Alg}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS at %s has no usable signing keys", a.opts.JWKSURL)
+	}
+	a.keys.Store(&keys)
+	return nil
+}
+
+// key returns the signing key for kid, refetching the key set once when the
+// provider may have rotated in a key we haven't seen yet
+func (a *JWTAuthenticator) key(ctx context.Context, kid string) (signingKey, bool) {
+	if key, ok := (*a.keys.Load())[kid]; ok {
+		return key, true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Another request may have refreshed while we waited
+	if key, ok := (*a.keys.Load())[kid]; ok {
+		return key, true
+	}
+	if time.Since(a.lastRefresh) < jwksMinRefresh {
+		return signingKey{}, false
+	}
+	if err := a.refresh(ctx); err != nil {
+		return signingKey{}, false
+	}
+	key, ok := (*a.keys.Load())[kid]
+	return key, ok
+}
+
+// Authenticate verifies the bearer token in the request and maps its claims
+// to an identity
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, nil
+	}
+
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed token header", ErrInvalidCredentials)
+	}
+	alg, ok := jwtAlgorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidCredentials, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidCredentials)
+	}
+	key, ok := a.key(r.Context(), header.Kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidCredentials, header.Kid)
+	}
+	if !key.allows(header.Alg) {
+		return nil, fmt.Errorf("%w: algorithm %q not allowed for key %q", ErrInvalidCredentials, header.Alg, header.Kid)
+	}
+	if !verifySignature(key.key, alg, parts[0]+"."+parts[1], signature) {
+		return nil, fmt.Errorf("%w: invalid signature", ErrInvalidCredentials)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed token claims", ErrInvalidCredentials)
+	}
+	if err := a.validate(claims, time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claim(claims, a.opts.SubjectClaim).(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidCredentials, a.opts.SubjectClaim)
+	}
+	identity := &Identity{Subject: subject, Method: MethodJWT}
+	if a.opts.TenantClaim != "" {
+		identity.Tenant, _ = claim(claims, a.opts.TenantClaim).(string)
+	}
+	if a.opts.RolesClaim != "" {
+		identity.Roles = stringsClaim(claim(claims, a.opts.RolesClaim))
+	}
+	return identity, nil
+}
+
+// validate checks the registered claims. Expiry and not-before are given the
+// clock window's tolerance, like every other caller-supplied timestamp.
+func (a *JWTAuthenticator) validate(claims map[string]interface{}, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != a.opts.Issuer {
+		return fmt.Errorf("unexpected issuer %q", iss)
+	}
+
+	if !hasAudience(claims["aud"], a.opts.Audience) {
+		return fmt.Errorf("token not issued for audience %q", a.opts.Audience)
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("missing exp claim")
+	}
+	if expiry := time.Unix(int64(exp), 0); now.Sub(expiry) > a.window.Tolerance {
+		return fmt.Errorf("token expired at %s", expiry.UTC().Format(time.RFC3339))
+	}
+	if nbf, ok := claims["nbf"].(float64); ok {
+		if notBefore := time.Unix(int64(nbf), 0); notBefore.Sub(now) > a.window.Tolerance {
+			return fmt.Errorf("token not valid before %s", notBefore.UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// jwk is a JSON Web Key as published by the JWKS endpoint
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA exponent out of range")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("EC point not on curve")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// verifySignature checks signature over signed with key. The caller has
+// already checked the key allows the algorithm.
+func verifySignature(key crypto.PublicKey, alg jwtAlgorithm, signed string, signature []byte) bool {
+	h := alg.hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, alg.hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		if len(signature) != 2*alg.size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:alg.size])
+		s := new(big.Int).SetBytes(signature[alg.size:])
+		return ecdsa.Verify(key, digest, r, s)
+	default:
+		return false
+	}
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// claim looks up a claim by its dotted path
+func claim(claims map[string]interface{}, path string) interface{} {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// hasAudience reports whether the aud claim names audience. Unlike a scope, a
+// string aud is a single value, so it isn't split on spaces.
+func hasAudience(value interface{}, audience string) bool {
+	if aud, ok := value.(string); ok {
+		return aud == audience
+	}
+	for _, aud := range stringsClaim(value) {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// stringsClaim reads a claim holding a string array or a space-separated
+// string, as scope claims are
+func stringsClaim(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
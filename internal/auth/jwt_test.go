@@ -0,0 +1,392 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/clock"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "blacklist-check"
+)
+
+// jwksServer publishes a key set that tests can rotate, counting fetches
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    []map[string]string
+	fetches int
+}
+
+func newJWKSServer(t *testing.T, keys ...map[string]string) *jwksServer {
+	t.Helper()
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) publish(keys ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func rsaJWK(kid, alg string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kid": kid, "kty": "RSA", "use": "sig", "alg": alg,
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kid": kid, "kty": "EC", "use": "sig", "crv": key.Curve.Params().Name,
+		"x": b64(key.X.Bytes()), "y": b64(key.Y.Bytes()),
+	}
+}
+
+// signer signs the token's signing input
+type signer func(t *testing.T, signed []byte) []byte
+
+func signRSA(key *rsa.PrivateKey, hash crypto.Hash) signer {
+	return func(t *testing.T, signed []byte) []byte {
+		h := hash.New()
+		h.Write(signed)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, hash, h.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+}
+
+func signEC(key *ecdsa.PrivateKey, hash crypto.Hash, size int) signer {
+	return func(t *testing.T, signed []byte) []byte {
+		h := hash.New()
+		h.Write(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig
+	}
+}
+
+func signHMAC(secret []byte) signer {
+	return func(t *testing.T, signed []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+func unsigned(t *testing.T, signed []byte) []byte { return nil }
+
+func encodeToken(t *testing.T, alg, kid string, claims map[string]interface{}, sign signer) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64(header) + "." + b64(payload)
+	return signed + "." + b64(sign(t, []byte(signed)))
+}
+
+// validClaims returns claims the test authenticator accepts, with changes
+// applied on top. A nil value removes the claim.
+func validClaims(now time.Time, changes map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss": testIssuer,
+		"aud": testAudience,
+		"sub": "analyst",
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func newTestJWTAuthenticator(t *testing.T, jwksURL string) *JWTAuthenticator {
+	t.Helper()
+	a, err := NewJWTAuthenticator(JWTOptions{
+		JWKSURL:  jwksURL,
+		Issuer:   testIssuer,
+		Audience: testAudience,
+	}, clock.Window{Tolerance: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newJWKSServer(t,
+		rsaJWK("rsa", "", rsaKey),
+		rsaJWK("rsa-rs256", "RS256", rsaKey),
+		ecJWK("ec", p256),
+	)
+	a := newTestJWTAuthenticator(t, server.URL)
+
+	now := time.Now()
+	tests := []struct {
+		name      string
+		token     func() string
+		wantValid bool
+	}{
+		{
+			name: "RS256",
+			token: func() string {
+				return encodeToken(t, "RS256", "rsa", validClaims(now, nil), signRSA(rsaKey, crypto.SHA256))
+			},
+			wantValid: true,
+		},
+		{
+			name: "ES256",
+			token: func() string {
+				return encodeToken(t, "ES256", "ec", validClaims(now, nil), signEC(p256, crypto.SHA256, 32))
+			},
+			wantValid: true,
+		},
+		{
+			name: "bad signature",
+			token: func() string {
+				// The signature of one token on the claims of another
+				signature := encodeToken(t, "RS256", "rsa", validClaims(now, nil), signRSA(rsaKey, crypto.SHA256))
+				forged := encodeToken(t, "RS256", "rsa", validClaims(now, map[string]interface{}{"sub": "admin"}), unsigned)
+				return forged + signature[strings.LastIndex(signature, ".")+1:]
+			},
+		},
+		{
+			name:  "alg none",
+			token: func() string { return encodeToken(t, "none", "rsa", validClaims(now, nil), unsigned) },
+		},
+		{
+			name: "HS256 keyed with the public key",
+			token: func() string {
+				return encodeToken(t, "HS256", "rsa", validClaims(now, nil), signHMAC(rsaKey.N.Bytes()))
+			},
+		},
+		{
+			name: "RS256 against an EC key",
+			token: func() string {
+				return encodeToken(t, "RS256", "ec", validClaims(now, nil), signRSA(rsaKey, crypto.SHA256))
+			},
+		},
+		{
+			name: "ES256 against an RSA key",
+			token: func() string {
+				return encodeToken(t, "ES256", "rsa", validClaims(now, nil), signEC(p256, crypto.SHA256, 32))
+			},
+		},
+		{
+			name: "ES384 against a P-256 key",
+			token: func() string {
+				return encodeToken(t, "ES384", "ec", validClaims(now, nil), signEC(p256, crypto.SHA384, 48))
+			},
+		},
+		{
+			name: "RS512 against a key bound to RS256",
+			token: func() string {
+				return encodeToken(t, "RS512", "rsa-rs256", validClaims(now, nil), signRSA(rsaKey, crypto.SHA512))
+			},
+		},
+		{
+			name: "RS256 against a key bound to RS256",
+			token: func() string {
+				return encodeToken(t, "RS256", "rsa-rs256", validClaims(now, nil), signRSA(rsaKey, crypto.SHA256))
+			},
+			wantValid: true,
+		},
+		{
+			name: "expired within tolerance",
+			token: func() string {
+				claims := validClaims(now, map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()})
+				return encodeToken(t, "RS256", "rsa", claims, signRSA(rsaKey, crypto.SHA256))
+			},
+			wantValid: true,
+		},
+		{
+			name: "expired beyond tolerance",
+			token: func() string {
+				claims := validClaims(now, map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})
+				return encodeToken(t, "RS256", "rsa", claims, signRSA(rsaKey, crypto.SHA256))
+			},
+		},
+		{
+			name: "missing exp",
+			token: func() string {
+				claims := validClaims(now, map[string]interface{}{"exp": nil})
+				return encodeToken(t, "RS256", "rsa", claims, signRSA(rsaKey, crypto.SHA256))
+			},
+		},
+		{
+			name: "not before within tolerance",
+			token: func() string {
+				claims := validClaims(now, map[string]interface{}{"nbf": now.Add(30 * time.Second).Unix()})
+				return encodeToken(t, "RS256", "rsa", claims, signRSA(rsaKey, crypto.SHA256))
+			},
+			wantValid: true,
+		},
+		{
+			name: "not before beyond tolerance",
+			token: func() string {
+				claims := validClaims(now, map[string]interface{}{"nbf": now.Add(2 * time.Minute).Unix()})
+				return encodeToken(t, "RS256", "rsa", claims, signRSA(rsaKey, crypto.SHA256))
+			},
+		},
+		{
+			name: "other issuer",
+			token: func() string {
+				claims := validClaims(now, map[string]interface{}{"iss": "https://evil.example.com"})
+				return encodeToken(t, "RS256", "rsa", claims, signRSA(rsaKey, crypto.SHA256))
+			},
+		},
+		{
+			name: "other audience",
+			token: func() string {
+				claims := validClaims(now, map[string]interface{}{"aud": "payments"})
+				return encodeToken(t, "RS256", "rsa", claims, signRSA(rsaKey, crypto.SHA256))
+			},
+		},
+		{
+			name: "audience with spaces",
+			token: func() string {
+				claims := validClaims(now, map[string]interface{}{"aud": "payments " + testAudience})
+				return encodeToken(t, "RS256", "rsa", claims, signRSA(rsaKey, crypto.SHA256))
+			},
+		},
+		{
+			name: "audience in array",
+			token: func() string {
+				claims := validClaims(now, map[string]interface{}{"aud": []string{"payments", testAudience}})
+				return encodeToken(t, "RS256", "rsa", claims, signRSA(rsaKey, crypto.SHA256))
+			},
+			wantValid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := a.Authenticate(bearer(tt.token()))
+			if tt.wantValid {
+				if err != nil {
+					t.Fatalf("Authenticate() error = %v", err)
+				}
+				if identity == nil || identity.Subject != "analyst" {
+					t.Errorf("Authenticate() = %+v, want subject analyst", identity)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Authenticate() = %+v, %v, want ErrInvalidCredentials", identity, err)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorRefetchesUnknownKey(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newJWKSServer(t, rsaJWK("old", "RS256", oldKey))
+	a := newTestJWTAuthenticator(t, server.URL)
+	now := time.Now()
+
+	// The provider rotates in a new key; within the rate limit a token signed
+	// with it is rejected without refetching
+	server.publish(rsaJWK("old", "RS256", oldKey), rsaJWK("new", "RS256", newKey))
+	token := encodeToken(t, "RS256", "new", validClaims(now, nil), signRSA(newKey, crypto.SHA256))
+	if _, err := a.Authenticate(bearer(token)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate() error = %v, want ErrInvalidCredentials within the rate limit", err)
+	}
+	if got := server.fetchCount(); got != 1 {
+		t.Fatalf("fetches = %d, want 1", got)
+	}
+
+	a.mu.Lock()
+	a.lastRefresh = time.Now().Add(-jwksMinRefresh)
+	a.mu.Unlock()
+	if _, err := a.Authenticate(bearer(token)); err != nil {
+		t.Fatalf("Authenticate() error = %v after the rate limit", err)
+	}
+	if got := server.fetchCount(); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+
+	// A made-up key ID right after doesn't refetch again
+	forged := encodeToken(t, "RS256", "made-up", validClaims(now, nil), signRSA(newKey, crypto.SHA256))
+	if _, err := a.Authenticate(bearer(forged)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate() error = %v, want ErrInvalidCredentials", err)
+	}
+	if got := server.fetchCount(); got != 2 {
+		t.Errorf("fetches = %d, want 2 within the rate limit", got)
+	}
+}
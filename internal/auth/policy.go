@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"blacklist-check/internal/apierror"
+
+	"go.uber.org/zap"
+)
+
+// MethodNone allows unauthenticated access
+const MethodNone = "none"
+
+// Rule is one entry of the route policy table
+type Rule struct {
+	Prefix string
+	// HTTPMethods limits the rule to requests with these methods; empty matches any
+	HTTPMethods []string
+	Methods     []string
+	Roles       []string
+}
+
+// Policy enforces per-route-group authentication requirements from a single table
+type Policy struct {
+	rules          []Rule
+	authenticators map[string]Authenticator
+	log            *zap.Logger
+}
+
+// NewPolicy parses a policy spec of the form
+//
+//	/healthz=none;GET /api/v1/admin=api_key@auditor;/api/v1/admin=api_key@admin;/api/v1=api_key@checker
+//
+// Each entry maps a path prefix to the auth methods it accepts ("|"-separated)
+// and optionally the roles it requires after "@". A prefix may be preceded by
+// the HTTP methods ("," separated) the entry is limited to. The longest
+// matching prefix wins, entries limited to the request's method before those
+// that aren't, and paths matching no entry are rejected. An empty spec is
+// refused, so routes are only open through an explicit "none". Entries under
+// /api/v1 also govern the same paths under /api/v2, unless the spec has an
+// entry of their own for them.
+func NewPolicy(spec string, log *zap.Logger, authenticators ...Authenticator) (*Policy, error) {
+	p := &Policy{
+		authenticators: make(map[string]Authenticator, len(authenticators)),
+		log:            log,
+	}
+	for _, a := range authenticators {
+		p.authenticators[a.Method()] = a
+	}
+
+	if strings.TrimSpace(spec) == "" {
+		return nil, fmt.Errorf("auth policy is empty; use /=%s to leave every route open", MethodNone)
+	}
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		prefix, requirement, ok := strings.Cut(entry, "=")
+		var httpMethods []string
+		if verbs, path, qualified := strings.Cut(strings.TrimSpace(prefix), " "); qualified {
+			prefix = path
+			for _, verb := range strings.Split(verbs, ",") {
+				httpMethods = append(httpMethods, strings.ToUpper(strings.TrimSpace(verb)))
+			}
+		}
+		prefix = strings.TrimSpace(prefix)
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid auth policy entry %q", entry)
+		}
+		methods, roles, _ := strings.Cut(requirement, "@")
+
+		rule := Rule{Prefix: prefix, HTTPMethods: httpMethods}
+		for _, method := range strings.Split(methods, "|") {
+			method = strings.TrimSpace(method)
+			if method != MethodNone && p.authenticators[method] == nil {
+				return nil, fmt.Errorf("auth policy for %s uses method %q which is not configured", rule.Prefix, method)
+			}
+			rule.Methods = append(rule.Methods, method)
+		}
+		if roles != "" {
+			rule.Roles = strings.Split(roles, "|")
+		}
+		p.rules = append(p.rules, rule)
+	}
+
+	// v2 serves the v1 routes, so a table written for v1 keeps covering them
+	declared := make(map[string]bool, len(p.rules))
+	for _, rule := range p.rules {
+		declared[ruleKey(rule.HTTPMethods, rule.Prefix)] = true
+	}
+	for _, rule := range p.rules {
+		if rule.Prefix != "/api/v1" && !strings.HasPrefix(rule.Prefix, "/api/v1/") {
+			continue
+		}
+		rule.Prefix = "/api/v2" + strings.TrimPrefix(rule.Prefix, "/api/v1")
+		if !declared[ruleKey(rule.HTTPMethods, rule.Prefix)] {
+			p.rules = append(p.rules, rule)
+		}
+	}
+
+	// Longest prefix first so the most specific rule wins
+	sort.SliceStable(p.rules, func(i, j int) bool {
+		if len(p.rules[i].Prefix) != len(p.rules[j].Prefix) {
+			return len(p.rules[i].Prefix) > len(p.rules[j].Prefix)
+		}
+		return len(p.rules[i].HTTPMethods) > 0 && len(p.rules[j].HTTPMethods) == 0
+	})
+
+	return p, nil
+}
+
+// WithSpec creates a policy enforcing the table in spec with the same
+// authenticators, for a listener with requirements of its own
+func (p *Policy) WithSpec(spec string) (*Policy, error) {
+	authenticators := make([]Authenticator, 0, len(p.authenticators))
+	for _, a := range p.authenticators {
+		authenticators = append(authenticators, a)
+	}
+	return NewPolicy(spec, p.log, authenticators...)
+}
+
+// Match returns the rule governing a request with the given HTTP method and
+// path, or nil if no rule applies
+func (p *Policy) Match(method, path string) *Rule {
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if len(rule.HTTPMethods) > 0 && !contains(rule.HTTPMethods, method) {
+			continue
+		}
+		if path == rule.Prefix ||
+			strings.HasPrefix(path, strings.TrimSuffix(rule.Prefix, "/")+"/") {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Authorize applies the policy to a request. It returns the resolved identity
+// (nil for anonymous access on open routes) or the HTTP status to reject with.
+func (p *Policy) Authorize(r *http.Request) (*Identity, int) {
+	rule := p.Match(r.Method, r.URL.Path)
+	if rule == nil {
+		return nil, http.StatusUnauthorized
+	}
+
+	identity, err := p.authenticate(r, rule)
+	if err != nil || (identity == nil && !rule.allows(MethodNone)) {
+		p.log.Warn("Authentication failed",
+			zap.String("path", r.URL.Path),
+			zap.Strings("methods", rule.Methods),
+			zap.Error(err))
+		return nil, http.StatusUnauthorized
+	}
+
+	if len(rule.Roles) > 0 && !hasAnyRole(identity, rule.Roles) {
+		p.log.Warn("Authorization failed",
+			zap.String("path", r.URL.Path),
+			zap.String("subject", identity.Subject),
+			zap.Strings("required_roles", rule.Roles))
+		return nil, http.StatusForbidden
+	}
+
+	return identity, 0
+}
+
+// Middleware enforces the policy for every request
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, status := p.Authorize(r)
+		switch status {
+		case 0:
+		case http.StatusForbidden:
+			apierror.Forbidden(w, r)
+			return
+		default:
+			apierror.Unauthorized(w, r)
+			return
+		}
+
+		if identity != nil {
+			r = r.WithContext(WithIdentity(r.Context(), identity))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate tries each method the rule accepts. On open routes credentials
+// are still resolved when present, so callers are attributed in logs, but
+// invalid ones are ignored.
+func (p *Policy) authenticate(r *http.Request, rule *Rule) (*Identity, error) {
+	open := rule.allows(MethodNone)
+	for _, method := range rule.Methods {
+		a := p.authenticators[method]
+		if a == nil {
+			continue
+		}
+		identity, err := a.Authenticate(r)
+		if err != nil {
+			if open {
+				continue
+			}
+			return nil, err
+		}
+		if identity != nil {
+			return identity, nil
+		}
+	}
+	return nil, nil
+}
+
+// ruleKey identifies the requests a rule is declared for
+func ruleKey(httpMethods []string, prefix string) string {
+	return strings.Join(httpMethods, ",") + " " + prefix
+}
+
+func (r *Rule) allows(method string) bool {
+	return contains(r.Methods, method)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyRole(identity *Identity, roles []string) bool {
+	if identity == nil {
+		return false
+	}
+	for _, role := range roles {
+		if identity.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
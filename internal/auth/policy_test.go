@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// stubAuthenticator authenticates requests whose X-Test-Key header names one
+// of its identities, and rejects any other key
+type stubAuthenticator struct {
+	method     string
+	identities map[string]*Identity
+}
+
+func (a stubAuthenticator) Method() string {
+	return a.method
+}
+
+func (a stubAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get("X-Test-Key")
+	if key == "" {
+		return nil, nil
+	}
+	identity, ok := a.identities[key]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return identity, nil
+}
+
+func testPolicy(t *testing.T, spec string) *Policy {
+	t.Helper()
+	a := stubAuthenticator{method: "api_key", identities: map[string]*Identity{
+		"checker": {Subject: "checker", Roles: []string{RoleChecker}},
+		"auditor": {Subject: "auditor", Roles: []string{RoleAuditor}},
+		"admin":   {Subject: "admin", Roles: []string{RoleAdmin}},
+	}}
+	p, err := NewPolicy(spec, zap.NewNop(), a)
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+	return p
+}
+
+func TestNewPolicyErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		" ",
+		"/api=jwt",
+		"api=api_key",
+		"/api",
+	} {
+		if _, err := NewPolicy(spec, zap.NewNop(), stubAuthenticator{method: "api_key"}); err == nil {
+			t.Errorf("NewPolicy(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestPolicyMatch(t *testing.T) {
+	p := testPolicy(t, "/healthz=none;GET /api/v1/admin=api_key@auditor;/api/v1/admin=api_key@admin;/api/v1=api_key@checker")
+	tests := []struct {
+		method, path string
+		want         string
+		wantRoles    []string
+	}{
+		{"GET", "/healthz", "/healthz", nil},
+		{"POST", "/api/v1/check", "/api/v1", []string{RoleChecker}},
+		{"GET", "/api/v1/admin/records", "/api/v1/admin", []string{RoleAuditor}},
+		{"POST", "/api/v1/admin/records", "/api/v1/admin", []string{RoleAdmin}},
+		// v2 inherits the v1 entries
+		{"POST", "/api/v2/admin/records", "/api/v2/admin", []string{RoleAdmin}},
+		// A prefix only matches whole path segments
+		{"GET", "/api/v1admin", "", nil},
+		{"GET", "/metrics", "", nil},
+	}
+	for _, tt := range tests {
+		rule := p.Match(tt.method, tt.path)
+		if tt.want == "" {
+			if rule != nil {
+				t.Errorf("Match(%s %s) = %s, want none", tt.method, tt.path, rule.Prefix)
+			}
+			continue
+		}
+		if rule == nil || rule.Prefix != tt.want || !equal(rule.Roles, tt.wantRoles) {
+			t.Errorf("Match(%s %s) = %+v, want %s@%v", tt.method, tt.path, rule, tt.want, tt.wantRoles)
+		}
+	}
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	p := testPolicy(t, "/healthz=none|api_key;GET /api/v1/admin=api_key@auditor;/api/v1/admin=api_key@admin;/api/v1=api_key@checker")
+	tests := []struct {
+		method, path, key string
+		wantStatus        int
+		wantSubject       string
+	}{
+		{"GET", "/healthz", "", 0, ""},
+		// Open routes ignore bad credentials but attribute good ones
+		{"GET", "/healthz", "bogus", 0, ""},
+		{"GET", "/healthz", "checker", 0, "checker"},
+		{"POST", "/api/v1/check", "", http.StatusUnauthorized, ""},
+		{"POST", "/api/v1/check", "bogus", http.StatusUnauthorized, ""},
+		{"POST", "/api/v1/check", "checker", 0, "checker"},
+		{"POST", "/api/v1/check", "admin", 0, "admin"},
+		{"GET", "/api/v1/admin/records", "checker", http.StatusForbidden, ""},
+		{"GET", "/api/v1/admin/records", "auditor", 0, "auditor"},
+		{"DELETE", "/api/v1/admin/records", "auditor", http.StatusForbidden, ""},
+		{"DELETE", "/api/v1/admin/records", "admin", 0, "admin"},
+		{"GET", "/metrics", "admin", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.key != "" {
+			r.Header.Set("X-Test-Key", tt.key)
+		}
+		identity, status := p.Authorize(r)
+		var subject string
+		if identity != nil {
+			subject = identity.Subject
+		}
+		if status != tt.wantStatus || subject != tt.wantSubject {
+			t.Errorf("Authorize(%s %s as %q) = %q, %d, want %q, %d",
+				tt.method, tt.path, tt.key, subject, status, tt.wantSubject, tt.wantStatus)
+		}
+	}
+}
+
+func TestExplicitNonePolicyIsOpen(t *testing.T) {
+	p := testPolicy(t, "/=none")
+	if _, status := p.Authorize(httptest.NewRequest("DELETE", "/api/v1/admin/records", nil)); status != 0 {
+		t.Errorf("Authorize() status = %d, want 0", status)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package auth
+
+// Roles of the authorization model. Screening clients hold checker, which
+// only allows running checks. Auditors can read the admin API but not change
+// anything, and admins can do both. Other roles, such as breakglass and
+// diagnostics, stand on their own.
+const (
+	RoleChecker = "checker"
+	RoleAuditor = "auditor"
+	RoleAdmin   = "admin"
+)
+
+// impliedRoles lists the roles each role includes
+var impliedRoles = map[string][]string{
+	RoleAdmin: {RoleAuditor, RoleChecker},
+}
+
+// implies reports whether holding role grants want
+func implies(role, want string) bool {
+	if role == want {
+		return true
+	}
+	for _, implied := range impliedRoles[role] {
+		if implies(implied, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+// Package backfill recomputes derived blacklist columns over existing rows,
+// so a new matching feature can be rolled out without hand-written SQL
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/nikcrypt"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/phonetic"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Column is a derived column and the function computing it from a record
+type Column struct {
+	Name    string
+	Compute func(nik, name string) string
+	// Pending is the condition on rows the column is still to be computed
+	// for; empty means those where it is empty
+	Pending string
+}
+
+// pending returns the condition on rows c is still to be computed for
+func (c Column) pending() string {
+	if c.Pending != "" {
+		return c.Pending
+	}
+	return c.Name + " = ''"
+}
+
+// Columns lists every derived column the backfill knows how to compute
+var Columns = []Column{
+	{Name: "name_phonetic", Compute: func(nik, name string) string { return phonetic.Encode(name) }},
+	{Name: "name_normalized", Compute: func(nik, name string) string { return normalize.Name(name) }},
+	{Name: "name_sorted", Compute: func(nik, name string) string { return normalize.TokenSorted(name) }},
+}
+
+// NIKColumns are the encrypted NIK and its keyed hash, computed with keys,
+// and the plaintext NIK, which they clear
+func NIKColumns(keys *nikcrypt.Keys) []Column {
+	return []Column{
+		{Name: "nik_encrypted", Compute: func(nik, name string) string { return keys.Encrypt(nik) }},
+		{Name: "nik_hmac", Compute: func(nik, name string) string { return keys.MAC(nik) }},
+		{Name: "nik", Compute: func(nik, name string) string { return "" }, Pending: "nik <> ''"},
+	}
+}
+
+// Available returns the columns a backfill can compute: every derived
+// column, and the NIK columns when there are keys for them
+func Available(keys *nikcrypt.Keys) []Column {
+	if keys == nil {
+		return Columns
+	}
+	return append(append([]Column{}, Columns...), NIKColumns(keys)...)
+}
+
+// Lookup returns the named columns among available, or an error naming the
+// first unknown one
+func Lookup(available []Column, names []string) ([]Column, error) {
+	var columns []Column
+	for _, name := range names {
+		found := false
+		for _, c := range available {
+			if c.Name == name {
+				columns = append(columns, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown backfill column %q", name)
+		}
+	}
+	return columns, nil
+}
+
+// checkNIKColumns refuses to clear plaintext NIKs without storing them
+// encrypted and keyed in the same run
+func checkNIKColumns(columns []Column) error {
+	names := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		names[c.Name] = true
+	}
+	if names["nik"] && (!names["nik_encrypted"] || !names["nik_hmac"]) {
+		return fmt.Errorf("backfilling nik needs nik_encrypted and nik_hmac too")
+	}
+	return nil
+}
+
+// Options controls batching and throttling of a backfill run
+type Options struct {
+	BatchSize int
+	// Pause is slept between batches to bound the load on the primary
+	Pause time.Duration
+	// Force recomputes every row instead of only rows with empty columns
+	Force bool
+}
+
+// Progress reports how far a run has got
+type Progress struct {
+	Done    int
+	Total   int
+	Elapsed time.Duration
+}
+
+// Backfiller walks the blacklist table in primary key order and rewrites derived columns
+type Backfiller struct {
+	db *sqlx.DB
+	// keys decrypt the NIKs of rows that store them encrypted only; nil
+	// when NIKs are stored in plaintext
+	keys *nikcrypt.Keys
+	log  *zap.Logger
+}
+
+// NewBackfiller creates a new backfiller
+func NewBackfiller(db *sqlx.DB, keys *nikcrypt.Keys, log *zap.Logger) *Backfiller {
+	return &Backfiller{
+		db:   db,
+		keys: keys,
+		log:  log,
+	}
+}
+
+type row struct {
+	ID           int64  `db:"id"`
+	NIK          string `db:"nik"`
+	NIKEncrypted string `db:"nik_encrypted"`
+	Name         string `db:"name"`
+}
+
+// Run backfills columns in batches, reporting progress after each one. It is
+// safe to interrupt and re-run: rows already filled in are skipped unless
+// opts.Force is set.
+func (b *Backfiller) Run(ctx context.Context, columns []Column, opts Options) (*Progress, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns to backfill")
+	}
+	if err := checkNIKColumns(columns); err != nil {
+		return nil, err
+	}
+
+	filter := "TRUE"
+	if !opts.Force {
+		conds := make([]string, len(columns))
+		for i, c := range columns {
+			conds[i] = c.pending()
+		}
+		filter = "(" + strings.Join(conds, " OR ") + ")"
+	}
+
+	progress := &Progress{}
+	if err := b.db.GetContext(ctx, &progress.Total, `SELECT count(*) FROM blacklist WHERE `+filter); err != nil {
+		return nil, fmt.Errorf("error counting rows to backfill: %w", err)
+	}
+	b.log.Info("Starting backfill",
+		zap.Strings("columns", columnNames(columns)),
+		zap.Int("rows", progress.Total),
+		zap.Bool("force", opts.Force))
+
+	start := time.Now()
+	var lastID int64
+	for {
+		var rows []row
+		err := b.db.SelectContext(ctx, &rows, `
+			SELECT id, nik, nik_encrypted, name FROM blacklist
+			WHERE id > $1 AND `+filter+`
+			ORDER BY id
+			LIMIT $2
+		`, lastID, opts.BatchSize)
+		if err != nil {
+			return progress, fmt.Errorf("error loading backfill batch after id %d: %w", lastID, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for i := range rows {
+			if rows[i].NIK, err = b.reveal(rows[i].NIK, rows[i].NIKEncrypted); err != nil {
+				return progress, fmt.Errorf("error decrypting NIK of record %d: %w", rows[i].ID, err)
+			}
+		}
+
+		if err := b.update(ctx, columns, rows); err != nil {
+			return progress, err
+		}
+		lastID = rows[len(rows)-1].ID
+		progress.Done += len(rows)
+		progress.Elapsed = time.Since(start)
+		b.logProgress(progress)
+
+		select {
+		case <-ctx.Done():
+			return progress, ctx.Err()
+		case <-time.After(opts.Pause):
+		}
+	}
+
+	b.log.Info("Backfill completed", zap.Int("rows", progress.Done), zap.Duration("elapsed", time.Since(start)))
+	return progress, nil
+}
+
+// update writes one batch with a single statement joined against unnest'ed arrays
+func (b *Backfiller) update(ctx context.Context, columns []Column, rows []row) error {
+	ids := make([]int64, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+	}
+	args := []interface{}{pq.Array(ids)}
+
+	sets := make([]string, len(columns))
+	params := []string{"$1::bigint[]"}
+	aliases := []string{"id"}
+	for i, c := range columns {
+		values := make([]string, len(rows))
+		for j, r := range rows {
+			values[j] = c.Compute(r.NIK, r.Name)
+		}
+		args = append(args, pq.Array(values))
+
+		sets[i] = fmt.Sprintf("%s = v.%s", c.Name, c.Name)
+		params = append(params, fmt.Sprintf("$%d::text[]", i+2))
+		aliases = append(aliases, c.Name)
+	}
+
+	// Derived columns aren't a data change, so updated_at is left alone
+	query := fmt.Sprintf(`
+		UPDATE blacklist b
+		SET %s
+		FROM unnest(%s) AS v(%s)
+		WHERE b.id = v.id
+	`, strings.Join(sets, ", "), strings.Join(params, ", "), strings.Join(aliases, ", "))
+	if _, err := b.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("error updating backfill batch: %w", err)
+	}
+	return nil
+}
+
+// reveal returns the NIK a row stores, decrypting it when the row keeps it
+// encrypted only
+func (b *Backfiller) reveal(nik, encrypted string) (string, error) {
+	if nik != "" || encrypted == "" {
+		return nik, nil
+	}
+	if b.keys == nil {
+		return "", fmt.Errorf("NIK is stored encrypted but no NIK keys are set")
+	}
+	return b.keys.Decrypt(encrypted)
+}
+
+func (b *Backfiller) logProgress(p *Progress) {
+	fields := []zap.Field{
+		zap.Int("done", p.Done),
+		zap.Int("total", p.Total),
+	}
+	if p.Total > 0 {
+		fields = append(fields, zap.String("percent", fmt.Sprintf("%.1f", float64(p.Done)*100/float64(p.Total))))
+	}
+	if p.Done > 0 && p.Done < p.Total {
+		remaining := time.Duration(float64(p.Elapsed) / float64(p.Done) * float64(p.Total-p.Done))
+		fields = append(fields, zap.Duration("eta", remaining.Round(time.Second)))
+	}
+	b.log.Info("Backfill progress", fields...)
+}
+
+func columnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
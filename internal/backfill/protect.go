@@ -0,0 +1,137 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// nikTable is a table besides blacklist that keeps a NIK
+type nikTable struct {
+	name string
+	// keyed tables are looked up by NIK, so they keep its keyed hash too
+	keyed bool
+	// record tables keep a proposed record, NIK included, in record
+	record bool
+}
+
+// nikTables are protected by Protect
+var nikTables = []nikTable{
+	{name: "blacklist_history", keyed: true},
+	{name: "whitelist", keyed: true},
+	{name: "record_proposals", keyed: true, record: true},
+	{name: "review_cases"},
+}
+
+type nikRow struct {
+	ID              int64   `db:"id"`
+	NIK             string  `db:"nik"`
+	NIKEncrypted    string  `db:"nik_encrypted"`
+	Record          *string `db:"record"`
+	RecordEncrypted string  `db:"record_encrypted"`
+}
+
+// Protect stores the NIKs the other tables keep in plaintext encrypted and
+// keyed under the backfiller's keys instead, as the stores do for rows
+// written with keys. It is safe to interrupt and re-run: protected rows have
+// no plaintext left. opts.Force rewrites the rows protected already too, so
+// that previous keys can be retired.
+func (b *Backfiller) Protect(ctx context.Context, opts Options) error {
+	if b.keys == nil {
+		return fmt.Errorf("protecting NIKs needs NIK keys")
+	}
+	for _, table := range nikTables {
+		pending := "nik <> ''"
+		record := "NULL::text AS record, '' AS record_encrypted"
+		if table.record {
+			pending = "(nik <> '' OR record IS NOT NULL)"
+			record = "record::text AS record, record_encrypted"
+		}
+		if opts.Force {
+			pending = "TRUE"
+		}
+
+		start := time.Now()
+		done := 0
+		var lastID int64
+		for {
+			var rows []nikRow
+			err := b.db.SelectContext(ctx, &rows, `
+				SELECT id, nik, nik_encrypted, `+record+` FROM `+table.name+`
+				WHERE id > $1 AND `+pending+`
+				ORDER BY id
+				LIMIT $2
+			`, lastID, opts.BatchSize)
+			if err != nil {
+				return fmt.Errorf("error loading %s batch after id %d: %w", table.name, lastID, err)
+			}
+			if len(rows) == 0 {
+				break
+			}
+			if err := b.protect(ctx, table, rows); err != nil {
+				return err
+			}
+			lastID = rows[len(rows)-1].ID
+			done += len(rows)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(opts.Pause):
+			}
+		}
+		b.log.Info("Protected NIKs", zap.String("table", table.name), zap.Int("rows", done), zap.Duration("elapsed", time.Since(start)))
+	}
+	return nil
+}
+
+// protect writes one batch of a table with a single statement joined against
+// unnest'ed arrays. A NIK already protected is left as it is.
+func (b *Backfiller) protect(ctx context.Context, table nikTable, rows []nikRow) error {
+	ids := make([]int64, len(rows))
+	encrypted := make([]string, len(rows))
+	macs := make([]string, len(rows))
+	records := make([]string, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+		nik, err := b.reveal(r.NIK, r.NIKEncrypted)
+		if err != nil {
+			return fmt.Errorf("error decrypting NIK of %s %d: %w", table.name, r.ID, err)
+		}
+		if nik != "" {
+			encrypted[i], macs[i] = b.keys.Encrypt(nik), b.keys.MAC(nik)
+		}
+		record := r.Record
+		if record == nil && r.RecordEncrypted != "" {
+			decrypted, err := b.keys.Decrypt(r.RecordEncrypted)
+			if err != nil {
+				return fmt.Errorf("error decrypting record of %s %d: %w", table.name, r.ID, err)
+			}
+			record = &decrypted
+		}
+		if record != nil {
+			records[i] = b.keys.Encrypt(*record)
+		}
+	}
+
+	sets := "nik = '', nik_encrypted = CASE WHEN v.nik_encrypted = '' THEN t.nik_encrypted ELSE v.nik_encrypted END"
+	if table.keyed {
+		sets += ", nik_hmac = CASE WHEN v.nik_hmac = '' THEN t.nik_hmac ELSE v.nik_hmac END"
+	}
+	if table.record {
+		sets += ", record = NULL, record_encrypted = CASE WHEN v.record_encrypted = '' THEN t.record_encrypted ELSE v.record_encrypted END"
+	}
+	_, err := b.db.ExecContext(ctx, `
+		UPDATE `+table.name+` t
+		SET `+sets+`
+		FROM unnest($1::bigint[], $2::text[], $3::text[], $4::text[]) AS v(id, nik_encrypted, nik_hmac, record_encrypted)
+		WHERE t.id = v.id
+	`, pq.Array(ids), pq.Array(encrypted), pq.Array(macs), pq.Array(records))
+	if err != nil {
+		return fmt.Errorf("error protecting %s batch: %w", table.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Package breakglass issues time-boxed emergency admin tokens. Every grant
+// needs a recorded justification, expires on its own, raises an alert when
+// issued or revoked and has each request made with it audited.
+package breakglass
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/alert"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// MinJustification is the shortest justification accepted for a grant
+const MinJustification = 20
+
+var (
+	// ErrJustification is returned when a grant request doesn't explain itself
+	ErrJustification = fmt.Errorf("justification must be at least %d characters", MinJustification)
+	// ErrDuration is returned when a grant request exceeds the maximum duration
+	ErrDuration = errors.New("duration exceeds the maximum allowed")
+	// ErrIneligible is returned when the caller may not request a grant
+	ErrIneligible = errors.New("caller may not request break-glass access")
+)
+
+// Alert events
+const (
+	EventIssued  = "issued"
+	EventRevoked = "revoked"
+)
+
+// Issued is a newly created grant with its token, which is never shown again
+type Issued struct {
+	Grant *store.BreakGlassGrant
+	Token string
+}
+
+// Manager issues, resolves and revokes break-glass grants
+type Manager struct {
+	store store.BreakGlassStore
+	// roles are conferred by a grant; issuerRoles may request one
+	roles       []string
+	issuerRoles []string
+	defaultTTL  time.Duration
+	maxTTL      time.Duration
+	alerts      *alert.Poster
+	log         *zap.Logger
+}
+
+// NewManager creates a new break-glass manager
+func NewManager(cfg *config.Config, store store.BreakGlassStore, log *zap.Logger) *Manager {
+	return &Manager{
+		store:       store,
+		roles:       splitRoles(cfg.BreakGlass.Roles),
+		issuerRoles: splitRoles(cfg.BreakGlass.IssuerRoles),
+		defaultTTL:  cfg.BreakGlass.DefaultTTL,
+		maxTTL:      cfg.BreakGlass.MaxTTL,
+		alerts:      alert.NewPoster(cfg.BreakGlass.AlertWebhook, "break-glass alert", log),
+		log:         log,
+	}
+}
+
+// splitRoles parses a comma-separated list of roles
+func splitRoles(spec string) []string {
+	var roles []string
+	for _, role := range strings.Split(spec, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Issue grants issuer elevated access for ttl, or the default duration when
+// ttl is zero. Only callers holding one of BREAKGLASS_ISSUER_ROLES may
+// request a grant, and nobody may when none is configured.
+func (m *Manager) Issue(ctx context.Context, issuer *auth.Identity, justification string, ttl time.Duration) (*Issued, error) {
+	// A break-glass identity must not extend itself
+	if issuer == nil || issuer.Method == auth.MethodBreakGlass || !m.eligible(issuer) {
+		return nil, ErrIneligible
+	}
+	justification = strings.TrimSpace(justification)
+	if len(justification) < MinJustification {
+		return nil, ErrJustification
+	}
+	if ttl == 0 {
+		ttl = m.defaultTTL
+	}
+	if ttl < 0 || ttl > m.maxTTL {
+		return nil, fmt.Errorf("%w (%s)", ErrDuration, m.maxTTL)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("error generating token: %w", err)
+	}
+	token := "bg_" + hex.EncodeToString(secret)
+
+	grant := &store.BreakGlassGrant{
+		Subject:       issuer.Subject,
+		Justification: justification,
+		TokenHash:     hashToken(token),
+		Roles:         m.roles,
+		ExpiresAt:     time.Now().Add(ttl),
+	}
+	if err := m.store.Create(ctx, grant); err != nil {
+		return nil, fmt.Errorf("error storing grant: %w", err)
+	}
+
+	m.alert(EventIssued, grant, issuer.Subject)
+	return &Issued{Grant: grant, Token: token}, nil
+}
+
+// eligible reports whether issuer holds a role that may request a grant
+func (m *Manager) eligible(issuer *auth.Identity) bool {
+	for _, role := range m.issuerRoles {
+		if issuer.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoke ends a grant before it expires
+func (m *Manager) Revoke(ctx context.Context, id int64, revokedBy string) (*store.BreakGlassGrant, error) {
+	grant, err := m.store.Revoke(ctx, id, revokedBy)
+	if err != nil {
+		return nil, err
+	}
+	m.alert(EventRevoked, grant, revokedBy)
+	return grant, nil
+}
+
+// List returns the grants issued since the given time, newest first
+func (m *Manager) List(ctx context.Context, since time.Time) ([]*store.BreakGlassGrant, error) {
+	return m.store.List(ctx, since)
+}
+
+// Uses returns the audit trail of a grant
+func (m *Manager) Uses(ctx context.Context, id int64) ([]*store.BreakGlassUse, error) {
+	return m.store.Uses(ctx, id)
+}
+
+// Lookup resolves a token to the identity its grant confers and records the
+// request in the grant's audit trail. A request that can't be audited is
+// refused. It implements auth.BreakGlassLookup.
+func (m *Manager) Lookup(r *http.Request, token string) (*auth.Identity, error) {
+	grant, err := m.store.Active(r.Context(), hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("error loading break-glass grant: %w", err)
+	}
+	if grant == nil {
+		return nil, nil
+	}
+
+	if err := m.store.RecordUse(r.Context(), grant.ID, r.Method, r.URL.Path); err != nil {
+		return nil, fmt.Errorf("error auditing break-glass request: %w", err)
+	}
+	metrics.BreakGlassRequestsTotal.WithLabelValues(grant.Subject).Inc()
+	m.log.Warn("Break-glass request",
+		zap.Int64("grant_id", grant.ID),
+		zap.String("subject", grant.Subject),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path))
+
+	return &auth.Identity{
+		Subject: "breakglass:" + grant.Subject,
+		Method:  auth.MethodBreakGlass,
+		Roles:   grant.Roles,
+	}, nil
+}
+
+// alertPayload is posted to the alert webhook
+type alertPayload struct {
+	Event         string    `json:"event"`
+	GrantID       int64     `json:"grant_id"`
+	Subject       string    `json:"subject"`
+	Justification string    `json:"justification"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	Actor         string    `json:"actor"`
+}
+
+// alert logs a grant event and posts it to the alert webhook without
+// holding up the caller
+func (m *Manager) alert(event string, grant *store.BreakGlassGrant, actor string) {
+	metrics.BreakGlassEventsTotal.WithLabelValues(event).Inc()
+	m.log.Warn("Break-glass grant "+event,
+		zap.Int64("grant_id", grant.ID),
+		zap.String("subject", grant.Subject),
+		zap.String("justification", grant.Justification),
+		zap.Time("expires_at", grant.ExpiresAt),
+		zap.String("actor", actor))
+
+	m.alerts.Post(alertPayload{
+		Event:         event,
+		GrantID:       grant.ID,
+		Subject:       grant.Subject,
+		Justification: grant.Justification,
+		ExpiresAt:     grant.ExpiresAt,
+		Actor:         actor,
+	})
+}
+
+// hashToken returns the hex SHA-256 of a token, which is all that is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Wait waits for alerts still being delivered to the webhook, or until ctx is done
+func (m *Manager) Wait(ctx context.Context) error {
+	return m.alerts.Wait(ctx)
+}
@@ -0,0 +1,98 @@
+package breakglass
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// grantStore records the grants created through it
+type grantStore struct {
+	store.BreakGlassStore
+	created []*store.BreakGlassGrant
+}
+
+func (s *grantStore) Create(ctx context.Context, grant *store.BreakGlassGrant) error {
+	grant.ID = int64(len(s.created) + 1)
+	s.created = append(s.created, grant)
+	return nil
+}
+
+func TestIssueRequiresIssuerRole(t *testing.T) {
+	const justification = "Primary on-call locked out during incident"
+	tests := []struct {
+		name        string
+		issuerRoles string
+		issuer      *auth.Identity
+		wantErr     error
+	}{
+		{
+			name:        "issuer role",
+			issuerRoles: "breakglass",
+			issuer:      &auth.Identity{Subject: "oncall", Method: auth.MethodAPIKey, Roles: []string{"breakglass"}},
+		},
+		{
+			name:        "one of several issuer roles",
+			issuerRoles: "breakglass, sre",
+			issuer:      &auth.Identity{Subject: "oncall", Method: auth.MethodAPIKey, Roles: []string{"sre"}},
+		},
+		{
+			name:        "without an issuer role",
+			issuerRoles: "breakglass",
+			issuer:      &auth.Identity{Subject: "checker", Method: auth.MethodAPIKey, Roles: []string{"checker"}},
+			wantErr:     ErrIneligible,
+		},
+		{
+			name:        "no issuer roles configured",
+			issuerRoles: " , ",
+			issuer:      &auth.Identity{Subject: "oncall", Method: auth.MethodAPIKey, Roles: []string{"breakglass"}},
+			wantErr:     ErrIneligible,
+		},
+		{
+			name:        "break-glass identity",
+			issuerRoles: "breakglass",
+			issuer:      &auth.Identity{Subject: "oncall", Method: auth.MethodBreakGlass, Roles: []string{"breakglass"}},
+			wantErr:     ErrIneligible,
+		},
+		{
+			name:        "no identity",
+			issuerRoles: "breakglass",
+			wantErr:     ErrIneligible,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.BreakGlass.Roles = "admin"
+			cfg.BreakGlass.IssuerRoles = tt.issuerRoles
+			cfg.BreakGlass.DefaultTTL = time.Hour
+			cfg.BreakGlass.MaxTTL = 4 * time.Hour
+			grants := &grantStore{}
+			m := NewManager(cfg, grants, zap.NewNop())
+
+			issued, err := m.Issue(context.Background(), tt.issuer, justification, 0)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Issue() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(grants.created) != 0 {
+					t.Errorf("Issue() stored %d grants, want none", len(grants.created))
+				}
+				return
+			}
+			if issued.Token == "" || len(grants.created) != 1 {
+				t.Fatalf("Issue() = %+v with %d grants stored, want one grant and a token", issued, len(grants.created))
+			}
+			if got := issued.Grant.Roles; len(got) != 1 || got[0] != "admin" {
+				t.Errorf("grant roles = %v, want [admin]", got)
+			}
+		})
+	}
+}
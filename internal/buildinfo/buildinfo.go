@@ -0,0 +1,60 @@
+// Package buildinfo identifies the build a binary came from. Release builds
+// inject the version, commit and build date with the linker:
+//
+//	go build -ldflags "-X blacklist-check/internal/buildinfo.Version=v1.4.0 \
+//		-X blacklist-check/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X blacklist-check/internal/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Builds without them fall back to the VCS stamp the Go toolchain embeds.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags "-X" at build time
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes a build
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	// CommitTime is when the commit was made, from the VCS stamp
+	CommitTime string `json:"commit_time,omitempty"`
+	BuildDate  string `json:"build_date,omitempty"`
+	// Modified is set when the build had uncommitted changes
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build the running binary came from
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			info.CommitTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
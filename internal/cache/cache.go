@@ -0,0 +1,164 @@
+// Package cache is the shared cache check results are kept in and record
+// changes are broadcast over. The service depends on the Cache interface
+// rather than a Redis client, so it can run against an in-memory fake.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrMiss is returned by Get when a key holds no value
+var ErrMiss = errors.New("cache miss")
+
+// Cache stores values under keys with a TTL, keeps counters and carries
+// messages between replicas
+type Cache interface {
+	// Get returns the value under key, or ErrMiss when there is none
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Counter returns the counter under key, 0 when it was never incremented
+	Counter(ctx context.Context, key string) (int64, error)
+	// IncrScore adds one to the score of member in the sorted set under key
+	IncrScore(ctx context.Context, key, member string) error
+	// TopScores returns the n members of the sorted set under key with the
+	// highest scores, highest first
+	TopScores(ctx context.Context, key string, n int) ([]string, error)
+	// TrimScores drops all but the keep members of the sorted set under key
+	// with the highest scores
+	TrimScores(ctx context.Context, key string, keep int) error
+	// Apply makes the changes of a batch at once and returns how many of
+	// its keys were deleted
+	Apply(ctx context.Context, batch Batch) (int64, error)
+	// Subscribe calls fn with the payload of every message published on
+	// channel until ctx is cancelled. Messages published while the cache is
+	// unreachable are lost.
+	Subscribe(ctx context.Context, channel string, fn func(payload string))
+	// Ping checks that the cache is reachable
+	Ping(ctx context.Context) error
+}
+
+// Batch is a set of changes applied together, so no reader sees part of them
+type Batch struct {
+	Delete []string
+	// Incr are the counters to increment
+	Incr    []string
+	Publish []Message
+}
+
+// Message is a payload published on a channel
+type Message struct {
+	Channel string
+	Payload string
+}
+
+// Redis is the Cache backed by Redis
+type Redis struct {
+	client redis.UniversalClient
+}
+
+var _ Cache = (*Redis)(nil)
+
+// NewRedis creates a Cache on a Redis client
+func NewRedis(client redis.UniversalClient) *Redis {
+	return &Redis{client: client}
+}
+
+// Get returns the value under key, or ErrMiss when there is none
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+// Set stores value under key for ttl
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Counter returns the counter under key, 0 when it was never incremented
+func (c *Redis) Counter(ctx context.Context, key string) (int64, error) {
+	value, err := c.client.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return value, err
+}
+
+// IncrScore adds one to the score of member in the sorted set under key
+func (c *Redis) IncrScore(ctx context.Context, key, member string) error {
+	return c.client.ZIncrBy(ctx, key, 1, member).Err()
+}
+
+// TopScores returns the n members of the sorted set under key with the
+// highest scores, highest first
+func (c *Redis) TopScores(ctx context.Context, key string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	return c.client.ZRevRange(ctx, key, 0, int64(n-1)).Result()
+}
+
+// TrimScores drops all but the keep members of the sorted set under key with
+// the highest scores
+func (c *Redis) TrimScores(ctx context.Context, key string, keep int) error {
+	return c.client.ZRemRangeByRank(ctx, key, 0, int64(-keep-1)).Err()
+}
+
+// Apply makes the changes of a batch in a MULTI/EXEC transaction. Keys are
+// deleted one command each, as a cluster refuses a DEL of keys in different
+// slots; a cluster runs one transaction per slot, so readers may see part of
+// a batch there.
+func (c *Redis) Apply(ctx context.Context, batch Batch) (int64, error) {
+	pipe := c.client.TxPipeline()
+	dels := make([]*redis.IntCmd, len(batch.Delete))
+	for i, key := range batch.Delete {
+		dels[i] = pipe.Del(ctx, key)
+	}
+	for _, key := range batch.Incr {
+		pipe.Incr(ctx, key)
+	}
+	for _, msg := range batch.Publish {
+		pipe.Publish(ctx, msg.Channel, msg.Payload)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("error applying cache batch: %w", err)
+	}
+	var deleted int64
+	for _, del := range dels {
+		deleted += del.Val()
+	}
+	return deleted, nil
+}
+
+// Subscribe calls fn with the payload of every message published on channel
+// until ctx is cancelled
+func (c *Redis) Subscribe(ctx context.Context, channel string, fn func(payload string)) {
+	pubsub := c.client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			fn(msg.Payload)
+		}
+	}
+}
+
+// Ping checks that Redis is reachable
+func (c *Redis) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
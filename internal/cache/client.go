@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"blacklist-check/pkg/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis deployments, picked with REDIS_MODE
+const (
+	// ModeStandalone connects to the one server at REDIS_HOST
+	ModeStandalone = "standalone"
+	// ModeSentinel connects to the master the sentinels at REDIS_ADDRS
+	// report for REDIS_SENTINEL_MASTER, following it through failovers
+	ModeSentinel = "sentinel"
+	// ModeCluster connects to the cluster REDIS_ADDRS are nodes of
+	ModeCluster = "cluster"
+)
+
+// NewRedisClient creates the Redis client of REDIS_MODE, over TLS when
+// REDIS_TLS_ENABLED is set
+func NewRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
+	var tlsConfig *tls.Config
+	if cfg.Redis.TLSEnabled {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.Redis.TLSCAFile != "" {
+			pem, err := os.ReadFile(cfg.Redis.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading Redis CA: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, errors.New("Redis CA file has no certificates")
+			}
+		}
+	}
+
+	switch cfg.Redis.Mode {
+	case ModeSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.Redis.SentinelMaster,
+			SentinelAddrs:    splitAddrs(cfg.Redis.Addrs),
+			SentinelPassword: cfg.Redis.SentinelPassword,
+			Password:         cfg.Redis.Password,
+			DB:               cfg.Redis.DB,
+			ReadTimeout:      cfg.Redis.Timeout,
+			WriteTimeout:     cfg.Redis.Timeout,
+			TLSConfig:        tlsConfig,
+		}), nil
+	case ModeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        splitAddrs(cfg.Redis.Addrs),
+			Password:     cfg.Redis.Password,
+			ReadTimeout:  cfg.Redis.Timeout,
+			WriteTimeout: cfg.Redis.Timeout,
+			TLSConfig:    tlsConfig,
+		}), nil
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:         fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port),
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		ReadTimeout:  cfg.Redis.Timeout,
+		WriteTimeout: cfg.Redis.Timeout,
+		TLSConfig:    tlsConfig,
+	}), nil
+}
+
+// splitAddrs splits a comma-separated REDIS_ADDRS into its addresses
+func splitAddrs(spec string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(spec, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// ScanKeys calls fn with each batch of up to about count keys matching
+// pattern, stopping at the first error fn returns. SCAN only walks the node
+// it is sent to, so a cluster is scanned one master after another.
+func ScanKeys(ctx context.Context, client redis.UniversalClient, pattern string, count int64, fn func(keys []string) error) error {
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, client, pattern, count, fn)
+	}
+
+	// ForEachMaster visits the masters concurrently, so they are collected
+	// first and scanned in turn
+	var (
+		mu      sync.Mutex
+		masters []*redis.Client
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		masters = append(masters, master)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, master := range masters {
+		if err := scanNode(ctx, master, pattern, count, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanNode walks the keys of a single node matching pattern
+func scanNode(ctx context.Context, client redis.UniversalClient, pattern string, count int64, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, pattern, count).Result()
+		if err != nil {
+			return fmt.Errorf("error scanning %s: %w", pattern, err)
+		}
+		if err := fn(keys); err != nil {
+			return err
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,368 @@
+// Package cacheinsight tracks how often each subject is looked up in the
+// caches and reports cache effectiveness by subject popularity, so the size
+// of the in-process tier and the TTLs can be set from observed traffic.
+// Subjects are counted over a fixed window and classed hot, warm or cold by
+// how often they were looked up in it.
+package cacheinsight
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Caches whose lookups are tracked, matching the "cache" metric label
+const (
+	TierRedis    = "redis"
+	TierLocalNIK = "local_nik"
+)
+
+// Popularity classes of a subject
+const (
+	ClassHot  = "hot"
+	ClassWarm = "warm"
+	ClassCold = "cold"
+)
+
+// Classes are the popularity classes, most popular first
+var Classes = []string{ClassHot, ClassWarm, ClassCold}
+
+// gapBuckets are the upper bounds of the time since a subject's previous
+// lookup that repeat misses are grouped by; the last catches the rest
+var gapBuckets = [...]time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// coverageShares are the shares of lookups the report says how many of the
+// most popular subjects it takes to serve
+var coverageShares = []float64{0.5, 0.8, 0.95, 0.99}
+
+// subject is what is known of one subject's lookups in the current window
+type subject struct {
+	lookups int64
+	hits    int64
+	last    time.Time
+	// gaps counts repeat misses by gapBuckets, with one more for longer gaps
+	gaps [len(gapBuckets) + 1]int64
+}
+
+// tracker counts the lookups of one cache
+type tracker struct {
+	started  time.Time
+	subjects map[uint64]*subject
+	// untracked counts the lookups of subjects first seen after maxSubjects
+	// were already tracked
+	untracked int64
+}
+
+// Insights tracks the lookups of every cache tier
+type Insights struct {
+	window      time.Duration
+	maxSubjects int
+	hot         int64
+	warm        int64
+	log         *zap.Logger
+
+	mu       sync.Mutex
+	trackers map[string]*tracker
+	// previous is the report of the last complete window, nil until one completes
+	previous []Report
+	now      func() time.Time
+}
+
+// New creates the cache insights tracker. It returns nil when tracking is
+// disabled; a nil tracker ignores lookups.
+func New(cfg *config.Config, log *zap.Logger) (*Insights, error) {
+	if !cfg.Cache.InsightsEnabled {
+		return nil, nil
+	}
+	if cfg.Cache.InsightsWindow <= 0 {
+		return nil, fmt.Errorf("CACHE_INSIGHTS_WINDOW must be positive")
+	}
+	if cfg.Cache.InsightsMaxSubjects <= 0 {
+		return nil, fmt.Errorf("CACHE_INSIGHTS_MAX_SUBJECTS must be positive")
+	}
+	if cfg.Cache.InsightsWarmLookups < 2 || cfg.Cache.InsightsHotLookups <= cfg.Cache.InsightsWarmLookups {
+		return nil, fmt.Errorf("CACHE_INSIGHTS_WARM_LOOKUPS must be at least 2 and below CACHE_INSIGHTS_HOT_LOOKUPS")
+	}
+	i := &Insights{
+		window:      cfg.Cache.InsightsWindow,
+		maxSubjects: cfg.Cache.InsightsMaxSubjects,
+		hot:         int64(cfg.Cache.InsightsHotLookups),
+		warm:        int64(cfg.Cache.InsightsWarmLookups),
+		log:         log,
+		trackers:    make(map[string]*tracker),
+		now:         time.Now,
+	}
+	i.reset()
+	return i, nil
+}
+
+// reset starts a new window for every tier. Callers must hold mu, or be New.
+func (i *Insights) reset() {
+	now := i.now()
+	for _, tier := range []string{TierRedis, TierLocalNIK} {
+		i.trackers[tier] = &tracker{started: now, subjects: make(map[uint64]*subject)}
+	}
+}
+
+// Record counts a lookup of key in a cache tier. Keys are hashed, so no
+// NIK or name is held.
+func (i *Insights) Record(tier, key string, hit bool) {
+	if i == nil {
+		return
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	id := h.Sum64()
+	now := i.now()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	t, ok := i.trackers[tier]
+	if !ok {
+		return
+	}
+	s, ok := t.subjects[id]
+	if !ok {
+		if len(t.subjects) >= i.maxSubjects {
+			t.untracked++
+			return
+		}
+		s = &subject{}
+		t.subjects[id] = s
+	}
+	if hit {
+		s.hits++
+	} else if s.lookups > 0 {
+		s.gaps[gapBucket(now.Sub(s.last))]++
+	}
+	s.lookups++
+	s.last = now
+}
+
+// gapBucket returns the gapBuckets index of gap
+func gapBucket(gap time.Duration) int {
+	for i, bound := range gapBuckets {
+		if gap <= bound {
+			return i
+		}
+	}
+	return len(gapBuckets)
+}
+
+// Run starts a new window every window, logging the report of the one that
+// ended, until ctx is cancelled
+func (i *Insights) Run(ctx context.Context) {
+	if i == nil {
+		return
+	}
+	ticker := time.NewTicker(i.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reports := i.rotate()
+			for _, report := range reports {
+				fields := []zap.Field{
+					zap.String("tier", report.Tier),
+					zap.Int64("lookups", report.Lookups),
+					zap.Float64("hit_ratio", report.HitRatio),
+					zap.Int("subjects", report.Subjects),
+					zap.Int64("untracked_lookups", report.Untracked),
+				}
+				for _, class := range report.Classes {
+					fields = append(fields,
+						zap.Int(class.Class+"_subjects", class.Subjects),
+						zap.Float64(class.Class+"_hit_ratio", class.HitRatio))
+				}
+				i.log.Info("Cache insights", fields...)
+			}
+		}
+	}
+}
+
+// rotate reports the current window, keeps the report as the previous one
+// and starts a new window
+func (i *Insights) rotate() []Report {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	reports := i.report()
+	i.previous = reports
+	i.reset()
+	return reports
+}
+
+// Report is the cache effectiveness of one tier over a window
+type Report struct {
+	Tier        string    `json:"tier"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	Lookups     int64     `json:"lookups"`
+	Hits        int64     `json:"hits"`
+	HitRatio    float64   `json:"hit_ratio"`
+	Subjects    int       `json:"subjects"`
+	// Untracked counts the lookups of subjects beyond the tracking limit,
+	// which are left out of every other figure
+	Untracked int64         `json:"untracked_lookups"`
+	Classes   []ClassReport `json:"classes"`
+	// Coverage says how many of the most looked-up subjects served each
+	// share of the lookups, the entries an ideal cache would need to hold
+	Coverage []Coverage `json:"coverage"`
+}
+
+// ClassReport is the cache effectiveness for the subjects of one popularity class
+type ClassReport struct {
+	Class string `json:"class"`
+	// MinLookups is the fewest lookups in the window that put a subject in the class
+	MinLookups  int64   `json:"min_lookups"`
+	Subjects    int     `json:"subjects"`
+	Lookups     int64   `json:"lookups"`
+	Hits        int64   `json:"hits"`
+	Misses      int64   `json:"misses"`
+	HitRatio    float64 `json:"hit_ratio"`
+	LookupShare float64 `json:"lookup_share"`
+	// RepeatMisses are misses on a subject already looked up in the window,
+	// the ones a longer TTL or a larger cache might have served
+	RepeatMisses int64 `json:"repeat_misses"`
+	// RepeatMissGaps groups the repeat misses by the time since the
+	// subject's previous lookup
+	RepeatMissGaps []GapBucket `json:"repeat_miss_gaps"`
+}
+
+// GapBucket counts the repeat misses whose gap was at most UpToSeconds,
+// and above the previous bucket's; the last bucket has no bound
+type GapBucket struct {
+	UpToSeconds *float64 `json:"up_to_seconds"`
+	Misses      int64    `json:"misses"`
+}
+
+// Coverage is how many subjects served a share of the lookups
+type Coverage struct {
+	Share    float64 `json:"share"`
+	Subjects int     `json:"subjects"`
+}
+
+// Reports returns the reports of the current window so far, and of the
+// previous complete window, nil until one completes
+func (i *Insights) Reports() (current, previous []Report) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.report(), i.previous
+}
+
+// Window returns how long each window lasts
+func (i *Insights) Window() time.Duration {
+	return i.window
+}
+
+// report reports the current window of every tier. Callers must hold mu.
+func (i *Insights) report() []Report {
+	now := i.now()
+	reports := make([]Report, 0, len(i.trackers))
+	for _, tier := range []string{TierRedis, TierLocalNIK} {
+		reports = append(reports, i.trackers[tier].report(tier, now, i.hot, i.warm))
+	}
+	return reports
+}
+
+// classOf returns the popularity class of a subject looked up lookups times
+func classOf(lookups, hot, warm int64) string {
+	switch {
+	case lookups >= hot:
+		return ClassHot
+	case lookups >= warm:
+		return ClassWarm
+	}
+	return ClassCold
+}
+
+// report summarizes the lookups of one tier
+func (t *tracker) report(tier string, now time.Time, hot, warm int64) Report {
+	report := Report{
+		Tier:        tier,
+		WindowStart: t.started,
+		WindowEnd:   now,
+		Subjects:    len(t.subjects),
+		Untracked:   t.untracked,
+		Coverage:    []Coverage{},
+	}
+	classes := map[string]*ClassReport{
+		ClassHot:  {Class: ClassHot, MinLookups: hot},
+		ClassWarm: {Class: ClassWarm, MinLookups: warm},
+		ClassCold: {Class: ClassCold, MinLookups: 1},
+	}
+	gaps := make(map[string]*[len(gapBuckets) + 1]int64, len(classes))
+	counts := make([]int64, 0, len(t.subjects))
+	for _, s := range t.subjects {
+		class := classes[classOf(s.lookups, hot, warm)]
+		class.Subjects++
+		class.Lookups += s.lookups
+		class.Hits += s.hits
+		if gaps[class.Class] == nil {
+			gaps[class.Class] = new([len(gapBuckets) + 1]int64)
+		}
+		for b, misses := range s.gaps {
+			gaps[class.Class][b] += misses
+			class.RepeatMisses += misses
+		}
+		report.Lookups += s.lookups
+		report.Hits += s.hits
+		counts = append(counts, s.lookups)
+	}
+	report.HitRatio = ratio(report.Hits, report.Lookups)
+
+	for _, name := range Classes {
+		class := classes[name]
+		class.Misses = class.Lookups - class.Hits
+		class.HitRatio = ratio(class.Hits, class.Lookups)
+		class.LookupShare = ratio(class.Lookups, report.Lookups)
+		class.RepeatMissGaps = make([]GapBucket, 0, len(gapBuckets)+1)
+		for b := 0; b <= len(gapBuckets); b++ {
+			bucket := GapBucket{}
+			if b < len(gapBuckets) {
+				seconds := gapBuckets[b].Seconds()
+				bucket.UpToSeconds = &seconds
+			}
+			if gaps[name] != nil {
+				bucket.Misses = gaps[name][b]
+			}
+			class.RepeatMissGaps = append(class.RepeatMissGaps, bucket)
+		}
+		report.Classes = append(report.Classes, *class)
+	}
+
+	sort.Slice(counts, func(a, b int) bool { return counts[a] > counts[b] })
+	var served int64
+	next := 0
+	for n, count := range counts {
+		served += count
+		for next < len(coverageShares) && float64(served) >= coverageShares[next]*float64(report.Lookups) {
+			report.Coverage = append(report.Coverage, Coverage{Share: coverageShares[next], Subjects: n + 1})
+			next++
+		}
+	}
+	return report
+}
+
+// ratio returns part/total, or 0 when total is 0
+func ratio(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
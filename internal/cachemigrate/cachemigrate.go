@@ -0,0 +1,263 @@
+// Package cachemigrate copies cached lookups from one Redis instance to
+// another, keeping each key's remaining TTL, so traffic can be moved to a new
+// cluster without a latency spike while its cache is cold
+package cachemigrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/cache"
+	"blacklist-check/internal/service"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// raiseVersion sets a namespace version only if it is higher than the stored
+// one, so a migration can never resurrect name results orphaned on the target
+var raiseVersion = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current < tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return current
+`)
+
+// Options controls which keys are copied and how fast
+type Options struct {
+	// Namespaces names the cache namespaces to copy (default: all)
+	Namespaces []string
+	// BatchSize is the number of keys scanned and copied per round trip
+	BatchSize int
+	// Rate caps the keys scanned per second to bound the load on the source;
+	// zero copies as fast as possible
+	Rate int
+	// Replace overwrites keys the target already has
+	Replace bool
+	// DryRun scans the source without writing to the target
+	DryRun bool
+}
+
+// Progress reports how far a run has got
+type Progress struct {
+	Scanned int
+	Copied  int
+	// Skipped counts keys the target already had
+	Skipped int
+	// Expired counts keys that expired between the scan and the copy
+	Expired int
+	Elapsed time.Duration
+}
+
+// Migrator copies cache namespaces from a source to a target instance
+type Migrator struct {
+	source redis.UniversalClient
+	target redis.UniversalClient
+	log    *zap.Logger
+}
+
+// NewMigrator creates a new cache migrator
+func NewMigrator(source, target redis.UniversalClient, log *zap.Logger) *Migrator {
+	return &Migrator{
+		source: source,
+		target: target,
+		log:    log,
+	}
+}
+
+// Run copies the selected namespaces. Name results are keyed by the source's
+// namespace version, so the target's version is raised to match first; if
+// the target is already ahead, its name results are newer and none are
+// copied. Run fails if records changed on the source while it was copying,
+// since the copied results may then be stale; running it again is safe.
+func (m *Migrator) Run(ctx context.Context, opts Options) (*Progress, error) {
+	version, err := readVersion(ctx, m.source)
+	if err != nil {
+		return nil, fmt.Errorf("error reading source namespace version: %w", err)
+	}
+	namespaces, err := selectNamespaces(service.CacheNamespaces(version), opts.Namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, ns := range namespaces {
+		if ns.Name != "name" {
+			continue
+		}
+		targetVersion, err := readVersion(ctx, m.target)
+		if err != nil {
+			return nil, fmt.Errorf("error reading target namespace version: %w", err)
+		}
+		if targetVersion > version {
+			m.log.Warn("Target namespace version is ahead of the source, not copying name results",
+				zap.Int64("source_version", version),
+				zap.Int64("target_version", targetVersion))
+			namespaces = append(namespaces[:i], namespaces[i+1:]...)
+			break
+		}
+		if !opts.DryRun {
+			if err := raiseVersion.Run(ctx, m.target, []string{service.NameVersionKey}, version).Err(); err != nil {
+				return nil, fmt.Errorf("error raising target namespace version: %w", err)
+			}
+		}
+		break
+	}
+
+	names := make([]string, len(namespaces))
+	for i, ns := range namespaces {
+		names[i] = ns.Name
+	}
+	m.log.Info("Starting cache migration",
+		zap.Strings("namespaces", names),
+		zap.Int64("name_version", version),
+		zap.Int("rate", opts.Rate),
+		zap.Bool("replace", opts.Replace),
+		zap.Bool("dry_run", opts.DryRun))
+
+	progress := &Progress{}
+	start := time.Now()
+	for _, ns := range namespaces {
+		if err := m.copyNamespace(ctx, ns, opts, progress, start); err != nil {
+			return progress, err
+		}
+	}
+
+	after, err := readVersion(ctx, m.source)
+	if err != nil {
+		return progress, fmt.Errorf("error reading source namespace version: %w", err)
+	}
+	if after != version {
+		return progress, fmt.Errorf("records changed during the migration (namespace version %d to %d), run it again", version, after)
+	}
+
+	m.log.Info("Cache migration completed",
+		zap.Int("scanned", progress.Scanned),
+		zap.Int("copied", progress.Copied),
+		zap.Int("skipped", progress.Skipped),
+		zap.Int("expired", progress.Expired),
+		zap.Duration("elapsed", time.Since(start)))
+	return progress, nil
+}
+
+// copyNamespace scans the source for a namespace's keys a batch at a time,
+// pacing the scan to opts.Rate. A cluster source is scanned one master after
+// another.
+func (m *Migrator) copyNamespace(ctx context.Context, ns service.CacheNamespace, opts Options, progress *Progress, start time.Time) error {
+	return cache.ScanKeys(ctx, m.source, ns.Pattern, int64(opts.BatchSize), func(keys []string) error {
+		if len(keys) > 0 {
+			if err := m.copyBatch(ctx, keys, opts, progress); err != nil {
+				return fmt.Errorf("error copying %s keys: %w", ns.Name, err)
+			}
+		}
+		progress.Elapsed = time.Since(start)
+		m.log.Info("Cache migration progress",
+			zap.String("namespace", ns.Name),
+			zap.Int("scanned", progress.Scanned),
+			zap.Int("copied", progress.Copied),
+			zap.Duration("elapsed", progress.Elapsed))
+
+		if opts.Rate > 0 {
+			due := time.Duration(float64(progress.Scanned) / float64(opts.Rate) * float64(time.Second))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(due - time.Since(start)):
+			}
+		}
+		return ctx.Err()
+	})
+}
+
+// copyBatch reads the values and remaining TTLs of keys from the source and
+// writes them to the target in one pipeline each way
+func (m *Migrator) copyBatch(ctx context.Context, keys []string, opts Options, progress *Progress) error {
+	progress.Scanned += len(keys)
+
+	read := m.source.Pipeline()
+	values := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		values[i] = read.Get(ctx, key)
+		ttls[i] = read.PTTL(ctx, key)
+	}
+	if _, err := read.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+
+	write := m.target.Pipeline()
+	var writes []*redis.BoolCmd
+	for i, key := range keys {
+		value, err := values[i].Result()
+		ttl := ttls[i].Val()
+		// PTTL reports -2 once a key is gone; -1 means it never expires
+		if err == redis.Nil || ttl == -2 {
+			progress.Expired++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if ttl < 0 {
+			ttl = 0
+		}
+		if opts.DryRun {
+			progress.Copied++
+			continue
+		}
+		if opts.Replace {
+			write.Set(ctx, key, value, ttl)
+			progress.Copied++
+		} else {
+			writes = append(writes, write.SetNX(ctx, key, value, ttl))
+		}
+	}
+	if opts.DryRun {
+		return nil
+	}
+	if _, err := write.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+	for _, cmd := range writes {
+		if cmd.Val() {
+			progress.Copied++
+		} else {
+			progress.Skipped++
+		}
+	}
+	return nil
+}
+
+// readVersion returns the name namespace version of an instance, zero when
+// it has none
+func readVersion(ctx context.Context, client redis.UniversalClient) (int64, error) {
+	version, err := client.Get(ctx, service.NameVersionKey).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// selectNamespaces returns the named namespaces, or all of them when none
+// are named
+func selectNamespaces(all []service.CacheNamespace, names []string) ([]service.CacheNamespace, error) {
+	if len(names) == 0 {
+		return all, nil
+	}
+	var selected []service.CacheNamespace
+	for _, name := range names {
+		found := false
+		for _, ns := range all {
+			if ns.Name == name {
+				selected = append(selected, ns)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown cache namespace %q", name)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,344 @@
+package cachemigrate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/service"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// fakeRedis speaks enough of the Redis protocol for the migrator: strings
+// with expiry, SCAN, and the version-raising script. Expired keys are still
+// scanned, as Redis may return keys it hasn't evicted yet.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{listener: listener, values: map[string]string{}, expires: map[string]time.Time{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return r
+}
+
+func (r *fakeRedis) client(t *testing.T) redis.UniversalClient {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: r.listener.Addr().String()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// set stores a key expiring after ttl, or never when ttl is zero
+func (r *fakeRedis) set(key, value string, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[key] = value
+	delete(r.expires, key)
+	if ttl != 0 {
+		r.expires[key] = time.Now().Add(ttl)
+	}
+}
+
+// get returns a key's value and remaining TTL, -1 when it never expires
+func (r *fakeRedis) get(key string) (string, time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookup(key)
+}
+
+func (r *fakeRedis) lookup(key string) (string, time.Duration, bool) {
+	value, ok := r.values[key]
+	if !ok {
+		return "", 0, false
+	}
+	expires, ok := r.expires[key]
+	if !ok {
+		return value, -1, true
+	}
+	ttl := time.Until(expires)
+	if ttl <= 0 {
+		return "", 0, false
+	}
+	return value, ttl, true
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		reply := r.exec(args)
+		r.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (r *fakeRedis) exec(args []string) string {
+	switch strings.ToLower(args[0]) {
+	case "get":
+		value, _, ok := r.lookup(args[1])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(value)
+	case "pttl":
+		_, ttl, ok := r.lookup(args[1])
+		switch {
+		case !ok:
+			return ":-2\r\n"
+		case ttl < 0:
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", ttl.Milliseconds())
+	case "set":
+		key, value := args[1], args[2]
+		var ttl time.Duration
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToLower(args[i]) {
+			case "px":
+				ms, _ := strconv.ParseInt(args[i+1], 10, 64)
+				ttl = time.Duration(ms) * time.Millisecond
+				i++
+			case "ex":
+				s, _ := strconv.ParseInt(args[i+1], 10, 64)
+				ttl = time.Duration(s) * time.Second
+				i++
+			case "nx":
+				nx = true
+			}
+		}
+		if _, _, exists := r.lookup(key); nx && exists {
+			return "$-1\r\n"
+		}
+		r.values[key] = value
+		delete(r.expires, key)
+		if ttl != 0 {
+			r.expires[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "setnx":
+		if _, _, exists := r.lookup(args[1]); exists {
+			return ":0\r\n"
+		}
+		r.values[args[1]] = args[2]
+		return ":1\r\n"
+	case "scan":
+		pattern := "*"
+		for i := 2; i < len(args)-1; i++ {
+			if strings.ToLower(args[i]) == "match" {
+				pattern = args[i+1]
+			}
+		}
+		var keys []string
+		for key := range r.values {
+			if ok, _ := path.Match(pattern, key); ok {
+				keys = append(keys, key)
+			}
+		}
+		reply := "*2\r\n" + bulk("0") + fmt.Sprintf("*%d\r\n", len(keys))
+		for _, key := range keys {
+			reply += bulk(key)
+		}
+		return reply
+	case "evalsha":
+		return "-NOSCRIPT No matching script\r\n"
+	case "eval":
+		// The only script run is raiseVersion: EVAL script 1 key version
+		key, version := args[3], args[4]
+		current, _, _ := r.lookup(key)
+		currentVersion, _ := strconv.ParseInt(current, 10, 64)
+		if raised, _ := strconv.ParseInt(version, 10, 64); currentVersion < raised {
+			r.values[key] = version
+		}
+		return fmt.Sprintf(":%d\r\n", currentVersion)
+	}
+	return fmt.Sprintf("-ERR unknown command %q\r\n", args[0])
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// cacheKey returns a key of a namespace at the given name version
+func cacheKey(namespace string, version int64, suffix string) string {
+	for _, ns := range service.CacheNamespaces(version) {
+		if ns.Name == namespace {
+			return strings.TrimSuffix(ns.Pattern, "*") + suffix
+		}
+	}
+	panic("unknown namespace " + namespace)
+}
+
+func TestRun(t *testing.T) {
+	nikKey := cacheKey("nik", 3, "internal:3171011505900001")
+	nameKey := cacheKey("name", 3, "internal:john doe")
+	staleNameKey := cacheKey("name", 2, "internal:john doe")
+	takenKey := cacheKey("nik", 3, "internal:3171011505900002")
+	expiringKey := cacheKey("nik", 3, "internal:3171011505900003")
+
+	tests := []struct {
+		name          string
+		opts          Options
+		targetVersion int64
+		wantCopied    []string
+		wantProgress  Progress
+		wantVersion   string
+	}{
+		{
+			name:          "copies current keys",
+			opts:          Options{BatchSize: 10},
+			targetVersion: 1,
+			wantCopied:    []string{nikKey, nameKey},
+			wantProgress:  Progress{Scanned: 4, Copied: 2, Skipped: 1, Expired: 1},
+			wantVersion:   "3",
+		},
+		{
+			name:          "replaces keys the target has",
+			opts:          Options{BatchSize: 10, Replace: true},
+			targetVersion: 1,
+			wantCopied:    []string{nikKey, nameKey, takenKey},
+			wantProgress:  Progress{Scanned: 4, Copied: 3, Expired: 1},
+			wantVersion:   "3",
+		},
+		{
+			name:          "one namespace",
+			opts:          Options{BatchSize: 10, Namespaces: []string{"nik"}},
+			targetVersion: 1,
+			wantCopied:    []string{nikKey},
+			wantProgress:  Progress{Scanned: 3, Copied: 1, Skipped: 1, Expired: 1},
+			wantVersion:   "1",
+		},
+		{
+			name:          "target version ahead",
+			opts:          Options{BatchSize: 10},
+			targetVersion: 4,
+			wantCopied:    []string{nikKey},
+			wantProgress:  Progress{Scanned: 3, Copied: 1, Skipped: 1, Expired: 1},
+			wantVersion:   "4",
+		},
+		{
+			name:          "dry run",
+			opts:          Options{BatchSize: 10, DryRun: true},
+			targetVersion: 1,
+			wantProgress:  Progress{Scanned: 4, Copied: 3, Expired: 1},
+			wantVersion:   "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, target := newFakeRedis(t), newFakeRedis(t)
+			source.set(service.NameVersionKey, "3", 0)
+			source.set(nikKey, "nik result", time.Hour)
+			source.set(nameKey, "name result", 30*time.Minute)
+			source.set(staleNameKey, "orphaned name result", time.Hour)
+			source.set(takenKey, "source result", time.Hour)
+			// Expires after the scan but before the copy
+			source.set(expiringKey, "expired result", -time.Second)
+			target.set(service.NameVersionKey, strconv.FormatInt(tt.targetVersion, 10), 0)
+			target.set(takenKey, "target result", time.Hour)
+
+			m := NewMigrator(source.client(t), target.client(t), zap.NewNop())
+			progress, err := m.Run(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			progress.Elapsed = 0
+			if *progress != tt.wantProgress {
+				t.Errorf("Run() = %+v, want %+v", *progress, tt.wantProgress)
+			}
+
+			for _, key := range tt.wantCopied {
+				want, sourceTTL, _ := source.get(key)
+				got, ttl, ok := target.get(key)
+				if !ok || got != want {
+					t.Errorf("target %s = %q, want %q", key, got, want)
+				}
+				// The remaining TTL is kept
+				if ttl <= 0 || ttl > sourceTTL+time.Second {
+					t.Errorf("target %s expires in %s, want about %s", key, ttl, sourceTTL)
+				}
+			}
+			if got, _, _ := target.get(takenKey); !tt.opts.Replace && got != "target result" {
+				t.Errorf("target %s = %q, want it kept", takenKey, got)
+			}
+			if _, _, ok := target.get(staleNameKey); ok {
+				t.Errorf("copied %s of an old namespace version", staleNameKey)
+			}
+			if got, _, _ := target.get(service.NameVersionKey); got != tt.wantVersion {
+				t.Errorf("target namespace version = %s, want %s", got, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestRunUnknownNamespace(t *testing.T) {
+	source, target := newFakeRedis(t), newFakeRedis(t)
+	m := NewMigrator(source.client(t), target.client(t), zap.NewNop())
+	if _, err := m.Run(context.Background(), Options{BatchSize: 10, Namespaces: []string{"phone"}}); err == nil {
+		t.Error("Run() with an unknown namespace error = nil")
+	}
+}
@@ -0,0 +1,80 @@
+// Package clock centralizes validation of caller-supplied timestamps, such as
+// those in signed requests, so every check shares one skew tolerance, and
+// watches this host's clock for drift that would make such checks fail.
+package clock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ErrSkew is returned when a timestamp falls outside the validation window
+var ErrSkew = errors.New("timestamp outside allowed window")
+
+// Window accepts timestamps within Tolerance of the local clock in either
+// direction, so a caller whose clock runs slightly ahead isn't rejected
+type Window struct {
+	Tolerance time.Duration
+}
+
+// Check validates ts against now
+func (w Window) Check(ts, now time.Time) error {
+	skew := now.Sub(ts)
+	if skew > w.Tolerance {
+		return fmt.Errorf("%w: %s old, tolerance %s", ErrSkew, skew.Round(time.Second), w.Tolerance)
+	}
+	if -skew > w.Tolerance {
+		return fmt.Errorf("%w: %s in the future, tolerance %s", ErrSkew, (-skew).Round(time.Second), w.Tolerance)
+	}
+	return nil
+}
+
+// DriftMonitor compares the local clock with the database server's, which is
+// expected to be NTP-synchronized, and warns when they diverge. Drift on this
+// host eats into every Window's tolerance.
+type DriftMonitor struct {
+	db        *sqlx.DB
+	threshold time.Duration
+	log       *zap.Logger
+}
+
+// NewDriftMonitor creates a drift monitor warning above threshold
+func NewDriftMonitor(db *sqlx.DB, threshold time.Duration, log *zap.Logger) *DriftMonitor {
+	return &DriftMonitor{db: db, threshold: threshold, log: log}
+}
+
+// Measure returns how far the local clock is ahead of the database server's.
+// The server time is assumed to be read halfway through the round trip.
+func (m *DriftMonitor) Measure(ctx context.Context) (time.Duration, error) {
+	var server time.Time
+	start := time.Now()
+	if err := m.db.GetContext(ctx, &server, `SELECT CURRENT_TIMESTAMP`); err != nil {
+		return 0, fmt.Errorf("error reading database time: %w", err)
+	}
+	rtt := time.Since(start)
+
+	return start.Add(rtt / 2).Sub(server), nil
+}
+
+// Check measures drift, exports it and logs a warning when it exceeds the threshold
+func (m *DriftMonitor) Check(ctx context.Context) error {
+	drift, err := m.Measure(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.ClockDriftSeconds.Set(drift.Seconds())
+
+	if drift > m.threshold || -drift > m.threshold {
+		m.log.Warn("Local clock drifts from database server; check NTP synchronization",
+			zap.Duration("drift", drift),
+			zap.Duration("threshold", m.threshold))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Package database connects to the database DB_DRIVER selects. Postgres is
+// the reference backend; MySQL and SQLite serve deployments without
+// Postgres and pg_trgm, backing the blacklist store only.
+package database
+
+import (
+	"fmt"
+
+	"blacklist-check/pkg/config"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DB_DRIVER values
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+)
+
+// mysqlTLS maps DB_SSL_MODE onto the MySQL driver's tls parameter
+var mysqlTLS = map[string]string{
+	"disable":     "false",
+	"allow":       "preferred",
+	"prefer":      "preferred",
+	"require":     "skip-verify",
+	"verify-ca":   "true",
+	"verify-full": "true",
+}
+
+// Connect opens and pings the database the configuration selects
+func Connect(cfg *config.Config) (*sqlx.DB, error) {
+	db := cfg.Database
+	switch db.Driver {
+	case Postgres:
+		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			db.Host, db.Port, db.User, db.Password, db.DBName, db.SSLMode)
+		return sqlx.Connect("postgres", dsn)
+	case MySQL:
+		// Times are read and written in UTC, and migrations hold several statements
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=UTC&multiStatements=true&tls=%s",
+			db.User, db.Password, db.Host, db.Port, db.DBName, mysqlTLS[db.SSLMode])
+		return sqlx.Connect("mysql", dsn)
+	case SQLite:
+		// One connection serializes writers, which SQLite would otherwise
+		// reject as busy; foreign keys are off unless asked for
+		conn, err := sqlx.Connect("sqlite3", "file:"+db.DBName+"?_foreign_keys=on&_busy_timeout=5000")
+		if err != nil {
+			return nil, err
+		}
+		conn.SetMaxOpenConns(1)
+		return conn, nil
+	}
+	return nil, fmt.Errorf("unknown DB_DRIVER %q", db.Driver)
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/pkg/config"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// ErrUnavailable is returned without reaching the database while the circuit
+// breaker is open
+var ErrUnavailable = errors.New("database unavailable")
+
+// failure classes of a database error, used as the "class" label
+const (
+	// classConflict is a serialization failure or deadlock; the statement
+	// was rolled back, so running it again is safe
+	classConflict = "conflict"
+	// classUnreachable is a connection that couldn't be made or was bad
+	// before anything was sent
+	classUnreachable = "unreachable"
+	// classDisconnected is a connection lost, or a server shutting down,
+	// while a statement ran; a write may have been applied
+	classDisconnected = "disconnected"
+)
+
+// circuit breaker states
+const (
+	breakerClosed = iota
+	breakerOpen
+	// breakerProbing lets one call through to find out whether the
+	// database is back
+	breakerProbing
+)
+
+// Guard runs database calls with retries of transient errors, backing off
+// with full jitter, and a circuit breaker. DB_BREAKER_FAILURES attempts in a
+// row that fail to reach the database open the breaker, failing calls with
+// ErrUnavailable for DB_BREAKER_COOLDOWN; one call then probes the database,
+// closing the breaker when it gets through and reopening it otherwise.
+type Guard struct {
+	attempts int
+	backoff  time.Duration
+	failures int
+	cooldown time.Duration
+	log      *zap.Logger
+
+	mu    sync.Mutex
+	state int
+	// failed counts attempts in a row that didn't reach the database
+	failed    int
+	openUntil time.Time
+}
+
+// NewGuard creates the guard of the blacklist store's database calls
+func NewGuard(cfg *config.Config, log *zap.Logger) *Guard {
+	return &Guard{
+		attempts: cfg.Database.RetryAttempts,
+		backoff:  cfg.Database.RetryBackoff,
+		failures: cfg.Database.BreakerFailures,
+		cooldown: cfg.Database.BreakerCooldown,
+		log:      log,
+	}
+}
+
+// Read runs fn, retrying every transient error
+func (g *Guard) Read(ctx context.Context, fn func() error) error {
+	return g.run(ctx, fn, func(class string) bool { return class != "" })
+}
+
+// Write runs fn, retrying only the errors after which the database is known
+// not to have applied it, so a write is never made twice
+func (g *Guard) Write(ctx context.Context, fn func() error) error {
+	return g.run(ctx, fn, func(class string) bool {
+		return class == classConflict || class == classUnreachable
+	})
+}
+
+// run calls fn until it succeeds, fails with an error retryable doesn't
+// accept, runs out of attempts or ctx is done
+func (g *Guard) run(ctx context.Context, fn func() error, retryable func(class string) bool) error {
+	for attempt := 1; ; attempt++ {
+		if !g.allow() {
+			metrics.DBCircuitRejectionsTotal.Inc()
+			return ErrUnavailable
+		}
+		err := fn()
+		class := classify(err)
+		g.record(ctx, err, class)
+		if err == nil || !retryable(class) || attempt >= g.attempts {
+			return err
+		}
+		metrics.DBRetriesTotal.WithLabelValues(class).Inc()
+
+		timer := time.NewTimer(g.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// delay is a random wait of up to the backoff doubled for each retry before
+// attempt, so callers that failed together don't retry together
+func (g *Guard) delay(attempt int) time.Duration {
+	ceiling := g.backoff << (attempt - 1)
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// allow reports whether a call may go to the database, moving an open
+// breaker whose cooldown is over to probing
+func (g *Guard) allow() bool {
+	if g.failures == 0 {
+		return true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch g.state {
+	case breakerOpen:
+		if time.Now().Before(g.openUntil) {
+			return false
+		}
+		g.state = breakerProbing
+		return true
+	case breakerProbing:
+		return false
+	}
+	return true
+}
+
+// record moves the breaker on the outcome of an attempt
+func (g *Guard) record(ctx context.Context, err error, class string) {
+	if g.failures == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch {
+	case class == classUnreachable || class == classDisconnected:
+		g.failed++
+		if g.state == breakerProbing || (g.state == breakerClosed && g.failed >= g.failures) {
+			if g.state == breakerClosed {
+				g.log.Warn("Database circuit breaker opened", zap.Int("failures", g.failed), zap.Error(err))
+			}
+			g.state = breakerOpen
+			g.openUntil = time.Now().Add(g.cooldown)
+			metrics.DBCircuitOpen.Set(1)
+		}
+	case err != nil && ctx.Err() != nil:
+		// The caller gave up, which says nothing about the database; a
+		// probe is let through again at once
+		if g.state == breakerProbing {
+			g.state = breakerOpen
+		}
+	default:
+		g.failed = 0
+		if g.state != breakerClosed {
+			g.log.Info("Database circuit breaker closed")
+			g.state = breakerClosed
+			metrics.DBCircuitOpen.Set(0)
+		}
+	}
+}
+
+// classify returns the failure class of err, or "" when it isn't transient.
+// Timeouts aren't transient: they are the caller's deadline, which a retry
+// would only overrun.
+func classify(err error) string {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ""
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "40001" || pqErr.Code == "40P01":
+			return classConflict
+		case pqErr.Code == "08001" || pqErr.Code == "08004" || pqErr.Code == "57P03":
+			// The server refused the connection, or is still starting up
+			return classUnreachable
+		case pqErr.Code.Class() == "08" || pqErr.Code == "57P01" || pqErr.Code == "57P02":
+			return classDisconnected
+		}
+		return ""
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED) {
+		return classUnreachable
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return classDisconnected
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && !netErr.Timeout() {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return classUnreachable
+		}
+		return classDisconnected
+	}
+	return ""
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"cancelled", context.Canceled, ""},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), ""},
+		{"serialization failure", &pq.Error{Code: "40001"}, classConflict},
+		{"deadlock", &pq.Error{Code: "40P01"}, classConflict},
+		{"connection refused by server", &pq.Error{Code: "08001"}, classUnreachable},
+		{"server starting up", &pq.Error{Code: "57P03"}, classUnreachable},
+		{"connection failure", &pq.Error{Code: "08006"}, classDisconnected},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, classDisconnected},
+		{"unique violation", &pq.Error{Code: "23505"}, ""},
+		{"bad connection", driver.ErrBadConn, classUnreachable},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, classUnreachable},
+		{"dial failure", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, classUnreachable},
+		{"connection reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, classDisconnected},
+		{"unexpected EOF", io.ErrUnexpectedEOF, classDisconnected},
+		{"network timeout", &net.OpError{Op: "read", Err: timeoutError{}}, ""},
+		{"other", errors.New("syntax error"), ""},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.err); got != tt.want {
+			t.Errorf("classify(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGuardRetries(t *testing.T) {
+	tests := []struct {
+		name  string
+		write bool
+		err   error
+		calls int
+	}{
+		{"read retries a conflict", false, &pq.Error{Code: "40001"}, 3},
+		{"read retries a lost connection", false, io.ErrUnexpectedEOF, 3},
+		{"write retries a conflict", true, &pq.Error{Code: "40001"}, 3},
+		{"write doesn't retry a lost connection", true, io.ErrUnexpectedEOF, 1},
+		{"nothing retries a constraint violation", false, &pq.Error{Code: "23505"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Guard{attempts: 3, backoff: time.Microsecond, log: zap.NewNop()}
+			calls := 0
+			fn := func() error {
+				calls++
+				return tt.err
+			}
+			run := g.Read
+			if tt.write {
+				run = g.Write
+			}
+			if err := run(context.Background(), fn); !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+			if calls != tt.calls {
+				t.Errorf("calls = %d, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestGuardBreaker(t *testing.T) {
+	g := &Guard{attempts: 1, failures: 2, cooldown: time.Hour, log: zap.NewNop()}
+	unreachable := func() error { return driver.ErrBadConn }
+	for i := 0; i < 2; i++ {
+		if err := g.Read(context.Background(), unreachable); !errors.Is(err, driver.ErrBadConn) {
+			t.Fatalf("attempt %d: error = %v", i+1, err)
+		}
+	}
+	called := false
+	err := g.Read(context.Background(), func() error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrUnavailable) || called {
+		t.Errorf("open breaker: error = %v, called = %t, want ErrUnavailable without a call", err, called)
+	}
+
+	// Once the cooldown is over a probe that gets through closes it
+	g.openUntil = time.Now()
+	if err := g.Read(context.Background(), func() error { return nil }); err != nil {
+		t.Fatalf("probe: error = %v", err)
+	}
+	if g.state != breakerClosed {
+		t.Errorf("state after probe = %d, want closed", g.state)
+	}
+}
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
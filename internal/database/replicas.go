@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/pkg/config"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// lagQuery reads how far a Postgres standby's replay trails its primary. A
+// standby that has replayed everything it received is caught up however long
+// ago the last transaction was; a server that isn't a standby has no lag.
+const lagQuery = `
+	SELECT COALESCE(CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+	END, 0)`
+
+// replica is a read replica and whether it may serve reads
+type replica struct {
+	host    string
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+// Replicas routes the reads that tolerate replication lag to Postgres read
+// replicas, round robin, and everything else to the primary. A replica
+// serves reads only while its last health check found it reachable and no
+// more than DB_REPLICA_MAX_LAG behind; with none healthy, reads fail over to
+// the primary. Replicas start unhealthy, so reads go to the primary until
+// the first check.
+type Replicas struct {
+	primary  *sqlx.DB
+	replicas []*replica
+	maxLag   time.Duration
+	interval time.Duration
+	log      *zap.Logger
+
+	next atomic.Uint64
+	// fencedUntil is when reads may go back to the replicas after a record
+	// change, in Unix nanoseconds
+	fencedUntil atomic.Int64
+}
+
+// NewReplicas opens a pool on each of DB_REPLICA_HOSTS, with the primary's
+// credentials. Without replicas every read goes to the primary. Replicas
+// aren't reached until their first health check, so one that is down
+// doesn't hold up startup.
+func NewReplicas(cfg *config.Config, primary *sqlx.DB, log *zap.Logger) (*Replicas, error) {
+	r := &Replicas{
+		primary:  primary,
+		maxLag:   cfg.Database.ReplicaMaxLag,
+		interval: cfg.Database.ReplicaCheckInterval,
+		log:      log,
+	}
+	for _, hostPort := range strings.Split(cfg.Database.ReplicaHosts, ",") {
+		if hostPort = strings.TrimSpace(hostPort); hostPort == "" {
+			continue
+		}
+		host, port := hostPort, cfg.Database.Port
+		if h, p, err := net.SplitHostPort(hostPort); err == nil {
+			if port, err = strconv.Atoi(p); err != nil {
+				return nil, fmt.Errorf("replica %q has an invalid port", hostPort)
+			}
+			host = h
+		}
+		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			host, port, cfg.Database.User, cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
+		db, err := sqlx.Open("postgres", dsn)
+		if err != nil {
+			return nil, fmt.Errorf("error opening replica %s: %w", hostPort, err)
+		}
+		r.replicas = append(r.replicas, &replica{host: hostPort, db: db})
+	}
+	return r, nil
+}
+
+// Reader returns the database to run a lag-tolerant read on: the next
+// healthy replica, or the primary when none is healthy or a record changed
+// too recently for the replicas to be trusted with it
+func (r *Replicas) Reader() *sqlx.DB {
+	if len(r.replicas) == 0 {
+		return r.primary
+	}
+	if time.Now().UnixNano() < r.fencedUntil.Load() {
+		metrics.DBReadsTotal.WithLabelValues("primary").Inc()
+		return r.primary
+	}
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		replica := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if replica.healthy.Load() {
+			metrics.DBReadsTotal.WithLabelValues("replica").Inc()
+			return replica.db
+		}
+	}
+	metrics.DBReadsTotal.WithLabelValues("primary").Inc()
+	return r.primary
+}
+
+// Fence sends reads to the primary for as long as a healthy replica may take
+// to replay a change just committed. Without it, a check right after a
+// record change could read the old record from a replica and cache it again
+// after the change invalidated it.
+func (r *Replicas) Fence() {
+	if len(r.replicas) == 0 {
+		return
+	}
+	r.fencedUntil.Store(time.Now().Add(r.maxLag + r.interval).UnixNano())
+}
+
+// Check measures the lag of every replica, marking those unreachable or too
+// far behind unhealthy until the next check
+func (r *Replicas) Check(ctx context.Context) {
+	for _, replica := range r.replicas {
+		healthy := r.check(ctx, replica)
+		if was := replica.healthy.Swap(healthy); was != healthy {
+			r.log.Info("Read replica health changed", zap.String("replica", replica.host), zap.Bool("healthy", healthy))
+		}
+		value := 0.0
+		if healthy {
+			value = 1
+		}
+		metrics.DBReplicaHealthy.WithLabelValues(replica.host).Set(value)
+	}
+}
+
+// check reports whether a replica is reachable and caught up enough to serve reads
+func (r *Replicas) check(ctx context.Context, replica *replica) bool {
+	ctx, cancel := context.WithTimeout(ctx, r.interval)
+	defer cancel()
+
+	var lag float64
+	if err := replica.db.GetContext(ctx, &lag, lagQuery); err != nil {
+		r.log.Warn("Error checking read replica", zap.String("replica", replica.host), zap.Error(err))
+		return false
+	}
+	metrics.DBReplicaLag.WithLabelValues(replica.host).Set(lag)
+	if lag > r.maxLag.Seconds() {
+		r.log.Debug("Read replica is lagging",
+			zap.String("replica", replica.host),
+			zap.Float64("lag_seconds", lag),
+			zap.Duration("max_lag", r.maxLag))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+// Package deadline lets latency-sensitive callers bound how long a check may
+// take. A request carrying an X-Deadline-Ms header runs under that deadline,
+// and once it passes the check answers with what it has screened so far
+// instead of waiting on slow queries. Without the header the server's own
+// timeout applies as before.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/pkg/config"
+)
+
+// Header is the request header carrying the caller's deadline in milliseconds
+const Header = "X-Deadline-Ms"
+
+// Hints is the deadline hint middleware
+type Hints struct {
+	min time.Duration
+	max time.Duration
+}
+
+// NewHints creates the deadline hint middleware
+func NewHints(cfg *config.Config) *Hints {
+	return &Hints{min: cfg.Server.DeadlineHintMin, max: cfg.Server.DeadlineHintMax}
+}
+
+type hintKey struct{}
+
+// Middleware runs requests with an X-Deadline-Ms header under that deadline,
+// raised to the configured minimum and capped at the maximum so a hint can
+// neither starve a check nor outlast the server's timeout
+func (h *Hints) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get(Header)
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ms, err := strconv.Atoi(value)
+		if err != nil || ms <= 0 {
+			apierror.Validation(w, r, "X-Deadline-Ms must be a positive number of milliseconds", nil)
+			return
+		}
+
+		timeout := time.Duration(ms) * time.Millisecond
+		if timeout < h.min {
+			timeout = h.min
+		}
+		if timeout > h.max {
+			timeout = h.max
+		}
+		ctx, cancel := context.WithTimeout(context.WithValue(r.Context(), hintKey{}, true), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Hinted reports whether the caller set a deadline for the request
+func Hinted(ctx context.Context) bool {
+	hinted, _ := ctx.Value(hintKey{}).(bool)
+	return hinted
+}
+
+// Exceeded reports whether the deadline the caller set has passed, in which
+// case a partial answer is preferred to an error
+func Exceeded(ctx context.Context) bool {
+	return Hinted(ctx) && errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
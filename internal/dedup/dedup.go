@@ -0,0 +1,130 @@
+// Package dedup finds records that likely describe the same person, as bulk
+// imports from several sources tend to create, and merges those a reviewer
+// confirms. Records of the same list are taken for duplicates when their NIKs
+// have the same digits, or when they were born the same day and their names
+// are at least DEDUP_NAME_SIMILARITY alike. Detection runs every
+// DEDUP_INTERVAL over the records changed since its last run on any replica.
+package dedup
+
+import (
+	"context"
+	"fmt"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Deduplicator detects duplicate records and merges or dismisses them
+type Deduplicator struct {
+	store      store.DuplicateStore
+	service    service.Service
+	similarity float64
+	log        *zap.Logger
+}
+
+// NewDeduplicator creates the deduplicator, or returns nil when
+// DEDUP_ENABLED is off
+func NewDeduplicator(cfg *config.Config, duplicates store.DuplicateStore, service service.Service, log *zap.Logger) *Deduplicator {
+	if !cfg.Dedup.Enabled {
+		return nil
+	}
+	return &Deduplicator{
+		store:      duplicates,
+		service:    service,
+		similarity: cfg.Dedup.NameSimilarity,
+		log:        log,
+	}
+}
+
+// Run records the duplicate pairs among the records changed since the last
+// run and updates the duplicate gauges. A run while another replica's is in
+// progress only updates the gauges.
+func (d *Deduplicator) Run(ctx context.Context) error {
+	found, err := d.store.Detect(ctx, d.similarity)
+	if err != nil {
+		return fmt.Errorf("error detecting duplicates: %w", err)
+	}
+
+	var total int64
+	for reason, n := range found {
+		metrics.RecordDuplicatesFoundTotal.WithLabelValues(reason).Add(float64(n))
+		total += n
+	}
+
+	stats, err := d.store.Stats(ctx)
+	if err != nil {
+		return fmt.Errorf("error counting duplicates: %w", err)
+	}
+	metrics.RecordDuplicatesPending.Set(float64(stats.Pending))
+	rate := 0.0
+	if stats.Live > 0 {
+		rate = float64(stats.Duplicated) / float64(stats.Live)
+	}
+	metrics.RecordDuplicateRate.Set(rate)
+
+	if total > 0 {
+		d.log.Info("Found duplicate records",
+			zap.Int64("nik", found[store.DuplicateNIK]),
+			zap.Int64("name_birth_date", found[store.DuplicateNameBirthDate]),
+			zap.Int64("pending", stats.Pending))
+	}
+	return nil
+}
+
+// List returns the pairs with status, oldest first
+func (d *Deduplicator) List(ctx context.Context, status string) ([]*store.DuplicatePair, error) {
+	return d.store.List(ctx, status)
+}
+
+// Get returns a pair, or store.ErrDuplicateNotFound
+func (d *Deduplicator) Get(ctx context.Context, id int64) (*store.DuplicatePair, error) {
+	return d.store.Get(ctx, id)
+}
+
+// Merge merges a pending pair into the record keep as the actor in ctx and
+// invalidates the cached checks of both NIKs
+func (d *Deduplicator) Merge(ctx context.Context, id, keep int64) (*store.DuplicatePair, error) {
+	pair, err := d.store.Merge(ctx, id, keep)
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordDuplicatesResolvedTotal.WithLabelValues(store.DuplicateMerged).Inc()
+
+	var niks []string
+	for _, record := range []*store.BlacklistRecord{pair.Record, pair.Duplicate} {
+		if record != nil {
+			niks = append(niks, record.NIK)
+		}
+	}
+	if err := d.service.InvalidateRecords(ctx, niks...); err != nil {
+		d.log.Error("Error invalidating cache after merging duplicates",
+			zap.Int64("duplicate_id", id),
+			zap.Error(err))
+	}
+
+	d.log.Info("Merged duplicate records",
+		zap.Int64("duplicate_id", id),
+		zap.String("list", pair.List),
+		zap.Int64("kept_id", keep),
+		zap.String("merged_by", store.Actor(ctx)))
+	return pair, nil
+}
+
+// Dismiss marks a pending pair as not duplicates as the actor in ctx
+func (d *Deduplicator) Dismiss(ctx context.Context, id int64) (*store.DuplicatePair, error) {
+	pair, err := d.store.Dismiss(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordDuplicatesResolvedTotal.WithLabelValues(store.DuplicateDismissed).Inc()
+
+	d.log.Info("Dismissed duplicate records",
+		zap.Int64("duplicate_id", id),
+		zap.String("list", pair.List),
+		zap.String("dismissed_by", store.Actor(ctx)))
+	return pair, nil
+}
@@ -0,0 +1,96 @@
+package dedup
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/testutil"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// duplicateStore serves one pair and counts detections
+type duplicateStore struct {
+	store.DuplicateStore
+	pair *store.DuplicatePair
+	// found is what Detect finds; nil as while another replica detects
+	found    map[string]int64
+	detected int
+}
+
+func (s *duplicateStore) Detect(ctx context.Context, minSimilarity float64) (map[string]int64, error) {
+	s.detected++
+	return s.found, nil
+}
+
+func (s *duplicateStore) Stats(ctx context.Context) (*store.DuplicateStats, error) {
+	return &store.DuplicateStats{Pending: 1, Duplicated: 2, Live: 10}, nil
+}
+
+func (s *duplicateStore) Merge(ctx context.Context, id, keep int64) (*store.DuplicatePair, error) {
+	if id != s.pair.ID {
+		return nil, store.ErrDuplicateNotFound
+	}
+	return s.pair, nil
+}
+
+func newDeduplicator(duplicates store.DuplicateStore, svc *testutil.Service) *Deduplicator {
+	cfg := &config.Config{}
+	cfg.Dedup.Enabled = true
+	cfg.Dedup.NameSimilarity = 0.85
+	return NewDeduplicator(cfg, duplicates, svc, zap.NewNop())
+}
+
+func TestNewDeduplicatorDisabled(t *testing.T) {
+	if d := NewDeduplicator(&config.Config{}, &duplicateStore{}, &testutil.Service{}, zap.NewNop()); d != nil {
+		t.Errorf("NewDeduplicator() = %v, want nil when DEDUP_ENABLED is off", d)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		found map[string]int64
+	}{
+		{name: "found pairs", found: map[string]int64{store.DuplicateNIK: 2, store.DuplicateNameBirthDate: 1}},
+		{name: "another replica detecting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duplicates := &duplicateStore{found: tt.found}
+			d := newDeduplicator(duplicates, &testutil.Service{})
+			if err := d.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if duplicates.detected != 1 {
+				t.Errorf("detected %d times, want once", duplicates.detected)
+			}
+		})
+	}
+}
+
+func TestMergeInvalidatesBothNIKs(t *testing.T) {
+	pair := &store.DuplicatePair{
+		ID:        6,
+		List:      "internal",
+		Record:    &store.BlacklistRecord{ID: 1, NIK: "3171011505900001"},
+		Duplicate: &store.BlacklistRecord{ID: 2, NIK: "3171-0115-0590-0001"},
+	}
+	var invalidated []string
+	d := newDeduplicator(&duplicateStore{pair: pair}, &testutil.Service{
+		InvalidateRecordsFunc: func(ctx context.Context, niks ...string) error {
+			invalidated = append(invalidated, niks...)
+			return nil
+		},
+	})
+
+	if _, err := d.Merge(context.Background(), 6, 1); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if want := []string{"3171011505900001", "3171-0115-0590-0001"}; !reflect.DeepEqual(invalidated, want) {
+		t.Errorf("invalidated %q, want %q", invalidated, want)
+	}
+}
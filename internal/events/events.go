@@ -0,0 +1,216 @@
+// Package events publishes a structured event for every screening decision
+// to Kafka, for the risk data lake, and re-screening alerts to a topic of
+// their own. Events are batched and sent in the background, so a slow or
+// unavailable broker never delays a check; failed batches are logged and
+// counted, not retried.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/pkg/config"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// Screening describes one screening decision. It carries no PII: the subject
+// is identified by a hash only.
+type Screening struct {
+	RequestID string `json:"request_id,omitempty"`
+	// SubjectHash is the SHA-256 of the subject's NIK, or without one the
+	// SHA-256 of their normalized name and birth date
+	SubjectHash string `json:"subject_hash"`
+	Blacklisted bool   `json:"blacklisted"`
+	Decision    string `json:"decision"`
+	// Outcome is hit, clear or unknown; consumers must not read an unknown
+	// outcome as clear
+	Outcome       string          `json:"outcome"`
+	UnknownReason string          `json:"unknown_reason,omitempty"`
+	MatchType     string          `json:"match_type"`
+	Score         float64         `json:"score"`
+	Confidence    float64         `json:"confidence"`
+	Profile       string          `json:"profile"`
+	PolicyVersion string          `json:"policy_version"`
+	Lists         []ListScreening `json:"lists"`
+	Caller        string          `json:"caller"`
+	LatencyMS     float64         `json:"latency_ms"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+}
+
+// ListScreening is the outcome on a single list
+type ListScreening struct {
+	List      string  `json:"list"`
+	Matched   bool    `json:"matched"`
+	MatchType string  `json:"match_type"`
+	Score     float64 `json:"score"`
+	Decision  string  `json:"decision"`
+	Outcome   string  `json:"outcome"`
+	// UnknownReason is set when the outcome is unknown
+	UnknownReason string `json:"unknown_reason,omitempty"`
+}
+
+// RescreenAlert reports a subject cleared earlier that matches on
+// re-screening. Like a screening event it carries no PII.
+type RescreenAlert struct {
+	// SubjectID is the subject's enrolment, and SubjectHash identifies it as
+	// its screening events do
+	SubjectID       int64           `json:"subject_id"`
+	SubjectHash     string          `json:"subject_hash"`
+	Tenant          string          `json:"tenant,omitempty"`
+	PreviousOutcome string          `json:"previous_outcome"`
+	Outcome         string          `json:"outcome"`
+	MatchType       string          `json:"match_type"`
+	Decision        string          `json:"decision"`
+	Score           float64         `json:"score"`
+	PolicyVersion   string          `json:"policy_version"`
+	Lists           []ListScreening `json:"lists"`
+	// EnrolledAt is when the subject was first screened with consent
+	EnrolledAt time.Time `json:"enrolled_at"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// queueSize bounds the events waiting for the writer. Events arriving while
+// it is full are dropped and counted as failures rather than blocking checks.
+const queueSize = 10000
+
+// Publisher sends screening events and re-screening alerts to Kafka
+type Publisher struct {
+	writer *kafka.Writer
+	queue  chan kafka.Message
+	done   chan struct{}
+	// drained is closed once the queue has been handed to the writer
+	drained chan struct{}
+	log     *zap.Logger
+
+	// topic receives screening events and rescreenTopic re-screening alerts
+	topic         string
+	rescreenTopic string
+}
+
+// NewPublisher creates a publisher for the configured brokers and topic. It
+// returns nil when no brokers are configured; a nil publisher drops events.
+func NewPublisher(cfg *config.Config, log *zap.Logger) *Publisher {
+	var brokers []string
+	for _, broker := range strings.Split(cfg.Events.KafkaBrokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	if len(brokers) == 0 {
+		return nil
+	}
+
+	p := &Publisher{
+		topic:         cfg.Events.KafkaTopic,
+		rescreenTopic: cfg.Events.RescreenTopic,
+		queue:         make(chan kafka.Message, queueSize),
+		done:          make(chan struct{}),
+		drained:       make(chan struct{}),
+		log:           log,
+	}
+	// Each message names its topic
+	p.writer = &kafka.Writer{
+		Addr: kafka.TCP(brokers...),
+		// Keying by subject keeps each subject's events in order on one partition
+		Balancer:     &kafka.Hash{},
+		BatchSize:    cfg.Events.BatchSize,
+		BatchTimeout: cfg.Events.BatchTimeout,
+		RequiredAcks: kafka.RequireOne,
+		Async:        true,
+		Completion:   p.completed,
+	}
+	go p.run()
+	return p
+}
+
+// Publish queues an event for the next batch without waiting on the brokers
+func (p *Publisher) Publish(ctx context.Context, event *Screening) {
+	if p == nil {
+		return
+	}
+	p.enqueue(p.topic, event.SubjectHash, event)
+}
+
+// PublishRescreenAlert queues a re-screening alert like Publish does an event
+func (p *Publisher) PublishRescreenAlert(ctx context.Context, alert *RescreenAlert) {
+	if p == nil {
+		return
+	}
+	p.enqueue(p.rescreenTopic, alert.SubjectHash, alert)
+}
+
+// enqueue encodes value for topic, keyed by subject, and queues it
+func (p *Publisher) enqueue(topic, subject string, value interface{}) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		metrics.EventPublishFailuresTotal.Inc()
+		p.log.Error("Error encoding event", zap.String("topic", topic), zap.Error(err))
+		return
+	}
+	select {
+	case <-p.done:
+		metrics.EventPublishFailuresTotal.Inc()
+	case p.queue <- kafka.Message{Topic: topic, Key: []byte(subject), Value: encoded}:
+	default:
+		metrics.EventPublishFailuresTotal.Inc()
+		p.log.Warn("Event queue is full, dropping event", zap.String("topic", topic))
+	}
+}
+
+// run hands queued events to the writer until the publisher is closed. The
+// writer may look up partitions on the brokers first, which is why this
+// happens off the request path.
+func (p *Publisher) run() {
+	defer close(p.drained)
+	for {
+		select {
+		case msg := <-p.queue:
+			p.write(msg)
+		case <-p.done:
+			for {
+				select {
+				case msg := <-p.queue:
+					p.write(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write hands one event to the writer. Delivery errors are reported to
+// completed; errors here come from the partition lookup.
+func (p *Publisher) write(msg kafka.Message) {
+	if err := p.writer.WriteMessages(context.Background(), msg); err != nil {
+		metrics.EventPublishFailuresTotal.Inc()
+		p.log.Warn("Error publishing event", zap.String("topic", msg.Topic), zap.Error(err))
+	}
+}
+
+// completed counts the events of a batch the brokers didn't accept
+func (p *Publisher) completed(messages []kafka.Message, err error) {
+	if err == nil {
+		return
+	}
+	metrics.EventPublishFailuresTotal.Add(float64(len(messages)))
+	p.log.Warn("Error publishing events",
+		zap.String("topic", messages[0].Topic),
+		zap.Int("events", len(messages)),
+		zap.Error(err))
+}
+
+// Close sends the queued events and closes the connections
+func (p *Publisher) Close() error {
+	if p == nil {
+		return nil
+	}
+	close(p.done)
+	<-p.drained
+	return p.writer.Close()
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/pkg/config"
+
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+func TestNewPublisherWithoutBrokers(t *testing.T) {
+	cfg := &config.Config{Events: config.EventsConfig{KafkaBrokers: " , "}}
+	p := NewPublisher(cfg, zap.NewNop())
+	if p != nil {
+		t.Fatal("NewPublisher() without brokers != nil")
+	}
+	// A nil publisher drops events
+	p.Publish(context.Background(), &Screening{SubjectHash: "abc"})
+	p.PublishRescreenAlert(context.Background(), &RescreenAlert{SubjectHash: "abc"})
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+// newQueue returns a publisher whose queue holds size events and is read by
+// the test rather than a writer
+func newQueue(size int) *Publisher {
+	return &Publisher{
+		topic:         "screenings",
+		rescreenTopic: "rescreens",
+		queue:         make(chan kafka.Message, size),
+		done:          make(chan struct{}),
+		log:           zap.NewNop(),
+	}
+}
+
+func TestPublishQueuesEventsKeyedBySubject(t *testing.T) {
+	p := newQueue(2)
+	p.Publish(context.Background(), &Screening{SubjectHash: "subject-1", Outcome: "clear"})
+	p.PublishRescreenAlert(context.Background(), &RescreenAlert{SubjectID: 7, SubjectHash: "subject-2", Outcome: "hit"})
+
+	tests := []struct {
+		topic, key, outcome string
+	}{
+		{"screenings", "subject-1", "clear"},
+		{"rescreens", "subject-2", "hit"},
+	}
+	for _, tt := range tests {
+		msg := <-p.queue
+		var event struct {
+			SubjectHash string `json:"subject_hash"`
+			Outcome     string `json:"outcome"`
+		}
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			t.Fatalf("decoding %s event: %v", msg.Topic, err)
+		}
+		if msg.Topic != tt.topic || string(msg.Key) != tt.key || event.SubjectHash != tt.key || event.Outcome != tt.outcome {
+			t.Errorf("queued %s keyed %s with %+v, want %s keyed %s with outcome %s", msg.Topic, msg.Key, event, tt.topic, tt.key, tt.outcome)
+		}
+	}
+}
+
+func TestPublishDropsEventsWhenQueueIsFull(t *testing.T) {
+	p := newQueue(1)
+	failures := promtest.ToFloat64(metrics.EventPublishFailuresTotal)
+	p.Publish(context.Background(), &Screening{SubjectHash: "subject-1"})
+	p.Publish(context.Background(), &Screening{SubjectHash: "subject-2"})
+
+	if got := promtest.ToFloat64(metrics.EventPublishFailuresTotal) - failures; got != 1 {
+		t.Errorf("failures counted = %v, want 1", got)
+	}
+	if msg := <-p.queue; string(msg.Key) != "subject-1" {
+		t.Errorf("queued event keyed %s, want subject-1", msg.Key)
+	}
+}
+
+func TestCloseHandsQueuedEventsToWriter(t *testing.T) {
+	// Nothing listens there, so every event fails and is counted
+	cfg := &config.Config{Events: config.EventsConfig{
+		KafkaBrokers: "127.0.0.1:1",
+		KafkaTopic:   "screenings",
+		BatchSize:    100,
+		BatchTimeout: 10 * time.Millisecond,
+	}}
+	p := NewPublisher(cfg, zap.NewNop())
+	failures := promtest.ToFloat64(metrics.EventPublishFailuresTotal)
+	for i := 0; i < 3; i++ {
+		p.Publish(context.Background(), &Screening{SubjectHash: "subject"})
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := promtest.ToFloat64(metrics.EventPublishFailuresTotal) - failures; got != 3 {
+		t.Errorf("failures counted = %v, want 3 once closed", got)
+	}
+}
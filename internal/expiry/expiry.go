@@ -0,0 +1,99 @@
+// Package expiry retires temporary blacklist records. Records that fraud ops
+// add during an investigation expire after a set number of days unless they
+// are confirmed, and owners are notified ahead of time and on expiry so that
+// entries neither lapse silently nor linger.
+package expiry
+
+import (
+	"context"
+	"time"
+
+	"blacklist-check/internal/alert"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Notice describes a temporary record about to expire or just expired
+type Notice struct {
+	Event     string    `json:"event"`
+	RecordID  int64     `json:"record_id"`
+	List      string    `json:"list"`
+	NIK       string    `json:"nik"`
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Sweeper notifies about and expires temporary records. Each record is
+// claimed by a conditional update, so replicas can all sweep without
+// notifying twice.
+type Sweeper struct {
+	service      *service.BlacklistService
+	notifyBefore time.Duration
+	alerts       *alert.Poster
+	log          *zap.Logger
+}
+
+// NewSweeper creates a new temporary record sweeper
+func NewSweeper(cfg *config.Config, service *service.BlacklistService, log *zap.Logger) *Sweeper {
+	return &Sweeper{
+		service:      service,
+		notifyBefore: cfg.Temporary.NotifyBefore,
+		alerts:       alert.NewPoster(cfg.Temporary.AlertWebhook, "expiry notice", log),
+		log:          log,
+	}
+}
+
+// Sweep sends the advance notices due and expires the records past their
+// expiry, returning every notice sent
+func (s *Sweeper) Sweep(ctx context.Context) ([]*Notice, error) {
+	var notices []*Notice
+
+	expiring, err := s.service.ExpiringRecords(ctx, s.notifyBefore)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range expiring {
+		notices = append(notices, s.notify(service.TemporaryExpiring, record))
+	}
+
+	expired, err := s.service.ExpireRecords(store.WithActor(ctx, "system"))
+	if err != nil {
+		return notices, err
+	}
+	for _, record := range expired {
+		notices = append(notices, s.notify(service.TemporaryExpired, record))
+	}
+	return notices, nil
+}
+
+// notify logs a notice and posts it to the alert webhook without holding up the sweep
+func (s *Sweeper) notify(event string, record *store.BlacklistRecord) *Notice {
+	notice := &Notice{
+		Event:    event,
+		RecordID: record.ID,
+		List:     record.List,
+		NIK:      record.NIK,
+		Name:     record.Name,
+	}
+	if record.ExpiresAt != nil {
+		notice.ExpiresAt = *record.ExpiresAt
+	}
+
+	metrics.TemporaryRecordEventsTotal.WithLabelValues(event).Inc()
+	s.log.Warn("Temporary blacklist record "+event,
+		zap.Int64("record_id", notice.RecordID),
+		zap.String("list", notice.List),
+		zap.Time("expires_at", notice.ExpiresAt))
+
+	s.alerts.Post(notice)
+	return notice
+}
+
+// Wait waits for notices still being delivered to the webhook, or until ctx is done
+func (s *Sweeper) Wait(ctx context.Context) error {
+	return s.alerts.Wait(ctx)
+}
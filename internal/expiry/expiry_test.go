@@ -0,0 +1,132 @@
+package expiry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+func TestSweep(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	expiring := &store.BlacklistRecord{ID: 1, List: "internal", NIK: "3171011505900001", Name: "John Doe", ExpiresAt: &expiresAt}
+	expired := &store.BlacklistRecord{ID: 2, List: "internal", NIK: "3171011505900002", Name: "Jane Roe", ExpiresAt: &expiresAt}
+
+	var mu sync.Mutex
+	var delivered []Notice
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notice Notice
+		if err := json.NewDecoder(r.Body).Decode(&notice); err != nil {
+			t.Errorf("decoding notice: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, notice)
+	}))
+	defer hook.Close()
+
+	// The expired record stays listed until the sweep expires it
+	var gone bool
+	records := &testutil.BlacklistStore{
+		GetByNIKFunc: func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if nik == expired.NIK && !gone {
+				return expired, nil
+			}
+			return nil, nil
+		},
+		GetByFuzzyMatchFunc: func(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*store.BlacklistRecord, bool, error) {
+			return nil, false, nil
+		},
+		GetByPhoneticFunc: func(ctx context.Context, list, name string, birthDate *time.Time) ([]*store.BlacklistRecord, error) {
+			return nil, nil
+		},
+		MarkExpiringFunc: func(ctx context.Context, within time.Duration) ([]*store.BlacklistRecord, error) {
+			return []*store.BlacklistRecord{expiring}, nil
+		},
+		ExpireFunc: func(ctx context.Context) ([]*store.BlacklistRecord, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			gone = true
+			return []*store.BlacklistRecord{expired}, nil
+		},
+	}
+	cfg := testutil.Config(t)
+	cfg.Match.Lists = "internal"
+	cfg.Temporary.AlertWebhook = hook.URL
+	s, err := service.NewBlacklistService(service.Params{Config: cfg, Cache: testutil.NewCache(), Store: records, Log: zap.NewNop()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sweeper := NewSweeper(cfg, s, zap.NewNop())
+
+	check := service.CheckRequest{Name: expired.Name, NIK: expired.NIK}
+	if result, err := s.CheckBlacklist(context.Background(), check); err != nil || !result.Blacklisted {
+		t.Fatalf("CheckBlacklist() before the sweep = %+v, %v, want blacklisted", result, err)
+	}
+
+	notices, err := sweeper.Sweep(context.Background())
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if len(notices) != 2 ||
+		notices[0].Event != service.TemporaryExpiring || notices[0].RecordID != expiring.ID ||
+		notices[1].Event != service.TemporaryExpired || notices[1].RecordID != expired.ID {
+		t.Fatalf("Sweep() = %+v, want an expiring notice for 1 and an expired one for 2", notices)
+	}
+	if !notices[0].ExpiresAt.Equal(expiresAt) {
+		t.Errorf("notice expires at %s, want %s", notices[0].ExpiresAt, expiresAt)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sweeper.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if len(delivered) != 2 {
+		t.Errorf("delivered %d notices, want 2", len(delivered))
+	}
+
+	// The cached hit on the expired record is invalidated
+	if result, err := s.CheckBlacklist(context.Background(), check); err != nil || result.Blacklisted {
+		t.Errorf("CheckBlacklist() after the sweep = %+v, %v, want cleared", result, err)
+	}
+}
+
+func TestSweepStopsOnError(t *testing.T) {
+	errDB := errors.New("connection reset")
+	expired := false
+	records := &testutil.BlacklistStore{
+		MarkExpiringFunc: func(ctx context.Context, within time.Duration) ([]*store.BlacklistRecord, error) {
+			return nil, errDB
+		},
+		ExpireFunc: func(ctx context.Context) ([]*store.BlacklistRecord, error) {
+			expired = true
+			return nil, nil
+		},
+	}
+	cfg := testutil.Config(t)
+	s, err := service.NewBlacklistService(service.Params{Config: cfg, Cache: testutil.NewCache(), Store: records, Log: zap.NewNop()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notices, err := NewSweeper(cfg, s, zap.NewNop()).Sweep(context.Background())
+	if !errors.Is(err, errDB) || len(notices) != 0 {
+		t.Errorf("Sweep() = %+v, %v, want %v", notices, err, errDB)
+	}
+	if expired {
+		t.Error("Sweep() expired records after failing to notify")
+	}
+}
@@ -0,0 +1,336 @@
+// Package format renders screening results the way each client wants its
+// dates, timestamps and identifiers, so downstream document generation can
+// use them as they are instead of re-formatting them. Formats are configured
+// per caller; callers without one get responses unchanged.
+package format
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/usage"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Date styles
+const (
+	// DateISO is 1980-05-17
+	DateISO = "iso"
+	// DateDMY is 17/05/1980
+	DateDMY = "dmy"
+	// DateMDY is 05/17/1980
+	DateMDY = "mdy"
+	// DateLong is 17 May 1980, with the month named in the response locale
+	DateLong = "long"
+)
+
+// Identifier styles
+const (
+	// IDsFull leaves identifiers as they are
+	IDsFull = "full"
+	// IDsLast4 masks all but the last four characters
+	IDsLast4 = "last4"
+	// IDsFirst6 masks all but the first six characters, which for a NIK are
+	// the region it was issued in
+	IDsFirst6 = "first6"
+	// IDsHidden masks every character
+	IDsHidden = "hidden"
+)
+
+var dateLayouts = map[string]string{
+	DateISO: "2006-01-02",
+	DateDMY: "02/01/2006",
+	DateMDY: "01/02/2006",
+}
+
+// months names the months for long dates per locale
+var months = map[string][12]string{
+	"en": {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	"id": {"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"},
+}
+
+// idFields are the JSON fields holding identifiers
+var idFields = map[string]bool{"nik": true, "registration_number": true}
+
+// Format is how a client wants results rendered
+type Format struct {
+	Date string
+	// Location is the time zone timestamps are given in; nil leaves them as
+	// stored. Dates aren't instants and are never shifted.
+	Location *time.Location
+	IDs      string
+}
+
+// Parse reads a format from <option>:<value> entries separated by "|", where
+// the options are date, timezone and ids. Options left out keep their
+// defaults: ISO dates, timestamps as stored and full identifiers.
+func Parse(spec string) (*Format, error) {
+	f := &Format{Date: DateISO, IDs: IDsFull}
+	for _, entry := range strings.Split(spec, "|") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		option, value, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid format option %q, expected option:value", entry)
+		}
+		switch option {
+		case "date":
+			if _, ok := dateLayouts[value]; !ok && value != DateLong {
+				return nil, fmt.Errorf("unknown date style %q", value)
+			}
+			f.Date = value
+		case "timezone":
+			location, err := time.LoadLocation(value)
+			if err != nil {
+				return nil, fmt.Errorf("unknown time zone %q: %w", value, err)
+			}
+			f.Location = location
+		case "ids":
+			switch value {
+			case IDsFull, IDsLast4, IDsFirst6, IDsHidden:
+			default:
+				return nil, fmt.Errorf("unknown identifier style %q", value)
+			}
+			f.IDs = value
+		default:
+			return nil, fmt.Errorf("unknown format option %q", option)
+		}
+	}
+	return f, nil
+}
+
+// FormatDate renders a date in the format's style, naming months in locale
+func (f *Format) FormatDate(t time.Time, locale string) string {
+	if f.Date != DateLong {
+		return t.Format(dateLayouts[f.Date])
+	}
+	names, ok := months[locale]
+	if !ok {
+		names = months[reason.DefaultLocale]
+	}
+	return fmt.Sprintf("%d %s %d", t.Day(), names[t.Month()-1], t.Year())
+}
+
+// FormatTimestamp renders a timestamp as RFC 3339 in the format's time zone
+func (f *Format) FormatTimestamp(t time.Time) string {
+	if f.Location != nil {
+		t = t.In(f.Location)
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// MaskID masks an identifier in the format's style
+func (f *Format) MaskID(id string) string {
+	keepFirst, keepLast := 0, 0
+	switch f.IDs {
+	case IDsFull:
+		return id
+	case IDsLast4:
+		keepLast = 4
+	case IDsFirst6:
+		keepFirst = 6
+	}
+	runes := []rune(id)
+	for i := range runes {
+		if i >= keepFirst && i < len(runes)-keepLast {
+			runes[i] = '*'
+		}
+	}
+	return string(runes)
+}
+
+// Formats selects the format for each caller
+type Formats struct {
+	callers map[string]*Format
+}
+
+// NewFormats parses <caller>=<format> entries separated by ";", each format
+// as read by Parse
+func NewFormats(spec string) (*Formats, error) {
+	fs := &Formats{callers: make(map[string]*Format)}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		caller, formatSpec, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(caller) == "" {
+			return nil, fmt.Errorf("invalid caller format %q, expected caller=format", entry)
+		}
+		f, err := Parse(formatSpec)
+		if err != nil {
+			return nil, fmt.Errorf("error loading format for caller %q: %w", caller, err)
+		}
+		fs.callers[strings.TrimSpace(caller)] = f
+	}
+	return fs, nil
+}
+
+// For returns the caller's format, or nil when it has none
+func (fs *Formats) For(caller string) *Format {
+	return fs.callers[caller]
+}
+
+type formatKey struct{}
+
+// WithFormat returns a context carrying the format for the response
+func WithFormat(ctx context.Context, f *Format) context.Context {
+	return context.WithValue(ctx, formatKey{}, f)
+}
+
+// FromContext returns the format for the response, or nil when the caller
+// has none
+func FromContext(ctx context.Context) *Format {
+	f, _ := ctx.Value(formatKey{}).(*Format)
+	return f
+}
+
+// Formatter is the response formatting middleware
+type Formatter struct {
+	formats *Formats
+	log     *zap.Logger
+}
+
+// NewFormatter creates the response formatting middleware
+func NewFormatter(cfg *config.Config, log *zap.Logger) (*Formatter, error) {
+	formats, err := NewFormats(cfg.Response.CallerFormats)
+	if err != nil {
+		return nil, fmt.Errorf("error loading response formats: %w", err)
+	}
+	return &Formatter{formats: formats, log: log}, nil
+}
+
+// Middleware rewrites the dates, timestamps and identifiers of JSON
+// responses in the caller's format, and passes the format on in the request
+// context for handlers writing other content types. Only JSON responses to
+// callers with a format are buffered; others, such as CSV downloads, stream.
+func (fm *Formatter) Middleware(next http.Handler) http.Handler {
+	if len(fm.formats.callers) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f := fm.formats.For(usage.Caller(r.Context()))
+		if f == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(WithFormat(r.Context(), f))
+
+		bw := &buffered{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+		if bw.passthrough {
+			return
+		}
+
+		body := bw.buf.Bytes()
+		if bw.decided {
+			locale := w.Header().Get("Content-Language")
+			if locale == "" {
+				locale = reason.Negotiate(r.Header.Get("Accept-Language"))
+			}
+			if formatted, err := f.rewrite(body, locale); err != nil {
+				fm.log.Warn("Error formatting response, sending it unformatted", zap.Error(err))
+			} else {
+				body = formatted
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(bw.status)
+		w.Write(body)
+	})
+}
+
+// rewrite formats the fields of a JSON body wherever they are nested
+func (f *Format) rewrite(body []byte, locale string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.NewEncoder(&out).Encode(f.walk(value, locale)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// walk formats birth_date as a date, *_at fields as timestamps and
+// identifier fields as identifiers
+func (f *Format) walk(value interface{}, locale string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			s, ok := field.(string)
+			switch {
+			case ok && key == "birth_date":
+				if t, err := time.Parse(time.RFC3339, s); err == nil {
+					v[key] = f.FormatDate(t, locale)
+				}
+			case ok && strings.HasSuffix(key, "_at"):
+				if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					v[key] = f.FormatTimestamp(t)
+				}
+			case ok && idFields[key]:
+				v[key] = f.MaskID(s)
+			default:
+				v[key] = f.walk(field, locale)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = f.walk(v[i], locale)
+		}
+	}
+	return value
+}
+
+// buffered holds a JSON response back until it has been formatted. Whether
+// the response is JSON is decided by its Content-Type once the handler
+// starts writing; anything else is passed straight through.
+type buffered struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+	// decided is set once the handler starts writing, and passthrough if
+	// the response isn't JSON
+	decided     bool
+	passthrough bool
+}
+
+func (b *buffered) decide() {
+	if b.decided {
+		return
+	}
+	b.decided = true
+	b.passthrough = !strings.HasPrefix(b.Header().Get("Content-Type"), "application/json")
+}
+
+func (b *buffered) WriteHeader(status int) {
+	b.decide()
+	if b.passthrough {
+		b.ResponseWriter.WriteHeader(status)
+		return
+	}
+	b.status = status
+}
+
+func (b *buffered) Write(p []byte) (int, error) {
+	b.decide()
+	if b.passthrough {
+		return b.ResponseWriter.Write(p)
+	}
+	return b.buf.Write(p)
+}
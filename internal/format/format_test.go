@@ -0,0 +1,166 @@
+package format
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/usage"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		wantDate string
+		wantIDs  string
+		wantZone string
+		wantErr  bool
+	}{
+		{"defaults", "", DateISO, IDsFull, "", false},
+		{"every option", "date:long|timezone:Asia/Jakarta|ids:last4", DateLong, IDsLast4, "Asia/Jakarta", false},
+		{"spaces around entries", " date:dmy | ids:hidden ", DateDMY, IDsHidden, "", false},
+		{"unknown date style", "date:julian", "", "", "", true},
+		{"unknown time zone", "timezone:Mars/Olympus", "", "", "", true},
+		{"unknown identifier style", "ids:first4", "", "", "", true},
+		{"unknown option", "currency:idr", "", "", "", true},
+		{"missing value", "date", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Parse(%q) = %+v, want error", tt.spec, f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			zone := ""
+			if f.Location != nil {
+				zone = f.Location.String()
+			}
+			if f.Date != tt.wantDate || f.IDs != tt.wantIDs || zone != tt.wantZone {
+				t.Errorf("Parse(%q) = %s, %s, %q, want %s, %s, %q", tt.spec, f.Date, f.IDs, zone, tt.wantDate, tt.wantIDs, tt.wantZone)
+			}
+		})
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(1980, 5, 17, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		style, locale, want string
+	}{
+		{DateISO, "en", "1980-05-17"},
+		{DateDMY, "en", "17/05/1980"},
+		{DateMDY, "en", "05/17/1980"},
+		{DateLong, "en", "17 May 1980"},
+		{DateLong, "id", "17 Mei 1980"},
+		{DateLong, "fr", "17 May 1980"},
+	}
+	for _, tt := range tests {
+		f := &Format{Date: tt.style}
+		if got := f.FormatDate(date, tt.locale); got != tt.want {
+			t.Errorf("FormatDate() in %s, %s = %q, want %q", tt.style, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestMaskID(t *testing.T) {
+	const nik = "3171230101900001"
+	tests := []struct {
+		style, want string
+	}{
+		{IDsFull, nik},
+		{IDsLast4, "************0001"},
+		{IDsFirst6, "317123**********"},
+		{IDsHidden, "****************"},
+	}
+	for _, tt := range tests {
+		f := &Format{IDs: tt.style}
+		if got := f.MaskID(nik); got != tt.want {
+			t.Errorf("MaskID() in %s = %q, want %q", tt.style, got, tt.want)
+		}
+	}
+	// Shorter than what is kept, nothing is masked
+	if got := (&Format{IDs: IDsLast4}).MaskID("123"); got != "123" {
+		t.Errorf("MaskID(\"123\") = %q, want it unmasked", got)
+	}
+}
+
+func TestNewFormats(t *testing.T) {
+	fs, err := NewFormats("partner=date:dmy; ops = ids:hidden")
+	if err != nil {
+		t.Fatalf("NewFormats() error = %v", err)
+	}
+	if f := fs.For("partner"); f == nil || f.Date != DateDMY {
+		t.Errorf("For(partner) = %+v, want dmy dates", f)
+	}
+	if f := fs.For("ops"); f == nil || f.IDs != IDsHidden {
+		t.Errorf("For(ops) = %+v, want hidden identifiers", f)
+	}
+	if f := fs.For("other"); f != nil {
+		t.Errorf("For(other) = %+v, want nil", f)
+	}
+
+	for _, spec := range []string{"partner", "=date:dmy", "partner=date:julian"} {
+		if _, err := NewFormats(spec); err == nil {
+			t.Errorf("NewFormats(%q) error = nil", spec)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	cfg := &config.Config{Response: config.ResponseConfig{
+		CallerFormats: "partner=date:long|timezone:Asia/Jakarta|ids:last4",
+	}}
+	fm, err := NewFormatter(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const body = `{"nik":"3171230101900001","birth_date":"1980-05-17T00:00:00Z","matches":[{"created_at":"2024-06-01T10:00:00Z"}]}`
+	tests := []struct {
+		name        string
+		caller      string
+		contentType string
+		want        string
+	}{
+		{
+			name:        "caller with a format",
+			caller:      "partner",
+			contentType: "application/json",
+			want:        `{"birth_date":"17 Mei 1980","matches":[{"created_at":"2024-06-01T17:00:00+07:00"}],"nik":"************0001"}` + "\n",
+		},
+		{"caller without a format", "other", "application/json", body},
+		{"not JSON", "partner", "text/csv", body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := fm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Language", "id")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(body))
+			}))
+			r := httptest.NewRequest("GET", "/", nil)
+			r = r.WithContext(usage.WithCaller(r.Context(), tt.caller))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusCreated {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusCreated)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %s, want %s", got, strings.TrimSpace(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Package gazetteer recognizes place names, so a check can warn about a
+// birth place that is likely misspelled or isn't a place at all, and tell
+// when two names are of the same place. The built-in list covers Indonesian
+// provinces, cities and the larger regencies; deployments screening people
+// born elsewhere, or wanting every regency and district, load more names
+// from a file.
+package gazetteer
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"blacklist-check/internal/nationalid"
+)
+
+//go:embed places.txt
+var builtin string
+
+// prefixes are administrative words written before a place name, which
+// don't tell places apart
+var prefixes = []string{"kota", "kabupaten", "kab", "provinsi", "prov", "kecamatan", "kec"}
+
+// Gazetteer is a set of known places and the names each is known by
+type Gazetteer struct {
+	// places maps the key of every known name to the name of its place
+	places map[string]string
+	// names lists the names of each place, by the key of the place's name
+	names map[string][]string
+}
+
+// New creates a gazetteer of the built-in places and the province names,
+// plus the places in the file at path, one per line, when path is set. A
+// line of the form "Place = Name, Name" gives the other names of a place; a
+// name listed again is moved to the place listed last. Blank lines and lines
+// starting with # are skipped.
+func New(path string) (*Gazetteer, error) {
+	g := &Gazetteer{places: make(map[string]string), names: make(map[string][]string)}
+	for _, province := range nationalid.Provinces {
+		g.add(province)
+	}
+	if err := g.read(strings.NewReader(builtin)); err != nil {
+		return nil, fmt.Errorf("error reading built-in places: %w", err)
+	}
+	if path == "" {
+		return g, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening gazetteer file: %w", err)
+	}
+	defer f.Close()
+	if err := g.read(f); err != nil {
+		return nil, fmt.Errorf("error reading gazetteer file %s: %w", path, err)
+	}
+	return g, nil
+}
+
+// read adds the place names listed in r
+func (g *Gazetteer) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		place, aliases, _ := strings.Cut(line, "=")
+		var names []string
+		for _, alias := range strings.Split(aliases, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				names = append(names, alias)
+			}
+		}
+		g.add(strings.TrimSpace(place), names...)
+	}
+	return scanner.Err()
+}
+
+// add makes a place known under its name and aliases
+func (g *Gazetteer) add(place string, aliases ...string) {
+	placeKey := key(place)
+	if placeKey == "" {
+		return
+	}
+	g.names[placeKey] = append([]string{place}, aliases...)
+	for _, name := range g.names[placeKey] {
+		if k := key(name); k != "" {
+			g.places[k] = place
+		}
+	}
+}
+
+// Known reports whether place names a known place. Case, punctuation,
+// spacing and administrative prefixes such as "Kota" or "Kab." are ignored,
+// so "KOTA TANJUNG PINANG" is Tanjungpinang.
+func (g *Gazetteer) Known(place string) bool {
+	_, ok := g.places[key(place)]
+	return ok
+}
+
+// Canonical returns the name of the place that place names, so "DKI
+// Jakarta" and "Jakarta Selatan" are both Jakarta, or "" for an unknown
+// place. A nil gazetteer knows no places.
+func (g *Gazetteer) Canonical(place string) string {
+	if g == nil {
+		return ""
+	}
+	return g.places[key(place)]
+}
+
+// Names returns every name of the place that place names, its own name
+// first, or nil for an unknown place
+func (g *Gazetteer) Names(place string) []string {
+	canonical := g.Canonical(place)
+	if canonical == "" {
+		return nil
+	}
+	return g.names[key(canonical)]
+}
+
+// Same reports whether a and b name the same place: they are equal, or both
+// are names of one known place. Without a gazetteer names are only the same
+// when equal.
+func (g *Gazetteer) Same(a, b string) bool {
+	if a == b {
+		return true
+	}
+	canonical := g.Canonical(a)
+	return canonical != "" && canonical == g.Canonical(b)
+}
+
+// key reduces a place name to its lowercase letters and digits, without
+// administrative prefixes
+func key(place string) string {
+	words := strings.FieldsFunc(strings.ToLower(place), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 && isPrefix(words[0]) {
+		words = words[1:]
+	}
+	return strings.Join(words, "")
+}
+
+// isPrefix reports whether word is an administrative prefix
+func isPrefix(word string) bool {
+	for _, prefix := range prefixes {
+		if word == prefix {
+			return true
+		}
+	}
+	return false
+}
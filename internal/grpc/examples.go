@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// checkMethod is the fully qualified Check RPC
+const checkMethod = "blacklist.BlacklistService/Check"
+
+//go:embed examples/*.json
+var examples embed.FS
+
+// WriteExamples prints the embedded example requests as ready-to-run grpcurl
+// and buf curl commands against addr. Both rely on server reflection.
+func WriteExamples(w io.Writer, addr string) error {
+	files, err := fs.Glob(examples, "examples/*.json")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		body, err := examples.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		payload := strings.TrimSpace(string(body))
+		name := strings.TrimSuffix(strings.TrimPrefix(file, "examples/"), ".json")
+
+		fmt.Fprintf(w, "# %s\n", name)
+		fmt.Fprintf(w, "grpcurl -plaintext -H \"x-api-key: $API_KEY\" -d @ %s %s <<'JSON'\n%s\nJSON\n\n", addr, checkMethod, payload)
+		fmt.Fprintf(w, "buf curl --protocol grpc --http2-prior-knowledge -H \"x-api-key: $API_KEY\" -d @- http://%s/%s <<'JSON'\n%s\nJSON\n\n", addr, checkMethod, payload)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"blacklist-check/internal/auth"
+	pb "blacklist-check/internal/grpc/proto"
+	"blacklist-check/internal/nationalid"
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/recovery"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/tenant"
+
+	"go.uber.org/zap"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the BlacklistService gRPC API on top of the blacklist service
+type Server struct {
+	pb.UnimplementedBlacklistServiceServer
+
+	service service.Service
+	log     *zap.Logger
+}
+
+// NewServer creates a new gRPC blacklist server
+func NewServer(service service.Service, log *zap.Logger) *Server {
+	return &Server{
+		service: service,
+		log:     log,
+	}
+}
+
+// Register builds a grpc.Server with the blacklist service, the panic
+// recovery, auth policy and tenant interceptors and, when enabled, server
+// reflection for grpcurl/buf curl. Reflection is a streaming RPC, so the
+// policy governs it like any other method.
+func (s *Server) Register(recoverer *recovery.Recoverer, policy *auth.Policy, tenants *tenant.Resolver, enableReflection bool) *grpclib.Server {
+	srv := grpclib.NewServer(
+		grpclib.ChainUnaryInterceptor(recoverer.UnaryInterceptor(), authInterceptor(policy, tenants)),
+		grpclib.ChainStreamInterceptor(recoverer.StreamInterceptor(), streamAuthInterceptor(policy, tenants)))
+	pb.RegisterBlacklistServiceServer(srv, s)
+	if enableReflection {
+		reflection.Register(srv)
+	}
+	return srv
+}
+
+// Check checks if a person is blacklisted
+func (s *Server) Check(ctx context.Context, req *pb.BlacklistRequest) (*pb.BlacklistResponse, error) {
+	if len(req.GetName()) < 3 {
+		return nil, status.Error(codes.InvalidArgument, "name must be at least 3 characters long")
+	}
+	if req.GetNik() != "" {
+		if _, err := nationalid.Parse(nationalid.DefaultCountry, req.GetNik()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	serviceReq := service.CheckRequest{
+		Name:       req.GetName(),
+		NIK:        req.GetNik(),
+		BirthPlace: req.GetBirthPlace(),
+	}
+	if req.GetBirthDate() != "" {
+		birthDate, err := time.Parse("2006-01-02", req.GetBirthDate())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "birth_date must be formatted as YYYY-MM-DD")
+		}
+		serviceReq.BirthDate = birthDate
+	}
+
+	result, err := s.service.CheckBlacklist(ctx, serviceReq)
+	var idErr *nationalid.Error
+	if errors.As(err, &idErr) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var timeoutErr *service.DependencyTimeoutError
+	if errors.As(err, &timeoutErr) {
+		s.log.Warn("Blacklist check timed out", zap.String("dependency", timeoutErr.Dependency), zap.Error(err))
+		return nil, status.Errorf(codes.DeadlineExceeded, "timed out waiting for %s", timeoutErr.Dependency)
+	}
+	var unavailableErr *service.DependencyUnavailableError
+	if errors.As(err, &unavailableErr) {
+		s.log.Warn("Blacklist check failed fast", zap.String("dependency", unavailableErr.Dependency), zap.Error(err))
+		return nil, status.Errorf(codes.Unavailable, "%s is unavailable", unavailableErr.Dependency)
+	}
+	if err != nil {
+		s.log.Error("Error checking blacklist", zap.Error(err))
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+
+	resp := &pb.BlacklistResponse{
+		Blacklisted: result.Blacklisted,
+		MatchType:   result.MatchType,
+	}
+	locale := reason.DefaultLocale
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("accept-language")) > 0 {
+		locale = reason.Negotiate(md.Get("accept-language")[0])
+	}
+	if description := result.Describe(locale); description != "" {
+		resp.Details = &pb.Individual{Description: description}
+	}
+	return resp, nil
+}
+
+// authInterceptor applies the HTTP auth policy to unary RPCs
+func authInterceptor(policy *auth.Policy, tenants *tenant.Resolver) grpclib.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (interface{}, error) {
+		ctx, err := authorize(ctx, info.FullMethod, policy, tenants)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamAuthInterceptor applies the HTTP auth policy to streaming RPCs, such
+// as server reflection
+func streamAuthInterceptor(policy *auth.Policy, tenants *tenant.Resolver) grpclib.StreamServerInterceptor {
+	return func(srv interface{}, ss grpclib.ServerStream, info *grpclib.StreamServerInfo, handler grpclib.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), info.FullMethod, policy, tenants)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorizedStream is a server stream carrying the caller's identity and tenant
+type authorizedStream struct {
+	grpclib.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorize applies the policy to an RPC, matching rules against the full
+// method name (e.g. /blacklist.BlacklistService/Check) and reading
+// credentials from metadata, then resolves the caller's tenant. It returns
+// ctx carrying both, or the status error to fail the RPC with.
+func authorize(ctx context.Context, fullMethod string, policy *auth.Policy, tenants *tenant.Resolver) (context.Context, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, fullMethod, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			for _, v := range values {
+				r.Header.Add(key, v)
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &tlsInfo.State
+		}
+	}
+
+	identity, code := policy.Authorize(r)
+	switch code {
+	case 0:
+	case http.StatusForbidden:
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	default:
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	if identity != nil {
+		ctx = auth.WithIdentity(ctx, identity)
+	}
+	ctx, err = tenants.Context(ctx)
+	if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return ctx, nil
+}
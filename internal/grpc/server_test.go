@@ -0,0 +1,143 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"blacklist-check/internal/auth"
+	pb "blacklist-check/internal/grpc/proto"
+	"blacklist-check/internal/recovery"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/tenant"
+	"blacklist-check/internal/testutil"
+
+	"go.uber.org/zap"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial serves the blacklist service with reflection under the policy in
+// spec and returns a client connection to it
+func dial(t *testing.T, spec string) *grpclib.ClientConn {
+	t.Helper()
+	return dialService(t, spec, &testutil.Service{
+		CheckBlacklistFunc: func(ctx context.Context, req service.CheckRequest) (*service.CheckResult, error) {
+			return &service.CheckResult{MatchType: service.MatchNone}, nil
+		},
+	})
+}
+
+// dialService is dial over the given blacklist service
+func dialService(t *testing.T, spec string, svc service.Service) *grpclib.ClientConn {
+	t.Helper()
+	keys, err := auth.NewAPIKeyAuthenticator("checker:checker-key:checker,auditor:auditor-key:auditor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := auth.NewPolicy(spec, zap.NewNop(), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := tenant.NewResolver(testutil.Config(t), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(svc, zap.NewNop()).Register(recovery.NewRecoverer(zap.NewNop()), policy, tenants, true)
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpclib.DialContext(context.Background(), "bufnet",
+		grpclib.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestPolicyGovernsEveryRPC(t *testing.T) {
+	const spec = "/blacklist.BlacklistService=api_key@checker;/grpc.reflection.v1.ServerReflection=api_key@auditor"
+	tests := []struct {
+		name string
+		key  string
+		wantUnary,
+		wantStream codes.Code
+	}{
+		{"no credentials", "", codes.Unauthenticated, codes.Unauthenticated},
+		{"bad key", "guess", codes.Unauthenticated, codes.Unauthenticated},
+		{"checker", "checker-key", codes.OK, codes.PermissionDenied},
+		{"auditor", "auditor-key", codes.PermissionDenied, codes.OK},
+	}
+	conn := dial(t, spec)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.key != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", tt.key)
+			}
+
+			_, err := pb.NewBlacklistServiceClient(conn).Check(ctx, &pb.BlacklistRequest{Name: "John Doe"})
+			if got := status.Code(err); got != tt.wantUnary {
+				t.Errorf("Check() code = %s, want %s", got, tt.wantUnary)
+			}
+
+			stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+			if err == nil {
+				err = stream.Send(&rpb.ServerReflectionRequest{
+					MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+				})
+			}
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if got := status.Code(err); got != tt.wantStream {
+				t.Errorf("ServerReflectionInfo() code = %s, want %s", got, tt.wantStream)
+			}
+		})
+	}
+}
+
+func TestReflectionRefusedWithoutPolicyEntry(t *testing.T) {
+	conn := dial(t, "/blacklist.BlacklistService=api_key@checker")
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "checker-key")
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}}); err == nil {
+		_, err = stream.Recv()
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("ServerReflectionInfo() error = %v, want Unauthenticated", err)
+		}
+	}
+}
+
+func TestPanicFailsOnlyItsRPC(t *testing.T) {
+	calls := 0
+	conn := dialService(t, "/=none", &testutil.Service{
+		CheckBlacklistFunc: func(ctx context.Context, req service.CheckRequest) (*service.CheckResult, error) {
+			calls++
+			if calls == 1 {
+				panic("boom")
+			}
+			return &service.CheckResult{MatchType: service.MatchNone}, nil
+		},
+	})
+	client := pb.NewBlacklistServiceClient(conn)
+
+	_, err := client.Check(context.Background(), &pb.BlacklistRequest{Name: "John Doe"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("Check() error = %v, want Internal", err)
+	}
+	if _, err := client.Check(context.Background(), &pb.BlacklistRequest{Name: "John Doe"}); err != nil {
+		t.Errorf("Check() after panic error = %v, want nil", err)
+	}
+}
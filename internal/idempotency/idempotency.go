@@ -0,0 +1,211 @@
+// Package idempotency lets callers safely retry requests that start work or
+// count towards metrics. A request carrying an Idempotency-Key header is
+// served once per caller and key; retries with the same key within the
+// replay window get the original response back instead of running again.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blacklist-check/api/types"
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/deadline"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/usage"
+	"blacklist-check/pkg/config"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// Header names
+const (
+	KeyHeader      = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxKeyLength matches the idempotency_key column
+const maxKeyLength = 255
+
+// claimTimeout is how long a pending claim blocks retries before it is
+// assumed abandoned. Requests time out well before it.
+const claimTimeout = 2 * time.Minute
+
+// Replayer is the idempotency key middleware
+type Replayer struct {
+	enabled bool
+	ttl     time.Duration
+	maxBody int64
+	store   store.IdempotencyStore
+	log     *zap.Logger
+}
+
+// NewReplayer creates the idempotency key middleware
+func NewReplayer(cfg *config.Config, store store.IdempotencyStore, log *zap.Logger) *Replayer {
+	return &Replayer{
+		enabled: cfg.Idempotency.Enabled,
+		ttl:     cfg.Idempotency.TTL,
+		maxBody: cfg.Idempotency.MaxBodyBytes,
+		store:   store,
+		log:     log,
+	}
+}
+
+// Prune deletes keys past the replay window
+func (rp *Replayer) Prune(ctx context.Context) error {
+	pruned, err := rp.store.Prune(ctx)
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		rp.log.Info("Pruned idempotency keys", zap.Int64("count", pruned))
+	}
+	return nil
+}
+
+// Middleware serves requests with an Idempotency-Key header at most once per
+// caller and key, replaying the stored response to retries. Requests without
+// the header pass straight through.
+func (rp *Replayer) Middleware(next http.Handler) http.Handler {
+	if !rp.enabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(KeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validKey(key) {
+			apierror.Validation(w, r, "Idempotency-Key must be 1 to 255 printable ASCII characters", nil)
+			return
+		}
+
+		// The body is read into memory to be fingerprinted
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, rp.maxBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apierror.Write(w, r, http.StatusRequestEntityTooLarge, types.ErrCodeValidation,
+					fmt.Sprintf("Requests with an Idempotency-Key must not exceed %d bytes", rp.maxBody), nil)
+				return
+			}
+			apierror.Validation(w, r, "Error reading request body", nil)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		caller := usage.Caller(r.Context())
+		hash := requestHash(r, body)
+		existing, err := rp.store.Claim(r.Context(), caller, key, hash, rp.ttl, claimTimeout)
+		if err != nil {
+			rp.log.Error("Error claiming idempotency key", zap.String("caller", caller), zap.Error(err))
+			apierror.Internal(w, r)
+			return
+		}
+		if existing != nil {
+			rp.replay(w, r, existing, hash)
+			return
+		}
+
+		captured := &bytes.Buffer{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(captured)
+		next.ServeHTTP(ww, r)
+
+		// The request's context may already be done; store the outcome regardless
+		ctx := context.WithoutCancel(r.Context())
+		status := ww.Status()
+		if status >= http.StatusInternalServerError || deadline.Exceeded(r.Context()) {
+			// Server errors, and answers cut short by the caller's deadline,
+			// are worth retrying, so free the key
+			if err := rp.store.Release(ctx, caller, key); err != nil {
+				rp.log.Error("Error releasing idempotency key", zap.String("caller", caller), zap.Error(err))
+			}
+			return
+		}
+		header := ww.Header()
+		if err := rp.store.Complete(ctx, &store.IdempotentResponse{
+			Caller:          caller,
+			Key:             key,
+			Status:          &status,
+			ContentType:     header.Get("Content-Type"),
+			ContentLanguage: header.Get("Content-Language"),
+			Location:        header.Get("Location"),
+			Body:            captured.Bytes(),
+		}); err != nil {
+			rp.log.Error("Error storing idempotent response", zap.String("caller", caller), zap.Error(err))
+		}
+	})
+}
+
+// replay answers a retry from the stored response
+func (rp *Replayer) replay(w http.ResponseWriter, r *http.Request, existing *store.IdempotentResponse, hash string) {
+	if existing.RequestHash != hash {
+		apierror.Conflict(w, r, "Idempotency-Key was already used for a different request")
+		return
+	}
+	if existing.Status == nil {
+		apierror.Conflict(w, r, "A request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	metrics.IdempotentReplaysTotal.WithLabelValues(routePattern(r)).Inc()
+	header := w.Header()
+	if existing.ContentType != "" {
+		header.Set("Content-Type", existing.ContentType)
+	}
+	if existing.ContentLanguage != "" {
+		header.Set("Content-Language", existing.ContentLanguage)
+	}
+	if existing.Location != "" {
+		header.Set("Location", existing.Location)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(existing.Body)))
+	header.Set(ReplayedHeader, "true")
+	w.WriteHeader(*existing.Status)
+	w.Write(existing.Body)
+}
+
+// requestHash fingerprints a request so a key can't be reused for another
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// routePattern returns the matched route, keeping the metric's labels bounded
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return r.URL.Path
+}
+
+// validKey reports whether key is 1 to 255 printable ASCII characters
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+// fakeStore keeps idempotency keys in memory, as the Postgres store does
+// without expiry
+type fakeStore struct {
+	mu   sync.Mutex
+	keys map[string]*store.IdempotentResponse
+}
+
+func (s *fakeStore) Claim(ctx context.Context, caller, key, requestHash string, ttl, claimTimeout time.Duration) (*store.IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.keys[caller+"\n"+key]; ok {
+		copied := *existing
+		return &copied, nil
+	}
+	s.keys[caller+"\n"+key] = &store.IdempotentResponse{Caller: caller, Key: key, RequestHash: requestHash}
+	return nil, nil
+}
+
+func (s *fakeStore) Complete(ctx context.Context, response *store.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := s.keys[response.Caller+"\n"+response.Key]
+	response.RequestHash = claimed.RequestHash
+	s.keys[response.Caller+"\n"+response.Key] = response
+	return nil
+}
+
+func (s *fakeStore) Release(ctx context.Context, caller, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, caller+"\n"+key)
+	return nil
+}
+
+func (s *fakeStore) Prune(ctx context.Context) (int64, error) { return 0, nil }
+
+func newReplayer(t *testing.T, maxBody int64) *Replayer {
+	t.Helper()
+	cfg := testutil.Config(t)
+	cfg.Idempotency.Enabled = true
+	cfg.Idempotency.TTL = time.Hour
+	cfg.Idempotency.MaxBodyBytes = maxBody
+	return NewReplayer(cfg, &fakeStore{keys: map[string]*store.IdempotentResponse{}}, zap.NewNop())
+}
+
+// request is a POST to path, carrying key unless it is empty
+type request struct {
+	path, key, body string
+}
+
+func (req request) serve(h http.Handler) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", req.path, strings.NewReader(req.body))
+	if req.key != "" {
+		r.Header.Set(KeyHeader, req.key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	first := request{"/api/v1/blacklist/check", "key-1", `{"name":"John Doe"}`}
+	tests := []struct {
+		name string
+		// status is what the handler answers every call with
+		status       int
+		retry        request
+		wantStatus   int
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{"same key and body", http.StatusCreated, first, http.StatusCreated, true, 1},
+		{"different body", http.StatusCreated, request{first.path, first.key, `{"name":"Jane Doe"}`}, http.StatusConflict, false, 1},
+		{"different path", http.StatusCreated, request{"/api/v1/blacklist/batch", first.key, first.body}, http.StatusConflict, false, 1},
+		{"other key", http.StatusCreated, request{first.path, "key-2", first.body}, http.StatusCreated, false, 2},
+		{"no key", http.StatusCreated, request{first.path, "", first.body}, http.StatusCreated, false, 2},
+		{"client error is kept", http.StatusBadRequest, first, http.StatusBadRequest, true, 1},
+		{"server error releases the key", http.StatusInternalServerError, first, http.StatusInternalServerError, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			h := newReplayer(t, 1<<20).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprintf(w, `{"call":%d}`, calls)
+			}))
+
+			original := first.serve(h)
+			retried := tt.retry.serve(h)
+			if retried.Code != tt.wantStatus {
+				t.Errorf("retry status = %d, want %d", retried.Code, tt.wantStatus)
+			}
+			if replayed := retried.Header().Get(ReplayedHeader) == "true"; replayed != tt.wantReplayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if tt.wantReplayed && retried.Body.String() != original.Body.String() {
+				t.Errorf("replayed body = %s, want %s", retried.Body, original.Body)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMiddlewarePending(t *testing.T) {
+	req := request{"/api/v1/blacklist/check", "key-1", `{"name":"John Doe"}`}
+	var h http.Handler
+	var retried *httptest.ResponseRecorder
+	calls := 0
+	h = newReplayer(t, 1<<20).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// Retried while the first request still holds the claim
+		if retried == nil {
+			retried = req.serve(h)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if w := req.serve(h); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if retried.Code != http.StatusConflict {
+		t.Errorf("retry status = %d, want %d while pending", retried.Code, http.StatusConflict)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestMiddlewareRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        request
+		wantStatus int
+	}{
+		{"body over the cap", request{"/api/v1/blacklist/check", "key-1", strings.Repeat("x", 65)}, http.StatusRequestEntityTooLarge},
+		{"body at the cap", request{"/api/v1/blacklist/check", "key-1", strings.Repeat("x", 64)}, http.StatusOK},
+		{"body over the cap without a key", request{"/api/v1/blacklist/check", "", strings.Repeat("x", 65)}, http.StatusOK},
+		{"key too long", request{"/api/v1/blacklist/check", strings.Repeat("k", maxKeyLength+1), "{}"}, http.StatusBadRequest},
+		{"key with control characters", request{"/api/v1/blacklist/check", "key\t1", "{}"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newReplayer(t, 64).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			if w := tt.req.serve(h); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// Package lifecycle runs the server's background components, such as its
+// periodic sweeps and the screening workers, and stops them on shutdown.
+// Every component is told to stop at once and then waited for up to its own
+// timeout, so one that hangs doesn't hold up the others and is reported by
+// name.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// StopError names the components that didn't stop in time or failed to
+type StopError struct {
+	Components []string
+}
+
+func (e *StopError) Error() string {
+	return fmt.Sprintf("components did not stop: %s", strings.Join(e.Components, ", "))
+}
+
+// Option configures a component
+type Option func(*settings)
+
+type settings struct {
+	timeout time.Duration
+}
+
+// Timeout waits up to d for the component to stop, instead of
+// SERVER_COMPONENT_STOP_TIMEOUT
+func Timeout(d time.Duration) Option {
+	return func(s *settings) {
+		s.timeout = d
+	}
+}
+
+// Manager tracks the running components and the functions that stop them
+type Manager struct {
+	timeout time.Duration
+	log     *zap.Logger
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	stopping bool
+	running  []*component
+	hooks    []*hook
+}
+
+type component struct {
+	name    string
+	timeout time.Duration
+	done    chan struct{}
+}
+
+type hook struct {
+	name    string
+	timeout time.Duration
+	stop    func(ctx context.Context) error
+}
+
+// NewManager creates a new lifecycle manager
+func NewManager(cfg *config.Config, log *zap.Logger) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		timeout: cfg.Server.ComponentStopTimeout,
+		log:     log,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Go runs fn in the background as the component name. The context fn is
+// given is cancelled on shutdown, and fn should return soon after.
+func (m *Manager) Go(name string, fn func(ctx context.Context), opts ...Option) {
+	s := settings{timeout: m.timeout}
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopping {
+		m.log.Warn("Not starting background component during shutdown", zap.String("component", name))
+		return
+	}
+	c := &component{name: name, timeout: s.timeout, done: make(chan struct{})}
+	m.running = append(m.running, c)
+	go func() {
+		defer close(c.done)
+		fn(m.ctx)
+	}()
+}
+
+// Every runs fn as the component name straight away and then every interval
+// until shutdown. A run in progress is given the cancelled context to finish.
+func (m *Manager) Every(name string, interval time.Duration, fn func(ctx context.Context), opts ...Option) {
+	m.Go(name, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			fn(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}, opts...)
+}
+
+// OnStop registers stop to run on shutdown once the components have
+// stopped, in the order registered. It is for work left behind by them, such
+// as flushing the events they published or waiting on webhook deliveries.
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error, opts ...Option) {
+	s := settings{timeout: m.timeout}
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, &hook{name: name, timeout: s.timeout, stop: stop})
+}
+
+// Shutdown cancels the components' context and waits for each of them, then
+// runs the stop functions, each within its timeout. It returns a *StopError
+// naming any that didn't stop in time or failed to, which are also logged.
+func (m *Manager) Shutdown() error {
+	m.mu.Lock()
+	m.stopping = true
+	running, hooks := m.running, m.hooks
+	m.mu.Unlock()
+
+	m.cancel()
+
+	stopped := make([]bool, len(running))
+	var wg sync.WaitGroup
+	for i, c := range running {
+		wg.Add(1)
+		go func(i int, c *component) {
+			defer wg.Done()
+			timer := time.NewTimer(c.timeout)
+			defer timer.Stop()
+			select {
+			case <-c.done:
+				stopped[i] = true
+			case <-timer.C:
+				m.log.Error("Background component did not stop in time",
+					zap.String("component", c.name),
+					zap.Duration("timeout", c.timeout))
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, c := range running {
+		if !stopped[i] {
+			failed = append(failed, c.name)
+		}
+	}
+	for _, h := range hooks {
+		if err := h.run(); err != nil {
+			m.log.Error("Error stopping background component",
+				zap.String("component", h.name),
+				zap.Duration("timeout", h.timeout),
+				zap.Error(err))
+			failed = append(failed, h.name)
+		}
+	}
+	if len(failed) > 0 {
+		return &StopError{Components: failed}
+	}
+	return nil
+}
+
+// run calls stop, giving up once the timeout passes even if stop doesn't
+// heed its context
+func (h *hook) run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- h.stop(ctx)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Group tracks goroutines doing work that should finish before the process
+// exits but can't be cancelled, such as webhook deliveries. The zero value
+// is ready to use.
+type Group struct {
+	wg sync.WaitGroup
+}
+
+// Go runs fn in a goroutine the group waits for
+func (g *Group) Go(fn func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn()
+	}()
+}
+
+// Wait waits for the goroutines started so far, or until ctx is done
+func (g *Group) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
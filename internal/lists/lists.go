@@ -0,0 +1,50 @@
+// Package lists defines the screening lists records belong to. Lists differ in
+// semantics: a hit on a blocking list blacklists the subject, while a hit on
+// an informational list such as PEP only flags it for enhanced due diligence.
+package lists
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Known list types
+const (
+	Internal  = "internal"
+	Sanctions = "sanctions"
+	PEP       = "pep"
+)
+
+// All lists every known list type in the order results are reported
+var All = []string{Internal, Sanctions, PEP}
+
+// blocking lists the list types whose hits blacklist a subject
+var blocking = map[string]bool{
+	Internal:  true,
+	Sanctions: true,
+}
+
+// Known reports whether list is a known list type
+func Known(list string) bool {
+	for _, l := range All {
+		if l == list {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocking reports whether a hit on list blacklists the subject
+func Blocking(list string) bool {
+	return blocking[list]
+}
+
+// Validate checks that every list is known
+func Validate(lists []string) error {
+	for _, list := range lists {
+		if !Known(list) {
+			return fmt.Errorf("unknown list %q (known: %s)", list, strings.Join(All, ", "))
+		}
+	}
+	return nil
+}
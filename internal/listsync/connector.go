@@ -0,0 +1,226 @@
+package listsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Sync run outcomes used as the "result" label
+const (
+	runApplied     = "applied"
+	runQuarantined = "quarantined"
+	runFailed      = "failed"
+)
+
+// Connector keeps external sanctions sources in sync. Each source is
+// downloaded, parsed and diffed against the records it contributed once per
+// interval; a failed run is retried after the retry delay. Replicas claim
+// runs through the sync status table, so each source is synced by one of them.
+type Connector struct {
+	sources    []Source
+	downloader *Downloader
+	syncer     *Syncer
+	status     store.SyncStatusStore
+	interval   time.Duration
+	retry      time.Duration
+	heartbeat  time.Duration
+	// maxAge is how old a source's records may get before they are stale,
+	// unless maxAges overrides it for the source
+	maxAge  time.Duration
+	maxAges map[string]time.Duration
+	log     *zap.Logger
+}
+
+// NewConnector creates a connector for the sources enabled in the config
+func NewConnector(cfg *config.Config, downloader *Downloader, syncer *Syncer, status store.SyncStatusStore, log *zap.Logger) (*Connector, error) {
+	urls := map[string]string{
+		"ofac": cfg.Sync.OFACURL,
+		"un":   cfg.Sync.UNURL,
+		"eu":   cfg.Sync.EUURL,
+	}
+
+	maxAges, err := ParseMaxAges(cfg.Sync.MaxAges)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing SYNC_MAX_AGES: %w", err)
+	}
+
+	c := &Connector{
+		downloader: downloader,
+		syncer:     syncer,
+		status:     status,
+		interval:   cfg.Sync.SourceInterval,
+		retry:      cfg.Sync.SourceRetry,
+		heartbeat:  cfg.Watchdog.HeartbeatTimeout / 3,
+		maxAge:     cfg.Sync.MaxAge,
+		maxAges:    maxAges,
+		log:        log,
+	}
+	for _, name := range strings.Split(cfg.Sync.Sources, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		source, ok := Sources[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sync source %q", name)
+		}
+		if urls[name] != "" {
+			source.URL = urls[name]
+		}
+		if source.URL == "" {
+			return nil, fmt.Errorf("sync source %q has no URL configured", name)
+		}
+		c.sources = append(c.sources, source)
+	}
+	return c, nil
+}
+
+// Enabled reports whether any source is configured
+func (c *Connector) Enabled() bool {
+	return len(c.sources) > 0
+}
+
+// RunDue syncs every source that is due and not being synced by another replica
+func (c *Connector) RunDue(ctx context.Context) {
+	for _, source := range c.sources {
+		claimed, err := c.status.Claim(ctx, source.Name, source.List, c.interval, c.retry)
+		if err != nil {
+			c.log.Error("Error claiming sync run",
+				zap.String("source", source.Name),
+				zap.Error(err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		stop := c.beat(ctx, source.Name)
+		err = c.run(ctx, source)
+		stop()
+		if err != nil {
+			metrics.SyncRunsTotal.WithLabelValues(source.Name, runFailed).Inc()
+			c.log.Error("Error syncing source",
+				zap.String("source", source.Name),
+				zap.Error(err))
+			if err := c.status.Fail(ctx, source.Name, err); err != nil {
+				c.log.Error("Error recording sync failure",
+					zap.String("source", source.Name),
+					zap.Error(err))
+			}
+		}
+	}
+}
+
+// beat heartbeats the running sync of source until the returned stop is
+// called, often enough that one missed write doesn't read as a stall
+func (c *Connector) beat(ctx context.Context, source string) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := c.status.Heartbeat(ctx, source); err != nil && ctx.Err() == nil {
+				c.log.Error("Error recording sync heartbeat",
+					zap.String("source", source),
+					zap.Error(err))
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// run downloads, parses and applies one source
+func (c *Connector) run(ctx context.Context, source Source) error {
+	started := time.Now()
+
+	path, err := c.downloader.Download(ctx, source.URL, source.Name+".download", "")
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening download: %w", err)
+	}
+	records, quality, err := source.Records(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %w", source.Title, err)
+	}
+	if len(records) == 0 {
+		// A format change must not read as the whole list being delisted
+		return errors.New("no individuals found in file")
+	}
+
+	result, err := c.syncer.Sync(ctx, source.List, source.Name, records, quality)
+	if err != nil {
+		return err
+	}
+
+	status := &store.SyncStatus{
+		Source:       source.Name,
+		Records:      len(records),
+		AddedCount:   result.Added,
+		UpdatedCount: result.Updated,
+		DeletedCount: result.Deleted,
+		Quality:      quality,
+	}
+	outcome := runApplied
+	if result.Quarantined {
+		status.QuarantineID = &result.QuarantineID
+		outcome = runQuarantined
+	}
+	if err := c.status.Succeed(ctx, status); err != nil {
+		return fmt.Errorf("error recording sync status: %w", err)
+	}
+
+	metrics.SyncRunsTotal.WithLabelValues(source.Name, outcome).Inc()
+	metrics.SyncSourceRecords.WithLabelValues(source.Name).Set(float64(len(records)))
+	metrics.SyncLastSuccess.WithLabelValues(source.Name).SetToCurrentTime()
+	metrics.SyncSourceQuality.WithLabelValues(source.Name).Set(quality.Score)
+	metrics.SyncSourceQualityIssues.DeletePartialMatch(prometheus.Labels{"source": source.Name})
+	for issue, count := range quality.Issues {
+		metrics.SyncSourceQualityIssues.WithLabelValues(source.Name, issue).Set(float64(count))
+	}
+	c.log.Info("Source synced",
+		zap.String("source", source.Name),
+		zap.Int("records", len(records)),
+		zap.Float64("quality_score", quality.Score),
+		zap.Int("poor_listings", quality.Poor),
+		zap.String("outcome", outcome),
+		zap.Duration("duration", time.Since(started)))
+	return nil
+}
+
+// Statuses returns the outcome of the latest run of every synced source and
+// how stale its records are
+func (c *Connector) Statuses(ctx context.Context) ([]*store.SyncStatus, error) {
+	statuses, err := c.status.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		c.annotate(status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,113 @@
+package listsync
+
+import (
+	"maps"
+	"time"
+
+	"blacklist-check/internal/store"
+)
+
+// Diff computes the change set that turns current into snapshot, keyed by NIK
+// within a list
+func Diff(list, source string, current, snapshot []*store.BlacklistRecord) *store.ChangeSet {
+	cs := &store.ChangeSet{List: list, Source: source}
+
+	existing := make(map[string]*store.BlacklistRecord, len(current))
+	for _, record := range current {
+		existing[record.NIK] = record
+	}
+
+	seen := make(map[string]bool, len(snapshot))
+	for _, record := range snapshot {
+		if seen[record.NIK] {
+			continue
+		}
+		seen[record.NIK] = true
+
+		old, ok := existing[record.NIK]
+		if !ok {
+			cs.Added = append(cs.Added, record)
+			continue
+		}
+		if !sameContent(old, record) {
+			cs.Updated = append(cs.Updated, record)
+		}
+	}
+
+	for nik := range existing {
+		if !seen[nik] {
+			cs.Deleted = append(cs.Deleted, nik)
+		}
+	}
+
+	return cs
+}
+
+// Rebase brings a change set diffed against older records of its source up
+// to date with current, the source's records now. The records it adds or
+// updates are added, updated or dropped as they now differ, and the NIKs it
+// deletes that are gone already are dropped.
+func Rebase(cs *store.ChangeSet, current []*store.BlacklistRecord) *store.ChangeSet {
+	rebased := &store.ChangeSet{List: cs.List, Source: cs.Source}
+
+	existing := make(map[string]*store.BlacklistRecord, len(current))
+	for _, record := range current {
+		existing[record.NIK] = record
+	}
+
+	for _, records := range [][]*store.BlacklistRecord{cs.Added, cs.Updated} {
+		for _, record := range records {
+			old, ok := existing[record.NIK]
+			switch {
+			case !ok:
+				rebased.Added = append(rebased.Added, record)
+			case !sameContent(old, record):
+				rebased.Updated = append(rebased.Updated, record)
+			}
+		}
+	}
+	for _, nik := range cs.Deleted {
+		if _, ok := existing[nik]; ok {
+			rebased.Deleted = append(rebased.Deleted, nik)
+		}
+	}
+
+	return rebased
+}
+
+// sameContent reports whether two records carry the same matchable data
+func sameContent(a, b *store.BlacklistRecord) bool {
+	return a.Name == b.Name &&
+		a.BirthPlace == b.BirthPlace &&
+		sameDate(a.BirthDate, b.BirthDate) &&
+		a.Reason == b.Reason &&
+		a.ReasonCode == b.ReasonCode &&
+		maps.Equal(a.ReasonParams, b.ReasonParams) &&
+		sameAliases(a.Aliases, b.Aliases)
+}
+
+// sameAliases reports whether two records carry the same aliases, in any order
+func sameAliases(a, b []store.Alias) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[store.Alias]int, len(a))
+	for _, alias := range a {
+		counts[alias]++
+	}
+	for _, alias := range b {
+		if counts[alias] == 0 {
+			return false
+		}
+		counts[alias]--
+	}
+	return true
+}
+
+// sameDate reports whether two optional birth dates are equal
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,69 @@
+package listsync
+
+import (
+	"reflect"
+	"testing"
+
+	"blacklist-check/internal/store"
+)
+
+func TestRebase(t *testing.T) {
+	record := func(nik, name string) *store.BlacklistRecord {
+		return &store.BlacklistRecord{NIK: nik, Name: name}
+	}
+	niks := func(records []*store.BlacklistRecord) []string {
+		var niks []string
+		for _, record := range records {
+			niks = append(niks, record.NIK)
+		}
+		return niks
+	}
+
+	tests := []struct {
+		name        string
+		cs          *store.ChangeSet
+		current     []*store.BlacklistRecord
+		wantAdded   []string
+		wantUpdated []string
+		wantDeleted []string
+	}{
+		{
+			name:        "nothing changed since",
+			cs:          &store.ChangeSet{Added: []*store.BlacklistRecord{record("1", "Budi")}, Updated: []*store.BlacklistRecord{record("2", "Siti")}, Deleted: []string{"3"}},
+			current:     []*store.BlacklistRecord{record("2", "Siti Aminah"), record("3", "Agus")},
+			wantAdded:   []string{"1"},
+			wantUpdated: []string{"2"},
+			wantDeleted: []string{"3"},
+		},
+		{
+			name:        "added since",
+			cs:          &store.ChangeSet{Added: []*store.BlacklistRecord{record("1", "Budi"), record("2", "Siti")}},
+			current:     []*store.BlacklistRecord{record("1", "Budi"), record("2", "Siti Aminah")},
+			wantUpdated: []string{"2"},
+		},
+		{
+			name:      "deleted since",
+			cs:        &store.ChangeSet{Updated: []*store.BlacklistRecord{record("2", "Siti")}, Deleted: []string{"3"}},
+			wantAdded: []string{"2"},
+		},
+		{
+			name:    "updated the same since",
+			cs:      &store.ChangeSet{Updated: []*store.BlacklistRecord{record("2", "Siti")}},
+			current: []*store.BlacklistRecord{record("2", "Siti")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Rebase(tt.cs, tt.current)
+			if added := niks(got.Added); !reflect.DeepEqual(added, tt.wantAdded) {
+				t.Errorf("Added = %v, want %v", added, tt.wantAdded)
+			}
+			if updated := niks(got.Updated); !reflect.DeepEqual(updated, tt.wantUpdated) {
+				t.Errorf("Updated = %v, want %v", updated, tt.wantUpdated)
+			}
+			if !reflect.DeepEqual(got.Deleted, tt.wantDeleted) {
+				t.Errorf("Deleted = %v, want %v", got.Deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package listsync
+
+import "errors"
+
+// freeBytes is not supported on this platform
+func freeBytes(dir string) (uint64, error) {
+	return 0, errors.New("free space check not supported on this platform")
+}
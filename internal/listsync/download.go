@@ -0,0 +1,434 @@
+package listsync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrChecksumMismatch is returned when a downloaded file does not match its expected digest
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrInsufficientDiskSpace is returned when the staging directory cannot hold the download
+	ErrInsufficientDiskSpace = errors.New("insufficient disk space")
+	// ErrThrottled is returned when a source asks us to back off for longer than we are willing to wait
+	ErrThrottled = errors.New("throttled by source")
+)
+
+// partSuffix marks files that are still being downloaded, and stateSuffix
+// the file next to one that records what it is a part of
+const (
+	partSuffix  = ".part"
+	stateSuffix = ".state"
+)
+
+// partState is what a part file is a part of: the validator of the version
+// of the object it holds, sent as If-Range so a resumed transfer continues
+// the same version, and the object's size, or -1 when the source didn't say
+type partState struct {
+	Validator string `json:"validator"`
+	Size      int64  `json:"size"`
+}
+
+// Downloader fetches vendor files into a staging directory, resuming interrupted
+// transfers. Requests are rate limited per source host, and 429/503 responses
+// hold back every request to that host for as long as its Retry-After asks.
+type Downloader struct {
+	client        *http.Client
+	dir           string
+	maxRetries    int
+	minFree       uint64
+	defaultLimit  RateLimit
+	limits        map[string]RateLimit
+	maxRetryAfter time.Duration
+	log           *zap.Logger
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewDownloader creates a new downloader
+func NewDownloader(cfg *config.Config, log *zap.Logger) (*Downloader, error) {
+	limits, err := ParseRateLimits(cfg.Sync.RateLimits)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing sync rate limits: %w", err)
+	}
+	defaultLimit := RateLimit{Rate: cfg.Sync.RateLimit, Burst: cfg.Sync.RateBurst}
+	if err := defaultLimit.validate(); err != nil {
+		return nil, fmt.Errorf("invalid SYNC_RATE_LIMIT or SYNC_RATE_BURST: %w", err)
+	}
+
+	return &Downloader{
+		client:        &http.Client{},
+		dir:           cfg.Sync.DownloadDir,
+		maxRetries:    cfg.Sync.DownloadRetries,
+		minFree:       cfg.Sync.DownloadMinFreeBytes,
+		defaultLimit:  defaultLimit,
+		limits:        limits,
+		maxRetryAfter: cfg.Sync.MaxRetryAfter,
+		log:           log,
+		buckets:       make(map[string]*tokenBucket),
+	}, nil
+}
+
+// PrepareReload reads the sync rate limits from cfg and returns the function
+// that applies them, or why they can't be. Sources keep any pause their
+// Retry-After asked for.
+func (d *Downloader) PrepareReload(cfg *config.Config) (func(), error) {
+	limits, err := ParseRateLimits(cfg.Sync.RateLimits)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing sync rate limits: %w", err)
+	}
+	defaultLimit := RateLimit{Rate: cfg.Sync.RateLimit, Burst: cfg.Sync.RateBurst}
+	if err := defaultLimit.validate(); err != nil {
+		return nil, fmt.Errorf("invalid SYNC_RATE_LIMIT or SYNC_RATE_BURST: %w", err)
+	}
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		d.defaultLimit = defaultLimit
+		d.limits = limits
+		for host, b := range d.buckets {
+			limit, ok := limits[host]
+			if !ok {
+				limit = defaultLimit
+			}
+			b.setLimit(limit)
+		}
+	}, nil
+}
+
+// throttledError reports a 429 or 503 response and how long the source asked us to wait
+type throttledError struct {
+	status string
+	wait   time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("throttled by source: %s (retry after %s)", e.status, e.wait)
+}
+
+// bucket returns the token bucket for the host of rawURL
+func (d *Downloader) bucket(rawURL string) (string, *tokenBucket, error) {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, ok := d.buckets[host]
+	if !ok {
+		limit, ok := d.limits[host]
+		if !ok {
+			limit = d.defaultLimit
+		}
+		var err error
+		if b, err = newTokenBucket(limit); err != nil {
+			return "", nil, fmt.Errorf("invalid rate limit for %s: %w", host, err)
+		}
+		d.buckets[host] = b
+	}
+	return host, b, nil
+}
+
+// Download fetches url into the staging directory under name and returns the final path.
+// If sha256Hex is non-empty the file is verified before it is moved into place.
+func (d *Downloader) Download(ctx context.Context, url, name, sha256Hex string) (string, error) {
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating staging directory: %w", err)
+	}
+
+	dest := filepath.Join(d.dir, name)
+	part := dest + partSuffix
+
+	var lastErr error
+	for attempt := 0; attempt <= d.maxRetries; attempt++ {
+		// The bucket already holds requests back for a throttled source
+		var throttled *throttledError
+		if attempt > 0 && !errors.As(lastErr, &throttled) {
+			backoff := time.Duration(attempt) * time.Second
+			d.log.Warn("Retrying download",
+				zap.String("url", url),
+				zap.Int("attempt", attempt),
+				zap.Duration("backoff", backoff),
+				zap.Error(lastErr))
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+
+		var resumed, sized bool
+		resumed, sized, lastErr = d.fetch(ctx, url, part)
+		if lastErr == nil && resumed && !sized && sha256Hex == "" {
+			// Nothing tells whether the pieces make up the whole file
+			discardPart(part)
+			lastErr = fmt.Errorf("resumed download has neither a size nor a checksum to verify")
+			continue
+		}
+		if lastErr == nil {
+			break
+		}
+		if errors.Is(lastErr, ErrInsufficientDiskSpace) || errors.Is(lastErr, ErrThrottled) || ctx.Err() != nil {
+			return "", lastErr
+		}
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("error downloading %s: %w", url, lastErr)
+	}
+
+	if sha256Hex != "" {
+		if err := verifyChecksum(part, sha256Hex); err != nil {
+			// A corrupt staging file must not be resumed on the next run
+			discardPart(part)
+			return "", err
+		}
+	}
+
+	if err := os.Rename(part, dest); err != nil {
+		return "", fmt.Errorf("error moving download into place: %w", err)
+	}
+	os.Remove(part + stateSuffix)
+
+	d.log.Info("Download complete", zap.String("url", url), zap.String("path", dest))
+	return dest, nil
+}
+
+// fetch appends the remaining bytes of url to part, resuming from its current
+// size when the version it holds is known. It reports whether it resumed, and
+// whether the file was found to be as large as the source said.
+func (d *Downloader) fetch(ctx context.Context, url, part string) (resumed, sized bool, err error) {
+	var offset int64
+	state, err := readPartState(part)
+	if err != nil {
+		return false, false, err
+	}
+	if info, err := os.Stat(part); err == nil && state != nil && state.Validator != "" {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, false, err
+	}
+	if offset > 0 {
+		// The source sends the whole object instead if it changed since
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", state.Validator)
+	}
+
+	host, bucket, err := d.bucket(url)
+	if err != nil {
+		return false, false, err
+	}
+	if err := bucket.Wait(ctx); err != nil {
+		return false, false, err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		return false, false, d.throttle(host, bucket, resp)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || offset == 0 || start != offset {
+			discardPart(part)
+			return false, false, fmt.Errorf("partial content %q doesn't continue the %d bytes downloaded", resp.Header.Get("Content-Range"), offset)
+		}
+		flags |= os.O_APPEND
+		resumed = true
+		if size >= 0 {
+			state.Size = size
+		}
+	case http.StatusOK:
+		// The server ignored the range request, or the object changed, so start over
+		flags |= os.O_TRUNC
+		offset = 0
+		state = &partState{Validator: validator(resp.Header), Size: resp.ContentLength}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The object shrank or changed under the staging file, which can't
+		// be trusted to hold any of it
+		discardPart(part)
+		return false, false, fmt.Errorf("range from byte %d not satisfiable: %s", offset, resp.Status)
+	default:
+		return false, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if err := writePartState(part, state); err != nil {
+		return false, false, err
+	}
+
+	if resp.ContentLength > 0 {
+		if err := d.ensureSpace(uint64(resp.ContentLength)); err != nil {
+			return false, false, err
+		}
+	}
+
+	f, err := os.OpenFile(part, flags, 0o644)
+	if err != nil {
+		return false, false, err
+	}
+	defer f.Close()
+
+	written, err := io.Copy(f, resp.Body)
+	d.log.Debug("Download progress",
+		zap.String("url", url),
+		zap.Int64("offset", offset),
+		zap.Int64("written", written))
+	if err != nil {
+		return resumed, false, err
+	}
+	if err := f.Sync(); err != nil {
+		return resumed, false, err
+	}
+
+	if state.Size < 0 {
+		return resumed, false, nil
+	}
+	if offset+written != state.Size {
+		discardPart(part)
+		return resumed, false, fmt.Errorf("downloaded %d bytes of a %d byte file", offset+written, state.Size)
+	}
+	return resumed, true, nil
+}
+
+// validator returns the If-Range validator of a response: its ETag unless
+// weak, which If-Range doesn't accept, else its Last-Modified date
+func validator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// parseContentRange returns the first byte and the object size of a
+// Content-Range header, the size being -1 when the source didn't say
+func parseContentRange(header string) (start, size int64, err error) {
+	var end int64
+	var total string
+	if _, err := fmt.Sscanf(header, "bytes %d-%d/%s", &start, &end, &total); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	if total == "*" {
+		return start, -1, nil
+	}
+	if size, err = strconv.ParseInt(total, 10, 64); err != nil || end >= size || start > end {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	return start, size, nil
+}
+
+// readPartState returns the state of part, nil when there is none
+func readPartState(part string) (*partState, error) {
+	data, err := os.ReadFile(part + stateSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state partState
+	if err := json.Unmarshal(data, &state); err != nil {
+		// A torn state file only costs the transfer a restart
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// writePartState records the state of part
+func writePartState(part string, state *partState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(part+stateSuffix, data, 0o644)
+}
+
+// discardPart removes a part file and its state, so the next attempt starts over
+func discardPart(part string) {
+	os.Remove(part)
+	os.Remove(part + stateSuffix)
+}
+
+// throttle pauses a source as long as its Retry-After header asks, falling
+// back to a pause of one second per burst token when it sends none
+func (d *Downloader) throttle(host string, bucket *tokenBucket, resp *http.Response) error {
+	metrics.SyncThrottledTotal.WithLabelValues(host).Inc()
+
+	wait := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if wait == 0 {
+		wait = time.Duration(bucket.burst) * time.Second
+	}
+	if wait > d.maxRetryAfter {
+		return fmt.Errorf("%w: %s asked to wait %s, more than the %s allowed", ErrThrottled, host, wait, d.maxRetryAfter)
+	}
+
+	d.log.Warn("Source throttled download",
+		zap.String("host", host),
+		zap.String("status", resp.Status),
+		zap.Duration("retry_after", wait))
+	bucket.Pause(time.Now().Add(wait))
+	return &throttledError{status: resp.Status, wait: wait}
+}
+
+// ensureSpace checks that the staging directory can hold need more bytes plus the configured reserve
+func (d *Downloader) ensureSpace(need uint64) error {
+	free, err := freeBytes(d.dir)
+	if err != nil {
+		d.log.Warn("Could not determine free disk space", zap.String("dir", d.dir), zap.Error(err))
+		return nil
+	}
+	if free < need+d.minFree {
+		return fmt.Errorf("%w: need %d bytes plus %d reserve, %d available", ErrInsufficientDiskSpace, need, d.minFree, free)
+	}
+	return nil
+}
+
+// verifyChecksum compares the SHA-256 digest of path with the expected hex digest
+func verifyChecksum(path, expected string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("error hashing download: %w", err)
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expected, actual)
+	}
+	return nil
+}
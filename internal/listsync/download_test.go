@@ -0,0 +1,158 @@
+package listsync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+func TestDownloadResume(t *testing.T) {
+	const content = "nik,name\n3171234567890123,Budi Santoso\n"
+	tests := []struct {
+		name  string
+		part  string
+		state *partState
+		// wantIfRange is the If-Range header of the first request
+		wantIfRange string
+	}{
+		{
+			name:        "same version",
+			part:        content[:10],
+			state:       &partState{Validator: `"v1"`, Size: int64(len(content))},
+			wantIfRange: `"v1"`,
+		},
+		{
+			name:        "changed version",
+			part:        "stale:" + content[:10],
+			state:       &partState{Validator: `"v0"`, Size: int64(len(content))},
+			wantIfRange: `"v0"`,
+		},
+		{
+			name: "no validator",
+			part: "stale:" + content[:10],
+		},
+		{
+			name:        "range not satisfiable",
+			part:        content + "trailing garbage",
+			state:       &partState{Validator: `"v1"`, Size: int64(len(content) + 16)},
+			wantIfRange: `"v1"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ifRanges []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+				w.Header().Set("ETag", `"v1"`)
+				http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+			}))
+			defer srv.Close()
+
+			d := newTestDownloader(t, 1)
+			part := filepath.Join(d.dir, "list.csv"+partSuffix)
+			if err := os.WriteFile(part, []byte(tt.part), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.state != nil {
+				if err := writePartState(part, tt.state); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			path, err := d.Download(context.Background(), srv.URL, "list.csv", "")
+			if err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != content {
+				t.Errorf("downloaded %q, want %q", got, content)
+			}
+			if _, err := os.Stat(part + stateSuffix); !os.IsNotExist(err) {
+				t.Errorf("part state left behind: %v", err)
+			}
+			if len(ifRanges) == 0 || ifRanges[0] != tt.wantIfRange {
+				t.Errorf("If-Range headers = %q, want %q first", ifRanges, tt.wantIfRange)
+			}
+		})
+	}
+}
+
+func TestDownloadRejectsMisplacedRange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Answers any range with the start of the file
+		w.Header().Set("Content-Range", "bytes 0-4/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("01234"))
+	}))
+	defer srv.Close()
+
+	d := newTestDownloader(t, 0)
+	part := filepath.Join(d.dir, "list.csv"+partSuffix)
+	if err := os.WriteFile(part, []byte("01234"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePartState(part, &partState{Validator: `"v1"`, Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Download(context.Background(), srv.URL, "list.csv", ""); err == nil {
+		t.Fatal("Download() succeeded, want an error")
+	}
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Errorf("part file kept after a misplaced range: %v", err)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantStart int64
+		wantSize  int64
+		wantErr   bool
+	}{
+		{header: "bytes 10-19/20", wantStart: 10, wantSize: 20},
+		{header: "bytes 0-9/*", wantStart: 0, wantSize: -1},
+		{header: "bytes 10-29/20", wantErr: true},
+		{header: "bytes 19-10/20", wantErr: true},
+		{header: "bytes */20", wantErr: true},
+		{header: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		start, size, err := parseContentRange(tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseContentRange(%q) error = %v, wantErr %t", tt.header, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (start != tt.wantStart || size != tt.wantSize) {
+			t.Errorf("parseContentRange(%q) = %d, %d, want %d, %d", tt.header, start, size, tt.wantStart, tt.wantSize)
+		}
+	}
+}
+
+// newTestDownloader returns an unthrottled downloader staging into a
+// temporary directory
+func newTestDownloader(t *testing.T, retries int) *Downloader {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Sync.DownloadDir = t.TempDir()
+	cfg.Sync.DownloadRetries = retries
+	cfg.Sync.RateBurst = 1
+	cfg.Sync.MaxRetryAfter = time.Minute
+	d, err := NewDownloader(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -0,0 +1,89 @@
+package listsync
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"blacklist-check/internal/store"
+)
+
+// Columns of the EU consolidated financial sanctions CSV (format 1.1)
+const (
+	euColumnID        = "Entity_LogicalId"
+	euColumnType      = "Entity_SubjectType_ClassificationCode"
+	euColumnProgramme = "Entity_Regulation_Programme"
+	euColumnName      = "NameAlias_WholeName"
+	euColumnBirthDate = "BirthDate_BirthDate"
+	euColumnBirthCity = "BirthDate_City"
+)
+
+// parseEU reads the persons of the EU consolidated financial sanctions list.
+// The file is semicolon separated with one row per alias, date of birth,
+// address and so on of an entity, so rows are merged by Entity_LogicalId and
+// the first alias is used as the name and the others as aliases.
+func parseEU(r io.Reader) ([]*Person, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	for _, name := range []string{euColumnID, euColumnType, euColumnName, euColumnBirthDate} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var people []*Person
+	byID := make(map[string]*Person)
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV row: %w", err)
+		}
+		if !strings.EqualFold(field(row, euColumnType), "P") {
+			continue
+		}
+
+		id := field(row, euColumnID)
+		person, ok := byID[id]
+		if !ok {
+			person = &Person{ID: id}
+			if programme := field(row, euColumnProgramme); programme != "" {
+				person.Programs = []string{programme}
+			}
+			byID[id] = person
+			people = append(people, person)
+		}
+		if name := field(row, euColumnName); person.Name == "" {
+			person.Name = name
+		} else {
+			person.addAlias(name, store.AliasAKA)
+		}
+		if person.BirthPlace == "" {
+			person.BirthPlace = field(row, euColumnBirthCity)
+		}
+		person.addBirthDate(field(row, euColumnBirthDate), "2006-01-02")
+	}
+	return people, nil
+}
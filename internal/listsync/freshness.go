@@ -0,0 +1,88 @@
+package listsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/store"
+)
+
+// ParseMaxAges parses per-source overrides of the maximum age of records,
+// of the form
+//
+//	ofac=36h,un=72h
+func ParseMaxAges(spec string) (map[string]time.Duration, error) {
+	ages := make(map[string]time.Duration)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		source, value, ok := strings.Cut(entry, "=")
+		if !ok || source == "" {
+			return nil, fmt.Errorf("invalid max age entry %q", entry)
+		}
+		if _, known := Sources[source]; !known {
+			return nil, fmt.Errorf("unknown sync source %q in max age entry %q", source, entry)
+		}
+		age, err := time.ParseDuration(value)
+		if err != nil || age < 0 {
+			return nil, fmt.Errorf("invalid duration in max age entry %q", entry)
+		}
+		ages[source] = age
+	}
+	return ages, nil
+}
+
+// maxAgeOf returns how old the records of source may get before they are
+// stale, 0 when they never are
+func (c *Connector) maxAgeOf(source string) time.Duration {
+	if age, ok := c.maxAges[source]; ok {
+		return age
+	}
+	return c.maxAge
+}
+
+// annotate fills in the maximum age of a status and whether its records
+// exceed it
+func (c *Connector) annotate(status *store.SyncStatus) {
+	maxAge := c.maxAgeOf(status.Source)
+	status.MaxAgeSeconds = maxAge.Seconds()
+	status.Stale = maxAge > 0 && status.AgeSeconds > maxAge.Seconds()
+}
+
+// Freshness returns the status of every configured source with how stale
+// its records are, and reports their age in the source metrics. Sources
+// that were never synced have no status yet.
+func (c *Connector) Freshness(ctx context.Context) ([]*store.SyncStatus, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	statuses, err := c.status.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configured := make(map[string]bool, len(c.sources))
+	for _, source := range c.sources {
+		configured[source.Name] = true
+	}
+
+	var fresh []*store.SyncStatus
+	for _, status := range statuses {
+		if !configured[status.Source] {
+			continue
+		}
+		c.annotate(status)
+		metrics.SyncSourceAge.WithLabelValues(status.Source).Set(status.AgeSeconds)
+		stale := 0.0
+		if status.Stale {
+			stale = 1
+		}
+		metrics.SyncSourceStale.WithLabelValues(status.Source).Set(stale)
+		fresh = append(fresh, status)
+	}
+	return fresh, nil
+}
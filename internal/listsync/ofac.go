@@ -0,0 +1,57 @@
+package listsync
+
+import (
+	"io"
+	"strings"
+
+	"blacklist-check/internal/store"
+)
+
+// ofacEntry is an sdnEntry of the OFAC SDN XML export
+type ofacEntry struct {
+	UID       string   `xml:"uid"`
+	FirstName string   `xml:"firstName"`
+	LastName  string   `xml:"lastName"`
+	Type      string   `xml:"sdnType"`
+	Programs  []string `xml:"programList>program"`
+	Births    []string `xml:"dateOfBirthList>dateOfBirthItem>dateOfBirth"`
+	Places    []string `xml:"placeOfBirthList>placeOfBirthItem>placeOfBirth"`
+	AKAs      []struct {
+		Type      string `xml:"type"`
+		FirstName string `xml:"firstName"`
+		LastName  string `xml:"lastName"`
+	} `xml:"akaList>aka"`
+}
+
+// parseOFAC reads the individuals of the OFAC SDN list (SDN.XML). Dates of
+// birth are written as "02 Jan 2006" when known in full. Aliases are typed
+// "a.k.a.", "f.k.a." or "n.k.a.".
+func parseOFAC(r io.Reader) ([]*Person, error) {
+	var people []*Person
+	err := decodeElements(r, "sdnEntry", func(e *ofacEntry) {
+		if !strings.EqualFold(e.Type, "Individual") {
+			return
+		}
+
+		person := &Person{
+			ID:       e.UID,
+			Name:     e.FirstName + " " + e.LastName,
+			Programs: e.Programs,
+		}
+		if len(e.Places) > 0 {
+			person.BirthPlace = e.Places[0]
+		}
+		for _, birth := range e.Births {
+			person.addBirthDate(birth, "02 Jan 2006", "2 Jan 2006")
+		}
+		for _, aka := range e.AKAs {
+			aliasType := store.AliasAKA
+			if strings.EqualFold(aka.Type, "f.k.a.") {
+				aliasType = store.AliasFKA
+			}
+			person.addAlias(aka.FirstName+" "+aka.LastName, aliasType)
+		}
+		people = append(people, person)
+	})
+	return people, err
+}
@@ -0,0 +1,139 @@
+package listsync
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"blacklist-check/internal/store"
+)
+
+// Data quality issues a listing can have
+const (
+	// IssueMissingID and IssueMissingName listings can't be imported at all
+	IssueMissingID   = "missing_id"
+	IssueMissingName = "missing_name"
+	// IssueImprobableName is a name with digits, a placeholder such as
+	// "unknown", or fewer than three letters
+	IssueImprobableName   = "improbable_name"
+	IssueMissingBirthDate = "missing_birth_date"
+	// IssueMalformedBirthDate is a date of birth given but not as a full
+	// date, such as a bare year or a typo
+	IssueMalformedBirthDate = "malformed_birth_date"
+	// IssueImprobableBirthDate is a date of birth before 1900 or in the future
+	IssueImprobableBirthDate = "improbable_birth_date"
+	IssueMissingBirthPlace   = "missing_birth_place"
+)
+
+// issuePenalties is how much each issue takes off a listing's score of 1.
+// Missing birth details weaken matching; a wrong name or date defeats it.
+var issuePenalties = map[string]float64{
+	IssueMissingID:           1,
+	IssueMissingName:         1,
+	IssueImprobableName:      0.5,
+	IssueMissingBirthDate:    0.3,
+	IssueMalformedBirthDate:  0.2,
+	IssueImprobableBirthDate: 0.4,
+	IssueMissingBirthPlace:   0.1,
+}
+
+// poorScore is the score below which a listing counts as poor
+const poorScore = 0.5
+
+// placeholderNames are values sources write when they don't know a name
+var placeholderNames = map[string]bool{
+	"unknown": true,
+	"n/a":     true,
+	"na":      true,
+	"none":    true,
+	"null":    true,
+	"test":    true,
+	"tbd":     true,
+	"-":       true,
+}
+
+// scorePerson scores how usable a listing is for matching, from 0 to 1, and
+// returns the issues found
+func scorePerson(p *Person, now time.Time) (float64, []string) {
+	var issues []string
+	name := strings.Join(strings.Fields(p.Name), " ")
+	if p.ID == "" {
+		issues = append(issues, IssueMissingID)
+	}
+	if name == "" {
+		issues = append(issues, IssueMissingName)
+	} else if improbableName(name) {
+		issues = append(issues, IssueImprobableName)
+	}
+	if len(p.BirthDates) == 0 {
+		issues = append(issues, IssueMissingBirthDate)
+	}
+	if p.MalformedBirthDates > 0 {
+		issues = append(issues, IssueMalformedBirthDate)
+	}
+	for _, date := range p.BirthDates {
+		if date.Year() < 1900 || date.After(now) {
+			issues = append(issues, IssueImprobableBirthDate)
+			break
+		}
+	}
+	if strings.TrimSpace(p.BirthPlace) == "" {
+		issues = append(issues, IssueMissingBirthPlace)
+	}
+
+	score := 1.0
+	for _, issue := range issues {
+		score -= issuePenalties[issue]
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score, issues
+}
+
+// improbableName reports whether name is unlikely to be a real person's name
+func improbableName(name string) bool {
+	if placeholderNames[strings.ToLower(name)] {
+		return true
+	}
+	letters := 0
+	for _, r := range name {
+		if unicode.IsDigit(r) {
+			return true
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters < 3
+}
+
+// qualityReport aggregates the scores of the listings in a file
+type qualityReport struct {
+	total  float64
+	report store.SyncQuality
+}
+
+// add counts one scored listing
+func (q *qualityReport) add(score float64, issues []string) {
+	q.total += score
+	q.report.Listings++
+	if score < poorScore {
+		q.report.Poor++
+	}
+	for _, issue := range issues {
+		if q.report.Issues == nil {
+			q.report.Issues = make(map[string]int)
+		}
+		q.report.Issues[issue]++
+	}
+}
+
+// quality returns the aggregate quality of the listings added
+func (q *qualityReport) quality() *store.SyncQuality {
+	quality := q.report
+	if quality.Listings > 0 {
+		quality.Score = q.total / float64(quality.Listings)
+	}
+	return &quality
+}
@@ -0,0 +1,173 @@
+package listsync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket smooths requests to one source: tokens refill at rate per
+// second up to burst, and a request waits until a token is available or the
+// source's Retry-After has passed
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu           sync.Mutex
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// newTokenBucket creates a full bucket. A burst below one would never hold
+// a whole token, so Wait would never return.
+func newTokenBucket(limit RateLimit) (*tokenBucket, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  float64(limit.Burst),
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}, nil
+}
+
+// setLimit changes the bucket's budget, keeping any pause the source asked for
+func (b *tokenBucket) setLimit(limit RateLimit) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rate = limit.Rate
+	b.burst = float64(limit.Burst)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is cancelled
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		delay := b.reserve()
+		if delay <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reserve takes a token if one is available, else returns how long to wait
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now)
+	}
+	if b.rate <= 0 {
+		// Unlimited
+		return 0
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Pause holds back every request to the source until the given time and
+// drains the bucket so requests resume at the steady rate, not in a burst
+func (b *tokenBucket) Pause(until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+	b.tokens = 0
+	b.last = until
+}
+
+// RateLimit is the request budget for one source
+type RateLimit struct {
+	// Rate is the sustained number of requests per second; 0 disables limiting
+	Rate float64
+	// Burst is the number of requests that may be sent back to back
+	Burst int
+}
+
+// validate reports a limit a token bucket can't enforce
+func (l RateLimit) validate() error {
+	if l.Rate < 0 {
+		return fmt.Errorf("rate must not be negative, got %g", l.Rate)
+	}
+	if l.Burst < 1 {
+		return fmt.Errorf("burst must be at least 1, got %d", l.Burst)
+	}
+	return nil
+}
+
+// ParseRateLimits parses per-source overrides of the form
+//
+//	api.vendor-a.com=0.5:2,lists.vendor-b.id=10:20
+//
+// mapping a host to <requests per second>:<burst>
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, value, ok := strings.Cut(entry, "=")
+		rate, burst, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 || host == "" {
+			return nil, fmt.Errorf("invalid rate limit entry %q", entry)
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || r < 0 {
+			return nil, fmt.Errorf("invalid rate in rate limit entry %q", entry)
+		}
+		b, err := strconv.Atoi(burst)
+		if err != nil || b < 1 {
+			return nil, fmt.Errorf("invalid burst in rate limit entry %q", entry)
+		}
+		limits[strings.ToLower(host)] = RateLimit{Rate: r, Burst: b}
+	}
+	return limits, nil
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as an
+// HTTP date. It returns 0 when the header is missing or invalid.
+func retryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
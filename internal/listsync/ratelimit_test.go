@@ -0,0 +1,99 @@
+package listsync
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewTokenBucket(t *testing.T) {
+	tests := []struct {
+		limit   RateLimit
+		wantErr bool
+	}{
+		{RateLimit{Rate: 1, Burst: 5}, false},
+		{RateLimit{Rate: 0, Burst: 1}, false},
+		{RateLimit{Rate: 1, Burst: 0}, true},
+		{RateLimit{Rate: 0, Burst: 0}, true},
+		{RateLimit{Rate: -1, Burst: 1}, true},
+	}
+	for _, tt := range tests {
+		if _, err := newTokenBucket(tt.limit); (err != nil) != tt.wantErr {
+			t.Errorf("newTokenBucket(%+v) error = %v, wantErr %t", tt.limit, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	b, err := newTokenBucket(RateLimit{Rate: 1000, Burst: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// The burst goes at once and the next waits about a millisecond
+	for i := 0; i < 3; i++ {
+		if err := b.Wait(ctx); err != nil {
+			t.Fatalf("Wait() %d error = %v", i+1, err)
+		}
+	}
+
+	b.Pause(time.Now().Add(time.Hour))
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wait() while paused error = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]RateLimit
+		wantErr bool
+	}{
+		{spec: "", want: map[string]RateLimit{}},
+		{
+			spec: "API.vendor-a.com=0.5:2, lists.vendor-b.id=0:1",
+			want: map[string]RateLimit{
+				"api.vendor-a.com":  {Rate: 0.5, Burst: 2},
+				"lists.vendor-b.id": {Rate: 0, Burst: 1},
+			},
+		},
+		{spec: "vendor=1", wantErr: true},
+		{spec: "vendor=-1:1", wantErr: true},
+		{spec: "vendor=1:0", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRateLimits(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRateLimits(%q) error = %v, wantErr %t", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRateLimits(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header, now); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package listsync
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/store"
+)
+
+// Source is an external list published as a file that is downloaded and
+// parsed into records on a schedule
+type Source struct {
+	// Name identifies the source and is stored as the records' source
+	Name string
+	// List is the list the records are screened on
+	List string
+	// Title names the source in reasons shown to callers
+	Title string
+	// URL is the default location of the published file
+	URL string
+	// Parse extracts the individuals listed in the file
+	Parse func(r io.Reader) ([]*Person, error)
+}
+
+// Sources lists the supported external sanctions sources by name
+var Sources = map[string]Source{
+	"ofac": {
+		Name:  "ofac",
+		List:  lists.Sanctions,
+		Title: "OFAC SDN",
+		URL:   "https://sanctionslistservice.ofac.treas.gov/api/PublicationPreview/exports/SDN.XML",
+		Parse: parseOFAC,
+	},
+	"un": {
+		Name:  "un",
+		List:  lists.Sanctions,
+		Title: "UN Consolidated",
+		URL:   "https://scsanctions.un.org/resources/xml/en/consolidated.xml",
+		Parse: parseUN,
+	},
+	"eu": {
+		Name:  "eu",
+		List:  lists.Sanctions,
+		Title: "EU Financial Sanctions",
+		// The EU publishes its file behind a per-subscriber token, so there is no default
+		Parse: parseEU,
+	},
+}
+
+// Column limits of the blacklist table
+const (
+	maxNameLength       = 255
+	maxBirthPlaceLength = 100
+)
+
+// Person is an individual as published by a source
+type Person struct {
+	// ID is the source's own identifier for the listing
+	ID         string
+	Name       string
+	BirthPlace string
+	// BirthDates holds every full date of birth given; partial dates are dropped
+	BirthDates []time.Time
+	// MalformedBirthDates counts the dates of birth given that weren't full dates
+	MalformedBirthDates int
+	// Programs are the sanctions programs or regimes the person is listed under
+	Programs []string
+	// Aliases are the other names the person is listed under
+	Aliases []store.Alias
+}
+
+// Records parses the file in r and flattens every person into one record per
+// known birth date, since matching compares a single date. Listings without
+// a full date get one record with an unknown (zero) date. Records are keyed
+// by "<source>:<id>" in place of a NIK. Every listing is scored for data
+// quality on the way, including those that can't be imported.
+func (s Source) Records(r io.Reader) ([]*store.BlacklistRecord, *store.SyncQuality, error) {
+	people, err := s.Parse(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []*store.BlacklistRecord
+	var report qualityReport
+	now := time.Now()
+	for _, p := range people {
+		report.add(scorePerson(p, now))
+		name := truncate(strings.Join(strings.Fields(p.Name), " "), maxNameLength)
+		if p.ID == "" || name == "" {
+			continue
+		}
+
+		listed := s.Title
+		if len(p.Programs) > 0 {
+			sort.Strings(p.Programs)
+			listed += " (" + strings.Join(p.Programs, ", ") + ")"
+		}
+
+		var aliases []store.Alias
+		for _, alias := range p.Aliases {
+			alias.Alias = truncate(alias.Alias, maxNameLength)
+			aliases = append(aliases, alias)
+		}
+
+		// One record per published birth date, or a single record without one
+		var dates []*time.Time
+		for _, date := range dedupeDates(p.BirthDates) {
+			date := date
+			dates = append(dates, &date)
+		}
+		if len(dates) == 0 {
+			dates = []*time.Time{nil}
+		}
+
+		for i, date := range dates {
+			key := s.Name + ":" + p.ID
+			if i > 0 {
+				key += "/" + strconv.Itoa(i)
+			}
+			records = append(records, &store.BlacklistRecord{
+				List:         s.List,
+				NIK:          key,
+				Name:         name,
+				BirthPlace:   truncate(strings.TrimSpace(p.BirthPlace), maxBirthPlaceLength),
+				BirthDate:    date,
+				ReasonCode:   "sanctions",
+				ReasonParams: store.ReasonParams{"list": listed},
+				Aliases:      aliases,
+			})
+		}
+	}
+	return records, report.quality(), nil
+}
+
+// dedupeDates drops repeated dates, keeping the order they were published in
+func dedupeDates(dates []time.Time) []time.Time {
+	seen := make(map[time.Time]bool, len(dates))
+	var unique []time.Time
+	for _, d := range dates {
+		if !seen[d] {
+			seen[d] = true
+			unique = append(unique, d)
+		}
+	}
+	return unique
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// addBirthDate adds the date of birth in value, or counts it as malformed
+// when it isn't a full date in any of layouts. Empty values are ignored.
+func (p *Person) addBirthDate(value string, layouts ...string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	if date, ok := parseDate(value, layouts...); ok {
+		p.BirthDates = append(p.BirthDates, date)
+		return
+	}
+	p.MalformedBirthDates++
+}
+
+// addAlias adds another name the person is listed under, skipping blanks,
+// the person's own name and aliases already added
+func (p *Person) addAlias(name, aliasType string) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || strings.EqualFold(name, strings.Join(strings.Fields(p.Name), " ")) {
+		return
+	}
+	for _, alias := range p.Aliases {
+		if strings.EqualFold(alias.Alias, name) {
+			return
+		}
+	}
+	p.Aliases = append(p.Aliases, store.Alias{Alias: name, Type: aliasType})
+}
+
+// parseDate parses a full date in any of layouts. Partial dates such as a
+// bare year can't be matched and yield false.
+func parseDate(value string, layouts ...string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// decodeElements decodes every element named name in r and passes it to fn,
+// streaming so large files aren't held in memory
+func decodeElements[T any](r io.Reader, name string, fn func(*T)) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error parsing XML: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != name {
+			continue
+		}
+		var v T
+		if err := decoder.DecodeElement(&v, &start); err != nil {
+			return fmt.Errorf("error parsing %s element: %w", name, err)
+		}
+		fn(&v)
+	}
+}
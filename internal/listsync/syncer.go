@@ -0,0 +1,247 @@
+package listsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrQuarantineNotFound is returned when a quarantine entry does not exist
+	ErrQuarantineNotFound = errors.New("quarantine entry not found")
+	// ErrQuarantineDecided is returned when a quarantine entry was already approved or rejected
+	ErrQuarantineDecided = errors.New("quarantine entry already decided")
+)
+
+// Result describes the outcome of a sync run
+type Result struct {
+	List         string  `json:"list"`
+	Source       string  `json:"source"`
+	Added        int     `json:"added"`
+	Updated      int     `json:"updated"`
+	Deleted      int     `json:"deleted"`
+	ChangeRatio  float64 `json:"change_ratio"`
+	Quarantined  bool    `json:"quarantined"`
+	QuarantineID int64   `json:"quarantine_id,omitempty"`
+	// Quality is the data quality of the snapshot, when it was scored
+	Quality *store.SyncQuality `json:"quality,omitempty"`
+}
+
+// Syncer applies source snapshots to the blacklist, quarantining suspicious change sets
+type Syncer struct {
+	store      store.BlacklistStore
+	quarantine store.QuarantineStore
+	status     store.SyncStatusStore
+	service    *service.BlacklistService
+	threshold  float64
+	minQuality float64
+	log        *zap.Logger
+}
+
+// NewSyncer creates a new syncer
+func NewSyncer(cfg *config.Config, store store.BlacklistStore, quarantine store.QuarantineStore, status store.SyncStatusStore, service *service.BlacklistService, log *zap.Logger) *Syncer {
+	return &Syncer{
+		store:      store,
+		quarantine: quarantine,
+		status:     status,
+		service:    service,
+		threshold:  cfg.Sync.QuarantineThreshold,
+		minQuality: cfg.Sync.QualityMinScore,
+		log:        log,
+	}
+}
+
+// Sync diffs a full snapshot of a source against the records it contributed
+// to a list and applies the delta. A change set that changes too much of the
+// list, or comes from a snapshot of poor quality, is quarantined instead.
+func (s *Syncer) Sync(ctx context.Context, list, source string, snapshot []*store.BlacklistRecord, quality *store.SyncQuality) (*Result, error) {
+	if !lists.Known(list) {
+		return nil, fmt.Errorf("unknown list %q", list)
+	}
+	current, err := s.store.ListBySource(ctx, list, source)
+	if err != nil {
+		return nil, fmt.Errorf("error loading records for source %s: %w", source, err)
+	}
+
+	cs := Diff(list, source, current, snapshot)
+	ratio := changeRatio(len(current), cs)
+	result := &Result{
+		List:        list,
+		Source:      source,
+		Added:       len(cs.Added),
+		Updated:     len(cs.Updated),
+		Deleted:     len(cs.Deleted),
+		ChangeRatio: ratio,
+		Quality:     quality,
+	}
+
+	poor := quality != nil && quality.Score < s.minQuality
+	if ratio > s.threshold || poor {
+		id, err := s.quarantine.Create(ctx, &store.QuarantineEntry{
+			TotalRecords: len(current),
+			ChangeRatio:  ratio,
+			Quality:      quality,
+		}, cs)
+		if err != nil {
+			return nil, fmt.Errorf("error quarantining change set: %w", err)
+		}
+		result.Quarantined = true
+		result.QuarantineID = id
+		fields := []zap.Field{
+			zap.String("source", source),
+			zap.Int64("quarantine_id", id),
+			zap.Float64("change_ratio", ratio),
+			zap.Float64("threshold", s.threshold),
+		}
+		if quality != nil {
+			fields = append(fields,
+				zap.Float64("quality_score", quality.Score),
+				zap.Float64("min_quality_score", s.minQuality))
+		}
+		s.log.Warn("Sync change set quarantined", fields...)
+		return result, nil
+	}
+
+	if err := s.apply(store.WithActor(ctx, "sync:"+source), cs); err != nil {
+		return nil, err
+	}
+	s.log.Info("Sync change set applied",
+		zap.String("source", source),
+		zap.Int("added", result.Added),
+		zap.Int("updated", result.Updated),
+		zap.Int("deleted", result.Deleted))
+
+	return result, nil
+}
+
+// ListQuarantined returns change sets awaiting approval
+func (s *Syncer) ListQuarantined(ctx context.Context) ([]*store.QuarantineEntry, error) {
+	return s.quarantine.ListPending(ctx)
+}
+
+// Approve applies a quarantined change set, refreshing its source as of when
+// the change set was quarantined. The change set is rebased on the source's
+// records as they are now, which later syncs or edits may have changed, and
+// applied in the transaction that approves it, so it is applied once.
+func (s *Syncer) Approve(ctx context.Context, id int64, approver string) error {
+	entry, err := s.quarantine.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error loading quarantine entry: %w", err)
+	}
+	if entry == nil {
+		return ErrQuarantineNotFound
+	}
+	if entry.Status != store.QuarantinePending {
+		return ErrQuarantineDecided
+	}
+
+	ctx = store.WithActor(ctx, approver)
+	var cs *store.ChangeSet
+	entry, err = s.quarantine.Approve(ctx, id, approver, func(ctx context.Context, entry *store.QuarantineEntry) error {
+		held, err := entry.Decode()
+		if err != nil {
+			return err
+		}
+		if held.List == "" {
+			// Quarantined before records were split into lists
+			held.List = lists.Internal
+		}
+		current, err := s.store.ListBySource(ctx, held.List, held.Source)
+		if err != nil {
+			return fmt.Errorf("error loading records for source %s: %w", held.Source, err)
+		}
+		cs = Rebase(held, current)
+		if err := s.store.ApplyChangeSet(ctx, cs); err != nil {
+			return fmt.Errorf("error applying change set: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return ErrQuarantineDecided
+	}
+	s.invalidate(ctx, cs)
+	// The source's records are now as current as the file that was held back
+	if err := s.status.Refreshed(ctx, entry.Source, entry.CreatedAt); err != nil {
+		s.log.Error("Error recording source refresh",
+			zap.String("source", entry.Source),
+			zap.Error(err))
+	}
+
+	s.log.Info("Quarantined change set approved",
+		zap.Int64("quarantine_id", id),
+		zap.String("source", entry.Source),
+		zap.String("approver", approver),
+		zap.Int("added", len(cs.Added)),
+		zap.Int("updated", len(cs.Updated)),
+		zap.Int("deleted", len(cs.Deleted)))
+	return nil
+}
+
+// Reject discards a quarantined change set
+func (s *Syncer) Reject(ctx context.Context, id int64, approver string) error {
+	entry, err := s.quarantine.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error loading quarantine entry: %w", err)
+	}
+	if entry == nil {
+		return ErrQuarantineNotFound
+	}
+	if entry.Status != store.QuarantinePending {
+		return ErrQuarantineDecided
+	}
+	if err := s.quarantine.Decide(ctx, id, store.QuarantineRejected, approver); err != nil {
+		return fmt.Errorf("error recording rejection: %w", err)
+	}
+
+	s.log.Info("Quarantined change set rejected",
+		zap.Int64("quarantine_id", id),
+		zap.String("source", entry.Source),
+		zap.String("approver", approver))
+	return nil
+}
+
+// apply writes a change set and drops cache entries for every touched NIK
+func (s *Syncer) apply(ctx context.Context, cs *store.ChangeSet) error {
+	if err := s.store.ApplyChangeSet(ctx, cs); err != nil {
+		return fmt.Errorf("error applying change set: %w", err)
+	}
+	s.invalidate(ctx, cs)
+	return nil
+}
+
+// invalidate drops cache entries for every NIK a written change set touched
+func (s *Syncer) invalidate(ctx context.Context, cs *store.ChangeSet) {
+	niks := make([]string, 0, len(cs.Added)+len(cs.Updated)+len(cs.Deleted))
+	for _, record := range cs.Added {
+		niks = append(niks, record.NIK)
+	}
+	for _, record := range cs.Updated {
+		niks = append(niks, record.NIK)
+	}
+	niks = append(niks, cs.Deleted...)
+
+	if err := s.service.InvalidateRecords(ctx, niks...); err != nil {
+		s.log.Error("Error invalidating cache after sync",
+			zap.String("source", cs.Source),
+			zap.Error(err))
+	}
+}
+
+// changeRatio returns the share of existing records a change set would delete or modify
+func changeRatio(total int, cs *store.ChangeSet) float64 {
+	if total == 0 {
+		// A brand new source has nothing to protect
+		return 0
+	}
+	return float64(len(cs.Updated)+len(cs.Deleted)) / float64(total)
+}
@@ -0,0 +1,61 @@
+package listsync
+
+import (
+	"io"
+	"strings"
+
+	"blacklist-check/internal/store"
+)
+
+// unIndividual is an INDIVIDUAL of the UN Consolidated List XML
+type unIndividual struct {
+	DataID   string `xml:"DATAID"`
+	ListType string `xml:"UN_LIST_TYPE"`
+	Births   []struct {
+		Date string `xml:"DATE"`
+	} `xml:"INDIVIDUAL_DATE_OF_BIRTH"`
+	Places []struct {
+		City    string `xml:"CITY"`
+		Country string `xml:"COUNTRY"`
+	} `xml:"INDIVIDUAL_PLACE_OF_BIRTH"`
+	First   string `xml:"FIRST_NAME"`
+	Second  string `xml:"SECOND_NAME"`
+	Third   string `xml:"THIRD_NAME"`
+	Fourth  string `xml:"FOURTH_NAME"`
+	Aliases []struct {
+		Name string `xml:"ALIAS_NAME"`
+	} `xml:"INDIVIDUAL_ALIAS"`
+}
+
+// parseUN reads the individuals of the UN Security Council Consolidated List.
+// Only dates of birth given in full (YYYY-MM-DD) are kept; entries known by
+// year or range alone carry only a YEAR or FROM_YEAR/TO_YEAR.
+func parseUN(r io.Reader) ([]*Person, error) {
+	var people []*Person
+	err := decodeElements(r, "INDIVIDUAL", func(e *unIndividual) {
+		person := &Person{
+			ID:   e.DataID,
+			Name: strings.Join([]string{e.First, e.Second, e.Third, e.Fourth}, " "),
+		}
+		if e.ListType != "" {
+			person.Programs = []string{e.ListType}
+		}
+		for _, place := range e.Places {
+			if place.City != "" {
+				person.BirthPlace = place.City
+				break
+			}
+			if person.BirthPlace == "" {
+				person.BirthPlace = place.Country
+			}
+		}
+		for _, birth := range e.Births {
+			person.addBirthDate(birth.Date, "2006-01-02")
+		}
+		for _, alias := range e.Aliases {
+			person.addAlias(alias.Name, store.AliasAKA)
+		}
+		people = append(people, person)
+	})
+	return people, err
+}
@@ -0,0 +1,524 @@
+// Package metrics defines every Prometheus metric the service exports, so
+// each is declared and registered exactly once
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache names used as the "cache" label
+const (
+	CacheRedis    = "redis"
+	CacheLocalNIK = "local_nik"
+)
+
+var (
+	// HTTPRequestsTotal counts HTTP requests by method, endpoint and status
+	HTTPRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests",
+		},
+		[]string{"method", "endpoint", "status"},
+	)
+
+	// HTTPRequestDuration observes HTTP request latency
+	HTTPRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "endpoint"},
+	)
+
+	// BlacklistChecksTotal counts screening decisions by match type and result
+	BlacklistChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blacklist_checks_total",
+			Help: "Total number of blacklist checks",
+		},
+		[]string{"match_type", "result"},
+	)
+
+	// CheckDecisionsTotal counts screening decisions by risk score band
+	CheckDecisionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blacklist_check_decisions_total",
+			Help: "Total number of blacklist checks by decision band",
+		},
+		[]string{"decision"},
+	)
+
+	// CacheHitsTotal counts lookups served from a cache
+	CacheHitsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_hits_total",
+			Help: "Total number of cache hits",
+		},
+		[]string{"cache"},
+	)
+
+	// CacheMissesTotal counts lookups that fell through to the database
+	CacheMissesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_misses_total",
+			Help: "Total number of cache misses",
+		},
+		[]string{"cache"},
+	)
+
+	// DBQueryDuration observes database query latency per query type
+	DBQueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "blacklist_db_query_duration_seconds",
+			Help:    "Blacklist database query duration in seconds",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		},
+		[]string{"query"},
+	)
+
+	// FuzzyMatchCandidates observes how many records a fuzzy query returned
+	FuzzyMatchCandidates = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "fuzzy_match_candidates",
+			Help:    "Number of candidate records returned by fuzzy matching",
+			Buckets: []float64{0, 1, 2, 3, 4, 5, 10, 25},
+		},
+	)
+
+	// FuzzyMatchTruncatedTotal counts fuzzy searches cut short by the candidate budget
+	FuzzyMatchTruncatedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fuzzy_match_truncated_total",
+			Help: "Total number of fuzzy searches that considered only part of their candidates because of the candidate budget",
+		},
+		[]string{"list"},
+	)
+
+	// LookupLatencySavedSeconds observes how much sooner checks with both a
+	// NIK and a name were screened by running both lookups at once, by the
+	// lookup that decided them
+	LookupLatencySavedSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "lookup_latency_saved_seconds",
+			Help:    "Time saved per list screened by running the NIK lookup and fuzzy search concurrently rather than one after the other",
+			Buckets: []float64{0, .001, .0025, .005, .01, .025, .05, .1, .25, .5},
+		},
+		[]string{"path"},
+	)
+
+	// PanicsTotal counts recovered panics by component
+	PanicsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "panics_total",
+			Help: "Total number of recovered panics",
+		},
+		[]string{"component"},
+	)
+
+	// SyncThrottledTotal counts 429/503 responses from list sources by host
+	SyncThrottledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sync_throttled_total",
+			Help: "Total number of throttled list source requests",
+		},
+		[]string{"host"},
+	)
+
+	// MeteredChecksTotal counts checks charged to each caller
+	MeteredChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "metered_checks_total",
+			Help: "Total number of checks charged to a caller",
+		},
+		[]string{"caller"},
+	)
+
+	// CheckCostUnitsTotal accumulates the estimated cost of checks by caller and operation
+	CheckCostUnitsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "check_cost_units_total",
+			Help: "Total estimated cost units of checks",
+		},
+		[]string{"caller", "operation"},
+	)
+
+	// DependencyTimeoutsTotal counts checks' dependencies that ran past their deadlines
+	DependencyTimeoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dependency_timeouts_total",
+			Help: "Total number of dependency calls and checks that exceeded their deadline",
+		},
+		[]string{"dependency"},
+	)
+
+	// SyncRunsTotal counts scheduled source syncs by source and outcome
+	SyncRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sync_runs_total",
+			Help: "Total number of scheduled list source syncs",
+		},
+		[]string{"source", "result"},
+	)
+
+	// SyncSourceRecords reports how many records the last sync of a source parsed
+	SyncSourceRecords = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sync_source_records",
+			Help: "Number of records in the last synced file of a list source",
+		},
+		[]string{"source"},
+	)
+
+	// SyncLastSuccess reports when a source last synced successfully
+	SyncLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sync_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful sync of a list source",
+		},
+		[]string{"source"},
+	)
+
+	// SyncSourceAge reports how long ago the records of a source were refreshed
+	SyncSourceAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sync_source_age_seconds",
+			Help: "Seconds since the records of a list source were last refreshed",
+		},
+		[]string{"source"},
+	)
+
+	// SyncSourceStale reports whether the records of a source are older than it allows
+	SyncSourceStale = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sync_source_stale",
+			Help: "1 when the records of a list source are older than its maximum age, 0 otherwise",
+		},
+		[]string{"source"},
+	)
+
+	// SyncSourceQuality reports the mean data quality score of the last synced file of a source
+	SyncSourceQuality = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sync_source_quality_score",
+			Help: "Mean data quality score (0-1) of the listings in the last synced file of a list source",
+		},
+		[]string{"source"},
+	)
+
+	// SyncSourceQualityIssues reports how many listings of the last synced file of a source had each issue
+	SyncSourceQualityIssues = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sync_source_quality_issues",
+			Help: "Number of listings with each data quality issue in the last synced file of a list source",
+		},
+		[]string{"source", "issue"},
+	)
+
+	// BreakGlassEventsTotal counts break-glass grants issued and revoked
+	BreakGlassEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "breakglass_events_total",
+			Help: "Total number of break-glass grant events",
+		},
+		[]string{"event"},
+	)
+
+	// BreakGlassRequestsTotal counts requests made with break-glass grants by grantee
+	BreakGlassRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "breakglass_requests_total",
+			Help: "Total number of requests authenticated with a break-glass grant",
+		},
+		[]string{"subject"},
+	)
+
+	// JobsStalledTotal counts background jobs the watchdog found stalled by kind and reason
+	JobsStalledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_stalled_total",
+			Help: "Total number of background jobs marked stalled",
+		},
+		[]string{"kind", "reason"},
+	)
+
+	// TemporaryRecordEventsTotal counts temporary record lifecycle events
+	TemporaryRecordEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "temporary_record_events_total",
+			Help: "Total number of temporary blacklist record events",
+		},
+		[]string{"event"},
+	)
+
+	// RescreensTotal counts subjects screened again by outcome; a hit was
+	// alerted as a new match
+	RescreensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rescreens_total",
+			Help: "Total number of subjects re-screened, by outcome",
+		},
+		[]string{"outcome"},
+	)
+
+	// RecordDuplicatesFoundTotal counts duplicate pairs found by detection,
+	// by why they were taken for duplicates
+	RecordDuplicatesFoundTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "record_duplicates_found_total",
+			Help: "Total number of likely duplicate record pairs found, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	// RecordDuplicatesResolvedTotal counts duplicate pairs reviewed, by action
+	RecordDuplicatesResolvedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "record_duplicates_resolved_total",
+			Help: "Total number of duplicate record pairs merged or dismissed",
+		},
+		[]string{"action"},
+	)
+
+	// RecordDuplicatesPending reports the duplicate pairs awaiting review
+	RecordDuplicatesPending = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "record_duplicates_pending",
+			Help: "Number of likely duplicate record pairs awaiting review at the last detection run",
+		},
+	)
+
+	// RecordDuplicateRate reports the share of live records in a pending pair
+	RecordDuplicateRate = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "record_duplicate_rate",
+			Help: "Share of live records in a duplicate pair awaiting review at the last detection run",
+		},
+	)
+
+	// DBReadsTotal counts lag-tolerant reads by whether a replica or the
+	// primary served them
+	DBReadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_reads_total",
+			Help: "Total number of replica-eligible database reads, by target",
+		},
+		[]string{"target"},
+	)
+
+	// DBReplicaHealthy is 1 while a read replica may serve reads
+	DBReplicaHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_replica_healthy",
+			Help: "Whether the read replica is reachable and caught up enough to serve reads",
+		},
+		[]string{"replica"},
+	)
+
+	// DBReplicaLag is how far a read replica trailed the primary at its last check
+	DBReplicaLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_replica_lag_seconds",
+			Help: "Replication lag of the read replica at its last health check",
+		},
+		[]string{"replica"},
+	)
+
+	// DBRetriesTotal counts blacklist store calls retried after a transient
+	// error, by its class
+	DBRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_retries_total",
+			Help: "Total number of database calls retried, by failure class",
+		},
+		[]string{"class"},
+	)
+
+	// DBCircuitOpen is 1 while the database circuit breaker fails calls fast
+	DBCircuitOpen = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "db_circuit_open",
+			Help: "Whether the database circuit breaker is open",
+		},
+	)
+
+	// DBCircuitRejectionsTotal counts calls failed by the open circuit breaker
+	// without reaching the database
+	DBCircuitRejectionsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "db_circuit_rejections_total",
+			Help: "Total number of database calls rejected by the open circuit breaker",
+		},
+	)
+
+	// SandboxChecksTotal counts checks by sandbox tenant callers, kept apart
+	// from the production screening metrics
+	SandboxChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sandbox_checks_total",
+			Help: "Total number of blacklist checks against the sandbox records",
+		},
+		[]string{"match_type", "decision"},
+	)
+
+	// IdempotentReplaysTotal counts retries answered with a stored response
+	IdempotentReplaysTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "idempotent_replays_total",
+			Help: "Total number of requests answered by replaying the response stored for their Idempotency-Key",
+		},
+		[]string{"endpoint"},
+	)
+
+	// DeprecatedRequestsTotal counts requests to deprecated API routes by
+	// endpoint and caller
+	DeprecatedRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_deprecated_requests_total",
+			Help: "Total number of requests to deprecated API routes",
+		},
+		[]string{"endpoint", "subject"},
+	)
+
+	// NIKFilterLookupsTotal counts NIK filter lookups by result
+	NIKFilterLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nik_filter_lookups_total",
+			Help: "Total number of NIK lookups by Bloom filter result: negative (skipped), true_positive or false_positive",
+		},
+		[]string{"result"},
+	)
+
+	// NIKFilterEntries reports how many NIKs the filter was last built with
+	NIKFilterEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nik_filter_entries",
+			Help: "Number of listed NIKs the NIK Bloom filter was last built with",
+		},
+	)
+
+	// CacheWarmupNIKs reports the progress of the startup cache warm-up
+	CacheWarmupNIKs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_warmup_niks",
+			Help: "Number of hot NIKs the startup cache warm-up is to preload (total) and has preloaded so far (loaded)",
+		},
+		[]string{"state"},
+	)
+
+	// CheckOutcomesTotal counts checks by tri-state outcome
+	CheckOutcomesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blacklist_check_outcomes_total",
+			Help: "Total number of blacklist checks by outcome, and why those left unknown were",
+		},
+		[]string{"outcome", "reason"},
+	)
+
+	// CheckWarningsTotal counts the input warnings returned by checks
+	CheckWarningsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blacklist_check_warnings_total",
+			Help: "Total number of warnings about suspect input returned by blacklist checks, by code",
+		},
+		[]string{"code"},
+	)
+
+	// DegradedChecksTotal counts checks answered partially at the caller's deadline
+	DegradedChecksTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blacklist_checks_degraded_total",
+			Help: "Total number of blacklist checks answered before every list was screened because the caller's deadline passed",
+		},
+	)
+
+	// ConfigReloadsTotal counts configuration reloads by result
+	ConfigReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Total number of configuration reloads, by result (applied or rejected)",
+		},
+		[]string{"result"},
+	)
+
+	// EventPublishFailuresTotal counts screening events Kafka didn't accept
+	EventPublishFailuresTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "screening_event_publish_failures_total",
+			Help: "Total number of screening decision events and re-screening alerts that failed to publish",
+		},
+	)
+
+	// ClockDriftSeconds reports how far the local clock is ahead of the database server's
+	ClockDriftSeconds = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "clock_drift_seconds",
+			Help: "Local clock offset from the database server clock",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		BlacklistChecksTotal,
+		CheckDecisionsTotal,
+		CacheHitsTotal,
+		CacheMissesTotal,
+		DBQueryDuration,
+		FuzzyMatchCandidates,
+		FuzzyMatchTruncatedTotal,
+		LookupLatencySavedSeconds,
+		PanicsTotal,
+		SyncThrottledTotal,
+		DependencyTimeoutsTotal,
+		SyncRunsTotal,
+		SyncSourceRecords,
+		SyncLastSuccess,
+		SyncSourceAge,
+		SyncSourceStale,
+		SyncSourceQuality,
+		SyncSourceQualityIssues,
+		BreakGlassEventsTotal,
+		BreakGlassRequestsTotal,
+		ClockDriftSeconds,
+		JobsStalledTotal,
+		TemporaryRecordEventsTotal,
+		RescreensTotal,
+		RecordDuplicatesFoundTotal,
+		RecordDuplicatesResolvedTotal,
+		RecordDuplicatesPending,
+		RecordDuplicateRate,
+		DBReadsTotal,
+		DBReplicaHealthy,
+		DBReplicaLag,
+		DBRetriesTotal,
+		DBCircuitOpen,
+		DBCircuitRejectionsTotal,
+		SandboxChecksTotal,
+		IdempotentReplaysTotal,
+		DeprecatedRequestsTotal,
+		NIKFilterLookupsTotal,
+		NIKFilterEntries,
+		CacheWarmupNIKs,
+		CheckOutcomesTotal,
+		CheckWarningsTotal,
+		DegradedChecksTotal,
+		ConfigReloadsTotal,
+		EventPublishFailuresTotal,
+		MeteredChecksTotal,
+		CheckCostUnitsTotal,
+	)
+}
+
+// ObserveQuery records the duration of a query started at start. Use with
+// defer at the top of a store method:
+//
+//	defer metrics.ObserveQuery("get_by_nik", time.Now())
+func ObserveQuery(query string, start time.Time) {
+	DBQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
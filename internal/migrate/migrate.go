@@ -0,0 +1,234 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// lockKey identifies the advisory lock held while migrating
+const lockKey int64 = 0x626c6b6d6967 // "blkmig"
+
+// ErrDirty is returned when a previous migration failed halfway and needs manual repair
+var ErrDirty = errors.New("database schema is dirty")
+
+var fileRegex = regexp.MustCompile(`^(\d+)_(.+)\.up\.sql$`)
+
+// Migration is a single up migration
+type Migration struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+	up      string
+}
+
+// Status reports the current schema version and the migrations not yet applied
+type Status struct {
+	CurrentVersion int64        `json:"current_version"`
+	Dirty          bool         `json:"dirty"`
+	Pending        []*Migration `json:"pending"`
+}
+
+// Migrator applies embedded migrations. It uses the same schema_migrations
+// table as golang-migrate, so the Makefile targets and startup runs agree.
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []*Migration
+	log        *zap.Logger
+}
+
+// NewMigrator creates a new migrator from the migration files in fsys
+func NewMigrator(db *sqlx.DB, fsys fs.FS, log *zap.Logger) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+
+	var migrations []*Migration
+	for _, entry := range entries {
+		m := fileRegex.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, &Migration{Version: version, Name: m[2], up: string(body)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return &Migrator{db: db, migrations: migrations, log: log}, nil
+}
+
+// Status returns the current schema version and pending migrations without taking the lock
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	version, dirty, err := currentVersion(ctx, m.db, m.db.DriverName())
+	if err != nil {
+		return nil, err
+	}
+	return &Status{
+		CurrentVersion: version,
+		Dirty:          dirty,
+		Pending:        m.pending(version),
+	}, nil
+}
+
+// Up applies all pending migrations while holding a database lock, so
+// replicas starting at the same time apply each migration exactly once.
+// Postgres takes an advisory lock and MySQL a named one; SQLite serves a
+// single instance and takes none.
+func (m *Migrator) Up(ctx context.Context) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	m.log.Info("Waiting for migration lock")
+	unlock, err := lock(ctx, conn, m.db.DriverName())
+	if err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			m.log.Error("Error releasing migration lock", zap.Error(err))
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty BOOLEAN NOT NULL
+		)
+	`); err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+
+	// Re-read the version under the lock; another replica may have migrated while we waited
+	version, dirty, err := currentVersion(ctx, conn, m.db.DriverName())
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w at version %d", ErrDirty, version)
+	}
+
+	pending := m.pending(version)
+	if len(pending) == 0 {
+		m.log.Info("Database schema is up to date", zap.Int64("version", version))
+		return nil
+	}
+
+	for _, migration := range pending {
+		if err := apply(ctx, conn, migration); err != nil {
+			return fmt.Errorf("error applying migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		m.log.Info("Applied migration",
+			zap.Int64("version", migration.Version),
+			zap.String("name", migration.Name))
+	}
+	return nil
+}
+
+func (m *Migrator) pending(version int64) []*Migration {
+	pending := []*Migration{}
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+// lock takes the migration lock of a driver on conn and returns its release
+func lock(ctx context.Context, conn *sqlx.Conn, driver string) (func() error, error) {
+	var (
+		acquire, release string
+		key              interface{} = lockKey
+	)
+	switch driver {
+	case "postgres":
+		acquire, release = `SELECT pg_advisory_lock($1)`, `SELECT pg_advisory_unlock($1)`
+	case "mysql":
+		acquire, release = `SELECT GET_LOCK(?, -1)`, `SELECT RELEASE_LOCK(?)`
+		key = "blacklist-check-migrate"
+	default:
+		return func() error { return nil }, nil
+	}
+	if _, err := conn.ExecContext(ctx, acquire, key); err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), release, key)
+		return err
+	}, nil
+}
+
+// apply runs a migration and records the new version in one transaction.
+// MySQL commits DDL as it runs, so a failed MySQL migration may leave part
+// of its changes behind.
+func apply(ctx context.Context, conn *sqlx.Conn, migration *Migration) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.up); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`INSERT INTO schema_migrations (version, dirty) VALUES (?, false)`), migration.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// tableExists holds, per driver, a query telling whether schema_migrations exists
+var tableExists = map[string]string{
+	"postgres": `SELECT to_regclass('schema_migrations') IS NOT NULL`,
+	"mysql":    `SELECT count(*) > 0 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'`,
+	"sqlite3":  `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`,
+}
+
+// currentVersion reads the applied schema version, treating a missing table as version 0
+func currentVersion(ctx context.Context, q sqlx.QueryerContext, driver string) (int64, bool, error) {
+	query, ok := tableExists[driver]
+	if !ok {
+		return 0, false, fmt.Errorf("unsupported driver %q", driver)
+	}
+	var exists bool
+	if err := sqlx.GetContext(ctx, q, &exists, query); err != nil {
+		return 0, false, fmt.Errorf("error checking schema_migrations: %w", err)
+	}
+	if !exists {
+		return 0, false, nil
+	}
+
+	var row struct {
+		Version int64 `db:"version"`
+		Dirty   bool  `db:"dirty"`
+	}
+	err := sqlx.GetContext(ctx, q, &row, `SELECT version, dirty FROM schema_migrations LIMIT 1`)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("error reading schema version: %w", err)
+	}
+	return row.Version, row.Dirty, nil
+}
@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// fakeDB is a database the migrator talks to over database/sql. Its
+// advisory lock is a mutex, and the migrations it applies are recorded.
+type fakeDB struct {
+	lock sync.Mutex
+
+	mu      sync.Mutex
+	table   bool
+	version int64
+	applied []string
+}
+
+func (db *fakeDB) Connect(ctx context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeDB) Driver() driver.Driver                            { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c *fakeConn) Commit() error                             { return nil }
+func (c *fakeConn) Rollback() error                           { return nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query = strings.TrimSpace(query)
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory_lock"):
+		c.db.lock.Lock()
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "SELECT pg_advisory_unlock"):
+		c.db.lock.Unlock()
+		return driver.RowsAffected(0), nil
+	}
+
+	if strings.HasPrefix(query, "CREATE TABLE ") {
+		// Gives replicas migrating without the lock time to overlap
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		c.db.table = true
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		c.db.version = args[0].Value.(int64)
+	default:
+		c.db.applied = append(c.db.applied, query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	switch {
+	case strings.Contains(query, "to_regclass"):
+		return &fakeRows{columns: []string{"exists"}, rows: [][]driver.Value{{c.db.table}}}, nil
+	case strings.Contains(query, "FROM schema_migrations") && c.db.version > 0:
+		return &fakeRows{columns: []string{"version", "dirty"}, rows: [][]driver.Value{{c.db.version, false}}}, nil
+	}
+	return &fakeRows{columns: []string{"version", "dirty"}}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestUpAppliesEachMigrationOnceAcrossReplicas(t *testing.T) {
+	fake := &fakeDB{}
+	db := sqlx.NewDb(sql.OpenDB(fake), "postgres")
+	defer db.Close()
+	fsys := fstest.MapFS{
+		"000001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id BIGINT)")},
+		"000001_create_a.down.sql": {Data: []byte("DROP TABLE a")},
+		"000002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id BIGINT)")},
+	}
+
+	const replicas = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, replicas)
+	for i := 0; i < replicas; i++ {
+		m, err := NewMigrator(db, fsys, zap.NewNop())
+		if err != nil {
+			t.Fatalf("NewMigrator() error = %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- m.Up(context.Background())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Up() error = %v", err)
+		}
+	}
+	want := []string{"CREATE TABLE a (id BIGINT)", "CREATE TABLE b (id BIGINT)"}
+	if strings.Join(fake.applied, ";") != strings.Join(want, ";") {
+		t.Errorf("applied %q, want %q once each", fake.applied, want)
+	}
+	if fake.version != 2 {
+		t.Errorf("version = %d, want 2", fake.version)
+	}
+	if !fake.lock.TryLock() {
+		t.Error("migration lock still held after Up")
+	}
+}
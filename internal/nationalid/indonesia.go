@@ -0,0 +1,115 @@
+package nationalid
+
+import (
+	"fmt"
+	"time"
+)
+
+// Provinces maps Indonesian province codes to province names
+var Provinces = map[string]string{
+	"11": "Aceh",
+	"12": "Sumatera Utara",
+	"13": "Sumatera Barat",
+	"14": "Riau",
+	"15": "Jambi",
+	"16": "Sumatera Selatan",
+	"17": "Bengkulu",
+	"18": "Lampung",
+	"19": "Kepulauan Bangka Belitung",
+	"21": "Kepulauan Riau",
+	"31": "DKI Jakarta",
+	"32": "Jawa Barat",
+	"33": "Jawa Tengah",
+	"34": "DI Yogyakarta",
+	"35": "Jawa Timur",
+	"36": "Banten",
+	"51": "Bali",
+	"52": "Nusa Tenggara Barat",
+	"53": "Nusa Tenggara Timur",
+	"61": "Kalimantan Barat",
+	"62": "Kalimantan Tengah",
+	"63": "Kalimantan Selatan",
+	"64": "Kalimantan Timur",
+	"65": "Kalimantan Utara",
+	"71": "Sulawesi Utara",
+	"72": "Sulawesi Tengah",
+	"73": "Sulawesi Selatan",
+	"74": "Sulawesi Tenggara",
+	"75": "Gorontalo",
+	"76": "Sulawesi Barat",
+	"81": "Maluku",
+	"82": "Maluku Utara",
+	"91": "Papua",
+	"92": "Papua Barat",
+	"93": "Papua Selatan",
+	"94": "Papua Tengah",
+	"95": "Papua Pegunungan",
+	"96": "Papua Barat Daya",
+}
+
+// NIK attributes
+const (
+	AttrRegencyCode  = "regency_code"
+	AttrDistrictCode = "district_code"
+	AttrSerial       = "serial"
+)
+
+func init() {
+	Register(nikValidator{})
+}
+
+// nikValidator validates Indonesian NIKs (Nomor Induk Kependudukan). A NIK is
+// PPRRDDTTMMYYSSSS: the province, regency and district of registration, the
+// holder's date of birth with 40 added to the day for women, and a serial
+// number. It carries no checksum.
+type nikValidator struct{}
+
+func (nikValidator) Country() string {
+	return "ID"
+}
+
+func (nikValidator) Parse(number string) (*ID, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return &Error{Name: "NIK", Reason: fmt.Sprintf(format, args...)}
+	}
+
+	s, ok := digitsOnly(number, 16)
+	if !ok {
+		return nil, invalid("must be a 16-digit number")
+	}
+
+	id := &ID{
+		Country:    "ID",
+		Number:     s,
+		RegionCode: s[:2],
+		Sex:        Male,
+		Attributes: map[string]string{
+			AttrRegencyCode:  s[:4],
+			AttrDistrictCode: s[:6],
+			AttrSerial:       s[12:],
+		},
+	}
+	if id.Region, ok = Provinces[id.RegionCode]; !ok {
+		return nil, invalid("has unknown province code %s", id.RegionCode)
+	}
+	// Regencies (kabupaten) are numbered from 01 and cities (kota) from 71
+	if regency := digits(s[2:4]); regency == 0 || regency > 79 {
+		return nil, invalid("has invalid regency code %s", s[2:4])
+	}
+	if s[4:6] == "00" {
+		return nil, invalid("has invalid district code %s", s[4:6])
+	}
+	if s[12:] == "0000" {
+		return nil, invalid("has invalid serial number %s", s[12:])
+	}
+
+	day, month, year := digits(s[6:8]), digits(s[8:10]), digits(s[10:12])
+	if day > 40 {
+		day -= 40
+		id.Sex = Female
+	}
+	if id.BirthDate, ok = birthDate(day, month, year, time.Now()); !ok {
+		return nil, invalid("encodes an invalid date of birth %s", s[6:12])
+	}
+	return id, nil
+}
@@ -0,0 +1,84 @@
+package nationalid
+
+import (
+	"fmt"
+	"time"
+)
+
+// myKadStates maps MyKad place-of-birth codes to Malaysian states. Codes 60
+// and above that aren't unused denote births abroad.
+var myKadStates = map[string]string{
+	"01": "Johor", "21": "Johor", "22": "Johor", "23": "Johor", "24": "Johor",
+	"02": "Kedah", "25": "Kedah", "26": "Kedah", "27": "Kedah",
+	"03": "Kelantan", "28": "Kelantan", "29": "Kelantan",
+	"04": "Melaka", "30": "Melaka",
+	"05": "Negeri Sembilan", "31": "Negeri Sembilan", "59": "Negeri Sembilan",
+	"06": "Pahang", "32": "Pahang", "33": "Pahang",
+	"07": "Pulau Pinang", "34": "Pulau Pinang", "35": "Pulau Pinang",
+	"08": "Perak", "36": "Perak", "37": "Perak", "38": "Perak", "39": "Perak",
+	"09": "Perlis", "40": "Perlis",
+	"10": "Selangor", "41": "Selangor", "42": "Selangor", "43": "Selangor", "44": "Selangor",
+	"11": "Terengganu", "45": "Terengganu", "46": "Terengganu",
+	"12": "Sabah", "47": "Sabah", "48": "Sabah", "49": "Sabah",
+	"13": "Sarawak", "50": "Sarawak", "51": "Sarawak", "52": "Sarawak", "53": "Sarawak",
+	"14": "Wilayah Persekutuan Kuala Lumpur", "54": "Wilayah Persekutuan Kuala Lumpur",
+	"55": "Wilayah Persekutuan Kuala Lumpur", "56": "Wilayah Persekutuan Kuala Lumpur",
+	"57": "Wilayah Persekutuan Kuala Lumpur",
+	"15": "Wilayah Persekutuan Labuan", "58": "Wilayah Persekutuan Labuan",
+	"16": "Wilayah Persekutuan Putrajaya",
+	"82": "Unknown state",
+}
+
+// myKadUnused lists place-of-birth codes that are never issued
+var myKadUnused = map[string]bool{
+	"00": true, "17": true, "18": true, "19": true, "20": true, "69": true,
+	"70": true, "73": true, "80": true, "81": true, "94": true, "95": true,
+	"96": true, "97": true,
+}
+
+// myKadAbroad is the region reported for births outside Malaysia
+const myKadAbroad = "Born abroad"
+
+func init() {
+	Register(myKadValidator{})
+}
+
+// myKadValidator validates Malaysian MyKad numbers, YYMMDD-PB-###G: the
+// holder's date of birth, a place-of-birth code and a serial whose last
+// digit is odd for men and even for women. It carries no checksum.
+type myKadValidator struct{}
+
+func (myKadValidator) Country() string {
+	return "MY"
+}
+
+func (myKadValidator) Parse(number string) (*ID, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return &Error{Name: "MyKad number", Reason: fmt.Sprintf(format, args...)}
+	}
+
+	s, ok := digitsOnly(number, 12)
+	if !ok {
+		return nil, invalid("must be a 12-digit number")
+	}
+
+	id := &ID{
+		Country:    "MY",
+		Number:     s,
+		RegionCode: s[6:8],
+		Sex:        Male,
+	}
+	if myKadUnused[id.RegionCode] {
+		return nil, invalid("has invalid place-of-birth code %s", id.RegionCode)
+	}
+	if id.Region, ok = myKadStates[id.RegionCode]; !ok {
+		id.Region = myKadAbroad
+	}
+	if digits(s[11:])%2 == 0 {
+		id.Sex = Female
+	}
+	if id.BirthDate, ok = birthDate(digits(s[4:6]), digits(s[2:4]), digits(s[:2]), time.Now()); !ok {
+		return nil, invalid("encodes an invalid date of birth %s", s[:6])
+	}
+	return id, nil
+}
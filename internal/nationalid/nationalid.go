@@ -0,0 +1,151 @@
+// Package nationalid validates national ID numbers and decodes the data they
+// embed. Each country is a plugin implementing Validator that registers
+// itself from an init function; Parse dispatches on the ISO 3166-1 alpha-2
+// country code.
+package nationalid
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCountry is assumed when a request doesn't name one
+const DefaultCountry = "ID"
+
+// Sexes encoded in a national ID
+const (
+	Male   = "male"
+	Female = "female"
+)
+
+// Error describes why a national ID is invalid
+type Error struct {
+	// Name is the local name of the ID, e.g. NIK or MyKad number
+	Name   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	if e.Name == "" {
+		return e.Reason
+	}
+	return e.Name + " " + e.Reason
+}
+
+// ErrBirthDateMismatch is returned when a submitted birth date disagrees
+// with the date of birth encoded in the ID
+var ErrBirthDateMismatch = &Error{Name: "national ID", Reason: "encodes a date of birth that doesn't match birth_date"}
+
+// ID is a validated national ID and the data it embeds. Fields a country's
+// ID doesn't encode are left empty.
+type ID struct {
+	Country string
+	// Number is the ID without formatting such as dashes
+	Number string
+	// RegionCode and Region are where the holder was registered or born
+	RegionCode string
+	Region     string
+	// BirthDate is resolved to the most recent century that isn't in the
+	// future, as IDs only hold two-digit years
+	BirthDate time.Time
+	Sex       string
+	// Attributes holds country-specific fields, e.g. the regency of a NIK
+	Attributes map[string]string
+}
+
+// MatchesBirthDate reports whether t falls on the encoded date of birth.
+// Only the last two digits of the year are compared, as that is all an ID
+// holds. IDs without a date of birth match any date.
+func (id *ID) MatchesBirthDate(t time.Time) bool {
+	if id.BirthDate.IsZero() {
+		return true
+	}
+	return t.Day() == id.BirthDate.Day() &&
+		t.Month() == id.BirthDate.Month() &&
+		t.Year()%100 == id.BirthDate.Year()%100
+}
+
+// Validator validates and decodes the national IDs of one country
+type Validator interface {
+	// Country returns the ISO 3166-1 alpha-2 code the validator handles
+	Country() string
+	// Parse validates the format and any checksum of number and extracts
+	// the data it embeds, returning an *Error when it is invalid
+	Parse(number string) (*ID, error)
+}
+
+var validators = map[string]Validator{}
+
+// Register makes a validator available to Parse. It panics when the
+// country already has one, as plugins register from init functions.
+func Register(v Validator) {
+	country := strings.ToUpper(v.Country())
+	if _, ok := validators[country]; ok {
+		panic("nationalid: duplicate validator for " + country)
+	}
+	validators[country] = v
+}
+
+// Countries lists the countries with a registered validator
+func Countries() []string {
+	countries := make([]string, 0, len(validators))
+	for country := range validators {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+// Parse validates number with the validator of country, or of DefaultCountry
+// when country is empty
+func Parse(country, number string) (*ID, error) {
+	if country == "" {
+		country = DefaultCountry
+	}
+	v, ok := validators[strings.ToUpper(country)]
+	if !ok {
+		return nil, &Error{Reason: fmt.Sprintf("national IDs of country %q aren't supported (supported: %s)",
+			country, strings.Join(Countries(), ", "))}
+	}
+	return v.Parse(number)
+}
+
+// birthDate resolves a two-digit year to the latest century that doesn't put
+// the date after now, reporting false when the date doesn't exist
+func birthDate(day, month, year int, now time.Time) (time.Time, bool) {
+	century := now.Year() / 100 * 100
+	date := time.Date(century+year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.After(now) {
+		century -= 100
+		date = time.Date(century+year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	}
+	// time.Date normalizes out-of-range values, e.g. 31 February to 3 March
+	if date.Day() != day || int(date.Month()) != month {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// digitsOnly strips separators from a formatted number and reports whether
+// what remains is exactly n digits
+func digitsOnly(number string, n int) (string, bool) {
+	number = strings.NewReplacer("-", "", " ", "").Replace(number)
+	if len(number) != n {
+		return "", false
+	}
+	for _, c := range number {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return number, true
+}
+
+// digits parses a string already known to hold only digits
+func digits(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,131 @@
+package nationalid
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		country string
+		number  string
+		want    *ID
+		wantErr bool
+	}{
+		{
+			name:   "NIK of a man",
+			number: "3171011505900001",
+			want: &ID{Country: "ID", Number: "3171011505900001", RegionCode: "31", Region: "DKI Jakarta",
+				BirthDate: date(1990, 5, 15), Sex: Male},
+		},
+		{
+			name:    "NIK of a woman has 40 added to the day",
+			country: "id",
+			number:  "3273015505900002",
+			want: &ID{Country: "ID", Number: "3273015505900002", RegionCode: "32", Region: "Jawa Barat",
+				BirthDate: date(1990, 5, 15), Sex: Female},
+		},
+		{name: "NIK too short", number: "317101150590", wantErr: true},
+		{name: "NIK with letters", number: "31710115059000AB", wantErr: true},
+		{name: "NIK of unknown province", number: "9971011505900001", wantErr: true},
+		{name: "NIK of regency 00", number: "3100011505900001", wantErr: true},
+		{name: "NIK of district 00", number: "3171001505900001", wantErr: true},
+		{name: "NIK with serial 0000", number: "3171011505900000", wantErr: true},
+		{name: "NIK of 31 February", number: "3171013102900001", wantErr: true},
+		{
+			name:    "MyKad number with dashes",
+			country: "MY",
+			number:  "900515-14-5677",
+			want: &ID{Country: "MY", Number: "900515145677", RegionCode: "14",
+				Region: "Wilayah Persekutuan Kuala Lumpur", BirthDate: date(1990, 5, 15), Sex: Male},
+		},
+		{
+			name:    "MyKad number of a woman born abroad",
+			country: "MY",
+			number:  "900515-71-5678",
+			want: &ID{Country: "MY", Number: "900515715678", RegionCode: "71",
+				Region: myKadAbroad, BirthDate: date(1990, 5, 15), Sex: Female},
+		},
+		{name: "MyKad number with unused place code", country: "MY", number: "900515-17-5677", wantErr: true},
+		{name: "MyKad number of 13th month", country: "MY", number: "901315-14-5677", wantErr: true},
+		{
+			name:    "PhilSys number",
+			country: "PH",
+			number:  "1234-5678-9012",
+			want:    &ID{Country: "PH", Number: "123456789012"},
+		},
+		{name: "PhilSys number of zeros", country: "PH", number: "0000-0000-0000", wantErr: true},
+		{name: "unsupported country", country: "SG", number: "S1234567D", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.country, tt.number)
+			if tt.wantErr {
+				var idErr *Error
+				if !errors.As(err, &idErr) {
+					t.Fatalf("Parse() error = %v, want an *Error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got.Country != tt.want.Country || got.Number != tt.want.Number ||
+				got.RegionCode != tt.want.RegionCode || got.Region != tt.want.Region ||
+				!got.BirthDate.Equal(tt.want.BirthDate) || got.Sex != tt.want.Sex {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBirthDateCentury(t *testing.T) {
+	now := date(2024, 6, 1)
+	tests := []struct {
+		day, month, year int
+		want             time.Time
+	}{
+		{15, 5, 24, date(2024, 5, 15)},
+		// Later this year would be in the future, so it was last century
+		{15, 7, 24, date(1924, 7, 15)},
+		{1, 1, 90, date(1990, 1, 1)},
+		{29, 2, 0, date(2000, 2, 29)},
+	}
+	for _, tt := range tests {
+		got, ok := birthDate(tt.day, tt.month, tt.year, now)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("birthDate(%d, %d, %d) = %s, %t, want %s", tt.day, tt.month, tt.year, got, ok, tt.want)
+		}
+	}
+	if _, ok := birthDate(29, 2, 1, now); ok {
+		t.Error("birthDate accepted 29 February 2001")
+	}
+}
+
+func TestMatchesBirthDate(t *testing.T) {
+	id := &ID{BirthDate: date(1990, 5, 15)}
+	tests := []struct {
+		date time.Time
+		want bool
+	}{
+		{date(1990, 5, 15), true},
+		// Only the last two digits of the year are encoded
+		{date(2090, 5, 15), true},
+		{date(1990, 5, 16), false},
+		{date(1991, 5, 15), false},
+	}
+	for _, tt := range tests {
+		if got := id.MatchesBirthDate(tt.date); got != tt.want {
+			t.Errorf("MatchesBirthDate(%s) = %t, want %t", tt.date.Format("2006-01-02"), got, tt.want)
+		}
+	}
+	if !(&ID{}).MatchesBirthDate(date(1990, 5, 15)) {
+		t.Error("an ID without a birth date didn't match any date")
+	}
+}
+
+func date(year, month, day int) time.Time {
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,26 @@
+package nationalid
+
+func init() {
+	Register(philSysValidator{})
+}
+
+// philSysValidator validates Philippine PhilSys Numbers (PSN). A PSN is a
+// randomly assigned 12-digit number, usually written 1234-5678-9012, that
+// embeds no personal data, and no check digit algorithm has been published,
+// so only the format is validated.
+type philSysValidator struct{}
+
+func (philSysValidator) Country() string {
+	return "PH"
+}
+
+func (philSysValidator) Parse(number string) (*ID, error) {
+	s, ok := digitsOnly(number, 12)
+	if !ok {
+		return nil, &Error{Name: "PhilSys number", Reason: "must be a 12-digit number"}
+	}
+	if s == "000000000000" {
+		return nil, &Error{Name: "PhilSys number", Reason: "must not be all zeros"}
+	}
+	return &ID{Country: "PH", Number: s}, nil
+}
@@ -0,0 +1,176 @@
+// Package nikcrypt protects stored NIKs. A NIK is encrypted with AES-256-GCM
+// for storage, and keyed with HMAC-SHA256 for exact lookups, which unlike a
+// plain hash can't be reversed by hashing every possible NIK without the key.
+package nikcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"blacklist-check/pkg/config"
+)
+
+// version prefixes every ciphertext, so the format or key can change later
+// without losing track of what older rows hold
+const version byte = 1
+
+// keySize is the length of both keys, decoded
+const keySize = 32
+
+// ErrCiphertext is returned for a stored NIK that can't be decrypted, because
+// it is malformed or was encrypted under another key
+var ErrCiphertext = errors.New("NIK ciphertext can't be decrypted")
+
+// Keys encrypts and keys NIKs under the current keys. Previous keys, being
+// rotated out, still decrypt and match the NIKs protected under them.
+type Keys struct {
+	// aeads and macs hold the current key first, then the previous ones
+	aeads []cipher.AEAD
+	macs  [][]byte
+}
+
+// FromConfig returns the keys NIK_ENCRYPTION_KEY and NIK_HMAC_KEY hold, with
+// the previous keys of NIK_PREVIOUS_ENCRYPTION_KEYS and NIK_PREVIOUS_HMAC_KEYS,
+// or nil when no key is set and NIKs are stored in plaintext
+func FromConfig(cfg *config.Config) (*Keys, error) {
+	previousEncryption, previousHMAC := splitKeys(cfg.NIK.PreviousEncryptionKeys), splitKeys(cfg.NIK.PreviousHMACKeys)
+	if cfg.NIK.EncryptionKey == "" && cfg.NIK.HMACKey == "" {
+		if len(previousEncryption) > 0 || len(previousHMAC) > 0 {
+			return nil, errors.New("previous NIK keys need NIK_ENCRYPTION_KEY and NIK_HMAC_KEY")
+		}
+		return nil, nil
+	}
+	keys, err := New(cfg.NIK.EncryptionKey, cfg.NIK.HMACKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := keys.Retire(previousEncryption, previousHMAC); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func splitKeys(list string) []string {
+	var keys []string
+	for _, key := range strings.Split(list, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// New creates keys from base64-encoded 32-byte encryption and HMAC keys
+func New(encryptionKey, hmacKey string) (*Keys, error) {
+	k := &Keys{}
+	if err := k.add("NIK_ENCRYPTION_KEY", []string{encryptionKey}, "NIK_HMAC_KEY", []string{hmacKey}); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Retire adds previous encryption and HMAC keys, base64-encoded like the
+// current ones, which Decrypt and MACs keep trying after the current keys
+func (k *Keys) Retire(encryptionKeys, hmacKeys []string) error {
+	return k.add("NIK_PREVIOUS_ENCRYPTION_KEYS", encryptionKeys, "NIK_PREVIOUS_HMAC_KEYS", hmacKeys)
+}
+
+func (k *Keys) add(encryptionName string, encryptionKeys []string, hmacName string, hmacKeys []string) error {
+	for _, key := range encryptionKeys {
+		enc, err := decodeKey(encryptionName, key)
+		if err != nil {
+			return err
+		}
+		block, err := aes.NewCipher(enc)
+		if err != nil {
+			return err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+		k.aeads = append(k.aeads, aead)
+	}
+	for _, key := range hmacKeys {
+		mac, err := decodeKey(hmacName, key)
+		if err != nil {
+			return err
+		}
+		k.macs = append(k.macs, mac)
+	}
+	return nil
+}
+
+func decodeKey(name, key string) ([]byte, error) {
+	if key == "" {
+		return nil, fmt.Errorf("%s is required with NIK encryption", name)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decoded) != keySize {
+		return nil, fmt.Errorf("%s must be %d bytes, base64-encoded", name, keySize)
+	}
+	return decoded, nil
+}
+
+// Encrypt returns nik encrypted under the current key and a random nonce,
+// base64-encoded with its version and nonce
+func (k *Keys) Encrypt(nik string) string {
+	aead := k.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Sprintf("nikcrypt: reading random nonce: %v", err))
+	}
+	sealed := append([]byte{version}, nonce...)
+	sealed = aead.Seal(sealed, nonce, []byte(nik), []byte{version})
+	return base64.StdEncoding.EncodeToString(sealed)
+}
+
+// Decrypt returns the NIK Encrypt sealed in ciphertext under the current or
+// a previous key
+func (k *Keys) Decrypt(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < 1 || sealed[0] != version {
+		return "", ErrCiphertext
+	}
+	for _, aead := range k.aeads {
+		if len(sealed) < 1+aead.NonceSize() {
+			break
+		}
+		nonce, box := sealed[1:1+aead.NonceSize()], sealed[1+aead.NonceSize():]
+		if nik, err := aead.Open(nil, nonce, box, []byte{version}); err == nil {
+			return string(nik), nil
+		}
+	}
+	return "", ErrCiphertext
+}
+
+// MAC returns the hex HMAC-SHA256 of nik under the current key, the same for
+// every call so that it can be looked up
+func (k *Keys) MAC(nik string) string {
+	return mac(k.macs[0], nik)
+}
+
+// MACs returns the hex HMAC-SHA256 of nik under the current key, then under
+// each previous one, so that lookups find NIKs not yet rewritten under the
+// current key
+func (k *Keys) MACs(nik string) []string {
+	macs := make([]string, len(k.macs))
+	for i, key := range k.macs {
+		macs[i] = mac(key, nik)
+	}
+	return macs
+}
+
+func mac(key []byte, nik string) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(nik))
+	return hex.EncodeToString(h.Sum(nil))
+}
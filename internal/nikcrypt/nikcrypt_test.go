@@ -0,0 +1,145 @@
+package nikcrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"blacklist-check/pkg/config"
+)
+
+var (
+	testEncryptionKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("e", keySize)))
+	testHMACKey       = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("h", keySize)))
+)
+
+func TestNew(t *testing.T) {
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+	tests := []struct {
+		name             string
+		encryption, hmac string
+		wantErr          bool
+	}{
+		{"both keys", testEncryptionKey, testHMACKey, false},
+		{"missing encryption key", "", testHMACKey, true},
+		{"missing HMAC key", testEncryptionKey, "", true},
+		{"short key", short, testHMACKey, true},
+		{"not base64", "not base64!", testHMACKey, true},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.encryption, tt.hmac); (err != nil) != tt.wantErr {
+			t.Errorf("%s: New() error = %v, wantErr %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFromConfigWithoutKeys(t *testing.T) {
+	keys, err := FromConfig(&config.Config{})
+	if keys != nil || err != nil {
+		t.Errorf("FromConfig() = %v, %v, want nil, nil", keys, err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keys := testKeys(t)
+	const nik = "3171011505900001"
+
+	a, b := keys.Encrypt(nik), keys.Encrypt(nik)
+	if a == b {
+		t.Error("Encrypt used the same nonce twice")
+	}
+	for _, ciphertext := range []string{a, b} {
+		got, err := keys.Decrypt(ciphertext)
+		if err != nil || got != nik {
+			t.Errorf("Decrypt() = %q, %v, want %q", got, err, nik)
+		}
+	}
+
+	other, err := New(testHMACKey, testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(a)
+	tampered[len(tampered)-3] ^= 1
+	for name, ciphertext := range map[string]string{
+		"not base64":  "%%%",
+		"too short":   base64.StdEncoding.EncodeToString([]byte{version}),
+		"tampered":    string(tampered),
+		"another key": other.Encrypt(nik),
+	} {
+		if _, err := keys.Decrypt(ciphertext); !errors.Is(err, ErrCiphertext) {
+			t.Errorf("%s: Decrypt() error = %v, want ErrCiphertext", name, err)
+		}
+	}
+}
+
+func TestMAC(t *testing.T) {
+	keys := testKeys(t)
+	if keys.MAC("3171011505900001") != keys.MAC("3171011505900001") {
+		t.Error("MAC of the same NIK differ")
+	}
+	if keys.MAC("3171011505900001") == keys.MAC("3171011505900002") {
+		t.Error("MAC of different NIKs are equal")
+	}
+	other, err := New(testEncryptionKey, testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.MAC("3171011505900001") == other.MAC("3171011505900001") {
+		t.Error("MAC under different keys are equal")
+	}
+}
+
+func TestRetire(t *testing.T) {
+	const nik = "3171011505900001"
+	previous := testKeys(t)
+	keys, err := New(testHMACKey, testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Retire([]string{testEncryptionKey}, []string{testHMACKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := keys.Decrypt(previous.Encrypt(nik)); err != nil || got != nik {
+		t.Errorf("Decrypt() of a NIK under the previous key = %q, %v, want %q", got, err, nik)
+	}
+	if _, err := previous.Decrypt(keys.Encrypt(nik)); !errors.Is(err, ErrCiphertext) {
+		t.Errorf("Encrypt() didn't use the current key: previous key decrypts it, error = %v", err)
+	}
+	macs := keys.MACs(nik)
+	if len(macs) != 2 || macs[0] != keys.MAC(nik) || macs[1] != previous.MAC(nik) {
+		t.Errorf("MACs() = %v, want the current then the previous key's", macs)
+	}
+	if err := keys.Retire([]string{"short"}, nil); err == nil {
+		t.Error("Retire() accepted a malformed key")
+	}
+}
+
+func TestFromConfigPreviousKeys(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.NIK.PreviousHMACKeys = testHMACKey
+	if _, err := FromConfig(cfg); err == nil {
+		t.Error("FromConfig() accepted previous keys without current ones")
+	}
+
+	cfg.NIK.EncryptionKey, cfg.NIK.HMACKey = testHMACKey, testEncryptionKey
+	cfg.NIK.PreviousEncryptionKeys = " " + testEncryptionKey + ", "
+	keys, err := FromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(keys.MACs("3171011505900001")); got != 2 {
+		t.Errorf("MACs() returned %d hashes, want 2", got)
+	}
+}
+
+func testKeys(t *testing.T) *Keys {
+	t.Helper()
+	keys, err := New(testEncryptionKey, testHMACKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
@@ -0,0 +1,197 @@
+// Package nikfilter keeps the NIKs on every list in an in-process Bloom
+// filter, so a check whose NIK is definitely not listed skips the exact-match
+// lookups in Redis and Postgres. The filter is rebuilt from the database
+// periodically and fed record changes in between; until it has loaded, and
+// whenever it can't tell, lookups go ahead as usual.
+package nikfilter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"blacklist-check/internal/bloom"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// headroom sizes each filter for more NIKs than it is built with, so records
+// added before the next rebuild don't push it past its false-positive rate
+const headroom = 1.25
+
+// Lookup results, the labels of nik_filter_lookups_total
+const (
+	ResultNegative      = "negative"
+	ResultTruePositive  = "true_positive"
+	ResultFalsePositive = "false_positive"
+)
+
+// Filter is the NIK Bloom filter
+type Filter struct {
+	store   store.BlacklistStore
+	fpRate  float64
+	refresh time.Duration
+	log     *zap.Logger
+
+	mu sync.RWMutex
+	// bloom is nil until the first build
+	bloom *bloom.Filter
+	// pending collects the NIKs added while a rebuild scans the table, for
+	// the new filter; nil when no rebuild is running
+	pending []string
+
+	// rebuild asks Run for an early rebuild
+	rebuild chan struct{}
+}
+
+// NewFilter creates the NIK filter. It returns nil when the filter is
+// disabled; a nil filter lets every lookup through.
+func NewFilter(cfg *config.Config, store store.BlacklistStore, log *zap.Logger) (*Filter, error) {
+	if !cfg.Cache.NIKFilterEnabled {
+		return nil, nil
+	}
+	if cfg.Cache.NIKFilterFPRate <= 0 || cfg.Cache.NIKFilterFPRate >= 1 {
+		return nil, fmt.Errorf("CACHE_NIK_FILTER_FP_RATE must be between 0 and 1")
+	}
+	return &Filter{
+		store:   store,
+		fpRate:  cfg.Cache.NIKFilterFPRate,
+		refresh: cfg.Cache.NIKFilterRefresh,
+		log:     log,
+		rebuild: make(chan struct{}, 1),
+	}, nil
+}
+
+// Run builds the filter and rebuilds it every refresh interval, or sooner
+// when all records may have changed, until ctx is cancelled
+func (f *Filter) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.refresh)
+	defer ticker.Stop()
+	for {
+		if err := f.Build(ctx); err != nil {
+			f.log.Error("Error building NIK filter", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-f.rebuild:
+		}
+	}
+}
+
+// Build loads every listed NIK into a new filter and swaps it in. NIKs added
+// while the table is scanned are carried over, so none is lost to the swap.
+func (f *Filter) Build(ctx context.Context) error {
+	start := time.Now()
+	count, err := f.store.CountNIKs(ctx)
+	if err != nil {
+		return fmt.Errorf("error counting NIKs: %w", err)
+	}
+
+	f.mu.Lock()
+	f.pending = []string{}
+	f.mu.Unlock()
+
+	next := bloom.New(int(float64(count)*headroom), f.fpRate)
+	var loaded int64
+	err = f.store.EachNIK(ctx, func(list, nik string) error {
+		next.Add(key(list, nik))
+		loaded++
+		return nil
+	})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pending := f.pending
+	f.pending = nil
+	if err != nil {
+		return fmt.Errorf("error loading NIKs: %w", err)
+	}
+	for _, nik := range pending {
+		addAll(next, nik)
+	}
+	f.bloom = next
+	metrics.NIKFilterEntries.Set(float64(loaded))
+	f.log.Info("Built NIK filter",
+		zap.Int64("niks", loaded),
+		zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// Add records NIKs that may have been listed, on every list since changes
+// don't say which. It takes record change notifications; no NIKs means all
+// records may have changed, which triggers a rebuild. Removed NIKs stay in
+// the filter until the next rebuild, costing a lookup but never a match.
+func (f *Filter) Add(niks ...string) {
+	if len(niks) == 0 {
+		select {
+		case f.rebuild <- struct{}{}:
+		default:
+		}
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, nik := range niks {
+		if nik == "" {
+			continue
+		}
+		if f.bloom != nil {
+			addAll(f.bloom, nik)
+		}
+		if f.pending != nil {
+			f.pending = append(f.pending, nik)
+		}
+	}
+}
+
+// MayContain reports whether list may hold a record with nik. False means it
+// definitely doesn't, and is counted as a negative.
+func (f *Filter) MayContain(list, nik string) bool {
+	if f == nil {
+		return true
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.bloom == nil || f.bloom.Test(key(list, nik)) {
+		return true
+	}
+	metrics.NIKFilterLookupsTotal.WithLabelValues(ResultNegative).Inc()
+	return false
+}
+
+// Confirm counts the outcome of a lookup the filter let through, for its
+// observed false-positive rate
+func (f *Filter) Confirm(found bool) {
+	if f == nil {
+		return
+	}
+	f.mu.RLock()
+	loaded := f.bloom != nil
+	f.mu.RUnlock()
+	if !loaded {
+		return
+	}
+	if found {
+		metrics.NIKFilterLookupsTotal.WithLabelValues(ResultTruePositive).Inc()
+	} else {
+		metrics.NIKFilterLookupsTotal.WithLabelValues(ResultFalsePositive).Inc()
+	}
+}
+
+func addAll(b *bloom.Filter, nik string) {
+	for _, list := range lists.All {
+		b.Add(key(list, nik))
+	}
+}
+
+func key(list, nik string) string {
+	return list + "\x00" + nik
+}
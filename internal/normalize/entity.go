@@ -0,0 +1,44 @@
+package normalize
+
+import (
+	"strings"
+	"unicode"
+)
+
+// legalForms are company-type designators that carry no identifying value.
+// Indonesian forms come first; common foreign forms cover offshore counterparties.
+var legalForms = map[string]bool{
+	"pt": true, "cv": true, "tbk": true, "persero": true, "perum": true,
+	"ud": true, "fa": true, "firma": true, "koperasi": true, "yayasan": true,
+	"ltd": true, "limited": true, "llc": true, "inc": true, "corp": true,
+	"corporation": true, "co": true, "pte": true, "plc": true, "gmbh": true,
+	"bv": true, "nv": true, "sa": true, "ag": true, "bhd": true, "sdn": true,
+}
+
+// EntityName normalizes a company name and drops legal forms wherever they
+// appear, so "PT Maju Jaya Tbk", "Maju Jaya, PT" and "PT. MAJU JAYA" compare equal
+func EntityName(name string) string {
+	// Join dotted abbreviations ("P.T.", "Tbk.") before tokenizing
+	name = strings.ReplaceAll(name, ".", "")
+
+	tokens := strings.Fields(Name(name))
+	kept := tokens[:0]
+	for _, token := range tokens {
+		if !legalForms[token] {
+			kept = append(kept, token)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// RegistrationNumber strips formatting from a registration number such as an
+// NPWP ("01.234.567.8-901.000"), keeping letters and digits only
+func RegistrationNumber(number string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(number) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
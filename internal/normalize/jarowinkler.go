@@ -0,0 +1,75 @@
+package normalize
+
+// JaroWinkler returns the Jaro-Winkler similarity of two names once
+// normalized, from 0 to 1. Unlike trigram similarity it counts transposed
+// characters as near misses and favours names sharing a prefix, which rates
+// short names more sensibly.
+func JaroWinkler(a, b string) float64 {
+	ra, rb := []rune(Name(a)), []rune(Name(b))
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	jaro := jaro(ra, rb)
+
+	// Up to four characters of common prefix raise the score by a tenth of
+	// the remaining distance each
+	prefix := 0
+	for prefix < 4 && prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// jaro returns the Jaro similarity of a and b: characters match when equal
+// and no further apart than half the longer name, and matches out of order
+// count as half a transposition each
+func jaro(a, b []rune) float64 {
+	window := len(a)
+	if len(b) > window {
+		window = len(b)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matchedA := make([]bool, len(a))
+	matchedB := make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		lo, hi := i-window, i+window+1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(b) {
+			hi = len(b)
+		}
+		for j := lo; j < hi; j++ {
+			if !matchedB[j] && a[i] == b[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+	for i := range a {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	return (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions/2))/m) / 3
+}
@@ -0,0 +1,44 @@
+// Package normalize derives the canonical name and NIK forms stored alongside
+// each record, so matching queries compare like with like
+package normalize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Name lowercases a name, strips diacritics and punctuation, and collapses whitespace
+func Name(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Combining marks left over from decomposing accented letters
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// TokenSorted returns the normalized name with its tokens in alphabetical
+// order, so "Doe John" and "John Doe" compare equal
+func TokenSorted(name string) string {
+	tokens := strings.Fields(Name(name))
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+// NIKHash returns the hex SHA-256 of a NIK, for lookups and exports that must
+// not carry the raw identity number
+func NIKHash(nik string) string {
+	sum := sha256.Sum256([]byte(nik))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+package normalize
+
+import (
+	"math"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"John Doe", "john doe"},
+		{"  JOHN   DOE ", "john doe"},
+		{"José Ñúñez", "jose nunez"},
+		{"O'Brien-Smith", "o brien smith"},
+		{"Budi, S.H.", "budi s h"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Name(tt.name); got != tt.want {
+			t.Errorf("Name(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTokenSorted(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"John Doe", "doe john"},
+		{"Doe, John", "doe john"},
+		{"Siti Nur Aisyah", "aisyah nur siti"},
+	}
+	for _, tt := range tests {
+		if got := TokenSorted(tt.name); got != tt.want {
+			t.Errorf("TokenSorted(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEntityName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"PT Maju Jaya Tbk", "maju jaya"},
+		{"Maju Jaya, PT", "maju jaya"},
+		{"P.T. MAJU JAYA", "maju jaya"},
+		{"Acme Sdn. Bhd.", "acme"},
+		{"Koperasi Sejahtera", "sejahtera"},
+	}
+	for _, tt := range tests {
+		if got := EntityName(tt.name); got != tt.want {
+			t.Errorf("EntityName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRegistrationNumber(t *testing.T) {
+	tests := []struct {
+		number, want string
+	}{
+		{"01.234.567.8-901.000", "012345678901000"},
+		{"ab-123", "AB123"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := RegistrationNumber(tt.number); got != tt.want {
+			t.Errorf("RegistrationNumber(%q) = %q, want %q", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"John", "john", 1},
+		{"John Doe", "Doe John", 1},
+		{"abc", "xyz", 0},
+		{"", "john", 0},
+		// "  w"," ww","ww " against "  w"," wo","wor","ord","rd ": 1 of 7
+		{"ww", "word", 1.0 / 7},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Similarity(%q, %q) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMaxSimilarity(t *testing.T) {
+	if got := MaxSimilarity("john", []string{"xyz", "John", "jon"}); got != 1 {
+		t.Errorf("MaxSimilarity = %g, want 1", got)
+	}
+	if got := MaxSimilarity("john", nil); got != 0 {
+		t.Errorf("MaxSimilarity with no others = %g, want 0", got)
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Martha", "martha", 1},
+		{"MARTHA", "MARHTA", 0.961111},
+		{"DIXON", "DICKSONX", 0.813333},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+	}
+	for _, tt := range tests {
+		if got := JaroWinkler(tt.a, tt.b); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("JaroWinkler(%q, %q) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNIKHash(t *testing.T) {
+	a, b := NIKHash("3171234567890001"), NIKHash("3171234567890002")
+	if len(a) != 64 {
+		t.Errorf("NIKHash is %d characters, want 64", len(a))
+	}
+	if a == b {
+		t.Error("NIKHash of different NIKs are equal")
+	}
+	if a != NIKHash("3171234567890001") {
+		t.Error("NIKHash of the same NIK differ")
+	}
+}
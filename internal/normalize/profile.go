@@ -0,0 +1,177 @@
+package normalize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxVariants caps the names a profile searches for, as each costs a query
+const maxVariants = 4
+
+// Profile adapts name matching to a naming culture. A submitted name is
+// rewritten into a handful of variants that are each searched for; records
+// are stored as they are, so profiles can be changed without a backfill.
+type Profile struct {
+	Name        string
+	Description string
+	// Spelling rewrites letter sequences inside tokens not replaced by
+	// Tokens, e.g. the pre-1972 Indonesian "tj" for "c". Rules are applied in order.
+	Spelling [][2]string
+	// Tokens replaces whole tokens, e.g. romanizations of the same surname
+	Tokens map[string]string
+	// Weights scales how much a token counts: 0 drops it and anything below
+	// 1 makes it optional, so the name is also searched for without it
+	Weights map[string]float64
+	// Suffixes weighs tokens by ending, e.g. patronymics ending in "ovich"
+	Suffixes map[string]float64
+	// OptionalInitials makes single-letter tokens such as middle initials optional
+	OptionalInitials bool
+	// SurnameFirst also searches for the name with its first token moved to
+	// the end, for cultures that write the family name first
+	SurnameFirst bool
+	// CommaReorder reads "Family, Given" as "Given Family"
+	CommaReorder bool
+}
+
+// DefaultProfile matches names as they are submitted
+const DefaultProfile = "default"
+
+// Profiles are the built-in matching profiles
+var Profiles = map[string]*Profile{
+	DefaultProfile: {
+		Name:        DefaultProfile,
+		Description: "Names are matched as submitted",
+	},
+	"chinese_indonesian": {
+		Name:        "chinese_indonesian",
+		Description: "Surname-first Chinese-Indonesian names in Dutch-era or dialect romanization",
+		Spelling:    legacySpelling,
+		Tokens: map[string]string{
+			"tjoa": "chua", "tjua": "chua", "tjhin": "chin", "tjie": "chie",
+			"oei": "huang", "oey": "huang", "wee": "huang",
+			"liem": "lim", "lie": "li",
+			"kwee": "kwe", "kwik": "kwek",
+			"tio": "chang", "thio": "chang",
+		},
+		SurnameFirst: true,
+	},
+	"western": {
+		Name:         "western",
+		Description:  "Given names before the family name, with honorifics and middle initials",
+		CommaReorder: true,
+		Weights: map[string]float64{
+			"mr": 0, "mrs": 0, "ms": 0, "miss": 0, "dr": 0, "prof": 0,
+			"jr": 0, "sr": 0, "ii": 0, "iii": 0, "iv": 0,
+		},
+		OptionalInitials: true,
+	},
+	"patronymic": {
+		Name:        "patronymic",
+		Description: "Names carrying a patronymic, such as bin/binti or -ovich/-son",
+		Spelling:    legacySpelling,
+		Weights: map[string]float64{
+			"bin": 0, "binti": 0, "bt": 0, "bte": 0, "ibn": 0, "al": 0, "el": 0,
+			"b": 0,
+		},
+		Suffixes: map[string]float64{
+			"ovich": 0.5, "evich": 0.5, "ovna": 0.5, "evna": 0.5,
+			"sson": 0.5, "dottir": 0.5, "oglu": 0.5,
+		},
+	},
+}
+
+// legacySpelling maps the Van Ophuijsen and Soewandi spellings still found on
+// older documents to the current Indonesian spelling
+var legacySpelling = [][2]string{
+	{"tj", "c"},
+	{"dj", "j"},
+	{"sj", "sy"},
+	{"nj", "ny"},
+	{"oe", "u"},
+}
+
+// LookupProfile returns the built-in profile with the given name
+func LookupProfile(name string) (*Profile, error) {
+	profile, ok := Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(Profiles))
+		for n := range Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown matching profile %q (known: %s)", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// weight returns how much a normalized token counts under the profile
+func (p *Profile) weight(token string) float64 {
+	if w, ok := p.Weights[token]; ok {
+		return w
+	}
+	if p.OptionalInitials && len([]rune(token)) == 1 {
+		return 0.5
+	}
+	for suffix, w := range p.Suffixes {
+		if len(token) > len(suffix) && strings.HasSuffix(token, suffix) {
+			return w
+		}
+	}
+	return 1
+}
+
+// Variants returns the names to search for when matching name under the
+// profile, starting with name itself. The default profile returns only name.
+func (p *Profile) Variants(name string) []string {
+	variants := []string{name}
+	if p == nil || p.Name == DefaultProfile {
+		return variants
+	}
+
+	if p.CommaReorder {
+		if family, given, ok := strings.Cut(name, ","); ok {
+			name = strings.TrimSpace(given) + " " + strings.TrimSpace(family)
+		}
+	}
+
+	var required, all []string
+	for _, token := range strings.Fields(Name(name)) {
+		w := p.weight(token)
+		if w == 0 {
+			continue
+		}
+		if replacement, ok := p.Tokens[token]; ok {
+			token = replacement
+		} else {
+			for _, rule := range p.Spelling {
+				token = strings.ReplaceAll(token, rule[0], rule[1])
+			}
+		}
+		all = append(all, token)
+		if w >= 1 {
+			required = append(required, token)
+		}
+	}
+
+	add := func(tokens []string) {
+		if len(tokens) == 0 || len(variants) == maxVariants {
+			return
+		}
+		v := strings.Join(tokens, " ")
+		for _, existing := range variants {
+			if strings.EqualFold(Name(existing), v) {
+				return
+			}
+		}
+		variants = append(variants, v)
+	}
+	add(all)
+	if len(required) < len(all) {
+		add(required)
+	}
+	if p.SurnameFirst && len(all) > 1 {
+		add(append(append([]string{}, all[1:]...), all[0]))
+	}
+	return variants
+}
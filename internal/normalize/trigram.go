@@ -0,0 +1,44 @@
+package normalize
+
+import "strings"
+
+// Similarity returns the trigram similarity of two names the way pg_trgm's
+// similarity() computes it: the shared trigrams of the padded words over all
+// distinct trigrams of both, from 0 to 1
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// MaxSimilarity returns the highest Similarity of a to any of others
+func MaxSimilarity(a string, others []string) float64 {
+	var max float64
+	for _, b := range others {
+		if similarity := Similarity(a, b); similarity > max {
+			max = similarity
+		}
+	}
+	return max
+}
+
+// trigrams returns the distinct trigrams of each word of name, padded with
+// two spaces in front and one behind
+func trigrams(name string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(Name(name)) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,238 @@
+// Package payloadlog logs HTTP request and response bodies at debug level
+// for troubleshooting. Bodies carry NIKs, names and birth data, so every
+// JSON field named in the redaction list is hashed, masked or dropped before
+// it reaches the log, wherever it is nested. Bodies that aren't JSON are
+// never logged, only their size.
+package payloadlog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"blacklist-check/internal/reqlog"
+	"blacklist-check/pkg/config"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redaction actions
+const (
+	// ActionHash replaces a value with its hex SHA-256
+	ActionHash = "hash"
+	// ActionMask keeps the first letter of each word and masks the rest
+	ActionMask = "mask"
+	// ActionDrop replaces a value with a placeholder
+	ActionDrop = "drop"
+)
+
+// dropped replaces dropped values
+const dropped = "[redacted]"
+
+// Redactor rewrites JSON payloads according to a field list
+type Redactor struct {
+	// fields maps JSON field names to their action
+	fields map[string]string
+}
+
+// NewRedactor parses a comma-separated list of field=action entries, where
+// action is hash, mask or drop
+func NewRedactor(spec string) (*Redactor, error) {
+	rd := &Redactor{fields: make(map[string]string)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, action, ok := strings.Cut(entry, "=")
+		if !ok || field == "" {
+			return nil, fmt.Errorf("invalid redaction entry %q, expected field=action", entry)
+		}
+		switch action {
+		case ActionHash, ActionMask, ActionDrop:
+		default:
+			return nil, fmt.Errorf("unknown redaction action %q for field %q", action, field)
+		}
+		rd.fields[field] = action
+	}
+	return rd, nil
+}
+
+// Redact decodes a JSON body and returns it with the listed fields redacted.
+// ok is false when the body isn't JSON.
+func (rd *Redactor) Redact(body []byte) (redacted interface{}, ok bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return rd.walk(value), true
+}
+
+// walk redacts the listed fields of every object within value
+func (rd *Redactor) walk(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if action, ok := rd.fields[key]; ok {
+				v[key] = redact(field, action)
+			} else {
+				v[key] = rd.walk(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = rd.walk(v[i])
+		}
+	}
+	return value
+}
+
+// redact applies action to a field's value. Nested objects and arrays are
+// dropped whole, whatever the action.
+func redact(value interface{}, action string) interface{} {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		s = v
+	case json.Number, bool:
+		s = fmt.Sprint(v)
+	default:
+		return dropped
+	}
+
+	switch action {
+	case ActionHash:
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	case ActionMask:
+		return mask(s)
+	default:
+		return dropped
+	}
+}
+
+// mask keeps the first letter of each word, replacing the other letters and
+// every digit with asterisks, so "Budi Hartono" becomes "B*** H******" and
+// "1980-05-17" becomes "****-**-**"
+func mask(s string) string {
+	var b strings.Builder
+	first := true
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune('*')
+		case !unicode.IsLetter(r):
+			first = true
+			b.WriteRune(r)
+		case first:
+			first = false
+			b.WriteRune(r)
+		default:
+			b.WriteRune('*')
+		}
+	}
+	return b.String()
+}
+
+// Logger is the payload logging middleware
+type Logger struct {
+	enabled  bool
+	maxBytes int
+	redactor *Redactor
+	log      *zap.Logger
+}
+
+// NewLogger creates the payload logging middleware
+func NewLogger(cfg *config.Config, log *zap.Logger) (*Logger, error) {
+	redactor, err := NewRedactor(cfg.Server.LogRedactFields)
+	if err != nil {
+		return nil, fmt.Errorf("error loading redaction fields: %w", err)
+	}
+	return &Logger{
+		enabled:  cfg.Server.LogPayloads,
+		maxBytes: cfg.Server.LogPayloadMaxBytes,
+		redactor: redactor,
+		log:      log,
+	}, nil
+}
+
+// Middleware logs the redacted request and response bodies of each request.
+// It does nothing unless payload logging is enabled and the logger is at
+// debug level, so bodies aren't buffered otherwise.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	if !l.enabled || !l.log.Core().Enabled(zapcore.DebugLevel) {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &capped{max: l.maxBytes}
+		if r.Body != nil {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, request), r.Body}
+		}
+		response := &capped{max: l.maxBytes}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(response)
+
+		next.ServeHTTP(ww, r)
+
+		reqlog.From(r.Context(), l.log).Debug("HTTP payload",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", ww.Status()),
+			l.field("request_body", request),
+			l.field("response_body", response))
+	})
+}
+
+// field renders a captured body for the log: redacted when it is JSON and
+// complete, otherwise only its size
+func (l *Logger) field(key string, body *capped) zap.Field {
+	if body.size == 0 {
+		return zap.Skip()
+	}
+	if !body.truncated() {
+		if redacted, ok := l.redactor.Redact(body.buf.Bytes()); ok {
+			return zap.Any(key, redacted)
+		}
+	}
+	return zap.String(key, fmt.Sprintf("[%d bytes not logged]", body.size))
+}
+
+// capped buffers up to max bytes written to it, counting the rest
+type capped struct {
+	buf  bytes.Buffer
+	max  int
+	size int
+}
+
+func (c *capped) Write(p []byte) (int, error) {
+	c.size += len(p)
+	if room := c.max - c.buf.Len(); room > 0 {
+		if len(p) > room {
+			c.buf.Write(p[:room])
+		} else {
+			c.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// truncated reports whether more was written than was buffered
+func (c *capped) truncated() bool {
+	return c.size > c.buf.Len()
+}
@@ -0,0 +1,219 @@
+package payloadlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewRedactor(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"", false},
+		{"nik=hash, name=mask ,birth_date=drop,", false},
+		{"nik", true},
+		{"=hash", true},
+		{"nik=encrypt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if _, err := NewRedactor(tt.spec); (err != nil) != tt.wantErr {
+				t.Errorf("NewRedactor(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	rd, err := NewRedactor("nik=hash,name=mask,birth_date=mask,address=drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "masks names and dates",
+			body: `{"name":"Budi Hartono","birth_date":"1980-05-17","reason":"fraud"}`,
+			want: `{"birth_date":"****-**-**","name":"B*** H******","reason":"fraud"}`,
+		},
+		{
+			name: "redacts nested fields",
+			body: `{"subjects":[{"name":"Jane Roe"},{"name":"Ann"}],"result":{"name":"John Doe"}}`,
+			want: `{"result":{"name":"J*** D**"},"subjects":[{"name":"J*** R**"},{"name":"A**"}]}`,
+		},
+		{
+			name: "drops objects whatever the action",
+			body: `{"name":{"first":"Budi"},"address":"Jl. Sudirman 1"}`,
+			want: `{"address":"[redacted]","name":"[redacted]"}`,
+		},
+		{
+			name: "keeps nulls and numbers",
+			body: `{"name":null,"score":0.91,"nik":null}`,
+			want: `{"name":null,"nik":null,"score":0.91}`,
+		},
+		{
+			name: "masks numbers",
+			body: `{"birth_date":19800517}`,
+			want: `{"birth_date":"********"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redacted, ok := rd.Redact([]byte(tt.body))
+			if !ok {
+				t.Fatalf("Redact(%s) not JSON", tt.body)
+			}
+			got, err := json.Marshal(redacted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Redact(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+
+	redacted, _ := rd.Redact([]byte(`{"nik":"3171011505900001"}`))
+	sum := sha256.Sum256([]byte("3171011505900001"))
+	if got, want := redacted.(map[string]interface{})["nik"], hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("hashed nik = %v, want %s", got, want)
+	}
+
+	if _, ok := rd.Redact([]byte("nik=3171011505900001")); ok {
+		t.Error("Redact() of a form body ok = true")
+	}
+}
+
+// newLogger creates a payload logger recording to an observer at level
+func newLogger(t *testing.T, enabled bool, level zapcore.Level, maxBytes int) (*Logger, *observer.ObservedLogs) {
+	t.Helper()
+	core, logs := observer.New(level)
+	cfg := &config.Config{Server: config.ServerConfig{
+		LogPayloads:        enabled,
+		LogPayloadMaxBytes: maxBytes,
+		LogRedactFields:    "nik=hash,name=mask",
+	}}
+	l, err := NewLogger(cfg, zap.New(core))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, logs
+}
+
+// echo reads the request body and answers with a JSON response
+func echo(response string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, response)
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	const (
+		request  = `{"name":"John Doe","nik":"3171011505900001"}`
+		response = `{"blacklisted":true,"name":"John Doe"}`
+	)
+	tests := []struct {
+		name         string
+		enabled      bool
+		level        zapcore.Level
+		maxBytes     int
+		body         string
+		wantRequest  string
+		wantResponse string
+	}{
+		{
+			name:         "redacted",
+			enabled:      true,
+			level:        zapcore.DebugLevel,
+			maxBytes:     1024,
+			body:         request,
+			wantRequest:  `"name":"J*** D**"`,
+			wantResponse: `{"blacklisted":true,"name":"J*** D**"}`,
+		},
+		{
+			name:         "over the cap",
+			enabled:      true,
+			level:        zapcore.DebugLevel,
+			maxBytes:     16,
+			body:         request,
+			wantRequest:  `"[44 bytes not logged]"`,
+			wantResponse: `"[38 bytes not logged]"`,
+		},
+		{
+			name:         "not JSON",
+			enabled:      true,
+			level:        zapcore.DebugLevel,
+			maxBytes:     1024,
+			body:         "nik=3171011505900001",
+			wantRequest:  `"[20 bytes not logged]"`,
+			wantResponse: `{"blacklisted":true,"name":"J*** D**"}`,
+		},
+		{
+			name:     "disabled",
+			level:    zapcore.DebugLevel,
+			maxBytes: 1024,
+			body:     request,
+		},
+		{
+			name:     "above debug level",
+			enabled:  true,
+			level:    zapcore.InfoLevel,
+			maxBytes: 1024,
+			body:     request,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, logs := newLogger(t, tt.enabled, tt.level, tt.maxBytes)
+			rec := httptest.NewRecorder()
+			l.Middleware(echo(response)).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/check", strings.NewReader(tt.body)))
+
+			// The handler's response reaches the client untouched
+			if rec.Code != http.StatusCreated || rec.Body.String() != response {
+				t.Errorf("response = %d %s, want %d %s", rec.Code, rec.Body, http.StatusCreated, response)
+			}
+
+			entries := logs.All()
+			if tt.wantRequest == "" {
+				if len(entries) != 0 {
+					t.Errorf("logged %d entries, want none", len(entries))
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			fields := entries[0].ContextMap()
+			if fields["status"] != int64(http.StatusCreated) || fields["path"] != "/v1/check" {
+				t.Errorf("logged %v, want status and path", fields)
+			}
+			got, _ := json.Marshal(fields["request_body"])
+			if !strings.Contains(string(got), tt.wantRequest) || strings.Contains(string(got), "3171011505900001") {
+				t.Errorf("request_body = %s, want %s", got, tt.wantRequest)
+			}
+			got, _ = json.Marshal(fields["response_body"])
+			if string(got) != tt.wantResponse {
+				t.Errorf("response_body = %s, want %s", got, tt.wantResponse)
+			}
+		})
+	}
+}
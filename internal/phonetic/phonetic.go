@@ -0,0 +1,95 @@
+// Package phonetic encodes names so that common Indonesian transliteration
+// variants ("Achmad"/"Ahmad", "Soekarno"/"Sukarno") produce the same code.
+package phonetic
+
+import (
+	"strings"
+	"unicode"
+)
+
+// spellingRules fold pre-1972 Indonesian spellings and common Arabic/Dutch
+// transliterations onto one form before encoding. Old "j" and modern "y" are
+// the same sound, as are old "dj" and modern "j", so both collapse to "j".
+// Order matters: longer patterns must come before the shorter ones they contain.
+var spellingRules = strings.NewReplacer(
+	"oe", "u",
+	"dj", "j",
+	"tj", "c",
+	"y", "j",
+	"ch", "h",
+	"kh", "h",
+	"ph", "f",
+	"th", "t",
+	"dh", "d",
+	"q", "k",
+	"v", "f",
+	"z", "j",
+)
+
+// soundexCodes maps consonants to their Soundex digit
+var soundexCodes = map[rune]byte{
+	'b': '1', 'f': '1', 'p': '1',
+	'c': '2', 'g': '2', 'j': '2', 'k': '2', 's': '2', 'x': '2',
+	'd': '3', 't': '3',
+	'l': '4',
+	'm': '5', 'n': '5',
+	'r': '6',
+}
+
+// Encode returns the phonetic code for a full name: one Soundex code per
+// normalized name token, separated by spaces
+func Encode(name string) string {
+	tokens := strings.Fields(normalize(name))
+	codes := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if code := soundex(token); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return strings.Join(codes, " ")
+}
+
+// normalize lowercases, strips everything but letters and spaces, and applies spelling rules
+func normalize(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '.':
+			b.WriteRune(' ')
+		}
+	}
+	return spellingRules.Replace(b.String())
+}
+
+// soundex encodes a single lowercase ASCII token
+func soundex(token string) string {
+	if token == "" {
+		return ""
+	}
+
+	code := []byte{byte(unicode.ToUpper(rune(token[0])))}
+	last := soundexCodes[rune(token[0])]
+	for _, r := range token[1:] {
+		digit, ok := soundexCodes[r]
+		if !ok {
+			// Vowels separate repeated codes; h, w and y do not
+			if r != 'h' && r != 'w' && r != 'y' {
+				last = 0
+			}
+			continue
+		}
+		if digit != last {
+			code = append(code, digit)
+		}
+		last = digit
+		if len(code) == 4 {
+			break
+		}
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
@@ -0,0 +1,41 @@
+package phonetic
+
+import "testing"
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"Ahmad", "A530"},
+		{"Sukarno", "S265"},
+		{"Robert", "R163"},
+		{"Siti-Nur", "S300 N600"},
+		{"", ""},
+		{"123", ""},
+	}
+	for _, tt := range tests {
+		if got := Encode(tt.name); got != tt.want {
+			t.Errorf("Encode(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeSpellingVariants(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"Achmad", "Ahmad"},
+		{"Soekarno", "Sukarno"},
+		{"Djoko", "Joko"},
+		{"Tjahjo", "Cahjo"},
+		{"Yusuf", "Jusuf"},
+		{"Khairul", "Hairul"},
+		{"Zainal", "Jainal"},
+		{"Taufiq", "Taufik"},
+	}
+	for _, tt := range tests {
+		if a, b := Encode(tt.a), Encode(tt.b); a != b {
+			t.Errorf("Encode(%q) = %q but Encode(%q) = %q", tt.a, a, tt.b, b)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Package profiling captures CPU and heap profiles of the running server on
+// demand, writing them to files that can be opened with go tool pprof, so a
+// latency regression can be diagnosed without a custom build.
+package profiling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"blacklist-check/pkg/config"
+)
+
+// Profile kinds
+const (
+	// KindCPU samples where CPU time is spent over a duration
+	KindCPU = "cpu"
+	// KindHeap snapshots the live heap after a garbage collection
+	KindHeap = "heap"
+)
+
+// Kinds are the profiles that can be captured
+var Kinds = []string{KindCPU, KindHeap}
+
+// ErrBusy is returned while another profile is being captured, or for a CPU
+// profile while /debug/pprof/profile samples one; the runtime samples one at
+// a time
+var ErrBusy = errors.New("a profile is already being captured")
+
+// Profile is a profile written to disk
+type Profile struct {
+	Kind string
+	// File is the name of the profile in the profile directory
+	File      string
+	Bytes     int64
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// Profiler writes profiles to a directory
+type Profiler struct {
+	dir string
+	// mu is held while a profile is captured
+	mu sync.Mutex
+}
+
+// NewProfiler creates a profiler writing to INTERNAL_PROFILE_DIR. It returns
+// nil when the directory isn't set or the internal listener doesn't profile.
+func NewProfiler(cfg *config.Config) (*Profiler, error) {
+	if cfg.Server.InternalProfileDir == "" || cfg.Server.InternalPort == 0 || !cfg.Server.InternalPprof {
+		return nil, nil
+	}
+	if err := os.MkdirAll(cfg.Server.InternalProfileDir, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating profile directory: %w", err)
+	}
+	return &Profiler{dir: cfg.Server.InternalProfileDir}, nil
+}
+
+// Capture writes a profile of kind. A CPU profile samples for duration, or
+// until ctx is done, in which case nothing is kept; a heap profile is
+// written at once.
+func (p *Profiler) Capture(ctx context.Context, kind string, duration time.Duration) (*Profile, error) {
+	if !p.mu.TryLock() {
+		return nil, ErrBusy
+	}
+	defer p.mu.Unlock()
+
+	started := time.Now().UTC()
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%s-%s-%s.pprof", kind, host, started.Format("20060102T150405.000Z"))
+	path := filepath.Join(p.dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("error creating profile file: %w", err)
+	}
+	defer f.Close()
+
+	switch kind {
+	case KindCPU:
+		err = captureCPU(ctx, f, duration)
+	case KindHeap:
+		runtime.GC()
+		err = pprof.Lookup("heap").WriteTo(f, 0)
+	default:
+		err = fmt.Errorf("unknown profile kind %q", kind)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading profile file: %w", err)
+	}
+	return &Profile{
+		Kind:      kind,
+		File:      name,
+		Bytes:     info.Size(),
+		StartedAt: started,
+		Duration:  time.Since(started),
+	}, nil
+}
+
+// captureCPU samples the CPU into f for duration
+func captureCPU(ctx context.Context, f *os.File, duration time.Duration) error {
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return ErrBusy
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		pprof.StopCPUProfile()
+		return nil
+	case <-ctx.Done():
+		pprof.StopCPUProfile()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,147 @@
+// Package reason renders templated blacklist reasons. Records store a reason
+// code plus parameters; the human text is produced per locale at response
+// time so analytics can group by code and translations stay consistent.
+package reason
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is used when the caller accepts none of the supported locales
+const DefaultLocale = "en"
+
+// Template defines a reason code, the parameters it requires and its text per locale.
+// Parameters are referenced in the text as {name}.
+type Template struct {
+	Params []string
+	Text   map[string]string
+}
+
+// Templates lists every known reason code
+var Templates = map[string]Template{
+	"fraud": {
+		Params: []string{"case_number"},
+		Text: map[string]string{
+			"en": "Involved in fraud case {case_number}",
+			"id": "Terlibat dalam kasus penipuan {case_number}",
+		},
+	},
+	"loan_default": {
+		Params: []string{"lender", "since"},
+		Text: map[string]string{
+			"en": "Defaulted on a loan with {lender} since {since}",
+			"id": "Gagal bayar pinjaman di {lender} sejak {since}",
+		},
+	},
+	"court_order": {
+		Params: []string{"order_number"},
+		Text: map[string]string{
+			"en": "Listed by court order {order_number}",
+			"id": "Dicantumkan berdasarkan putusan pengadilan {order_number}",
+		},
+	},
+	"sanctions": {
+		Params: []string{"list"},
+		Text: map[string]string{
+			"en": "Subject to sanctions list {list}",
+			"id": "Tercantum dalam daftar sanksi {list}",
+		},
+	},
+	"identity_theft": {
+		Text: map[string]string{
+			"en": "NIK reported as used in identity theft",
+			"id": "NIK dilaporkan digunakan dalam pencurian identitas",
+		},
+	},
+	"regulator_request": {
+		Params: []string{"regulator"},
+		Text: map[string]string{
+			"en": "Listed at the request of {regulator}",
+			"id": "Dicantumkan atas permintaan {regulator}",
+		},
+	},
+}
+
+// Validate checks that code is known and every parameter it requires is set
+func Validate(code string, params map[string]string) error {
+	tmpl, ok := Templates[code]
+	if !ok {
+		return fmt.Errorf("unknown reason code %q (known: %s)", code, strings.Join(Codes(), ", "))
+	}
+	for _, name := range tmpl.Params {
+		if params[name] == "" {
+			return fmt.Errorf("reason code %q requires parameter %q", code, name)
+		}
+	}
+	return nil
+}
+
+// Render returns the text for code in locale, falling back to DefaultLocale.
+// Unknown codes render as the code itself so a removed template never
+// produces an empty reason.
+func Render(code string, params map[string]string, locale string) string {
+	tmpl, ok := Templates[code]
+	if !ok {
+		return code
+	}
+	text, ok := tmpl.Text[locale]
+	if !ok {
+		text = tmpl.Text[DefaultLocale]
+	}
+
+	replacements := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		replacements = append(replacements, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(text)
+}
+
+// Negotiate picks the best supported locale from an Accept-Language header
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		locale string
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if primary == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			fmt.Sscanf(v, "%g", &q)
+		}
+		candidates = append(candidates, candidate{primary, q})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.q > 0 && supported(c.locale) {
+			return c.locale
+		}
+	}
+	return DefaultLocale
+}
+
+// Codes returns the known reason codes in alphabetical order
+func Codes() []string {
+	codes := make([]string, 0, len(Templates))
+	for code := range Templates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func supported(locale string) bool {
+	for _, tmpl := range Templates {
+		if _, ok := tmpl.Text[locale]; ok {
+			return true
+		}
+	}
+	return false
+}
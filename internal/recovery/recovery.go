@@ -0,0 +1,137 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/metrics"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PanicError is an internal error converted from a recovered panic
+type PanicError struct {
+	Component string
+	Value     interface{}
+	Stack     []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Component, e.Value)
+}
+
+// Reporter forwards recovered panics to an external error tracker
+type Reporter interface {
+	Report(err *PanicError)
+}
+
+// Recoverer converts panics into PanicErrors, logging them with their stack
+// and forwarding them to the configured reporters
+type Recoverer struct {
+	log       *zap.Logger
+	reporters []Reporter
+}
+
+// NewRecoverer creates a new recoverer
+func NewRecoverer(log *zap.Logger, reporters ...Reporter) *Recoverer {
+	return &Recoverer{
+		log:       log,
+		reporters: reporters,
+	}
+}
+
+// Middleware recovers panics in HTTP handlers and responds with 500
+func (rc *Recoverer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// ErrAbortHandler is net/http's way of aborting a response on purpose
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			rc.handle(&PanicError{
+				Component: "http",
+				Value:     v,
+				Stack:     debug.Stack(),
+			}, zap.String("method", r.Method), zap.String("path", r.URL.Path))
+			apierror.Internal(w, r)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryInterceptor recovers panics in unary RPCs and fails them with Internal
+func (rc *Recoverer) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer rc.recoverRPC(info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor recovers panics in streaming RPCs and fails them with Internal
+func (rc *Recoverer) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer rc.recoverRPC(info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+func (rc *Recoverer) recoverRPC(method string, err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	rc.handle(&PanicError{
+		Component: "grpc",
+		Value:     v,
+		Stack:     debug.Stack(),
+	}, zap.String("method", method))
+	*err = status.Error(codes.Internal, "internal server error")
+}
+
+// Do runs fn and converts a panic into a returned *PanicError, so background
+// workers can treat it like any other failure and re-queue their job
+func (rc *Recoverer) Do(component string, fn func() error) (err error) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+
+		perr := &PanicError{
+			Component: component,
+			Value:     v,
+			Stack:     debug.Stack(),
+		}
+		rc.handle(perr)
+		err = perr
+	}()
+
+	return fn()
+}
+
+func (rc *Recoverer) handle(err *PanicError, fields ...zap.Field) {
+	metrics.PanicsTotal.WithLabelValues(err.Component).Inc()
+
+	fields = append(fields,
+		zap.String("component", err.Component),
+		zap.Any("panic", err.Value),
+		zap.ByteString("stack", err.Stack))
+	rc.log.Error("Recovered from panic", fields...)
+
+	for _, reporter := range rc.reporters {
+		reporter.Report(err)
+	}
+}
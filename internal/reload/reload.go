@@ -0,0 +1,92 @@
+// Package reload applies changes to tunable settings without a restart. On a
+// reload the configuration is read again and every registered component
+// checks its settings first; only when all of them accept theirs are they
+// applied, so an instance never runs with half a reload. Settings that aren't
+// tunable keep their startup value until the next restart.
+package reload
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Results of a reload, the labels of config_reloads_total
+const (
+	ResultApplied  = "applied"
+	ResultRejected = "rejected"
+)
+
+// Preparer reads a component's tunable settings from cfg and returns the
+// function that applies them, or why they can't be applied. It must not
+// change anything itself.
+type Preparer func(cfg *config.Config) (apply func(), err error)
+
+// Reloader reloads the tunable settings of registered components
+type Reloader struct {
+	log *zap.Logger
+
+	mu         sync.Mutex
+	components []component
+}
+
+type component struct {
+	name    string
+	prepare Preparer
+}
+
+// NewReloader creates a new reloader
+func NewReloader(log *zap.Logger) *Reloader {
+	return &Reloader{log: log}
+}
+
+// Register adds a component whose settings are reloaded, under name
+func (r *Reloader) Register(name string, prepare Preparer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.components = append(r.components, component{name: name, prepare: prepare})
+}
+
+// Reload reads the configuration again and applies it to every component,
+// or to none of them when the configuration is invalid or any component
+// rejects its settings
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return r.reject(err)
+	}
+
+	var applies []func()
+	var errs []error
+	for _, c := range r.components {
+		apply, err := c.prepare(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		applies = append(applies, apply)
+	}
+	if len(errs) > 0 {
+		return r.reject(errors.Join(errs...))
+	}
+
+	for _, apply := range applies {
+		apply()
+	}
+	metrics.ConfigReloadsTotal.WithLabelValues(ResultApplied).Inc()
+	r.log.Info("Reloaded configuration", zap.Int("components", len(applies)))
+	return nil
+}
+
+func (r *Reloader) reject(err error) error {
+	metrics.ConfigReloadsTotal.WithLabelValues(ResultRejected).Inc()
+	return fmt.Errorf("configuration reload rejected, keeping current settings: %w", err)
+}
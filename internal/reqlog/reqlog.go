@@ -0,0 +1,104 @@
+// Package reqlog scopes a logger to each HTTP request, so every line logged
+// while serving it carries the request ID, route and caller, and logs the
+// request once it has been served.
+package reqlog
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+type contextKey struct{}
+
+// WithLogger returns a copy of ctx carrying log
+func WithLogger(ctx context.Context, log *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, log)
+}
+
+// From returns the logger scoped to the request in ctx, or fallback outside
+// a request
+func From(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if log, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+		return log
+	}
+	return fallback
+}
+
+// Middleware scopes log to each request, with the request ID and the route
+// pattern routes matches, echoes the request ID in X-Request-ID and logs the
+// request with its status and duration. It must run after
+// middleware.RequestID. Only the path is logged, never the query, which may
+// carry PII.
+func Middleware(log *zap.Logger, routes chi.Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := middleware.GetReqID(r.Context())
+			w.Header().Set(middleware.RequestIDHeader, id)
+
+			fields := []zap.Field{zap.String("request_id", id)}
+			// Routing happens after the middleware, so the route is matched
+			// on a scratch context here
+			if rctx := chi.NewRouteContext(); routes.Match(rctx, r.Method, r.URL.Path) {
+				fields = append(fields, zap.String("route", rctx.RoutePattern()))
+			}
+			scoped := log.With(fields...)
+			r = r.WithContext(WithLogger(r.Context(), scoped))
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			// The caller is only known once authentication has run further
+			// down the chain, which records it in the holder
+			caller := &callerFields{}
+			r = r.WithContext(context.WithValue(r.Context(), callerKey{}, caller))
+			next.ServeHTTP(ww, r)
+
+			scoped.Info("HTTP request",
+				append(caller.fields,
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Int("status", ww.Status()),
+					zap.Int("bytes", ww.BytesWritten()),
+					zap.Duration("duration", time.Since(start)))...)
+		})
+	}
+}
+
+type callerKey struct{}
+
+// callerFields carries the caller's log fields from Identify back up to
+// Middleware's request line
+type callerFields struct {
+	fields []zap.Field
+}
+
+// Identify adds the authenticated caller and its tenant to the request's
+// logger. It must run after authentication and tenant resolution.
+func Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := auth.FromContext(r.Context())
+		if identity == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fields := []zap.Field{
+			zap.String("caller", identity.Subject),
+			zap.String("auth_method", identity.Method),
+			zap.String("tenant", store.Tenant(r.Context())),
+		}
+		if caller, ok := r.Context().Value(callerKey{}).(*callerFields); ok {
+			caller.fields = fields
+		}
+		if log, ok := r.Context().Value(contextKey{}).(*zap.Logger); ok {
+			r = r.WithContext(WithLogger(r.Context(), log.With(fields...)))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,133 @@
+// Package rescreen screens cleared subjects again as the lists change, as
+// AML rules require after list updates. Subjects are enrolled by checks that
+// carry their consent; each consenting subject last cleared is screened
+// again every RESCREEN_INTERVAL, and a new match is alerted to a webhook and
+// a Kafka topic.
+package rescreen
+
+import (
+	"context"
+	"time"
+
+	"blacklist-check/internal/alert"
+	"blacklist-check/internal/events"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Scheduler re-screens the enrolled subjects that are due. Subjects are
+// claimed by a conditional update, so replicas can all run it without
+// screening a subject twice.
+type Scheduler struct {
+	service   *service.BlacklistService
+	store     store.RescreenStore
+	publisher *events.Publisher
+	interval  time.Duration
+	batchSize int
+	alerts    *alert.Poster
+	log       *zap.Logger
+}
+
+// NewScheduler creates a new re-screening scheduler
+func NewScheduler(cfg *config.Config, service *service.BlacklistService, subjects store.RescreenStore, publisher *events.Publisher, log *zap.Logger) *Scheduler {
+	return &Scheduler{
+		service:   service,
+		store:     subjects,
+		publisher: publisher,
+		interval:  cfg.Rescreen.Interval,
+		batchSize: cfg.Rescreen.BatchSize,
+		alerts:    alert.NewPoster(cfg.Rescreen.AlertWebhook, "re-screening alert", log),
+		log:       log,
+	}
+}
+
+// Run re-screens a batch of the subjects due, alerting the ones that now
+// match, and returns the alerts. A subject that can't be screened is tried
+// again when it is next due.
+func (s *Scheduler) Run(ctx context.Context) ([]*events.RescreenAlert, error) {
+	subjects, err := s.store.Due(ctx, time.Now().Add(-s.interval), s.batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []*events.RescreenAlert
+	for _, subject := range subjects {
+		if ctx.Err() != nil {
+			break
+		}
+		result, err := s.service.Rescreen(ctx, subject)
+		if err != nil {
+			metrics.RescreensTotal.WithLabelValues("error").Inc()
+			s.log.Error("Error re-screening subject", zap.Int64("subject_id", subject.ID), zap.Error(err))
+			continue
+		}
+		outcome := result.Outcome()
+		metrics.RescreensTotal.WithLabelValues(outcome).Inc()
+		// An unknown outcome is neither a match nor a clearance, so the
+		// subject stays cleared until a later run decides it
+		if outcome == service.OutcomeUnknown {
+			continue
+		}
+		if err := s.store.Rescreened(ctx, subject.ID, outcome, result.MatchType); err != nil {
+			s.log.Error("Error recording re-screening", zap.Int64("subject_id", subject.ID), zap.Error(err))
+			continue
+		}
+		if outcome == service.OutcomeHit {
+			alerts = append(alerts, s.alert(ctx, subject, result))
+		}
+	}
+
+	if len(subjects) > 0 {
+		s.log.Info("Re-screened subjects",
+			zap.Int("subjects", len(subjects)),
+			zap.Int("new_matches", len(alerts)))
+	}
+	return alerts, nil
+}
+
+// alert logs a new match and publishes it to Kafka and the alert webhook
+// without holding up the run
+func (s *Scheduler) alert(ctx context.Context, subject *store.RescreenSubject, result *service.CheckResult) *events.RescreenAlert {
+	alert := &events.RescreenAlert{
+		SubjectID:       subject.ID,
+		SubjectHash:     subject.SubjectHash,
+		Tenant:          subject.Tenant,
+		PreviousOutcome: subject.Outcome,
+		Outcome:         result.Outcome(),
+		MatchType:       result.MatchType,
+		Decision:        result.Decision,
+		Score:           result.Score,
+		PolicyVersion:   result.PolicyVersion,
+		EnrolledAt:      subject.EnrolledAt,
+		OccurredAt:      time.Now().UTC(),
+	}
+	for _, r := range result.Lists {
+		alert.Lists = append(alert.Lists, events.ListScreening{
+			List:          r.List,
+			Matched:       r.Matched,
+			MatchType:     r.MatchType,
+			Score:         r.Score,
+			Decision:      r.Decision,
+			Outcome:       r.Outcome(),
+			UnknownReason: r.UnknownReason,
+		})
+	}
+
+	s.log.Warn("Re-screened subject now matches",
+		zap.Int64("subject_id", alert.SubjectID),
+		zap.String("tenant", alert.Tenant),
+		zap.String("match_type", alert.MatchType))
+	s.publisher.PublishRescreenAlert(ctx, alert)
+
+	s.alerts.Post(alert)
+	return alert
+}
+
+// Wait waits for alerts still being delivered to the webhook, or until ctx is done
+func (s *Scheduler) Wait(ctx context.Context) error {
+	return s.alerts.Wait(ctx)
+}
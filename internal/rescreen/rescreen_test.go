@@ -0,0 +1,123 @@
+package rescreen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/events"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+// fakeSubjects hands out the subjects it holds as due and records the
+// outcomes of re-screening them
+type fakeSubjects struct {
+	store.RescreenStore
+	due      []*store.RescreenSubject
+	outcomes map[int64]string
+}
+
+func (s *fakeSubjects) Due(ctx context.Context, before time.Time, limit int) ([]*store.RescreenSubject, error) {
+	if len(s.due) > limit {
+		return s.due[:limit], nil
+	}
+	return s.due, nil
+}
+
+func (s *fakeSubjects) Rescreened(ctx context.Context, id int64, outcome, matchType string) error {
+	s.outcomes[id] = outcome
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	const listedNIK = "3171011505900001"
+	subjects := &fakeSubjects{
+		due: []*store.RescreenSubject{
+			{ID: 1, Tenant: "acme", SubjectHash: "hash-1", Name: "John Doe", NIK: listedNIK, Outcome: service.OutcomeClear},
+			{ID: 2, SubjectHash: "hash-2", Name: "Jane Roe", NIK: "3171011505900002", Outcome: service.OutcomeClear},
+			// Tokenized while tokenization is disabled, so it can't be screened
+			{ID: 3, SubjectHash: "hash-3", Name: "tok_name", NIK: "tok_nik", Tokenized: true, Outcome: service.OutcomeClear},
+		},
+		outcomes: map[int64]string{},
+	}
+
+	var mu sync.Mutex
+	var delivered []events.RescreenAlert
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert events.RescreenAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, alert)
+	}))
+	defer hook.Close()
+
+	var tenants []string
+	records := &testutil.BlacklistStore{
+		GetByNIKFunc: func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+			mu.Lock()
+			tenants = append(tenants, store.Tenant(ctx))
+			mu.Unlock()
+			if nik == listedNIK {
+				return &store.BlacklistRecord{ID: 1, List: list, NIK: nik, Name: "John Doe", Reason: "fraud"}, nil
+			}
+			return nil, nil
+		},
+		GetByFuzzyMatchFunc: func(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*store.BlacklistRecord, bool, error) {
+			return nil, false, nil
+		},
+		GetByPhoneticFunc: func(ctx context.Context, list, name string, birthDate *time.Time) ([]*store.BlacklistRecord, error) {
+			return nil, nil
+		},
+	}
+	cfg := testutil.Config(t)
+	cfg.Match.Lists = "internal"
+	cfg.Rescreen.Interval = 24 * time.Hour
+	cfg.Rescreen.BatchSize = 10
+	cfg.Rescreen.AlertWebhook = hook.URL
+	s, err := service.NewBlacklistService(service.Params{Config: cfg, Cache: testutil.NewCache(), Store: records, Log: zap.NewNop()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := NewScheduler(cfg, s, subjects, nil, zap.NewNop())
+
+	alerts, err := scheduler.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].SubjectID != 1 || alerts[0].Tenant != "acme" ||
+		alerts[0].PreviousOutcome != service.OutcomeClear || alerts[0].Outcome != service.OutcomeHit {
+		t.Fatalf("Run() = %+v, want one alert for subject 1 of acme, previously clear", alerts)
+	}
+	if len(alerts[0].Lists) != 1 || !alerts[0].Lists[0].Matched {
+		t.Errorf("alert lists = %+v, want the matched internal list", alerts[0].Lists)
+	}
+	// A subject that couldn't be screened is left to be tried again
+	want := map[int64]string{1: service.OutcomeHit, 2: service.OutcomeClear}
+	if len(subjects.outcomes) != len(want) || subjects.outcomes[1] != want[1] || subjects.outcomes[2] != want[2] {
+		t.Errorf("recorded outcomes %v, want %v", subjects.outcomes, want)
+	}
+	// Each subject is screened against its own tenant's records
+	if len(tenants) == 0 || tenants[0] != "acme" {
+		t.Errorf("looked up records of tenants %q, want acme first", tenants)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := scheduler.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if len(delivered) != 1 || delivered[0].SubjectHash != "hash-1" {
+		t.Errorf("delivered %+v, want the alert for hash-1", delivered)
+	}
+}
@@ -0,0 +1,364 @@
+// Package sandbox serves the screening API's sandbox tenant. Partners
+// integrating against a real deployment authenticate with keys assigned to
+// the sandbox tenant and are screened against a fixed set of synthetic
+// records, so every check has a known outcome and never touches production
+// data.
+package sandbox
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/phonetic"
+	"blacklist-check/internal/store"
+)
+
+// ErrReadOnly is returned when a sandbox caller tries to change records
+var ErrReadOnly = errors.New("sandbox records are read-only")
+
+// Source is the source of every synthetic record
+const Source = "sandbox"
+
+// matchLimit bounds the candidates returned per lookup, as the database does
+const matchLimit = 5
+
+//go:embed records.json
+var seed []byte
+
+// seedRecord is a synthetic record as written in records.json
+type seedRecord struct {
+	ID           int64             `json:"id"`
+	List         string            `json:"list"`
+	NIK          string            `json:"nik"`
+	Name         string            `json:"name"`
+	BirthPlace   string            `json:"birth_place"`
+	BirthDate    string            `json:"birth_date"`
+	Reason       string            `json:"reason"`
+	ReasonCode   string            `json:"reason_code"`
+	ReasonParams map[string]string `json:"reason_params"`
+	Aliases      []store.Alias     `json:"aliases"`
+}
+
+// Store is a read-only, in-memory blacklist store holding the synthetic
+// records. Its lookups mirror the database queries so that sandbox checks
+// behave like production ones.
+type Store struct {
+	records []*store.BlacklistRecord
+}
+
+var _ store.BlacklistStore = (*Store)(nil)
+
+// NewStore loads the synthetic records
+func NewStore() (*Store, error) {
+	var seeds []seedRecord
+	if err := json.Unmarshal(seed, &seeds); err != nil {
+		return nil, fmt.Errorf("error decoding sandbox records: %w", err)
+	}
+
+	s := &Store{}
+	for _, r := range seeds {
+		record := &store.BlacklistRecord{
+			ID:             r.ID,
+			List:           r.List,
+			NIK:            r.NIK,
+			Name:           r.Name,
+			BirthPlace:     r.BirthPlace,
+			Reason:         r.Reason,
+			ReasonCode:     r.ReasonCode,
+			ReasonParams:   store.ReasonParams(r.ReasonParams),
+			Source:         Source,
+			NamePhonetic:   phonetic.Encode(r.Name),
+			NameNormalized: normalize.Name(r.Name),
+			NameSorted:     normalize.TokenSorted(r.Name),
+			Aliases:        r.Aliases,
+		}
+		if r.BirthDate != "" {
+			date, err := time.Parse("2006-01-02", r.BirthDate)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding sandbox record %d: %w", r.ID, err)
+			}
+			record.BirthDate = &date
+		}
+		s.records = append(s.records, record)
+	}
+	return s, nil
+}
+
+// GetByNIK returns the record of a list with the given NIK, or nil
+func (s *Store) GetByNIK(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	for _, record := range s.records {
+		if record.List == list && record.NIK == nik {
+			return copyRecord(record, 0), nil
+		}
+	}
+	return nil, nil
+}
+
+// CountNIKs counts the synthetic records
+func (s *Store) CountNIKs(ctx context.Context) (int64, error) {
+	return int64(len(s.records)), nil
+}
+
+// EachNIK calls fn with the list and NIK of every synthetic record
+func (s *Store) EachNIK(ctx context.Context, fn func(list, nik string) error) error {
+	for _, record := range s.records {
+		if err := fn(record.List, record.NIK); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByFuzzyMatch returns the records of a list whose name or an alias is
+// more similar than minSimilarity and whose birth data agrees or is missing.
+// A positive budget bounds the candidates considered, the most similar first
+// with ties in record order, and at most limit records are returned.
+func (s *Store) GetByFuzzyMatch(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*store.BlacklistRecord, bool, error) {
+	var matches []*store.BlacklistRecord
+	for _, record := range s.records {
+		if record.List != list {
+			continue
+		}
+		similarity, alias := closestName(record, name)
+		if similarity <= minSimilarity {
+			continue
+		}
+		if birthDate != nil && record.BirthDate != nil && !record.BornOn(*birthDate) {
+			continue
+		}
+		if len(birthPlaces) > 0 && record.BirthPlace != "" && normalize.MaxSimilarity(record.BirthPlace, birthPlaces) <= minSimilarity {
+			continue
+		}
+		match := copyRecord(record, similarity)
+		match.MatchedAlias = alias
+		matches = append(matches, match)
+	}
+	truncated := budget > 0 && len(matches) > budget
+	if truncated {
+		matches = mostSimilar(matches, budget)
+	}
+	return mostSimilar(matches, limit), truncated, nil
+}
+
+// SearchByName returns the records of any list resembling name
+func (s *Store) SearchByName(ctx context.Context, name string) ([]*store.BlacklistRecord, error) {
+	const minSimilarity = 0.3
+
+	var matches []*store.BlacklistRecord
+	for _, record := range s.records {
+		if similarity := normalize.Similarity(record.Name, name); similarity > minSimilarity {
+			matches = append(matches, copyRecord(record, similarity))
+		}
+	}
+	return mostSimilar(matches, matchLimit), nil
+}
+
+// GetByPhonetic returns the records of a list whose name or an alias sounds
+// like name, restricted to the birth date when one is given
+func (s *Store) GetByPhonetic(ctx context.Context, list, name string, birthDate *time.Time) ([]*store.BlacklistRecord, error) {
+	code := phonetic.Encode(name)
+	if code == "" {
+		return nil, nil
+	}
+
+	var matches []*store.BlacklistRecord
+	for _, record := range s.records {
+		if record.List != list {
+			continue
+		}
+		alias, ok := soundsLike(record, code)
+		if !ok {
+			continue
+		}
+		if birthDate != nil && !record.BornOn(*birthDate) {
+			continue
+		}
+		match := copyRecord(record, 0)
+		match.MatchedAlias = alias
+		matches = append(matches, match)
+		if len(matches) == matchLimit {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// NearestByName returns the records of a list most similar to name
+func (s *Store) NearestByName(ctx context.Context, list, name string, limit int) ([]*store.BlacklistRecord, error) {
+	var matches []*store.BlacklistRecord
+	for _, record := range s.records {
+		if record.List == list {
+			matches = append(matches, copyRecord(record, normalize.Similarity(record.Name, name)))
+		}
+	}
+	return mostSimilar(matches, limit), nil
+}
+
+// Search returns the records of a list whose NIK starts with query or whose
+// name contains it
+func (s *Store) Search(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*store.BlacklistRecord, error) {
+	var matches []*store.BlacklistRecord
+	for _, record := range s.records {
+		if record.List != list {
+			continue
+		}
+		if query == "" || strings.HasPrefix(record.NIK, query) || strings.Contains(strings.ToLower(record.Name), strings.ToLower(query)) {
+			matches = append(matches, copyRecord(record, 0))
+		}
+		if len(matches) == limit {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// List returns every record of the filter's list in a single page
+func (s *Store) List(ctx context.Context, filter store.RecordFilter, sort, cursor string, limit int) ([]*store.BlacklistRecord, string, error) {
+	records, err := s.Search(ctx, filter.List, filter.NIK, false, limit)
+	return records, "", err
+}
+
+// ListBySource returns the records of a list from source
+func (s *Store) ListBySource(ctx context.Context, list, source string) ([]*store.BlacklistRecord, error) {
+	if source != Source {
+		return nil, nil
+	}
+	return s.Search(ctx, list, "", false, len(s.records))
+}
+
+// Create is not supported
+func (s *Store) Create(ctx context.Context, record *store.BlacklistRecord) error {
+	return ErrReadOnly
+}
+
+// Update is not supported
+func (s *Store) Update(ctx context.Context, record *store.BlacklistRecord) error {
+	return ErrReadOnly
+}
+
+// Delete is not supported
+func (s *Store) Delete(ctx context.Context, list, nik string) error {
+	return ErrReadOnly
+}
+
+// Restore is not supported
+func (s *Store) Restore(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	return nil, ErrReadOnly
+}
+
+// Confirm is not supported
+func (s *Store) Confirm(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	return nil, ErrReadOnly
+}
+
+// Extend is not supported
+func (s *Store) Extend(ctx context.Context, list, nik string, expiresAt time.Time) (*store.BlacklistRecord, error) {
+	return nil, ErrReadOnly
+}
+
+// MarkExpiring returns nothing; synthetic records never expire
+func (s *Store) MarkExpiring(ctx context.Context, within time.Duration) ([]*store.BlacklistRecord, error) {
+	return nil, nil
+}
+
+// Expire returns nothing; synthetic records never expire
+func (s *Store) Expire(ctx context.Context) ([]*store.BlacklistRecord, error) {
+	return nil, nil
+}
+
+// History returns nothing; synthetic records never change
+func (s *Store) History(ctx context.Context, nik string) ([]*store.RecordChange, error) {
+	return nil, nil
+}
+
+// ApplyChangeSet is not supported
+func (s *Store) ApplyChangeSet(ctx context.Context, cs *store.ChangeSet) error {
+	return ErrReadOnly
+}
+
+// Ping always succeeds
+func (s *Store) Ping(ctx context.Context) error {
+	return nil
+}
+
+// copyRecord returns a copy of record carrying the given similarity, so
+// callers can't alter the synthetic records
+func copyRecord(record *store.BlacklistRecord, similarity float64) *store.BlacklistRecord {
+	c := *record
+	c.Similarity = similarity
+	return &c
+}
+
+// closestName returns the similarity to name of the closest of a record's
+// names, and the alias it is, empty for the record's own name
+func closestName(record *store.BlacklistRecord, name string) (float64, string) {
+	best, matched := normalize.Similarity(record.Name, name), ""
+	for _, alias := range record.Aliases {
+		if similarity := normalize.Similarity(alias.Alias, name); similarity > best {
+			best, matched = similarity, alias.Alias
+		}
+	}
+	return best, matched
+}
+
+// soundsLike reports whether one of a record's names has the phonetic code,
+// and which alias it is, empty for the record's own name
+func soundsLike(record *store.BlacklistRecord, code string) (string, bool) {
+	if record.NamePhonetic == code {
+		return "", true
+	}
+	for _, alias := range record.Aliases {
+		if phonetic.Encode(alias.Alias) == code {
+			return alias.Alias, true
+		}
+	}
+	return "", false
+}
+
+// mostSimilar returns up to limit records, most similar first. Ties keep
+// their record order so results are deterministic.
+func mostSimilar(records []*store.BlacklistRecord, limit int) []*store.BlacklistRecord {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Similarity > records[j].Similarity
+	})
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records
+}
+
+// allowedRoutes are the only routes sandbox callers may use; everything
+// else reads or changes production data
+var allowedRoutes = map[string]bool{
+	"POST /api/v1/blacklist":      true,
+	"GET /api/v1/blacklist/check": true,
+	"GET /api/v1/profiles":        true,
+	"POST /api/v2/blacklist":      true,
+	"GET /api/v2/blacklist/check": true,
+	"GET /api/v2/profiles":        true,
+}
+
+// Middleware forbids sandbox tenant callers from every route but screening
+// and its reference data. It must run after authentication.
+func Middleware(tenant string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity := auth.FromContext(r.Context())
+			if identity != nil && identity.Tenant == tenant && !allowedRoutes[r.Method+" "+r.URL.Path] {
+				apierror.Forbidden(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/store"
+)
+
+func newStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := NewStore()
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	return s
+}
+
+func TestGetByNIK(t *testing.T) {
+	s := newStore(t)
+	ctx := context.Background()
+
+	record, err := s.GetByNIK(ctx, "internal", "3171011705809901")
+	if err != nil || record == nil || record.Name != "Budi Hartono" || record.Source != Source {
+		t.Fatalf("GetByNIK() = %+v, %v, want Budi Hartono from the sandbox", record, err)
+	}
+	// Callers get copies, so the synthetic records can't be altered
+	record.Name = "Changed"
+	if again, _ := s.GetByNIK(ctx, "internal", "3171011705809901"); again.Name != "Budi Hartono" {
+		t.Errorf("GetByNIK() after changing a result = %q, want Budi Hartono", again.Name)
+	}
+	if record, _ := s.GetByNIK(ctx, "sanctions", "3171011705809901"); record != nil {
+		t.Errorf("GetByNIK() on another list = %+v, want nil", record)
+	}
+}
+
+func TestGetByFuzzyMatch(t *testing.T) {
+	s := newStore(t)
+	born := func(date string) *time.Time {
+		d, _ := time.Parse("2006-01-02", date)
+		return &d
+	}
+	tests := []struct {
+		name        string
+		list        string
+		query       string
+		birthPlaces []string
+		birthDate   *time.Time
+		wantIDs     []int64
+		wantAlias   string
+	}{
+		{name: "own name", list: "internal", query: "Budi Hartono", wantIDs: []int64{1}},
+		{name: "alias", list: "sanctions", query: "Wiktor Petrenka", wantIDs: []int64{6}, wantAlias: "Wiktor Petrenka"},
+		{name: "other birth date", list: "internal", query: "Budi Hartono", birthDate: born("1981-05-17")},
+		{name: "same birth date", list: "internal", query: "Budi Hartono", birthDate: born("1980-05-17"), wantIDs: []int64{1}},
+		// A record without a birth date remains a candidate
+		{name: "missing birth date", list: "internal", query: "Dewi Lestari Kusuma", birthDate: born("1990-03-22"), wantIDs: []int64{3}},
+		{name: "other birth place", list: "internal", query: "Budi Hartono", birthPlaces: []string{"Makassar"}},
+		{name: "other list", list: "pep", query: "Budi Hartono"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, truncated, err := s.GetByFuzzyMatch(context.Background(), tt.list, tt.query, tt.birthPlaces, tt.birthDate, 0.3, 0, matchLimit)
+			if err != nil {
+				t.Fatalf("GetByFuzzyMatch() error = %v", err)
+			}
+			var ids []int64
+			for _, r := range records {
+				ids = append(ids, r.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) || truncated {
+				t.Fatalf("GetByFuzzyMatch() = %v, truncated %v, want %v", ids, truncated, tt.wantIDs)
+			}
+			if len(records) > 0 && records[0].MatchedAlias != tt.wantAlias {
+				t.Errorf("matched alias = %q, want %q", records[0].MatchedAlias, tt.wantAlias)
+			}
+		})
+	}
+}
+
+func TestGetByFuzzyMatchBudget(t *testing.T) {
+	s := &Store{records: []*store.BlacklistRecord{
+		{ID: 1, List: "internal", Name: "Jon Doe"},
+		{ID: 2, List: "internal", Name: "John Doe"},
+		{ID: 3, List: "internal", Name: "John Doe"},
+	}}
+	records, truncated, err := s.GetByFuzzyMatch(context.Background(), "internal", "John Doe", nil, nil, 0.3, 2, matchLimit)
+	if err != nil {
+		t.Fatalf("GetByFuzzyMatch() error = %v", err)
+	}
+	if len(records) != 2 || records[0].ID != 2 || records[1].ID != 3 || !truncated {
+		t.Errorf("GetByFuzzyMatch() = %+v, truncated %v, want records 2 and 3, truncated", records, truncated)
+	}
+}
+
+func TestStoreIsReadOnly(t *testing.T) {
+	s := newStore(t)
+	ctx := context.Background()
+	writes := map[string]error{
+		"Create": s.Create(ctx, &store.BlacklistRecord{}),
+		"Update": s.Update(ctx, &store.BlacklistRecord{}),
+		"Delete": s.Delete(ctx, "internal", "3171011705809901"),
+	}
+	_, writes["Restore"] = s.Restore(ctx, "internal", "3171011705809901")
+	_, writes["Confirm"] = s.Confirm(ctx, "internal", "3171011705809901")
+	_, writes["Extend"] = s.Extend(ctx, "internal", "3171011705809901", time.Now())
+	writes["ApplyChangeSet"] = s.ApplyChangeSet(ctx, &store.ChangeSet{})
+	for name, err := range writes {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s() error = %v, want ErrReadOnly", name, err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		tenant     string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"sandbox check", "sandbox", "GET", "/api/v1/blacklist/check", http.StatusOK},
+		{"sandbox screening", "sandbox", "POST", "/api/v2/blacklist", http.StatusOK},
+		{"sandbox record listing", "sandbox", "GET", "/api/v1/blacklist/records", http.StatusForbidden},
+		{"sandbox admin", "sandbox", "POST", "/api/v1/admin/pins", http.StatusForbidden},
+		{"other tenant", "acme", "GET", "/api/v1/blacklist/records", http.StatusOK},
+		{"anonymous", "", "GET", "/healthz", http.StatusOK},
+	}
+	h := Middleware("sandbox")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.tenant != "" {
+				r = r.WithContext(auth.WithIdentity(r.Context(), &auth.Identity{Subject: "partner", Tenant: tt.tenant}))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/recovery"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/usage"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrJobNotFound is returned when a screening job does not exist
+	ErrJobNotFound = errors.New("screening job not found")
+	// ErrJobNotStalled is returned when requeueing a job that isn't stalled
+	ErrJobNotStalled = errors.New("screening job is not stalled")
+)
+
+// Processor accepts bulk screening jobs and works through them in the
+// background, persisting progress after every batch so a restart resumes
+// where it left off
+type Processor struct {
+	store     store.ScreeningStore
+	service   *service.BlacklistService
+	recoverer *recovery.Recoverer
+	cfg       config.ScreeningConfig
+	log       *zap.Logger
+}
+
+// NewProcessor creates a new screening processor
+func NewProcessor(cfg *config.Config, store store.ScreeningStore, service *service.BlacklistService, recoverer *recovery.Recoverer, log *zap.Logger) *Processor {
+	return &Processor{
+		store:     store,
+		service:   service,
+		recoverer: recoverer,
+		cfg:       cfg.Screening,
+		log:       log,
+	}
+}
+
+// MaxSubjects returns the largest number of subjects accepted in one job
+func (p *Processor) MaxSubjects() int {
+	return p.cfg.MaxSubjects
+}
+
+// Submit queues a new job and returns it without waiting for any processing
+func (p *Processor) Submit(ctx context.Context, submittedBy string, subjects []*store.ScreeningSubject) (*store.ScreeningJob, error) {
+	id, err := p.store.Create(ctx, submittedBy, subjects)
+	if err != nil {
+		return nil, err
+	}
+	p.log.Info("Screening job submitted",
+		zap.Int64("job_id", id),
+		zap.Int("subjects", len(subjects)),
+		zap.String("submitted_by", submittedBy))
+	return p.Get(ctx, id)
+}
+
+// Get retrieves a job with its current progress
+func (p *Processor) Get(ctx context.Context, id int64) (*store.ScreeningJob, error) {
+	job, err := p.store.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting screening job: %w", err)
+	}
+	if job == nil {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// Requeue puts a job the watchdog held as stalled back in the queue
+func (p *Processor) Requeue(ctx context.Context, id int64) (*store.ScreeningJob, error) {
+	job, err := p.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	requeued, err := p.store.Requeue(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error requeueing screening job: %w", err)
+	}
+	if !requeued {
+		return nil, ErrJobNotStalled
+	}
+	p.log.Info("Stalled screening job requeued", zap.Int64("job_id", id), zap.Int("attempts", job.Attempts))
+	return p.Get(ctx, id)
+}
+
+// Results streams the subjects of a job along with their results
+func (p *Processor) Results(ctx context.Context, id int64, fn func(*store.ScreeningSubject) error) error {
+	return p.store.Results(ctx, id, fn)
+}
+
+// Run starts the configured number of workers and blocks until ctx is cancelled
+func (p *Processor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// work polls for runnable jobs until ctx is cancelled
+func (p *Processor) work(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before going back to sleep
+		for ctx.Err() == nil {
+			job, err := p.store.Claim(ctx, p.cfg.Lease)
+			if err != nil {
+				if ctx.Err() == nil {
+					p.log.Error("Error claiming screening job", zap.Error(err))
+				}
+				break
+			}
+			if job == nil {
+				break
+			}
+			p.handle(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handle processes a claimed job, releasing it for another attempt on failure
+func (p *Processor) handle(ctx context.Context, job *store.ScreeningJob) {
+	log := p.log.With(zap.Int64("job_id", job.ID), zap.Int("attempt", job.Attempts))
+
+	if job.Attempts > p.cfg.MaxAttempts {
+		log.Error("Screening job exceeded max attempts")
+		if err := p.store.Finish(context.Background(), job.ID, store.ScreeningFailed, job.Error); err != nil {
+			log.Error("Error failing screening job", zap.Error(err))
+		}
+		return
+	}
+
+	log.Info("Processing screening job", zap.Int("processed", job.Processed), zap.Int("total", job.Total))
+	err := p.recoverer.Do("screening", func() error {
+		return p.process(ctx, job)
+	})
+	if err != nil {
+		log.Error("Screening job attempt failed", zap.Error(err))
+		// Release with a fresh context so shutdown doesn't leave the job to wait out its lease
+		if err := p.store.Release(context.Background(), job.ID, err.Error()); err != nil {
+			log.Error("Error releasing screening job", zap.Error(err))
+		}
+		return
+	}
+
+	if err := p.store.Finish(ctx, job.ID, store.ScreeningCompleted, nil); err != nil {
+		log.Error("Error completing screening job", zap.Error(err))
+		return
+	}
+	log.Info("Screening job completed")
+}
+
+// process screens the remaining subjects of a job batch by batch
+func (p *Processor) process(ctx context.Context, job *store.ScreeningJob) error {
+	// Charge the checks to whoever submitted the job, screening the records
+	// of its tenant
+	ctx = store.WithTenant(usage.WithCaller(ctx, job.SubmittedBy), job.Tenant)
+
+	for {
+		subjects, err := p.store.NextBatch(ctx, job.ID, p.cfg.BatchSize)
+		if err != nil {
+			return fmt.Errorf("error loading screening batch: %w", err)
+		}
+		if len(subjects) == 0 {
+			return nil
+		}
+
+		for _, subject := range subjects {
+			req := service.CheckRequest{
+				Name:       subject.Name,
+				NIK:        subject.NIK,
+				BirthPlace: subject.BirthPlace,
+			}
+			if subject.BirthDate != nil {
+				req.BirthDate = *subject.BirthDate
+			}
+
+			result, err := p.service.CheckBlacklist(ctx, req)
+			if err != nil {
+				return fmt.Errorf("error screening subject %d: %w", subject.Seq, err)
+			}
+			subject.Blacklisted = &result.Blacklisted
+			subject.MatchType = &result.MatchType
+			details := result.Describe(reason.DefaultLocale)
+			subject.Details = &details
+		}
+
+		if err := p.store.SaveResults(ctx, job.ID, subjects); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/recovery"
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/testutil"
+	"blacklist-check/internal/usage"
+
+	"go.uber.org/zap"
+)
+
+// fakeJobs is a screening store holding one job in memory
+type fakeJobs struct {
+	store.ScreeningStore
+
+	mu       sync.Mutex
+	job      *store.ScreeningJob
+	subjects []*store.ScreeningSubject
+	// batchErr fails loading the next batch
+	batchErr error
+	claimed  bool
+	saves    int
+	released string
+	finished string
+	// done is closed once the job is finished
+	done chan struct{}
+}
+
+func newFakeJobs(job *store.ScreeningJob, subjects ...*store.ScreeningSubject) *fakeJobs {
+	return &fakeJobs{job: job, subjects: subjects, done: make(chan struct{})}
+}
+
+func (s *fakeJobs) Get(ctx context.Context, id int64) (*store.ScreeningJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.job == nil || s.job.ID != id {
+		return nil, nil
+	}
+	job := *s.job
+	return &job, nil
+}
+
+func (s *fakeJobs) Claim(ctx context.Context, lease time.Duration) (*store.ScreeningJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.claimed {
+		return nil, nil
+	}
+	s.claimed = true
+	s.job.Attempts++
+	job := *s.job
+	return &job, nil
+}
+
+func (s *fakeJobs) NextBatch(ctx context.Context, jobID int64, limit int) ([]*store.ScreeningSubject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.batchErr != nil {
+		return nil, s.batchErr
+	}
+	var batch []*store.ScreeningSubject
+	for _, subject := range s.subjects {
+		if subject.ProcessedAt == nil && len(batch) < limit {
+			batch = append(batch, subject)
+		}
+	}
+	return batch, nil
+}
+
+func (s *fakeJobs) SaveResults(ctx context.Context, jobID int64, results []*store.ScreeningSubject) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saves++
+	now := time.Now()
+	for _, subject := range results {
+		subject.ProcessedAt = &now
+	}
+	return nil
+}
+
+func (s *fakeJobs) Release(ctx context.Context, id int64, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.released = reason
+	return nil
+}
+
+func (s *fakeJobs) Finish(ctx context.Context, id int64, status string, reason *string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = status
+	close(s.done)
+	return nil
+}
+
+func (s *fakeJobs) Requeue(ctx context.Context, id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.job.StalledAt == nil {
+		return false, nil
+	}
+	s.job.StalledAt = nil
+	return true, nil
+}
+
+const listedNIK = "3171011505900001"
+
+// newProcessor creates a processor screening against a store listing only
+// listedNIK. Lookups record the caller and tenant they were made for.
+func newProcessor(t *testing.T, jobs store.ScreeningStore, lookups chan<- string) *Processor {
+	t.Helper()
+	cfg := testutil.Config(t)
+	cfg.Match.Lists = "internal"
+	cfg.Screening.Workers = 1
+	cfg.Screening.PollInterval = 10 * time.Millisecond
+	cfg.Screening.BatchSize = 2
+	cfg.Screening.MaxAttempts = 3
+	records := &testutil.BlacklistStore{
+		GetByNIKFunc: func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+			if lookups != nil {
+				lookups <- usage.Caller(ctx) + "@" + store.Tenant(ctx)
+			}
+			if nik == listedNIK {
+				return &store.BlacklistRecord{ID: 1, List: list, NIK: nik, Name: "John Doe", Reason: "fraud"}, nil
+			}
+			return nil, nil
+		},
+		GetByFuzzyMatchFunc: func(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*store.BlacklistRecord, bool, error) {
+			return nil, false, nil
+		},
+		GetByPhoneticFunc: func(ctx context.Context, list, name string, birthDate *time.Time) ([]*store.BlacklistRecord, error) {
+			return nil, nil
+		},
+	}
+	s, err := service.NewBlacklistService(service.Params{Config: cfg, Cache: testutil.NewCache(), Store: records, Log: zap.NewNop()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewProcessor(cfg, jobs, s, recovery.NewRecoverer(zap.NewNop()), zap.NewNop())
+}
+
+func TestRunScreensJobInBatches(t *testing.T) {
+	jobs := newFakeJobs(&store.ScreeningJob{ID: 1, Tenant: "acme", SubmittedBy: "partner", Total: 3},
+		&store.ScreeningSubject{Seq: 1, Name: "John Doe", NIK: listedNIK},
+		&store.ScreeningSubject{Seq: 2, Name: "Jane Roe", NIK: "3171011505900002"},
+		&store.ScreeningSubject{Seq: 3, Name: "Richard Roe", NIK: "3171011505900003"},
+	)
+	lookups := make(chan string, 10)
+	p := newProcessor(t, jobs, lookups)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+	select {
+	case <-jobs.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job not finished")
+	}
+
+	if jobs.finished != store.ScreeningCompleted || jobs.saves != 2 {
+		t.Errorf("finished %q after %d batches, want %q after 2", jobs.finished, jobs.saves, store.ScreeningCompleted)
+	}
+	for _, subject := range jobs.subjects {
+		want := subject.NIK == listedNIK
+		if subject.Blacklisted == nil || *subject.Blacklisted != want || subject.Details == nil {
+			t.Errorf("subject %d blacklisted = %v, want %v with details", subject.Seq, subject.Blacklisted, want)
+		}
+	}
+	// Checks are charged to the submitter and screen its tenant's records
+	if lookup := <-lookups; lookup != "partner@acme" {
+		t.Errorf("looked up as %s, want partner@acme", lookup)
+	}
+}
+
+func TestHandleReleasesFailedAttempt(t *testing.T) {
+	jobs := newFakeJobs(&store.ScreeningJob{ID: 1, Attempts: 1},
+		&store.ScreeningSubject{Seq: 1, Name: "John Doe"})
+	jobs.batchErr = errors.New("connection reset")
+	p := newProcessor(t, jobs, nil)
+
+	p.handle(context.Background(), jobs.job)
+	if jobs.released == "" || jobs.finished != "" {
+		t.Errorf("released %q, finished %q, want released for another attempt", jobs.released, jobs.finished)
+	}
+}
+
+func TestHandleFailsJobPastMaxAttempts(t *testing.T) {
+	reason := "connection reset"
+	jobs := newFakeJobs(&store.ScreeningJob{ID: 1, Attempts: 4, Error: &reason},
+		&store.ScreeningSubject{Seq: 1, Name: "John Doe"})
+	p := newProcessor(t, jobs, nil)
+
+	p.handle(context.Background(), jobs.job)
+	if jobs.finished != store.ScreeningFailed || jobs.saves != 0 {
+		t.Errorf("finished %q after %d batches, want %q without screening", jobs.finished, jobs.saves, store.ScreeningFailed)
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	stalled := time.Now()
+	tests := []struct {
+		name    string
+		id      int64
+		job     *store.ScreeningJob
+		wantErr error
+	}{
+		{"stalled job", 1, &store.ScreeningJob{ID: 1, StalledAt: &stalled}, nil},
+		{"running job", 1, &store.ScreeningJob{ID: 1}, ErrJobNotStalled},
+		{"unknown job", 2, &store.ScreeningJob{ID: 1}, ErrJobNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newProcessor(t, newFakeJobs(tt.job), nil)
+			job, err := p.Requeue(context.Background(), tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Requeue() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && job.StalledAt != nil {
+				t.Errorf("Requeue() = %+v, want it no longer stalled", job)
+			}
+		})
+	}
+}
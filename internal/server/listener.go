@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation
+const listenFDsStart = 3
+
+// Listen returns the public HTTP listener, preferring the first socket
+// inherited from systemd and otherwise binding the configured port,
+// optionally with SO_REUSEPORT so a new process can bind while the old one is
+// still draining. It serves TLS when a certificate is configured.
+func Listen(ctx context.Context, cfg *config.Config, log *zap.Logger) (net.Listener, error) {
+	tlsConfig, err := TLSConfig(cfg, cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile, cfg.Server.TLSClientCAFile, log)
+	if err != nil {
+		return nil, err
+	}
+	return listen(ctx, cfg, cfg.Server.Port, 0, tlsConfig)
+}
+
+// ListenInternal returns the internal HTTP listener like Listen, from the
+// second socket inherited from systemd or INTERNAL_PORT
+func ListenInternal(ctx context.Context, cfg *config.Config, log *zap.Logger) (net.Listener, error) {
+	tlsConfig, err := TLSConfig(cfg, cfg.Server.InternalTLSCertFile, cfg.Server.InternalTLSKeyFile, cfg.Server.InternalTLSClientCAFile, log)
+	if err != nil {
+		return nil, err
+	}
+	return listen(ctx, cfg, cfg.Server.InternalPort, 1, tlsConfig)
+}
+
+func listen(ctx context.Context, cfg *config.Config, port, index int, tlsConfig *tls.Config) (net.Listener, error) {
+	ln, err := systemdListener(index)
+	if err != nil {
+		return nil, err
+	}
+	if ln == nil {
+		lc := net.ListenConfig{}
+		if cfg.Server.ReusePort {
+			lc.Control = reusePortControl
+		}
+		if ln, err = lc.Listen(ctx, "tcp", fmt.Sprintf(":%d", port)); err != nil {
+			return nil, err
+		}
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	return ln, nil
+}
+
+// activation holds the sockets passed via LISTEN_FDS, read once as the
+// variables are cleared after
+var activation struct {
+	once  sync.Once
+	files []*os.File
+}
+
+// systemdListener returns the socket passed via LISTEN_FDS at index, or nil
+// when the process was not socket activated or got fewer sockets
+func systemdListener(index int) (net.Listener, error) {
+	activation.once.Do(func() {
+		pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		if err != nil || pid != os.Getpid() {
+			return
+		}
+		fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || fds < 1 {
+			return
+		}
+
+		// Don't leak the activation variables to child processes
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+
+		for i := 0; i < fds; i++ {
+			activation.files = append(activation.files, os.NewFile(uintptr(listenFDsStart+i), "systemd-socket"))
+		}
+	})
+	if index >= len(activation.files) {
+		return nil, nil
+	}
+
+	f := activation.files[index]
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("error using systemd socket: %w", err)
+	}
+	f.Close()
+	return ln, nil
+}
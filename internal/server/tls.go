@@ -0,0 +1,179 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// tlsVersions maps the TLS_MIN_VERSION values onto the versions
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig loads the certificate a listener serves, or returns nil when
+// certFile is empty and the listener serves plain HTTP. With a client CA,
+// client certificates it signed are verified for mTLS authentication;
+// callers without one can still authenticate otherwise.
+//
+// The certificate, key and client CA are read again when any of their files
+// changes, checked at most every TLS_RELOAD_INTERVAL, so rotated
+// certificates are served without a restart. A rotation that can't be
+// loaded is logged and the previous certificate kept.
+func TLSConfig(cfg *config.Config, certFile, keyFile, clientCAFile string, log *zap.Logger) (*tls.Config, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+	files := &certificates{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		interval:     cfg.Server.TLSReloadInterval,
+		log:          log.With(zap.String("cert_file", certFile)),
+	}
+	if err := files.load(); err != nil {
+		return nil, err
+	}
+
+	suites, err := CipherSuites(cfg.Server.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	base := &tls.Config{
+		MinVersion:   tlsVersions[cfg.Server.TLSMinVersion],
+		CipherSuites: suites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	// Every handshake gets the certificate and client CA current at the time
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, clientCAs := files.current()
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.Certificates = []tls.Certificate{*cert}
+		if clientCAs != nil {
+			config.ClientCAs = clientCAs
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		return config, nil
+	}
+	return base, nil
+}
+
+// CipherSuites parses a comma-separated list of cipher suite names, as Go
+// names them. They apply to TLS 1.2; TLS 1.3 suites aren't configurable. An
+// empty list leaves the choice to Go's defaults, and insecure suites are
+// refused.
+func CipherSuites(names string) ([]uint16, error) {
+	if strings.TrimSpace(names) == "" {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	var ids []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// certificates holds a listener's certificate and client CA as last loaded
+// from their files
+type certificates struct {
+	certFile, keyFile, clientCAFile string
+	interval                        time.Duration
+	log                             *zap.Logger
+
+	mu        sync.Mutex
+	checked   time.Time
+	modified  time.Time
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// current returns the certificate and client CA, reloading them first when
+// a check is due and a file has changed since they were loaded
+func (c *certificates) current() (*tls.Certificate, *x509.CertPool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.interval > 0 && time.Since(c.checked) >= c.interval {
+		c.checked = time.Now()
+		if modified, err := c.lastModified(); err != nil {
+			c.log.Warn("Error checking TLS certificate files", zap.Error(err))
+		} else if !modified.Equal(c.modified) {
+			if err := c.load(); err != nil {
+				c.log.Error("Error reloading TLS certificate, keeping the previous one", zap.Error(err))
+			} else {
+				c.log.Info("Reloaded TLS certificate", zap.Time("not_after", c.cert.Leaf.NotAfter))
+			}
+		}
+	}
+	return c.cert, c.clientCAs
+}
+
+// load reads the certificate, key and client CA, replacing the current ones
+// only when all of them can be read
+func (c *certificates) load() error {
+	modified, err := c.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("error parsing TLS certificate: %w", err)
+		}
+	}
+	var pool *x509.CertPool
+	if c.clientCAFile != "" {
+		pem, err := os.ReadFile(c.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("error reading client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("client CA file has no certificates")
+		}
+	}
+	c.cert, c.clientCAs, c.modified = &cert, pool, modified
+	return nil
+}
+
+// lastModified returns when the newest of the files was last modified.
+// Files swapped in by symlink, as mounted secrets are, count from the new
+// target.
+func (c *certificates) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{c.certFile, c.keyFile, c.clientCAFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -4,111 +4,788 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"blacklist-check/internal/cache"
+	"blacklist-check/internal/cacheinsight"
+	"blacklist-check/internal/deadline"
+	"blacklist-check/internal/events"
+	"blacklist-check/internal/gazetteer"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikfilter"
+	"blacklist-check/internal/reason"
+	"blacklist-check/internal/reqlog"
+	"blacklist-check/internal/sandbox"
 	"blacklist-check/internal/store"
+	"blacklist-check/internal/tenant"
+	"blacklist-check/internal/tokenize"
+	"blacklist-check/internal/usage"
+	"blacklist-check/pkg/config"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/jmoiron/sqlx"
+	"go.uber.org/dig"
 	"go.uber.org/zap"
 )
 
 // BlacklistService handles blacklist checking business logic
 type BlacklistService struct {
-	db    *sqlx.DB
-	redis *redis.Client
+	cache cache.Cache
 	store store.BlacklistStore
 	log   *zap.Logger
+
+	// tenancy decides whose records, and so whose cached results, a check reads
+	tenancy *store.Tenancy
+
+	// history records production decisions; nil when disabled
+	history          store.CheckHistoryStore
+	historyRetention time.Duration
+
+	// settings holds the tunables, which a configuration reload swaps
+	settings atomic.Pointer[tunables]
+
+	// defaultLists are screened when a request names none
+	defaultLists []string
+
+	// meter charges the cost of each check to its caller
+	meter *usage.Meter
+
+	// birthDateCheck is how birth dates are cross-checked against NIKs
+	birthDateCheck string
+
+	// tokenizer replaces PII in check history
+	tokenizer tokenize.Tokenizer
+
+	// profiles selects how names are matched for each caller
+	profiles matchProfiles
+
+	// whitelist clears subjects of known false positives; nil when unavailable
+	whitelist       store.WhitelistStore
+	whitelistTTL    time.Duration
+	whitelistMaxTTL time.Duration
+	// pins hold records in place during investigations; nil when unavailable
+	pins store.PinStore
+	// rescreen enrols checked subjects for re-screening; nil when disabled
+	rescreen store.RescreenStore
+	// cases opens review cases for positive checks; nil when disabled
+	cases store.CaseStore
+
+	// scorer rates each result for its decision band
+	scorer *Scorer
+
+	// temporaryDefaultDays and temporaryMaxDays bound how long temporary records last
+	temporaryDefaultDays int
+	temporaryMaxDays     int
+
+	// events publishes each production decision; nil when disabled
+	events *events.Publisher
+
+	// nikFilter rules out unlisted NIKs before the exact-match lookups; nil when disabled
+	nikFilter *nikfilter.Filter
+	// insights tracks cache lookups by subject; nil when disabled
+	insights *cacheinsight.Insights
+	// trackNIKs counts checks per NIK for the startup cache warm-up
+	trackNIKs bool
+
+	// gazetteer recognizes birth places for input warnings; nil when disabled
+	gazetteer *gazetteer.Gazetteer
+
+	// sandbox screens callers of sandboxTenant against synthetic records; nil when disabled
+	sandbox       *BlacklistService
+	sandboxTenant string
+
+	// queryTimeout bounds each database query of a check, and checkTimeout
+	// the whole check unless the caller set a deadline of its own
+	queryTimeout time.Duration
+	checkTimeout time.Duration
+}
+
+// Params are the dependencies of the blacklist service. The optional ones
+// may be left nil, leaving the features they back unavailable.
+type Params struct {
+	dig.In
+
+	Config *config.Config
+	Cache  cache.Cache
+	Store  store.BlacklistStore
+	Log    *zap.Logger
+
+	History   store.CheckHistoryStore `optional:"true"`
+	Whitelist store.WhitelistStore    `optional:"true"`
+	Pins      store.PinStore          `optional:"true"`
+	Rescreen  store.RescreenStore     `optional:"true"`
+	Cases     store.CaseStore         `optional:"true"`
+	Publisher *events.Publisher       `optional:"true"`
+	NIKFilter *nikfilter.Filter       `optional:"true"`
+	Insights  *cacheinsight.Insights  `optional:"true"`
 }
 
 // NewBlacklistService creates a new blacklist service
-func NewBlacklistService(db *sqlx.DB, redis *redis.Client, store store.BlacklistStore, log *zap.Logger) *BlacklistService {
-	return &BlacklistService{
-		db:    db,
-		redis: redis,
-		store: store,
-		log:   log,
+func NewBlacklistService(p Params) (*BlacklistService, error) {
+	cfg, log := p.Config, p.Log
+	history, whitelist, pins, rescreen, cases := p.History, p.Whitelist, p.Pins, p.Rescreen, p.Cases
+	settings, err := newTunables(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defaultLists := splitList(cfg.Match.Lists)
+	if err := lists.Validate(defaultLists); err != nil {
+		return nil, fmt.Errorf("error loading default lists: %w", err)
+	}
+	meter, err := usage.NewMeter(cfg.Usage.CostRates)
+	if err != nil {
+		return nil, fmt.Errorf("error loading cost rates: %w", err)
+	}
+	if err := validateBirthDateCheck(cfg.NIK.BirthDateCheck); err != nil {
+		return nil, fmt.Errorf("error loading NIK config: %w", err)
+	}
+	tokenizer, err := tokenize.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading tokenizer: %w", err)
+	}
+	profiles, err := newMatchProfiles(cfg.Match.Profile, cfg.Match.CallerProfiles)
+	if err != nil {
+		return nil, fmt.Errorf("error loading matching profiles: %w", err)
 	}
+	scorer, err := NewScorer(cfg.Score.Weights, cfg.Score.ReviewThreshold, cfg.Score.HitThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("error loading score config: %w", err)
+	}
+	var places *gazetteer.Gazetteer
+	if cfg.Gazetteer.Enabled {
+		if places, err = gazetteer.New(cfg.Gazetteer.File); err != nil {
+			return nil, fmt.Errorf("error loading gazetteer: %w", err)
+		}
+	}
+
+	tenancy, err := tenant.Tenancy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.History.Enabled {
+		history = nil
+	}
+	if !cfg.Rescreen.Enabled {
+		rescreen = nil
+	}
+	if !cfg.Cases.Enabled {
+		cases = nil
+	}
+	// The whitelist and pins have no setting of their own, and no tables but in Postgres
+	if cfg.Database.Driver != "postgres" {
+		whitelist, pins = nil, nil
+	}
+
+	service := &BlacklistService{
+		cache:            p.Cache,
+		store:            p.Store,
+		log:              log,
+		tenancy:          tenancy,
+		history:          history,
+		historyRetention: cfg.History.Retention,
+		defaultLists:     defaultLists,
+		meter:            meter,
+		birthDateCheck:   cfg.NIK.BirthDateCheck,
+		tokenizer:        tokenizer,
+		profiles:         profiles,
+		whitelist:        whitelist,
+		whitelistTTL:     cfg.Whitelist.DefaultTTL,
+		whitelistMaxTTL:  cfg.Whitelist.MaxTTL,
+		pins:             pins,
+		rescreen:         rescreen,
+		cases:            cases,
+		scorer:           scorer,
+		events:           p.Publisher,
+		nikFilter:        p.NIKFilter,
+		insights:         p.Insights,
+		trackNIKs:        cfg.Cache.WarmupEnabled,
+		gazetteer:        places,
+
+		temporaryDefaultDays: cfg.Temporary.DefaultDays,
+		temporaryMaxDays:     cfg.Temporary.MaxDays,
+
+		queryTimeout: cfg.Database.QueryTimeout,
+		checkTimeout: cfg.Server.CheckTimeout,
+	}
+
+	if err := service.setTunables(settings); err != nil {
+		return nil, err
+	}
+
+	if cfg.Sandbox.Enabled {
+		records, err := sandbox.NewStore()
+		if err != nil {
+			return nil, err
+		}
+		// The sandbox matches like production but shares none of its data:
+		// no cache, whitelist, check history or usage
+		service.sandbox = &BlacklistService{
+			store:          records,
+			log:            log.With(zap.String("tenant", cfg.Sandbox.Tenant)),
+			defaultLists:   defaultLists,
+			birthDateCheck: cfg.NIK.BirthDateCheck,
+			profiles:       profiles,
+			scorer:         scorer,
+			gazetteer:      places,
+		}
+		sandboxSettings := *settings
+		if err := service.sandbox.setTunables(&sandboxSettings); err != nil {
+			return nil, err
+		}
+		service.sandboxTenant = cfg.Sandbox.Tenant
+	}
+	return service, nil
+}
+
+// logger returns the logger scoped to the request in ctx, or the service's
+// own for background work
+func (s *BlacklistService) logger(ctx context.Context) *zap.Logger {
+	return reqlog.From(ctx, s.log)
 }
 
 // CheckRequest represents a blacklist check request
 type CheckRequest struct {
-	Name       string
+	Name string
+	// NIK is the national ID number of the country in IDCountry
 	NIK        string
+	IDCountry  string
 	BirthPlace string
 	BirthDate  time.Time
+	// Lists to screen against; empty means the configured default lists
+	Lists []string
+	// Diagnostics reports the nearest records on lists that didn't match
+	Diagnostics bool
+	// Profile is the name matching profile; empty means the caller's or the default
+	Profile string
+	// CandidateBudget lowers the policy's candidate budget for this check; 0 keeps it
+	CandidateBudget int
+	// Explain reports how the subject compared against each matched record
+	Explain bool
+	// RescreenConsent is whether the subject consents to re-screening; nil
+	// leaves any earlier enrolment as it is
+	RescreenConsent *bool
+}
+
+// HasBirthPlace reports whether the subject's birth place is known. A blank
+// birth place is as good as none.
+func (req CheckRequest) HasBirthPlace() bool {
+	return strings.TrimSpace(req.BirthPlace) != ""
 }
 
-// CheckResult represents the result of a blacklist check
+// HasBirthDate reports whether the subject's birth date is known
+func (req CheckRequest) HasBirthDate() bool {
+	return !req.BirthDate.IsZero()
+}
+
+// withOptionalFields returns req with its unknown fields cleared, so a blank
+// birth place is left out of the search, the rules, the cache key and the
+// check history rather than compared against records
+func (req CheckRequest) withOptionalFields() CheckRequest {
+	if !req.HasBirthPlace() {
+		req.BirthPlace = ""
+	}
+	return req
+}
+
+// CheckResult represents the result of a blacklist check. The top-level
+// fields summarize the first match on a blocking list, in the order the
+// lists were requested; Lists holds the outcome on every screened list.
 type CheckResult struct {
-	Blacklisted bool
-	Details     string
-	MatchType   string
+	Blacklisted  bool
+	Details      string
+	MatchType    string
+	ReasonCode   string
+	ReasonParams map[string]string
+	Confidence   float64
+	// MatchedAlias is the alias the match was made on, if any
+	MatchedAlias string
+	// Score and Decision are the highest score on a blocking list and its band
+	Score    float64
+	Decision string
+	Lists    []ListResult
+	// Cost counts the operations the check performed
+	Cost usage.Cost
+	// ID is decoded from the request's national ID; nil when it had none
+	ID *IDCheck
+	// Sandbox is set when the check ran against the synthetic sandbox records
+	Sandbox bool
+	// UnknownReason says why a blocking list was left unknown when nothing
+	// matched
+	UnknownReason string
+	// Degraded is set when some lists weren't screened before the caller's
+	// deadline; their results have match type unknown
+	Degraded bool
+	// PolicyVersion is the effective policy the check was made under
+	PolicyVersion string
+	// Warnings flag suspect input the check ran with regardless
+	Warnings []Warning
+	// Explanation says why the blocking list summarized above matched, when
+	// explanations were requested
+	Explanation *Explanation
+	// CaseID is the review case the check opened or added its hit to; zero
+	// when it opened none
+	CaseID int64
+}
+
+// Outcome is hit when the subject is blacklisted, unknown when a blocking
+// list could be neither matched nor cleared, and clear otherwise
+func (r *CheckResult) Outcome() string {
+	return OutcomeOf(r.Blacklisted, r.MatchType)
 }
 
-// CheckBlacklist checks if a person is blacklisted
+// ListResult is the outcome of screening against a single list. A match on
+// an informational list such as PEP doesn't blacklist the subject.
+type ListResult struct {
+	List         string
+	Matched      bool
+	Details      string
+	MatchType    string
+	ReasonCode   string
+	ReasonParams map[string]string
+	// Confidence rates a match from 0 to 1, lowered when the matched record
+	// lacks fields the rules would otherwise have compared
+	Confidence float64
+	// RecordID is the matched record
+	RecordID int64
+	// MatchedAlias is the alias of the record the subject's name matched,
+	// empty when it matched the record's own name
+	MatchedAlias string
+	// SuppressedBy is the whitelist entry that cleared a match
+	SuppressedBy int64
+	// Score rates the matched record, or the closest candidate on a
+	// no-match, and Decision is the band it falls into
+	Score    float64
+	Decision string
+	Factors  []ScoreFactor
+	// NearMisses explains a no-match when diagnostics were requested
+	NearMisses []NearMiss
+	// Explanation explains a match when explanations were requested
+	Explanation *Explanation
+	// UnknownReason says why the list could be neither matched nor cleared
+	UnknownReason string
+	// Truncated reports to diagnostics requests that fuzzy matching left
+	// candidates out for the candidate budget
+	Truncated bool
+	// candidatesTruncated is whether it did, whatever was requested
+	candidatesTruncated bool
+}
+
+// Outcome is hit on a match, unknown when the list could be neither matched
+// nor cleared, and clear otherwise
+func (r *ListResult) Outcome() string {
+	return OutcomeOf(r.Matched, r.MatchType)
+}
+
+// OutcomeOf derives the outcome of a result from whether it matched and its
+// match type, for results stored without one
+func OutcomeOf(matched bool, matchType string) string {
+	switch {
+	case matched:
+		return OutcomeHit
+	case matchType == MatchUnknown:
+		return OutcomeUnknown
+	}
+	return OutcomeClear
+}
+
+// Describe returns the reason for a match in locale: the rendered reason
+// template when the record has a code, else its free-text reason
+func (r *CheckResult) Describe(locale string) string {
+	return describe(r.ReasonCode, r.ReasonParams, r.Details, locale)
+}
+
+// Describe returns the reason for a match on the list in locale
+func (r *ListResult) Describe(locale string) string {
+	return describe(r.ReasonCode, r.ReasonParams, r.Details, locale)
+}
+
+func describe(code string, params map[string]string, details, locale string) string {
+	if code != "" {
+		return reason.Render(code, params, locale)
+	}
+	return details
+}
+
+// summarize builds a check result from per-list results
+func summarize(results []ListResult) *CheckResult {
+	result := &CheckResult{MatchType: MatchNone, Decision: DecisionClear, Lists: results}
+	for _, r := range results {
+		if lists.Blocking(r.List) && r.Score > result.Score {
+			result.Score = r.Score
+			result.Decision = r.Decision
+		}
+	}
+	for _, r := range results {
+		if r.UnknownReason == UnknownDeadline {
+			result.Degraded = true
+		}
+	}
+	var unknown *ListResult
+	for i, r := range results {
+		if !lists.Blocking(r.List) {
+			continue
+		}
+		if r.Matched {
+			result.Blacklisted = true
+			result.Details = r.Details
+			result.MatchType = r.MatchType
+			result.ReasonCode = r.ReasonCode
+			result.ReasonParams = r.ReasonParams
+			result.Confidence = r.Confidence
+			result.MatchedAlias = r.MatchedAlias
+			result.Explanation = r.Explanation
+			return result
+		}
+		if r.MatchType == MatchSuppressed {
+			result.MatchType = MatchSuppressed
+		}
+		if r.MatchType == MatchUnknown && (unknown == nil || r.Decision == DecisionUnknown) {
+			unknown = &results[i]
+		}
+	}
+	// A blocking list left unknown could still have matched, so without a
+	// match elsewhere the check can't be cleared
+	if unknown != nil {
+		result.MatchType = MatchUnknown
+		result.UnknownReason = unknown.UnknownReason
+		if unknown.Decision == DecisionUnknown {
+			result.Decision = DecisionUnknown
+		}
+	}
+	return result
+}
+
+// listsFor returns the lists a request screens against, without duplicates
+func (s *BlacklistService) listsFor(req CheckRequest) []string {
+	if len(req.Lists) == 0 {
+		return s.defaultLists
+	}
+	seen := make(map[string]bool, len(req.Lists))
+	var result []string
+	for _, list := range req.Lists {
+		if !seen[list] {
+			seen[list] = true
+			result = append(result, list)
+		}
+	}
+	return result
+}
+
+// CheckBlacklist checks if a person is blacklisted on any of the requested lists
 func (s *BlacklistService) CheckBlacklist(ctx context.Context, req CheckRequest) (*CheckResult, error) {
-	// Generate cache key based on request type
-	var cacheKey string
-	if req.NIK != "" {
-		cacheKey = fmt.Sprintf("blacklist:nik:%s", req.NIK)
-	} else {
-		cacheKey = fmt.Sprintf("blacklist:name:%s:%s:%s", 
-			req.Name, 
-			req.BirthPlace, 
-			req.BirthDate.Format("2006-01-02"))
+	start := time.Now()
+	req = req.withOptionalFields()
+	idCheck, err := s.CheckID(req)
+	if err != nil {
+		return nil, err
+	}
+	if idCheck != nil {
+		// Records are keyed by the ID without formatting
+		req.NIK = idCheck.Number
+	}
+	profile, err := s.profiles.resolve(ctx, req.Profile)
+	if err != nil {
+		return nil, err
+	}
+	req.Profile = profile.Name
+	warnings := s.warnings(req, idCheck)
+
+	checkCtx, cancel := s.budget(ctx)
+	defer cancel()
+	if s.isSandbox(ctx) {
+		result, err := s.sandbox.checkSandbox(checkCtx, req, idCheck)
+		if err != nil {
+			return nil, s.budgetError(ctx, checkCtx, err)
+		}
+		result.Warnings = warnings
+		return result, nil
+	}
+	countWarnings(warnings)
+	s.trackNIK(checkCtx, req.NIK)
+
+	version := s.nameVersion(checkCtx)
+	settings := s.current()
+	policy := settings.policyFor(store.Tenant(ctx))
+	cost := usage.Cost{}
+
+	// Whitelist entries are only looked up once a list matches
+	var suppressions map[int64]int64
+	var results []ListResult
+	requested := s.listsFor(req)
+	for i, list := range requested {
+		result, err := s.checkList(checkCtx, req, list, version, settings, cost)
+		if err == nil && result.Matched && s.whitelist != nil {
+			result, suppressions, err = s.suppress(checkCtx, req, result, suppressions, policy, cost)
+		}
+		if err != nil {
+			// Once the caller's deadline has passed, the lists not yet
+			// screened are answered as unknown instead of failing the check
+			if !deadline.Exceeded(ctx) {
+				return nil, s.budgetError(ctx, checkCtx, err)
+			}
+			for _, list := range requested[i:] {
+				results = append(results, ListResult{
+					List:          list,
+					MatchType:     MatchUnknown,
+					UnknownReason: UnknownDeadline,
+					Details:       "Not screened before the requested deadline",
+					Decision:      DecisionUnknown,
+				})
+			}
+			break
+		}
+		results = append(results, *result)
+	}
+
+	result := summarize(results)
+	if req.Diagnostics && !result.Degraded {
+		if err := s.diagnose(checkCtx, req, result, policy, cost); err != nil {
+			return nil, s.budgetError(ctx, checkCtx, err)
+		}
+	}
+	result.Cost = cost
+	result.ID = idCheck
+	result.Warnings = warnings
+	result.PolicyVersion = settings.policyVersion
+	s.meter.Record(ctx, cost)
+	// A degraded answer isn't a decision worth replaying later
+	if !result.Degraded {
+		s.recordCheck(ctx, req, result)
+		s.enrol(ctx, req, result)
+		s.openCase(ctx, req, result)
+	}
+	s.publishDecision(ctx, req, result, time.Since(start))
+	return result, nil
+}
+
+// suppress reevaluates a whitelisted match without the record so that another
+// record can still match, looking the subject's entries up on the first
+// match. The outcome depends on the subject's whitelist and is never cached.
+func (s *BlacklistService) suppress(ctx context.Context, req CheckRequest, result *ListResult, suppressions map[int64]int64, policy MatchPolicy, cost usage.Cost) (*ListResult, map[int64]int64, error) {
+	if suppressions == nil {
+		var err error
+		if suppressions, err = s.suppressions(ctx, req); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, ok := suppressions[result.RecordID]; !ok {
+		return result, suppressions, nil
+	}
+	result, err := s.evaluate(ctx, req, result.List, evaluation{policy: policy, log: s.logger(ctx), cost: cost, suppressions: suppressions})
+	return result, suppressions, err
+}
+
+// checkList screens against a single list, serving the result from cache when possible
+func (s *BlacklistService) checkList(ctx context.Context, req CheckRequest, list string, version int64, settings *tunables, cost usage.Cost) (*ListResult, error) {
+	// Explanations compare the subject against the matched record, which a
+	// cached result doesn't keep, so explained checks go to the database
+	if req.Explain {
+		return s.evaluate(ctx, req, list, evaluation{policy: settings.policyFor(store.Tenant(ctx)), log: s.logger(ctx), observe: true, cost: cost, nikUnlisted: req.NIK != "" && !s.nikFilter.MayContain(list, req.NIK)})
+	}
+
+	// Exact NIK hits are cached under the NIK so they can be invalidated per record;
+	// everything else depends on fuzzy matching and lives under the versioned name namespace
+	nameKey := nameCacheKey(version, s.nameTenant(ctx, list, settings), list, req.Profile, req.Name, req.BirthPlace, req.BirthDate)
+	lookupKeys := []string{nameKey}
+	// A NIK the filter rules out can have neither a cached nor a stored exact match
+	nikListed := req.NIK != "" && s.nikFilter.MayContain(list, req.NIK)
+	if nikListed {
+		lookupKeys = []string{nikCacheKey(s.nikTenant(ctx, list), list, req.NIK), nameKey}
 	}
 
 	// Try to get from cache first
-	cachedResult, err := s.redis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var result CheckResult
-		if err := json.Unmarshal([]byte(cachedResult), &result); err == nil {
-			s.log.Info("Cache hit for blacklist check",
+	for _, cacheKey := range lookupKeys {
+		cachedResult, err := s.cache.Get(ctx, cacheKey)
+		if err != nil {
+			// A slow cache reads as a miss, so the database still answers
+			if s.timedOut(ctx, DependencyRedis, err) {
+				s.logger(ctx).Warn("Cache lookup timed out", zap.String("cache_key", cacheKey), zap.Error(err))
+			}
+			continue
+		}
+		var result ListResult
+		if err := json.Unmarshal(cachedResult, &result); err == nil {
+			metrics.CacheHitsTotal.WithLabelValues(metrics.CacheRedis).Inc()
+			s.recordLookup(list, req, true)
+			cost.Add(usage.CacheHit)
+			s.logger(ctx).Info("Cache hit for blacklist check",
 				zap.String("cache_key", cacheKey),
 				zap.String("match_type", result.MatchType))
 			return &result, nil
 		}
 	}
+	metrics.CacheMissesTotal.WithLabelValues(metrics.CacheRedis).Inc()
+	s.recordLookup(list, req, false)
 
 	// If not in cache, check database
-	var result CheckResult
+	result, err := s.evaluate(ctx, req, list, evaluation{policy: settings.policyFor(store.Tenant(ctx)), log: s.logger(ctx), observe: true, cost: cost, nikUnlisted: req.NIK != "" && !nikListed})
+	if err != nil {
+		return nil, err
+	}
 
-	// First try exact NIK match if provided
-	if req.NIK != "" {
-		record, err := s.store.GetByNIK(ctx, req.NIK)
+	// A truncated result depends on the candidate budget, which the cache key
+	// leaves out, so only complete ones are cached
+	if result.candidatesTruncated {
+		return result, nil
+	}
+
+	// Cache the result
+	cacheKey := nameKey
+	if result.MatchType == MatchExactNIK {
+		cacheKey = nikCacheKey(s.nikTenant(ctx, list), list, req.NIK)
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		s.logger(ctx).Error("Error marshaling result for cache",
+			zap.Error(err))
+	} else {
+		err = s.cache.Set(ctx, cacheKey, resultJSON, settings.cacheTTL(result))
+		if s.timedOut(ctx, DependencyRedis, err) {
+			s.logger(ctx).Warn("Caching result timed out", zap.String("cache_key", cacheKey), zap.Error(err))
+		} else if err != nil {
+			s.logger(ctx).Error("Error caching result",
+				zap.Error(err))
+		}
+	}
+
+	return result, nil
+}
+
+// evaluation carries what differs between a production check and a simulation
+type evaluation struct {
+	policy MatchPolicy
+	log    *zap.Logger
+	// observe records decision metrics; simulations must not skew them
+	observe bool
+	// cost accumulates the operations performed; nil for simulations, which aren't metered
+	cost usage.Cost
+	// suppressions maps whitelisted record IDs to the entry clearing them
+	suppressions map[int64]int64
+	// nikUnlisted skips the exact NIK lookup for a NIK the filter ruled out
+	nikUnlisted bool
+}
+
+// charge counts op against the check being evaluated
+func (e evaluation) charge(op string) {
+	if e.cost != nil {
+		e.cost.Add(op)
+	}
+}
+
+// evaluateLists screens against every requested list, bypassing the cache
+func (s *BlacklistService) evaluateLists(ctx context.Context, req CheckRequest, e evaluation) (*CheckResult, error) {
+	var results []ListResult
+	for _, list := range s.listsFor(req) {
+		result, err := s.evaluate(ctx, req, list, e)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+	return summarize(results), nil
+}
+
+// evaluate runs the matching rules of a policy against one list in the database, bypassing the cache
+func (s *BlacklistService) evaluate(ctx context.Context, req CheckRequest, list string, e evaluation) (*ListResult, error) {
+	var result ListResult
+	log := e.log.With(zap.String("list", list))
+
+	// allowed reports whether record may match, noting the whitelist entry
+	// when the subject has been cleared of it
+	var suppressedBy int64
+	// candidates are every record considered, for scoring
+	var candidates []*store.BlacklistRecord
+	// candidatesTruncated is set when a fuzzy search hit the candidate budget
+	var candidatesTruncated bool
+	allowed := func(record *store.BlacklistRecord) bool {
+		if id, ok := e.suppressions[record.ID]; ok {
+			if suppressedBy == 0 {
+				suppressedBy = id
+			}
+			return false
+		}
+		return true
+	}
+
+	// First try exact NIK match if provided. With a name to search too, both
+	// lookups run at once and the fuzzy search is used only without a match.
+	nikLookup := req.NIK != "" && e.policy.enabled(MatchExactNIK) && !e.nikUnlisted
+	var search *fuzzySearch
+	if nikLookup {
+		e.charge(usage.NIKLookup)
+		var record *store.BlacklistRecord
+		var err error
+		if e.policy.fuzzy() {
+			record, search, err = s.lookupConcurrently(ctx, req, list, e, log)
+		} else {
+			record, err = s.lookupNIK(ctx, req, list, e)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("error checking NIK: %w", err)
+			return nil, err
 		}
 		if record != nil {
-			result = CheckResult{
-				Blacklisted: true,
-				Details:     record.Reason,
-				MatchType:   "exact_nik",
+			candidates = append(candidates, record)
+		}
+		if record != nil && allowed(record) {
+			result = ListResult{
+				Matched:      true,
+				Details:      record.Reason,
+				ReasonCode:   record.ReasonCode,
+				ReasonParams: record.ReasonParams,
+				MatchType:    MatchExactNIK,
+				Confidence:   1,
+				RecordID:     record.ID,
 			}
-			s.log.Info("Found blacklist record by NIK",
+			log.Info("Found blacklist record by NIK",
 				zap.String("nik", req.NIK),
 				zap.String("match_type", result.MatchType))
 		}
 	}
 
 	// If no NIK match, try fuzzy matching with birth place and birth date
-	if !result.Blacklisted {
-		records, err := s.store.GetByFuzzyMatch(ctx, req.Name, &req.BirthPlace, &req.BirthDate)
-		if err != nil {
-			return nil, fmt.Errorf("error searching by fuzzy match: %w", err)
+	if !result.Matched {
+		var records []*store.BlacklistRecord
+		if e.policy.fuzzy() {
+			if search == nil {
+				var err error
+				search, err = s.searchFuzzy(ctx, req, list, e, log)
+				if err != nil {
+					return nil, err
+				}
+			}
+			for i := 0; i < search.queries; i++ {
+				e.charge(usage.FuzzyQuery)
+			}
+			if search.truncated > 0 {
+				candidatesTruncated = true
+				if e.observe {
+					metrics.FuzzyMatchTruncatedTotal.WithLabelValues(list).Add(float64(search.truncated))
+				}
+			}
+			records = search.records
+			if e.observe {
+				metrics.FuzzyMatchCandidates.Observe(float64(len(records)))
+			}
+			candidates = append(candidates, records...)
 		}
 
-		if len(records) > 0 {
+		if len(records) > 0 && e.policy.enabled(MatchFuzzyFull) {
 			// Check if any record matches both birth place and birth date
 			for _, record := range records {
-				if record.BirthPlace == req.BirthPlace && record.BirthDate.Equal(req.BirthDate) {
-					result = CheckResult{
-						Blacklisted: true,
-						Details:     record.Reason,
-						MatchType:   "fuzzy_full_match",
+				if req.HasBirthPlace() && s.gazetteer.Same(record.BirthPlace, req.BirthPlace) && e.policy.bornOn(record, req.BirthDate) && allowed(record) {
+					result = ListResult{
+						Matched:      true,
+						Details:      record.Reason,
+						ReasonCode:   record.ReasonCode,
+						ReasonParams: record.ReasonParams,
+						MatchType:    MatchFuzzyFull,
+						Confidence:   e.policy.confidence(record),
+						RecordID:     record.ID,
+						MatchedAlias: record.MatchedAlias,
 					}
-					s.log.Info("Found blacklist record by fuzzy full match",
+					log.Info("Found blacklist record by fuzzy full match",
 						zap.String("name", req.Name),
 						zap.String("birth_place", req.BirthPlace),
 						zap.Time("birth_date", req.BirthDate),
@@ -116,50 +793,178 @@ func (s *BlacklistService) CheckBlacklist(ctx context.Context, req CheckRequest)
 					break
 				}
 			}
+		}
+
+		// If no full match found, try partial match with birth date only
+		if len(records) > 0 && !result.Matched && e.policy.enabled(MatchFuzzyDate) {
+			for _, record := range records {
+				if e.policy.bornOn(record, req.BirthDate) && allowed(record) {
+					result = ListResult{
+						Matched:      true,
+						Details:      record.Reason,
+						ReasonCode:   record.ReasonCode,
+						ReasonParams: record.ReasonParams,
+						MatchType:    MatchFuzzyDate,
+						Confidence:   e.policy.confidence(record),
+						RecordID:     record.ID,
+						MatchedAlias: record.MatchedAlias,
+					}
+					log.Info("Found blacklist record by fuzzy date match",
+						zap.String("name", req.Name),
+						zap.Time("birth_date", req.BirthDate),
+						zap.String("match_type", result.MatchType))
+					break
+				}
+			}
+		}
 
-			// If no full match found, try partial match with birth date only
-			if !result.Blacklisted {
+		// Records without a birth date can't match on it; match them on birth
+		// place, or on a closer name when they lack that too
+		if len(records) > 0 && !result.Matched && e.policy.enabled(MatchFuzzyPlace) && req.HasBirthPlace() {
+			for _, record := range records {
+				if record.BirthDate == nil && s.gazetteer.Same(record.BirthPlace, req.BirthPlace) && allowed(record) {
+					result = ListResult{
+						Matched:      true,
+						Details:      record.Reason,
+						ReasonCode:   record.ReasonCode,
+						ReasonParams: record.ReasonParams,
+						MatchType:    MatchFuzzyPlace,
+						Confidence:   e.policy.confidence(record),
+						RecordID:     record.ID,
+						MatchedAlias: record.MatchedAlias,
+					}
+					log.Info("Found blacklist record by fuzzy place match",
+						zap.String("name", req.Name),
+						zap.String("birth_place", req.BirthPlace),
+						zap.String("match_type", result.MatchType))
+					break
+				}
+			}
+		}
+		if len(records) > 0 && !result.Matched && e.policy.enabled(MatchFuzzyName) {
+			for _, record := range records {
+				if record.BirthDate == nil && record.BirthPlace == "" && record.Similarity >= e.policy.NameOnlySimilarity && allowed(record) {
+					result = ListResult{
+						Matched:      true,
+						Details:      record.Reason,
+						ReasonCode:   record.ReasonCode,
+						ReasonParams: record.ReasonParams,
+						MatchType:    MatchFuzzyName,
+						Confidence:   e.policy.confidence(record),
+						RecordID:     record.ID,
+						MatchedAlias: record.MatchedAlias,
+					}
+					log.Info("Found blacklist record by fuzzy name match",
+						zap.String("name", req.Name),
+						zap.Float64("similarity", record.Similarity),
+						zap.String("match_type", result.MatchType))
+					break
+				}
+			}
+		}
+
+		// If trigram matching found nothing, fall back to phonetic matching to
+		// catch transliteration variants such as "Achmad"/"Ahmad". Without a
+		// birth date to confirm it, a phonetic code alone is too weak to match.
+		if !result.Matched && e.policy.enabled(MatchPhonetic) && req.HasBirthDate() {
+			// With a tolerance, birth dates are compared here rather than in the query
+			birthDate := &req.BirthDate
+			if e.policy.BirthDateToleranceDays > 0 {
+				birthDate = nil
+			}
+			var match *store.BlacklistRecord
+			for _, name := range req.variants() {
+				e.charge(usage.PhoneticQuery)
+				queryCtx, cancel := s.query(ctx)
+				records, err := s.store.GetByPhonetic(queryCtx, list, name, birthDate)
+				cancel()
+				if err != nil {
+					return nil, fmt.Errorf("error searching by phonetic match: %w", s.dependencyError(ctx, DependencyPostgres, err))
+				}
+				candidates = append(candidates, records...)
 				for _, record := range records {
-					if record.BirthDate.Equal(req.BirthDate) {
-						result = CheckResult{
-							Blacklisted: true,
-							Details:     record.Reason,
-							MatchType:   "fuzzy_date_match",
-						}
-						s.log.Info("Found blacklist record by fuzzy date match",
-							zap.String("name", req.Name),
-							zap.Time("birth_date", req.BirthDate),
-							zap.String("match_type", result.MatchType))
+					if e.policy.bornOn(record, req.BirthDate) && allowed(record) {
+						match = record
 						break
 					}
 				}
+				if match != nil {
+					break
+				}
+			}
+			if match != nil {
+				result = ListResult{
+					Matched:      true,
+					Details:      match.Reason,
+					ReasonCode:   match.ReasonCode,
+					ReasonParams: match.ReasonParams,
+					MatchType:    MatchPhonetic,
+					Confidence:   e.policy.confidence(match),
+					RecordID:     match.ID,
+					MatchedAlias: match.MatchedAlias,
+				}
+				log.Info("Found blacklist record by phonetic match",
+					zap.String("name", req.Name),
+					zap.Time("birth_date", req.BirthDate),
+					zap.String("match_type", result.MatchType))
+			}
+		}
+
+		// A match cleared by the whitelist is reported as such for the audit trail
+		if !result.Matched && suppressedBy != 0 {
+			result = ListResult{
+				MatchType:    MatchSuppressed,
+				SuppressedBy: suppressedBy,
+			}
+			log.Info("Blacklist match suppressed by whitelist",
+				zap.String("name", req.Name),
+				zap.Int64("whitelist_entry", suppressedBy),
+				zap.String("match_type", result.MatchType))
+		}
+
+		// A close record the rules couldn't compare for want of the
+		// subject's birth date or place leaves the list undecided rather
+		// than clear
+		if !result.Matched && result.MatchType != MatchSuppressed {
+			for _, record := range records {
+				if _, suppressed := e.suppressions[record.ID]; !suppressed && e.policy.undecided(req, record) {
+					result = ListResult{
+						MatchType:     MatchUnknown,
+						UnknownReason: UnknownInsufficientData,
+						Details:       "A record with a similar name couldn't be ruled out without the subject's birth date or birth place",
+					}
+					log.Info("Blacklist record undecided for want of data",
+						zap.String("name", req.Name),
+						zap.Int64("record_id", record.ID),
+						zap.String("match_type", result.MatchType))
+					break
+				}
 			}
 		}
 
 		// If still no match found
-		if !result.Blacklisted {
-			result = CheckResult{
-				Blacklisted: false,
-				MatchType:   "no_match",
+		if !result.Matched && result.MatchType != MatchSuppressed && result.MatchType != MatchUnknown {
+			result = ListResult{
+				Matched:   false,
+				MatchType: MatchNone,
 			}
-			s.log.Info("No blacklist record found",
+			log.Info("No blacklist record found",
 				zap.String("name", req.Name),
 				zap.String("match_type", result.MatchType))
 		}
 	}
 
-	// Cache the result
-	resultJSON, err := json.Marshal(result)
-	if err != nil {
-		s.log.Error("Error marshaling result for cache",
-			zap.Error(err))
-	} else {
-		err = s.redis.Set(ctx, cacheKey, resultJSON, 24*time.Hour).Err()
-		if err != nil {
-			s.log.Error("Error caching result",
-				zap.Error(err))
+	if req.Explain && result.Matched {
+		for _, record := range candidates {
+			if record.ID == result.RecordID {
+				result.Explanation = e.policy.explain(req, result.MatchType, record, s.gazetteer)
+				break
+			}
 		}
 	}
 
+	s.score(req, &result, candidates, e.suppressions)
+	result.List = list
+	result.candidatesTruncated = candidatesTruncated
 	return &result, nil
-} 
\ No newline at end of file
+}
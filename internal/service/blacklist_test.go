@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+// newService creates a service over the fake store and cache
+func newService(t *testing.T, records *testutil.BlacklistStore, c *testutil.Cache) *service.BlacklistService {
+	t.Helper()
+	cfg := testutil.Config(t)
+	cfg.Match.Lists = "internal"
+	s, err := service.NewBlacklistService(service.Params{Config: cfg, Cache: c, Store: records, Log: zap.NewNop()})
+	if err != nil {
+		t.Fatalf("NewBlacklistService() error = %v", err)
+	}
+	return s
+}
+
+func TestCheckBlacklist(t *testing.T) {
+	listed := &store.BlacklistRecord{ID: 1, List: "internal", NIK: "3171011505900001", Name: "John Doe", Reason: "fraud"}
+	tests := []struct {
+		name            string
+		req             service.CheckRequest
+		byNIK           *store.BlacklistRecord
+		byName          []*store.BlacklistRecord
+		wantBlacklisted bool
+		wantMatch       string
+	}{
+		{
+			name:            "listed NIK",
+			req:             service.CheckRequest{Name: "Someone Else", NIK: listed.NIK},
+			byNIK:           listed,
+			wantBlacklisted: true,
+			wantMatch:       service.MatchExactNIK,
+		},
+		{
+			name:      "unlisted NIK and name",
+			req:       service.CheckRequest{Name: "Jane Roe", NIK: "3171011505900002"},
+			wantMatch: service.MatchNone,
+		},
+		{
+			name:      "unlisted name only",
+			req:       service.CheckRequest{Name: "Jane Roe"},
+			wantMatch: service.MatchNone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookups atomic.Int32
+			records := &testutil.BlacklistStore{
+				GetByNIKFunc: func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+					lookups.Add(1)
+					if tt.byNIK != nil && nik == tt.byNIK.NIK {
+						return tt.byNIK, nil
+					}
+					return nil, nil
+				},
+				GetByFuzzyMatchFunc: func(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*store.BlacklistRecord, bool, error) {
+					lookups.Add(1)
+					return tt.byName, false, nil
+				},
+				GetByPhoneticFunc: func(ctx context.Context, list, name string, birthDate *time.Time) ([]*store.BlacklistRecord, error) {
+					return nil, nil
+				},
+			}
+			s := newService(t, records, testutil.NewCache())
+
+			result, err := s.CheckBlacklist(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("CheckBlacklist() error = %v", err)
+			}
+			if result.Blacklisted != tt.wantBlacklisted || result.MatchType != tt.wantMatch {
+				t.Errorf("blacklisted = %t, match = %q, want %t, %q",
+					result.Blacklisted, result.MatchType, tt.wantBlacklisted, tt.wantMatch)
+			}
+
+			// The repeated check is answered from the cache
+			looked := lookups.Load()
+			cached, err := s.CheckBlacklist(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("repeated CheckBlacklist() error = %v", err)
+			}
+			if lookups.Load() != looked {
+				t.Error("repeated check looked up the store again")
+			}
+			if cached.Blacklisted != result.Blacklisted || cached.MatchType != result.MatchType {
+				t.Errorf("cached result = %t, %q, want %t, %q", cached.Blacklisted, cached.MatchType, result.Blacklisted, result.MatchType)
+			}
+		})
+	}
+}
+
+func TestCheckBlacklistConcurrentLookups(t *testing.T) {
+	listed := &store.BlacklistRecord{ID: 1, List: "internal", NIK: "3171011505900001", Name: "John Doe", Reason: "fraud"}
+	errFuzzy := errors.New("fuzzy search failed")
+	errNIK := errors.New("NIK lookup failed")
+	tests := []struct {
+		name            string
+		nik             *store.BlacklistRecord
+		nikErr          error
+		fuzzyErr        error
+		wantErr         error
+		wantBlacklisted bool
+	}{
+		{name: "fuzzy failing first doesn't abort a deciding NIK", nik: listed, fuzzyErr: errFuzzy, wantBlacklisted: true},
+		{name: "fuzzy error fails a check the NIK didn't decide", fuzzyErr: errFuzzy, wantErr: errFuzzy},
+		{name: "NIK error fails the check", nikErr: errNIK, wantErr: errNIK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := &testutil.BlacklistStore{
+				GetByNIKFunc: func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+					// Answer after the fuzzy search has failed
+					select {
+					case <-time.After(20 * time.Millisecond):
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					}
+					return tt.nik, tt.nikErr
+				},
+				GetByFuzzyMatchFunc: func(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*store.BlacklistRecord, bool, error) {
+					if tt.fuzzyErr != nil {
+						return nil, false, tt.fuzzyErr
+					}
+					<-ctx.Done()
+					return nil, false, ctx.Err()
+				},
+				GetByPhoneticFunc: func(ctx context.Context, list, name string, birthDate *time.Time) ([]*store.BlacklistRecord, error) {
+					return nil, nil
+				},
+			}
+			s := newService(t, records, testutil.NewCache())
+
+			result, err := s.CheckBlacklist(context.Background(), service.CheckRequest{Name: "John Doe", NIK: listed.NIK})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CheckBlacklist() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckBlacklist() error = %v", err)
+			}
+			if result.Blacklisted != tt.wantBlacklisted {
+				t.Errorf("blacklisted = %t, want %t", result.Blacklisted, tt.wantBlacklisted)
+			}
+		})
+	}
+}
+
+func TestWhitelistAndPinsUnavailable(t *testing.T) {
+	// The fake store stands in for a driver with no whitelist or pin tables
+	s := newService(t, &testutil.BlacklistStore{}, &testutil.Cache{})
+	ctx := context.Background()
+	if _, err := s.ListWhitelist(ctx, false); !errors.Is(err, service.ErrWhitelistUnavailable) {
+		t.Errorf("ListWhitelist() error = %v, want %v", err, service.ErrWhitelistUnavailable)
+	}
+	if _, err := s.PinRecord(ctx, "internal", "3171011505900001", "CASE-1", ""); !errors.Is(err, service.ErrPinsUnavailable) {
+		t.Errorf("PinRecord() error = %v, want %v", err, service.ErrPinsUnavailable)
+	}
+}
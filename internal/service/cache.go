@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/cache"
+	"blacklist-check/internal/cacheinsight"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// NameVersionKey holds the namespace version for fuzzy-match cache keys.
+// Bumping it orphans every cached name lookup at once, since a single record
+// change can affect any number of fuzzy results.
+const NameVersionKey = "blacklist:name:version"
+
+// changesChannel carries record-change events between replicas as a
+// comma-separated list of NIKs; an empty message means "everything changed"
+const changesChannel = "blacklist:changes"
+
+// cacheSchema versions the cached ListResult payload. Bump it whenever a
+// change to ListResult would make results cached by the previous release
+// deserialize wrongly; the old keys are then simply never read again and
+// expire on their TTL.
+const cacheSchema = 4
+
+// nikCacheKey returns the cache key for an exact NIK lookup on a list of a tenant
+func nikCacheKey(tenant, list, nik string) string {
+	return fmt.Sprintf("blacklist:s%d:nik:%s:%s", cacheSchema, cacheScope(tenant, list), nik)
+}
+
+// nameCacheKey returns the cache key for a fuzzy lookup on a list of a tenant
+// under the given namespace version. The matching profile and request fields
+// are hashed so keys have a fixed length however long the submitted name is.
+func nameCacheKey(version int64, tenant, list, profile, name, birthPlace string, birthDate time.Time) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		profile,
+		name,
+		birthPlace,
+		birthDate.Format("2006-01-02"),
+	}, "\x00")))
+	return fmt.Sprintf("blacklist:s%d:name:v%d:%s:%s",
+		cacheSchema,
+		version,
+		cacheScope(tenant, list),
+		hex.EncodeToString(sum[:]))
+}
+
+// cacheScope names a list of a tenant in cache keys. The default tenant's
+// lists keep their own names, so its keys are those of a single tenant.
+func cacheScope(tenant, list string) string {
+	if tenant == store.DefaultTenant {
+		return list
+	}
+	return tenant + "/" + list
+}
+
+// nikTenant returns the tenant whose cached exact-match results on list a
+// check in ctx reads: the owner of the records, as exact matches don't
+// depend on thresholds
+func (s *BlacklistService) nikTenant(ctx context.Context, list string) string {
+	return s.tenancy.Owner(ctx, list)
+}
+
+// nameTenant returns the tenant whose cached fuzzy results on list a check in
+// ctx reads: the caller's own when it has thresholds of its own, else the
+// owner of the records
+func (s *BlacklistService) nameTenant(ctx context.Context, list string, settings *tunables) string {
+	if tenant := store.Tenant(ctx); tenant != store.DefaultTenant {
+		if _, ok := settings.tenantPolicies[tenant]; ok {
+			return tenant
+		}
+	}
+	return s.tenancy.Owner(ctx, list)
+}
+
+// CacheNamespace is a group of cached lookups matched by a key pattern
+type CacheNamespace struct {
+	Name    string
+	Pattern string
+}
+
+// CacheNamespaces returns the namespaces of cached lookups worth copying to
+// another Redis instance. Only keys of the current schema are matched, and
+// for names only those of the given namespace version, since no other keys
+// are ever read again.
+func CacheNamespaces(nameVersion int64) []CacheNamespace {
+	return []CacheNamespace{
+		{Name: "nik", Pattern: fmt.Sprintf("blacklist:s%d:nik:*", cacheSchema)},
+		{Name: "name", Pattern: fmt.Sprintf("blacklist:s%d:name:v%d:*", cacheSchema, nameVersion)},
+	}
+}
+
+// nameVersion returns the current fuzzy-match namespace version
+func (s *BlacklistService) nameVersion(ctx context.Context) int64 {
+	version, err := s.cache.Counter(ctx, NameVersionKey)
+	if err != nil {
+		s.logger(ctx).Error("Error reading cache namespace version", zap.Error(err))
+	}
+	return version
+}
+
+// ListVersion returns the cache namespace version, which every record change
+// bumps, so instances reporting the same version see the same lists
+func (s *BlacklistService) ListVersion(ctx context.Context) int64 {
+	return s.nameVersion(ctx)
+}
+
+// InvalidateRecords drops cached results affected by changes to the given
+// NIKs on the lists of the tenant in ctx. Other replicas drop the NIKs of
+// every tenant from their local caches.
+func (s *BlacklistService) InvalidateRecords(ctx context.Context, niks ...string) error {
+	batch := cache.Batch{
+		Incr:    []string{NameVersionKey},
+		Publish: []cache.Message{{Channel: changesChannel, Payload: strings.Join(niks, ",")}},
+	}
+	for _, nik := range niks {
+		if nik == "" {
+			continue
+		}
+		for _, list := range lists.All {
+			batch.Delete = append(batch.Delete, nikCacheKey(s.nikTenant(ctx, list), list, nik))
+		}
+	}
+	if _, err := s.cache.Apply(ctx, batch); err != nil {
+		return fmt.Errorf("error invalidating cache: %w", err)
+	}
+
+	s.logger(ctx).Info("Invalidated blacklist cache", zap.Int("niks", len(niks)))
+	return nil
+}
+
+// SubscribeChanges calls fn with the NIKs of every record change published by
+// any replica until ctx is cancelled. An empty slice means all records may
+// have changed. Events published while the connection is down are lost, so
+// consumers must bound staleness themselves.
+func (s *BlacklistService) SubscribeChanges(ctx context.Context, fn func(niks ...string)) {
+	s.cache.Subscribe(ctx, changesChannel, func(payload string) {
+		var niks []string
+		if payload != "" {
+			niks = strings.Split(payload, ",")
+		}
+		fn(niks...)
+	})
+}
+
+// recordLookup counts a cached result lookup of the subject of req on a list
+// towards the cache insights. Subjects are told apart like in decision
+// events, so one looked up under several matching profiles counts once.
+func (s *BlacklistService) recordLookup(list string, req CheckRequest, hit bool) {
+	if s.insights == nil {
+		return
+	}
+	s.insights.Record(cacheinsight.TierRedis, list+":"+subjectHash(req), hit)
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/tokenize"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrCasesDisabled is returned when review cases are not enabled
+	ErrCasesDisabled = errors.New("review cases are not enabled")
+	// ErrCaseStatus is returned when updating a case to an unknown status
+	ErrCaseStatus = errors.New("status must be open, cleared or confirmed")
+	// ErrCaseAssignee is returned when an assignee is too long to store
+	ErrCaseAssignee = errors.New("assignee must be at most 255 characters")
+)
+
+// maxAssigneeLength is the longest assignee the cases table holds
+const maxAssigneeLength = 255
+
+// openCase opens a review case for a production check that blacklisted the
+// subject, or adds the hit to the subject's open case for the same record,
+// and reports the case on the result. A failure is logged rather than
+// failing the check.
+func (s *BlacklistService) openCase(ctx context.Context, req CheckRequest, result *CheckResult) {
+	if s.cases == nil || !result.Blacklisted {
+		return
+	}
+	// The case is of the match that blacklisted the subject
+	var match *ListResult
+	for i := range result.Lists {
+		if r := &result.Lists[i]; r.Matched && lists.Blocking(r.List) {
+			match = r
+			break
+		}
+	}
+	if match == nil {
+		return
+	}
+
+	c := &store.ReviewCase{
+		SubjectHash: subjectHash(req),
+		Name:        req.Name,
+		NIK:         req.NIK,
+		BirthPlace:  req.BirthPlace,
+		List:        match.List,
+		RecordID:    match.RecordID,
+		MatchType:   match.MatchType,
+		Score:       match.Score,
+		Confidence:  match.Confidence,
+		RequestID:   middleware.GetReqID(ctx),
+	}
+	if req.HasBirthDate() {
+		c.BirthDate = &req.BirthDate
+	}
+	if s.tokenizer.Enabled() {
+		tokens, err := s.tokenizer.Tokenize(ctx, map[string]string{
+			tokenize.FieldNIK:  c.NIK,
+			tokenize.FieldName: c.Name,
+		})
+		if err != nil {
+			s.logger(ctx).Error("Error tokenizing review case", zap.Error(err))
+			return
+		}
+		c.NIK = tokens[tokenize.FieldNIK]
+		c.Name = tokens[tokenize.FieldName]
+		c.Tokenized = true
+	}
+	if err := s.cases.Open(ctx, c); err != nil {
+		s.logger(ctx).Error("Error opening review case", zap.Error(err))
+		return
+	}
+	result.CaseID = c.ID
+}
+
+// ListCases returns the caller's review cases matching filter, oldest first,
+// with tokenized values restored
+func (s *BlacklistService) ListCases(ctx context.Context, filter store.CaseFilter) ([]*store.ReviewCase, error) {
+	if s.cases == nil {
+		return nil, ErrCasesDisabled
+	}
+	cases, err := s.cases.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error listing review cases: %w", err)
+	}
+	for _, c := range cases {
+		if err := s.detokenizeCase(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+	return cases, nil
+}
+
+// GetCase returns a review case with tokenized values restored, or
+// store.ErrCaseNotFound
+func (s *BlacklistService) GetCase(ctx context.Context, id int64) (*store.ReviewCase, error) {
+	if s.cases == nil {
+		return nil, ErrCasesDisabled
+	}
+	c, err := s.cases.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.detokenizeCase(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UpdateCase changes the status, assignee or notes of a review case as the
+// actor in ctx
+func (s *BlacklistService) UpdateCase(ctx context.Context, id int64, update store.CaseUpdate) (*store.ReviewCase, error) {
+	if s.cases == nil {
+		return nil, ErrCasesDisabled
+	}
+	if update.Status != nil {
+		switch *update.Status {
+		case store.CaseOpen, store.CaseCleared, store.CaseConfirmed:
+		default:
+			return nil, ErrCaseStatus
+		}
+	}
+	if update.Assignee != nil && len(*update.Assignee) > maxAssigneeLength {
+		return nil, ErrCaseAssignee
+	}
+
+	c, err := s.cases.Update(ctx, id, update)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.detokenizeCase(ctx, c); err != nil {
+		return nil, err
+	}
+	s.logger(ctx).Info("Updated review case",
+		zap.Int64("case_id", id),
+		zap.String("status", c.Status),
+		zap.String("updated_by", store.Actor(ctx)))
+	return c, nil
+}
+
+// detokenizeCase restores the NIK and name of a case whose subject was
+// stored as tokens
+func (s *BlacklistService) detokenizeCase(ctx context.Context, c *store.ReviewCase) error {
+	if !c.Tokenized {
+		return nil
+	}
+	if !s.tokenizer.Enabled() {
+		return fmt.Errorf("review case %d was tokenized but tokenization is disabled", c.ID)
+	}
+
+	values, err := s.tokenizer.Detokenize(ctx, map[string]string{
+		tokenize.FieldNIK:  c.NIK,
+		tokenize.FieldName: c.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("error detokenizing review case %d: %w", c.ID, err)
+	}
+	c.NIK = values[tokenize.FieldNIK]
+	c.Name = values[tokenize.FieldName]
+	c.Tokenized = false
+	return nil
+}
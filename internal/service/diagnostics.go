@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"blacklist-check/internal/gazetteer"
+	"blacklist-check/internal/phonetic"
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/usage"
+
+	"go.uber.org/zap"
+)
+
+// nearMissLimit bounds how many candidates are reported per list
+const nearMissLimit = 5
+
+// Constraints a near miss can fail
+const (
+	ExclusionBelowThreshold = "below_threshold"
+	ExclusionBirthDate      = "birth_date_mismatch"
+	ExclusionBirthPlace     = "birth_place_mismatch"
+	ExclusionPhonetic       = "phonetic_mismatch"
+	// ExclusionNameOnly is a record without birth data whose name isn't
+	// close enough to match on the name alone
+	ExclusionNameOnly = "below_name_only_threshold"
+)
+
+// NearMiss is a record that came close to matching a check, with the
+// constraints of the enabled rules it failed
+type NearMiss struct {
+	Record     *store.BlacklistRecord
+	Similarity float64
+	Excluded   []string
+}
+
+// diagnose explains a no-match on each list by attaching its most similar
+// records. Matched lists are left alone.
+func (s *BlacklistService) diagnose(ctx context.Context, req CheckRequest, result *CheckResult, policy MatchPolicy, cost usage.Cost) error {
+	for i := range result.Lists {
+		r := &result.Lists[i]
+		r.Truncated = r.candidatesTruncated
+		if r.Matched {
+			continue
+		}
+
+		cost.Add(usage.FuzzyQuery)
+		queryCtx, cancel := s.query(ctx)
+		records, err := s.store.NearestByName(queryCtx, r.List, req.Name, nearMissLimit)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("error finding near misses: %w", s.dependencyError(ctx, DependencyPostgres, err))
+		}
+		for _, record := range records {
+			r.NearMisses = append(r.NearMisses, NearMiss{
+				Record:     record,
+				Similarity: record.Similarity,
+				Excluded:   policy.exclusions(req, record, s.gazetteer),
+			})
+		}
+	}
+
+	s.logger(ctx).Info("Reported near misses for blacklist check",
+		zap.String("name", req.Name))
+	return nil
+}
+
+// exclusions lists the constraints record fails under the name-based rules
+// the policy enables, telling birth places apart with places
+func (p MatchPolicy) exclusions(req CheckRequest, record *store.BlacklistRecord, places *gazetteer.Gazetteer) []string {
+	var excluded []string
+	if p.fuzzy() && record.Similarity <= p.MinSimilarity {
+		excluded = append(excluded, ExclusionBelowThreshold)
+	}
+	if !p.bornOn(record, req.BirthDate) {
+		excluded = append(excluded, ExclusionBirthDate)
+	}
+	if p.enabled(MatchFuzzyFull) && (!req.HasBirthPlace() || !places.Same(record.BirthPlace, req.BirthPlace)) {
+		excluded = append(excluded, ExclusionBirthPlace)
+	}
+	if record.BirthDate == nil && record.BirthPlace == "" && p.enabled(MatchFuzzyName) &&
+		record.Similarity < p.NameOnlySimilarity {
+		excluded = append(excluded, ExclusionNameOnly)
+	}
+	if p.enabled(MatchPhonetic) && record.NamePhonetic != phonetic.Encode(req.Name) {
+		excluded = append(excluded, ExclusionPhonetic)
+	}
+	return excluded
+}
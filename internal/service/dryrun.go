@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// candidateLimit bounds how many candidates a dry run reports per list
+const candidateLimit = 20
+
+// Candidate is a record a dry run rated against the checked subject
+type Candidate struct {
+	List   string
+	Record *store.BlacklistRecord
+	// Similarity is the trigram similarity of the names, zero for a record
+	// found by NIK alone
+	Similarity float64
+	Score      float64
+	Decision   string
+	Factors    []ScoreFactor
+	// Matched is set on the record the list matched under the policy tried
+	Matched bool
+	// Excluded lists the constraints of the enabled rules the record fails
+	Excluded []string
+}
+
+// DryRun is a check evaluated under a policy tried for one request, with
+// every candidate considered
+type DryRun struct {
+	Result     *CheckResult
+	Candidates []Candidate
+}
+
+// DryRun evaluates req under policy and rates the records closest to the
+// subject on each list, whether or not they matched. Like Simulate it
+// bypasses the cache and whitelist, and nothing is metered, counted,
+// published or kept in check history, so it is never taken for a decision.
+func (s *BlacklistService) DryRun(ctx context.Context, req CheckRequest, policy MatchPolicy) (*DryRun, error) {
+	req = req.withOptionalFields()
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	profile, err := s.profiles.resolve(ctx, req.Profile)
+	if err != nil {
+		return nil, err
+	}
+	req.Profile = profile.Name
+
+	result, err := s.evaluateLists(ctx, req, evaluation{policy: policy, log: zap.NewNop()})
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating policy: %w", err)
+	}
+
+	run := &DryRun{Result: result}
+	for _, r := range result.Lists {
+		records, err := s.candidates(ctx, req, r.List)
+		if err != nil {
+			return nil, err
+		}
+		var candidates []Candidate
+		for _, record := range records {
+			score, factors := s.scorer.Score(req, record, s.gazetteer)
+			candidates = append(candidates, Candidate{
+				List:       r.List,
+				Record:     record,
+				Similarity: record.Similarity,
+				Score:      score,
+				Decision:   s.scorer.Decision(score),
+				Factors:    factors,
+				Matched:    r.Matched && record.ID == r.RecordID,
+				Excluded:   policy.exclusions(req, record, s.gazetteer),
+			})
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].Score != candidates[j].Score {
+				return candidates[i].Score > candidates[j].Score
+			}
+			return candidates[i].Similarity > candidates[j].Similarity
+		})
+		run.Candidates = append(run.Candidates, candidates...)
+	}
+	return run, nil
+}
+
+// candidates returns the record with the subject's NIK and the records with
+// the most similar names under each name variant, regardless of threshold or
+// birth data
+func (s *BlacklistService) candidates(ctx context.Context, req CheckRequest, list string) ([]*store.BlacklistRecord, error) {
+	seen := make(map[int64]bool)
+	var records []*store.BlacklistRecord
+	for _, name := range req.variants() {
+		found, err := s.store.NearestByName(ctx, list, name, candidateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("error finding candidates: %w", err)
+		}
+		for _, record := range found {
+			if !seen[record.ID] {
+				seen[record.ID] = true
+				records = append(records, record)
+			}
+		}
+	}
+	if req.NIK != "" {
+		record, err := s.store.GetByNIK(ctx, list, req.NIK)
+		if err != nil {
+			return nil, fmt.Errorf("error finding candidates: %w", err)
+		}
+		if record != nil && !seen[record.ID] {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"blacklist-check/internal/reqlog"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// EntityService handles company screening business logic
+type EntityService struct {
+	store store.EntityStore
+	log   *zap.Logger
+}
+
+// NewEntityService creates a new entity service
+func NewEntityService(store store.EntityStore, log *zap.Logger) *EntityService {
+	return &EntityService{
+		store: store,
+		log:   log,
+	}
+}
+
+// logger returns the logger scoped to the request in ctx
+func (s *EntityService) logger(ctx context.Context) *zap.Logger {
+	return reqlog.From(ctx, s.log)
+}
+
+// EntityCheckRequest represents a company screening request
+type EntityCheckRequest struct {
+	LegalName          string
+	RegistrationNumber string
+	Country            string
+}
+
+// CheckEntity checks if a company is blacklisted, first by registration
+// number and then by legal name with legal forms stripped
+func (s *EntityService) CheckEntity(ctx context.Context, req EntityCheckRequest) (*CheckResult, error) {
+	if req.RegistrationNumber != "" && req.Country != "" {
+		record, err := s.store.GetByRegistration(ctx, req.Country, req.RegistrationNumber)
+		if err != nil {
+			return nil, fmt.Errorf("error checking registration number: %w", err)
+		}
+		if record != nil {
+			s.logger(ctx).Info("Found entity blacklist record by registration number",
+				zap.Int64("entity_id", record.ID),
+				zap.String("match_type", "exact_registration"))
+			return &CheckResult{
+				Blacklisted: true,
+				Details:     record.Reason,
+				MatchType:   "exact_registration",
+			}, nil
+		}
+	}
+
+	var country *string
+	if req.Country != "" {
+		country = &req.Country
+	}
+	records, err := s.store.GetByFuzzyName(ctx, req.LegalName, country)
+	if err != nil {
+		return nil, fmt.Errorf("error searching entities by name: %w", err)
+	}
+	if len(records) > 0 {
+		s.logger(ctx).Info("Found entity blacklist record by fuzzy name match",
+			zap.String("legal_name", req.LegalName),
+			zap.Float64("similarity", records[0].Similarity),
+			zap.String("match_type", "fuzzy_name_match"))
+		return &CheckResult{
+			Blacklisted: true,
+			Details:     records[0].Reason,
+			MatchType:   "fuzzy_name_match",
+		}, nil
+	}
+
+	s.logger(ctx).Info("No entity blacklist record found",
+		zap.String("legal_name", req.LegalName),
+		zap.String("match_type", "no_match"))
+	return &CheckResult{
+		Blacklisted: false,
+		MatchType:   "no_match",
+	}, nil
+}
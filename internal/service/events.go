@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"blacklist-check/internal/events"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/usage"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// publishDecision emits a production decision to the event stream. Only a
+// hash of the subject leaves the service.
+func (s *BlacklistService) publishDecision(ctx context.Context, req CheckRequest, result *CheckResult, latency time.Duration) {
+	if s.events == nil {
+		return
+	}
+
+	event := &events.Screening{
+		RequestID:     middleware.GetReqID(ctx),
+		SubjectHash:   subjectHash(req),
+		Blacklisted:   result.Blacklisted,
+		Decision:      result.Decision,
+		Outcome:       result.Outcome(),
+		UnknownReason: result.UnknownReason,
+		MatchType:     result.MatchType,
+		Score:         result.Score,
+		Confidence:    result.Confidence,
+		Profile:       req.Profile,
+		PolicyVersion: result.PolicyVersion,
+		Caller:        usage.Caller(ctx),
+		LatencyMS:     float64(latency.Microseconds()) / 1000,
+		OccurredAt:    time.Now().UTC(),
+	}
+	for _, r := range result.Lists {
+		event.Lists = append(event.Lists, events.ListScreening{
+			List:          r.List,
+			Matched:       r.Matched,
+			MatchType:     r.MatchType,
+			Score:         r.Score,
+			Decision:      r.Decision,
+			Outcome:       r.Outcome(),
+			UnknownReason: r.UnknownReason,
+		})
+	}
+	s.events.Publish(ctx, event)
+}
+
+// subjectHash identifies a subject without revealing them: the SHA-256 of
+// their NIK, or without one a hash of their normalized name and birth date
+func subjectHash(req CheckRequest) string {
+	if req.NIK != "" {
+		return normalize.NIKHash(req.NIK)
+	}
+	subject := normalize.Name(req.Name)
+	if req.HasBirthDate() {
+		subject += "\x00" + req.BirthDate.Format("2006-01-02")
+	}
+	sum := sha256.Sum256([]byte(subject))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"blacklist-check/internal/gazetteer"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/phonetic"
+	"blacklist-check/internal/store"
+)
+
+// Comparisons an explanation reports between the subject and the matched record
+const (
+	CompareNIK            = "nik"
+	CompareNameSimilarity = "name_similarity"
+	CompareBirthDate      = "birth_date"
+	CompareBirthPlace     = "birth_place"
+	ComparePhonetic       = "phonetic_code"
+)
+
+// Explanation says why a list matched: the rule that matched the record and
+// how the subject compared against the record's fields
+type Explanation struct {
+	Rule     string
+	RecordID int64
+	Checks   []RuleCheck
+}
+
+// RuleCheck is one comparison of the subject against the matched record
+type RuleCheck struct {
+	Check string
+	// Required is set when the rule that matched depends on the comparison;
+	// the others are reported for context
+	Required bool
+	Passed   bool
+	// Subject and Record are the compared values, empty when absent
+	Subject string
+	Record  string
+	// SubjectPlace and RecordPlace are the places the gazetteer resolved
+	// compared birth places to, empty for places it doesn't know
+	SubjectPlace string
+	RecordPlace  string
+	// Value and Threshold are the similarity and the bound it was held to,
+	// for comparisons by similarity
+	Value     float64
+	Threshold float64
+	// ToleranceDays is how far apart birth dates may be and still agree
+	ToleranceDays int
+}
+
+// requiredChecks lists the comparisons each rule matches on
+var requiredChecks = map[string][]string{
+	MatchExactNIK:   {CompareNIK},
+	MatchFuzzyFull:  {CompareNameSimilarity, CompareBirthDate, CompareBirthPlace},
+	MatchFuzzyDate:  {CompareNameSimilarity, CompareBirthDate},
+	MatchFuzzyPlace: {CompareNameSimilarity, CompareBirthPlace},
+	MatchFuzzyName:  {CompareNameSimilarity},
+	MatchPhonetic:   {ComparePhonetic, CompareBirthDate},
+}
+
+// explain compares req against the record that matched by rule, under the
+// policy's thresholds, resolving birth places with places
+func (p MatchPolicy) explain(req CheckRequest, rule string, record *store.BlacklistRecord, places *gazetteer.Gazetteer) *Explanation {
+	required := make(map[string]bool)
+	for _, check := range requiredChecks[rule] {
+		required[check] = true
+	}
+	explanation := &Explanation{Rule: rule, RecordID: record.ID}
+	add := func(check RuleCheck) {
+		check.Required = required[check.Check]
+		explanation.Checks = append(explanation.Checks, check)
+	}
+
+	if req.NIK != "" || rule == MatchExactNIK {
+		add(RuleCheck{
+			Check:   CompareNIK,
+			Passed:  req.NIK != "" && record.NIK == req.NIK,
+			Subject: req.NIK,
+			Record:  record.NIK,
+		})
+	}
+
+	// Fuzzy rules compare the name the record was found under, which may be
+	// an alias; the similarity is recomputed for records found otherwise
+	name := record.Name
+	if record.MatchedAlias != "" {
+		name = record.MatchedAlias
+	}
+	similarity := record.Similarity
+	if similarity == 0 {
+		similarity = normalize.Similarity(req.Name, name)
+	}
+	nameCheck := RuleCheck{
+		Check:     CompareNameSimilarity,
+		Passed:    similarity > p.MinSimilarity,
+		Subject:   req.Name,
+		Record:    name,
+		Value:     similarity,
+		Threshold: p.MinSimilarity,
+	}
+	if rule == MatchFuzzyName {
+		nameCheck.Passed = similarity >= p.NameOnlySimilarity
+		nameCheck.Threshold = p.NameOnlySimilarity
+	}
+	add(nameCheck)
+
+	dateCheck := RuleCheck{
+		Check:         CompareBirthDate,
+		Passed:        p.bornOn(record, req.BirthDate),
+		ToleranceDays: p.BirthDateToleranceDays,
+	}
+	if req.HasBirthDate() {
+		dateCheck.Subject = req.BirthDate.Format("2006-01-02")
+	}
+	if record.BirthDate != nil {
+		dateCheck.Record = record.BirthDate.Format("2006-01-02")
+	}
+	add(dateCheck)
+
+	// Birth places agree when identical or names of the same place; the
+	// similarity of the places they resolve to shows how close differing
+	// ones came
+	placeCheck := RuleCheck{
+		Check:        CompareBirthPlace,
+		Passed:       req.HasBirthPlace() && places.Same(record.BirthPlace, req.BirthPlace),
+		Subject:      req.BirthPlace,
+		Record:       record.BirthPlace,
+		SubjectPlace: places.Canonical(req.BirthPlace),
+		RecordPlace:  places.Canonical(record.BirthPlace),
+	}
+	if req.HasBirthPlace() && record.BirthPlace != "" {
+		placeCheck.Value = normalize.Similarity(orPlace(placeCheck.SubjectPlace, req.BirthPlace), orPlace(placeCheck.RecordPlace, record.BirthPlace))
+	}
+	add(placeCheck)
+
+	// The phonetic rule matches when any variant of the subject's name
+	// sounds like the name the record was found under
+	if rule == MatchPhonetic {
+		recordCode := phonetic.Encode(name)
+		check := RuleCheck{Check: ComparePhonetic, Subject: phonetic.Encode(req.Name), Record: recordCode}
+		for _, variant := range req.variants() {
+			if code := phonetic.Encode(variant); code != "" && code == recordCode {
+				check.Passed = true
+				check.Subject = code
+				break
+			}
+		}
+		add(check)
+	}
+	return explanation
+}
+
+// orPlace returns the place a birth place resolved to, or the birth place
+// itself when it resolved to none
+func orPlace(place, birthPlace string) string {
+	if place == "" {
+		return birthPlace
+	}
+	return place
+}
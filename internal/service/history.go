@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/tokenize"
+
+	"go.uber.org/zap"
+)
+
+// recordCheck stores a production decision for later what-if analysis. A
+// failure is logged rather than failing the check. When tokenization is
+// enabled the NIK and name are stored as tokens, and a decision whose values
+// can't be tokenized isn't stored at all.
+func (s *BlacklistService) recordCheck(ctx context.Context, req CheckRequest, result *CheckResult) {
+	if s.history == nil {
+		return
+	}
+
+	entry := &store.CheckHistoryEntry{
+		Name:          req.Name,
+		NIK:           req.NIK,
+		BirthPlace:    req.BirthPlace,
+		Blacklisted:   result.Blacklisted,
+		MatchType:     result.MatchType,
+		Lists:         req.Lists,
+		PolicyVersion: result.PolicyVersion,
+	}
+	if req.HasBirthDate() {
+		entry.BirthDate = &req.BirthDate
+	}
+	if s.tokenizer.Enabled() {
+		tokens, err := s.tokenizer.Tokenize(ctx, map[string]string{
+			tokenize.FieldNIK:  entry.NIK,
+			tokenize.FieldName: entry.Name,
+		})
+		if err != nil {
+			s.logger(ctx).Error("Error tokenizing check history", zap.Error(err))
+			return
+		}
+		entry.NIK = tokens[tokenize.FieldNIK]
+		entry.Name = tokens[tokenize.FieldName]
+		entry.Tokenized = true
+	}
+	if err := s.history.Record(ctx, entry); err != nil {
+		s.logger(ctx).Error("Error recording check history", zap.Error(err))
+	}
+}
+
+// PruneHistory deletes recorded decisions older than the configured retention
+func (s *BlacklistService) PruneHistory(ctx context.Context) error {
+	if s.history == nil || s.historyRetention <= 0 {
+		return nil
+	}
+
+	deleted, err := s.history.Prune(ctx, time.Now().Add(-s.historyRetention))
+	if err != nil {
+		return fmt.Errorf("error pruning check history: %w", err)
+	}
+	if deleted > 0 {
+		s.logger(ctx).Info("Pruned check history", zap.Int64("deleted", deleted))
+	}
+	return nil
+}
+
+// RecentChecks returns the latest recorded decisions, newest first, with
+// tokenized values restored. It returns nothing when history is disabled.
+func (s *BlacklistService) RecentChecks(ctx context.Context, limit int) ([]*store.CheckHistoryEntry, error) {
+	if s.history == nil {
+		return nil, nil
+	}
+
+	entries, err := s.history.Recent(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error loading recent checks: %w", err)
+	}
+	for _, entry := range entries {
+		if err := s.Detokenize(ctx, entry); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Detokenize restores the NIK and name of a recorded decision that was
+// stored as tokens
+func (s *BlacklistService) Detokenize(ctx context.Context, entry *store.CheckHistoryEntry) error {
+	if !entry.Tokenized {
+		return nil
+	}
+	if !s.tokenizer.Enabled() {
+		return fmt.Errorf("check %d was tokenized but tokenization is disabled", entry.ID)
+	}
+
+	values, err := s.tokenizer.Detokenize(ctx, map[string]string{
+		tokenize.FieldNIK:  entry.NIK,
+		tokenize.FieldName: entry.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("error detokenizing check %d: %w", entry.ID, err)
+	}
+	entry.NIK = values[tokenize.FieldNIK]
+	entry.Name = values[tokenize.FieldName]
+	entry.Tokenized = false
+	return nil
+}
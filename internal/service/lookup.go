@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// errNIKMatched cancels a fuzzy search made moot by an exact NIK match
+var errNIKMatched = errors.New("NIK matched exactly")
+
+// Lookup paths, by which lookup decided a check screened both ways at once
+const (
+	LookupPathNIK   = "nik"
+	LookupPathFuzzy = "fuzzy"
+)
+
+// fuzzySearch is the outcome of the trigram searches for every variant of a name
+type fuzzySearch struct {
+	records []*store.BlacklistRecord
+	// queries is how many searches ran, for metering
+	queries int
+	// truncated is how many searches hit the candidate budget
+	truncated int
+}
+
+// lookupNIK returns the record on list with the subject's NIK, or nil
+func (s *BlacklistService) lookupNIK(ctx context.Context, req CheckRequest, list string, e evaluation) (*store.BlacklistRecord, error) {
+	queryCtx, cancel := s.query(ctx)
+	record, err := s.store.GetByNIK(queryCtx, list, req.NIK)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("error checking NIK: %w", s.dependencyError(ctx, DependencyPostgres, err))
+	}
+	if e.observe {
+		s.nikFilter.Confirm(record != nil)
+	}
+	return record, nil
+}
+
+// searchFuzzy returns the records on list with a name like a variant of the
+// subject's. Each variant is a separate search; a record found under several
+// variants is only returned once.
+func (s *BlacklistService) searchFuzzy(ctx context.Context, req CheckRequest, list string, e evaluation, log *zap.Logger) (*fuzzySearch, error) {
+	// Unknown fields are left out of the search rather than compared
+	// against their zero values
+	birthPlaces := s.placeNames(req.BirthPlace)
+	var birthDate *time.Time
+	if req.HasBirthDate() && e.policy.BirthDateToleranceDays == 0 {
+		birthDate = &req.BirthDate
+	}
+
+	search := &fuzzySearch{}
+	seen := make(map[int64]bool)
+	budget := e.policy.candidateBudget(req)
+	for _, name := range req.variants() {
+		search.queries++
+		queryCtx, cancel := s.query(ctx)
+		found, truncated, err := s.store.GetByFuzzyMatch(queryCtx, list, name, birthPlaces, birthDate, e.policy.MinSimilarity, budget, e.policy.fuzzyLimit())
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("error searching by fuzzy match: %w", s.dependencyError(ctx, DependencyPostgres, err))
+		}
+		found = e.policy.rerank(name, found)
+		if truncated {
+			search.truncated++
+			log.Info("Fuzzy match candidates truncated by budget",
+				zap.String("name", name),
+				zap.Int("budget", budget))
+		}
+		for _, record := range found {
+			if !seen[record.ID] {
+				seen[record.ID] = true
+				search.records = append(search.records, record)
+			}
+		}
+	}
+	return search, nil
+}
+
+// lookupConcurrently runs the NIK lookup and the fuzzy search at once, so a
+// check with both identifiers waits for the slower rather than for both. A
+// NIK matching a record the subject isn't cleared of decides the check, so
+// the fuzzy search is cancelled then and no search is returned. The fuzzy
+// search never cancels the NIK lookup: its error is only returned when the
+// NIK didn't decide the check.
+func (s *BlacklistService) lookupConcurrently(ctx context.Context, req CheckRequest, list string, e evaluation, log *zap.Logger) (*store.BlacklistRecord, *fuzzySearch, error) {
+	start := time.Now()
+	fuzzyCtx, cancelFuzzy := context.WithCancelCause(ctx)
+	defer cancelFuzzy(nil)
+
+	var g errgroup.Group
+	var record *store.BlacklistRecord
+	var search *fuzzySearch
+	var nikErr, fuzzyErr error
+	var nikTook, fuzzyTook time.Duration
+	g.Go(func() error {
+		defer func() { nikTook = time.Since(start) }()
+		record, nikErr = s.lookupNIK(ctx, req, list, e)
+		if nikErr != nil {
+			// Without the NIK the check fails whatever the search finds
+			cancelFuzzy(nikErr)
+		} else if s.nikDecides(record, e) {
+			cancelFuzzy(errNIKMatched)
+		}
+		return nil
+	})
+	g.Go(func() error {
+		defer func() { fuzzyTook = time.Since(start) }()
+		search, fuzzyErr = s.searchFuzzy(fuzzyCtx, req, list, e, log)
+		return nil
+	})
+	g.Wait()
+
+	switch {
+	case nikErr != nil:
+		return nil, nil, nikErr
+	case s.nikDecides(record, e):
+		search = nil
+	case fuzzyErr != nil:
+		return nil, nil, fuzzyErr
+	}
+
+	if e.observe {
+		// One after the other, the fuzzy search would only have run without
+		// a deciding NIK match
+		path, sequential := LookupPathNIK, nikTook
+		if search != nil {
+			path, sequential = LookupPathFuzzy, nikTook+fuzzyTook
+		}
+		saved := max(sequential-time.Since(start), 0)
+		metrics.LookupLatencySavedSeconds.WithLabelValues(path).Observe(saved.Seconds())
+	}
+	return record, search, nil
+}
+
+// nikDecides reports whether a NIK lookup found a record the subject isn't
+// cleared of, which decides the check without the fuzzy search
+func (s *BlacklistService) nikDecides(record *store.BlacklistRecord, e evaluation) bool {
+	if record == nil {
+		return false
+	}
+	_, cleared := e.suppressions[record.ID]
+	return !cleared
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+
+	"blacklist-check/internal/nationalid"
+)
+
+// How a submitted birth date is cross-checked against the national ID
+const (
+	BirthDateCheckOff    = "off"
+	BirthDateCheckFlag   = "flag"
+	BirthDateCheckReject = "reject"
+)
+
+// IDCheck is what a check learned from the structure of the submitted national ID
+type IDCheck struct {
+	*nationalid.ID
+	// BirthDateMismatch flags a birth date that disagrees with the ID
+	BirthDateMismatch bool
+}
+
+// validateBirthDateCheck checks a NIK_BIRTH_DATE_CHECK mode
+func validateBirthDateCheck(mode string) error {
+	switch mode {
+	case BirthDateCheckOff, BirthDateCheckFlag, BirthDateCheckReject:
+		return nil
+	}
+	return fmt.Errorf("unknown birth date check %q (known: %s, %s, %s)",
+		mode, BirthDateCheckOff, BirthDateCheckFlag, BirthDateCheckReject)
+}
+
+// CheckID validates the national ID of a request with the validator of its
+// country and cross-checks it against the birth date. It returns nil when the
+// request has no ID, a *nationalid.Error when the ID is invalid and
+// nationalid.ErrBirthDateMismatch when the birth date disagrees and
+// mismatches are rejected.
+func (s *BlacklistService) CheckID(req CheckRequest) (*IDCheck, error) {
+	if req.NIK == "" {
+		return nil, nil
+	}
+	id, err := nationalid.Parse(req.IDCountry, req.NIK)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &IDCheck{ID: id}
+	if !req.HasBirthDate() || s.birthDateCheck == BirthDateCheckOff || id.MatchesBirthDate(req.BirthDate) {
+		return check, nil
+	}
+	if s.birthDateCheck == BirthDateCheckReject {
+		return nil, nationalid.ErrBirthDateMismatch
+	}
+	check.BirthDateMismatch = true
+	return check, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrCaseID is returned when a pin or case closure names no case
+	ErrCaseID = errors.New("case_id is required and at most 255 characters")
+	// ErrPinsUnavailable is returned when records can't be pinned, as pins
+	// are kept in Postgres only
+	ErrPinsUnavailable = errors.New("record pins need DB_DRIVER=postgres")
+)
+
+// maxCaseIDLength is the longest case ID the pins table holds
+const maxCaseIDLength = 255
+
+// validCaseID reports whether caseID can name a case
+func validCaseID(caseID string) bool {
+	return caseID != "" && len(caseID) <= maxCaseIDLength
+}
+
+// PinRecord pins the record with the NIK on a list for an investigation
+// case, holding it in place until the pin is released
+func (s *BlacklistService) PinRecord(ctx context.Context, list, nik, caseID, reason string) (*store.Pin, error) {
+	if s.pins == nil {
+		return nil, ErrPinsUnavailable
+	}
+	if !validCaseID(caseID) {
+		return nil, ErrCaseID
+	}
+	pin, err := s.pins.Pin(ctx, list, nik, caseID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger(ctx).Info("Pinned blacklist record",
+		zap.Int64("pin_id", pin.ID),
+		zap.Int64("record_id", pin.RecordID),
+		zap.String("case_id", pin.CaseID),
+		zap.String("pinned_by", pin.PinnedBy))
+	return pin, nil
+}
+
+// UnpinRecord releases a pin before its case closes
+func (s *BlacklistService) UnpinRecord(ctx context.Context, id int64) (*store.Pin, error) {
+	if s.pins == nil {
+		return nil, ErrPinsUnavailable
+	}
+	pin, err := s.pins.Unpin(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger(ctx).Info("Unpinned blacklist record",
+		zap.Int64("pin_id", pin.ID),
+		zap.Int64("record_id", pin.RecordID),
+		zap.String("case_id", pin.CaseID),
+		zap.String("unpinned_by", store.Actor(ctx)))
+	return pin, nil
+}
+
+// ListPins returns the pins matching filter, newest first
+func (s *BlacklistService) ListPins(ctx context.Context, filter store.PinFilter) ([]*store.Pin, error) {
+	if s.pins == nil {
+		return nil, ErrPinsUnavailable
+	}
+	return s.pins.List(ctx, filter)
+}
+
+// CloseCase releases every pin of a closed case and returns them. Records
+// kept past their expiry expire on the next sweep, and syncs held back
+// apply on the next run.
+func (s *BlacklistService) CloseCase(ctx context.Context, caseID string) ([]*store.Pin, error) {
+	if s.pins == nil {
+		return nil, ErrPinsUnavailable
+	}
+	if !validCaseID(caseID) {
+		return nil, ErrCaseID
+	}
+	pins, err := s.pins.ReleaseCase(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger(ctx).Info("Closed case",
+		zap.String("case_id", caseID),
+		zap.Int("released_pins", len(pins)),
+		zap.String("closed_by", store.Actor(ctx)))
+	return pins, nil
+}
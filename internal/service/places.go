@@ -0,0 +1,18 @@
+package service
+
+// placeNames returns the birth places a fuzzy search holds records to: place
+// and the gazetteer's other names for it, so a record born in "Jakarta
+// Selatan" is a candidate for a subject born in "DKI Jakarta". Nil leaves
+// birth place out of the search.
+func (s *BlacklistService) placeNames(place string) []string {
+	if place == "" {
+		return nil
+	}
+	names := []string{place}
+	for _, name := range s.gazetteer.Names(place) {
+		if name != place {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// Match types, which double as the names of the rules producing them
+const (
+	MatchExactNIK  = "exact_nik"
+	MatchFuzzyFull = "fuzzy_full_match"
+	MatchFuzzyDate = "fuzzy_date_match"
+	// MatchFuzzyPlace and MatchFuzzyName match records that lack a birth
+	// date, by birth place or, lacking that too, by a closer name alone
+	MatchFuzzyPlace = "fuzzy_place_match"
+	MatchFuzzyName  = "fuzzy_name_match"
+	MatchPhonetic   = "phonetic_match"
+	MatchNone       = "no_match"
+	// MatchSuppressed is a match cleared by a whitelist entry
+	MatchSuppressed = "suppressed_by_whitelist"
+	// MatchUnknown is a list that could be neither matched nor cleared; its
+	// UnknownReason says why
+	MatchUnknown = "unknown"
+)
+
+// Outcomes of a check or a list: the subject is blacklisted, cleared, or
+// couldn't be decided either way
+const (
+	OutcomeHit     = "hit"
+	OutcomeClear   = "clear"
+	OutcomeUnknown = "unknown"
+)
+
+// Reasons a list was left unknown
+const (
+	// UnknownDeadline is a list not screened before the caller's deadline
+	UnknownDeadline = "deadline_exceeded"
+	// UnknownInsufficientData is a list with a record the enabled rules
+	// couldn't rule out because the check lacks a field they compare
+	UnknownInsufficientData = "insufficient_data"
+)
+
+// ErrInvalidPolicy is returned when a proposed match policy can't be evaluated
+var ErrInvalidPolicy = errors.New("invalid match policy")
+
+// MatchRules lists every rule a policy may enable, in evaluation order
+var MatchRules = []string{MatchExactNIK, MatchFuzzyFull, MatchFuzzyDate, MatchFuzzyPlace, MatchFuzzyName, MatchPhonetic}
+
+// MatchPolicy holds the tunable parts of individual matching
+type MatchPolicy struct {
+	// MinSimilarity is the trigram similarity a name must exceed to be a fuzzy candidate
+	MinSimilarity float64 `json:"min_similarity"`
+	// Rules are the enabled match rules; disabled rules are skipped
+	Rules []string `json:"rules"`
+	// NameOnlySimilarity is the stricter similarity fuzzy_name_match requires,
+	// as the name is all there is to compare
+	NameOnlySimilarity float64 `json:"name_only_similarity"`
+	// MissingBirthDatePenalty and MissingBirthPlacePenalty are taken off the
+	// confidence of a match on a record lacking that field
+	MissingBirthDatePenalty  float64 `json:"missing_birth_date_penalty"`
+	MissingBirthPlacePenalty float64 `json:"missing_birth_place_penalty"`
+	// BirthDateToleranceDays is how many days apart birth dates may be and
+	// still agree; 0 requires the same date
+	BirthDateToleranceDays int `json:"birth_date_tolerance_days"`
+	// CandidateBudget bounds the candidate names each fuzzy search
+	// considers, so a common name can't make a check arbitrarily expensive;
+	// 0 is unlimited
+	CandidateBudget int `json:"candidate_budget"`
+	// RerankCandidates is how many of the most similar records each fuzzy
+	// search fetches to re-rank by the weighted trigram and Jaro-Winkler
+	// similarities, keeping the best; 0 ranks by trigram similarity alone
+	RerankCandidates        int     `json:"rerank_candidates"`
+	RerankTrigramWeight     float64 `json:"rerank_trigram_weight"`
+	RerankJaroWinklerWeight float64 `json:"rerank_jaro_winkler_weight"`
+}
+
+func (p MatchPolicy) enabled(rule string) bool {
+	for _, r := range p.Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// candidateBudget returns the candidate budget of a check: the policy's,
+// unless the request asks for a smaller one
+func (p MatchPolicy) candidateBudget(req CheckRequest) int {
+	if req.CandidateBudget > 0 && (p.CandidateBudget == 0 || req.CandidateBudget < p.CandidateBudget) {
+		return req.CandidateBudget
+	}
+	return p.CandidateBudget
+}
+
+// maxRerankCandidates bounds RerankCandidates, as every candidate fetched is
+// rated again for each name variant
+const maxRerankCandidates = 100
+
+// fuzzyLimit returns how many records each fuzzy search fetches
+func (p MatchPolicy) fuzzyLimit() int {
+	if p.RerankCandidates > store.FuzzyMatchLimit {
+		return p.RerankCandidates
+	}
+	return store.FuzzyMatchLimit
+}
+
+// rerank orders the records a fuzzy search for name found by the weighted
+// trigram and Jaro-Winkler similarities of the names they matched on,
+// keeping as many as a search returns without re-ranking. Similarity stays
+// the trigram similarity the thresholds compare.
+func (p MatchPolicy) rerank(name string, records []*store.BlacklistRecord) []*store.BlacklistRecord {
+	if p.RerankCandidates == 0 {
+		return records
+	}
+	total := p.RerankTrigramWeight + p.RerankJaroWinklerWeight
+	scores := make(map[*store.BlacklistRecord]float64, len(records))
+	for _, record := range records {
+		matched := record.Name
+		if record.MatchedAlias != "" {
+			matched = record.MatchedAlias
+		}
+		scores[record] = (p.RerankTrigramWeight*record.Similarity +
+			p.RerankJaroWinklerWeight*normalize.JaroWinkler(matched, name)) / total
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return scores[records[i]] > scores[records[j]]
+	})
+	if len(records) > store.FuzzyMatchLimit {
+		records = records[:store.FuzzyMatchLimit]
+	}
+	return records
+}
+
+// fuzzy reports whether any rule needs fuzzy name candidates
+func (p MatchPolicy) fuzzy() bool {
+	return p.enabled(MatchFuzzyFull) || p.enabled(MatchFuzzyDate) ||
+		p.enabled(MatchFuzzyPlace) || p.enabled(MatchFuzzyName)
+}
+
+// undecided reports whether the rules could neither match nor clear a record
+// for want of a field in the check. Only records whose names are as close as
+// a name-only match needs count, so a partial check isn't left unknown by
+// every distant candidate.
+func (p MatchPolicy) undecided(req CheckRequest, record *store.BlacklistRecord) bool {
+	if record.Similarity < p.NameOnlySimilarity {
+		return false
+	}
+	if record.BirthDate != nil {
+		return !req.HasBirthDate() && (p.enabled(MatchFuzzyFull) || p.enabled(MatchFuzzyDate))
+	}
+	if record.BirthPlace != "" {
+		return !req.HasBirthPlace() && p.enabled(MatchFuzzyPlace)
+	}
+	// Records lacking both are decided by fuzzy_name_match on the name alone
+	return false
+}
+
+// maxBirthDateTolerance bounds BirthDateToleranceDays at about ten years
+const maxBirthDateTolerance = 3660
+
+// bornOn reports whether record has a birth date within the policy's
+// tolerance of date
+func (p MatchPolicy) bornOn(record *store.BlacklistRecord, date time.Time) bool {
+	if p.BirthDateToleranceDays == 0 {
+		return record.BornOn(date)
+	}
+	if record.BirthDate == nil || date.IsZero() {
+		return false
+	}
+	diff := record.BirthDate.Sub(date)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= time.Duration(p.BirthDateToleranceDays)*24*time.Hour
+}
+
+// confidence rates a match on record: 1, less the penalty for each of the
+// birth date and birth place the record lacks
+func (p MatchPolicy) confidence(record *store.BlacklistRecord) float64 {
+	c := 1.0
+	if record.BirthDate == nil {
+		c -= p.MissingBirthDatePenalty
+	}
+	if record.BirthPlace == "" {
+		c -= p.MissingBirthPlacePenalty
+	}
+	return c
+}
+
+// Validate checks that the policy can be evaluated
+func (p MatchPolicy) Validate() error {
+	if p.MinSimilarity <= 0 || p.MinSimilarity > 1 {
+		return fmt.Errorf("%w: min_similarity must be in (0, 1]", ErrInvalidPolicy)
+	}
+	if p.enabled(MatchFuzzyName) && (p.NameOnlySimilarity < p.MinSimilarity || p.NameOnlySimilarity > 1) {
+		return fmt.Errorf("%w: name_only_similarity must be in [min_similarity, 1]", ErrInvalidPolicy)
+	}
+	if p.MissingBirthDatePenalty < 0 || p.MissingBirthPlacePenalty < 0 ||
+		p.MissingBirthDatePenalty+p.MissingBirthPlacePenalty > 1 {
+		return fmt.Errorf("%w: missing field penalties must be non-negative and sum to at most 1", ErrInvalidPolicy)
+	}
+	if p.BirthDateToleranceDays < 0 || p.BirthDateToleranceDays > maxBirthDateTolerance {
+		return fmt.Errorf("%w: birth_date_tolerance_days must be in [0, %d]", ErrInvalidPolicy, maxBirthDateTolerance)
+	}
+	if p.CandidateBudget < 0 {
+		return fmt.Errorf("%w: candidate_budget must not be negative", ErrInvalidPolicy)
+	}
+	if p.RerankCandidates < 0 || p.RerankCandidates > maxRerankCandidates {
+		return fmt.Errorf("%w: rerank_candidates must be in [0, %d]", ErrInvalidPolicy, maxRerankCandidates)
+	}
+	if p.RerankCandidates > 0 && (p.RerankTrigramWeight < 0 || p.RerankJaroWinklerWeight < 0 ||
+		p.RerankTrigramWeight+p.RerankJaroWinklerWeight == 0) {
+		return fmt.Errorf("%w: rerank weights must be non-negative and not both zero", ErrInvalidPolicy)
+	}
+	for _, rule := range p.Rules {
+		known := false
+		for _, r := range MatchRules {
+			known = known || r == rule
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown rule %q (known: %s)", ErrInvalidPolicy, rule, strings.Join(MatchRules, ", "))
+		}
+	}
+	return nil
+}
+
+// Policy returns the match policy used for production checks
+func (s *BlacklistService) Policy() MatchPolicy {
+	return s.current().policy
+}
+
+// Simulation compares the outcome of a check under the current and a proposed policy
+type Simulation struct {
+	Current  *CheckResult
+	Proposed *CheckResult
+	Changed  bool
+}
+
+// Simulate evaluates req under both the current policy of the caller's tenant
+// and the proposed policy. It bypasses the cache, records no decision metrics
+// and doesn't log the individual match decisions, so previews can't be
+// mistaken for production screening.
+func (s *BlacklistService) Simulate(ctx context.Context, req CheckRequest, proposed MatchPolicy) (*Simulation, error) {
+	req = req.withOptionalFields()
+	if err := proposed.Validate(); err != nil {
+		return nil, err
+	}
+	profile, err := s.profiles.resolve(ctx, req.Profile)
+	if err != nil {
+		return nil, err
+	}
+	req.Profile = profile.Name
+
+	quiet := zap.NewNop()
+	current, err := s.evaluateLists(ctx, req, evaluation{policy: s.current().policyFor(store.Tenant(ctx)), log: quiet})
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating current policy: %w", err)
+	}
+	next, err := s.evaluateLists(ctx, req, evaluation{policy: proposed, log: quiet})
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating proposed policy: %w", err)
+	}
+
+	return &Simulation{
+		Current:  current,
+		Proposed: next,
+		Changed:  !sameOutcome(current, next),
+	}, nil
+}
+
+// sameOutcome reports whether two results reach the same decision on every list
+func sameOutcome(a, b *CheckResult) bool {
+	if a.Blacklisted != b.Blacklisted || a.MatchType != b.MatchType || len(a.Lists) != len(b.Lists) {
+		return false
+	}
+	for i := range a.Lists {
+		if a.Lists[i].Matched != b.Lists[i].Matched || a.Lists[i].MatchType != b.Lists[i].MatchType {
+			return false
+		}
+	}
+	return true
+}
+
+// splitList parses a comma-separated config value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/usage"
+)
+
+// matchProfiles selects the name matching profile for a check
+type matchProfiles struct {
+	fallback *normalize.Profile
+	// callers maps a caller to the profile its checks use
+	callers map[string]*normalize.Profile
+}
+
+// newMatchProfiles parses the default profile and the per-caller overrides,
+// given as comma-separated caller=profile pairs
+func newMatchProfiles(fallback, callers string) (matchProfiles, error) {
+	profile, err := normalize.LookupProfile(fallback)
+	if err != nil {
+		return matchProfiles{}, err
+	}
+	m := matchProfiles{fallback: profile, callers: make(map[string]*normalize.Profile)}
+	for _, entry := range splitList(callers) {
+		caller, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			return matchProfiles{}, fmt.Errorf("invalid caller profile %q, expected caller=profile", entry)
+		}
+		profile, err := normalize.LookupProfile(strings.TrimSpace(name))
+		if err != nil {
+			return matchProfiles{}, err
+		}
+		m.callers[strings.TrimSpace(caller)] = profile
+	}
+	return m, nil
+}
+
+// resolve returns the profile a check uses: the one the request names, else
+// the caller's, else the default
+func (m matchProfiles) resolve(ctx context.Context, requested string) (*normalize.Profile, error) {
+	if requested != "" {
+		return normalize.LookupProfile(requested)
+	}
+	if profile, ok := m.callers[usage.Caller(ctx)]; ok {
+		return profile, nil
+	}
+	return m.fallback, nil
+}
+
+// variants returns the names req is matched under, per its matching profile
+func (req CheckRequest) variants() []string {
+	return normalize.Profiles[req.Profile].Variants(req.Name)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"blacklist-check/internal/cache"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/normalize"
+
+	"go.uber.org/zap"
+)
+
+// Recache is the outcome of purging the cached results of some subjects
+type Recache struct {
+	// Purged is how many cache entries were dropped
+	Purged int64
+	// Results holds the fresh result of each subject when they were
+	// rechecked, in the order given; Errors the failure of any that weren't
+	Results []*CheckResult
+	Errors  []error
+}
+
+// Recache drops the cached results of specific subjects on every list of the
+// caller's tenant, so a correction to their data takes effect without
+// orphaning every other cached lookup the way a record change does. NIKs drop their exact-match entries;
+// subjects also drop the entries cached under their name, birth place and
+// birth date for every matching profile. With recheck each subject is then
+// screened again, which caches the fresh result and publishes its decision.
+func (s *BlacklistService) Recache(ctx context.Context, niks []string, subjects []CheckRequest, recheck bool) (*Recache, error) {
+	version := s.nameVersion(ctx)
+	settings := s.current()
+	all := append([]string(nil), niks...)
+	var keys []string
+	for _, req := range subjects {
+		req = req.withOptionalFields()
+		if idCheck, err := s.CheckID(req); err == nil && idCheck != nil {
+			req.NIK = idCheck.Number
+		}
+		if req.NIK != "" {
+			all = append(all, req.NIK)
+		}
+		for profile := range normalize.Profiles {
+			for _, list := range lists.All {
+				keys = append(keys, nameCacheKey(version, s.nameTenant(ctx, list, settings), list, profile, req.Name, req.BirthPlace, req.BirthDate))
+			}
+		}
+	}
+	for _, nik := range all {
+		for _, list := range lists.All {
+			keys = append(keys, nikCacheKey(s.nikTenant(ctx, list), list, nik))
+		}
+	}
+
+	recache := &Recache{}
+	if len(keys) > 0 {
+		batch := cache.Batch{Delete: keys}
+		// Replicas drop the NIKs from their local caches too; the namespace
+		// version is left alone so other subjects stay cached
+		if len(all) > 0 {
+			batch.Publish = []cache.Message{{Channel: changesChannel, Payload: strings.Join(all, ",")}}
+		}
+		purged, err := s.cache.Apply(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("error purging cache: %w", err)
+		}
+		recache.Purged = purged
+	}
+	s.logger(ctx).Info("Purged cached results of subjects",
+		zap.Int("niks", len(all)),
+		zap.Int("subjects", len(subjects)),
+		zap.Int64("purged", recache.Purged))
+
+	if !recheck {
+		return recache, nil
+	}
+	recache.Results = make([]*CheckResult, len(subjects))
+	recache.Errors = make([]error, len(subjects))
+	for i, req := range subjects {
+		recache.Results[i], recache.Errors[i] = s.CheckBlacklist(ctx, req)
+	}
+	return recache, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// CreateRecord adds a record to the blacklist and invalidates affected cache entries
+func (s *BlacklistService) CreateRecord(ctx context.Context, record *store.BlacklistRecord) error {
+	if err := s.store.Create(ctx, record); err != nil {
+		return fmt.Errorf("error creating record: %w", err)
+	}
+	s.invalidate(ctx, record.NIK)
+	return nil
+}
+
+// UpdateRecord modifies a blacklist record and invalidates affected cache entries
+func (s *BlacklistService) UpdateRecord(ctx context.Context, record *store.BlacklistRecord) error {
+	if err := s.store.Update(ctx, record); err != nil {
+		return fmt.Errorf("error updating record: %w", err)
+	}
+	s.invalidate(ctx, record.NIK)
+	return nil
+}
+
+// DeleteRecord removes a blacklist record and invalidates affected cache entries
+func (s *BlacklistService) DeleteRecord(ctx context.Context, list, nik string) error {
+	if err := s.store.Delete(ctx, list, nik); err != nil {
+		return fmt.Errorf("error deleting record: %w", err)
+	}
+	s.invalidate(ctx, nik)
+	return nil
+}
+
+// RestoreRecord reverses the deletion of a blacklist record and invalidates affected cache entries
+func (s *BlacklistService) RestoreRecord(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	record, err := s.store.Restore(ctx, list, nik)
+	if err != nil {
+		return nil, fmt.Errorf("error restoring record: %w", err)
+	}
+	s.invalidate(ctx, nik)
+	return record, nil
+}
+
+// RecordHistory returns every recorded change to a NIK, including its deletion
+func (s *BlacklistService) RecordHistory(ctx context.Context, nik string) ([]*store.RecordChange, error) {
+	changes, err := s.store.History(ctx, nik)
+	if err != nil {
+		return nil, fmt.Errorf("error loading record history: %w", err)
+	}
+	return changes, nil
+}
+
+// SearchRecords returns records of a list matching a NIK prefix or name
+func (s *BlacklistService) SearchRecords(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*store.BlacklistRecord, error) {
+	records, err := s.store.Search(ctx, list, query, includeDeleted, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching records: %w", err)
+	}
+	return records, nil
+}
+
+// ListRecords returns a page of records matching filter in sort order, with
+// the cursor of the next page
+func (s *BlacklistService) ListRecords(ctx context.Context, filter store.RecordFilter, sort, cursor string, limit int) ([]*store.BlacklistRecord, string, error) {
+	records, next, err := s.store.List(ctx, filter, sort, cursor, limit)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing records: %w", err)
+	}
+	return records, next, nil
+}
+
+// invalidate drops cache entries after a committed write. The write already
+// succeeded, so a cache failure is logged rather than returned.
+func (s *BlacklistService) invalidate(ctx context.Context, niks ...string) {
+	if err := s.InvalidateRecords(ctx, niks...); err != nil {
+		s.logger(ctx).Error("Error invalidating cache after record change",
+			zap.Strings("niks", niks),
+			zap.Error(err))
+	}
+}
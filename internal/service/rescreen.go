@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"blacklist-check/internal/store"
+	"blacklist-check/internal/tokenize"
+
+	"go.uber.org/zap"
+)
+
+// enrol records the subject of a production check for re-screening when the
+// request said whether the subject consents. Without consent only the
+// subject's hash and outcome are kept. A failure is logged rather than
+// failing the check.
+func (s *BlacklistService) enrol(ctx context.Context, req CheckRequest, result *CheckResult) {
+	if s.rescreen == nil || req.RescreenConsent == nil {
+		return
+	}
+
+	subject := &store.RescreenSubject{
+		SubjectHash: subjectHash(req),
+		Consent:     *req.RescreenConsent,
+		Outcome:     result.Outcome(),
+		MatchType:   result.MatchType,
+	}
+	if subject.Consent {
+		subject.Name = req.Name
+		subject.NIK = req.NIK
+		subject.IDCountry = req.IDCountry
+		subject.BirthPlace = req.BirthPlace
+		subject.Lists = req.Lists
+		subject.Profile = req.Profile
+		if req.HasBirthDate() {
+			subject.BirthDate = &req.BirthDate
+		}
+		if s.tokenizer.Enabled() {
+			tokens, err := s.tokenizer.Tokenize(ctx, map[string]string{
+				tokenize.FieldNIK:  subject.NIK,
+				tokenize.FieldName: subject.Name,
+			})
+			if err != nil {
+				s.logger(ctx).Error("Error tokenizing re-screening subject", zap.Error(err))
+				return
+			}
+			subject.NIK = tokens[tokenize.FieldNIK]
+			subject.Name = tokens[tokenize.FieldName]
+			subject.Tokenized = true
+		}
+	}
+	if err := s.rescreen.Enrol(ctx, subject); err != nil {
+		s.logger(ctx).Error("Error enrolling subject for re-screening", zap.Error(err))
+	}
+}
+
+// Rescreen screens an enrolled subject again, for its tenant, against the
+// current lists and whitelist. It bypasses the cache, so list updates are
+// seen at once, and isn't metered, kept in check history or published as a
+// screening decision.
+func (s *BlacklistService) Rescreen(ctx context.Context, subject *store.RescreenSubject) (*CheckResult, error) {
+	req := CheckRequest{
+		Name:       subject.Name,
+		NIK:        subject.NIK,
+		IDCountry:  subject.IDCountry,
+		BirthPlace: subject.BirthPlace,
+		Lists:      subject.Lists,
+		Profile:    subject.Profile,
+	}
+	if subject.BirthDate != nil {
+		req.BirthDate = *subject.BirthDate
+	}
+	if subject.Tokenized {
+		if !s.tokenizer.Enabled() {
+			return nil, fmt.Errorf("subject %d was tokenized but tokenization is disabled", subject.ID)
+		}
+		values, err := s.tokenizer.Detokenize(ctx, map[string]string{
+			tokenize.FieldNIK:  req.NIK,
+			tokenize.FieldName: req.Name,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error detokenizing subject %d: %w", subject.ID, err)
+		}
+		req.NIK = values[tokenize.FieldNIK]
+		req.Name = values[tokenize.FieldName]
+	}
+
+	ctx = store.WithTenant(ctx, subject.Tenant)
+	profile, err := s.profiles.resolve(ctx, req.Profile)
+	if err != nil {
+		return nil, err
+	}
+	req.Profile = profile.Name
+
+	checkCtx, cancel := s.budget(ctx)
+	defer cancel()
+	settings := s.current()
+	e := evaluation{policy: settings.policyFor(store.Tenant(ctx)), log: s.logger(ctx)}
+	if s.whitelist != nil {
+		if e.suppressions, err = s.suppressions(checkCtx, req); err != nil {
+			return nil, s.budgetError(ctx, checkCtx, err)
+		}
+	}
+	result, err := s.evaluateLists(checkCtx, req, e)
+	if err != nil {
+		return nil, s.budgetError(ctx, checkCtx, err)
+	}
+	result.PolicyVersion = settings.policyVersion
+	return result, nil
+}
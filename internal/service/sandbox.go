@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/usage"
+)
+
+// isSandbox reports whether the caller in ctx belongs to the sandbox tenant
+func (s *BlacklistService) isSandbox(ctx context.Context) bool {
+	if s.sandbox == nil {
+		return false
+	}
+	identity := auth.FromContext(ctx)
+	return identity != nil && identity.Tenant == s.sandboxTenant
+}
+
+// checkSandbox screens req against the synthetic records. Results are
+// neither cached, whitelisted, metered nor kept in check history, so each
+// sandbox check has the same outcome and leaves no trace in production data.
+func (s *BlacklistService) checkSandbox(ctx context.Context, req CheckRequest, idCheck *IDCheck) (*CheckResult, error) {
+	policy := s.current().policy
+	result, err := s.evaluateLists(ctx, req, evaluation{policy: policy, log: s.logger(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	if req.Diagnostics {
+		if err := s.diagnose(ctx, req, result, policy, usage.Cost{}); err != nil {
+			return nil, err
+		}
+	}
+	result.ID = idCheck
+	result.Sandbox = true
+	return result, nil
+}
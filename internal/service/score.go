@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"blacklist-check/internal/gazetteer"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/store"
+)
+
+// Decision bands a risk score falls into
+const (
+	DecisionClear  = "clear"
+	DecisionReview = "review"
+	DecisionHit    = "hit"
+	// DecisionUnknown is given when a blocking list wasn't screened before
+	// the caller's deadline, so its score is missing, and nothing else matched
+	DecisionUnknown = "unknown"
+)
+
+// Score factors, which double as the keys of the configured weights
+const (
+	FactorNIK        = "nik"
+	FactorName       = "name"
+	FactorBirthDate  = "birth_date"
+	FactorBirthPlace = "birth_place"
+)
+
+// DefaultScoreWeights are used for factors the configuration leaves out
+var DefaultScoreWeights = map[string]float64{
+	FactorNIK:        1,
+	FactorName:       0.5,
+	FactorBirthDate:  0.3,
+	FactorBirthPlace: 0.2,
+}
+
+// scoreFactors lists the factors in the order they are reported
+var scoreFactors = []string{FactorNIK, FactorName, FactorBirthDate, FactorBirthPlace}
+
+// ScoreFactor is one factor's part in a risk score
+type ScoreFactor struct {
+	Factor string
+	// Value is how well the factor agrees, from 0 to 1
+	Value  float64
+	Weight float64
+	// Contribution is Value times Weight
+	Contribution float64
+}
+
+// Scorer rates how likely a record is the checked subject. The score is the
+// weighted sum of the factors, capped at 1, and falls into a decision band by
+// the review and hit thresholds.
+type Scorer struct {
+	weights map[string]float64
+	review  float64
+	hit     float64
+}
+
+// NewScorer parses comma-separated factor=weight pairs over the default
+// weights and validates the band thresholds
+func NewScorer(spec string, review, hit float64) (*Scorer, error) {
+	weights := make(map[string]float64, len(DefaultScoreWeights))
+	for factor, weight := range DefaultScoreWeights {
+		weights[factor] = weight
+	}
+
+	for _, entry := range splitList(spec) {
+		factor, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid score weight %q, expected factor=weight", entry)
+		}
+		factor = strings.TrimSpace(factor)
+		if _, known := DefaultScoreWeights[factor]; !known {
+			return nil, fmt.Errorf("unknown factor %q in score weights", factor)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid score weight for %q: %s", factor, value)
+		}
+		weights[factor] = weight
+	}
+
+	if review <= 0 || hit > 1 || review >= hit {
+		return nil, fmt.Errorf("score thresholds must satisfy 0 < review < hit <= 1")
+	}
+	return &Scorer{weights: weights, review: review, hit: hit}, nil
+}
+
+// Score rates record against the subject of req. Birth places agree when
+// places knows them as names of one place.
+func (s *Scorer) Score(req CheckRequest, record *store.BlacklistRecord, places *gazetteer.Gazetteer) (float64, []ScoreFactor) {
+	values := map[string]float64{
+		FactorName: normalize.Similarity(req.Name, record.Name),
+	}
+	if req.NIK != "" && record.NIK == req.NIK {
+		values[FactorNIK] = 1
+	}
+	if record.BornOn(req.BirthDate) {
+		values[FactorBirthDate] = 1
+	}
+	if req.HasBirthPlace() && (normalize.Name(record.BirthPlace) == normalize.Name(req.BirthPlace) || places.Same(record.BirthPlace, req.BirthPlace)) {
+		values[FactorBirthPlace] = 1
+	}
+
+	var score float64
+	factors := make([]ScoreFactor, 0, len(scoreFactors))
+	for _, factor := range scoreFactors {
+		f := ScoreFactor{
+			Factor: factor,
+			Value:  values[factor],
+			Weight: s.weights[factor],
+		}
+		f.Contribution = f.Value * f.Weight
+		score += f.Contribution
+		factors = append(factors, f)
+	}
+	if score > 1 {
+		score = 1
+	}
+	return score, factors
+}
+
+// Decision returns the band score falls into
+func (s *Scorer) Decision(score float64) string {
+	switch {
+	case score >= s.hit:
+		return DecisionHit
+	case score >= s.review:
+		return DecisionReview
+	default:
+		return DecisionClear
+	}
+}
+
+// score rates the matched record, or when nothing matched the best candidate
+// the subject hasn't been whitelisted against, so that near misses can still
+// land in review
+func (s *BlacklistService) score(req CheckRequest, result *ListResult, candidates []*store.BlacklistRecord, suppressions map[int64]int64) {
+	for _, record := range candidates {
+		if _, cleared := suppressions[record.ID]; cleared {
+			continue
+		}
+		if result.Matched && record.ID != result.RecordID {
+			continue
+		}
+		score, factors := s.scorer.Score(req, record, s.gazetteer)
+		if result.Factors == nil || score > result.Score {
+			result.Score = score
+			result.Factors = factors
+		}
+	}
+	result.Decision = s.scorer.Decision(result.Score)
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"blacklist-check/internal/store"
+)
+
+// Service is what the HTTP and gRPC handlers need of the blacklist service.
+// BlacklistService implements it; handlers depend on the interface so they can
+// be tested against a fake.
+type Service interface {
+	// Checks
+	CheckBlacklist(ctx context.Context, req CheckRequest) (*CheckResult, error)
+	CheckID(req CheckRequest) (*IDCheck, error)
+	RecentChecks(ctx context.Context, limit int) ([]*store.CheckHistoryEntry, error)
+	Recache(ctx context.Context, niks []string, subjects []CheckRequest, recheck bool) (*Recache, error)
+	ListVersion(ctx context.Context) int64
+
+	// Policy
+	Policy() MatchPolicy
+	DryRun(ctx context.Context, req CheckRequest, policy MatchPolicy) (*DryRun, error)
+	Simulate(ctx context.Context, req CheckRequest, proposed MatchPolicy) (*Simulation, error)
+
+	// Records
+	ListRecords(ctx context.Context, filter store.RecordFilter, sort, cursor string, limit int) ([]*store.BlacklistRecord, string, error)
+	SearchRecords(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*store.BlacklistRecord, error)
+	RecordHistory(ctx context.Context, nik string) ([]*store.RecordChange, error)
+	CreateRecord(ctx context.Context, record *store.BlacklistRecord) error
+	UpdateRecord(ctx context.Context, record *store.BlacklistRecord) error
+	DeleteRecord(ctx context.Context, list, nik string) error
+	RestoreRecord(ctx context.Context, list, nik string) (*store.BlacklistRecord, error)
+	CreateTemporaryRecord(ctx context.Context, record *store.BlacklistRecord, days int) error
+	ConfirmRecord(ctx context.Context, list, nik string) (*store.BlacklistRecord, error)
+	ExtendRecord(ctx context.Context, list, nik string, days int) (*store.BlacklistRecord, error)
+	InvalidateRecords(ctx context.Context, niks ...string) error
+
+	// Whitelist
+	CreateWhitelistEntry(ctx context.Context, entry *store.WhitelistEntry, ttl time.Duration) error
+	ListWhitelist(ctx context.Context, includeInactive bool) ([]*store.WhitelistEntry, error)
+	RevokeWhitelistEntry(ctx context.Context, id int64) (*store.WhitelistEntry, error)
+
+	// Pins
+	PinRecord(ctx context.Context, list, nik, caseID, reason string) (*store.Pin, error)
+	UnpinRecord(ctx context.Context, id int64) (*store.Pin, error)
+	ListPins(ctx context.Context, filter store.PinFilter) ([]*store.Pin, error)
+	CloseCase(ctx context.Context, caseID string) ([]*store.Pin, error)
+
+	// Review cases
+	ListCases(ctx context.Context, filter store.CaseFilter) ([]*store.ReviewCase, error)
+	GetCase(ctx context.Context, id int64) (*store.ReviewCase, error)
+	UpdateCase(ctx context.Context, id int64, update store.CaseUpdate) (*store.ReviewCase, error)
+}
+
+var _ Service = (*BlacklistService)(nil)
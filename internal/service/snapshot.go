@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// EffectivePolicy is every setting that decides the outcome of a check
+// besides the records themselves. A check's own choices, such as the lists
+// or profile it asks for, are made within it.
+type EffectivePolicy struct {
+	Match        MatchPolicy `json:"match"`
+	DefaultLists []string    `json:"default_lists"`
+	// Profile is the default matching profile and CallerProfiles the per-caller overrides
+	Profile        string             `json:"profile"`
+	CallerProfiles map[string]string  `json:"caller_profiles"`
+	BirthDateCheck string             `json:"birth_date_check"`
+	ScoreWeights   map[string]float64 `json:"score_weights"`
+	ReviewScore    float64            `json:"review_score"`
+	HitScore       float64            `json:"hit_score"`
+	// TenantMatch are the match policies of tenants with thresholds of their own
+	TenantMatch map[string]MatchPolicy `json:"tenant_match,omitempty"`
+}
+
+// effectivePolicy collects the service's effective policy under the given
+// tunables
+func (s *BlacklistService) effectivePolicy(t *tunables) EffectivePolicy {
+	callers := make(map[string]string, len(s.profiles.callers))
+	for caller, profile := range s.profiles.callers {
+		callers[caller] = profile.Name
+	}
+	return EffectivePolicy{
+		Match:          t.policy,
+		DefaultLists:   s.defaultLists,
+		Profile:        s.profiles.fallback.Name,
+		CallerProfiles: callers,
+		BirthDateCheck: s.birthDateCheck,
+		ScoreWeights:   s.scorer.weights,
+		ReviewScore:    s.scorer.review,
+		HitScore:       s.scorer.hit,
+		TenantMatch:    t.tenantPolicies,
+	}
+}
+
+// policyVersion identifies an effective policy by a digest of its contents,
+// so instances configured alike agree on it and any change yields a new one.
+// Maps are encoded with sorted keys, which keeps the digest stable.
+func policyVersion(policy EffectivePolicy) (string, error) {
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// PolicyVersion returns the version of the effective policy checks are made under
+func (s *BlacklistService) PolicyVersion() string {
+	return s.current().policyVersion
+}
+
+// SnapshotPolicy stores the effective policy under its version, unless an
+// instance with the same settings already did
+func (s *BlacklistService) SnapshotPolicy(ctx context.Context, policies store.PolicyStore) error {
+	settings := s.current()
+	created, err := policies.Save(ctx, settings.policyVersion, s.effectivePolicy(settings))
+	if err != nil {
+		return fmt.Errorf("error saving policy snapshot %s: %w", settings.policyVersion, err)
+	}
+	if created {
+		s.logger(ctx).Info("Recorded new screening policy", zap.String("policy_version", settings.policyVersion))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// ErrTemporaryDuration is returned when a temporary record would outlive the maximum number of days
+var ErrTemporaryDuration = errors.New("temporary record duration exceeds the maximum allowed")
+
+// Temporary record events
+const (
+	TemporaryCreated   = "created"
+	TemporaryConfirmed = "confirmed"
+	TemporaryExtended  = "extended"
+	TemporaryExpiring  = "expiring"
+	TemporaryExpired   = "expired"
+)
+
+// expiryIn returns when a temporary record lasting days from now expires, or
+// the default number of days when days is zero
+func (s *BlacklistService) expiryIn(days int) (time.Time, error) {
+	if days == 0 {
+		days = s.temporaryDefaultDays
+	}
+	if days < 0 || days > s.temporaryMaxDays {
+		return time.Time{}, ErrTemporaryDuration
+	}
+	return time.Now().AddDate(0, 0, days), nil
+}
+
+// CreateTemporaryRecord adds a record that is deleted after days, or the
+// default number of days when days is zero, unless it is confirmed first
+func (s *BlacklistService) CreateTemporaryRecord(ctx context.Context, record *store.BlacklistRecord, days int) error {
+	expiresAt, err := s.expiryIn(days)
+	if err != nil {
+		return err
+	}
+	record.ExpiresAt = &expiresAt
+	if err := s.CreateRecord(ctx, record); err != nil {
+		return err
+	}
+
+	metrics.TemporaryRecordEventsTotal.WithLabelValues(TemporaryCreated).Inc()
+	s.logger(ctx).Info("Created temporary blacklist record",
+		zap.Int64("record_id", record.ID),
+		zap.String("list", record.List),
+		zap.String("created_by", store.Actor(ctx)),
+		zap.Time("expires_at", expiresAt))
+	return nil
+}
+
+// ConfirmRecord makes a temporary record permanent
+func (s *BlacklistService) ConfirmRecord(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	record, err := s.store.Confirm(ctx, list, nik)
+	if err != nil {
+		return nil, fmt.Errorf("error confirming record: %w", err)
+	}
+
+	metrics.TemporaryRecordEventsTotal.WithLabelValues(TemporaryConfirmed).Inc()
+	s.logger(ctx).Info("Confirmed temporary blacklist record",
+		zap.Int64("record_id", record.ID),
+		zap.String("list", record.List),
+		zap.String("confirmed_by", store.Actor(ctx)))
+	return record, nil
+}
+
+// ExtendRecord moves the expiry of a temporary record to days from now, or
+// the default number of days when days is zero
+func (s *BlacklistService) ExtendRecord(ctx context.Context, list, nik string, days int) (*store.BlacklistRecord, error) {
+	expiresAt, err := s.expiryIn(days)
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.store.Extend(ctx, list, nik, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("error extending record: %w", err)
+	}
+
+	metrics.TemporaryRecordEventsTotal.WithLabelValues(TemporaryExtended).Inc()
+	s.logger(ctx).Info("Extended temporary blacklist record",
+		zap.Int64("record_id", record.ID),
+		zap.String("list", record.List),
+		zap.String("extended_by", store.Actor(ctx)),
+		zap.Time("expires_at", expiresAt))
+	return record, nil
+}
+
+// ExpiringRecords flags and returns the temporary records expiring within
+// the given window that haven't been reported yet
+func (s *BlacklistService) ExpiringRecords(ctx context.Context, within time.Duration) ([]*store.BlacklistRecord, error) {
+	records, err := s.store.MarkExpiring(ctx, within)
+	if err != nil {
+		return nil, fmt.Errorf("error finding expiring records: %w", err)
+	}
+	return records, nil
+}
+
+// ExpireRecords deletes the temporary records of every tenant past their
+// expiry and invalidates affected cache entries
+func (s *BlacklistService) ExpireRecords(ctx context.Context) ([]*store.BlacklistRecord, error) {
+	records, err := s.store.Expire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error expiring records: %w", err)
+	}
+	byTenant := make(map[string][]string)
+	for _, record := range records {
+		byTenant[record.Tenant] = append(byTenant[record.Tenant], record.NIK)
+	}
+	for tenant, niks := range byTenant {
+		s.invalidate(store.WithTenant(ctx, tenant), niks...)
+	}
+	return records, nil
+}
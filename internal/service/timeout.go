@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"blacklist-check/internal/database"
+	"blacklist-check/internal/deadline"
+	"blacklist-check/internal/metrics"
+
+	"github.com/lib/pq"
+)
+
+// Dependencies a check waits on, used as the "dependency" label
+const (
+	DependencyPostgres = "postgres"
+	DependencyRedis    = "redis"
+	// DependencyCheck is the overall budget of a check across its dependencies
+	DependencyCheck = "check"
+)
+
+// ErrDependencyTimeout is matched by every DependencyTimeoutError
+var ErrDependencyTimeout = errors.New("dependency timeout")
+
+// DependencyTimeoutError is returned when a dependency, or the check as a
+// whole, didn't finish within its deadline while the caller was still waiting
+type DependencyTimeoutError struct {
+	Dependency string
+	Err        error
+}
+
+func (e *DependencyTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out: %v", e.Dependency, e.Err)
+}
+
+func (e *DependencyTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes every DependencyTimeoutError match ErrDependencyTimeout
+func (e *DependencyTimeoutError) Is(target error) bool {
+	return target == ErrDependencyTimeout
+}
+
+// DependencyUnavailableError is returned when a dependency is known to be
+// down and was not called, so the check failed fast
+type DependencyUnavailableError struct {
+	Dependency string
+	Err        error
+}
+
+func (e *DependencyUnavailableError) Error() string {
+	return fmt.Sprintf("%s unavailable: %v", e.Dependency, e.Err)
+}
+
+func (e *DependencyUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// query bounds a database query by the query timeout
+func (s *BlacklistService) query(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// budget bounds a check by the check timeout, unless the caller set a
+// deadline of its own, which then governs alone
+func (s *BlacklistService) budget(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.checkTimeout <= 0 || deadline.Hinted(ctx) {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.checkTimeout)
+}
+
+// budgetError returns err as a DependencyTimeoutError of the check when it
+// failed because checkCtx ran out of budget while ctx was still live. A
+// dependency that timed out on its own deadline keeps its name.
+func (s *BlacklistService) budgetError(ctx, checkCtx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrDependencyTimeout) {
+		return err
+	}
+	if !s.timedOut(ctx, DependencyCheck, checkCtx.Err()) {
+		return err
+	}
+	return &DependencyTimeoutError{Dependency: DependencyCheck, Err: err}
+}
+
+// timedOut reports whether err is dependency running past its own deadline
+// while ctx, the one the caller is waiting on, was still live, and counts it
+func (s *BlacklistService) timedOut(ctx context.Context, dependency string, err error) bool {
+	if err == nil || ctx.Err() != nil || !isTimeout(err) {
+		return false
+	}
+	metrics.DependencyTimeoutsTotal.WithLabelValues(dependency).Inc()
+	return true
+}
+
+// dependencyError returns err as a DependencyTimeoutError when it is
+// dependency running past its own deadline, as a DependencyUnavailableError
+// when the database circuit breaker turned it away, and unchanged otherwise
+func (s *BlacklistService) dependencyError(ctx context.Context, dependency string, err error) error {
+	if errors.Is(err, database.ErrUnavailable) {
+		return &DependencyUnavailableError{Dependency: dependency, Err: err}
+	}
+	if !s.timedOut(ctx, dependency, err) {
+		return err
+	}
+	return &DependencyTimeoutError{Dependency: dependency, Err: err}
+}
+
+// isTimeout reports whether err is a deadline passing, however the client
+// that hit it reports one
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// lib/pq may surface the cancellation it sent when the context expired
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014"
+}
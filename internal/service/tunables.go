@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// tunables are the settings a configuration reload may change. They are
+// swapped as a whole, and a check reads them once, so it never runs under a
+// mix of old and new settings.
+type tunables struct {
+	policy MatchPolicy
+	// tenantPolicies are the policies of tenants with thresholds of their own
+	tenantPolicies map[string]MatchPolicy
+	// policyVersion identifies the effective policy in audit records
+	policyVersion string
+	positiveTTL   time.Duration
+	negativeTTL   time.Duration
+}
+
+// newTunables reads the tunable settings from cfg
+func newTunables(cfg *config.Config) (*tunables, error) {
+	policy := MatchPolicy{
+		MinSimilarity:            cfg.Match.MinSimilarity,
+		Rules:                    splitList(cfg.Match.Rules),
+		NameOnlySimilarity:       cfg.Match.NameOnlySimilarity,
+		MissingBirthDatePenalty:  cfg.Match.MissingBirthDatePenalty,
+		MissingBirthPlacePenalty: cfg.Match.MissingBirthPlacePenalty,
+		BirthDateToleranceDays:   cfg.Match.BirthDateToleranceDays,
+		CandidateBudget:          cfg.Match.CandidateBudget,
+		RerankCandidates:         cfg.Match.RerankCandidates,
+		RerankTrigramWeight:      cfg.Match.RerankTrigramWeight,
+		RerankJaroWinklerWeight:  cfg.Match.RerankJaroWinklerWeight,
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("error loading match policy: %w", err)
+	}
+	tenantPolicies, err := parseTenantPolicies(cfg.Tenant.MatchThresholds, policy)
+	if err != nil {
+		return nil, fmt.Errorf("error loading tenant match thresholds: %w", err)
+	}
+	return &tunables{
+		policy:         policy,
+		tenantPolicies: tenantPolicies,
+		positiveTTL:    cfg.Cache.PositiveTTL,
+		negativeTTL:    cfg.Cache.NegativeTTL,
+	}, nil
+}
+
+// parseTenantPolicies parses per-tenant thresholds of the form
+//
+//	acme=0.4:0.85,globex=0.3:0.8
+//
+// mapping a tenant to <min similarity>:<name-only similarity>, each applied
+// over base
+func parseTenantPolicies(spec string, base MatchPolicy) (map[string]MatchPolicy, error) {
+	policies := make(map[string]MatchPolicy)
+	for _, entry := range splitList(spec) {
+		tenant, value, ok := strings.Cut(entry, "=")
+		min, nameOnly, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 || strings.TrimSpace(tenant) == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected tenant=min:name_only", entry)
+		}
+		policy := base
+		var err error
+		if policy.MinSimilarity, err = strconv.ParseFloat(strings.TrimSpace(min), 64); err != nil {
+			return nil, fmt.Errorf("invalid min similarity in entry %q", entry)
+		}
+		if policy.NameOnlySimilarity, err = strconv.ParseFloat(strings.TrimSpace(nameOnly), 64); err != nil {
+			return nil, fmt.Errorf("invalid name-only similarity in entry %q", entry)
+		}
+		if err := policy.Validate(); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+		policies[strings.TrimSpace(tenant)] = policy
+	}
+	return policies, nil
+}
+
+// policyFor returns the match policy checks of tenant are made under
+func (t *tunables) policyFor(tenant string) MatchPolicy {
+	if policy, ok := t.tenantPolicies[tenant]; ok {
+		return policy
+	}
+	return t.policy
+}
+
+// cacheTTL returns how long a result may be cached. Negatives get a much
+// shorter TTL so a newly listed person can't pass screening on a stale cache
+// entry written before the listing.
+func (t *tunables) cacheTTL(result *ListResult) time.Duration {
+	if result.Matched {
+		return t.positiveTTL
+	}
+	return t.negativeTTL
+}
+
+// current returns the settings checks are made under now
+func (s *BlacklistService) current() *tunables {
+	return s.settings.Load()
+}
+
+// setTunables versions the policy of t and makes it current
+func (s *BlacklistService) setTunables(t *tunables) error {
+	version, err := policyVersion(s.effectivePolicy(t))
+	if err != nil {
+		return fmt.Errorf("error versioning policy: %w", err)
+	}
+	t.policyVersion = version
+	s.settings.Store(t)
+	return nil
+}
+
+// PrepareReload reads the match policies and cache TTLs from cfg and returns
+// the function that applies them, or why they can't be. Checks in flight
+// finish under the settings they started with.
+func (s *BlacklistService) PrepareReload(cfg *config.Config) (func(), error) {
+	next, err := newTunables(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		previous := s.current()
+		for _, service := range []*BlacklistService{s, s.sandbox} {
+			if service == nil {
+				continue
+			}
+			t := *next
+			// Only a misbehaving encoder can fail here, and it would have at startup
+			if err := service.setTunables(&t); err != nil {
+				s.log.Error("Error applying reloaded settings", zap.Error(err))
+				return
+			}
+		}
+		if version := s.current().policyVersion; version != previous.policyVersion {
+			s.log.Info("Screening policy changed",
+				zap.String("previous_version", previous.policyVersion),
+				zap.String("policy_version", version))
+		}
+	}, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// HotNIKsKey holds the number of checks of each NIK, as a sorted set, for the
+// startup warm-up to preload the most checked ones
+const HotNIKsKey = "blacklist:nik:checks"
+
+// hotNIKsKept is how many NIKs the set keeps per NIK preloaded. The rest are
+// dropped at each warm-up, so the set doesn't grow with every NIK ever checked
+// yet newly popular NIKs have room to climb.
+const hotNIKsKept = 10
+
+// preloader is a store that can cache a NIK lookup ahead of the first one
+type preloader interface {
+	Preload(ctx context.Context, list, nik string) error
+}
+
+// hotNIKMember names a NIK checked by a tenant in the hot NIK set. NIKs of
+// the default tenant are kept as they are.
+func hotNIKMember(tenant, nik string) string {
+	if tenant == store.DefaultTenant {
+		return nik
+	}
+	return tenant + "/" + nik
+}
+
+// parseHotNIKMember splits a hot NIK set member into its tenant and NIK
+func parseHotNIKMember(member string) (tenant, nik string) {
+	if i := strings.LastIndex(member, "/"); i >= 0 {
+		return member[:i], member[i+1:]
+	}
+	return store.DefaultTenant, member
+}
+
+// trackNIK counts a check of nik towards the hot NIKs, when the warm-up is
+// enabled. A failure only costs the warm-up accuracy, so it is logged.
+func (s *BlacklistService) trackNIK(ctx context.Context, nik string) {
+	if !s.trackNIKs || nik == "" {
+		return
+	}
+	err := s.cache.IncrScore(ctx, HotNIKsKey, hotNIKMember(s.tenancy.Caller(ctx), nik))
+	if s.timedOut(ctx, DependencyRedis, err) {
+		s.logger(ctx).Warn("Counting NIK check timed out", zap.Error(err))
+	} else if err != nil {
+		s.logger(ctx).Error("Error counting NIK check", zap.Error(err))
+	}
+}
+
+// WarmUp preloads the exact-match lookups of the limit most checked NIKs on
+// every list, filling the local NIK cache when enabled and the database's
+// buffers either way. Results cached in Redis are scored against the checked
+// name, so they are left to the first check. It stops early when ctx ends,
+// which isn't an error.
+func (s *BlacklistService) WarmUp(ctx context.Context, limit int) error {
+	start := time.Now()
+	if err := s.cache.TrimScores(ctx, HotNIKsKey, limit*hotNIKsKept); err != nil {
+		return fmt.Errorf("error trimming hot NIKs: %w", err)
+	}
+	members, err := s.cache.TopScores(ctx, HotNIKsKey, limit)
+	if err != nil {
+		return fmt.Errorf("error reading hot NIKs: %w", err)
+	}
+	metrics.CacheWarmupNIKs.WithLabelValues("total").Set(float64(len(members)))
+	loaded := metrics.CacheWarmupNIKs.WithLabelValues("loaded")
+	loaded.Set(0)
+
+	preload := func(ctx context.Context, list, nik string) error {
+		_, err := s.store.GetByNIK(ctx, list, nik)
+		return err
+	}
+	if p, ok := s.store.(preloader); ok {
+		preload = p.Preload
+	}
+
+	var done int
+	for _, member := range members {
+		if ctx.Err() != nil {
+			break
+		}
+		tenant, nik := parseHotNIKMember(member)
+		tenantCtx := store.WithTenant(ctx, tenant)
+		for _, list := range lists.All {
+			// A NIK the filter rules out is never looked up by a check
+			if !s.nikFilter.MayContain(list, nik) {
+				continue
+			}
+			queryCtx, cancel := s.query(tenantCtx)
+			err := preload(queryCtx, list, nik)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				s.logger(ctx).Warn("Error preloading NIK", zap.String("list", list), zap.Error(err))
+			}
+		}
+		done++
+		loaded.Inc()
+	}
+
+	s.logger(ctx).Info("Cache warm-up finished",
+		zap.Int("niks", done),
+		zap.Int("hot_niks", len(members)),
+		zap.Bool("complete", done == len(members)),
+		zap.Duration("duration", time.Since(start)))
+	return nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"time"
+
+	"blacklist-check/internal/metrics"
+)
+
+// Warnings a check can return. They flag suspect input without failing the
+// check, so the caller can prompt for a correction.
+const (
+	// WarningNIKBirthDateMismatch is a birth date that disagrees with the
+	// date of birth encoded in the national ID
+	WarningNIKBirthDateMismatch = "nik_birth_date_mismatch"
+	// WarningBirthDateImprobable is a birth date in the future or before 1900
+	WarningBirthDateImprobable = "birth_date_improbable"
+	// WarningBirthPlaceUnrecognized is a birth place the gazetteer doesn't know
+	WarningBirthPlaceUnrecognized = "birth_place_unrecognized"
+)
+
+// Warning is a non-fatal problem with the input of a check
+type Warning struct {
+	Code string
+	// Field is the request field at fault
+	Field   string
+	Message string
+}
+
+// warnings finds the problems with the input of a check that don't stop it
+// from running
+func (s *BlacklistService) warnings(req CheckRequest, idCheck *IDCheck) []Warning {
+	var warnings []Warning
+	if idCheck != nil && idCheck.BirthDateMismatch {
+		warnings = append(warnings, Warning{
+			Code:    WarningNIKBirthDateMismatch,
+			Field:   "birth_date",
+			Message: "birth_date doesn't match the date of birth encoded in nik",
+		})
+	}
+	if req.HasBirthDate() && (req.BirthDate.Year() < 1900 || req.BirthDate.After(time.Now())) {
+		warnings = append(warnings, Warning{
+			Code:    WarningBirthDateImprobable,
+			Field:   "birth_date",
+			Message: "birth_date is in the future or before 1900",
+		})
+	}
+	if req.HasBirthPlace() && s.gazetteer != nil && !s.gazetteer.Known(req.BirthPlace) {
+		warnings = append(warnings, Warning{
+			Code:    WarningBirthPlaceUnrecognized,
+			Field:   "birth_place",
+			Message: "birth_place isn't a place the gazetteer knows; check its spelling",
+		})
+	}
+	return warnings
+}
+
+// countWarnings counts the warnings of a production check
+func countWarnings(warnings []Warning) {
+	for _, warning := range warnings {
+		metrics.CheckWarningsTotal.WithLabelValues(warning.Code).Inc()
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrWhitelistDuration is returned when a whitelist entry would outlive the maximum TTL
+	ErrWhitelistDuration = errors.New("whitelist duration exceeds the maximum allowed")
+	// ErrWhitelistUnavailable is returned when the whitelist is not available,
+	// as it is kept in Postgres only
+	ErrWhitelistUnavailable = errors.New("the whitelist needs DB_DRIVER=postgres")
+)
+
+// CreateWhitelistEntry clears the subject of entry of matches on its record
+// for ttl, or the default TTL when ttl is zero
+func (s *BlacklistService) CreateWhitelistEntry(ctx context.Context, entry *store.WhitelistEntry, ttl time.Duration) error {
+	if s.whitelist == nil {
+		return ErrWhitelistUnavailable
+	}
+	if ttl == 0 {
+		ttl = s.whitelistTTL
+	}
+	if ttl < 0 || ttl > s.whitelistMaxTTL {
+		return ErrWhitelistDuration
+	}
+	entry.NameNormalized = normalize.Name(entry.Name)
+	entry.CreatedBy = store.Actor(ctx)
+	entry.ExpiresAt = time.Now().Add(ttl)
+	if err := s.whitelist.Create(ctx, entry); err != nil {
+		return err
+	}
+
+	s.logger(ctx).Info("Whitelisted subject for blacklist record",
+		zap.Int64("entry_id", entry.ID),
+		zap.Int64("record_id", entry.RecordID),
+		zap.String("created_by", entry.CreatedBy),
+		zap.Time("expires_at", entry.ExpiresAt))
+	return nil
+}
+
+// ListWhitelist returns the active whitelist entries, or every entry when includeInactive is set
+func (s *BlacklistService) ListWhitelist(ctx context.Context, includeInactive bool) ([]*store.WhitelistEntry, error) {
+	if s.whitelist == nil {
+		return nil, ErrWhitelistUnavailable
+	}
+	return s.whitelist.List(ctx, includeInactive)
+}
+
+// RevokeWhitelistEntry ends a whitelist entry early
+func (s *BlacklistService) RevokeWhitelistEntry(ctx context.Context, id int64) (*store.WhitelistEntry, error) {
+	if s.whitelist == nil {
+		return nil, ErrWhitelistUnavailable
+	}
+	entry, err := s.whitelist.Revoke(ctx, id, store.Actor(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger(ctx).Info("Revoked whitelist entry",
+		zap.Int64("entry_id", entry.ID),
+		zap.Int64("record_id", entry.RecordID),
+		zap.String("revoked_by", store.Actor(ctx)))
+	return entry, nil
+}
+
+// suppressions maps the records the subject of req is whitelisted against
+// to the whitelist entry clearing it
+func (s *BlacklistService) suppressions(ctx context.Context, req CheckRequest) (map[int64]int64, error) {
+	var birthDate *time.Time
+	if req.HasBirthDate() {
+		birthDate = &req.BirthDate
+	}
+	queryCtx, cancel := s.query(ctx)
+	defer cancel()
+	entries, err := s.whitelist.Active(queryCtx, req.NIK, normalize.Name(req.Name), birthDate)
+	if err != nil {
+		return nil, fmt.Errorf("error loading whitelist: %w", s.dependencyError(ctx, DependencyPostgres, err))
+	}
+
+	suppressions := make(map[int64]int64, len(entries))
+	for _, entry := range entries {
+		suppressions[entry.RecordID] = entry.ID
+	}
+	return suppressions, nil
+}
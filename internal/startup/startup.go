@@ -0,0 +1,288 @@
+// Package startup logs what an instance is running with as a single event
+// when it boots: its build, a digest of its configuration, the features it
+// has enabled and the versions of the database, schema, Redis and lists it
+// found. Incident responders can compare pods by that one line.
+package startup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/buildinfo"
+	"blacklist-check/internal/listsync"
+	"blacklist-check/internal/migrate"
+	"blacklist-check/internal/service"
+	"blacklist-check/pkg/config"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// collectTimeout bounds the lookups made for the summary, so a slow
+// dependency can't hold up the boot
+const collectTimeout = 5 * time.Second
+
+// secretSuffixes mark the settings left out of the config digest
+var secretSuffixes = []string{"_PASSWORD", "_KEY", "_KEYS", "_SECRET", "_TOKEN", "_WEBHOOK"}
+
+// perInstance settings differ between replicas by design, so they are left
+// out of the config digest too
+var perInstance = map[string]bool{"INSTANCE_ID": true}
+
+// Summary describes a booting instance
+type Summary struct {
+	InstanceID  string
+	Environment string
+	GoVersion   string
+	// Version, Revision and RevisionTime identify the build and the commit
+	// it was made from
+	Version      string
+	Revision     string
+	RevisionTime string
+	BuildDate    string
+	// ConfigDigest is the SHA-256 of every setting but the secrets, so
+	// instances with the same digest were configured alike
+	ConfigDigest string
+	// SecretsSet names the secret settings that have a value
+	SecretsSet []string
+	Features   []string
+	// PostgresVersion, RedisVersion and SchemaVersion are empty or zero when
+	// they couldn't be read; Errors says why
+	PostgresVersion   string
+	RedisVersion      string
+	SchemaVersion     int64
+	SchemaDirty       bool
+	PendingMigrations int
+	// ListVersion is the cache namespace version, bumped on every record change
+	ListVersion int64
+	// PolicyVersion identifies the effective screening policy
+	PolicyVersion string
+	// SourcesSyncedAt is when each synced source last succeeded
+	SourcesSyncedAt map[string]time.Time
+	Errors          []string
+}
+
+// Reporter collects and logs the startup summary
+type Reporter struct {
+	cfg       *config.Config
+	db        *sqlx.DB
+	redis     redis.UniversalClient
+	migrator  *migrate.Migrator
+	service   *service.BlacklistService
+	connector *listsync.Connector
+	log       *zap.Logger
+}
+
+// NewReporter creates a new startup reporter
+func NewReporter(cfg *config.Config, db *sqlx.DB, redis redis.UniversalClient, migrator *migrate.Migrator, service *service.BlacklistService, connector *listsync.Connector, log *zap.Logger) *Reporter {
+	return &Reporter{
+		cfg:       cfg,
+		db:        db,
+		redis:     redis,
+		migrator:  migrator,
+		service:   service,
+		connector: connector,
+		log:       log,
+	}
+}
+
+// Collect gathers the summary. Dependencies that can't be read are noted in
+// Errors rather than failing, since the summary must not stop a boot.
+func (r *Reporter) Collect(ctx context.Context) *Summary {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+
+	build := buildinfo.Get()
+	s := &Summary{
+		InstanceID:   instanceID(r.cfg.Server.InstanceID),
+		Environment:  r.cfg.Server.Environment,
+		GoVersion:    build.GoVersion,
+		Version:      build.Version,
+		Revision:     build.Commit,
+		RevisionTime: build.CommitTime,
+		BuildDate:    build.BuildDate,
+		Features:     features(r.cfg),
+	}
+	s.ConfigDigest, s.SecretsSet = digest(r.cfg)
+
+	if err := r.db.GetContext(ctx, &s.PostgresVersion, `SHOW server_version`); err != nil {
+		s.Errors = append(s.Errors, fmt.Sprintf("error reading Postgres version: %v", err))
+	}
+	if info, err := r.redis.Info(ctx, "server").Result(); err != nil {
+		s.Errors = append(s.Errors, fmt.Sprintf("error reading Redis version: %v", err))
+	} else {
+		s.RedisVersion = infoField(info, "redis_version")
+	}
+	if status, err := r.migrator.Status(ctx); err != nil {
+		s.Errors = append(s.Errors, fmt.Sprintf("error reading schema version: %v", err))
+	} else {
+		s.SchemaVersion = status.CurrentVersion
+		s.SchemaDirty = status.Dirty
+		s.PendingMigrations = len(status.Pending)
+	}
+	s.ListVersion = r.service.ListVersion(ctx)
+	s.PolicyVersion = r.service.PolicyVersion()
+	if r.connector.Enabled() {
+		statuses, err := r.connector.Statuses(ctx)
+		if err != nil {
+			s.Errors = append(s.Errors, fmt.Sprintf("error reading sync status: %v", err))
+		}
+		s.SourcesSyncedAt = make(map[string]time.Time)
+		for _, status := range statuses {
+			if status.LastSuccessAt != nil {
+				s.SourcesSyncedAt[status.Source] = *status.LastSuccessAt
+			}
+		}
+	}
+	return s
+}
+
+// Log collects the summary and logs it as one event
+func (r *Reporter) Log(ctx context.Context) {
+	s := r.Collect(ctx)
+	r.log.Info("Instance starting",
+		zap.String("instance_id", s.InstanceID),
+		zap.String("environment", s.Environment),
+		zap.String("go_version", s.GoVersion),
+		zap.String("version", s.Version),
+		zap.String("revision", s.Revision),
+		zap.String("revision_time", s.RevisionTime),
+		zap.String("build_date", s.BuildDate),
+		zap.String("config_digest", s.ConfigDigest),
+		zap.Strings("secrets_set", s.SecretsSet),
+		zap.Strings("features", s.Features),
+		zap.String("postgres_version", s.PostgresVersion),
+		zap.String("redis_version", s.RedisVersion),
+		zap.Int64("schema_version", s.SchemaVersion),
+		zap.Bool("schema_dirty", s.SchemaDirty),
+		zap.Int("pending_migrations", s.PendingMigrations),
+		zap.Int64("list_version", s.ListVersion),
+		zap.String("policy_version", s.PolicyVersion),
+		zap.Any("sources_synced_at", s.SourcesSyncedAt),
+		zap.Strings("errors", s.Errors))
+}
+
+// instanceID returns the configured instance ID, or the hostname, which is
+// the pod name under Kubernetes
+func instanceID(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// features names the optional behavior the config turns on
+func features(cfg *config.Config) []string {
+	var enabled []string
+	add := func(on bool, name string) {
+		if on {
+			enabled = append(enabled, name)
+		}
+	}
+	add(cfg.Server.GRPCReflection, "grpc_reflection")
+	add(cfg.Server.ReusePort, "reuse_port")
+	add(cfg.Server.TLSCertFile != "", "tls")
+	add(cfg.Server.InternalPort != 0, "internal_listener")
+	add(cfg.Server.InternalPort != 0 && cfg.Server.InternalPprof, "pprof")
+	add(cfg.Server.LogPayloads, "payload_logging")
+	add(cfg.Database.MigrateOnStart, "migrate_on_start")
+	add(cfg.Cache.LocalNIKEnabled, "local_nik_cache")
+	add(cfg.Cache.NIKFilterEnabled, "nik_filter")
+	add(cfg.Auth.Policy != "", "auth_policy")
+	add(cfg.Auth.APIKeys != "", "auth_api_key")
+	add(cfg.Auth.HMACKeys != "", "auth_hmac")
+	add(cfg.Auth.JWTJWKSURL != "", "auth_jwt")
+	add(cfg.History.Enabled, "check_history")
+	add(cfg.Tokenize.Provider != "" && cfg.Tokenize.Provider != "none", "tokenize_"+cfg.Tokenize.Provider)
+	add(cfg.Watchdog.Reclaim, "watchdog_reclaim")
+	add(cfg.Sandbox.Enabled, "sandbox")
+	add(cfg.Events.KafkaBrokers != "", "events_kafka")
+	add(cfg.Redis.Mode != "standalone", "redis_"+cfg.Redis.Mode)
+	add(cfg.Redis.TLSEnabled, "redis_tls")
+	add(strings.TrimSpace(cfg.Database.ReplicaHosts) != "", "db_replicas")
+	for _, source := range strings.Split(cfg.Sync.Sources, ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			enabled = append(enabled, "sync_"+source)
+		}
+	}
+	return enabled
+}
+
+// digest hashes every setting except the secrets in name order, returning
+// the digest and the names of the secrets that are set
+func digest(cfg *config.Config) (string, []string) {
+	settings := make(map[string]string)
+	var secrets []string
+	collect(reflect.ValueOf(*cfg), settings, &secrets)
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sort.Strings(secrets)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s\n", name, settings[name])
+	}
+	return hex.EncodeToString(h.Sum(nil)), secrets
+}
+
+// collect walks the config sections, recording each setting by its
+// environment variable name
+func collect(v reflect.Value, settings map[string]string, secrets *[]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		name := t.Field(i).Tag.Get("mapstructure")
+		if name == "" {
+			if field.Kind() == reflect.Struct {
+				collect(field, settings, secrets)
+			}
+			continue
+		}
+		if secret(name) {
+			if !field.IsZero() {
+				*secrets = append(*secrets, name)
+			}
+			continue
+		}
+		if perInstance[name] {
+			continue
+		}
+		settings[name] = fmt.Sprint(field.Interface())
+	}
+}
+
+// secret reports whether a setting holds a credential
+func secret(name string) bool {
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// infoField returns a field of a Redis INFO reply
+func infoField(info, key string) string {
+	for _, line := range strings.Split(info, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), key+":"); ok {
+			return value
+		}
+	}
+	return ""
+}
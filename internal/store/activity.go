@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikcrypt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Activity kinds recorded in admin_activity; the other kinds of the feed come
+// from the tables of the features themselves
+const (
+	ActivityCertMapping = "cert_mapping"
+	ActivityScreening   = "screening"
+	ActivityExport      = "export"
+	ActivityCache       = "cache"
+	ActivityRuntime     = "runtime"
+	ActivityReviewCase  = "review_case"
+)
+
+// Activity is an event of the admin activity feed
+type Activity struct {
+	EventID    string          `db:"event_id" json:"event_id"`
+	Kind       string          `db:"kind" json:"kind"`
+	Action     string          `db:"action" json:"action"`
+	Actor      string          `db:"actor" json:"actor"`
+	Target     string          `db:"target" json:"target,omitempty"`
+	Details    json.RawMessage `db:"details" json:"details,omitempty"`
+	OccurredAt time.Time       `db:"occurred_at" json:"occurred_at"`
+}
+
+// ActivityFilter narrows the activity feed. Zero fields don't filter.
+type ActivityFilter struct {
+	Kind   string
+	Action string
+	Actor  string
+	Target string
+	Since  *time.Time
+	Until  *time.Time
+	// IncludeSystem includes changes made by syncs and other unattended processes
+	IncludeSystem bool
+}
+
+// activityCursor is the position after the last event of a page
+type activityCursor struct {
+	OccurredAt time.Time `json:"t"`
+	EventID    string    `json:"id"`
+}
+
+// ActivityStore defines the interface for admin activity access
+type ActivityStore interface {
+	Record(ctx context.Context, kind, action, target string, details interface{}) error
+	List(ctx context.Context, filter ActivityFilter, cursor string, limit int) ([]*Activity, string, error)
+}
+
+// activityStore implements ActivityStore
+type activityStore struct {
+	db *sqlx.DB
+	// keys find the record changes and proposals of a NIK, which target its
+	// keyed hash once it is protected
+	keys *nikcrypt.Keys
+}
+
+// NewActivityStore creates a new admin activity store
+func NewActivityStore(db *sqlx.DB, keys *nikcrypt.Keys) ActivityStore {
+	return &activityStore{db: db, keys: keys}
+}
+
+// Record appends an event attributed to the actor in ctx. details is stored as JSON.
+func (s *activityStore) Record(ctx context.Context, kind, action, target string, details interface{}) error {
+	var data *string
+	if details != nil {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("error encoding activity details: %w", err)
+		}
+		data = new(string)
+		*data = string(encoded)
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO admin_activity (kind, action, actor, target, details)
+		VALUES ($1, $2, $3, $4, $5)
+	`, kind, action, Actor(ctx), target, data)
+	return err
+}
+
+// List returns up to limit events matching filter, newest first, starting
+// after cursor, along with the cursor of the next page or "" on the last one
+func (s *activityStore) List(ctx context.Context, filter ActivityFilter, cursor string, limit int) ([]*Activity, string, error) {
+	defer metrics.ObserveQuery("activity_list", time.Now())
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	// where adds a condition, numbering its ? placeholders after the arguments so far
+	where := func(cond string, values ...interface{}) {
+		for _, v := range values {
+			args = append(args, v)
+			cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conds = append(conds, cond)
+	}
+	if filter.Kind != "" {
+		where("kind = ?", filter.Kind)
+	}
+	if filter.Action != "" {
+		where("action = ?", filter.Action)
+	}
+	if filter.Actor != "" {
+		where("actor = ?", filter.Actor)
+	}
+	if filter.Target != "" {
+		targets := append(nikMACs(s.keys, []string{filter.Target}), filter.Target)
+		where("target = ANY(?)", targets)
+	}
+	if filter.Since != nil {
+		where("occurred_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		where("occurred_at < ?", *filter.Until)
+	}
+	if !filter.IncludeSystem {
+		conds = append(conds, "actor <> 'system' AND actor NOT LIKE 'sync:%'")
+	}
+	if cursor != "" {
+		c, err := decodeActivityCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		where("(occurred_at, event_id) < (?, ?)", c.OccurredAt, c.EventID)
+	}
+
+	query := `
+		SELECT event_id, kind, action, actor, target, details, occurred_at
+		FROM admin_activity_feed`
+	if len(conds) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, limit+1)
+	query += fmt.Sprintf("\n\t\tORDER BY occurred_at DESC, event_id DESC\n\t\tLIMIT $%d", len(args))
+
+	var events []*Activity
+	if err := s.db.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, "", err
+	}
+	if len(events) <= limit {
+		return events, "", nil
+	}
+
+	events = events[:limit]
+	last := events[limit-1]
+	data, _ := json.Marshal(activityCursor{OccurredAt: last.OccurredAt, EventID: last.EventID})
+	return events, base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeActivityCursor(s string) (*activityCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c activityCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.EventID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
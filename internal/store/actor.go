@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	actorKey struct{}
+	txKey    struct{}
+)
+
+// WithActor returns a copy of ctx attributing blacklist writes to actor, who
+// is recorded as deleted_by and in blacklist_history
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor stored in ctx, or "system" when there is none
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return "system"
+}
+
+// withTx returns a copy of ctx whose writes join tx, committing or rolling
+// back with it, instead of running in transactions of their own
+func withTx(ctx context.Context, tx *sqlx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// txFrom returns the transaction ctx carries, if any
+func txFrom(ctx context.Context) (*sqlx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sqlx.Tx)
+	return tx, ok
+}
+
+// inActorTx runs fn in a transaction whose app.actor setting carries the actor
+// from ctx, so the history trigger can attribute the change. fn joins the
+// transaction ctx carries, if any, which is then left to its owner to commit.
+func inActorTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	if tx, ok := txFrom(ctx); ok {
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('app.actor', $1, true)`, Actor(ctx)); err != nil {
+			return err
+		}
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('app.actor', $1, true)`, Actor(ctx)); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
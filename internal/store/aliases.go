@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"blacklist-check/internal/phonetic"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Kinds of alias a record can carry
+const (
+	// AliasAKA is another name the person is known by
+	AliasAKA = "aka"
+	// AliasFKA is a name the person was formerly known by
+	AliasFKA = "fka"
+	// AliasSpelling is another spelling or transliteration of the name
+	AliasSpelling = "spelling"
+)
+
+// AliasTypes are the kinds of alias a record can carry
+var AliasTypes = []string{AliasAKA, AliasFKA, AliasSpelling}
+
+// ValidAliasType reports whether t is a known kind of alias
+func ValidAliasType(t string) bool {
+	for _, known := range AliasTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Alias is another name a blacklisted person is known by. Checks match
+// aliases like the record's own name.
+type Alias struct {
+	Alias string `db:"alias" json:"alias"`
+	Type  string `db:"alias_type" json:"alias_type"`
+}
+
+// replaceAliases replaces the aliases of a record with the given ones within
+// tx. Blank and repeated aliases are skipped.
+func replaceAliases(ctx context.Context, tx *sqlx.Tx, recordID int64, aliases []Alias) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM blacklist_aliases WHERE record_id = $1`, recordID); err != nil {
+		return fmt.Errorf("error clearing aliases: %w", err)
+	}
+	for _, alias := range aliases {
+		name := strings.Join(strings.Fields(alias.Alias), " ")
+		if name == "" {
+			continue
+		}
+		if alias.Type == "" {
+			alias.Type = AliasAKA
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO blacklist_aliases (record_id, alias, alias_type, name_phonetic)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (record_id, alias) DO NOTHING
+		`, recordID, name, alias.Type, phonetic.Encode(name))
+		if err != nil {
+			return fmt.Errorf("error inserting alias: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadAliases fills in the aliases of records, leaving records without any
+// with an empty list
+func loadAliases(ctx context.Context, q sqlx.QueryerContext, records []*BlacklistRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*BlacklistRecord, len(records))
+	ids := make([]int64, 0, len(records))
+	for _, record := range records {
+		record.Aliases = []Alias{}
+		byID[record.ID] = record
+		ids = append(ids, record.ID)
+	}
+
+	var rows []struct {
+		RecordID int64 `db:"record_id"`
+		Alias
+	}
+	err := sqlx.SelectContext(ctx, q, &rows, `
+		SELECT record_id, alias, alias_type
+		FROM blacklist_aliases
+		WHERE record_id = ANY($1)
+		ORDER BY record_id, id
+	`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("error loading aliases: %w", err)
+	}
+	for _, row := range rows {
+		record := byID[row.RecordID]
+		record.Aliases = append(record.Aliases, row.Alias)
+	}
+	return nil
+}
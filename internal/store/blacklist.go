@@ -3,164 +3,804 @@ package store
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"blacklist-check/internal/database"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikcrypt"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/phonetic"
+
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // BlacklistRecord represents a blacklist record in the database
 type BlacklistRecord struct {
-	ID          int64     `db:"id"`
-	NIK         string    `db:"nik"`
-	Name        string    `db:"name"`
-	BirthPlace  string    `db:"birth_place"`
-	BirthDate   time.Time `db:"birth_date"`
-	Reason      string    `db:"reason"`
-	CreatedAt   time.Time `db:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at"`
-	Similarity  float64   `db:"similarity"`
+	ID             int64        `db:"id"`
+	Tenant         string       `db:"tenant_id"`
+	List           string       `db:"list_type"`
+	NIK            string       `db:"nik"`
+	Name           string       `db:"name"`
+	BirthPlace     string       `db:"birth_place"`
+	BirthDate      *time.Time   `db:"birth_date"`
+	Reason         string       `db:"reason"`
+	ReasonCode     string       `db:"reason_code"`
+	ReasonParams   ReasonParams `db:"reason_params"`
+	Source         string       `db:"source"`
+	NamePhonetic   string       `db:"name_phonetic"`
+	NameNormalized string       `db:"name_normalized"`
+	NameSorted     string       `db:"name_sorted"`
+	NIKHash        string       `db:"nik_hash"`
+	// NIKEncrypted is the NIK encrypted under the NIK keys, which is all a
+	// row written with keys stores of it; reads fill NIK in from it
+	NIKEncrypted string     `db:"nik_encrypted" json:"-"`
+	CreatedAt    time.Time  `db:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at"`
+	DeletedAt    *time.Time `db:"deleted_at"`
+	DeletedBy    *string    `db:"deleted_by"`
+	// ExpiresAt is set on temporary records, which are deleted when it passes
+	ExpiresAt *time.Time `db:"expires_at"`
+	// Aliases are the record's other names. Update leaves them alone when
+	// nil; an empty list removes them.
+	Aliases    []Alias `db:"-"`
+	Similarity float64 `db:"similarity"`
+	// MatchedAlias is the alias a name lookup matched, empty when it matched
+	// the record's own name
+	MatchedAlias string `db:"matched_alias"`
+}
+
+// BornOn reports whether the record has a birth date and it is date
+func (r *BlacklistRecord) BornOn(date time.Time) bool {
+	return r.BirthDate != nil && !date.IsZero() && r.BirthDate.Equal(date)
+}
+
+// nullDate stores a missing or zero birth date as NULL
+func nullDate(t *time.Time) *time.Time {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// ReasonParams holds the variables of a templated reason, stored as JSONB
+type ReasonParams map[string]string
+
+// Value implements driver.Valuer
+func (p ReasonParams) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(p)
+}
+
+// Scan implements sql.Scanner
+func (p *ReasonParams) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ReasonParams", src)
+	}
+	return json.Unmarshal(data, p)
 }
 
 // BlacklistStore defines the interface for blacklist data access
 type BlacklistStore interface {
-	GetByNIK(ctx context.Context, nik string) (*BlacklistRecord, error)
-	GetByFuzzyMatch(ctx context.Context, name string, birthPlace *string, birthDate *time.Time) ([]*BlacklistRecord, error)
+	GetByNIK(ctx context.Context, list, nik string) (*BlacklistRecord, error)
+	CountNIKs(ctx context.Context) (int64, error)
+	EachNIK(ctx context.Context, fn func(list, nik string) error) error
+	GetByFuzzyMatch(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*BlacklistRecord, bool, error)
 	SearchByName(ctx context.Context, name string) ([]*BlacklistRecord, error)
+	GetByPhonetic(ctx context.Context, list, name string, birthDate *time.Time) ([]*BlacklistRecord, error)
+	NearestByName(ctx context.Context, list, name string, limit int) ([]*BlacklistRecord, error)
+	Search(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*BlacklistRecord, error)
+	List(ctx context.Context, filter RecordFilter, sort, cursor string, limit int) ([]*BlacklistRecord, string, error)
+	ListBySource(ctx context.Context, list, source string) ([]*BlacklistRecord, error)
+	Create(ctx context.Context, record *BlacklistRecord) error
+	Update(ctx context.Context, record *BlacklistRecord) error
+	Delete(ctx context.Context, list, nik string) error
+	Restore(ctx context.Context, list, nik string) (*BlacklistRecord, error)
+	Confirm(ctx context.Context, list, nik string) (*BlacklistRecord, error)
+	Extend(ctx context.Context, list, nik string, expiresAt time.Time) (*BlacklistRecord, error)
+	MarkExpiring(ctx context.Context, within time.Duration) ([]*BlacklistRecord, error)
+	Expire(ctx context.Context) ([]*BlacklistRecord, error)
+	History(ctx context.Context, nik string) ([]*RecordChange, error)
+	ApplyChangeSet(ctx context.Context, cs *ChangeSet) error
 	Ping(ctx context.Context) error
 }
 
+var (
+	// ErrRecordNotFound is returned when a mutation targets a record that does not exist
+	ErrRecordNotFound = errors.New("blacklist record not found")
+	// ErrRecordExists is returned when creating a record whose NIK is already blacklisted
+	ErrRecordExists = errors.New("blacklist record already exists")
+)
+
+// RecordChange is an entry of a record's history with full snapshots of the
+// row before and after the change. Action is one of create, update, delete,
+// restore or purge.
+type RecordChange struct {
+	ID        int64           `db:"id"`
+	NIK       string          `db:"nik"`
+	Action    string          `db:"action"`
+	Before    json.RawMessage `db:"before"`
+	After     json.RawMessage `db:"after"`
+	ChangedBy string          `db:"changed_by"`
+	ChangedAt time.Time       `db:"changed_at"`
+}
+
+// ChangeSet is a delta of records for a single list source
+type ChangeSet struct {
+	List    string             `json:"list"`
+	Source  string             `json:"source"`
+	Added   []*BlacklistRecord `json:"added"`
+	Updated []*BlacklistRecord `json:"updated"`
+	Deleted []string           `json:"deleted"`
+}
+
 // blacklistStore implements BlacklistStore
 type blacklistStore struct {
 	db *sqlx.DB
+	// keys encrypt the NIKs written and key their lookups; nil stores them
+	// in plaintext only
+	keys *nikcrypt.Keys
+	// tenancy scopes every read and write to the records of the caller's tenant
+	tenancy *Tenancy
+	// replicas serve the check lookups, which tolerate replication lag; nil
+	// reads everything from db
+	replicas *database.Replicas
 }
 
-// NewBlacklistStore creates a new blacklist store
-func NewBlacklistStore(db *sqlx.DB) BlacklistStore {
-	return &blacklistStore{db: db}
+// NewBlacklistStore creates a new blacklist store. With keys, NIKs are also
+// stored encrypted and looked up by their keyed hash.
+func NewBlacklistStore(db *sqlx.DB, keys *nikcrypt.Keys, tenancy *Tenancy) BlacklistStore {
+	return NewReplicatedBlacklistStore(db, nil, keys, tenancy)
 }
 
-// GetByNIK retrieves a blacklist record by NIK
-func (s *blacklistStore) GetByNIK(ctx context.Context, nik string) (*BlacklistRecord, error) {
+// NewReplicatedBlacklistStore creates a blacklist store whose check lookups
+// read from the healthy read replicas. Writes, listings, exports and history
+// stay on the primary, where they see every committed change.
+func NewReplicatedBlacklistStore(db *sqlx.DB, replicas *database.Replicas, keys *nikcrypt.Keys, tenancy *Tenancy) BlacklistStore {
+	return &blacklistStore{db: db, keys: keys, tenancy: tenancy, replicas: replicas}
+}
+
+// reader returns the database a check lookup reads from
+func (s *blacklistStore) reader() *sqlx.DB {
+	if s.replicas == nil {
+		return s.db
+	}
+	return s.replicas.Reader()
+}
+
+// GetByNIK retrieves a blacklist record by NIK from a list. With keys it is
+// looked up by keyed hash, or by plaintext on rows not yet backfilled, and
+// the NIK returned is the one decrypted.
+func (s *blacklistStore) GetByNIK(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("get_by_nik", time.Now())
+
+	condition, args := nikCondition(s.keys, "", "$3", "$4", nik)
 	var record BlacklistRecord
-	err := s.db.GetContext(ctx, &record, `
-		SELECT id, nik, name, birth_place, birth_date, reason, created_at, updated_at
+	err := s.reader().GetContext(ctx, &record, `
+		SELECT id, list_type, nik, nik_encrypted, name, birth_place, birth_date, reason, reason_code, reason_params, created_at, updated_at
 		FROM blacklist
-		WHERE nik = $1
-	`, nik)
+		WHERE list_type = $1 AND tenant_id = $2 AND `+condition+` AND deleted_at IS NULL
+	`, append([]interface{}{list, s.tenancy.Owner(ctx, list)}, args...)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
+	if err := revealRecords(s.keys, &record); err != nil {
+		return nil, err
+	}
 	return &record, nil
 }
 
-// GetByFuzzyMatch performs an efficient fuzzy match using PostgreSQL's trigram similarity
-func (s *blacklistStore) GetByFuzzyMatch(ctx context.Context, name string, birthPlace *string, birthDate *time.Time) ([]*BlacklistRecord, error) {
-	var records []*BlacklistRecord
-	var err error
+// CountNIKs counts the records on every list, of every tenant
+func (s *blacklistStore) CountNIKs(ctx context.Context) (int64, error) {
+	defer metrics.ObserveQuery("count_niks", time.Now())
 
-	// Minimum similarity threshold (0.3 is a good balance between accuracy and performance)
-	const minSimilarity = 0.3
+	var count int64
+	err := s.db.GetContext(ctx, &count, `SELECT count(*) FROM blacklist WHERE deleted_at IS NULL`)
+	return count, err
+}
 
-	if birthDate != nil && birthPlace != nil {
-		// Full match with name similarity, exact birth date, and birth place similarity
-		err = s.db.SelectContext(ctx, &records, `
-			WITH name_matches AS (
-				SELECT 
-					id, nik, name, birth_place, birth_date, reason, created_at, updated_at,
-					similarity(name, $1) as similarity
-				FROM blacklist
-				WHERE similarity(name, $1) > $4
-					AND birth_date = $2
-					AND similarity(birth_place, $3) > $4
-				ORDER BY similarity DESC
-				LIMIT 5
-			)
-			SELECT * FROM name_matches
-			WHERE similarity > $4
-		`, name, birthDate, *birthPlace, minSimilarity)
-	} else if birthDate != nil {
-		// Match with name similarity and exact birth date
-		err = s.db.SelectContext(ctx, &records, `
-			WITH name_matches AS (
-				SELECT 
-					id, nik, name, birth_place, birth_date, reason, created_at, updated_at,
-					similarity(name, $1) as similarity
-				FROM blacklist
-				WHERE similarity(name, $1) > $3
-					AND birth_date = $2
-				ORDER BY similarity DESC
-				LIMIT 5
-			)
-			SELECT * FROM name_matches
-			WHERE similarity > $3
-		`, name, birthDate, minSimilarity)
-	} else if birthPlace != nil {
-		// Match with name and birth place similarity
-		err = s.db.SelectContext(ctx, &records, `
-			WITH name_matches AS (
-				SELECT 
-					id, nik, name, birth_place, birth_date, reason, created_at, updated_at,
-					similarity(name, $1) as similarity
-				FROM blacklist
-				WHERE similarity(name, $1) > $3
-					AND similarity(birth_place, $2) > $3
-				ORDER BY similarity DESC
-				LIMIT 5
-			)
-			SELECT * FROM name_matches
-			WHERE similarity > $3
-		`, name, *birthPlace, minSimilarity)
-	} else {
-		// Name-only match with similarity
-		err = s.db.SelectContext(ctx, &records, `
-			WITH name_matches AS (
-				SELECT 
-					id, nik, name, birth_place, birth_date, reason, created_at, updated_at,
-					similarity(name, $1) as similarity
-				FROM blacklist
-				WHERE similarity(name, $1) > $2
-				ORDER BY similarity DESC
-				LIMIT 5
-			)
-			SELECT * FROM name_matches
-			WHERE similarity > $2
-		`, name, minSimilarity)
+// EachNIK calls fn with the list and NIK of every record of every tenant,
+// streaming them rather than loading the table into memory
+func (s *blacklistStore) EachNIK(ctx context.Context, fn func(list, nik string) error) error {
+	defer metrics.ObserveQuery("each_nik", time.Now())
+
+	rows, err := s.db.QueryContext(ctx, `SELECT list_type, nik, nik_encrypted FROM blacklist WHERE deleted_at IS NULL`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var list, nik, encrypted string
+		if err := rows.Scan(&list, &nik, &encrypted); err != nil {
+			return err
+		}
+		if nik, err = revealNIK(s.keys, nik, encrypted); err != nil {
+			return err
+		}
+		if err := fn(list, nik); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FuzzyMatchLimit is how many records a fuzzy match returns unless a caller
+// asks for more
+const FuzzyMatchLimit = 5
+
+// GetByFuzzyMatch performs an efficient fuzzy match within a list using PostgreSQL's
+// trigram similarity, returning records whose similarity exceeds minSimilarity.
+// Records missing a birth date or birth place remain candidates; the matching
+// rules decide what a partial record can match. A birth place must resemble
+// one of birthPlaces, the subject's and the other names of the same place. Aliases are matched like the
+// record's name, and a record is rated by whichever of its names is closest.
+//
+// A positive budget bounds how many candidate names are considered. The most
+// similar are taken, with ties in record order, so a truncated search leaves
+// out only names less similar than every one it considered, the same ones
+// every time, and truncated reports that some were. At most limit records are
+// returned, the most similar first; records as similar as each other are
+// returned in record order.
+func (s *blacklistStore) GetByFuzzyMatch(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*BlacklistRecord, bool, error) {
+	defer metrics.ObserveQuery("fuzzy_match", time.Now())
+
+	// Unknown birth data is left out of the search rather than compared
+	conditions := []string{
+		"b.list_type = $1",
+		"b.tenant_id = $4",
+		"b.deleted_at IS NULL",
+		"similarity(n.name, $2) > $3",
+	}
+	args := []interface{}{list, name, minSimilarity, s.tenancy.Owner(ctx, list)}
+	if birthDate != nil {
+		args = append(args, *birthDate)
+		conditions = append(conditions, fmt.Sprintf("(b.birth_date = $%d OR b.birth_date IS NULL)", len(args)))
+	}
+	if len(birthPlaces) > 0 {
+		args = append(args, pq.Array(birthPlaces))
+		conditions = append(conditions, fmt.Sprintf("(EXISTS (SELECT 1 FROM unnest($%d::text[]) AS place WHERE similarity(b.birth_place, place) > $3) OR b.birth_place = '')", len(args)))
 	}
 
+	// One candidate past the budget tells a truncated search from one that
+	// fit exactly
+	bounded, considered, truncated := "", "candidates", "false"
+	if budget > 0 {
+		args = append(args, budget)
+		bounded = fmt.Sprintf("LIMIT $%d + 1", len(args))
+		considered = fmt.Sprintf("(SELECT * FROM candidates ORDER BY similarity DESC, id, matched_alias LIMIT $%d) considered", len(args))
+		truncated = fmt.Sprintf("(SELECT count(*) FROM candidates) > $%d", len(args))
+	}
+
+	args = append(args, limit)
+	returned := fmt.Sprintf("LIMIT $%d", len(args))
+
+	var rows []struct {
+		BlacklistRecord
+		Truncated bool `db:"truncated"`
+	}
+	err := s.reader().SelectContext(ctx, &rows, `
+		WITH candidates AS (
+			SELECT b.id, b.list_type, b.nik, b.nik_encrypted, b.name, b.birth_place, b.birth_date, b.reason, b.reason_code, b.reason_params, b.created_at, b.updated_at,
+				similarity(n.name, $2) AS similarity, n.alias AS matched_alias
+			FROM blacklist b
+			JOIN blacklist_names n ON n.record_id = b.id
+			WHERE `+strings.Join(conditions, " AND ")+`
+			ORDER BY similarity DESC, b.id, n.alias
+			`+bounded+`
+		), name_matches AS (
+			SELECT DISTINCT ON (id) *
+			FROM `+considered+`
+			ORDER BY id, similarity DESC, matched_alias
+		)
+		SELECT *, `+truncated+` AS truncated
+		FROM name_matches
+		ORDER BY similarity DESC, id
+		`+returned+`
+	`, args...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	return records, nil
+	// A truncated search had candidates past the budget, so it has rows to
+	// report it on
+	records := make([]*BlacklistRecord, 0, len(rows))
+	for i := range rows {
+		records = append(records, &rows[i].BlacklistRecord)
+	}
+	if err := revealRecords(s.keys, records...); err != nil {
+		return nil, false, err
+	}
+	return records, len(rows) > 0 && rows[0].Truncated, nil
 }
 
 // SearchByName searches for blacklist records by name using fuzzy matching
 func (s *blacklistStore) SearchByName(ctx context.Context, name string) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("search_by_name", time.Now())
+
 	var records []*BlacklistRecord
 	const minSimilarity = 0.3
 
-	err := s.db.SelectContext(ctx, &records, `
+	err := s.reader().SelectContext(ctx, &records, `
 		WITH name_matches AS (
 			SELECT 
-				id, nik, name, birth_place, birth_date, reason, created_at, updated_at,
+				id, list_type, nik, nik_encrypted, name, birth_place, birth_date, reason, reason_code, reason_params, created_at, updated_at,
 				similarity(name, $1) as similarity
 			FROM blacklist
-			WHERE similarity(name, $1) > $2
+			WHERE deleted_at IS NULL
+				AND `+ownerCondition("", "$3", "$4")+`
+				AND similarity(name, $1) > $2
 			ORDER BY similarity DESC
 			LIMIT 5
 		)
 		SELECT * FROM name_matches
 		WHERE similarity > $2
-	`, name, minSimilarity)
+	`, name, minSimilarity, s.tenancy.sharedLists(), s.tenancy.Caller(ctx))
 	if err != nil {
 		return nil, err
 	}
+	if err := revealRecords(s.keys, records...); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetByPhonetic finds records in a list whose precomputed phonetic code, or that of
+// one of their aliases, equals that of name, catching transliteration variants
+// that fall below the trigram threshold
+func (s *blacklistStore) GetByPhonetic(ctx context.Context, list, name string, birthDate *time.Time) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("phonetic_match", time.Now())
+
+	code := phonetic.Encode(name)
+	if code == "" {
+		return nil, nil
+	}
+
+	var records []*BlacklistRecord
+	var err error
+	if birthDate != nil {
+		err = s.reader().SelectContext(ctx, &records, `
+			SELECT DISTINCT ON (b.id)
+				b.id, b.list_type, b.nik, b.nik_encrypted, b.name, b.birth_place, b.birth_date, b.reason, b.reason_code, b.reason_params, b.created_at, b.updated_at,
+				n.alias AS matched_alias
+			FROM blacklist b
+			JOIN blacklist_names n ON n.record_id = b.id
+			WHERE n.name_phonetic = $1 AND b.list_type = $3 AND b.tenant_id = $4 AND b.deleted_at IS NULL
+				AND b.birth_date = $2
+			ORDER BY b.id, n.alias
+			LIMIT 5
+		`, code, birthDate, list, s.tenancy.Owner(ctx, list))
+	} else {
+		err = s.reader().SelectContext(ctx, &records, `
+			SELECT DISTINCT ON (b.id)
+				b.id, b.list_type, b.nik, b.nik_encrypted, b.name, b.birth_place, b.birth_date, b.reason, b.reason_code, b.reason_params, b.created_at, b.updated_at,
+				n.alias AS matched_alias
+			FROM blacklist b
+			JOIN blacklist_names n ON n.record_id = b.id
+			WHERE n.name_phonetic = $1 AND b.list_type = $2 AND b.tenant_id = $3 AND b.deleted_at IS NULL
+			ORDER BY b.id, n.alias
+			LIMIT 5
+		`, code, list, s.tenancy.Owner(ctx, list))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := revealRecords(s.keys, records...); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// NearestByName returns the records in a list with the most similar names,
+// regardless of threshold or birth data, for explaining why a check didn't match
+func (s *blacklistStore) NearestByName(ctx context.Context, list, name string, limit int) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("nearest_by_name", time.Now())
+
+	var records []*BlacklistRecord
+	err := s.reader().SelectContext(ctx, &records, `
+		SELECT id, list_type, nik, nik_encrypted, name, birth_place, birth_date, reason, reason_code, reason_params, name_phonetic, created_at, updated_at,
+			similarity(name, $1) as similarity
+		FROM blacklist
+		WHERE list_type = $2 AND tenant_id = $4 AND deleted_at IS NULL
+		ORDER BY similarity DESC
+		LIMIT $3
+	`, name, list, limit, s.tenancy.Owner(ctx, list))
+	if err != nil {
+		return nil, err
+	}
+	if err := revealRecords(s.keys, records...); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Search returns records of a list for browsing: those whose NIK starts with
+// query or whose name resembles it, most similar first, or the most recently
+// updated ones when query is empty. With keys, a protected NIK can't be
+// matched by prefix, only when query is the whole NIK. Records come with
+// their aliases.
+func (s *blacklistStore) Search(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("search", time.Now())
+
+	var records []*BlacklistRecord
+	err := s.db.SelectContext(ctx, &records, `
+		SELECT id, list_type, nik, nik_encrypted, name, birth_place, birth_date, reason, reason_code, reason_params, source,
+			created_at, updated_at, deleted_at, deleted_by, expires_at,
+			CASE WHEN $2 = '' THEN 0 ELSE similarity(name, $2) END AS similarity
+		FROM blacklist
+		WHERE list_type = $1 AND tenant_id = $5
+			AND ($3 OR deleted_at IS NULL)
+			AND ($2 = '' OR nik LIKE $2 || '%' OR nik_hmac = ANY($6) OR name ILIKE '%' || $2 || '%' OR similarity(name, $2) > 0.3)
+		ORDER BY similarity DESC, updated_at DESC
+		LIMIT $4
+	`, list, query, includeDeleted, limit, s.tenancy.Owner(ctx, list), nikMACs(s.keys, []string{query}))
+	if err != nil {
+		return nil, err
+	}
+	if err := revealRecords(s.keys, records...); err != nil {
+		return nil, err
+	}
+	if err := loadAliases(ctx, s.db, records); err != nil {
+		return nil, err
+	}
 	return records, nil
 }
 
 func (s *blacklistStore) Ping(ctx context.Context) error {
 	return s.db.PingContext(ctx)
-} 
\ No newline at end of file
+}
+
+// recordColumns are returned for a record written
+const recordColumns = `id, tenant_id, list_type, nik, nik_encrypted, name, birth_place, birth_date, reason, reason_code, reason_params, source,
+	name_phonetic, name_normalized, name_sorted, created_at, updated_at, expires_at`
+
+// Create inserts a new blacklist record for the caller's tenant. It returns
+// ErrSharedList when a caller with a tenant creates one on a shared list.
+func (s *blacklistStore) Create(ctx context.Context, record *BlacklistRecord) error {
+	defer metrics.ObserveQuery("create", time.Now())
+
+	if record.Source == "" {
+		record.Source = "internal"
+	}
+	if record.List == "" {
+		record.List = lists.Internal
+	}
+	tenant, err := s.tenancy.writer(ctx, record.List)
+	if err != nil {
+		return err
+	}
+	nik := record.NIK
+	plain, encrypted, mac := protectNIK(s.keys, nik)
+	err = inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		if err := s.adoptNIK(ctx, tx, tenant, record.List, nik, encrypted, mac); err != nil {
+			return err
+		}
+		err := tx.GetContext(ctx, record, s.insertRecordQuery()+`
+			RETURNING `+recordColumns,
+			plain, record.Name, record.BirthPlace, nullDate(record.BirthDate), record.Reason, record.Source,
+			phonetic.Encode(record.Name), normalize.Name(record.Name), normalize.TokenSorted(record.Name),
+			record.ReasonCode, record.ReasonParams, record.List, record.ExpiresAt, encrypted, mac, tenant)
+		if err != nil {
+			return err
+		}
+		// A soft-deleted row taken over drops the aliases it had
+		return replaceAliases(ctx, tx, record.ID, record.Aliases)
+	})
+	if err == sql.ErrNoRows {
+		return ErrRecordExists
+	}
+	record.NIK = nik
+	return err
+}
+
+// insertRecordQuery inserts a record, taking over the row of a soft-deleted
+// record with the same NIK in the same list of the same tenant. It affects no
+// row when the NIK is live on the list. A NIK is identified by its keyed hash
+// with keys and by its plaintext without. expires_at is NULL except for
+// temporary records.
+func (s *blacklistStore) insertRecordQuery() string {
+	identity := "nik"
+	if s.keys != nil {
+		identity = "nik_hmac"
+	}
+	return `
+	INSERT INTO blacklist (nik, name, birth_place, birth_date, reason, source,
+		name_phonetic, name_normalized, name_sorted, reason_code, reason_params, list_type, expires_at,
+		nik_encrypted, nik_hmac, tenant_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	ON CONFLICT (tenant_id, list_type, ` + identity + `) WHERE ` + identity + ` <> '' DO UPDATE
+	SET nik = EXCLUDED.nik, name = EXCLUDED.name, birth_place = EXCLUDED.birth_place, birth_date = EXCLUDED.birth_date,
+		reason = EXCLUDED.reason, source = EXCLUDED.source,
+		name_phonetic = EXCLUDED.name_phonetic, name_normalized = EXCLUDED.name_normalized,
+		name_sorted = EXCLUDED.name_sorted,
+		nik_encrypted = EXCLUDED.nik_encrypted, nik_hmac = EXCLUDED.nik_hmac,
+		reason_code = EXCLUDED.reason_code, reason_params = EXCLUDED.reason_params,
+		expires_at = EXCLUDED.expires_at, expiry_notified_at = NULL,
+		deleted_at = NULL, deleted_by = NULL, updated_at = CURRENT_TIMESTAMP
+	WHERE blacklist.deleted_at IS NOT NULL
+`
+}
+
+// adoptNIK protects, within tx, the row of a NIK on a list of tenant that
+// isn't stored under the current keys yet: one holding its plaintext, or its
+// keyed hash under a previous key. The insert that follows then finds the
+// row, live or soft-deleted, by the current keyed hash.
+func (s *blacklistStore) adoptNIK(ctx context.Context, tx *sqlx.Tx, tenant, list, nik, encrypted, mac string) error {
+	if s.keys == nil {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE blacklist
+		SET nik = '', nik_encrypted = $4, nik_hmac = $5
+		WHERE tenant_id = $1 AND list_type = $2
+			AND (nik_hmac = ANY($3) OR nik = $6)
+			AND (nik <> '' OR nik_hmac <> $5)
+	`, tenant, list, pq.Array(s.keys.MACs(nik)), encrypted, mac, nik)
+	return err
+}
+
+// Update modifies an existing blacklist record identified by list and NIK,
+// replacing its aliases unless they are nil
+func (s *blacklistStore) Update(ctx context.Context, record *BlacklistRecord) error {
+	defer metrics.ObserveQuery("update", time.Now())
+
+	if record.List == "" {
+		record.List = lists.Internal
+	}
+	tenant, err := s.tenancy.writer(ctx, record.List)
+	if err != nil {
+		return err
+	}
+
+	nik := record.NIK
+	condition, nikArgs := nikCondition(s.keys, "", "$11", "$12", nik)
+	err = inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, record, `
+			UPDATE blacklist
+			SET name = $1, birth_place = $2, birth_date = $3, reason = $4,
+				name_phonetic = $5, name_normalized = $6, name_sorted = $7,
+				reason_code = $8, reason_params = $9, updated_at = CURRENT_TIMESTAMP
+			WHERE list_type = $10 AND tenant_id = $13 AND `+condition+` AND deleted_at IS NULL
+			RETURNING `+recordColumns,
+			append([]interface{}{record.Name, record.BirthPlace, nullDate(record.BirthDate), record.Reason,
+				phonetic.Encode(record.Name), normalize.Name(record.Name), normalize.TokenSorted(record.Name),
+				record.ReasonCode, record.ReasonParams, record.List}, append(nikArgs, tenant)...)...)
+		if err != nil || record.Aliases == nil {
+			return err
+		}
+		return replaceAliases(ctx, tx, record.ID, record.Aliases)
+	})
+	if err == sql.ErrNoRows {
+		return ErrRecordNotFound
+	}
+	record.NIK = nik
+	return err
+}
+
+// Delete soft-deletes a blacklist record by list and NIK, attributing it to
+// the actor in ctx. It returns ErrRecordPinned when an open case pins the record.
+func (s *blacklistStore) Delete(ctx context.Context, list, nik string) error {
+	defer metrics.ObserveQuery("delete", time.Now())
+
+	tenant, err := s.tenancy.writer(ctx, list)
+	if err != nil {
+		return err
+	}
+	condition, nikArgs := nikCondition(s.keys, "", "$3", "$4", nik)
+	var n int64
+	err = inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		var pinned bool
+		err := tx.GetContext(ctx, &pinned, `
+			SELECT `+pinnedCondition+`
+			FROM blacklist
+			WHERE list_type = $1 AND tenant_id = $2 AND `+condition+` AND deleted_at IS NULL
+			FOR UPDATE
+		`, append([]interface{}{list, tenant}, nikArgs...)...)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if pinned {
+			return ErrRecordPinned
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE blacklist
+			SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $5
+			WHERE list_type = $1 AND tenant_id = $2 AND `+condition+` AND deleted_at IS NULL
+		`, append([]interface{}{list, tenant}, append(nikArgs, Actor(ctx))...)...)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Restore reverses the soft deletion of a blacklist record. A temporary record
+// restored after it expired stays on the list for good.
+func (s *blacklistStore) Restore(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("restore", time.Now())
+
+	tenant, err := s.tenancy.writer(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	condition, nikArgs := nikCondition(s.keys, "", "$3", "$4", nik)
+	var record BlacklistRecord
+	err = inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &record, `
+			UPDATE blacklist
+			SET deleted_at = NULL, deleted_by = NULL, updated_at = CURRENT_TIMESTAMP,
+				expires_at = CASE WHEN expires_at <= CURRENT_TIMESTAMP THEN NULL ELSE expires_at END
+			WHERE list_type = $1 AND tenant_id = $2 AND `+condition+` AND deleted_at IS NOT NULL
+			RETURNING `+recordColumns,
+			append([]interface{}{list, tenant}, nikArgs...)...)
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	record.NIK = nik
+	return &record, nil
+}
+
+// History returns every recorded change to a NIK on any list the caller
+// reads, oldest first. With keys, changes are found by the NIK's keyed hash
+// like records, and their snapshots hold no NIK.
+func (s *blacklistStore) History(ctx context.Context, nik string) ([]*RecordChange, error) {
+	defer metrics.ObserveQuery("history", time.Now())
+
+	condition, nikArgs := nikCondition(s.keys, "", "$3", "$4", nik)
+	var changes []*RecordChange
+	err := s.db.SelectContext(ctx, &changes, `
+		SELECT id, $4::text AS nik, action, before, after, changed_by, changed_at
+		FROM blacklist_history
+		WHERE `+ownerCondition("", "$1", "$2")+` AND `+condition+`
+		ORDER BY id
+	`, append([]interface{}{s.tenancy.sharedLists(), s.tenancy.Caller(ctx)}, nikArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ListBySource retrieves all records a source contributed to a list, with
+// their aliases. Within the transaction ctx carries, the records are locked
+// until it ends, so a change set written in it is diffed against records no
+// other sync changes meanwhile.
+func (s *blacklistStore) ListBySource(ctx context.Context, list, source string) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("list_by_source", time.Now())
+
+	var (
+		q    sqlx.QueryerContext = s.db
+		lock string
+	)
+	if tx, ok := txFrom(ctx); ok {
+		q, lock = tx, "FOR UPDATE"
+	}
+	var records []*BlacklistRecord
+	err := sqlx.SelectContext(ctx, q, &records, `
+		SELECT id, list_type, nik, nik_encrypted, name, birth_place, birth_date, reason, reason_code, reason_params, source, created_at, updated_at
+		FROM blacklist
+		WHERE list_type = $1 AND tenant_id = $3 AND source = $2 AND deleted_at IS NULL
+		`+lock, list, source, s.tenancy.Owner(ctx, list))
+	if err != nil {
+		return nil, err
+	}
+	if err := revealRecords(s.keys, records...); err != nil {
+		return nil, err
+	}
+	if err := loadAliases(ctx, q, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ApplyChangeSet writes a change set in a single transaction
+func (s *blacklistStore) ApplyChangeSet(ctx context.Context, cs *ChangeSet) error {
+	defer metrics.ObserveQuery("apply_change_set", time.Now())
+
+	tenant, err := s.tenancy.writer(ctx, cs.List)
+	if err != nil {
+		return err
+	}
+	return inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		return s.applyChangeSet(ctx, tx, cs, tenant)
+	})
+}
+
+// applyChangeSet writes the inserts, updates and soft deletes of a change set
+// to the records of tenant within tx. Updates and deletes pass over pinned
+// records; the source still differs from them, so the next sync after they
+// are unpinned applies them.
+func (s *blacklistStore) applyChangeSet(ctx context.Context, tx *sqlx.Tx, cs *ChangeSet, tenant string) error {
+	for _, record := range cs.Added {
+		var id int64
+		plain, encrypted, mac := protectNIK(s.keys, record.NIK)
+		if err := s.adoptNIK(ctx, tx, tenant, cs.List, record.NIK, encrypted, mac); err != nil {
+			return fmt.Errorf("error inserting record %s: %w", record.NIK, err)
+		}
+		err := tx.GetContext(ctx, &id, s.insertRecordQuery()+` RETURNING id`,
+			plain, record.Name, record.BirthPlace, nullDate(record.BirthDate), record.Reason, cs.Source,
+			phonetic.Encode(record.Name), normalize.Name(record.Name), normalize.TokenSorted(record.Name),
+			record.ReasonCode, record.ReasonParams, cs.List, nil, encrypted, mac, tenant)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("error inserting record %s: %w", record.NIK, ErrRecordExists)
+		}
+		if err != nil {
+			return fmt.Errorf("error inserting record %s: %w", record.NIK, err)
+		}
+		if err := replaceAliases(ctx, tx, id, record.Aliases); err != nil {
+			return fmt.Errorf("error inserting record %s: %w", record.NIK, err)
+		}
+	}
+
+	// A change set carries every alias of its records, so an update without
+	// any removes those the record had
+	for _, record := range cs.Updated {
+		var id int64
+		condition, nikArgs := nikCondition(s.keys, "", "$12", "$13", record.NIK)
+		err := tx.GetContext(ctx, &id, `
+			UPDATE blacklist
+			SET name = $1, birth_place = $2, birth_date = $3, reason = $4,
+				name_phonetic = $6, name_normalized = $7, name_sorted = $8,
+				reason_code = $9, reason_params = $10, updated_at = CURRENT_TIMESTAMP
+			WHERE list_type = $11 AND tenant_id = $14 AND `+condition+` AND source = $5 AND deleted_at IS NULL
+				AND NOT `+pinnedCondition+`
+			RETURNING id
+		`, append([]interface{}{record.Name, record.BirthPlace, nullDate(record.BirthDate), record.Reason, cs.Source,
+			phonetic.Encode(record.Name), normalize.Name(record.Name), normalize.TokenSorted(record.Name),
+			record.ReasonCode, record.ReasonParams, cs.List}, append(nikArgs, tenant)...)...)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error updating record %s: %w", record.NIK, err)
+		}
+		if err := replaceAliases(ctx, tx, id, record.Aliases); err != nil {
+			return fmt.Errorf("error updating record %s: %w", record.NIK, err)
+		}
+	}
+
+	if len(cs.Deleted) > 0 {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE blacklist
+			SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $3
+			WHERE list_type = $4 AND tenant_id = $5 AND source = $1 AND (nik = ANY($2) OR nik_hmac = ANY($6)) AND deleted_at IS NULL
+				AND NOT `+pinnedCondition+`
+		`, cs.Source, pq.Array(cs.Deleted), Actor(ctx), cs.List, tenant, nikMACs(s.keys, cs.Deleted))
+		if err != nil {
+			return fmt.Errorf("error deleting records: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"blacklist-check/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrGrantNotFound is returned when a break-glass grant does not exist or is no longer active
+var ErrGrantNotFound = errors.New("break-glass grant not found")
+
+// BreakGlassGrant is a time-boxed emergency admin grant
+type BreakGlassGrant struct {
+	ID            int64          `db:"id" json:"id"`
+	Subject       string         `db:"subject" json:"subject"`
+	Justification string         `db:"justification" json:"justification"`
+	TokenHash     string         `db:"token_hash" json:"-"`
+	Roles         pq.StringArray `db:"roles" json:"roles"`
+	ExpiresAt     time.Time      `db:"expires_at" json:"expires_at"`
+	CreatedAt     time.Time      `db:"created_at" json:"created_at"`
+	RevokedAt     *time.Time     `db:"revoked_at" json:"revoked_at,omitempty"`
+	RevokedBy     *string        `db:"revoked_by" json:"revoked_by,omitempty"`
+}
+
+// BreakGlassUse is a request made with a break-glass grant
+type BreakGlassUse struct {
+	ID     int64     `db:"id" json:"id"`
+	Method string    `db:"method" json:"method"`
+	Path   string    `db:"path" json:"path"`
+	UsedAt time.Time `db:"used_at" json:"used_at"`
+}
+
+// BreakGlassStore defines the interface for break-glass grant access
+type BreakGlassStore interface {
+	Create(ctx context.Context, grant *BreakGlassGrant) error
+	Active(ctx context.Context, tokenHash string) (*BreakGlassGrant, error)
+	List(ctx context.Context, since time.Time) ([]*BreakGlassGrant, error)
+	Revoke(ctx context.Context, id int64, revokedBy string) (*BreakGlassGrant, error)
+	RecordUse(ctx context.Context, grantID int64, method, path string) error
+	Uses(ctx context.Context, grantID int64) ([]*BreakGlassUse, error)
+}
+
+// breakGlassStore implements BreakGlassStore
+type breakGlassStore struct {
+	db *sqlx.DB
+}
+
+// NewBreakGlassStore creates a new break-glass store
+func NewBreakGlassStore(db *sqlx.DB) BreakGlassStore {
+	return &breakGlassStore{db: db}
+}
+
+const breakGlassColumns = `id, subject, justification, token_hash, roles, expires_at, created_at, revoked_at, revoked_by`
+
+// Create inserts a grant, filling in its ID and creation time
+func (s *breakGlassStore) Create(ctx context.Context, grant *BreakGlassGrant) error {
+	if grant.Roles == nil {
+		grant.Roles = pq.StringArray{}
+	}
+	return s.db.GetContext(ctx, grant, `
+		INSERT INTO breakglass_grants (subject, justification, token_hash, roles, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+breakGlassColumns,
+		grant.Subject, grant.Justification, grant.TokenHash, grant.Roles, grant.ExpiresAt)
+}
+
+// Active returns the unexpired, unrevoked grant with the given token hash, or nil
+func (s *breakGlassStore) Active(ctx context.Context, tokenHash string) (*BreakGlassGrant, error) {
+	defer metrics.ObserveQuery("breakglass_active", time.Now())
+
+	var grant BreakGlassGrant
+	err := s.db.GetContext(ctx, &grant, `
+		SELECT `+breakGlassColumns+`
+		FROM breakglass_grants
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+	`, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// List returns the grants issued since the given time, newest first
+func (s *breakGlassStore) List(ctx context.Context, since time.Time) ([]*BreakGlassGrant, error) {
+	var grants []*BreakGlassGrant
+	err := s.db.SelectContext(ctx, &grants, `
+		SELECT `+breakGlassColumns+`
+		FROM breakglass_grants
+		WHERE created_at >= $1
+		ORDER BY id DESC
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// Revoke ends an active grant early
+func (s *breakGlassStore) Revoke(ctx context.Context, id int64, revokedBy string) (*BreakGlassGrant, error) {
+	var grant BreakGlassGrant
+	err := s.db.GetContext(ctx, &grant, `
+		UPDATE breakglass_grants
+		SET revoked_at = CURRENT_TIMESTAMP, revoked_by = $2
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING `+breakGlassColumns,
+		id, revokedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrGrantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// RecordUse appends a request made with a grant to its audit trail
+func (s *breakGlassStore) RecordUse(ctx context.Context, grantID int64, method, path string) error {
+	defer metrics.ObserveQuery("breakglass_record_use", time.Now())
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO breakglass_audit (grant_id, method, path)
+		VALUES ($1, $2, $3)
+	`, grantID, method, path)
+	return err
+}
+
+// Uses returns every request made with a grant, oldest first
+func (s *breakGlassStore) Uses(ctx context.Context, grantID int64) ([]*BreakGlassUse, error) {
+	var uses []*BreakGlassUse
+	err := s.db.SelectContext(ctx, &uses, `
+		SELECT id, method, path, used_at
+		FROM breakglass_audit
+		WHERE grant_id = $1
+		ORDER BY id
+	`, grantID)
+	if err != nil {
+		return nil, err
+	}
+	return uses, nil
+}
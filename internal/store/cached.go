@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"blacklist-check/internal/cacheinsight"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/metrics"
+)
+
+// nikKey identifies a NIK on a list of a tenant
+type nikKey struct {
+	tenant string
+	list   string
+	nik    string
+}
+
+// cachedLookup is a GetByNIK result; a nil record caches the absence of one
+type cachedLookup struct {
+	record  *BlacklistRecord
+	expires time.Time
+}
+
+// CachedBlacklistStore decorates a BlacklistStore with an in-process cache of
+// GetByNIK results, sparing the exact-match fast path a database round trip.
+// Writes through the decorator evict the affected NIKs; changes made on other
+// replicas must be fed to Invalidate, and the TTL bounds staleness if such an
+// event is missed.
+type CachedBlacklistStore struct {
+	BlacklistStore
+
+	ttl        time.Duration
+	maxEntries int
+	// insights tracks lookups by NIK; nil when disabled
+	insights *cacheinsight.Insights
+	tenancy  *Tenancy
+
+	mu      sync.RWMutex
+	entries map[nikKey]cachedLookup
+	// tenants are those with cached lookups, for evicting a NIK from each
+	tenants map[string]bool
+	// generation is bumped by Invalidate so a lookup racing with a change
+	// doesn't repopulate the cache with the value it read before the change
+	generation uint64
+}
+
+// NewCachedBlacklistStore wraps next with a GetByNIK cache, keyed by the
+// tenant whose records tenancy says a lookup reads
+func NewCachedBlacklistStore(next BlacklistStore, ttl time.Duration, maxEntries int, insights *cacheinsight.Insights, tenancy *Tenancy) *CachedBlacklistStore {
+	return &CachedBlacklistStore{
+		BlacklistStore: next,
+		ttl:            ttl,
+		maxEntries:     maxEntries,
+		insights:       insights,
+		tenancy:        tenancy,
+		entries:        make(map[nikKey]cachedLookup),
+		tenants:        make(map[string]bool),
+	}
+}
+
+// GetByNIK retrieves a blacklist record by NIK from a list, serving repeated lookups from memory
+func (s *CachedBlacklistStore) GetByNIK(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	key := nikKey{tenant: s.tenancy.Owner(ctx, list), list: list, nik: nik}
+	s.mu.RLock()
+	entry, ok := s.entries[key]
+	generation := s.generation
+	s.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		metrics.CacheHitsTotal.WithLabelValues(metrics.CacheLocalNIK).Inc()
+		s.insights.Record(cacheinsight.TierLocalNIK, list+":"+nik, true)
+		return copyRecord(entry.record), nil
+	}
+	metrics.CacheMissesTotal.WithLabelValues(metrics.CacheLocalNIK).Inc()
+	s.insights.Record(cacheinsight.TierLocalNIK, list+":"+nik, false)
+
+	record, err := s.BlacklistStore.GetByNIK(ctx, list, nik)
+	if err != nil {
+		return nil, err
+	}
+	s.put(key, generation, record)
+	return record, nil
+}
+
+// Preload caches the GetByNIK result of a NIK on a list ahead of its first
+// lookup, which isn't counted as one
+func (s *CachedBlacklistStore) Preload(ctx context.Context, list, nik string) error {
+	key := nikKey{tenant: s.tenancy.Owner(ctx, list), list: list, nik: nik}
+	s.mu.RLock()
+	generation := s.generation
+	s.mu.RUnlock()
+
+	record, err := s.BlacklistStore.GetByNIK(ctx, list, nik)
+	if err != nil {
+		return err
+	}
+	s.put(key, generation, record)
+	return nil
+}
+
+// put caches a lookup read at generation, unless the cache was invalidated
+// since
+func (s *CachedBlacklistStore) put(key nikKey, generation uint64, record *BlacklistRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation != generation {
+		return
+	}
+	if len(s.entries) >= s.maxEntries {
+		s.evict()
+	}
+	s.entries[key] = cachedLookup{record: copyRecord(record), expires: time.Now().Add(s.ttl)}
+	s.tenants[key.tenant] = true
+}
+
+// Create inserts a new blacklist record and evicts its NIK
+func (s *CachedBlacklistStore) Create(ctx context.Context, record *BlacklistRecord) error {
+	defer s.Invalidate(record.NIK)
+	return s.BlacklistStore.Create(ctx, record)
+}
+
+// Update modifies a blacklist record and evicts its NIK
+func (s *CachedBlacklistStore) Update(ctx context.Context, record *BlacklistRecord) error {
+	defer s.Invalidate(record.NIK)
+	return s.BlacklistStore.Update(ctx, record)
+}
+
+// Delete removes a blacklist record and evicts its NIK
+func (s *CachedBlacklistStore) Delete(ctx context.Context, list, nik string) error {
+	defer s.Invalidate(nik)
+	return s.BlacklistStore.Delete(ctx, list, nik)
+}
+
+// Restore reverses the soft deletion of a blacklist record and evicts its NIK
+func (s *CachedBlacklistStore) Restore(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	defer s.Invalidate(nik)
+	return s.BlacklistStore.Restore(ctx, list, nik)
+}
+
+// Expire deletes the temporary records past their expiry and evicts their NIKs
+func (s *CachedBlacklistStore) Expire(ctx context.Context) ([]*BlacklistRecord, error) {
+	records, err := s.BlacklistStore.Expire(ctx)
+	if len(records) > 0 {
+		niks := make([]string, 0, len(records))
+		for _, record := range records {
+			niks = append(niks, record.NIK)
+		}
+		s.Invalidate(niks...)
+	}
+	return records, err
+}
+
+// ApplyChangeSet writes a change set and evicts every NIK it touches
+func (s *CachedBlacklistStore) ApplyChangeSet(ctx context.Context, cs *ChangeSet) error {
+	niks := make([]string, 0, len(cs.Added)+len(cs.Updated)+len(cs.Deleted))
+	for _, record := range cs.Added {
+		niks = append(niks, record.NIK)
+	}
+	for _, record := range cs.Updated {
+		niks = append(niks, record.NIK)
+	}
+	niks = append(niks, cs.Deleted...)
+
+	defer s.Invalidate(niks...)
+	return s.BlacklistStore.ApplyChangeSet(ctx, cs)
+}
+
+// Invalidate evicts the given NIKs on every list of every tenant, or the
+// whole cache when none are given
+func (s *CachedBlacklistStore) Invalidate(niks ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	if len(niks) == 0 {
+		s.entries = make(map[nikKey]cachedLookup)
+		s.tenants = make(map[string]bool)
+		return
+	}
+	for _, nik := range niks {
+		for tenant := range s.tenants {
+			for _, list := range lists.All {
+				delete(s.entries, nikKey{tenant: tenant, list: list, nik: nik})
+			}
+		}
+	}
+}
+
+// Len returns the number of cached lookups, expired ones included until
+// they are evicted
+func (s *CachedBlacklistStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// evict makes room for a new entry, dropping expired entries first and an
+// arbitrary one if none have expired. Callers must hold mu.
+func (s *CachedBlacklistStore) evict() {
+	now := time.Now()
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+	for key := range s.entries {
+		if len(s.entries) < s.maxEntries {
+			break
+		}
+		delete(s.entries, key)
+	}
+}
+
+// copyRecord keeps callers from mutating cached records
+func copyRecord(record *BlacklistRecord) *BlacklistRecord {
+	if record == nil {
+		return nil
+	}
+	c := *record
+	return &c
+}
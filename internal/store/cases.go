@@ -0,0 +1,233 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikcrypt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Review case statuses
+const (
+	CaseOpen      = "open"
+	CaseCleared   = "cleared"
+	CaseConfirmed = "confirmed"
+)
+
+var (
+	// ErrCaseNotFound is returned when a review case does not exist
+	ErrCaseNotFound = errors.New("review case not found")
+	// ErrCaseOpen is returned when reopening a case while the subject has
+	// another open case for the same record
+	ErrCaseOpen = errors.New("subject already has an open case for the record")
+)
+
+// ReviewCase is a positive production check awaiting or past review. It keeps
+// a snapshot of the subject and the record the subject matched.
+type ReviewCase struct {
+	ID     int64  `db:"id" json:"id"`
+	Tenant string `db:"tenant_id" json:"-"`
+	// SubjectHash identifies the subject as its decision events do
+	SubjectHash string `db:"subject_hash" json:"subject_hash"`
+	Name        string `db:"name" json:"name"`
+	NIK         string `db:"nik" json:"nik,omitempty"`
+	// NIKEncrypted is NIK encrypted under the NIK keys, which is all a case
+	// opened with keys stores of it
+	NIKEncrypted string     `db:"nik_encrypted" json:"-"`
+	BirthPlace   string     `db:"birth_place" json:"birth_place,omitempty"`
+	BirthDate    *time.Time `db:"birth_date" json:"birth_date,omitempty"`
+	// Tokenized means Name and NIK hold tokens from the tokenization service
+	Tokenized  bool    `db:"tokenized" json:"-"`
+	List       string  `db:"list_type" json:"list"`
+	RecordID   int64   `db:"record_id" json:"record_id"`
+	MatchType  string  `db:"match_type" json:"match_type"`
+	Score      float64 `db:"score" json:"score"`
+	Confidence float64 `db:"confidence" json:"confidence"`
+	// RequestID is the request of the check that opened the case
+	RequestID string  `db:"request_id" json:"request_id,omitempty"`
+	Status    string  `db:"status" json:"status"`
+	Assignee  *string `db:"assignee" json:"assignee,omitempty"`
+	Notes     string  `db:"notes" json:"notes,omitempty"`
+	// Hits counts the checks of the subject that matched the record while
+	// the case was open
+	Hits      int        `db:"hits" json:"hits"`
+	OpenedAt  time.Time  `db:"opened_at" json:"opened_at"`
+	LastHitAt time.Time  `db:"last_hit_at" json:"last_hit_at"`
+	UpdatedBy *string    `db:"updated_by" json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+}
+
+// CaseFilter narrows a review case listing. Zero fields don't filter.
+type CaseFilter struct {
+	Status   string
+	Assignee string
+	// After lists the cases after the case with this ID
+	After int64
+	Limit int
+}
+
+// CaseUpdate changes a review case. Nil fields are left as they are; an
+// empty assignee unassigns the case.
+type CaseUpdate struct {
+	Status   *string
+	Assignee *string
+	Notes    *string
+}
+
+// CaseStore defines the interface for review case access
+type CaseStore interface {
+	// Open opens a case for the caller in ctx, or counts a hit on the open
+	// case of the same subject and record, and sets the case's ID
+	Open(ctx context.Context, c *ReviewCase) error
+	// List returns the cases matching filter, oldest first
+	List(ctx context.Context, filter CaseFilter) ([]*ReviewCase, error)
+	Get(ctx context.Context, id int64) (*ReviewCase, error)
+	// Update changes a case as the actor in ctx
+	Update(ctx context.Context, id int64, update CaseUpdate) (*ReviewCase, error)
+}
+
+// caseStore implements CaseStore
+type caseStore struct {
+	db *sqlx.DB
+	// keys encrypt the NIKs of cases like those of records
+	keys    *nikcrypt.Keys
+	tenancy *Tenancy
+}
+
+// NewCaseStore creates a new review case store. Cases are scoped to the
+// caller's tenant.
+func NewCaseStore(db *sqlx.DB, keys *nikcrypt.Keys, tenancy *Tenancy) CaseStore {
+	return &caseStore{db: db, keys: keys, tenancy: tenancy}
+}
+
+// caseColumns are the columns of a review case
+const caseColumns = `id, tenant_id, subject_hash, name, nik, nik_encrypted, birth_place, birth_date, tokenized, list_type, record_id, match_type, score, confidence, request_id, status, assignee, notes, hits, opened_at, last_hit_at, updated_by, updated_at`
+
+// Open inserts a case under the caller's tenant. A repeated hit refreshes the
+// snapshot and match of the open case, keeping its review state.
+func (s *caseStore) Open(ctx context.Context, c *ReviewCase) error {
+	defer metrics.ObserveQuery("open_case", time.Now())
+
+	c.Tenant = s.tenancy.Caller(ctx)
+	plain, encrypted, _ := protectNIK(s.keys, c.NIK)
+	return s.db.GetContext(ctx, &c.ID, `
+		INSERT INTO review_cases (tenant_id, subject_hash, name, nik, birth_place, birth_date, tokenized, list_type, record_id, match_type, score, confidence, request_id, nik_encrypted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (tenant_id, subject_hash, record_id) WHERE status = 'open' DO UPDATE SET
+			name = EXCLUDED.name,
+			nik = EXCLUDED.nik,
+			nik_encrypted = EXCLUDED.nik_encrypted,
+			birth_place = EXCLUDED.birth_place,
+			birth_date = EXCLUDED.birth_date,
+			tokenized = EXCLUDED.tokenized,
+			match_type = EXCLUDED.match_type,
+			score = EXCLUDED.score,
+			confidence = EXCLUDED.confidence,
+			hits = review_cases.hits + 1,
+			last_hit_at = CURRENT_TIMESTAMP
+		RETURNING id
+	`, c.Tenant, c.SubjectHash, c.Name, plain, c.BirthPlace, c.BirthDate, c.Tokenized,
+		c.List, c.RecordID, c.MatchType, c.Score, c.Confidence, c.RequestID, encrypted)
+}
+
+// List returns a page of the caller's cases
+func (s *caseStore) List(ctx context.Context, filter CaseFilter) ([]*ReviewCase, error) {
+	defer metrics.ObserveQuery("list_cases", time.Now())
+
+	query := `
+		SELECT ` + caseColumns + `
+		FROM review_cases
+		WHERE tenant_id = ? AND id > ?`
+	args := []interface{}{s.tenancy.Caller(ctx), filter.After}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.Assignee != "" {
+		query += ` AND assignee = ?`
+		args = append(args, filter.Assignee)
+	}
+	query += ` ORDER BY id LIMIT ?`
+	args = append(args, filter.Limit)
+
+	var cases []*ReviewCase
+	if err := s.db.SelectContext(ctx, &cases, s.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	if err := s.reveal(cases...); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// Get retrieves a case of the caller's tenant by ID
+func (s *caseStore) Get(ctx context.Context, id int64) (*ReviewCase, error) {
+	defer metrics.ObserveQuery("get_case", time.Now())
+
+	var c ReviewCase
+	err := s.db.GetContext(ctx, &c, `
+		SELECT `+caseColumns+`
+		FROM review_cases
+		WHERE id = $1 AND tenant_id = $2
+	`, id, s.tenancy.Caller(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Update changes a case of the caller's tenant. Reopening a case fails with
+// ErrCaseOpen while the subject has another open case for the record.
+func (s *caseStore) Update(ctx context.Context, id int64, update CaseUpdate) (*ReviewCase, error) {
+	defer metrics.ObserveQuery("update_case", time.Now())
+
+	var c ReviewCase
+	err := s.db.GetContext(ctx, &c, `
+		UPDATE review_cases SET
+			status = COALESCE($3, status),
+			assignee = CASE WHEN $4::text IS NULL THEN assignee ELSE NULLIF($4, '') END,
+			notes = COALESCE($5, notes),
+			updated_by = $6,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND tenant_id = $2
+		RETURNING `+caseColumns,
+		id, s.tenancy.Caller(ctx), update.Status, update.Assignee, update.Notes, Actor(ctx))
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrCaseNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == uniqueViolation:
+		return nil, ErrCaseOpen
+	case err != nil:
+		return nil, err
+	}
+	if err := s.reveal(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// reveal fills in the NIK of cases that store it encrypted
+func (s *caseStore) reveal(cases ...*ReviewCase) error {
+	for _, c := range cases {
+		nik, err := revealNIK(s.keys, c.NIK, c.NIKEncrypted)
+		if err != nil {
+			return fmt.Errorf("review case %d: %w", c.ID, err)
+		}
+		c.NIK = nik
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrCertMappingNotFound is returned when a client certificate mapping does not exist
+var ErrCertMappingNotFound = errors.New("client certificate mapping not found")
+
+// CertMapping maps a client certificate field value to an API identity
+type CertMapping struct {
+	ID        int64          `db:"id"`
+	Field     string         `db:"field"`
+	Value     string         `db:"value"`
+	Subject   string         `db:"subject"`
+	Tenant    string         `db:"tenant"`
+	Roles     pq.StringArray `db:"roles"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+// CertMappingStore defines the interface for client certificate mapping access
+type CertMappingStore interface {
+	List(ctx context.Context) ([]*CertMapping, error)
+	Create(ctx context.Context, mapping *CertMapping) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// certMappingStore implements CertMappingStore
+type certMappingStore struct {
+	db *sqlx.DB
+}
+
+// NewCertMappingStore creates a new client certificate mapping store
+func NewCertMappingStore(db *sqlx.DB) CertMappingStore {
+	return &certMappingStore{db: db}
+}
+
+// List retrieves all client certificate mappings
+func (s *certMappingStore) List(ctx context.Context) ([]*CertMapping, error) {
+	var mappings []*CertMapping
+	err := s.db.SelectContext(ctx, &mappings, `
+		SELECT id, field, value, subject, tenant, roles, created_at
+		FROM client_cert_mappings
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// Create inserts a client certificate mapping
+func (s *certMappingStore) Create(ctx context.Context, mapping *CertMapping) error {
+	if mapping.Roles == nil {
+		mapping.Roles = pq.StringArray{}
+	}
+	return s.db.GetContext(ctx, mapping, `
+		INSERT INTO client_cert_mappings (field, value, subject, tenant, roles)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, field, value, subject, tenant, roles, created_at
+	`, mapping.Field, mapping.Value, mapping.Subject, mapping.Tenant, mapping.Roles)
+}
+
+// Delete removes a client certificate mapping
+func (s *certMappingStore) Delete(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM client_cert_mappings
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCertMappingNotFound
+	}
+	return nil
+}
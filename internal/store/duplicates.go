@@ -0,0 +1,619 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikcrypt"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/phonetic"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Duplicate pair statuses
+const (
+	DuplicatePending   = "pending"
+	DuplicateMerged    = "merged"
+	DuplicateDismissed = "dismissed"
+)
+
+// Why a pair of records was taken for duplicates
+const (
+	// DuplicateNIK pairs records whose NIKs have the same digits
+	DuplicateNIK = "nik"
+	// DuplicateNameBirthDate pairs records born the same day with similar names
+	DuplicateNameBirthDate = "name_birth_date"
+)
+
+var (
+	// ErrDuplicateNotFound is returned when a duplicate pair does not exist
+	ErrDuplicateNotFound = errors.New("duplicate pair not found")
+	// ErrDuplicateDecided is returned when deciding a pair that was already merged or dismissed
+	ErrDuplicateDecided = errors.New("duplicate pair already decided")
+	// ErrDuplicateKeep is returned when merging a pair into a record that isn't one of the pair
+	ErrDuplicateKeep = errors.New("keep must be one of the records of the pair")
+	// ErrDuplicateNIKs is returned when merging away a record whose NIK
+	// differs from the kept record's, as that NIK would no longer be
+	// blacklisted. Such pairs, which only similar names and the same birth
+	// date bring together, can only be dismissed.
+	ErrDuplicateNIKs = errors.New("records of the pair have different NIKs")
+)
+
+// DuplicatePair is two live records of a list that likely describe the same
+// person. RecordID is the older of the two.
+type DuplicatePair struct {
+	ID          int64   `db:"id" json:"id"`
+	List        string  `db:"list_type" json:"list"`
+	RecordID    int64   `db:"record_id" json:"record_id"`
+	DuplicateID int64   `db:"duplicate_id" json:"duplicate_id"`
+	Reason      string  `db:"reason" json:"reason"`
+	Similarity  float64 `db:"similarity" json:"similarity"`
+	Status      string  `db:"status" json:"status"`
+	// KeptID is the record a merged pair was merged into
+	KeptID    *int64     `db:"kept_id" json:"kept_id,omitempty"`
+	FoundAt   time.Time  `db:"found_at" json:"found_at"`
+	DecidedBy *string    `db:"decided_by" json:"decided_by,omitempty"`
+	DecidedAt *time.Time `db:"decided_at" json:"decided_at,omitempty"`
+
+	// Record and Duplicate are the records of the pair, with their aliases
+	Record    *BlacklistRecord `db:"-" json:"-"`
+	Duplicate *BlacklistRecord `db:"-" json:"-"`
+}
+
+// Removed returns the record a merged pair removed, nil for other pairs
+func (p *DuplicatePair) Removed() *BlacklistRecord {
+	switch {
+	case p.KeptID == nil:
+		return nil
+	case *p.KeptID == p.RecordID:
+		return p.Duplicate
+	default:
+		return p.Record
+	}
+}
+
+// DuplicateStats sums up the pending duplicates of every tenant
+type DuplicateStats struct {
+	// Pending is how many pairs await review
+	Pending int64 `db:"pending"`
+	// Duplicated is how many live records are in a pending pair
+	Duplicated int64 `db:"duplicated"`
+	// Live is how many live records there are
+	Live int64 `db:"live"`
+}
+
+// DuplicateStore defines the interface for duplicate record access
+type DuplicateStore interface {
+	// Detect records the pairs of every tenant found among the records
+	// changed since the last detection by any replica, every record the
+	// first time, and returns how many new pairs it found for each reason.
+	// It returns nil while another replica is detecting.
+	Detect(ctx context.Context, minSimilarity float64) (map[string]int64, error)
+	// List returns the pairs with status, oldest first. Pending pairs are
+	// only listed while both their records are live.
+	List(ctx context.Context, status string) ([]*DuplicatePair, error)
+	Get(ctx context.Context, id int64) (*DuplicatePair, error)
+	// Merge merges a pending pair into the record keep as the actor in ctx
+	Merge(ctx context.Context, id, keep int64) (*DuplicatePair, error)
+	// Dismiss marks a pending pair as not duplicates as the actor in ctx
+	Dismiss(ctx context.Context, id int64) (*DuplicatePair, error)
+	Stats(ctx context.Context) (*DuplicateStats, error)
+}
+
+// duplicateStore implements DuplicateStore
+type duplicateStore struct {
+	db *sqlx.DB
+	// keys reveal the NIKs of records stored protected
+	keys    *nikcrypt.Keys
+	tenancy *Tenancy
+}
+
+// NewDuplicateStore creates a new duplicate store. Pairs are scoped to the
+// caller's tenant; detection covers every tenant.
+func NewDuplicateStore(db *sqlx.DB, keys *nikcrypt.Keys, tenancy *Tenancy) DuplicateStore {
+	return &duplicateStore{db: db, keys: keys, tenancy: tenancy}
+}
+
+// duplicateColumns are the columns of a duplicate pair
+const duplicateColumns = `id, list_type, record_id, duplicate_id, reason, similarity, status, kept_id, found_at, decided_by, decided_at`
+
+// livePair holds for a record_duplicates row d whose records are both live
+const livePair = `EXISTS (SELECT 1 FROM blacklist a WHERE a.id = d.record_id AND a.deleted_at IS NULL)
+	AND EXISTS (SELECT 1 FROM blacklist b WHERE b.id = d.duplicate_id AND b.deleted_at IS NULL)`
+
+// duplicateQueries find the pairs of live records of the same tenant and
+// list, one of them changed since $1, by reason. Each starts from the records
+// changed since, which idx_blacklist_updated_at_id finds, and looks up their
+// counterparts by index, rather than comparing every record with every other.
+// NIK pairs are found first, so a pair that also has similar names is
+// recorded as a NIK pair. The NIK query compares the NIKs of records storing
+// them in plaintext; those of records storing them protected are compared
+// by detectProtectedNIKs.
+var duplicateQueries = []struct {
+	reason string
+	query  string
+}{
+	{DuplicateNIK, `
+		WITH changed AS (
+			SELECT id, tenant_id, list_type, regexp_replace(nik, '[^0-9]', '', 'g') AS digits
+			FROM blacklist
+			WHERE updated_at >= $1 AND deleted_at IS NULL AND nik_hmac = ''
+		)
+		SELECT DISTINCT c.tenant_id, c.list_type, LEAST(c.id, o.id), GREATEST(c.id, o.id), 'nik', 1
+		FROM changed c
+		JOIN blacklist o ON o.tenant_id = c.tenant_id AND o.list_type = c.list_type AND o.id <> c.id
+			AND o.nik_hmac = '' AND regexp_replace(o.nik, '[^0-9]', '', 'g') = c.digits
+			AND o.deleted_at IS NULL
+		WHERE c.digits <> ''
+	`},
+	// Similar names are found with the % operator, which the trigram index
+	// on name_normalized serves, at the threshold Detect sets
+	{DuplicateNameBirthDate, `
+		WITH changed AS (
+			SELECT id, tenant_id, list_type, birth_date, name_normalized
+			FROM blacklist
+			WHERE updated_at >= $1 AND deleted_at IS NULL
+		)
+		SELECT DISTINCT c.tenant_id, c.list_type, LEAST(c.id, o.id), GREATEST(c.id, o.id), 'name_birth_date',
+			similarity(c.name_normalized, o.name_normalized)
+		FROM changed c
+		JOIN blacklist o ON o.tenant_id = c.tenant_id AND o.list_type = c.list_type AND o.id <> c.id
+			AND o.birth_date = c.birth_date
+			AND o.name_normalized % c.name_normalized
+			AND o.deleted_at IS NULL
+		WHERE c.name_normalized <> '' AND c.birth_date IS NOT NULL
+	`},
+}
+
+// Detect records the duplicate pairs among records changed since the
+// duplicate_detection watermark, which it holds locked and moves to the
+// start of the run when done. Pairs already recorded, whatever their status,
+// are left alone.
+func (s *duplicateStore) Detect(ctx context.Context, minSimilarity float64) (map[string]int64, error) {
+	defer metrics.ObserveQuery("detect_duplicates", time.Now())
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var since time.Time
+	err = tx.GetContext(ctx, &since, `SELECT since FROM duplicate_detection FOR UPDATE SKIP LOCKED`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]int64, len(duplicateQueries))
+
+	// What set_limit sets for the session, for this transaction only, so
+	// pooled connections keep the default threshold
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('pg_trgm.similarity_threshold', $1::text, true)`, minSimilarity); err != nil {
+		return nil, err
+	}
+	for _, q := range duplicateQueries {
+		var n int64
+		err := tx.GetContext(ctx, &n, `
+			WITH found AS (
+				INSERT INTO record_duplicates (tenant_id, list_type, record_id, duplicate_id, reason, similarity)
+				`+q.query+`
+				ON CONFLICT (record_id, duplicate_id) DO NOTHING
+				RETURNING 1
+			)
+			SELECT count(*) FROM found
+		`, since)
+		if err != nil {
+			return nil, err
+		}
+		if q.reason == DuplicateNIK && s.keys != nil {
+			protected, err := s.detectProtectedNIKs(ctx, tx, since)
+			if err != nil {
+				return nil, err
+			}
+			n += protected
+		}
+		found[q.reason] = n
+	}
+	// The next run looks again a little before this one started, by the
+	// database's clock, so records committed while it ran don't slip by
+	if _, err := tx.ExecContext(ctx, `UPDATE duplicate_detection SET since = now() - interval '1 minute'`); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// protectedBatch is how many changed records detectProtectedNIKs reads and
+// looks up at a time
+const protectedBatch = 1000
+
+// protectedNIKPairs records the NIK pairs of the changed records of $1 and
+// $3, given with the keyed hashes of $2 their NIKs may be stored under and
+// the digits of $4 their NIKs have
+const protectedNIKPairs = `
+	WITH found AS (
+		INSERT INTO record_duplicates (tenant_id, list_type, record_id, duplicate_id, reason, similarity)
+		SELECT c.tenant_id, c.list_type, LEAST(c.id, o.id), GREATEST(c.id, o.id), 'nik', 1
+		FROM unnest($1::bigint[], $2::text[]) AS m(id, mac)
+		JOIN blacklist c ON c.id = m.id
+		JOIN blacklist o ON o.tenant_id = c.tenant_id AND o.list_type = c.list_type AND o.id <> c.id
+			AND o.nik_hmac = m.mac
+			AND o.deleted_at IS NULL
+		UNION
+		SELECT c.tenant_id, c.list_type, LEAST(c.id, o.id), GREATEST(c.id, o.id), 'nik', 1
+		FROM unnest($3::bigint[], $4::text[]) AS m(id, digits)
+		JOIN blacklist c ON c.id = m.id
+		JOIN blacklist o ON o.tenant_id = c.tenant_id AND o.list_type = c.list_type AND o.id <> c.id
+			AND o.nik_hmac = '' AND regexp_replace(o.nik, '[^0-9]', '', 'g') = m.digits
+			AND o.deleted_at IS NULL
+		ON CONFLICT (record_id, duplicate_id) DO NOTHING
+		RETURNING 1
+	)
+	SELECT count(*) FROM found`
+
+// detectProtectedNIKs records the NIK pairs of records changed since that
+// the database can only tell apart by keyed hash, and returns how many it
+// found. The NIK of each record changed since is revealed, and the keyed
+// hashes under every key of it and of its digits are looked up among the
+// records storing theirs protected. Protected NIKs are also compared by
+// their digits with records still storing theirs in plaintext.
+func (s *duplicateStore) detectProtectedNIKs(ctx context.Context, tx *sqlx.Tx, since time.Time) (int64, error) {
+	var (
+		found  int64
+		lastID int64
+	)
+	for {
+		var rows []struct {
+			ID           int64  `db:"id"`
+			NIK          string `db:"nik"`
+			NIKEncrypted string `db:"nik_encrypted"`
+		}
+		err := tx.SelectContext(ctx, &rows, `
+			SELECT id, nik, nik_encrypted
+			FROM blacklist
+			WHERE updated_at >= $1 AND deleted_at IS NULL AND (nik <> '' OR nik_hmac <> '') AND id > $2
+			ORDER BY id
+			LIMIT $3
+		`, since, lastID, protectedBatch)
+		if err != nil {
+			return found, err
+		}
+		if len(rows) == 0 {
+			return found, nil
+		}
+		lastID = rows[len(rows)-1].ID
+
+		var (
+			macIDs, digitIDs []int64
+			macs, digits     pq.StringArray
+		)
+		for _, row := range rows {
+			nik, err := revealNIK(s.keys, row.NIK, row.NIKEncrypted)
+			if err != nil {
+				return found, fmt.Errorf("record %d: %w", row.ID, err)
+			}
+			d := nikDigits(nik)
+			if d == "" {
+				continue
+			}
+			for _, mac := range append(s.keys.MACs(nik), s.keys.MACs(d)...) {
+				macIDs = append(macIDs, row.ID)
+				macs = append(macs, mac)
+			}
+			// Plaintext NIKs are compared by their digits in SQL already
+			if row.NIK == "" {
+				digitIDs = append(digitIDs, row.ID)
+				digits = append(digits, d)
+			}
+		}
+		var n int64
+		if err := tx.GetContext(ctx, &n, protectedNIKPairs, pq.Array(macIDs), macs, pq.Array(digitIDs), digits); err != nil {
+			return found, err
+		}
+		found += n
+	}
+}
+
+// List returns the caller's pairs with status, oldest first
+func (s *duplicateStore) List(ctx context.Context, status string) ([]*DuplicatePair, error) {
+	defer metrics.ObserveQuery("list_duplicates", time.Now())
+
+	query := `
+		SELECT ` + duplicateColumns + `
+		FROM record_duplicates d
+		WHERE tenant_id = $1 AND status = $2`
+	if status == DuplicatePending {
+		query += ` AND ` + livePair
+	}
+	var pairs []*DuplicatePair
+	if err := s.db.SelectContext(ctx, &pairs, query+` ORDER BY id`, s.tenancy.Caller(ctx), status); err != nil {
+		return nil, err
+	}
+	if err := loadPairRecords(ctx, s.db, s.keys, pairs); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// Get retrieves a pair by ID
+func (s *duplicateStore) Get(ctx context.Context, id int64) (*DuplicatePair, error) {
+	defer metrics.ObserveQuery("get_duplicate", time.Now())
+
+	pair, err := s.get(ctx, s.db, id, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := loadPairRecords(ctx, s.db, s.keys, []*DuplicatePair{pair}); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// get reads a pair of the caller's tenant with q, locking it with lock
+func (s *duplicateStore) get(ctx context.Context, q sqlx.QueryerContext, id int64, lock string) (*DuplicatePair, error) {
+	var pair DuplicatePair
+	err := sqlx.GetContext(ctx, q, &pair, `
+		SELECT `+duplicateColumns+`
+		FROM record_duplicates
+		WHERE id = $1 AND tenant_id = $2
+		`+lock, id, s.tenancy.Caller(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDuplicateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pair, nil
+}
+
+// Merge merges a pending pair into the record keep. The other record's name
+// and aliases become aliases of the kept record, which takes the other's
+// birth place and birth date where it has none. The other record is then
+// soft-deleted, attributed to the actor in ctx, and its history stays under
+// its NIK; it can be restored like any deleted record. Active whitelist
+// entries for it move to the kept record. It returns ErrRecordNotFound when
+// either record is no longer live, ErrRecordPinned when an open case pins
+// the record to be removed and ErrDuplicateNIKs when the record to be
+// removed has a NIK other than the kept record's, as checks of that NIK
+// would then no longer match.
+func (s *duplicateStore) Merge(ctx context.Context, id, keep int64) (*DuplicatePair, error) {
+	defer metrics.ObserveQuery("merge_duplicate", time.Now())
+
+	var pair *DuplicatePair
+	err := inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		var err error
+		if pair, err = s.get(ctx, tx, id, "FOR UPDATE"); err != nil {
+			return err
+		}
+		if pair.Status != DuplicatePending {
+			return ErrDuplicateDecided
+		}
+		remove := pair.DuplicateID
+		switch keep {
+		case pair.RecordID:
+		case pair.DuplicateID:
+			remove = pair.RecordID
+		default:
+			return ErrDuplicateKeep
+		}
+
+		var records []struct {
+			BlacklistRecord
+			Pinned bool `db:"pinned"`
+		}
+		err = tx.SelectContext(ctx, &records, `
+			SELECT id, nik, nik_encrypted, name, birth_place, birth_date, `+pinnedCondition+` AS pinned
+			FROM blacklist
+			WHERE id = ANY($1) AND deleted_at IS NULL
+			ORDER BY id
+			FOR UPDATE
+		`, pq.Array([]int64{keep, remove}))
+		if err != nil {
+			return err
+		}
+		if len(records) != 2 {
+			return ErrRecordNotFound
+		}
+		kept, removed := &records[0], &records[1]
+		if kept.ID != keep {
+			kept, removed = removed, kept
+		}
+		if err := checkMerge(s.keys, &kept.BlacklistRecord, &removed.BlacklistRecord, removed.Pinned); err != nil {
+			return err
+		}
+
+		if normalize.Name(removed.Name) != normalize.Name(kept.Name) {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO blacklist_aliases (record_id, alias, alias_type, name_phonetic)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (record_id, alias) DO NOTHING
+			`, keep, removed.Name, AliasSpelling, phonetic.Encode(removed.Name))
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO blacklist_aliases (record_id, alias, alias_type, name_phonetic)
+			SELECT $1, alias, alias_type, name_phonetic
+			FROM blacklist_aliases
+			WHERE record_id = $2 AND alias <> $3
+			ON CONFLICT (record_id, alias) DO NOTHING
+		`, keep, remove, kept.Name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE blacklist
+			SET birth_place = CASE WHEN birth_place = '' THEN $2 ELSE birth_place END,
+				birth_date = COALESCE(birth_date, $3),
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, keep, removed.BirthPlace, nullDate(removed.BirthDate))
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE blacklist
+			SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2
+			WHERE id = $1
+		`, remove, Actor(ctx))
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE whitelist
+			SET record_id = $1
+			WHERE record_id = $2 AND revoked_at IS NULL
+		`, keep, remove)
+		if err != nil {
+			return err
+		}
+
+		pair, err = s.decide(ctx, tx, id, DuplicateMerged, &keep)
+		if err != nil {
+			return err
+		}
+		return loadPairRecords(ctx, tx, s.keys, []*DuplicatePair{pair})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Dismiss marks a pending pair as not duplicates
+func (s *duplicateStore) Dismiss(ctx context.Context, id int64) (*DuplicatePair, error) {
+	defer metrics.ObserveQuery("dismiss_duplicate", time.Now())
+
+	pair, err := s.decide(ctx, s.db, id, DuplicateDismissed, nil)
+	if errors.Is(err, ErrDuplicateDecided) {
+		// Tell a pair that doesn't exist from one decided already
+		if _, err := s.get(ctx, s.db, id, ""); err != nil {
+			return nil, err
+		}
+		return nil, ErrDuplicateDecided
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := loadPairRecords(ctx, s.db, s.keys, []*DuplicatePair{pair}); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// decide records the decision on a pending pair of the caller's tenant. Only
+// one decision is ever recorded, however many are made at once.
+func (s *duplicateStore) decide(ctx context.Context, q sqlx.QueryerContext, id int64, status string, kept *int64) (*DuplicatePair, error) {
+	var pair DuplicatePair
+	err := sqlx.GetContext(ctx, q, &pair, `
+		UPDATE record_duplicates
+		SET status = $3, kept_id = $4, decided_by = $5, decided_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND tenant_id = $2 AND status = $6
+		RETURNING `+duplicateColumns,
+		id, s.tenancy.Caller(ctx), status, kept, Actor(ctx), DuplicatePending)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDuplicateDecided
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pair, nil
+}
+
+// checkMerge refuses to merge removed into kept when an open case pins it,
+// or when removed has a NIK other than kept's, as the merge would then
+// unlist it. A removed record without a NIK unlists nobody.
+func checkMerge(keys *nikcrypt.Keys, kept, removed *BlacklistRecord, pinned bool) error {
+	if pinned {
+		return ErrRecordPinned
+	}
+	if err := revealRecords(keys, kept, removed); err != nil {
+		return err
+	}
+	if removed.NIK != "" && nikDigits(removed.NIK) != nikDigits(kept.NIK) {
+		return ErrDuplicateNIKs
+	}
+	return nil
+}
+
+// nikDigits returns the digits of a NIK, which two NIKs written with
+// different separators share
+func nikDigits(nik string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, nik)
+}
+
+// Stats sums up the pending pairs of every tenant whose records are live
+func (s *duplicateStore) Stats(ctx context.Context) (*DuplicateStats, error) {
+	defer metrics.ObserveQuery("duplicate_stats", time.Now())
+
+	var stats DuplicateStats
+	err := s.db.GetContext(ctx, &stats, `
+		WITH pending AS (
+			SELECT record_id, duplicate_id
+			FROM record_duplicates d
+			WHERE status = $1 AND `+livePair+`
+		)
+		SELECT
+			(SELECT count(*) FROM pending) AS pending,
+			(SELECT count(*) FROM (SELECT record_id FROM pending UNION SELECT duplicate_id FROM pending) ids) AS duplicated,
+			(SELECT count(*) FROM blacklist WHERE deleted_at IS NULL) AS live
+	`, DuplicatePending)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// loadPairRecords fills in the records of pairs with their aliases, deleted
+// or not
+func loadPairRecords(ctx context.Context, q sqlx.QueryerContext, keys *nikcrypt.Keys, pairs []*DuplicatePair) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, 2*len(pairs))
+	for _, pair := range pairs {
+		ids = append(ids, pair.RecordID, pair.DuplicateID)
+	}
+	var records []*BlacklistRecord
+	err := sqlx.SelectContext(ctx, q, &records, `
+		SELECT id, tenant_id, list_type, nik, nik_encrypted, name, birth_place, birth_date, reason, reason_code, reason_params, source,
+			created_at, updated_at, deleted_at, deleted_by, expires_at
+		FROM blacklist
+		WHERE id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	if err := revealRecords(keys, records...); err != nil {
+		return err
+	}
+	if err := loadAliases(ctx, q, records); err != nil {
+		return err
+	}
+	byID := make(map[int64]*BlacklistRecord, len(records))
+	for _, record := range records {
+		byID[record.ID] = record
+	}
+	for _, pair := range pairs {
+		pair.Record = byID[pair.RecordID]
+		pair.Duplicate = byID[pair.DuplicateID]
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"blacklist-check/internal/nikcrypt"
+)
+
+func TestCheckMerge(t *testing.T) {
+	keys, err := nikcrypt.New(
+		base64.StdEncoding.EncodeToString([]byte(strings.Repeat("e", 32))),
+		base64.StdEncoding.EncodeToString([]byte(strings.Repeat("h", 32))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const nik = "3171011505900001"
+	tests := []struct {
+		name    string
+		keys    *nikcrypt.Keys
+		kept    BlacklistRecord
+		removed BlacklistRecord
+		pinned  bool
+		wantErr error
+	}{
+		{
+			name:    "same NIK",
+			kept:    BlacklistRecord{ID: 1, NIK: nik},
+			removed: BlacklistRecord{ID: 2, NIK: nik},
+		},
+		{
+			name:    "same NIK written with separators",
+			kept:    BlacklistRecord{ID: 1, NIK: nik},
+			removed: BlacklistRecord{ID: 2, NIK: "31.7101.150590.0001"},
+		},
+		{
+			name:    "same NIK stored encrypted",
+			keys:    keys,
+			kept:    BlacklistRecord{ID: 1, NIK: nik},
+			removed: BlacklistRecord{ID: 2, NIKEncrypted: keys.Encrypt(nik)},
+		},
+		{
+			name:    "removed record without NIK",
+			kept:    BlacklistRecord{ID: 1, NIK: nik},
+			removed: BlacklistRecord{ID: 2},
+		},
+		{
+			name:    "kept record without NIK",
+			kept:    BlacklistRecord{ID: 1},
+			removed: BlacklistRecord{ID: 2, NIK: nik},
+			wantErr: ErrDuplicateNIKs,
+		},
+		{
+			name:    "different NIKs",
+			kept:    BlacklistRecord{ID: 1, NIK: nik},
+			removed: BlacklistRecord{ID: 2, NIK: "3171011505900002"},
+			wantErr: ErrDuplicateNIKs,
+		},
+		{
+			name:    "different NIK stored encrypted",
+			keys:    keys,
+			kept:    BlacklistRecord{ID: 1, NIK: nik},
+			removed: BlacklistRecord{ID: 2, NIKEncrypted: keys.Encrypt("3171011505900002")},
+			wantErr: ErrDuplicateNIKs,
+		},
+		{
+			name:    "encrypted NIK without keys",
+			kept:    BlacklistRecord{ID: 1, NIK: nik},
+			removed: BlacklistRecord{ID: 2, NIKEncrypted: keys.Encrypt(nik)},
+			wantErr: ErrNIKKeys,
+		},
+		{
+			name:    "pinned",
+			kept:    BlacklistRecord{ID: 1, NIK: nik},
+			removed: BlacklistRecord{ID: 2, NIK: nik},
+			pinned:  true,
+			wantErr: ErrRecordPinned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMerge(tt.keys, &tt.kept, &tt.removed, tt.pinned)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkMerge() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/normalize"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// EntityRecord represents a blacklisted company in the database
+type EntityRecord struct {
+	ID                 int64     `db:"id"`
+	LegalName          string    `db:"legal_name"`
+	NameNormalized     string    `db:"name_normalized"`
+	RegistrationNumber string    `db:"registration_number"`
+	Country            string    `db:"country"`
+	Reason             string    `db:"reason"`
+	Source             string    `db:"source"`
+	CreatedAt          time.Time `db:"created_at"`
+	UpdatedAt          time.Time `db:"updated_at"`
+	Similarity         float64   `db:"similarity"`
+}
+
+// EntityStore defines the interface for entity blacklist data access
+type EntityStore interface {
+	GetByRegistration(ctx context.Context, country, number string) (*EntityRecord, error)
+	GetByFuzzyName(ctx context.Context, name string, country *string) ([]*EntityRecord, error)
+	Create(ctx context.Context, record *EntityRecord) error
+}
+
+// entityStore implements EntityStore. Entities belong to the caller's tenant.
+type entityStore struct {
+	db      *sqlx.DB
+	tenancy *Tenancy
+}
+
+// NewEntityStore creates a new entity store
+func NewEntityStore(db *sqlx.DB, tenancy *Tenancy) EntityStore {
+	return &entityStore{db: db, tenancy: tenancy}
+}
+
+// GetByRegistration retrieves an entity by its registration number within a country
+func (s *entityStore) GetByRegistration(ctx context.Context, country, number string) (*EntityRecord, error) {
+	defer metrics.ObserveQuery("entity_by_registration", time.Now())
+
+	var record EntityRecord
+	err := s.db.GetContext(ctx, &record, `
+		SELECT id, legal_name, name_normalized, registration_number, country, reason, source, created_at, updated_at
+		FROM entity_blacklist
+		WHERE tenant_id = $3 AND country = $1 AND registration_number = $2
+	`, country, normalize.RegistrationNumber(number), s.tenancy.Caller(ctx))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetByFuzzyName finds entities whose normalized legal name is similar to
+// name, ignoring legal forms such as PT, CV or Tbk
+func (s *entityStore) GetByFuzzyName(ctx context.Context, name string, country *string) ([]*EntityRecord, error) {
+	defer metrics.ObserveQuery("entity_fuzzy_name", time.Now())
+
+	var records []*EntityRecord
+
+	// Company names share fewer incidental trigrams than personal names, so
+	// the threshold is stricter than for individuals
+	const minSimilarity = 0.4
+
+	query := `
+		SELECT id, legal_name, name_normalized, registration_number, country, reason, source, created_at, updated_at,
+			similarity(name_normalized, $1) AS similarity
+		FROM entity_blacklist
+		WHERE tenant_id = $3 AND similarity(name_normalized, $1) > $2
+	`
+	args := []interface{}{normalize.EntityName(name), minSimilarity, s.tenancy.Caller(ctx)}
+	if country != nil {
+		query += ` AND country = $4`
+		args = append(args, *country)
+	}
+	query += ` ORDER BY similarity DESC LIMIT 5`
+
+	if err := s.db.SelectContext(ctx, &records, query, args...); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Create inserts a new entity, deriving its normalized name and registration number
+func (s *entityStore) Create(ctx context.Context, record *EntityRecord) error {
+	defer metrics.ObserveQuery("entity_create", time.Now())
+
+	if record.Source == "" {
+		record.Source = "internal"
+	}
+	return s.db.GetContext(ctx, record, `
+		INSERT INTO entity_blacklist (legal_name, name_normalized, registration_number, country, reason, source, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, legal_name, name_normalized, registration_number, country, reason, source, created_at, updated_at
+	`, record.LegalName, normalize.EntityName(record.LegalName), normalize.RegistrationNumber(record.RegistrationNumber),
+		record.Country, record.Reason, record.Source, s.tenancy.Caller(ctx))
+}
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"blacklist-check/internal/database"
+	"blacklist-check/internal/nikcrypt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// NewBlacklistStoreFor creates the blacklist store for the driver db was
+// opened with. The other stores, NIK encryption, tenant isolation and read
+// replicas need Postgres; the other drivers ignore tenancy.
+func NewBlacklistStoreFor(db *sqlx.DB, replicas *database.Replicas, keys *nikcrypt.Keys, tenancy *Tenancy) (BlacklistStore, error) {
+	if keys != nil && db.DriverName() != "postgres" {
+		return nil, errors.New("NIK encryption needs the postgres driver")
+	}
+	switch db.DriverName() {
+	case "postgres":
+		return NewReplicatedBlacklistStore(db, replicas, keys, tenancy), nil
+	case "mysql":
+		return NewMySQLBlacklistStore(db), nil
+	case "sqlite3":
+		return NewSQLiteBlacklistStore(db), nil
+	}
+	return nil, fmt.Errorf("no blacklist store for driver %q", db.DriverName())
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"blacklist-check/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CheckHistoryEntry is a recorded production screening decision
+type CheckHistoryEntry struct {
+	ID          int64          `db:"id"`
+	Tenant      string         `db:"tenant_id"`
+	Name        string         `db:"name"`
+	NIK         string         `db:"nik"`
+	BirthPlace  string         `db:"birth_place"`
+	BirthDate   *time.Time     `db:"birth_date"`
+	Blacklisted bool           `db:"blacklisted"`
+	MatchType   string         `db:"match_type"`
+	Lists       pq.StringArray `db:"lists"`
+	// Tokenized means Name and NIK hold tokens from the tokenization service
+	Tokenized bool `db:"tokenized"`
+	// PolicyVersion is the policy snapshot the decision was made under
+	PolicyVersion string    `db:"policy_version"`
+	CheckedAt     time.Time `db:"checked_at"`
+}
+
+// CheckHistoryStore defines the interface for check history data access
+type CheckHistoryStore interface {
+	Record(ctx context.Context, entry *CheckHistoryEntry) error
+	Sample(ctx context.Context, since time.Time, limit int, fn func(*CheckHistoryEntry) error) error
+	Prune(ctx context.Context, before time.Time) (int64, error)
+	Recent(ctx context.Context, limit int) ([]*CheckHistoryEntry, error)
+}
+
+// checkHistoryStore implements CheckHistoryStore
+type checkHistoryStore struct {
+	db      *sqlx.DB
+	tenancy *Tenancy
+}
+
+// NewCheckHistoryStore creates a new check history store
+func NewCheckHistoryStore(db *sqlx.DB, tenancy *Tenancy) CheckHistoryStore {
+	return &checkHistoryStore{db: db, tenancy: tenancy}
+}
+
+// Record stores a screening decision made for the caller's tenant
+func (s *checkHistoryStore) Record(ctx context.Context, entry *CheckHistoryEntry) error {
+	defer metrics.ObserveQuery("history_record", time.Now())
+
+	entry.Tenant = s.tenancy.Caller(ctx)
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO check_history (name, nik, birth_place, birth_date, blacklisted, match_type, lists, tokenized, policy_version, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, entry.Name, entry.NIK, entry.BirthPlace, entry.BirthDate, entry.Blacklisted, entry.MatchType, entry.Lists, entry.Tokenized, entry.PolicyVersion, entry.Tenant)
+	return err
+}
+
+// Sample streams decisions made since the given time for every tenant, in
+// the order they were made, or a random selection of at most limit of them
+// when limit is positive
+func (s *checkHistoryStore) Sample(ctx context.Context, since time.Time, limit int, fn func(*CheckHistoryEntry) error) error {
+	query := `
+		SELECT id, tenant_id, name, nik, birth_place, birth_date, blacklisted, match_type, lists, tokenized, policy_version, checked_at
+		FROM check_history
+		WHERE checked_at >= $1
+	`
+	args := []interface{}{since}
+	if limit > 0 {
+		query += ` ORDER BY random() LIMIT $2`
+		args = append(args, limit)
+	} else {
+		query += ` ORDER BY id`
+	}
+
+	rows, err := s.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry CheckHistoryEntry
+		if err := rows.StructScan(&entry); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Prune deletes decisions made before the given time and returns how many were removed
+func (s *checkHistoryStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	defer metrics.ObserveQuery("history_prune", time.Now())
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM check_history WHERE checked_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Recent returns the latest decisions made for the caller's tenant, newest first
+func (s *checkHistoryStore) Recent(ctx context.Context, limit int) ([]*CheckHistoryEntry, error) {
+	defer metrics.ObserveQuery("history_recent", time.Now())
+
+	var entries []*CheckHistoryEntry
+	err := s.db.SelectContext(ctx, &entries, `
+		SELECT id, tenant_id, name, nik, birth_place, birth_date, blacklisted, match_type, lists, tokenized, policy_version, checked_at
+		FROM check_history
+		WHERE tenant_id = $2
+		ORDER BY id DESC
+		LIMIT $1
+	`, limit, s.tenancy.Caller(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikcrypt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// IdempotentResponse is the response stored for an idempotency key. Status
+// is nil while the first request with the key is still being served.
+type IdempotentResponse struct {
+	Caller          string     `db:"caller"`
+	Key             string     `db:"idempotency_key"`
+	RequestHash     string     `db:"request_hash"`
+	Status          *int       `db:"status"`
+	ContentType     string     `db:"content_type"`
+	ContentLanguage string     `db:"content_language"`
+	Location        string     `db:"location"`
+	Body            []byte     `db:"body"`
+	BodyEncrypted   string     `db:"body_encrypted"`
+	CreatedAt       time.Time  `db:"created_at"`
+	CompletedAt     *time.Time `db:"completed_at"`
+	ExpiresAt       time.Time  `db:"expires_at"`
+}
+
+// IdempotencyStore defines the interface for idempotency key access
+type IdempotencyStore interface {
+	Claim(ctx context.Context, caller, key, requestHash string, ttl, claimTimeout time.Duration) (*IdempotentResponse, error)
+	Complete(ctx context.Context, response *IdempotentResponse) error
+	Release(ctx context.Context, caller, key string) error
+	Prune(ctx context.Context) (int64, error)
+}
+
+// idempotencyStore implements IdempotencyStore
+type idempotencyStore struct {
+	db *sqlx.DB
+	// keys encrypt stored response bodies, which echo the NIKs of their
+	// requests; nil stores them in plaintext
+	keys *nikcrypt.Keys
+}
+
+// NewIdempotencyStore creates a new idempotency key store
+func NewIdempotencyStore(db *sqlx.DB, keys *nikcrypt.Keys) IdempotencyStore {
+	return &idempotencyStore{db: db, keys: keys}
+}
+
+// Claim reserves a caller's key for a request, expiring after ttl. It
+// returns nil when the caller may go ahead, and otherwise the stored
+// response, which is still pending when its Status is nil. Expired keys and
+// pending claims older than claimTimeout, whose server likely died, are
+// taken over.
+func (s *idempotencyStore) Claim(ctx context.Context, caller, key, requestHash string, ttl, claimTimeout time.Duration) (*IdempotentResponse, error) {
+	defer metrics.ObserveQuery("idempotency_claim", time.Now())
+
+	var claimed string
+	err := s.db.GetContext(ctx, &claimed, `
+		INSERT INTO idempotency_keys (caller, idempotency_key, request_hash, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4 * INTERVAL '1 second')
+		ON CONFLICT (caller, idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, expires_at = EXCLUDED.expires_at,
+			created_at = CURRENT_TIMESTAMP, completed_at = NULL, status = NULL,
+			content_type = '', content_language = '', location = '', body = NULL, body_encrypted = ''
+		WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+			OR (idempotency_keys.completed_at IS NULL
+				AND idempotency_keys.created_at <= CURRENT_TIMESTAMP - $5 * INTERVAL '1 second')
+		RETURNING caller
+	`, caller, key, requestHash, ttl.Seconds(), claimTimeout.Seconds())
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var existing IdempotentResponse
+	err = s.db.GetContext(ctx, &existing, `
+		SELECT caller, idempotency_key, request_hash, status, content_type, content_language, location, body,
+			body_encrypted, created_at, completed_at, expires_at
+		FROM idempotency_keys
+		WHERE caller = $1 AND idempotency_key = $2
+	`, caller, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing.BodyEncrypted != "" {
+		if s.keys == nil {
+			return nil, ErrNIKKeys
+		}
+		body, err := s.keys.Decrypt(existing.BodyEncrypted)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting idempotent response: %w", err)
+		}
+		existing.Body = []byte(body)
+	}
+	return &existing, nil
+}
+
+// Complete stores the response to a claimed key, encrypting its body when
+// there are NIK keys
+func (s *idempotencyStore) Complete(ctx context.Context, response *IdempotentResponse) error {
+	defer metrics.ObserveQuery("idempotency_complete", time.Now())
+
+	body, encrypted := response.Body, ""
+	if s.keys != nil && len(body) > 0 {
+		body, encrypted = nil, s.keys.Encrypt(string(response.Body))
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status = $3, content_type = $4, content_language = $5, location = $6, body = $7,
+			body_encrypted = $8, completed_at = CURRENT_TIMESTAMP
+		WHERE caller = $1 AND idempotency_key = $2 AND completed_at IS NULL
+	`, response.Caller, response.Key, response.Status, response.ContentType, response.ContentLanguage,
+		response.Location, body, encrypted)
+	return err
+}
+
+// Release drops a pending claim so the request can be retried
+func (s *idempotencyStore) Release(ctx context.Context, caller, key string) error {
+	defer metrics.ObserveQuery("idempotency_release", time.Now())
+
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys WHERE caller = $1 AND idempotency_key = $2 AND completed_at IS NULL
+	`, caller, key)
+	return err
+}
+
+// Prune deletes expired keys and returns how many were removed
+func (s *idempotencyStore) Prune(ctx context.Context) (int64, error) {
+	defer metrics.ObserveQuery("idempotency_prune", time.Now())
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
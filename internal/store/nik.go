@@ -0,0 +1,67 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"blacklist-check/internal/nikcrypt"
+
+	"github.com/lib/pq"
+)
+
+// ErrNIKKeys is returned when reading a NIK stored encrypted without keys
+var ErrNIKKeys = errors.New("NIK is stored encrypted but no NIK keys are set")
+
+// protectNIK returns what a row stores of nik: without keys its plaintext
+// only, with keys its encryption and keyed hash only. An empty NIK stays empty.
+func protectNIK(keys *nikcrypt.Keys, nik string) (plain, encrypted, mac string) {
+	if keys == nil || nik == "" {
+		return nik, "", ""
+	}
+	return "", keys.Encrypt(nik), keys.MAC(nik)
+}
+
+// nikCondition restricts rows to those of nik, given the table prefix of the
+// columns and the placeholders of the keyed hashes and of the plaintext, in
+// that order, with the arguments to bind them to. A row is found by the NIK's
+// keyed hash under the current or a previous key, or by its plaintext while
+// it isn't protected.
+func nikCondition(keys *nikcrypt.Keys, prefix, macs, plain, nik string) (string, []interface{}) {
+	return "(" + prefix + "nik_hmac = ANY(" + macs + ") OR (" + prefix + "nik_hmac = '' AND " + prefix + "nik = " + plain + "))",
+		[]interface{}{nikMACs(keys, []string{nik}), nik}
+}
+
+// nikMACs returns the keyed hashes of niks under every key, none without keys
+func nikMACs(keys *nikcrypt.Keys, niks []string) pq.StringArray {
+	macs := pq.StringArray{}
+	if keys != nil {
+		for _, nik := range niks {
+			macs = append(macs, keys.MACs(nik)...)
+		}
+	}
+	return macs
+}
+
+// revealNIK returns the NIK a row stores, decrypting it when the row keeps
+// it encrypted only
+func revealNIK(keys *nikcrypt.Keys, plain, encrypted string) (string, error) {
+	if plain != "" || encrypted == "" {
+		return plain, nil
+	}
+	if keys == nil {
+		return "", ErrNIKKeys
+	}
+	return keys.Decrypt(encrypted)
+}
+
+// revealRecords fills in the NIK of records read with their encrypted NIK
+func revealRecords(keys *nikcrypt.Keys, records ...*BlacklistRecord) error {
+	for _, record := range records {
+		nik, err := revealNIK(keys, record.NIK, record.NIKEncrypted)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", record.ID, err)
+		}
+		record.NIK = nik
+	}
+	return nil
+}
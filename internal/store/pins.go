@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikcrypt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrPinNotFound is returned when a pin does not exist or was already released
+	ErrPinNotFound = errors.New("pin not found")
+	// ErrPinExists is returned when a case already pins the record
+	ErrPinExists = errors.New("record already pinned by the case")
+	// ErrRecordPinned is returned when deleting a record an open case pins
+	ErrRecordPinned = errors.New("blacklist record is pinned")
+)
+
+// uniqueViolation is the PostgreSQL error code for a duplicate key
+const uniqueViolation = "23505"
+
+// Pin holds a record in place for an investigation: while it is active the
+// record is neither expired, deleted nor changed by list syncs
+type Pin struct {
+	ID       int64  `db:"id" json:"id"`
+	RecordID int64  `db:"record_id" json:"record_id"`
+	List     string `db:"list_type" json:"list"`
+	NIK      string `db:"nik" json:"nik"`
+	// NIKEncrypted is the record's NIK when it is stored encrypted only
+	NIKEncrypted string     `db:"nik_encrypted" json:"-"`
+	CaseID       string     `db:"case_id" json:"case_id"`
+	Reason       string     `db:"reason" json:"reason,omitempty"`
+	PinnedBy     string     `db:"pinned_by" json:"pinned_by"`
+	PinnedAt     time.Time  `db:"pinned_at" json:"pinned_at"`
+	UnpinnedAt   *time.Time `db:"unpinned_at" json:"unpinned_at,omitempty"`
+	UnpinnedBy   *string    `db:"unpinned_by" json:"unpinned_by,omitempty"`
+}
+
+// PinFilter narrows a pin listing. Zero fields don't filter.
+type PinFilter struct {
+	CaseID string
+	NIK    string
+	// IncludeReleased includes pins that were unpinned or whose case closed
+	IncludeReleased bool
+}
+
+// PinStore defines the interface for record pin access
+type PinStore interface {
+	// Pin pins the live record with the NIK on a list for a case, attributed
+	// to the actor in ctx
+	Pin(ctx context.Context, list, nik, caseID, reason string) (*Pin, error)
+	// Unpin releases an active pin, attributed to the actor in ctx
+	Unpin(ctx context.Context, id int64) (*Pin, error)
+	// ReleaseCase releases every active pin of a case, attributed to the actor in ctx
+	ReleaseCase(ctx context.Context, caseID string) ([]*Pin, error)
+	List(ctx context.Context, filter PinFilter) ([]*Pin, error)
+}
+
+// pinStore implements PinStore. Callers pin and release the records of
+// their own tenant only, so a tenant can't hold back a shared list's sync.
+type pinStore struct {
+	db *sqlx.DB
+	// keys find and reveal the NIKs of records stored protected
+	keys    *nikcrypt.Keys
+	tenancy *Tenancy
+}
+
+// NewPinStore creates a new record pin store
+func NewPinStore(db *sqlx.DB, keys *nikcrypt.Keys, tenancy *Tenancy) PinStore {
+	return &pinStore{db: db, keys: keys, tenancy: tenancy}
+}
+
+// pinColumns are the columns of a pin p and its record b
+const pinColumns = `p.id, p.record_id, b.list_type, b.nik, b.nik_encrypted, p.case_id, p.reason, p.pinned_by, p.pinned_at, p.unpinned_at, p.unpinned_by`
+
+// pinnedCondition holds for a blacklist row an open case pins
+const pinnedCondition = `EXISTS (SELECT 1 FROM record_pins p WHERE p.record_id = blacklist.id AND p.unpinned_at IS NULL)`
+
+// Pin pins a record for a case. It returns ErrRecordNotFound when the list
+// has no live record with the NIK, ErrPinExists when the case already pins it
+// and ErrSharedList when a caller with a tenant pins a record of a shared list.
+func (s *pinStore) Pin(ctx context.Context, list, nik, caseID, reason string) (*Pin, error) {
+	defer metrics.ObserveQuery("pin", time.Now())
+
+	tenant, err := s.tenancy.writer(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	condition, nikArgs := nikCondition(s.keys, "", "$6", "$7", nik)
+	var pin Pin
+	err = s.db.GetContext(ctx, &pin, `
+		WITH p AS (
+			INSERT INTO record_pins (record_id, case_id, reason, pinned_by)
+			SELECT id, $3, $4, $5
+			FROM blacklist
+			WHERE list_type = $1 AND tenant_id = $2 AND `+condition+` AND deleted_at IS NULL
+			RETURNING *
+		)
+		SELECT `+pinColumns+`
+		FROM p JOIN blacklist b ON b.id = p.record_id
+	`, append([]interface{}{list, tenant, caseID, reason, Actor(ctx)}, nikArgs...)...)
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == uniqueViolation:
+		return nil, ErrPinExists
+	case err != nil:
+		return nil, err
+	}
+	pin.NIK = nik
+	return &pin, nil
+}
+
+// Unpin releases an active pin
+func (s *pinStore) Unpin(ctx context.Context, id int64) (*Pin, error) {
+	defer metrics.ObserveQuery("unpin", time.Now())
+
+	var pin Pin
+	err := s.db.GetContext(ctx, &pin, `
+		WITH p AS (
+			UPDATE record_pins
+			SET unpinned_at = CURRENT_TIMESTAMP, unpinned_by = $2
+			WHERE id = $1 AND unpinned_at IS NULL
+				AND record_id IN (SELECT id FROM blacklist WHERE tenant_id = $3)
+			RETURNING *
+		)
+		SELECT `+pinColumns+`
+		FROM p JOIN blacklist b ON b.id = p.record_id
+	`, id, Actor(ctx), s.tenancy.Caller(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPinNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(&pin); err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// ReleaseCase releases the active pins of a case and returns them, none when
+// the case pins nothing
+func (s *pinStore) ReleaseCase(ctx context.Context, caseID string) ([]*Pin, error) {
+	defer metrics.ObserveQuery("release_case", time.Now())
+
+	var pins []*Pin
+	err := s.db.SelectContext(ctx, &pins, `
+		WITH p AS (
+			UPDATE record_pins
+			SET unpinned_at = CURRENT_TIMESTAMP, unpinned_by = $2
+			WHERE case_id = $1 AND unpinned_at IS NULL
+				AND record_id IN (SELECT id FROM blacklist WHERE tenant_id = $3)
+			RETURNING *
+		)
+		SELECT `+pinColumns+`
+		FROM p JOIN blacklist b ON b.id = p.record_id
+		ORDER BY p.id
+	`, caseID, Actor(ctx), s.tenancy.Caller(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(pins...); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+// List returns the pins matching filter, newest first
+func (s *pinStore) List(ctx context.Context, filter PinFilter) ([]*Pin, error) {
+	defer metrics.ObserveQuery("list_pins", time.Now())
+
+	// The NIK filter binds the plaintext to $3 and the keyed hashes to $5
+	condition, _ := nikCondition(s.keys, "b.", "$5", "$3", filter.NIK)
+	var pins []*Pin
+	err := s.db.SelectContext(ctx, &pins, `
+		SELECT `+pinColumns+`
+		FROM record_pins p JOIN blacklist b ON b.id = p.record_id
+		WHERE b.tenant_id = $4 AND ($1 OR p.unpinned_at IS NULL)
+			AND ($2 = '' OR p.case_id = $2)
+			AND ($3 = '' OR `+condition+`)
+		ORDER BY p.id DESC
+	`, filter.IncludeReleased, filter.CaseID, filter.NIK, s.tenancy.Caller(ctx), nikMACs(s.keys, []string{filter.NIK}))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(pins...); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+// reveal fills in the NIK of pins whose record stores it encrypted
+func (s *pinStore) reveal(pins ...*Pin) error {
+	for _, pin := range pins {
+		nik, err := revealNIK(s.keys, pin.NIK, pin.NIKEncrypted)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", pin.RecordID, err)
+		}
+		pin.NIK = nik
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"blacklist-check/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrPolicyNotFound is returned when no policy snapshot has the requested version
+var ErrPolicyNotFound = errors.New("policy snapshot not found")
+
+// PolicySnapshot is an effective screening policy as an instance ran with it.
+// Version is a digest of Policy, so identical settings share a snapshot.
+type PolicySnapshot struct {
+	Version   string          `db:"version" json:"version"`
+	Policy    json.RawMessage `db:"policy" json:"policy"`
+	CreatedAt time.Time       `db:"created_at" json:"created_at"`
+}
+
+// PolicyStore defines the interface for policy snapshot access
+type PolicyStore interface {
+	// Save stores a snapshot unless its version exists, and reports whether it was new
+	Save(ctx context.Context, version string, policy interface{}) (bool, error)
+	Get(ctx context.Context, version string) (*PolicySnapshot, error)
+	List(ctx context.Context) ([]*PolicySnapshot, error)
+}
+
+// policyStore implements PolicyStore
+type policyStore struct {
+	db *sqlx.DB
+}
+
+// NewPolicyStore creates a new policy snapshot store
+func NewPolicyStore(db *sqlx.DB) PolicyStore {
+	return &policyStore{db: db}
+}
+
+// Save stores a snapshot unless its version exists, and reports whether it was new
+func (s *policyStore) Save(ctx context.Context, version string, policy interface{}) (bool, error) {
+	defer metrics.ObserveQuery("policy_save", time.Now())
+
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO policy_snapshots (version, policy)
+		VALUES ($1, $2)
+		ON CONFLICT (version) DO NOTHING
+	`, version, body)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Get returns the snapshot with the given version, or ErrPolicyNotFound
+func (s *policyStore) Get(ctx context.Context, version string) (*PolicySnapshot, error) {
+	defer metrics.ObserveQuery("policy_get", time.Now())
+
+	var snapshot PolicySnapshot
+	err := s.db.GetContext(ctx, &snapshot, `
+		SELECT version, policy, created_at
+		FROM policy_snapshots
+		WHERE version = $1
+	`, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPolicyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// List returns every snapshot, newest first
+func (s *policyStore) List(ctx context.Context) ([]*PolicySnapshot, error) {
+	defer metrics.ObserveQuery("policy_list", time.Now())
+
+	var snapshots []*PolicySnapshot
+	err := s.db.SelectContext(ctx, &snapshots, `
+		SELECT version, policy, created_at
+		FROM policy_snapshots
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
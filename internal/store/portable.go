@@ -0,0 +1,1003 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/normalize"
+	"blacklist-check/internal/phonetic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// portableStore implements BlacklistStore on MySQL and SQLite. Neither has
+// pg_trgm, so name lookups read the candidate records and rate them with
+// normalize.Similarity, which computes similarity() the way pg_trgm does.
+// MySQL narrows the candidates with n-gram fulltext indexes on names and
+// aliases; SQLite rates every record of the list. There is no history
+// trigger either, so the store records each change in blacklist_history
+// itself, the way the trigger does.
+//
+// Records can't be pinned on these backends: pins are Postgres-only, so
+// nothing holds a record back from expiry, deletion or list syncs.
+type portableStore struct {
+	db *sqlx.DB
+	// fulltext narrows name lookups with MySQL's n-gram fulltext indexes
+	fulltext bool
+	// insertIgnore starts an insert that skips rows breaking a unique key
+	insertIgnore string
+	// forUpdate locks the rows a MySQL transaction reads before changing
+	// them; a SQLite write transaction locks the whole database
+	forUpdate string
+	// now tells the time of changes. It is read from the application rather
+	// than the database, as the drivers disagree on time zones.
+	now func() time.Time
+}
+
+// NewMySQLBlacklistStore creates a blacklist store on MySQL 5.7.6 or later,
+// whose ngram fulltext parser name lookups rely on
+func NewMySQLBlacklistStore(db *sqlx.DB) BlacklistStore {
+	return &portableStore{
+		db:           db,
+		fulltext:     true,
+		insertIgnore: "INSERT IGNORE INTO",
+		forUpdate:    "FOR UPDATE",
+		now:          time.Now,
+	}
+}
+
+// NewSQLiteBlacklistStore creates a blacklist store on SQLite
+func NewSQLiteBlacklistStore(db *sqlx.DB) BlacklistStore {
+	return &portableStore{
+		db:           db,
+		insertIgnore: "INSERT OR IGNORE INTO",
+		now:          time.Now,
+	}
+}
+
+// portableColumns are the columns of a record the portable store returns
+const portableColumns = `b.id, b.list_type, b.nik, b.name, b.birth_place, b.birth_date, b.reason, b.reason_code, b.reason_params, b.source,
+	b.name_phonetic, b.name_normalized, b.name_sorted, b.nik_hash, b.created_at, b.updated_at, b.deleted_at, b.deleted_by, b.expires_at`
+
+// recordRow is a full blacklist row, as history entries snapshot it
+type recordRow struct {
+	ID               int64        `db:"id" json:"id"`
+	List             string       `db:"list_type" json:"list_type"`
+	NIK              string       `db:"nik" json:"nik"`
+	Name             string       `db:"name" json:"name"`
+	BirthPlace       string       `db:"birth_place" json:"birth_place"`
+	BirthDate        *time.Time   `db:"birth_date" json:"birth_date"`
+	Reason           string       `db:"reason" json:"reason"`
+	ReasonCode       string       `db:"reason_code" json:"reason_code"`
+	ReasonParams     ReasonParams `db:"reason_params" json:"reason_params"`
+	Source           string       `db:"source" json:"source"`
+	NamePhonetic     string       `db:"name_phonetic" json:"name_phonetic"`
+	NameNormalized   string       `db:"name_normalized" json:"name_normalized"`
+	NameSorted       string       `db:"name_sorted" json:"name_sorted"`
+	NIKHash          string       `db:"nik_hash" json:"nik_hash"`
+	CreatedAt        time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time    `db:"updated_at" json:"updated_at"`
+	DeletedAt        *time.Time   `db:"deleted_at" json:"deleted_at"`
+	DeletedBy        *string      `db:"deleted_by" json:"deleted_by"`
+	ExpiresAt        *time.Time   `db:"expires_at" json:"expires_at"`
+	ExpiryNotifiedAt *time.Time   `db:"expiry_notified_at" json:"expiry_notified_at"`
+}
+
+// record returns the row as a record
+func (r *recordRow) record() *BlacklistRecord {
+	return &BlacklistRecord{
+		ID:             r.ID,
+		List:           r.List,
+		NIK:            r.NIK,
+		Name:           r.Name,
+		BirthPlace:     r.BirthPlace,
+		BirthDate:      r.BirthDate,
+		Reason:         r.Reason,
+		ReasonCode:     r.ReasonCode,
+		ReasonParams:   r.ReasonParams,
+		Source:         r.Source,
+		NamePhonetic:   r.NamePhonetic,
+		NameNormalized: r.NameNormalized,
+		NameSorted:     r.NameSorted,
+		NIKHash:        r.NIKHash,
+		CreatedAt:      r.CreatedAt,
+		UpdatedAt:      r.UpdatedAt,
+		DeletedAt:      r.DeletedAt,
+		DeletedBy:      r.DeletedBy,
+		ExpiresAt:      r.ExpiresAt,
+	}
+}
+
+// GetByNIK retrieves a blacklist record by NIK from a list
+func (s *portableStore) GetByNIK(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("get_by_nik", time.Now())
+
+	var record BlacklistRecord
+	err := s.db.GetContext(ctx, &record, `
+		SELECT `+portableColumns+`
+		FROM blacklist b
+		WHERE b.list_type = ? AND b.nik = ? AND b.deleted_at IS NULL
+	`, list, nik)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CountNIKs counts the records on every list
+func (s *portableStore) CountNIKs(ctx context.Context) (int64, error) {
+	defer metrics.ObserveQuery("count_niks", time.Now())
+
+	var count int64
+	err := s.db.GetContext(ctx, &count, `SELECT count(*) FROM blacklist WHERE deleted_at IS NULL`)
+	return count, err
+}
+
+// EachNIK calls fn with the list and NIK of every record, streaming them
+func (s *portableStore) EachNIK(ctx context.Context, fn func(list, nik string) error) error {
+	defer metrics.ObserveQuery("each_nik", time.Now())
+
+	rows, err := s.db.QueryContext(ctx, `SELECT list_type, nik FROM blacklist WHERE deleted_at IS NULL`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var list, nik string
+		if err := rows.Scan(&list, &nik); err != nil {
+			return err
+		}
+		if err := fn(list, nik); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// candidates returns the live records of a list, or of every list when list
+// is empty, that may resemble name, in record order and with their aliases.
+// On MySQL they are the records sharing an n-gram with name in their own
+// name or an alias; elsewhere every record of the list is a candidate.
+func (s *portableStore) candidates(ctx context.Context, list, name string) ([]*BlacklistRecord, error) {
+	cond := "b.deleted_at IS NULL AND (? = '' OR b.list_type = ?)"
+	args := []interface{}{list, list}
+	if s.fulltext {
+		query := normalize.Name(name)
+		cond += ` AND (MATCH (b.name) AGAINST (?)
+			OR b.id IN (SELECT a.record_id FROM blacklist_aliases a WHERE MATCH (a.alias) AGAINST (?)))`
+		args = append(args, query, query)
+	}
+
+	var records []*BlacklistRecord
+	err := s.db.SelectContext(ctx, &records, `
+		SELECT `+portableColumns+`
+		FROM blacklist b
+		WHERE `+cond+`
+		ORDER BY b.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadAliases(ctx, records, "JOIN blacklist b ON b.id = a.record_id WHERE "+cond, args...); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// loadAliases fills in the aliases of records, leaving records without any
+// with an empty list. join selects the aliases a to read; it may cover more
+// records than those given.
+func (s *portableStore) loadAliases(ctx context.Context, records []*BlacklistRecord, join string, args ...interface{}) error {
+	if len(records) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*BlacklistRecord, len(records))
+	for _, record := range records {
+		record.Aliases = []Alias{}
+		byID[record.ID] = record
+	}
+
+	var rows []struct {
+		RecordID int64 `db:"record_id"`
+		Alias
+	}
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT a.record_id, a.alias, a.alias_type
+		FROM blacklist_aliases a `+join+`
+		ORDER BY a.record_id, a.id
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("error loading aliases: %w", err)
+	}
+	for _, row := range rows {
+		if record, ok := byID[row.RecordID]; ok {
+			record.Aliases = append(record.Aliases, row.Alias)
+		}
+	}
+	return nil
+}
+
+// loadAliasesOf fills in the aliases of a page of records
+func (s *portableStore) loadAliasesOf(ctx context.Context, records []*BlacklistRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	join, args, err := sqlx.In("WHERE a.record_id IN (?)", ids)
+	if err != nil {
+		return err
+	}
+	return s.loadAliases(ctx, records, join, args...)
+}
+
+// recordName is one of the names a record is known by
+type recordName struct {
+	name string
+	// alias is empty for the record's own name
+	alias string
+}
+
+// recordNames returns the names of a record in the order the Postgres store
+// considers them: its own name, then its aliases in alias order
+func recordNames(record *BlacklistRecord) []recordName {
+	names := []recordName{{name: record.Name}}
+	for _, alias := range record.Aliases {
+		names = append(names, recordName{name: alias.Alias, alias: alias.Alias})
+	}
+	sort.SliceStable(names[1:], func(i, j int) bool { return names[1+i].alias < names[1+j].alias })
+	return names
+}
+
+// mostSimilar returns up to limit records, most similar first. Ties keep
+// their record order, as the Postgres store breaks them by ID.
+func mostSimilar(records []*BlacklistRecord, limit int) []*BlacklistRecord {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Similarity > records[j].Similarity
+	})
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records
+}
+
+// GetByFuzzyMatch returns the records of a list whose name or an alias is
+// more similar to name than minSimilarity, as the Postgres store does. A
+// positive budget bounds how many candidate names are considered, the most
+// similar first with ties in record order, and truncated reports that some
+// were left out. At most limit records are returned.
+func (s *portableStore) GetByFuzzyMatch(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*BlacklistRecord, bool, error) {
+	defer metrics.ObserveQuery("fuzzy_match", time.Now())
+
+	records, err := s.candidates(ctx, list, name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	type candidate struct {
+		record     *BlacklistRecord
+		alias      string
+		similarity float64
+	}
+	var candidates []candidate
+	for _, record := range records {
+		// Unknown birth data is left out of the search rather than compared
+		if birthDate != nil && record.BirthDate != nil && !record.BornOn(*birthDate) {
+			continue
+		}
+		if len(birthPlaces) > 0 && record.BirthPlace != "" && normalize.MaxSimilarity(record.BirthPlace, birthPlaces) <= minSimilarity {
+			continue
+		}
+		for _, n := range recordNames(record) {
+			if similarity := normalize.Similarity(n.name, name); similarity > minSimilarity {
+				candidates = append(candidates, candidate{record: record, alias: n.alias, similarity: similarity})
+			}
+		}
+	}
+
+	// Records come in record order and their names in alias order, so a
+	// stable sort breaks ties as the Postgres store does
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+	truncated := budget > 0 && len(candidates) > budget
+	if truncated {
+		candidates = candidates[:budget]
+	}
+
+	// A record's first candidate is its closest name
+	var matches []*BlacklistRecord
+	seen := make(map[int64]bool, len(candidates))
+	for _, c := range candidates {
+		if seen[c.record.ID] {
+			continue
+		}
+		seen[c.record.ID] = true
+		c.record.MatchedAlias = c.alias
+		c.record.Similarity = c.similarity
+		matches = append(matches, c.record)
+	}
+	return mostSimilar(matches, limit), truncated, nil
+}
+
+// SearchByName returns the records of any list whose name resembles name
+func (s *portableStore) SearchByName(ctx context.Context, name string) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("search_by_name", time.Now())
+
+	const minSimilarity = 0.3
+
+	records, err := s.candidates(ctx, "", name)
+	if err != nil {
+		return nil, err
+	}
+	var matches []*BlacklistRecord
+	for _, record := range records {
+		if record.Similarity = normalize.Similarity(record.Name, name); record.Similarity > minSimilarity {
+			matches = append(matches, record)
+		}
+	}
+	return mostSimilar(matches, FuzzyMatchLimit), nil
+}
+
+// GetByPhonetic finds records in a list whose phonetic code, or that of one
+// of their aliases, equals that of name, restricted to the birth date when
+// one is given
+func (s *portableStore) GetByPhonetic(ctx context.Context, list, name string, birthDate *time.Time) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("phonetic_match", time.Now())
+
+	code := phonetic.Encode(name)
+	if code == "" {
+		return nil, nil
+	}
+
+	cond := `b.list_type = ? AND b.deleted_at IS NULL
+		AND (b.name_phonetic = ? OR b.id IN (SELECT a.record_id FROM blacklist_aliases a WHERE a.name_phonetic = ?))`
+	args := []interface{}{list, code, code}
+	var records []*BlacklistRecord
+	err := s.db.SelectContext(ctx, &records, `
+		SELECT `+portableColumns+`
+		FROM blacklist b
+		WHERE `+cond+`
+		ORDER BY b.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadAliases(ctx, records, "JOIN blacklist b ON b.id = a.record_id WHERE "+cond, args...); err != nil {
+		return nil, err
+	}
+
+	var matches []*BlacklistRecord
+	for _, record := range records {
+		if birthDate != nil && !record.BornOn(*birthDate) {
+			continue
+		}
+		for _, n := range recordNames(record) {
+			if (n.alias == "" && record.NamePhonetic == code) || (n.alias != "" && phonetic.Encode(n.alias) == code) {
+				record.MatchedAlias = n.alias
+				matches = append(matches, record)
+				break
+			}
+		}
+		if len(matches) == FuzzyMatchLimit {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// NearestByName returns the records of a list with the most similar names,
+// regardless of threshold or birth data. On MySQL only records sharing an
+// n-gram with name are rated, so it returns fewer than limit when the rest
+// of the list has nothing in common with name.
+func (s *portableStore) NearestByName(ctx context.Context, list, name string, limit int) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("nearest_by_name", time.Now())
+
+	records, err := s.candidates(ctx, list, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		record.Similarity = normalize.Similarity(record.Name, name)
+		record.Aliases = nil
+	}
+	return mostSimilar(records, limit), nil
+}
+
+// Search returns records of a list for browsing: those whose NIK starts with
+// query or whose name contains or resembles it, most similar first, or the
+// most recently updated ones when query is empty
+func (s *portableStore) Search(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("search", time.Now())
+
+	var records []*BlacklistRecord
+	if query == "" {
+		err := s.db.SelectContext(ctx, &records, `
+			SELECT `+portableColumns+`
+			FROM blacklist b
+			WHERE b.list_type = ? AND (? OR b.deleted_at IS NULL)
+			ORDER BY b.updated_at DESC, b.id
+			LIMIT ?
+		`, list, includeDeleted, limit)
+		if err != nil {
+			return nil, err
+		}
+		return records, s.loadAliasesOf(ctx, records)
+	}
+
+	const minSimilarity = 0.3
+	cond := "b.list_type = ? AND (? OR b.deleted_at IS NULL)"
+	args := []interface{}{list, includeDeleted}
+	if s.fulltext {
+		cond += " AND (b.nik LIKE ? OR LOWER(b.name) LIKE ? OR MATCH (b.name) AGAINST (?))"
+		args = append(args, query+"%", "%"+strings.ToLower(query)+"%", normalize.Name(query))
+	}
+	err := s.db.SelectContext(ctx, &records, `
+		SELECT `+portableColumns+`
+		FROM blacklist b
+		WHERE `+cond+`
+		ORDER BY b.updated_at DESC, b.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	lower := strings.ToLower(query)
+	var matches []*BlacklistRecord
+	for _, record := range records {
+		record.Similarity = normalize.Similarity(record.Name, query)
+		if strings.HasPrefix(record.NIK, query) || strings.Contains(strings.ToLower(record.Name), lower) || record.Similarity > minSimilarity {
+			matches = append(matches, record)
+		}
+	}
+	matches = mostSimilar(matches, limit)
+	return matches, s.loadAliasesOf(ctx, matches)
+}
+
+// List returns a page of records as the Postgres store does, with their aliases
+func (s *portableStore) List(ctx context.Context, filter RecordFilter, sort, cursor string, limit int) ([]*BlacklistRecord, string, error) {
+	defer metrics.ObserveQuery("list", time.Now())
+
+	records, next, err := listRecords(ctx, s.db, nil, nil, filter, sort, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	return records, next, s.loadAliasesOf(ctx, records)
+}
+
+// ListBySource retrieves all records a source contributed to a list, with their aliases
+func (s *portableStore) ListBySource(ctx context.Context, list, source string) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("list_by_source", time.Now())
+
+	const cond = "b.list_type = ? AND b.source = ? AND b.deleted_at IS NULL"
+	var records []*BlacklistRecord
+	err := s.db.SelectContext(ctx, &records, `
+		SELECT `+portableColumns+`
+		FROM blacklist b
+		WHERE `+cond, list, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadAliases(ctx, records, "JOIN blacklist b ON b.id = a.record_id WHERE "+cond, list, source); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// History returns every recorded change to a NIK on any list, oldest first
+func (s *portableStore) History(ctx context.Context, nik string) ([]*RecordChange, error) {
+	defer metrics.ObserveQuery("history", time.Now())
+
+	// before and after are quoted, being keywords in both dialects. The
+	// snapshots are read as bytes, as SQLite returns text columns as strings.
+	var rows []struct {
+		RecordChange
+		Before []byte `db:"before"`
+		After  []byte `db:"after"`
+	}
+	err := s.db.SelectContext(ctx, &rows, "SELECT id, nik, action, `before`, `after`, changed_by, changed_at "+
+		"FROM blacklist_history WHERE nik = ? ORDER BY id", nik)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*RecordChange, 0, len(rows))
+	for i := range rows {
+		change := rows[i].RecordChange
+		change.Before, change.After = rows[i].Before, rows[i].After
+		changes = append(changes, &change)
+	}
+	return changes, nil
+}
+
+func (s *portableStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// inTx runs fn in a transaction
+func (s *portableStore) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// row reads the full row matching cond within tx, locking it on MySQL, or
+// returns nil when there is none
+func (s *portableStore) row(ctx context.Context, tx *sqlx.Tx, cond string, args ...interface{}) (*recordRow, error) {
+	var row recordRow
+	err := tx.GetContext(ctx, &row, `SELECT * FROM blacklist WHERE `+cond+` `+s.forUpdate, args...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// rows reads the full rows matching cond within tx, locking them on MySQL
+func (s *portableStore) rows(ctx context.Context, tx *sqlx.Tx, cond string, args ...interface{}) ([]*recordRow, error) {
+	var rows []*recordRow
+	err := tx.SelectContext(ctx, &rows, `SELECT * FROM blacklist WHERE `+cond+` ORDER BY id `+s.forUpdate, args...)
+	return rows, err
+}
+
+// historySnapshot returns the JSON snapshot of a row history entries hold,
+// and the same without the derived columns, for telling real changes apart
+func historySnapshot(row *recordRow) ([]byte, []byte, error) {
+	if row == nil {
+		return nil, nil, nil
+	}
+	full, err := json.Marshal(row)
+	if err != nil {
+		return nil, nil, err
+	}
+	derived := *row
+	derived.NamePhonetic, derived.NameNormalized, derived.NameSorted, derived.NIKHash = "", "", "", ""
+	derived.UpdatedAt, derived.ExpiryNotifiedAt = time.Time{}, nil
+	compared, err := json.Marshal(derived)
+	return full, compared, err
+}
+
+// logChange records the change of a row from before to after in
+// blacklist_history, attributed to the actor in ctx. Like the Postgres
+// trigger, it skips changes to derived columns only and names the change
+// create, delete, restore or update.
+func (s *portableStore) logChange(ctx context.Context, tx *sqlx.Tx, before, after *recordRow) error {
+	beforeJSON, beforeCompared, err := historySnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, afterCompared, err := historySnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	var action, nik string
+	switch {
+	case before == nil:
+		action, nik = "create", after.NIK
+	case bytes.Equal(beforeCompared, afterCompared):
+		return nil
+	case before.DeletedAt == nil && after.DeletedAt != nil:
+		action, nik = "delete", after.NIK
+	case before.DeletedAt != nil && after.DeletedAt == nil:
+		action, nik = "restore", after.NIK
+	default:
+		action, nik = "update", after.NIK
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO blacklist_history (nik, action, `before`, `after`, changed_by, changed_at) "+
+		"VALUES (?, ?, ?, ?, ?, ?)", nik, action, beforeJSON, afterJSON, Actor(ctx), s.now().UTC())
+	return err
+}
+
+// replaceAliases replaces the aliases of a record with the given ones within
+// tx. Blank and repeated aliases are skipped.
+func (s *portableStore) replaceAliases(ctx context.Context, tx *sqlx.Tx, recordID int64, aliases []Alias) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM blacklist_aliases WHERE record_id = ?`, recordID); err != nil {
+		return fmt.Errorf("error clearing aliases: %w", err)
+	}
+	for _, alias := range aliases {
+		name := strings.Join(strings.Fields(alias.Alias), " ")
+		if name == "" {
+			continue
+		}
+		if alias.Type == "" {
+			alias.Type = AliasAKA
+		}
+		_, err := tx.ExecContext(ctx, s.insertIgnore+` blacklist_aliases (record_id, alias, alias_type, name_phonetic, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, recordID, name, alias.Type, phonetic.Encode(name), s.now().UTC())
+		if err != nil {
+			return fmt.Errorf("error inserting alias: %w", err)
+		}
+	}
+	return nil
+}
+
+// insert inserts a record into a list, taking over the row of a soft-deleted
+// record with the same NIK when there is one, and returns the new row
+func (s *portableStore) insert(ctx context.Context, tx *sqlx.Tx, deleted *recordRow, record *BlacklistRecord, list, source string) (*recordRow, error) {
+	now := s.now().UTC()
+	args := []interface{}{record.Name, record.BirthPlace, nullDate(record.BirthDate), record.Reason, source,
+		phonetic.Encode(record.Name), normalize.Name(record.Name), normalize.TokenSorted(record.Name), normalize.NIKHash(record.NIK),
+		record.ReasonCode, record.ReasonParams, record.ExpiresAt, now}
+
+	var id int64
+	if deleted != nil {
+		id = deleted.ID
+		_, err := tx.ExecContext(ctx, `
+			UPDATE blacklist
+			SET name = ?, birth_place = ?, birth_date = ?, reason = ?, source = ?,
+				name_phonetic = ?, name_normalized = ?, name_sorted = ?, nik_hash = ?,
+				reason_code = ?, reason_params = ?, expires_at = ?, updated_at = ?,
+				expiry_notified_at = NULL, deleted_at = NULL, deleted_by = NULL
+			WHERE id = ?
+		`, append(args, id)...)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO blacklist (name, birth_place, birth_date, reason, source,
+				name_phonetic, name_normalized, name_sorted, nik_hash,
+				reason_code, reason_params, expires_at, updated_at, created_at, list_type, nik)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, append(args, now, list, record.NIK)...)
+		if err != nil {
+			return nil, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return nil, err
+		}
+	}
+
+	after, err := s.row(ctx, tx, "id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return after, s.logChange(ctx, tx, deleted, after)
+}
+
+// update rewrites the fields of a live row from record and returns the new row
+func (s *portableStore) update(ctx context.Context, tx *sqlx.Tx, before *recordRow, record *BlacklistRecord) (*recordRow, error) {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE blacklist
+		SET name = ?, birth_place = ?, birth_date = ?, reason = ?,
+			name_phonetic = ?, name_normalized = ?, name_sorted = ?,
+			reason_code = ?, reason_params = ?, updated_at = ?
+		WHERE id = ?
+	`, record.Name, record.BirthPlace, nullDate(record.BirthDate), record.Reason,
+		phonetic.Encode(record.Name), normalize.Name(record.Name), normalize.TokenSorted(record.Name),
+		record.ReasonCode, record.ReasonParams, s.now().UTC(), before.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.reread(ctx, tx, before)
+}
+
+// softDelete soft-deletes a live row, attributing it to the actor in ctx, and
+// returns the new row
+func (s *portableStore) softDelete(ctx context.Context, tx *sqlx.Tx, before *recordRow) (*recordRow, error) {
+	_, err := tx.ExecContext(ctx, `UPDATE blacklist SET deleted_at = ?, deleted_by = ? WHERE id = ?`,
+		s.now().UTC(), Actor(ctx), before.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.reread(ctx, tx, before)
+}
+
+// reread reads a row again after changing it and records the change
+func (s *portableStore) reread(ctx context.Context, tx *sqlx.Tx, before *recordRow) (*recordRow, error) {
+	after, err := s.row(ctx, tx, "id = ?", before.ID)
+	if err != nil {
+		return nil, err
+	}
+	return after, s.logChange(ctx, tx, before, after)
+}
+
+// Create inserts a new blacklist record
+func (s *portableStore) Create(ctx context.Context, record *BlacklistRecord) error {
+	defer metrics.ObserveQuery("create", time.Now())
+
+	if record.Source == "" {
+		record.Source = "internal"
+	}
+	if record.List == "" {
+		record.List = lists.Internal
+	}
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		existing, err := s.row(ctx, tx, "list_type = ? AND nik = ?", record.List, record.NIK)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.DeletedAt == nil {
+			return ErrRecordExists
+		}
+		after, err := s.insert(ctx, tx, existing, record, record.List, record.Source)
+		if err != nil {
+			return err
+		}
+		aliases := record.Aliases
+		*record = *after.record()
+		record.Aliases = aliases
+		// A soft-deleted row taken over drops the aliases it had
+		return s.replaceAliases(ctx, tx, record.ID, aliases)
+	})
+}
+
+// Update modifies an existing blacklist record identified by list and NIK,
+// replacing its aliases unless they are nil
+func (s *portableStore) Update(ctx context.Context, record *BlacklistRecord) error {
+	defer metrics.ObserveQuery("update", time.Now())
+
+	if record.List == "" {
+		record.List = lists.Internal
+	}
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := s.row(ctx, tx, "list_type = ? AND nik = ? AND deleted_at IS NULL", record.List, record.NIK)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return ErrRecordNotFound
+		}
+		after, err := s.update(ctx, tx, before, record)
+		if err != nil {
+			return err
+		}
+		aliases := record.Aliases
+		*record = *after.record()
+		record.Aliases = aliases
+		if aliases == nil {
+			return nil
+		}
+		return s.replaceAliases(ctx, tx, record.ID, aliases)
+	})
+}
+
+// Delete soft-deletes a blacklist record by list and NIK, attributing it to
+// the actor in ctx
+func (s *portableStore) Delete(ctx context.Context, list, nik string) error {
+	defer metrics.ObserveQuery("delete", time.Now())
+
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := s.row(ctx, tx, "list_type = ? AND nik = ? AND deleted_at IS NULL", list, nik)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return ErrRecordNotFound
+		}
+		_, err = s.softDelete(ctx, tx, before)
+		return err
+	})
+}
+
+// Restore reverses the soft deletion of a blacklist record. A temporary record
+// restored after it expired stays on the list for good.
+func (s *portableStore) Restore(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("restore", time.Now())
+
+	var record *BlacklistRecord
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := s.row(ctx, tx, "list_type = ? AND nik = ? AND deleted_at IS NOT NULL", list, nik)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return ErrRecordNotFound
+		}
+		now := s.now().UTC()
+		expiresAt := before.ExpiresAt
+		if expiresAt != nil && !expiresAt.After(now) {
+			expiresAt = nil
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE blacklist
+			SET deleted_at = NULL, deleted_by = NULL, updated_at = ?, expires_at = ?
+			WHERE id = ?
+		`, now, expiresAt, before.ID)
+		if err != nil {
+			return err
+		}
+		after, err := s.reread(ctx, tx, before)
+		if err != nil {
+			return err
+		}
+		record = after.record()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// setExpiry sets the expiry of a live temporary record, resetting its expiry
+// notice, and returns the record
+func (s *portableStore) setExpiry(ctx context.Context, list, nik string, expiresAt *time.Time) (*BlacklistRecord, error) {
+	var record *BlacklistRecord
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := s.row(ctx, tx, "list_type = ? AND nik = ? AND deleted_at IS NULL AND expires_at IS NOT NULL", list, nik)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return ErrRecordNotFound
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE blacklist
+			SET expires_at = ?, expiry_notified_at = NULL, updated_at = ?
+			WHERE id = ?
+		`, expiresAt, s.now().UTC(), before.ID)
+		if err != nil {
+			return err
+		}
+		after, err := s.reread(ctx, tx, before)
+		if err != nil {
+			return err
+		}
+		record = after.record()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Confirm makes a live temporary record permanent
+func (s *portableStore) Confirm(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("confirm", time.Now())
+	return s.setExpiry(ctx, list, nik, nil)
+}
+
+// Extend moves the expiry of a live temporary record, resetting its expiry notice
+func (s *portableStore) Extend(ctx context.Context, list, nik string, expiresAt time.Time) (*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("extend", time.Now())
+	expiresAt = expiresAt.UTC()
+	return s.setExpiry(ctx, list, nik, &expiresAt)
+}
+
+// MarkExpiring flags the live temporary records expiring within the given
+// window that haven't been flagged yet and returns them. Each record is
+// claimed by a conditional update, so replicas don't notify twice.
+func (s *portableStore) MarkExpiring(ctx context.Context, within time.Duration) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("mark_expiring", time.Now())
+
+	now := s.now().UTC()
+	var due []*BlacklistRecord
+	err := s.db.SelectContext(ctx, &due, `
+		SELECT `+portableColumns+`
+		FROM blacklist b
+		WHERE b.deleted_at IS NULL AND b.expiry_notified_at IS NULL
+			AND b.expires_at IS NOT NULL AND b.expires_at <= ?
+		ORDER BY b.id
+	`, now.Add(within))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*BlacklistRecord
+	for _, record := range due {
+		res, err := s.db.ExecContext(ctx, `
+			UPDATE blacklist SET expiry_notified_at = ?
+			WHERE id = ? AND expiry_notified_at IS NULL AND deleted_at IS NULL
+		`, now, record.ID)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// Expire soft-deletes the temporary records whose expiry has passed,
+// attributing it to the actor in ctx, and returns them
+func (s *portableStore) Expire(ctx context.Context) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("expire", time.Now())
+
+	var records []*BlacklistRecord
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		expired, err := s.rows(ctx, tx, "deleted_at IS NULL AND expires_at <= ?", s.now().UTC())
+		if err != nil {
+			return err
+		}
+		for _, before := range expired {
+			after, err := s.softDelete(ctx, tx, before)
+			if err != nil {
+				return err
+			}
+			records = append(records, after.record())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ApplyChangeSet writes the inserts, updates and soft deletes of a change set
+// in a single transaction
+func (s *portableStore) ApplyChangeSet(ctx context.Context, cs *ChangeSet) error {
+	defer metrics.ObserveQuery("apply_change_set", time.Now())
+
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		for _, record := range cs.Added {
+			existing, err := s.row(ctx, tx, "list_type = ? AND nik = ?", cs.List, record.NIK)
+			if err != nil {
+				return fmt.Errorf("error inserting record %s: %w", record.NIK, err)
+			}
+			if existing != nil && existing.DeletedAt == nil {
+				return fmt.Errorf("error inserting record %s: %w", record.NIK, ErrRecordExists)
+			}
+			added := *record
+			added.ExpiresAt = nil
+			after, err := s.insert(ctx, tx, existing, &added, cs.List, cs.Source)
+			if err != nil {
+				return fmt.Errorf("error inserting record %s: %w", record.NIK, err)
+			}
+			if err := s.replaceAliases(ctx, tx, after.ID, record.Aliases); err != nil {
+				return fmt.Errorf("error inserting record %s: %w", record.NIK, err)
+			}
+		}
+
+		// A change set carries every alias of its records, so an update without
+		// any removes those the record had
+		for _, record := range cs.Updated {
+			before, err := s.row(ctx, tx, "list_type = ? AND nik = ? AND source = ? AND deleted_at IS NULL", cs.List, record.NIK, cs.Source)
+			if err != nil {
+				return fmt.Errorf("error updating record %s: %w", record.NIK, err)
+			}
+			if before == nil {
+				continue
+			}
+			if _, err := s.update(ctx, tx, before, record); err != nil {
+				return fmt.Errorf("error updating record %s: %w", record.NIK, err)
+			}
+			if err := s.replaceAliases(ctx, tx, before.ID, record.Aliases); err != nil {
+				return fmt.Errorf("error updating record %s: %w", record.NIK, err)
+			}
+		}
+
+		for _, nik := range cs.Deleted {
+			before, err := s.row(ctx, tx, "list_type = ? AND nik = ? AND source = ? AND deleted_at IS NULL", cs.List, nik, cs.Source)
+			if err != nil {
+				return fmt.Errorf("error deleting records: %w", err)
+			}
+			if before == nil {
+				continue
+			}
+			if _, err := s.softDelete(ctx, tx, before); err != nil {
+				return fmt.Errorf("error deleting records: %w", err)
+			}
+		}
+		return nil
+	})
+}
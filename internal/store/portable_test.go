@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"blacklist-check/migrations"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newSQLiteStore returns a SQLite store on an in-memory database with the
+// SQLite migrations applied
+func newSQLiteStore(t *testing.T) (BlacklistStore, *sqlx.DB) {
+	t.Helper()
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	fsys, err := migrations.For("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"000001_create_blacklist.up.sql", "000002_table_versions.up.sql"} {
+		migration, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("applying %s: %v", name, err)
+		}
+	}
+	return NewSQLiteBlacklistStore(db), db
+}
+
+func TestPortableFuzzyMatchBudget(t *testing.T) {
+	s, db := newSQLiteStore(t)
+	// The closest names are past the least similar in record order
+	for _, record := range []struct{ nik, name string }{
+		{"3171230101900001", "Jon Doe"},
+		{"3171230101900002", "John Doe"},
+		{"3171230101900003", "John Doe"},
+	} {
+		if _, err := db.Exec(`INSERT INTO blacklist (list_type, nik, name) VALUES ('internal', ?, ?)`, record.nik, record.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		budget        int
+		wantIDs       []int64
+		wantTruncated bool
+	}{
+		{"most similar first", 1, []int64{2}, true},
+		{"ties in record order", 2, []int64{2, 3}, true},
+		{"budget fits", 3, []int64{2, 3, 1}, false},
+		{"no budget", 0, []int64{2, 3, 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, truncated, err := s.GetByFuzzyMatch(context.Background(), "internal", "John Doe", nil, nil, 0.3, tt.budget, FuzzyMatchLimit)
+			if err != nil {
+				t.Fatalf("GetByFuzzyMatch() error = %v", err)
+			}
+			var ids []int64
+			for _, r := range records {
+				ids = append(ids, r.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) || truncated != tt.wantTruncated {
+				t.Errorf("GetByFuzzyMatch() = %v, truncated %v, want %v, truncated %v", ids, truncated, tt.wantIDs, tt.wantTruncated)
+			}
+		})
+	}
+}
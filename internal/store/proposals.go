@@ -0,0 +1,250 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikcrypt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Proposal statuses
+const (
+	ProposalPending  = "pending"
+	ProposalApproved = "approved"
+	ProposalRejected = "rejected"
+)
+
+// Proposed record changes, one per record mutation of the admin API
+const (
+	ChangeCreate          = "create"
+	ChangeUpdate          = "update"
+	ChangeDelete          = "delete"
+	ChangeRestore         = "restore"
+	ChangeCreateTemporary = "create_temporary"
+	ChangeConfirm         = "confirm"
+	ChangeExtend          = "extend"
+	ChangeMerge           = "merge"
+)
+
+var (
+	// ErrProposalNotFound is returned when a proposal does not exist
+	ErrProposalNotFound = errors.New("proposal not found")
+	// ErrProposalDecided is returned when deciding a proposal that was already approved or rejected
+	ErrProposalDecided = errors.New("proposal already decided")
+)
+
+// Proposal is a record change held back until a second user approves it
+type Proposal struct {
+	ID     int64  `db:"id" json:"id"`
+	Action string `db:"action" json:"action"`
+	List   string `db:"list_type" json:"list"`
+	NIK    string `db:"nik" json:"nik"`
+	// Record is the proposed record, encoded, for creates and updates
+	Record []byte `db:"record" json:"-"`
+	// NIKEncrypted and RecordEncrypted hold NIK and Record encrypted under
+	// the NIK keys, which is all a proposal made with keys stores of them
+	NIKEncrypted    string `db:"nik_encrypted" json:"-"`
+	RecordEncrypted string `db:"record_encrypted" json:"-"`
+	// Days is how long a proposed temporary record lasts or is extended by
+	Days int `db:"days" json:"days,omitempty"`
+	// DuplicateID and KeepID are the duplicate pair a merge merges and the
+	// record it keeps
+	DuplicateID int64      `db:"duplicate_id" json:"duplicate_id,omitempty"`
+	KeepID      int64      `db:"keep_id" json:"keep_id,omitempty"`
+	Status      string     `db:"status" json:"status"`
+	ProposedBy  string     `db:"proposed_by" json:"proposed_by"`
+	ProposedAt  time.Time  `db:"proposed_at" json:"proposed_at"`
+	DecidedBy   *string    `db:"decided_by" json:"decided_by,omitempty"`
+	DecidedAt   *time.Time `db:"decided_at" json:"decided_at,omitempty"`
+	Comment     string     `db:"comment" json:"comment,omitempty"`
+}
+
+// Decode unmarshals the proposed record, nil when the change has none
+func (p *Proposal) Decode() (*BlacklistRecord, error) {
+	if len(p.Record) == 0 {
+		return nil, nil
+	}
+	var record BlacklistRecord
+	if err := json.Unmarshal(p.Record, &record); err != nil {
+		return nil, fmt.Errorf("error decoding proposed record: %w", err)
+	}
+	return &record, nil
+}
+
+// ProposalStore defines the interface for proposed record change access
+type ProposalStore interface {
+	// Create stores a pending proposal of record, which may be nil, by the
+	// actor in ctx, setting its ID, status, proposer and time
+	Create(ctx context.Context, proposal *Proposal, record *BlacklistRecord) error
+	Get(ctx context.Context, id int64) (*Proposal, error)
+	// List returns the proposals with status, oldest first
+	List(ctx context.Context, status string) ([]*Proposal, error)
+	// Decide approves or rejects a pending proposal as the actor in ctx
+	Decide(ctx context.Context, id int64, status, comment string) (*Proposal, error)
+	// Approve approves a pending proposal as the actor in ctx and calls apply
+	// with it in the same transaction, which the record writes made with the
+	// context apply is given join. The change and the approval commit
+	// together, or neither does when apply fails.
+	Approve(ctx context.Context, id int64, comment string, apply func(ctx context.Context, proposal *Proposal) error) (*Proposal, error)
+}
+
+// proposalStore implements ProposalStore
+type proposalStore struct {
+	db *sqlx.DB
+	// keys protect the NIK and record of proposals like those of records
+	keys    *nikcrypt.Keys
+	tenancy *Tenancy
+}
+
+// NewProposalStore creates a new proposal store, scoped to the caller's tenant
+func NewProposalStore(db *sqlx.DB, keys *nikcrypt.Keys, tenancy *Tenancy) ProposalStore {
+	return &proposalStore{db: db, keys: keys, tenancy: tenancy}
+}
+
+// proposalColumns are the columns of a proposal
+const proposalColumns = `id, action, list_type, nik, nik_encrypted, record, record_encrypted, days, duplicate_id, keep_id, status, proposed_by, proposed_at, decided_by, decided_at, comment`
+
+// Create stores a pending proposal
+func (s *proposalStore) Create(ctx context.Context, proposal *Proposal, record *BlacklistRecord) error {
+	defer metrics.ObserveQuery("create_proposal", time.Now())
+
+	var payload []byte
+	if record != nil {
+		var err error
+		if payload, err = json.Marshal(record); err != nil {
+			return fmt.Errorf("error encoding proposed record: %w", err)
+		}
+	}
+	plain, encrypted, mac := protectNIK(s.keys, proposal.NIK)
+	var sealed string
+	if s.keys != nil && payload != nil {
+		payload, sealed = nil, s.keys.Encrypt(string(payload))
+	}
+	err := s.db.GetContext(ctx, proposal, `
+		INSERT INTO record_proposals (tenant_id, action, list_type, nik, nik_encrypted, nik_hmac, record, record_encrypted, days, duplicate_id, keep_id, proposed_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING `+proposalColumns,
+		s.tenancy.Caller(ctx), proposal.Action, proposal.List, plain, encrypted, mac, payload, sealed, proposal.Days, proposal.DuplicateID, proposal.KeepID, Actor(ctx))
+	if err != nil {
+		return err
+	}
+	return s.reveal(proposal)
+}
+
+// Get retrieves a proposal by ID
+func (s *proposalStore) Get(ctx context.Context, id int64) (*Proposal, error) {
+	defer metrics.ObserveQuery("get_proposal", time.Now())
+
+	var proposal Proposal
+	err := s.db.GetContext(ctx, &proposal, `
+		SELECT `+proposalColumns+`
+		FROM record_proposals
+		WHERE id = $1 AND tenant_id = $2
+	`, id, s.tenancy.Caller(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProposalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(&proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// List returns the proposals with status, oldest first
+func (s *proposalStore) List(ctx context.Context, status string) ([]*Proposal, error) {
+	defer metrics.ObserveQuery("list_proposals", time.Now())
+
+	var proposals []*Proposal
+	err := s.db.SelectContext(ctx, &proposals, `
+		SELECT `+proposalColumns+`
+		FROM record_proposals
+		WHERE tenant_id = $1 AND status = $2
+		ORDER BY id
+	`, s.tenancy.Caller(ctx), status)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(proposals...); err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+// Decide approves or rejects a pending proposal. Only one decision is ever
+// recorded, however many are made at once.
+func (s *proposalStore) Decide(ctx context.Context, id int64, status, comment string) (*Proposal, error) {
+	defer metrics.ObserveQuery("decide_proposal", time.Now())
+
+	return s.decide(ctx, s.db, id, status, comment)
+}
+
+// Approve claims a pending proposal with the conditional update of Decide,
+// so of concurrent approvals only one applies the change; the others find it
+// decided, or wait on the claim and find it still pending if it rolls back.
+func (s *proposalStore) Approve(ctx context.Context, id int64, comment string, apply func(ctx context.Context, proposal *Proposal) error) (*Proposal, error) {
+	defer metrics.ObserveQuery("approve_proposal", time.Now())
+
+	var proposal *Proposal
+	err := inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		var err error
+		if proposal, err = s.decide(ctx, tx, id, ProposalApproved, comment); err != nil {
+			return err
+		}
+		return apply(withTx(ctx, tx), proposal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// decide records a decision on a pending proposal through q
+func (s *proposalStore) decide(ctx context.Context, q sqlx.QueryerContext, id int64, status, comment string) (*Proposal, error) {
+	var proposal Proposal
+	err := sqlx.GetContext(ctx, q, &proposal, `
+		UPDATE record_proposals
+		SET status = $3, decided_by = $4, decided_at = CURRENT_TIMESTAMP, comment = $5
+		WHERE id = $1 AND tenant_id = $2 AND status = $6
+		RETURNING `+proposalColumns,
+		id, s.tenancy.Caller(ctx), status, Actor(ctx), comment, ProposalPending)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProposalDecided
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(&proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// reveal fills in the NIK and record of proposals that store them encrypted
+func (s *proposalStore) reveal(proposals ...*Proposal) error {
+	for _, proposal := range proposals {
+		nik, err := revealNIK(s.keys, proposal.NIK, proposal.NIKEncrypted)
+		if err != nil {
+			return fmt.Errorf("proposal %d: %w", proposal.ID, err)
+		}
+		proposal.NIK = nik
+		if proposal.RecordEncrypted == "" {
+			continue
+		}
+		record, err := revealNIK(s.keys, "", proposal.RecordEncrypted)
+		if err != nil {
+			return fmt.Errorf("proposal %d: %w", proposal.ID, err)
+		}
+		proposal.Record = []byte(record)
+	}
+	return nil
+}
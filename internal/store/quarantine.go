@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Quarantine statuses
+const (
+	QuarantinePending  = "pending"
+	QuarantineApproved = "approved"
+	QuarantineRejected = "rejected"
+)
+
+// QuarantineEntry represents a change set held back for manual approval
+type QuarantineEntry struct {
+	ID           int64        `db:"id" json:"id"`
+	Source       string       `db:"source" json:"source"`
+	ChangeSet    []byte       `db:"change_set" json:"-"`
+	TotalRecords int          `db:"total_records" json:"total_records"`
+	AddedCount   int          `db:"added_count" json:"added_count"`
+	UpdatedCount int          `db:"updated_count" json:"updated_count"`
+	DeletedCount int          `db:"deleted_count" json:"deleted_count"`
+	ChangeRatio  float64      `db:"change_ratio" json:"change_ratio"`
+	Quality      *SyncQuality `db:"quality" json:"quality,omitempty"`
+	Status       string       `db:"status" json:"status"`
+	DecidedBy    *string      `db:"decided_by" json:"decided_by,omitempty"`
+	DecidedAt    sql.NullTime `db:"decided_at" json:"-"`
+	CreatedAt    time.Time    `db:"created_at" json:"created_at"`
+}
+
+// Decode unmarshals the stored change set
+func (e *QuarantineEntry) Decode() (*ChangeSet, error) {
+	var cs ChangeSet
+	if err := json.Unmarshal(e.ChangeSet, &cs); err != nil {
+		return nil, fmt.Errorf("error decoding change set: %w", err)
+	}
+	return &cs, nil
+}
+
+// QuarantineStore defines the interface for quarantined change set access
+type QuarantineStore interface {
+	Create(ctx context.Context, entry *QuarantineEntry, cs *ChangeSet) (int64, error)
+	Get(ctx context.Context, id int64) (*QuarantineEntry, error)
+	ListPending(ctx context.Context) ([]*QuarantineEntry, error)
+	Decide(ctx context.Context, id int64, status, decidedBy string) error
+	// Approve approves a pending change set as decidedBy and calls apply
+	// with it in the same transaction, which the record reads and writes
+	// made with the context apply is given join. The change set is applied
+	// and approved together, or neither when apply fails. It returns nil when
+	// the change set is no longer pending.
+	Approve(ctx context.Context, id int64, decidedBy string, apply func(ctx context.Context, entry *QuarantineEntry) error) (*QuarantineEntry, error)
+}
+
+// quarantineStore implements QuarantineStore
+type quarantineStore struct {
+	db *sqlx.DB
+}
+
+// NewQuarantineStore creates a new quarantine store
+func NewQuarantineStore(db *sqlx.DB) QuarantineStore {
+	return &quarantineStore{db: db}
+}
+
+// Create stores a change set in quarantine and returns its ID
+func (s *quarantineStore) Create(ctx context.Context, entry *QuarantineEntry, cs *ChangeSet) (int64, error) {
+	payload, err := json.Marshal(cs)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding change set: %w", err)
+	}
+
+	var id int64
+	err = s.db.GetContext(ctx, &id, `
+		INSERT INTO sync_quarantine (source, change_set, total_records, added_count, updated_count, deleted_count, change_ratio, quality)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, cs.Source, payload, entry.TotalRecords, len(cs.Added), len(cs.Updated), len(cs.Deleted), entry.ChangeRatio, entry.Quality)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Get retrieves a quarantined change set by ID
+func (s *quarantineStore) Get(ctx context.Context, id int64) (*QuarantineEntry, error) {
+	var entry QuarantineEntry
+	err := s.db.GetContext(ctx, &entry, `
+		SELECT id, source, change_set, total_records, added_count, updated_count, deleted_count,
+			change_ratio, quality, status, decided_by, decided_at, created_at
+		FROM sync_quarantine
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ListPending retrieves all change sets awaiting a decision
+func (s *quarantineStore) ListPending(ctx context.Context) ([]*QuarantineEntry, error) {
+	var entries []*QuarantineEntry
+	err := s.db.SelectContext(ctx, &entries, `
+		SELECT id, source, total_records, added_count, updated_count, deleted_count,
+			change_ratio, quality, status, created_at
+		FROM sync_quarantine
+		WHERE status = $1
+		ORDER BY created_at
+	`, QuarantinePending)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Decide records an approval or rejection for a pending change set
+func (s *quarantineStore) Decide(ctx context.Context, id int64, status, decidedBy string) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE sync_quarantine
+		SET status = $2, decided_by = $3, decided_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $4
+	`, id, status, decidedBy, QuarantinePending)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("quarantine entry %d is not pending", id)
+	}
+	return nil
+}
+
+// Approve claims a pending change set with a conditional update, so of
+// concurrent approvals only one applies it
+func (s *quarantineStore) Approve(ctx context.Context, id int64, decidedBy string, apply func(ctx context.Context, entry *QuarantineEntry) error) (*QuarantineEntry, error) {
+	var (
+		entry   QuarantineEntry
+		claimed bool
+	)
+	err := inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &entry, `
+			UPDATE sync_quarantine
+			SET status = $2, decided_by = $3, decided_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND status = $4
+			RETURNING id, source, change_set, total_records, added_count, updated_count, deleted_count,
+				change_ratio, quality, status, decided_by, decided_at, created_at
+		`, id, QuarantineApproved, decidedBy, QuarantinePending)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		claimed = true
+		return apply(withTx(ctx, tx), &entry)
+	})
+	if err != nil || !claimed {
+		return nil, err
+	}
+	return &entry, nil
+}
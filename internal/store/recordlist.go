@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikcrypt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrInvalidCursor is returned when a page cursor is malformed or was issued for another sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// RecordSorts lists the columns records can be listed by. A "-" prefix sorts descending.
+var RecordSorts = []string{"id", "created_at", "updated_at", "name"}
+
+// RecordFilter narrows a record listing. Zero fields don't filter.
+type RecordFilter struct {
+	List string
+	// Name matches records whose name contains it, ignoring case
+	Name string
+	// NIK matches records whose NIK starts with it; a NIK protected by the
+	// NIK keys only matches it whole
+	NIK            string
+	Source         string
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	IncludeDeleted bool
+	// Temporary limits the listing to temporary records
+	Temporary bool
+}
+
+// recordCursor is the position after the last record of a page, encoded as
+// opaque base64 JSON. It carries the sort it was issued for so it can't be
+// replayed against another order.
+type recordCursor struct {
+	Sort  string     `json:"s"`
+	ID    int64      `json:"id"`
+	Time  *time.Time `json:"t,omitempty"`
+	Value string     `json:"v,omitempty"`
+}
+
+func (c recordCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeRecordCursor(s, sort string) (*recordCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c recordCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// ValidRecordSort reports whether sort is one of RecordSorts, optionally prefixed with "-"
+func ValidRecordSort(sort string) bool {
+	column := strings.TrimPrefix(sort, "-")
+	for _, s := range RecordSorts {
+		if s == column {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns up to limit records matching filter in sort order, starting
+// after cursor, along with the cursor of the next page or "" on the last one.
+// Pages are keyed on the sort column and ID rather than offsets, so the full
+// dataset can be walked page by page at a constant cost per page. Records
+// come with their aliases.
+func (s *blacklistStore) List(ctx context.Context, filter RecordFilter, sort, cursor string, limit int) ([]*BlacklistRecord, string, error) {
+	defer metrics.ObserveQuery("list", time.Now())
+
+	records, next, err := listRecords(ctx, s.db, s.tenancy, s.keys, filter, sort, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := loadAliases(ctx, s.db, records); err != nil {
+		return nil, "", err
+	}
+	return records, next, nil
+}
+
+// listRecords returns a page of records as List describes, without their
+// aliases, in the SQL of db's driver. With tenancy, only records the caller
+// reads are listed; the stores without it have no tenants. Postgres rows may
+// keep their NIK encrypted under keys.
+func listRecords(ctx context.Context, db *sqlx.DB, tenancy *Tenancy, keys *nikcrypt.Keys, filter RecordFilter, sort, cursor string, limit int) ([]*BlacklistRecord, string, error) {
+	if !ValidRecordSort(sort) {
+		return nil, "", fmt.Errorf("unknown sort %q", sort)
+	}
+	column := strings.TrimPrefix(sort, "-")
+	direction, op := "ASC", ">"
+	if strings.HasPrefix(sort, "-") {
+		direction, op = "DESC", "<"
+	}
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	// where adds a condition with ? placeholders, rebound for the driver at the end
+	where := func(cond string, values ...interface{}) {
+		args = append(args, values...)
+		conds = append(conds, cond)
+	}
+	postgres := db.DriverName() == "postgres"
+	if tenancy != nil {
+		where(ownerCondition("", "?", "?"), tenancy.sharedLists(), tenancy.Caller(ctx))
+	}
+	if filter.List != "" {
+		where("list_type = ?", filter.List)
+	}
+	if filter.Name != "" {
+		// MySQL and SQLite have no ILIKE, and their || isn't always concatenation
+		if postgres {
+			where("name ILIKE '%' || ? || '%'", filter.Name)
+		} else {
+			where("LOWER(name) LIKE ?", "%"+strings.ToLower(filter.Name)+"%")
+		}
+	}
+	if filter.NIK != "" {
+		if postgres {
+			where("(nik LIKE ? OR nik_hmac = ANY(?))", filter.NIK+"%", nikMACs(keys, []string{filter.NIK}))
+		} else {
+			where("nik LIKE ?", filter.NIK+"%")
+		}
+	}
+	if filter.Source != "" {
+		where("source = ?", filter.Source)
+	}
+	if filter.CreatedAfter != nil {
+		where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		where("created_at < ?", *filter.CreatedBefore)
+	}
+	if !filter.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if filter.Temporary {
+		conds = append(conds, "expires_at IS NOT NULL")
+	}
+
+	if cursor != "" {
+		c, err := decodeRecordCursor(cursor, sort)
+		if err != nil {
+			return nil, "", err
+		}
+		switch column {
+		case "id":
+			where("id "+op+" ?", c.ID)
+		case "name":
+			where("(name, id) "+op+" (?, ?)", c.Value, c.ID)
+		default:
+			if c.Time == nil {
+				return nil, "", ErrInvalidCursor
+			}
+			where("("+column+", id) "+op+" (?, ?)", *c.Time, c.ID)
+		}
+	}
+
+	columns := "id, list_type, nik, name, birth_place, birth_date, reason, reason_code, reason_params, source, created_at, updated_at, deleted_at, deleted_by, expires_at"
+	if postgres {
+		columns += ", nik_encrypted"
+	}
+	query := `
+		SELECT ` + columns + `
+		FROM blacklist`
+	if len(conds) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conds, " AND ")
+	}
+	// Fetch one extra row to learn whether there is a next page
+	args = append(args, limit+1)
+	query += fmt.Sprintf("\n\t\tORDER BY %s %s, id %s\n\t\tLIMIT ?", column, direction, direction)
+
+	var records []*BlacklistRecord
+	if err := db.SelectContext(ctx, &records, db.Rebind(query), args...); err != nil {
+		return nil, "", err
+	}
+	if err := revealRecords(keys, records...); err != nil {
+		return nil, "", err
+	}
+	var next string
+	if len(records) > limit {
+		records = records[:limit]
+		last := records[limit-1]
+		c := recordCursor{Sort: sort, ID: last.ID}
+		switch column {
+		case "name":
+			c.Value = last.Name
+		case "created_at":
+			c.Time = &last.CreatedAt
+		case "updated_at":
+			c.Time = &last.UpdatedAt
+		}
+		next = c.encode()
+	}
+	return records, next, nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"blacklist-check/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// RescreenSubject is a screened subject enrolled for re-screening
+type RescreenSubject struct {
+	ID     int64  `db:"id"`
+	Tenant string `db:"tenant_id"`
+	// SubjectHash identifies the subject as its decision events do
+	SubjectHash string `db:"subject_hash"`
+	// Consent is whether the subject agreed to be re-screened. Without it
+	// only the hash and the outcome are kept.
+	Consent    bool           `db:"consent"`
+	Name       string         `db:"name"`
+	NIK        string         `db:"nik"`
+	IDCountry  string         `db:"id_country"`
+	BirthPlace string         `db:"birth_place"`
+	BirthDate  *time.Time     `db:"birth_date"`
+	Lists      pq.StringArray `db:"lists"`
+	Profile    string         `db:"profile"`
+	// Tokenized means Name and NIK hold tokens from the tokenization service
+	Tokenized bool `db:"tokenized"`
+	// Outcome and MatchType are those of the latest screening
+	Outcome    string    `db:"outcome"`
+	MatchType  string    `db:"match_type"`
+	EnrolledAt time.Time `db:"enrolled_at"`
+	ScreenedAt time.Time `db:"screened_at"`
+}
+
+// RescreenStore defines the interface for re-screening enrolment access
+type RescreenStore interface {
+	// Enrol records a subject screened by the caller in ctx, replacing an
+	// earlier enrolment of the same subject
+	Enrol(ctx context.Context, subject *RescreenSubject) error
+	// Due claims up to limit consenting subjects of every tenant, last
+	// cleared and screened before before, marking them screened now
+	Due(ctx context.Context, before time.Time, limit int) ([]*RescreenSubject, error)
+	// Rescreened records the outcome of re-screening a subject
+	Rescreened(ctx context.Context, id int64, outcome, matchType string) error
+}
+
+// rescreenStore implements RescreenStore
+type rescreenStore struct {
+	db      *sqlx.DB
+	tenancy *Tenancy
+}
+
+// NewRescreenStore creates a new re-screening store
+func NewRescreenStore(db *sqlx.DB, tenancy *Tenancy) RescreenStore {
+	return &rescreenStore{db: db, tenancy: tenancy}
+}
+
+// rescreenColumns are the columns of an enrolled subject
+const rescreenColumns = `id, tenant_id, subject_hash, consent, name, nik, id_country, birth_place, birth_date, lists, profile, tokenized, outcome, match_type, enrolled_at, screened_at`
+
+// Enrol upserts a subject under the caller's tenant
+func (s *rescreenStore) Enrol(ctx context.Context, subject *RescreenSubject) error {
+	defer metrics.ObserveQuery("rescreen_enrol", time.Now())
+
+	subject.Tenant = s.tenancy.Caller(ctx)
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO rescreen_subjects (tenant_id, subject_hash, consent, name, nik, id_country, birth_place, birth_date, lists, profile, tokenized, outcome, match_type)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (tenant_id, subject_hash) DO UPDATE SET
+			consent = EXCLUDED.consent,
+			name = EXCLUDED.name,
+			nik = EXCLUDED.nik,
+			id_country = EXCLUDED.id_country,
+			birth_place = EXCLUDED.birth_place,
+			birth_date = EXCLUDED.birth_date,
+			lists = EXCLUDED.lists,
+			profile = EXCLUDED.profile,
+			tokenized = EXCLUDED.tokenized,
+			outcome = EXCLUDED.outcome,
+			match_type = EXCLUDED.match_type,
+			screened_at = CURRENT_TIMESTAMP
+	`, subject.Tenant, subject.SubjectHash, subject.Consent, subject.Name, subject.NIK, subject.IDCountry,
+		subject.BirthPlace, subject.BirthDate, subject.Lists, subject.Profile, subject.Tokenized, subject.Outcome, subject.MatchType)
+	return err
+}
+
+// Due claims the subjects longest due. Claimed rows are skipped by
+// concurrent claims, so replicas can all re-screen without overlapping.
+func (s *rescreenStore) Due(ctx context.Context, before time.Time, limit int) ([]*RescreenSubject, error) {
+	defer metrics.ObserveQuery("rescreen_due", time.Now())
+
+	var subjects []*RescreenSubject
+	err := s.db.SelectContext(ctx, &subjects, `
+		UPDATE rescreen_subjects SET screened_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id FROM rescreen_subjects
+			WHERE consent AND outcome = 'clear' AND screened_at < $1
+			ORDER BY screened_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+rescreenColumns,
+		before, limit)
+	if err != nil {
+		return nil, err
+	}
+	return subjects, nil
+}
+
+// Rescreened records the outcome of re-screening a subject
+func (s *rescreenStore) Rescreened(ctx context.Context, id int64, outcome, matchType string) error {
+	defer metrics.ObserveQuery("rescreen_record", time.Now())
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE rescreen_subjects SET outcome = $2, match_type = $3
+		WHERE id = $1
+	`, id, outcome, matchType)
+	return err
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"blacklist-check/internal/database"
+)
+
+// ResilientBlacklistStore decorates a BlacklistStore with the retries and
+// circuit breaker of a database.Guard, so a brief database failover costs
+// checks some latency rather than failing them. Ping goes straight through,
+// so readiness reflects the database rather than the breaker.
+type ResilientBlacklistStore struct {
+	BlacklistStore
+
+	guard *database.Guard
+}
+
+// NewResilientBlacklistStore wraps next with guard
+func NewResilientBlacklistStore(next BlacklistStore, guard *database.Guard) *ResilientBlacklistStore {
+	return &ResilientBlacklistStore{BlacklistStore: next, guard: guard}
+}
+
+// guarded runs fn under guard, as a read when read is set and a write otherwise
+func guarded[T any](ctx context.Context, guard *database.Guard, read bool, fn func() (T, error)) (T, error) {
+	var result T
+	call := func() error {
+		var err error
+		result, err = fn()
+		return err
+	}
+	var err error
+	if read {
+		err = guard.Read(ctx, call)
+	} else {
+		err = guard.Write(ctx, call)
+	}
+	return result, err
+}
+
+// GetByNIK retrieves a blacklist record by NIK from a list
+func (s *ResilientBlacklistStore) GetByNIK(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, true, func() (*BlacklistRecord, error) {
+		return s.BlacklistStore.GetByNIK(ctx, list, nik)
+	})
+}
+
+// CountNIKs counts the records on every list
+func (s *ResilientBlacklistStore) CountNIKs(ctx context.Context) (int64, error) {
+	return guarded(ctx, s.guard, true, func() (int64, error) {
+		return s.BlacklistStore.CountNIKs(ctx)
+	})
+}
+
+// EachNIK calls fn with the list and NIK of every record. It is retried like
+// a write, since fn has already seen the NIKs read before a failure.
+func (s *ResilientBlacklistStore) EachNIK(ctx context.Context, fn func(list, nik string) error) error {
+	return s.guard.Write(ctx, func() error {
+		return s.BlacklistStore.EachNIK(ctx, fn)
+	})
+}
+
+// GetByFuzzyMatch finds the records of a list with a similar name
+func (s *ResilientBlacklistStore) GetByFuzzyMatch(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*BlacklistRecord, bool, error) {
+	var truncated bool
+	records, err := guarded(ctx, s.guard, true, func() ([]*BlacklistRecord, error) {
+		var (
+			records []*BlacklistRecord
+			err     error
+		)
+		records, truncated, err = s.BlacklistStore.GetByFuzzyMatch(ctx, list, name, birthPlaces, birthDate, minSimilarity, budget, limit)
+		return records, err
+	})
+	return records, truncated, err
+}
+
+// SearchByName searches for blacklist records by name using fuzzy matching
+func (s *ResilientBlacklistStore) SearchByName(ctx context.Context, name string) ([]*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, true, func() ([]*BlacklistRecord, error) {
+		return s.BlacklistStore.SearchByName(ctx, name)
+	})
+}
+
+// GetByPhonetic finds the records of a list whose name sounds alike
+func (s *ResilientBlacklistStore) GetByPhonetic(ctx context.Context, list, name string, birthDate *time.Time) ([]*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, true, func() ([]*BlacklistRecord, error) {
+		return s.BlacklistStore.GetByPhonetic(ctx, list, name, birthDate)
+	})
+}
+
+// NearestByName returns the records in a list with the most similar names
+func (s *ResilientBlacklistStore) NearestByName(ctx context.Context, list, name string, limit int) ([]*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, true, func() ([]*BlacklistRecord, error) {
+		return s.BlacklistStore.NearestByName(ctx, list, name, limit)
+	})
+}
+
+// Search returns records of a list for browsing
+func (s *ResilientBlacklistStore) Search(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, true, func() ([]*BlacklistRecord, error) {
+		return s.BlacklistStore.Search(ctx, list, query, includeDeleted, limit)
+	})
+}
+
+// List pages through the records matching filter
+func (s *ResilientBlacklistStore) List(ctx context.Context, filter RecordFilter, sort, cursor string, limit int) ([]*BlacklistRecord, string, error) {
+	var next string
+	records, err := guarded(ctx, s.guard, true, func() ([]*BlacklistRecord, error) {
+		var (
+			records []*BlacklistRecord
+			err     error
+		)
+		records, next, err = s.BlacklistStore.List(ctx, filter, sort, cursor, limit)
+		return records, err
+	})
+	return records, next, err
+}
+
+// ListBySource retrieves all records a source contributed to a list
+func (s *ResilientBlacklistStore) ListBySource(ctx context.Context, list, source string) ([]*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, true, func() ([]*BlacklistRecord, error) {
+		return s.BlacklistStore.ListBySource(ctx, list, source)
+	})
+}
+
+// Create inserts a new blacklist record
+func (s *ResilientBlacklistStore) Create(ctx context.Context, record *BlacklistRecord) error {
+	return s.guard.Write(ctx, func() error {
+		return s.BlacklistStore.Create(ctx, record)
+	})
+}
+
+// Update modifies an existing blacklist record
+func (s *ResilientBlacklistStore) Update(ctx context.Context, record *BlacklistRecord) error {
+	return s.guard.Write(ctx, func() error {
+		return s.BlacklistStore.Update(ctx, record)
+	})
+}
+
+// Delete soft-deletes a blacklist record
+func (s *ResilientBlacklistStore) Delete(ctx context.Context, list, nik string) error {
+	return s.guard.Write(ctx, func() error {
+		return s.BlacklistStore.Delete(ctx, list, nik)
+	})
+}
+
+// Restore reverses the soft deletion of a blacklist record
+func (s *ResilientBlacklistStore) Restore(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, false, func() (*BlacklistRecord, error) {
+		return s.BlacklistStore.Restore(ctx, list, nik)
+	})
+}
+
+// Confirm makes a live temporary record permanent
+func (s *ResilientBlacklistStore) Confirm(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, false, func() (*BlacklistRecord, error) {
+		return s.BlacklistStore.Confirm(ctx, list, nik)
+	})
+}
+
+// Extend moves the expiry of a live temporary record
+func (s *ResilientBlacklistStore) Extend(ctx context.Context, list, nik string, expiresAt time.Time) (*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, false, func() (*BlacklistRecord, error) {
+		return s.BlacklistStore.Extend(ctx, list, nik, expiresAt)
+	})
+}
+
+// MarkExpiring flags the live temporary records expiring within a duration
+func (s *ResilientBlacklistStore) MarkExpiring(ctx context.Context, within time.Duration) ([]*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, false, func() ([]*BlacklistRecord, error) {
+		return s.BlacklistStore.MarkExpiring(ctx, within)
+	})
+}
+
+// Expire soft-deletes the temporary records past their expiry
+func (s *ResilientBlacklistStore) Expire(ctx context.Context) ([]*BlacklistRecord, error) {
+	return guarded(ctx, s.guard, false, func() ([]*BlacklistRecord, error) {
+		return s.BlacklistStore.Expire(ctx)
+	})
+}
+
+// History returns every recorded change to a NIK
+func (s *ResilientBlacklistStore) History(ctx context.Context, nik string) ([]*RecordChange, error) {
+	return guarded(ctx, s.guard, true, func() ([]*RecordChange, error) {
+		return s.BlacklistStore.History(ctx, nik)
+	})
+}
+
+// ApplyChangeSet writes a change set in a single transaction
+func (s *ResilientBlacklistStore) ApplyChangeSet(ctx context.Context, cs *ChangeSet) error {
+	return s.guard.Write(ctx, func() error {
+		return s.BlacklistStore.ApplyChangeSet(ctx, cs)
+	})
+}
@@ -0,0 +1,338 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Screening job statuses
+const (
+	ScreeningPending   = "pending"
+	ScreeningRunning   = "running"
+	ScreeningCompleted = "completed"
+	ScreeningFailed    = "failed"
+	// ScreeningStalled jobs stopped heartbeating or overran and wait to be requeued
+	ScreeningStalled = "stalled"
+)
+
+// ScreeningJob represents a bulk screening job and its progress
+type ScreeningJob struct {
+	ID          int64      `db:"id"`
+	Tenant      string     `db:"tenant_id"`
+	Status      string     `db:"status"`
+	Total       int        `db:"total"`
+	Processed   int        `db:"processed"`
+	Matched     int        `db:"matched"`
+	Attempts    int        `db:"attempts"`
+	Error       *string    `db:"error"`
+	SubmittedBy string     `db:"submitted_by"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+	CompletedAt *time.Time `db:"completed_at"`
+	// StartedAt is when the current attempt was claimed
+	StartedAt *time.Time `db:"started_at"`
+	StalledAt *time.Time `db:"stalled_at"`
+}
+
+// ScreeningSubject is one person to screen within a job, with its result once processed
+type ScreeningSubject struct {
+	JobID       int64      `db:"job_id"`
+	Seq         int        `db:"seq"`
+	Name        string     `db:"name"`
+	NIK         string     `db:"nik"`
+	BirthPlace  string     `db:"birth_place"`
+	BirthDate   *time.Time `db:"birth_date"`
+	Blacklisted *bool      `db:"blacklisted"`
+	MatchType   *string    `db:"match_type"`
+	Details     *string    `db:"details"`
+	ProcessedAt *time.Time `db:"processed_at"`
+}
+
+// ScreeningStore defines the interface for screening job persistence
+type ScreeningStore interface {
+	Create(ctx context.Context, submittedBy string, subjects []*ScreeningSubject) (int64, error)
+	Get(ctx context.Context, id int64) (*ScreeningJob, error)
+	Claim(ctx context.Context, lease time.Duration) (*ScreeningJob, error)
+	NextBatch(ctx context.Context, jobID int64, limit int) ([]*ScreeningSubject, error)
+	SaveResults(ctx context.Context, jobID int64, results []*ScreeningSubject) error
+	Release(ctx context.Context, id int64, reason string) error
+	Finish(ctx context.Context, id int64, status string, reason *string) error
+	Results(ctx context.Context, jobID int64, fn func(*ScreeningSubject) error) error
+	MarkStalled(ctx context.Context, heartbeat, maxDuration time.Duration, reclaim bool) ([]*ScreeningJob, error)
+	Requeue(ctx context.Context, id int64) (bool, error)
+}
+
+// screeningStore implements ScreeningStore. Jobs belong to the caller's
+// tenant; the workers claiming them act for every tenant.
+type screeningStore struct {
+	db      *sqlx.DB
+	tenancy *Tenancy
+}
+
+// NewScreeningStore creates a new screening store
+func NewScreeningStore(db *sqlx.DB, tenancy *Tenancy) ScreeningStore {
+	return &screeningStore{db: db, tenancy: tenancy}
+}
+
+// Create stores a new pending job for the caller's tenant and its subjects,
+// returning the job ID. Subjects are bulk loaded with COPY so large
+// portfolios submit quickly.
+func (s *screeningStore) Create(ctx context.Context, submittedBy string, subjects []*ScreeningSubject) (int64, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.GetContext(ctx, &id, `
+		INSERT INTO screening_jobs (total, submitted_by, tenant_id)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, len(subjects), submittedBy, s.tenancy.Caller(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("error creating screening job: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("screening_subjects",
+		"job_id", "seq", "name", "nik", "birth_place", "birth_date"))
+	if err != nil {
+		return 0, fmt.Errorf("error preparing subject copy: %w", err)
+	}
+	for i, subject := range subjects {
+		if _, err := stmt.ExecContext(ctx, id, i, subject.Name, subject.NIK, subject.BirthPlace, subject.BirthDate); err != nil {
+			stmt.Close()
+			return 0, fmt.Errorf("error copying subject %d: %w", i, err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return 0, fmt.Errorf("error flushing subject copy: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Get retrieves a screening job of the caller's tenant by ID
+func (s *screeningStore) Get(ctx context.Context, id int64) (*ScreeningJob, error) {
+	var job ScreeningJob
+	err := s.db.GetContext(ctx, &job, `
+		SELECT id, tenant_id, status, total, processed, matched, attempts, error, submitted_by,
+			created_at, updated_at, completed_at, started_at, stalled_at
+		FROM screening_jobs
+		WHERE id = $1 AND tenant_id = $2
+	`, id, s.tenancy.Caller(ctx))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Claim marks the oldest runnable job as running and returns it, or nil if
+// there is none. Running jobs whose lease has expired (the worker stopped
+// heartbeating) are picked up again so a crashed replica's work resumes.
+func (s *screeningStore) Claim(ctx context.Context, lease time.Duration) (*ScreeningJob, error) {
+	var job ScreeningJob
+	err := s.db.GetContext(ctx, &job, `
+		UPDATE screening_jobs
+		SET status = $1, attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP, started_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM screening_jobs
+			WHERE status = $2
+				OR (status = $1 AND updated_at < CURRENT_TIMESTAMP - $3 * INTERVAL '1 second')
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, tenant_id, status, total, processed, matched, attempts, error, submitted_by,
+			created_at, updated_at, completed_at, started_at, stalled_at
+	`, ScreeningRunning, ScreeningPending, lease.Seconds())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// NextBatch retrieves up to limit unprocessed subjects of a job in submission order
+func (s *screeningStore) NextBatch(ctx context.Context, jobID int64, limit int) ([]*ScreeningSubject, error) {
+	var subjects []*ScreeningSubject
+	err := s.db.SelectContext(ctx, &subjects, `
+		SELECT job_id, seq, name, nik, birth_place, birth_date
+		FROM screening_subjects
+		WHERE job_id = $1 AND processed_at IS NULL
+		ORDER BY seq
+		LIMIT $2
+	`, jobID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return subjects, nil
+}
+
+// SaveResults records the results of a batch and advances the job's progress,
+// which also renews its lease. Subjects already processed by another worker
+// are skipped so progress is never counted twice.
+func (s *screeningStore) SaveResults(ctx context.Context, jobID int64, results []*ScreeningSubject) error {
+	seqs := make([]int64, len(results))
+	blacklisted := make([]bool, len(results))
+	matchTypes := make([]string, len(results))
+	details := make([]string, len(results))
+	for i, r := range results {
+		seqs[i] = int64(r.Seq)
+		if r.Blacklisted != nil {
+			blacklisted[i] = *r.Blacklisted
+		}
+		if r.MatchType != nil {
+			matchTypes[i] = *r.MatchType
+		}
+		if r.Details != nil {
+			details[i] = *r.Details
+		}
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var saved []bool
+	err = tx.SelectContext(ctx, &saved, `
+		UPDATE screening_subjects s
+		SET blacklisted = r.blacklisted, match_type = r.match_type,
+			details = NULLIF(r.details, ''), processed_at = CURRENT_TIMESTAMP
+		FROM unnest($2::int[], $3::bool[], $4::text[], $5::text[]) AS r(seq, blacklisted, match_type, details)
+		WHERE s.job_id = $1 AND s.seq = r.seq AND s.processed_at IS NULL
+		RETURNING s.blacklisted
+	`, jobID, pq.Array(seqs), pq.Array(blacklisted), pq.Array(matchTypes), pq.Array(details))
+	if err != nil {
+		return fmt.Errorf("error saving screening results: %w", err)
+	}
+
+	matched := 0
+	for _, b := range saved {
+		if b {
+			matched++
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE screening_jobs
+		SET processed = processed + $2, matched = matched + $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, jobID, len(saved), matched)
+	if err != nil {
+		return fmt.Errorf("error updating screening progress: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Release puts a running job back in the queue after a failed attempt
+func (s *screeningStore) Release(ctx context.Context, id int64, reason string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE screening_jobs
+		SET status = $2, error = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $4
+	`, id, ScreeningPending, reason, ScreeningRunning)
+	return err
+}
+
+// Finish marks a job as completed or failed
+func (s *screeningStore) Finish(ctx context.Context, id int64, status string, reason *string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE screening_jobs
+		SET status = $2, error = $3, updated_at = CURRENT_TIMESTAMP, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, id, status, reason)
+	return err
+}
+
+// MarkStalled flags running jobs that haven't heartbeated within heartbeat or
+// whose attempt has run longer than maxDuration, and returns them. With
+// reclaim they go back in the queue, otherwise they are held as stalled until
+// requeued. Their updated_at is left alone so it still shows the last heartbeat.
+func (s *screeningStore) MarkStalled(ctx context.Context, heartbeat, maxDuration time.Duration, reclaim bool) ([]*ScreeningJob, error) {
+	defer metrics.ObserveQuery("screening_mark_stalled", time.Now())
+
+	status := ScreeningStalled
+	if reclaim {
+		status = ScreeningPending
+	}
+	var jobs []*ScreeningJob
+	err := s.db.SelectContext(ctx, &jobs, `
+		UPDATE screening_jobs
+		SET status = $2, stalled_at = CURRENT_TIMESTAMP, error = 'stalled'
+		WHERE status = $1
+			AND (updated_at < CURRENT_TIMESTAMP - $3 * INTERVAL '1 second'
+				OR started_at < CURRENT_TIMESTAMP - $4 * INTERVAL '1 second')
+		RETURNING id, tenant_id, status, total, processed, matched, attempts, error, submitted_by,
+			created_at, updated_at, completed_at, started_at, stalled_at
+	`, ScreeningRunning, status, heartbeat.Seconds(), maxDuration.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Requeue puts a stalled job back in the queue, reporting whether it was stalled
+func (s *screeningStore) Requeue(ctx context.Context, id int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE screening_jobs
+		SET status = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $3
+	`, id, ScreeningPending, ScreeningStalled)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Results streams the subjects of a job in submission order, so exports of
+// large jobs don't need to be held in memory
+func (s *screeningStore) Results(ctx context.Context, jobID int64, fn func(*ScreeningSubject) error) error {
+	rows, err := s.db.QueryxContext(ctx, `
+		SELECT job_id, seq, name, nik, birth_place, birth_date, blacklisted, match_type, details, processed_at
+		FROM screening_subjects
+		WHERE job_id = $1
+		ORDER BY seq
+	`, jobID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var subject ScreeningSubject
+		if err := rows.StructScan(&subject); err != nil {
+			return err
+		}
+		if err := fn(&subject); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
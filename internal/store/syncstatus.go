@@ -0,0 +1,240 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SyncStatus is the outcome of the latest scheduled sync of an external source
+type SyncStatus struct {
+	Source        string     `db:"source" json:"source"`
+	List          string     `db:"list_type" json:"list"`
+	LastAttemptAt time.Time  `db:"last_attempt_at" json:"last_attempt_at"`
+	LastSuccessAt *time.Time `db:"last_success_at" json:"last_success_at,omitempty"`
+	LastError     *string    `db:"last_error" json:"last_error,omitempty"`
+	Records       int        `db:"records" json:"records"`
+	AddedCount    int        `db:"added_count" json:"added_count"`
+	UpdatedCount  int        `db:"updated_count" json:"updated_count"`
+	DeletedCount  int        `db:"deleted_count" json:"deleted_count"`
+	QuarantineID  *int64     `db:"quarantine_id" json:"quarantine_id,omitempty"`
+	// Quality is the data quality of the last successfully synced file
+	Quality      *SyncQuality `db:"quality" json:"quality,omitempty"`
+	RunningSince *time.Time   `db:"running_since" json:"running_since,omitempty"`
+	HeartbeatAt  *time.Time   `db:"heartbeat_at" json:"heartbeat_at,omitempty"`
+	StalledAt    *time.Time   `db:"stalled_at" json:"stalled_at,omitempty"`
+	// RefreshedAt is when the source's records were last brought up to date,
+	// by an applied run or an approved quarantine. A quarantined run succeeds
+	// without refreshing them.
+	RefreshedAt *time.Time `db:"refreshed_at" json:"refreshed_at,omitempty"`
+	// AgeSeconds is how long ago by the database clock the records were
+	// refreshed, or the source first synced when they never were
+	AgeSeconds float64 `db:"age_seconds" json:"age_seconds"`
+	// MaxAgeSeconds and Stale are filled in by the connector from the age the
+	// source is allowed
+	MaxAgeSeconds  float64    `db:"-" json:"max_age_seconds,omitempty"`
+	Stale          bool       `db:"-" json:"stale"`
+	StaleAlertedAt *time.Time `db:"stale_alerted_at" json:"stale_alerted_at,omitempty"`
+}
+
+// SyncQuality summarises the data quality of the listings in a source file
+type SyncQuality struct {
+	// Score is the mean score of the listings, from 0 (unusable) to 1
+	Score float64 `json:"score"`
+	// Listings is how many listings were scored, and Poor how many of them
+	// scored below half
+	Listings int `json:"listings"`
+	Poor     int `json:"poor"`
+	// Issues counts the listings with each kind of problem
+	Issues map[string]int `json:"issues,omitempty"`
+}
+
+// Value implements driver.Valuer
+func (q SyncQuality) Value() (driver.Value, error) {
+	return json.Marshal(q)
+}
+
+// Scan implements sql.Scanner
+func (q *SyncQuality) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into SyncQuality", src)
+	}
+	return json.Unmarshal(data, q)
+}
+
+// SyncStatusStore defines the interface for sync status access
+type SyncStatusStore interface {
+	Claim(ctx context.Context, source, list string, interval, retry time.Duration) (bool, error)
+	Succeed(ctx context.Context, status *SyncStatus) error
+	Fail(ctx context.Context, source string, cause error) error
+	List(ctx context.Context) ([]*SyncStatus, error)
+	Heartbeat(ctx context.Context, source string) error
+	MarkStalled(ctx context.Context, heartbeat, maxDuration time.Duration, reclaim bool) ([]*SyncStatus, error)
+	Refreshed(ctx context.Context, source string, at time.Time) error
+	AlertStale(ctx context.Context, source string) (bool, error)
+}
+
+// syncStatusStore implements SyncStatusStore
+type syncStatusStore struct {
+	db *sqlx.DB
+}
+
+// NewSyncStatusStore creates a new sync status store
+func NewSyncStatusStore(db *sqlx.DB) SyncStatusStore {
+	return &syncStatusStore{db: db}
+}
+
+// Claim starts a run of source when its last success is older than interval
+// and no other run was attempted within retry, reporting whether the caller
+// should go ahead
+func (s *syncStatusStore) Claim(ctx context.Context, source, list string, interval, retry time.Duration) (bool, error) {
+	defer metrics.ObserveQuery("sync_status_claim", time.Now())
+
+	var claimed string
+	err := s.db.GetContext(ctx, &claimed, `
+		INSERT INTO sync_status (source, list_type, last_attempt_at, running_since, heartbeat_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (source) DO UPDATE
+		SET list_type = EXCLUDED.list_type, last_attempt_at = CURRENT_TIMESTAMP,
+			running_since = CURRENT_TIMESTAMP, heartbeat_at = CURRENT_TIMESTAMP, stalled_at = NULL
+		WHERE (sync_status.last_success_at IS NULL OR sync_status.last_success_at <= CURRENT_TIMESTAMP - $3 * INTERVAL '1 second')
+			AND sync_status.last_attempt_at <= CURRENT_TIMESTAMP - $4 * INTERVAL '1 second'
+		RETURNING source
+	`, source, list, interval.Seconds(), retry.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Succeed records a completed run. A run that wasn't quarantined refreshes
+// the source's records.
+func (s *syncStatusStore) Succeed(ctx context.Context, status *SyncStatus) error {
+	defer metrics.ObserveQuery("sync_status_succeed", time.Now())
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE sync_status
+		SET last_success_at = CURRENT_TIMESTAMP, last_error = NULL, records = $2,
+			added_count = $3, updated_count = $4, deleted_count = $5, quarantine_id = $6,
+			quality = $7, running_since = NULL,
+			refreshed_at = CASE WHEN $6 IS NULL THEN CURRENT_TIMESTAMP ELSE refreshed_at END,
+			stale_alerted_at = CASE WHEN $6 IS NULL THEN NULL ELSE stale_alerted_at END
+		WHERE source = $1
+	`, status.Source, status.Records, status.AddedCount, status.UpdatedCount, status.DeletedCount, status.QuarantineID,
+		status.Quality)
+	return err
+}
+
+// Fail records why a run failed, keeping the counts of the last successful one
+func (s *syncStatusStore) Fail(ctx context.Context, source string, cause error) error {
+	defer metrics.ObserveQuery("sync_status_fail", time.Now())
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE sync_status SET last_error = $2, running_since = NULL WHERE source = $1
+	`, source, cause.Error())
+	return err
+}
+
+// List returns the status of every source that has been synced
+func (s *syncStatusStore) List(ctx context.Context) ([]*SyncStatus, error) {
+	var statuses []*SyncStatus
+	err := s.db.SelectContext(ctx, &statuses, `
+		SELECT source, list_type, last_attempt_at, last_success_at, last_error, records,
+			added_count, updated_count, deleted_count, quarantine_id, quality,
+			running_since, heartbeat_at, stalled_at, refreshed_at, stale_alerted_at,
+			EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - COALESCE(refreshed_at, created_at)) AS age_seconds
+		FROM sync_status
+		ORDER BY source
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Heartbeat records that the running sync of source is still alive
+func (s *syncStatusStore) Heartbeat(ctx context.Context, source string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE sync_status SET heartbeat_at = CURRENT_TIMESTAMP
+		WHERE source = $1 AND running_since IS NOT NULL
+	`, source)
+	return err
+}
+
+// MarkStalled ends running syncs that haven't heartbeated within heartbeat or
+// have run longer than maxDuration, and returns them as they were when they
+// stalled. With reclaim they are due again straight away, otherwise they wait
+// out the retry delay like a failed run.
+func (s *syncStatusStore) MarkStalled(ctx context.Context, heartbeat, maxDuration time.Duration, reclaim bool) ([]*SyncStatus, error) {
+	defer metrics.ObserveQuery("sync_status_mark_stalled", time.Now())
+
+	var statuses []*SyncStatus
+	err := s.db.SelectContext(ctx, &statuses, `
+		UPDATE sync_status s
+		SET running_since = NULL, stalled_at = CURRENT_TIMESTAMP, last_error = 'stalled',
+			last_attempt_at = CASE WHEN $3 THEN 'epoch'::timestamptz ELSE s.last_attempt_at END
+		FROM sync_status old
+		WHERE s.source = old.source
+			AND s.running_since IS NOT NULL
+			AND (s.heartbeat_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+				OR s.running_since < CURRENT_TIMESTAMP - $2 * INTERVAL '1 second')
+		RETURNING s.source, s.list_type, old.last_attempt_at, s.last_success_at, s.last_error, s.records,
+			s.added_count, s.updated_count, s.deleted_count, s.quarantine_id, s.quality,
+			old.running_since, old.heartbeat_at, s.stalled_at
+	`, heartbeat.Seconds(), maxDuration.Seconds(), reclaim)
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Refreshed records that the records of source were brought up to date with
+// a file downloaded at the given time, unless they already were with a later one
+func (s *syncStatusStore) Refreshed(ctx context.Context, source string, at time.Time) error {
+	defer metrics.ObserveQuery("sync_status_refreshed", time.Now())
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE sync_status
+		SET stale_alerted_at = CASE WHEN refreshed_at IS NULL OR refreshed_at < $2 THEN NULL ELSE stale_alerted_at END,
+			refreshed_at = GREATEST(refreshed_at, $2)
+		WHERE source = $1
+	`, source, at)
+	return err
+}
+
+// AlertStale claims the alert for a source whose records went stale,
+// reporting whether the caller should raise it. Only one alert is claimed
+// per source until its records are refreshed.
+func (s *syncStatusStore) AlertStale(ctx context.Context, source string) (bool, error) {
+	defer metrics.ObserveQuery("sync_status_alert_stale", time.Now())
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE sync_status SET stale_alerted_at = CURRENT_TIMESTAMP
+		WHERE source = $1 AND stale_alerted_at IS NULL
+	`, source)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
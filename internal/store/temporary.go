@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"blacklist-check/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// temporaryColumns are returned for temporary records
+const temporaryColumns = `id, tenant_id, list_type, nik, nik_encrypted, name, birth_place, birth_date, reason, reason_code, reason_params, source,
+	created_at, updated_at, expires_at`
+
+// Confirm makes a live temporary record permanent
+func (s *blacklistStore) Confirm(ctx context.Context, list, nik string) (*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("confirm", time.Now())
+
+	tenant, err := s.tenancy.writer(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	condition, nikArgs := nikCondition(s.keys, "", "$3", "$4", nik)
+	var record BlacklistRecord
+	err = inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &record, `
+			UPDATE blacklist
+			SET expires_at = NULL, expiry_notified_at = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE list_type = $1 AND tenant_id = $2 AND `+condition+` AND deleted_at IS NULL AND expires_at IS NOT NULL
+			RETURNING `+temporaryColumns, append([]interface{}{list, tenant}, nikArgs...)...)
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	record.NIK = nik
+	return &record, nil
+}
+
+// Extend moves the expiry of a live temporary record, resetting its expiry notice
+func (s *blacklistStore) Extend(ctx context.Context, list, nik string, expiresAt time.Time) (*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("extend", time.Now())
+
+	tenant, err := s.tenancy.writer(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	condition, nikArgs := nikCondition(s.keys, "", "$4", "$5", nik)
+	var record BlacklistRecord
+	err = inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &record, `
+			UPDATE blacklist
+			SET expires_at = $3, expiry_notified_at = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE list_type = $1 AND tenant_id = $2 AND `+condition+` AND deleted_at IS NULL AND expires_at IS NOT NULL
+			RETURNING `+temporaryColumns, append([]interface{}{list, tenant, expiresAt}, nikArgs...)...)
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	record.NIK = nik
+	return &record, nil
+}
+
+// MarkExpiring flags the live temporary records of every tenant expiring
+// within the given window that haven't been flagged yet and returns them.
+// Each record is claimed by a single conditional update, so replicas don't
+// notify twice. Pinned records are left for when their pins are released.
+func (s *blacklistStore) MarkExpiring(ctx context.Context, within time.Duration) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("mark_expiring", time.Now())
+
+	var records []*BlacklistRecord
+	err := s.db.SelectContext(ctx, &records, `
+		UPDATE blacklist
+		SET expiry_notified_at = CURRENT_TIMESTAMP
+		WHERE deleted_at IS NULL AND expiry_notified_at IS NULL
+			AND expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP + $1 * INTERVAL '1 second'
+			AND NOT `+pinnedCondition+`
+		RETURNING `+temporaryColumns, within.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	if err := revealRecords(s.keys, records...); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Expire soft-deletes the temporary records of every tenant whose expiry has
+// passed, attributing it to the actor in ctx, and returns them. Pinned
+// records stay until their pins are released and expire on the next sweep
+// after.
+func (s *blacklistStore) Expire(ctx context.Context) ([]*BlacklistRecord, error) {
+	defer metrics.ObserveQuery("expire", time.Now())
+
+	var records []*BlacklistRecord
+	err := inActorTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, &records, `
+			UPDATE blacklist
+			SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $1
+			WHERE deleted_at IS NULL AND expires_at <= CURRENT_TIMESTAMP
+				AND NOT `+pinnedCondition+`
+			RETURNING `+temporaryColumns, Actor(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := revealRecords(s.keys, records...); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
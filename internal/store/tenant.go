@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// DefaultTenant owns the records of callers without a tenant, and those of
+// shared lists
+const DefaultTenant = ""
+
+// ErrSharedList is returned when a caller with a tenant writes to a list
+// every tenant shares
+var ErrSharedList = errors.New("list is shared by every tenant")
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx acting for tenant, whose records the
+// stores read and write under isolation
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant stored in ctx, or DefaultTenant when there is none
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Tenancy decides which tenant's records a caller reads and writes. Without
+// isolation every caller acts for the default tenant. With it, records belong
+// to the tenant that wrote them, except those of shared lists, which are kept
+// once under the default tenant: every tenant reads them, but only callers
+// without a tenant write them.
+type Tenancy struct {
+	isolated bool
+	shared   map[string]bool
+}
+
+// NewTenancy creates the tenancy of a deployment, isolated or not, with the
+// given shared lists
+func NewTenancy(isolated bool, sharedLists []string) *Tenancy {
+	t := &Tenancy{isolated: isolated, shared: make(map[string]bool, len(sharedLists))}
+	for _, list := range sharedLists {
+		t.shared[list] = true
+	}
+	return t
+}
+
+// Caller returns the tenant the caller in ctx acts for: its own under
+// isolation, else the default tenant
+func (t *Tenancy) Caller(ctx context.Context) string {
+	if t == nil || !t.isolated {
+		return DefaultTenant
+	}
+	return Tenant(ctx)
+}
+
+// Owner returns the tenant whose records of list the caller in ctx reads
+func (t *Tenancy) Owner(ctx context.Context, list string) string {
+	if t != nil && t.shared[list] {
+		return DefaultTenant
+	}
+	return t.Caller(ctx)
+}
+
+// writer returns the tenant whose records of list the caller in ctx writes,
+// or ErrSharedList when it has a tenant and list is shared
+func (t *Tenancy) writer(ctx context.Context, list string) (string, error) {
+	caller := t.Caller(ctx)
+	if caller != DefaultTenant && t.shared[list] {
+		return "", ErrSharedList
+	}
+	return caller, nil
+}
+
+// sharedLists returns the shared lists as a query argument, for conditions
+// on records of any list
+func (t *Tenancy) sharedLists() pq.StringArray {
+	var lists pq.StringArray
+	if t != nil {
+		for list := range t.shared {
+			lists = append(lists, list)
+		}
+	}
+	return lists
+}
+
+// ownerCondition restricts rows of any list to those the caller reads, given
+// the table prefix of the columns and the placeholders of the shared lists
+// and the caller's tenant, in that order
+func ownerCondition(prefix, shared, tenant string) string {
+	return prefix + "tenant_id = CASE WHEN " + prefix + "list_type = ANY(" + shared + ") THEN '' ELSE " + tenant + " END"
+}
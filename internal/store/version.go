@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"blacklist-check/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// VersionRecords names the change counter of blacklist records and their aliases
+const VersionRecords = "blacklist"
+
+// VersionStore defines the interface for reading table change counters
+type VersionStore interface {
+	// Version returns the change counter of table, which triggers bump
+	// whenever a statement changes its rows
+	Version(ctx context.Context, table string) (int64, error)
+}
+
+// versionStore implements VersionStore on every driver
+type versionStore struct {
+	db *sqlx.DB
+}
+
+// NewVersionStore creates a new table version store
+func NewVersionStore(db *sqlx.DB) VersionStore {
+	return &versionStore{db: db}
+}
+
+// Version reads the counter of table
+func (s *versionStore) Version(ctx context.Context, table string) (int64, error) {
+	defer metrics.ObserveQuery("table_version", time.Now())
+
+	var version int64
+	err := s.db.GetContext(ctx, &version, s.db.Rebind(`SELECT version FROM table_versions WHERE table_name = ?`), table)
+	return version, err
+}
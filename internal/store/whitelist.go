@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/nikcrypt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrWhitelistEntryNotFound is returned when a whitelist entry does not exist or is no longer active
+var ErrWhitelistEntryNotFound = errors.New("whitelist entry not found")
+
+// WhitelistEntry clears a subject of a match on one record until it expires.
+// The subject is identified by NIK, or by name and birth date when NIK is empty.
+type WhitelistEntry struct {
+	ID             int64      `db:"id" json:"id"`
+	RecordID       int64      `db:"record_id" json:"record_id"`
+	NIK            string     `db:"nik" json:"nik,omitempty"`
+	NIKEncrypted   string     `db:"nik_encrypted" json:"-"`
+	Name           string     `db:"name" json:"name,omitempty"`
+	NameNormalized string     `db:"name_normalized" json:"-"`
+	BirthDate      *time.Time `db:"birth_date" json:"birth_date,omitempty"`
+	Reason         string     `db:"reason" json:"reason"`
+	CreatedBy      string     `db:"created_by" json:"created_by"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt      time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt      *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	RevokedBy      *string    `db:"revoked_by" json:"revoked_by,omitempty"`
+}
+
+// WhitelistStore defines the interface for whitelist access
+type WhitelistStore interface {
+	Create(ctx context.Context, entry *WhitelistEntry) error
+	List(ctx context.Context, includeInactive bool) ([]*WhitelistEntry, error)
+	Revoke(ctx context.Context, id int64, revokedBy string) (*WhitelistEntry, error)
+	// Active returns the unexpired, unrevoked entries for a subject
+	Active(ctx context.Context, nik, nameNormalized string, birthDate *time.Time) ([]*WhitelistEntry, error)
+}
+
+// whitelistStore implements WhitelistStore. Entries belong to the caller's
+// tenant, and may clear it of matches on any record it reads.
+type whitelistStore struct {
+	db *sqlx.DB
+	// keys protect the NIKs of entries like those of records
+	keys    *nikcrypt.Keys
+	tenancy *Tenancy
+}
+
+// NewWhitelistStore creates a new whitelist store
+func NewWhitelistStore(db *sqlx.DB, keys *nikcrypt.Keys, tenancy *Tenancy) WhitelistStore {
+	return &whitelistStore{db: db, keys: keys, tenancy: tenancy}
+}
+
+const whitelistColumns = `id, record_id, nik, nik_encrypted, name, name_normalized, birth_date, reason, created_by, created_at, expires_at, revoked_at, revoked_by`
+
+// Create inserts an entry, filling in its ID and creation time. It returns
+// ErrRecordNotFound when the entry's record doesn't exist or belongs to
+// another tenant.
+func (s *whitelistStore) Create(ctx context.Context, entry *WhitelistEntry) error {
+	nik := entry.NIK
+	plain, encrypted, mac := protectNIK(s.keys, nik)
+	err := s.db.GetContext(ctx, entry, `
+		INSERT INTO whitelist (record_id, nik, name, name_normalized, birth_date, reason, created_by, expires_at, tenant_id, nik_encrypted, nik_hmac)
+		SELECT id, $2, $3, $4, $5, $6, $7, $8, $10, $11, $12
+		FROM blacklist
+		WHERE id = $1 AND `+ownerCondition("", "$9", "$10")+`
+		RETURNING `+whitelistColumns,
+		entry.RecordID, plain, entry.Name, entry.NameNormalized, nullDate(entry.BirthDate),
+		entry.Reason, entry.CreatedBy, entry.ExpiresAt, s.tenancy.sharedLists(), s.tenancy.Caller(ctx), encrypted, mac)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+	entry.NIK = nik
+	return err
+}
+
+// List returns the active entries, or every entry when includeInactive is set, newest first
+func (s *whitelistStore) List(ctx context.Context, includeInactive bool) ([]*WhitelistEntry, error) {
+	var entries []*WhitelistEntry
+	err := s.db.SelectContext(ctx, &entries, `
+		SELECT `+whitelistColumns+`
+		FROM whitelist
+		WHERE tenant_id = $2 AND ($1 OR (revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP))
+		ORDER BY id DESC
+	`, includeInactive, s.tenancy.Caller(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(entries...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Revoke ends an active entry early
+func (s *whitelistStore) Revoke(ctx context.Context, id int64, revokedBy string) (*WhitelistEntry, error) {
+	var entry WhitelistEntry
+	err := s.db.GetContext(ctx, &entry, `
+		UPDATE whitelist
+		SET revoked_at = CURRENT_TIMESTAMP, revoked_by = $2
+		WHERE id = $1 AND tenant_id = $3 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING `+whitelistColumns,
+		id, revokedBy, s.tenancy.Caller(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWhitelistEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Active returns the unexpired, unrevoked entries for a subject: those with
+// its NIK, and those without a NIK that have its name and birth date
+func (s *whitelistStore) Active(ctx context.Context, nik, nameNormalized string, birthDate *time.Time) ([]*WhitelistEntry, error) {
+	defer metrics.ObserveQuery("whitelist_active", time.Now())
+
+	condition, _ := nikCondition(s.keys, "", "$5", "$1", nik)
+	var entries []*WhitelistEntry
+	err := s.db.SelectContext(ctx, &entries, `
+		SELECT `+whitelistColumns+`
+		FROM whitelist
+		WHERE tenant_id = $4 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+			AND (($1 <> '' AND `+condition+`)
+				OR (nik = '' AND nik_hmac = '' AND name_normalized = $2 AND birth_date IS NOT DISTINCT FROM $3))
+	`, nik, nameNormalized, nullDate(birthDate), s.tenancy.Caller(ctx), nikMACs(s.keys, []string{nik}))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reveal(entries...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// reveal fills in the NIK of entries that store it encrypted
+func (s *whitelistStore) reveal(entries ...*WhitelistEntry) error {
+	for _, entry := range entries {
+		nik, err := revealNIK(s.keys, entry.NIK, entry.NIKEncrypted)
+		if err != nil {
+			return fmt.Errorf("whitelist entry %d: %w", entry.ID, err)
+		}
+		entry.NIK = nik
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Package tenant serves the tenants that share a deployment. A caller's
+// tenant comes from its credentials: the tenant of its API key, HMAC key or
+// client certificate mapping, or its JWT's tenant claim. The tenant is put in
+// the request context, where the stores scope records to it under
+// TENANT_ISOLATION, and each tenant's requests may be rate limited.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"blacklist-check/internal/apierror"
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/lists"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// ErrRateLimited is returned when a tenant has used up its request budget
+var ErrRateLimited = errors.New("tenant rate limit exceeded")
+
+// Tenancy returns the tenancy TENANT_ISOLATION and TENANT_SHARED_LISTS
+// configure
+func Tenancy(cfg *config.Config) (*store.Tenancy, error) {
+	var shared []string
+	for _, list := range strings.Split(cfg.Tenant.SharedLists, ",") {
+		if list = strings.TrimSpace(list); list != "" {
+			shared = append(shared, list)
+		}
+	}
+	if err := lists.Validate(shared); err != nil {
+		return nil, fmt.Errorf("invalid TENANT_SHARED_LISTS: %w", err)
+	}
+	return store.NewTenancy(cfg.Tenant.Isolation, shared), nil
+}
+
+// RateLimit is the request budget of one tenant
+type RateLimit struct {
+	// Rate is the sustained number of requests per second
+	Rate float64
+	// Burst is the number of requests that may be made back to back
+	Burst int
+}
+
+// ParseRateLimits parses per-tenant limits of the form
+//
+//	acme=50:100,globex=5:10
+//
+// mapping a tenant to <requests per second>:<burst>
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, value, ok := strings.Cut(entry, "=")
+		rate, burst, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 || tenant == "" {
+			return nil, fmt.Errorf("invalid tenant rate limit entry %q", entry)
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("invalid rate in tenant rate limit entry %q", entry)
+		}
+		b, err := strconv.Atoi(burst)
+		if err != nil || b < 1 {
+			return nil, fmt.Errorf("invalid burst in tenant rate limit entry %q", entry)
+		}
+		limits[tenant] = RateLimit{Rate: r, Burst: b}
+	}
+	return limits, nil
+}
+
+// Resolver puts each caller's tenant in its request context and enforces
+// the tenants' rate limits. Limits are kept per replica, so a tenant's
+// budget across the deployment is its limit times the replicas.
+type Resolver struct {
+	log     *zap.Logger
+	buckets map[string]*bucket
+}
+
+// NewResolver creates the resolver of the tenants TENANT_RATE_LIMITS limits
+func NewResolver(cfg *config.Config, log *zap.Logger) (*Resolver, error) {
+	limits, err := ParseRateLimits(cfg.Tenant.RateLimits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TENANT_RATE_LIMITS: %w", err)
+	}
+	buckets := make(map[string]*bucket, len(limits))
+	for tenant, limit := range limits {
+		buckets[tenant] = newBucket(limit)
+	}
+	return &Resolver{log: log, buckets: buckets}, nil
+}
+
+// Context returns ctx acting for the tenant of the identity authenticated in
+// it, or ErrRateLimited when the tenant has no request left to make
+func (r *Resolver) Context(ctx context.Context) (context.Context, error) {
+	var tenant string
+	if identity := auth.FromContext(ctx); identity != nil {
+		tenant = identity.Tenant
+	}
+	if b, ok := r.buckets[tenant]; ok && !b.take() {
+		r.log.Debug("Tenant rate limited", zap.String("tenant", tenant))
+		return nil, ErrRateLimited
+	}
+	return store.WithTenant(ctx, tenant), nil
+}
+
+// Middleware resolves the tenant of each request, answering 429 once the
+// tenant's rate limit is exceeded. It must run after authentication.
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, err := r.Context(req.Context())
+		if err != nil {
+			apierror.RateLimited(w, req)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// bucket is a token bucket refilling at rate per second up to burst
+type bucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(limit RateLimit) *bucket {
+	return &bucket{
+		rate:   limit.Rate,
+		burst:  float64(limit.Burst),
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// take spends a token, reporting false when none is left
+func (b *bucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,40 @@
+package tenant
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]RateLimit
+		wantErr bool
+	}{
+		{spec: "", want: map[string]RateLimit{}},
+		{
+			spec: "acme=50:100, globex=0.5:1,",
+			want: map[string]RateLimit{
+				"acme":   {Rate: 50, Burst: 100},
+				"globex": {Rate: 0.5, Burst: 1},
+			},
+		},
+		{spec: "acme", wantErr: true},
+		{spec: "acme=50", wantErr: true},
+		{spec: "=50:100", wantErr: true},
+		{spec: "acme=fast:100", wantErr: true},
+		{spec: "acme=0:100", wantErr: true},
+		{spec: "acme=50:0", wantErr: true},
+		{spec: "acme=50:1.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRateLimits(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRateLimits(%q) error = %v, wantErr %t", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRateLimits(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
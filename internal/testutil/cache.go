@@ -0,0 +1,239 @@
+// Package testutil holds in-memory fakes of the interfaces the handlers and
+// service depend on, so they can be exercised without Postgres or Redis.
+package testutil
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"blacklist-check/internal/cache"
+)
+
+// Cache is an in-memory cache.Cache. Values expire like they would in Redis,
+// and messages reach the subscribers of the same Cache.
+type Cache struct {
+	mu          sync.Mutex
+	values      map[string]cacheEntry
+	counters    map[string]int64
+	scores      map[string]map[string]float64
+	subscribers map[string][]chan string
+	// Now tells the time entries expire against; time.Now when nil
+	Now func() time.Time
+	// Err, when set, is returned by every call instead of doing it, to
+	// simulate Redis being down
+	Err error
+}
+
+// cacheEntry is a value and when it expires
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+var _ cache.Cache = (*Cache)(nil)
+
+// NewCache creates an empty in-memory cache
+func NewCache() *Cache {
+	return &Cache{
+		values:      make(map[string]cacheEntry),
+		counters:    make(map[string]int64),
+		scores:      make(map[string]map[string]float64),
+		subscribers: make(map[string][]chan string),
+	}
+}
+
+// now returns the time entries expire against
+func (c *Cache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Get returns the value under key, or cache.ErrMiss when there is none or it
+// has expired
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	entry, ok := c.values[key]
+	if !ok || (!entry.expires.IsZero() && !c.now().Before(entry.expires)) {
+		return nil, cache.ErrMiss
+	}
+	return append([]byte(nil), entry.value...), nil
+}
+
+// Set stores value under key for ttl, or indefinitely when ttl is 0
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return c.Err
+	}
+	entry := cacheEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expires = c.now().Add(ttl)
+	}
+	c.values[key] = entry
+	return nil
+}
+
+// Counter returns the counter under key, 0 when it was never incremented
+func (c *Cache) Counter(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return 0, c.Err
+	}
+	return c.counters[key], nil
+}
+
+// IncrScore adds one to the score of member in the sorted set under key
+func (c *Cache) IncrScore(ctx context.Context, key, member string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return c.Err
+	}
+	if c.scores[key] == nil {
+		c.scores[key] = make(map[string]float64)
+	}
+	c.scores[key][member]++
+	return nil
+}
+
+// TopScores returns the n members of the sorted set under key with the
+// highest scores, highest first. Ties are ordered like Redis orders them,
+// by member in reverse.
+func (c *Cache) TopScores(ctx context.Context, key string, n int) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	members := c.ranked(key)
+	if n < len(members) {
+		members = members[:n]
+	}
+	return members, nil
+}
+
+// TrimScores drops all but the keep members of the sorted set under key with
+// the highest scores
+func (c *Cache) TrimScores(ctx context.Context, key string, keep int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return c.Err
+	}
+	for i, member := range c.ranked(key) {
+		if i >= keep {
+			delete(c.scores[key], member)
+		}
+	}
+	return nil
+}
+
+// ranked returns the members of the sorted set under key, highest score first
+func (c *Cache) ranked(key string) []string {
+	scores := c.scores[key]
+	members := make([]string, 0, len(scores))
+	for member := range scores {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if scores[members[i]] != scores[members[j]] {
+			return scores[members[i]] > scores[members[j]]
+		}
+		return members[i] > members[j]
+	})
+	return members
+}
+
+// Apply makes the changes of a batch under one lock and returns how many of
+// its keys held a live value
+func (c *Cache) Apply(ctx context.Context, batch cache.Batch) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return 0, c.Err
+	}
+	var deleted int64
+	now := c.now()
+	for _, key := range batch.Delete {
+		if entry, ok := c.values[key]; ok {
+			if entry.expires.IsZero() || now.Before(entry.expires) {
+				deleted++
+			}
+			delete(c.values, key)
+		}
+	}
+	for _, key := range batch.Incr {
+		c.counters[key]++
+	}
+	for _, msg := range batch.Publish {
+		for _, ch := range c.subscribers[msg.Channel] {
+			// Like Redis, a subscriber that can't keep up loses messages
+			select {
+			case ch <- msg.Payload:
+			default:
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// Subscribe calls fn with the payload of every message published on channel
+// until ctx is cancelled
+func (c *Cache) Subscribe(ctx context.Context, channel string, fn func(payload string)) {
+	ch := make(chan string, 64)
+	c.mu.Lock()
+	c.subscribers[channel] = append(c.subscribers[channel], ch)
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		subscribers := c.subscribers[channel]
+		for i, sub := range subscribers {
+			if sub == ch {
+				c.subscribers[channel] = append(subscribers[:i], subscribers[i+1:]...)
+				break
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-ch:
+			fn(payload)
+		}
+	}
+}
+
+// Ping returns Err
+func (c *Cache) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Err
+}
+
+// Keys returns the keys holding a live value, for asserting on what was cached
+func (c *Cache) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	keys := make([]string, 0, len(c.values))
+	for key, entry := range c.values {
+		if entry.expires.IsZero() || now.Before(entry.expires) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
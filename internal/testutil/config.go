@@ -0,0 +1,23 @@
+package testutil
+
+import (
+	"testing"
+
+	"blacklist-check/pkg/config"
+)
+
+// Config returns the default configuration, with the connection settings
+// that have no default filled in. Tests change the fields they exercise.
+func Config(t testing.TB) *config.Config {
+	t.Helper()
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_USER", "blacklist")
+	t.Setenv("DB_NAME", "blacklist")
+	t.Setenv("REDIS_HOST", "localhost")
+	t.Setenv("AUTH_POLICY", "/=none")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	return cfg
+}
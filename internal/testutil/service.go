@@ -0,0 +1,282 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+)
+
+// ErrNotStubbed is returned by a Service method whose func wasn't set
+var ErrNotStubbed = errors.New("testutil: method not stubbed")
+
+// Service is a service.Service whose methods call the func of the same name.
+// A method whose func isn't set returns ErrNotStubbed, or the zero value when
+// it can't fail.
+type Service struct {
+	CheckBlacklistFunc        func(ctx context.Context, req service.CheckRequest) (*service.CheckResult, error)
+	CheckIDFunc               func(req service.CheckRequest) (*service.IDCheck, error)
+	RecentChecksFunc          func(ctx context.Context, limit int) ([]*store.CheckHistoryEntry, error)
+	RecacheFunc               func(ctx context.Context, niks []string, subjects []service.CheckRequest, recheck bool) (*service.Recache, error)
+	ListVersionFunc           func(ctx context.Context) int64
+	PolicyFunc                func() service.MatchPolicy
+	DryRunFunc                func(ctx context.Context, req service.CheckRequest, policy service.MatchPolicy) (*service.DryRun, error)
+	SimulateFunc              func(ctx context.Context, req service.CheckRequest, proposed service.MatchPolicy) (*service.Simulation, error)
+	ListRecordsFunc           func(ctx context.Context, filter store.RecordFilter, sort, cursor string, limit int) ([]*store.BlacklistRecord, string, error)
+	SearchRecordsFunc         func(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*store.BlacklistRecord, error)
+	RecordHistoryFunc         func(ctx context.Context, nik string) ([]*store.RecordChange, error)
+	CreateRecordFunc          func(ctx context.Context, record *store.BlacklistRecord) error
+	UpdateRecordFunc          func(ctx context.Context, record *store.BlacklistRecord) error
+	DeleteRecordFunc          func(ctx context.Context, list, nik string) error
+	RestoreRecordFunc         func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error)
+	CreateTemporaryRecordFunc func(ctx context.Context, record *store.BlacklistRecord, days int) error
+	ConfirmRecordFunc         func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error)
+	ExtendRecordFunc          func(ctx context.Context, list, nik string, days int) (*store.BlacklistRecord, error)
+	InvalidateRecordsFunc     func(ctx context.Context, niks ...string) error
+	CreateWhitelistEntryFunc  func(ctx context.Context, entry *store.WhitelistEntry, ttl time.Duration) error
+	ListWhitelistFunc         func(ctx context.Context, includeInactive bool) ([]*store.WhitelistEntry, error)
+	RevokeWhitelistEntryFunc  func(ctx context.Context, id int64) (*store.WhitelistEntry, error)
+	PinRecordFunc             func(ctx context.Context, list, nik, caseID, reason string) (*store.Pin, error)
+	UnpinRecordFunc           func(ctx context.Context, id int64) (*store.Pin, error)
+	ListPinsFunc              func(ctx context.Context, filter store.PinFilter) ([]*store.Pin, error)
+	CloseCaseFunc             func(ctx context.Context, caseID string) ([]*store.Pin, error)
+	ListCasesFunc             func(ctx context.Context, filter store.CaseFilter) ([]*store.ReviewCase, error)
+	GetCaseFunc               func(ctx context.Context, id int64) (*store.ReviewCase, error)
+	UpdateCaseFunc            func(ctx context.Context, id int64, update store.CaseUpdate) (*store.ReviewCase, error)
+}
+
+var _ service.Service = (*Service)(nil)
+
+// CheckBlacklist calls CheckBlacklistFunc
+func (s *Service) CheckBlacklist(ctx context.Context, req service.CheckRequest) (*service.CheckResult, error) {
+	if s.CheckBlacklistFunc != nil {
+		return s.CheckBlacklistFunc(ctx, req)
+	}
+	return nil, ErrNotStubbed
+}
+
+// CheckID calls CheckIDFunc
+func (s *Service) CheckID(req service.CheckRequest) (*service.IDCheck, error) {
+	if s.CheckIDFunc != nil {
+		return s.CheckIDFunc(req)
+	}
+	return nil, ErrNotStubbed
+}
+
+// RecentChecks calls RecentChecksFunc
+func (s *Service) RecentChecks(ctx context.Context, limit int) ([]*store.CheckHistoryEntry, error) {
+	if s.RecentChecksFunc != nil {
+		return s.RecentChecksFunc(ctx, limit)
+	}
+	return nil, ErrNotStubbed
+}
+
+// Recache calls RecacheFunc
+func (s *Service) Recache(ctx context.Context, niks []string, subjects []service.CheckRequest, recheck bool) (*service.Recache, error) {
+	if s.RecacheFunc != nil {
+		return s.RecacheFunc(ctx, niks, subjects, recheck)
+	}
+	return nil, ErrNotStubbed
+}
+
+// ListVersion calls ListVersionFunc
+func (s *Service) ListVersion(ctx context.Context) int64 {
+	if s.ListVersionFunc != nil {
+		return s.ListVersionFunc(ctx)
+	}
+	return 0
+}
+
+// Policy calls PolicyFunc
+func (s *Service) Policy() service.MatchPolicy {
+	if s.PolicyFunc != nil {
+		return s.PolicyFunc()
+	}
+	return service.MatchPolicy{}
+}
+
+// DryRun calls DryRunFunc
+func (s *Service) DryRun(ctx context.Context, req service.CheckRequest, policy service.MatchPolicy) (*service.DryRun, error) {
+	if s.DryRunFunc != nil {
+		return s.DryRunFunc(ctx, req, policy)
+	}
+	return nil, ErrNotStubbed
+}
+
+// Simulate calls SimulateFunc
+func (s *Service) Simulate(ctx context.Context, req service.CheckRequest, proposed service.MatchPolicy) (*service.Simulation, error) {
+	if s.SimulateFunc != nil {
+		return s.SimulateFunc(ctx, req, proposed)
+	}
+	return nil, ErrNotStubbed
+}
+
+// ListRecords calls ListRecordsFunc
+func (s *Service) ListRecords(ctx context.Context, filter store.RecordFilter, sort, cursor string, limit int) ([]*store.BlacklistRecord, string, error) {
+	if s.ListRecordsFunc != nil {
+		return s.ListRecordsFunc(ctx, filter, sort, cursor, limit)
+	}
+	return nil, "", ErrNotStubbed
+}
+
+// SearchRecords calls SearchRecordsFunc
+func (s *Service) SearchRecords(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*store.BlacklistRecord, error) {
+	if s.SearchRecordsFunc != nil {
+		return s.SearchRecordsFunc(ctx, list, query, includeDeleted, limit)
+	}
+	return nil, ErrNotStubbed
+}
+
+// RecordHistory calls RecordHistoryFunc
+func (s *Service) RecordHistory(ctx context.Context, nik string) ([]*store.RecordChange, error) {
+	if s.RecordHistoryFunc != nil {
+		return s.RecordHistoryFunc(ctx, nik)
+	}
+	return nil, ErrNotStubbed
+}
+
+// CreateRecord calls CreateRecordFunc
+func (s *Service) CreateRecord(ctx context.Context, record *store.BlacklistRecord) error {
+	if s.CreateRecordFunc != nil {
+		return s.CreateRecordFunc(ctx, record)
+	}
+	return ErrNotStubbed
+}
+
+// UpdateRecord calls UpdateRecordFunc
+func (s *Service) UpdateRecord(ctx context.Context, record *store.BlacklistRecord) error {
+	if s.UpdateRecordFunc != nil {
+		return s.UpdateRecordFunc(ctx, record)
+	}
+	return ErrNotStubbed
+}
+
+// DeleteRecord calls DeleteRecordFunc
+func (s *Service) DeleteRecord(ctx context.Context, list, nik string) error {
+	if s.DeleteRecordFunc != nil {
+		return s.DeleteRecordFunc(ctx, list, nik)
+	}
+	return ErrNotStubbed
+}
+
+// RestoreRecord calls RestoreRecordFunc
+func (s *Service) RestoreRecord(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	if s.RestoreRecordFunc != nil {
+		return s.RestoreRecordFunc(ctx, list, nik)
+	}
+	return nil, ErrNotStubbed
+}
+
+// CreateTemporaryRecord calls CreateTemporaryRecordFunc
+func (s *Service) CreateTemporaryRecord(ctx context.Context, record *store.BlacklistRecord, days int) error {
+	if s.CreateTemporaryRecordFunc != nil {
+		return s.CreateTemporaryRecordFunc(ctx, record, days)
+	}
+	return ErrNotStubbed
+}
+
+// ConfirmRecord calls ConfirmRecordFunc
+func (s *Service) ConfirmRecord(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	if s.ConfirmRecordFunc != nil {
+		return s.ConfirmRecordFunc(ctx, list, nik)
+	}
+	return nil, ErrNotStubbed
+}
+
+// ExtendRecord calls ExtendRecordFunc
+func (s *Service) ExtendRecord(ctx context.Context, list, nik string, days int) (*store.BlacklistRecord, error) {
+	if s.ExtendRecordFunc != nil {
+		return s.ExtendRecordFunc(ctx, list, nik, days)
+	}
+	return nil, ErrNotStubbed
+}
+
+// InvalidateRecords calls InvalidateRecordsFunc
+func (s *Service) InvalidateRecords(ctx context.Context, niks ...string) error {
+	if s.InvalidateRecordsFunc != nil {
+		return s.InvalidateRecordsFunc(ctx, niks...)
+	}
+	return ErrNotStubbed
+}
+
+// CreateWhitelistEntry calls CreateWhitelistEntryFunc
+func (s *Service) CreateWhitelistEntry(ctx context.Context, entry *store.WhitelistEntry, ttl time.Duration) error {
+	if s.CreateWhitelistEntryFunc != nil {
+		return s.CreateWhitelistEntryFunc(ctx, entry, ttl)
+	}
+	return ErrNotStubbed
+}
+
+// ListWhitelist calls ListWhitelistFunc
+func (s *Service) ListWhitelist(ctx context.Context, includeInactive bool) ([]*store.WhitelistEntry, error) {
+	if s.ListWhitelistFunc != nil {
+		return s.ListWhitelistFunc(ctx, includeInactive)
+	}
+	return nil, ErrNotStubbed
+}
+
+// RevokeWhitelistEntry calls RevokeWhitelistEntryFunc
+func (s *Service) RevokeWhitelistEntry(ctx context.Context, id int64) (*store.WhitelistEntry, error) {
+	if s.RevokeWhitelistEntryFunc != nil {
+		return s.RevokeWhitelistEntryFunc(ctx, id)
+	}
+	return nil, ErrNotStubbed
+}
+
+// PinRecord calls PinRecordFunc
+func (s *Service) PinRecord(ctx context.Context, list, nik, caseID, reason string) (*store.Pin, error) {
+	if s.PinRecordFunc != nil {
+		return s.PinRecordFunc(ctx, list, nik, caseID, reason)
+	}
+	return nil, ErrNotStubbed
+}
+
+// UnpinRecord calls UnpinRecordFunc
+func (s *Service) UnpinRecord(ctx context.Context, id int64) (*store.Pin, error) {
+	if s.UnpinRecordFunc != nil {
+		return s.UnpinRecordFunc(ctx, id)
+	}
+	return nil, ErrNotStubbed
+}
+
+// ListPins calls ListPinsFunc
+func (s *Service) ListPins(ctx context.Context, filter store.PinFilter) ([]*store.Pin, error) {
+	if s.ListPinsFunc != nil {
+		return s.ListPinsFunc(ctx, filter)
+	}
+	return nil, ErrNotStubbed
+}
+
+// CloseCase calls CloseCaseFunc
+func (s *Service) CloseCase(ctx context.Context, caseID string) ([]*store.Pin, error) {
+	if s.CloseCaseFunc != nil {
+		return s.CloseCaseFunc(ctx, caseID)
+	}
+	return nil, ErrNotStubbed
+}
+
+// ListCases calls ListCasesFunc
+func (s *Service) ListCases(ctx context.Context, filter store.CaseFilter) ([]*store.ReviewCase, error) {
+	if s.ListCasesFunc != nil {
+		return s.ListCasesFunc(ctx, filter)
+	}
+	return nil, ErrNotStubbed
+}
+
+// GetCase calls GetCaseFunc
+func (s *Service) GetCase(ctx context.Context, id int64) (*store.ReviewCase, error) {
+	if s.GetCaseFunc != nil {
+		return s.GetCaseFunc(ctx, id)
+	}
+	return nil, ErrNotStubbed
+}
+
+// UpdateCase calls UpdateCaseFunc
+func (s *Service) UpdateCase(ctx context.Context, id int64, update store.CaseUpdate) (*store.ReviewCase, error) {
+	if s.UpdateCaseFunc != nil {
+		return s.UpdateCaseFunc(ctx, id, update)
+	}
+	return nil, ErrNotStubbed
+}
@@ -0,0 +1,204 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"blacklist-check/internal/store"
+)
+
+// BlacklistStore is a store.BlacklistStore whose methods call the func of the
+// same name. A method whose func isn't set returns ErrNotStubbed.
+type BlacklistStore struct {
+	GetByNIKFunc        func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error)
+	CountNIKsFunc       func(ctx context.Context) (int64, error)
+	EachNIKFunc         func(ctx context.Context, fn func(list, nik string) error) error
+	GetByFuzzyMatchFunc func(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*store.BlacklistRecord, bool, error)
+	SearchByNameFunc    func(ctx context.Context, name string) ([]*store.BlacklistRecord, error)
+	GetByPhoneticFunc   func(ctx context.Context, list, name string, birthDate *time.Time) ([]*store.BlacklistRecord, error)
+	NearestByNameFunc   func(ctx context.Context, list, name string, limit int) ([]*store.BlacklistRecord, error)
+	SearchFunc          func(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*store.BlacklistRecord, error)
+	ListFunc            func(ctx context.Context, filter store.RecordFilter, sort, cursor string, limit int) ([]*store.BlacklistRecord, string, error)
+	ListBySourceFunc    func(ctx context.Context, list, source string) ([]*store.BlacklistRecord, error)
+	CreateFunc          func(ctx context.Context, record *store.BlacklistRecord) error
+	UpdateFunc          func(ctx context.Context, record *store.BlacklistRecord) error
+	DeleteFunc          func(ctx context.Context, list, nik string) error
+	RestoreFunc         func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error)
+	ConfirmFunc         func(ctx context.Context, list, nik string) (*store.BlacklistRecord, error)
+	ExtendFunc          func(ctx context.Context, list, nik string, expiresAt time.Time) (*store.BlacklistRecord, error)
+	MarkExpiringFunc    func(ctx context.Context, within time.Duration) ([]*store.BlacklistRecord, error)
+	ExpireFunc          func(ctx context.Context) ([]*store.BlacklistRecord, error)
+	HistoryFunc         func(ctx context.Context, nik string) ([]*store.RecordChange, error)
+	ApplyChangeSetFunc  func(ctx context.Context, cs *store.ChangeSet) error
+	PingFunc            func(ctx context.Context) error
+}
+
+var _ store.BlacklistStore = (*BlacklistStore)(nil)
+
+// GetByNIK calls GetByNIKFunc
+func (s *BlacklistStore) GetByNIK(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	if s.GetByNIKFunc != nil {
+		return s.GetByNIKFunc(ctx, list, nik)
+	}
+	return nil, ErrNotStubbed
+}
+
+// CountNIKs calls CountNIKsFunc
+func (s *BlacklistStore) CountNIKs(ctx context.Context) (int64, error) {
+	if s.CountNIKsFunc != nil {
+		return s.CountNIKsFunc(ctx)
+	}
+	return 0, ErrNotStubbed
+}
+
+// EachNIK calls EachNIKFunc
+func (s *BlacklistStore) EachNIK(ctx context.Context, fn func(list, nik string) error) error {
+	if s.EachNIKFunc != nil {
+		return s.EachNIKFunc(ctx, fn)
+	}
+	return ErrNotStubbed
+}
+
+// GetByFuzzyMatch calls GetByFuzzyMatchFunc
+func (s *BlacklistStore) GetByFuzzyMatch(ctx context.Context, list, name string, birthPlaces []string, birthDate *time.Time, minSimilarity float64, budget, limit int) ([]*store.BlacklistRecord, bool, error) {
+	if s.GetByFuzzyMatchFunc != nil {
+		return s.GetByFuzzyMatchFunc(ctx, list, name, birthPlaces, birthDate, minSimilarity, budget, limit)
+	}
+	return nil, false, ErrNotStubbed
+}
+
+// SearchByName calls SearchByNameFunc
+func (s *BlacklistStore) SearchByName(ctx context.Context, name string) ([]*store.BlacklistRecord, error) {
+	if s.SearchByNameFunc != nil {
+		return s.SearchByNameFunc(ctx, name)
+	}
+	return nil, ErrNotStubbed
+}
+
+// GetByPhonetic calls GetByPhoneticFunc
+func (s *BlacklistStore) GetByPhonetic(ctx context.Context, list, name string, birthDate *time.Time) ([]*store.BlacklistRecord, error) {
+	if s.GetByPhoneticFunc != nil {
+		return s.GetByPhoneticFunc(ctx, list, name, birthDate)
+	}
+	return nil, ErrNotStubbed
+}
+
+// NearestByName calls NearestByNameFunc
+func (s *BlacklistStore) NearestByName(ctx context.Context, list, name string, limit int) ([]*store.BlacklistRecord, error) {
+	if s.NearestByNameFunc != nil {
+		return s.NearestByNameFunc(ctx, list, name, limit)
+	}
+	return nil, ErrNotStubbed
+}
+
+// Search calls SearchFunc
+func (s *BlacklistStore) Search(ctx context.Context, list, query string, includeDeleted bool, limit int) ([]*store.BlacklistRecord, error) {
+	if s.SearchFunc != nil {
+		return s.SearchFunc(ctx, list, query, includeDeleted, limit)
+	}
+	return nil, ErrNotStubbed
+}
+
+// List calls ListFunc
+func (s *BlacklistStore) List(ctx context.Context, filter store.RecordFilter, sort, cursor string, limit int) ([]*store.BlacklistRecord, string, error) {
+	if s.ListFunc != nil {
+		return s.ListFunc(ctx, filter, sort, cursor, limit)
+	}
+	return nil, "", ErrNotStubbed
+}
+
+// ListBySource calls ListBySourceFunc
+func (s *BlacklistStore) ListBySource(ctx context.Context, list, source string) ([]*store.BlacklistRecord, error) {
+	if s.ListBySourceFunc != nil {
+		return s.ListBySourceFunc(ctx, list, source)
+	}
+	return nil, ErrNotStubbed
+}
+
+// Create calls CreateFunc
+func (s *BlacklistStore) Create(ctx context.Context, record *store.BlacklistRecord) error {
+	if s.CreateFunc != nil {
+		return s.CreateFunc(ctx, record)
+	}
+	return ErrNotStubbed
+}
+
+// Update calls UpdateFunc
+func (s *BlacklistStore) Update(ctx context.Context, record *store.BlacklistRecord) error {
+	if s.UpdateFunc != nil {
+		return s.UpdateFunc(ctx, record)
+	}
+	return ErrNotStubbed
+}
+
+// Delete calls DeleteFunc
+func (s *BlacklistStore) Delete(ctx context.Context, list, nik string) error {
+	if s.DeleteFunc != nil {
+		return s.DeleteFunc(ctx, list, nik)
+	}
+	return ErrNotStubbed
+}
+
+// Restore calls RestoreFunc
+func (s *BlacklistStore) Restore(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	if s.RestoreFunc != nil {
+		return s.RestoreFunc(ctx, list, nik)
+	}
+	return nil, ErrNotStubbed
+}
+
+// Confirm calls ConfirmFunc
+func (s *BlacklistStore) Confirm(ctx context.Context, list, nik string) (*store.BlacklistRecord, error) {
+	if s.ConfirmFunc != nil {
+		return s.ConfirmFunc(ctx, list, nik)
+	}
+	return nil, ErrNotStubbed
+}
+
+// Extend calls ExtendFunc
+func (s *BlacklistStore) Extend(ctx context.Context, list, nik string, expiresAt time.Time) (*store.BlacklistRecord, error) {
+	if s.ExtendFunc != nil {
+		return s.ExtendFunc(ctx, list, nik, expiresAt)
+	}
+	return nil, ErrNotStubbed
+}
+
+// MarkExpiring calls MarkExpiringFunc
+func (s *BlacklistStore) MarkExpiring(ctx context.Context, within time.Duration) ([]*store.BlacklistRecord, error) {
+	if s.MarkExpiringFunc != nil {
+		return s.MarkExpiringFunc(ctx, within)
+	}
+	return nil, ErrNotStubbed
+}
+
+// Expire calls ExpireFunc
+func (s *BlacklistStore) Expire(ctx context.Context) ([]*store.BlacklistRecord, error) {
+	if s.ExpireFunc != nil {
+		return s.ExpireFunc(ctx)
+	}
+	return nil, ErrNotStubbed
+}
+
+// History calls HistoryFunc
+func (s *BlacklistStore) History(ctx context.Context, nik string) ([]*store.RecordChange, error) {
+	if s.HistoryFunc != nil {
+		return s.HistoryFunc(ctx, nik)
+	}
+	return nil, ErrNotStubbed
+}
+
+// ApplyChangeSet calls ApplyChangeSetFunc
+func (s *BlacklistStore) ApplyChangeSet(ctx context.Context, cs *store.ChangeSet) error {
+	if s.ApplyChangeSetFunc != nil {
+		return s.ApplyChangeSetFunc(ctx, cs)
+	}
+	return ErrNotStubbed
+}
+
+// Ping calls PingFunc
+func (s *BlacklistStore) Ping(ctx context.Context) error {
+	if s.PingFunc != nil {
+		return s.PingFunc(ctx)
+	}
+	return ErrNotStubbed
+}
@@ -0,0 +1,144 @@
+// Package tokenize delegates PII to an external tokenization service so
+// stored history holds opaque tokens instead of national IDs and names, for
+// deployments whose data-protection policy forbids keeping them locally.
+package tokenize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"blacklist-check/pkg/config"
+)
+
+// Tokenized fields
+const (
+	FieldNIK  = "nik"
+	FieldName = "name"
+)
+
+// Providers
+const (
+	ProviderNone = "none"
+	ProviderHTTP = "http"
+)
+
+// Tokenizer swaps field values for tokens and back. Values are keyed by
+// field so a record is tokenized in a single round trip.
+type Tokenizer interface {
+	// Enabled reports whether values are actually replaced by tokens
+	Enabled() bool
+	Tokenize(ctx context.Context, values map[string]string) (map[string]string, error)
+	Detokenize(ctx context.Context, tokens map[string]string) (map[string]string, error)
+}
+
+// New creates the tokenizer selected by TOKENIZE_PROVIDER
+func New(cfg *config.Config) (Tokenizer, error) {
+	switch cfg.Tokenize.Provider {
+	case "", ProviderNone:
+		return none{}, nil
+	case ProviderHTTP:
+		if cfg.Tokenize.URL == "" {
+			return nil, fmt.Errorf("TOKENIZE_URL is required for the %s provider", ProviderHTTP)
+		}
+		return &httpTokenizer{
+			url:    strings.TrimSuffix(cfg.Tokenize.URL, "/"),
+			apiKey: cfg.Tokenize.APIKey,
+			client: &http.Client{Timeout: cfg.Tokenize.Timeout},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown tokenize provider %q (known: %s, %s)", cfg.Tokenize.Provider, ProviderNone, ProviderHTTP)
+}
+
+// none keeps values as they are
+type none struct{}
+
+func (none) Enabled() bool { return false }
+
+func (none) Tokenize(ctx context.Context, values map[string]string) (map[string]string, error) {
+	return values, nil
+}
+
+func (none) Detokenize(ctx context.Context, tokens map[string]string) (map[string]string, error) {
+	return tokens, nil
+}
+
+// httpTokenizer calls a tokenization service that exposes
+//
+//	POST <url>/tokenize   {"values": {"nik": "...", "name": "..."}} -> {"values": {"nik": "<token>", ...}}
+//	POST <url>/detokenize {"values": {"nik": "<token>", ...}}       -> {"values": {"nik": "...", ...}}
+//
+// Empty values are never sent and come back empty.
+type httpTokenizer struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func (t *httpTokenizer) Enabled() bool { return true }
+
+func (t *httpTokenizer) Tokenize(ctx context.Context, values map[string]string) (map[string]string, error) {
+	return t.call(ctx, "/tokenize", values)
+}
+
+func (t *httpTokenizer) Detokenize(ctx context.Context, tokens map[string]string) (map[string]string, error) {
+	return t.call(ctx, "/detokenize", tokens)
+}
+
+// payload is the request and response body of the tokenization service
+type payload struct {
+	Values map[string]string `json:"values"`
+}
+
+func (t *httpTokenizer) call(ctx context.Context, path string, values map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(values))
+	req := payload{Values: make(map[string]string, len(values))}
+	for field, value := range values {
+		if value == "" {
+			result[field] = ""
+			continue
+		}
+		req.Values[field] = value
+	}
+	if len(req.Values) == 0 {
+		return result, nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if t.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error calling tokenization service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokenization service returned %s", resp.Status)
+	}
+
+	var out payload
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("error decoding tokenization response: %w", err)
+	}
+	for field := range req.Values {
+		value, ok := out.Values[field]
+		if !ok || value == "" {
+			return nil, fmt.Errorf("tokenization service returned no value for %s", field)
+		}
+		result[field] = value
+	}
+	return result, nil
+}
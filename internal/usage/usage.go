@@ -0,0 +1,126 @@
+// Package usage meters what each caller's checks cost to serve, so chargeback
+// can follow resource consumption instead of a flat per-call price.
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"blacklist-check/internal/auth"
+	"blacklist-check/internal/metrics"
+)
+
+// Operations a check can spend resources on
+const (
+	// CacheHit is a per-list result served from Redis
+	CacheHit = "cache_hit"
+	// NIKLookup is an exact NIK query against the database
+	NIKLookup = "nik_lookup"
+	// FuzzyQuery is a trigram similarity query against the database
+	FuzzyQuery = "fuzzy_query"
+	// PhoneticQuery is a phonetic code query against the database
+	PhoneticQuery = "phonetic_query"
+)
+
+// DefaultRates are the cost units charged per operation unless overridden
+var DefaultRates = map[string]float64{
+	CacheHit:      0.1,
+	NIKLookup:     1,
+	FuzzyQuery:    5,
+	PhoneticQuery: 3,
+}
+
+// Cost counts the operations one check performed
+type Cost map[string]int
+
+// Add records one more occurrence of op
+func (c Cost) Add(op string) {
+	c[op]++
+}
+
+// Meter converts check costs to units using a rate per operation and
+// accumulates them per caller
+type Meter struct {
+	rates map[string]float64
+}
+
+// NewMeter parses rate overrides in the form "op=units,..." on top of DefaultRates
+func NewMeter(spec string) (*Meter, error) {
+	rates := make(map[string]float64, len(DefaultRates))
+	for op, rate := range DefaultRates {
+		rates[op] = rate
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		op, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid cost rate %q, expected op=units", entry)
+		}
+		op = strings.TrimSpace(op)
+		if _, known := DefaultRates[op]; !known {
+			return nil, fmt.Errorf("unknown operation %q in cost rate", op)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid cost rate for %q: %s", op, value)
+		}
+		rates[op] = rate
+	}
+	return &Meter{rates: rates}, nil
+}
+
+// Units returns the cost of c in units
+func (m *Meter) Units(c Cost) float64 {
+	var units float64
+	for op, count := range c {
+		units += m.rates[op] * float64(count)
+	}
+	return units
+}
+
+// Record charges c to the caller in ctx and returns its cost in units
+func (m *Meter) Record(ctx context.Context, c Cost) float64 {
+	caller := Caller(ctx)
+	metrics.MeteredChecksTotal.WithLabelValues(caller).Inc()
+
+	ops := make([]string, 0, len(c))
+	for op := range c {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	var units float64
+	for _, op := range ops {
+		cost := m.rates[op] * float64(c[op])
+		metrics.CheckCostUnitsTotal.WithLabelValues(caller, op).Add(cost)
+		units += cost
+	}
+	return units
+}
+
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx charging checks to caller, for work such
+// as screening jobs that runs outside the request that submitted it
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// Caller returns who checks made with ctx are charged to: the caller set with
+// WithCaller, else the authenticated identity, else "anonymous"
+func Caller(ctx context.Context) string {
+	if caller, ok := ctx.Value(callerKey{}).(string); ok && caller != "" {
+		return caller
+	}
+	if identity := auth.FromContext(ctx); identity != nil {
+		return identity.Subject
+	}
+	return "anonymous"
+}
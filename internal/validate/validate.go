@@ -0,0 +1,85 @@
+// Package validate collects the field errors of a request payload, so a
+// caller learns of every problem with a request at once rather than fixing
+// one field per round trip.
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"blacklist-check/api/types"
+)
+
+// Errors are the fields of a payload that failed validation, in the order
+// they were checked
+type Errors []types.FieldError
+
+// Add records a failing field
+func (e *Errors) Add(field, message string) {
+	*e = append(*e, types.FieldError{Field: field, Message: message})
+}
+
+// Check records err against field unless it is nil, and reports whether it was
+func (e *Errors) Check(field string, err error) bool {
+	if err != nil {
+		e.Add(field, err.Error())
+	}
+	return err == nil
+}
+
+// Length records field unless value is between min and max characters long;
+// a max of 0 is unbounded
+func (e *Errors) Length(field, value string, min, max int) bool {
+	n := utf8.RuneCountInString(value)
+	switch {
+	case n < min:
+		e.Add(field, fmt.Sprintf("%s must be at least %d characters long", label(field), min))
+	case max > 0 && n > max:
+		e.Add(field, fmt.Sprintf("%s must be at most %d characters long", label(field), max))
+	default:
+		return true
+	}
+	return false
+}
+
+// Date normalizes a date to midnight UTC on the calendar day it names, so a
+// birth date sent with a time or zone compares equal to the same day stored
+// without one. Implausible dates are left to the check's warnings.
+func Date(date *time.Time) *time.Time {
+	if date == nil {
+		return nil
+	}
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return &day
+}
+
+// Err returns e as an error, or nil when no field failed
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error joins the messages of the failing fields
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// label turns a field name such as birth_place into "Birth place" for messages
+func label(field string) string {
+	if i := strings.LastIndexByte(field, '.'); i >= 0 {
+		field = field[i+1:]
+	}
+	field = strings.ReplaceAll(field, "_", " ")
+	if field == "" {
+		return field
+	}
+	return strings.ToUpper(field[:1]) + field[1:]
+}
@@ -0,0 +1,219 @@
+// Package watchdog finds background jobs that stopped making progress. A
+// screening job or source sync that misses its heartbeats, or runs far longer
+// than it should, is marked stalled and raises an alert, and can be put back
+// up for retry so a crashed worker doesn't leave it "running" forever. A
+// synced source whose records haven't been refreshed for longer than it
+// allows raises an alert too.
+package watchdog
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"blacklist-check/internal/alert"
+	"blacklist-check/internal/listsync"
+	"blacklist-check/internal/metrics"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Job kinds watched
+const (
+	KindScreening = "screening"
+	KindSync      = "sync"
+)
+
+// Stall reasons
+const (
+	// ReasonHeartbeat means the job stopped heartbeating, usually because its worker died
+	ReasonHeartbeat = "missed_heartbeat"
+	// ReasonDuration means the job is still alive but ran past its max duration
+	ReasonDuration = "exceeded_duration"
+	// ReasonStale means a source's records weren't refreshed within its max age
+	ReasonStale = "stale"
+)
+
+// Stall describes a job the watchdog caught
+type Stall struct {
+	Kind string `json:"kind"`
+	// ID is the screening job ID or the sync source
+	ID          string    `json:"id"`
+	Reason      string    `json:"reason"`
+	StartedAt   time.Time `json:"started_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+	StalledAt   time.Time `json:"stalled_at"`
+	// Reclaimed is set when the job was put back up for retry
+	Reclaimed bool `json:"reclaimed"`
+}
+
+// Staleness describes a synced source whose records are older than it allows
+type Staleness struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+	List   string `json:"list"`
+	// RefreshedAt is absent when the records were never refreshed
+	RefreshedAt   *time.Time `json:"refreshed_at,omitempty"`
+	AgeSeconds    float64    `json:"age_seconds"`
+	MaxAgeSeconds float64    `json:"max_age_seconds"`
+}
+
+// Watchdog sweeps running jobs for stalls. Each stalled job is claimed by a
+// single conditional update, so replicas can all run a watchdog without
+// alerting twice.
+type Watchdog struct {
+	screenings   store.ScreeningStore
+	syncs        store.SyncStatusStore
+	connector    *listsync.Connector
+	heartbeat    time.Duration
+	screeningMax time.Duration
+	syncMax      time.Duration
+	reclaim      bool
+	alerts       *alert.Poster
+	log          *zap.Logger
+}
+
+// NewWatchdog creates a new watchdog
+func NewWatchdog(cfg *config.Config, screenings store.ScreeningStore, syncs store.SyncStatusStore, connector *listsync.Connector, log *zap.Logger) *Watchdog {
+	return &Watchdog{
+		screenings:   screenings,
+		syncs:        syncs,
+		connector:    connector,
+		heartbeat:    cfg.Watchdog.HeartbeatTimeout,
+		screeningMax: cfg.Watchdog.ScreeningMaxDuration,
+		syncMax:      cfg.Watchdog.SyncMaxDuration,
+		reclaim:      cfg.Watchdog.Reclaim,
+		alerts:       alert.NewPoster(cfg.Watchdog.AlertWebhook, "watchdog alert", log),
+		log:          log,
+	}
+}
+
+// Check marks every stalled job and alerts on it, returning what it caught
+func (w *Watchdog) Check(ctx context.Context) ([]*Stall, error) {
+	var stalls []*Stall
+
+	jobs, err := w.screenings.MarkStalled(ctx, w.heartbeat, w.screeningMax, w.reclaim)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		stall := &Stall{
+			Kind:        KindScreening,
+			ID:          strconv.FormatInt(job.ID, 10),
+			HeartbeatAt: job.UpdatedAt,
+			Reclaimed:   w.reclaim,
+		}
+		if job.StartedAt != nil {
+			stall.StartedAt = *job.StartedAt
+		}
+		if job.StalledAt != nil {
+			stall.StalledAt = *job.StalledAt
+		}
+		stall.Reason = w.reason(stall)
+		stalls = append(stalls, stall)
+	}
+
+	syncs, err := w.syncs.MarkStalled(ctx, w.heartbeat, w.syncMax, w.reclaim)
+	if err != nil {
+		return stalls, err
+	}
+	for _, status := range syncs {
+		stall := &Stall{
+			Kind:      KindSync,
+			ID:        status.Source,
+			Reclaimed: w.reclaim,
+		}
+		if status.RunningSince != nil {
+			stall.StartedAt = *status.RunningSince
+		}
+		if status.HeartbeatAt != nil {
+			stall.HeartbeatAt = *status.HeartbeatAt
+		}
+		if status.StalledAt != nil {
+			stall.StalledAt = *status.StalledAt
+		}
+		stall.Reason = w.reason(stall)
+		stalls = append(stalls, stall)
+	}
+
+	for _, stall := range stalls {
+		w.alert(stall)
+	}
+	if err := w.checkStale(ctx); err != nil {
+		return stalls, err
+	}
+	return stalls, nil
+}
+
+// checkStale alerts on every synced source whose records went stale. Each
+// replica reports the age of every source, but only the one that claims the
+// alert raises it, once until the source is refreshed.
+func (w *Watchdog) checkStale(ctx context.Context) error {
+	statuses, err := w.connector.Freshness(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if !status.Stale || status.StaleAlertedAt != nil {
+			continue
+		}
+		claimed, err := w.syncs.AlertStale(ctx, status.Source)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		w.alertStale(&Staleness{
+			Kind:          KindSync,
+			ID:            status.Source,
+			Reason:        ReasonStale,
+			List:          status.List,
+			RefreshedAt:   status.RefreshedAt,
+			AgeSeconds:    status.AgeSeconds,
+			MaxAgeSeconds: status.MaxAgeSeconds,
+		})
+	}
+	return nil
+}
+
+// reason tells a missed heartbeat from an overrun. Both times come from the
+// database clock, so local drift doesn't skew them.
+func (w *Watchdog) reason(stall *Stall) string {
+	if stall.StalledAt.Sub(stall.HeartbeatAt) >= w.heartbeat {
+		return ReasonHeartbeat
+	}
+	return ReasonDuration
+}
+
+// alert logs a stall and posts it to the alert webhook without holding up the sweep
+func (w *Watchdog) alert(stall *Stall) {
+	metrics.JobsStalledTotal.WithLabelValues(stall.Kind, stall.Reason).Inc()
+	w.log.Warn("Background job stalled",
+		zap.String("kind", stall.Kind),
+		zap.String("id", stall.ID),
+		zap.String("reason", stall.Reason),
+		zap.Time("started_at", stall.StartedAt),
+		zap.Time("heartbeat_at", stall.HeartbeatAt),
+		zap.Bool("reclaimed", stall.Reclaimed))
+
+	w.alerts.Post(stall)
+}
+
+// alertStale logs a stale source and posts it to the alert webhook
+func (w *Watchdog) alertStale(stale *Staleness) {
+	w.log.Warn("Sync source stale",
+		zap.String("source", stale.ID),
+		zap.String("list", stale.List),
+		zap.Float64("age_seconds", stale.AgeSeconds),
+		zap.Float64("max_age_seconds", stale.MaxAgeSeconds))
+	w.alerts.Post(stale)
+}
+
+// Wait waits for alerts still being delivered to the webhook, or until ctx is done
+func (w *Watchdog) Wait(ctx context.Context) error {
+	return w.alerts.Wait(ctx)
+}
@@ -0,0 +1,183 @@
+package watchdog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"blacklist-check/internal/listsync"
+	"blacklist-check/internal/store"
+	"blacklist-check/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+const heartbeat = time.Minute
+
+// fakeScreenings returns the jobs it holds as stalled
+type fakeScreenings struct {
+	store.ScreeningStore
+	stalled []*store.ScreeningJob
+	reclaim bool
+}
+
+func (s *fakeScreenings) MarkStalled(ctx context.Context, heartbeat, maxDuration time.Duration, reclaim bool) ([]*store.ScreeningJob, error) {
+	s.reclaim = reclaim
+	return s.stalled, nil
+}
+
+// fakeSyncs returns the syncs it holds as stalled and lets the stale alert
+// of each source be claimed once
+type fakeSyncs struct {
+	store.SyncStatusStore
+	stalled  []*store.SyncStatus
+	statuses []*store.SyncStatus
+	alerted  map[string]bool
+}
+
+func (s *fakeSyncs) MarkStalled(ctx context.Context, heartbeat, maxDuration time.Duration, reclaim bool) ([]*store.SyncStatus, error) {
+	return s.stalled, nil
+}
+
+func (s *fakeSyncs) List(ctx context.Context) ([]*store.SyncStatus, error) {
+	return s.statuses, nil
+}
+
+func (s *fakeSyncs) AlertStale(ctx context.Context, source string) (bool, error) {
+	if s.alerted[source] {
+		return false, nil
+	}
+	s.alerted[source] = true
+	return true, nil
+}
+
+// webhook collects the kind, ID and reason of each alert posted to it
+type webhook struct {
+	*httptest.Server
+	mu     sync.Mutex
+	alerts []string
+}
+
+func newWebhook(t *testing.T) *webhook {
+	t.Helper()
+	h := &webhook{}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert struct{ Kind, ID, Reason string }
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.alerts = append(h.alerts, alert.Kind+"/"+alert.ID+"/"+alert.Reason)
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *webhook) received() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sort.Strings(h.alerts)
+	return strings.Join(h.alerts, " ")
+}
+
+func newWatchdog(t *testing.T, screenings store.ScreeningStore, syncs store.SyncStatusStore, webhookURL string) *Watchdog {
+	t.Helper()
+	cfg := &config.Config{
+		Watchdog: config.WatchdogConfig{
+			HeartbeatTimeout:     heartbeat,
+			ScreeningMaxDuration: time.Hour,
+			SyncMaxDuration:      time.Hour,
+			Reclaim:              true,
+			AlertWebhook:         webhookURL,
+		},
+		Sync: config.SyncConfig{
+			Sources: "ofac,un,eu",
+			OFACURL: "https://ofac.example.com/sdn.xml",
+			UNURL:   "https://un.example.com/consolidated.xml",
+			EUURL:   "https://eu.example.com/fsf.xml",
+			MaxAge:  48 * time.Hour,
+		},
+	}
+	connector, err := listsync.NewConnector(cfg, nil, nil, syncs, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWatchdog(cfg, screenings, syncs, connector, zap.NewNop())
+}
+
+func TestCheck(t *testing.T) {
+	started := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := started.Add(d)
+		return &ts
+	}
+	alerted := at(0)
+	screenings := &fakeScreenings{stalled: []*store.ScreeningJob{
+		// Silent for longer than the heartbeat timeout
+		{ID: 7, StartedAt: at(0), UpdatedAt: started.Add(time.Minute), StalledAt: at(5 * time.Minute)},
+		// Still heartbeating, but past its max duration
+		{ID: 8, StartedAt: at(0), UpdatedAt: started.Add(time.Hour), StalledAt: at(time.Hour + 10*time.Second)},
+	}}
+	syncs := &fakeSyncs{
+		stalled: []*store.SyncStatus{
+			{Source: "un", RunningSince: at(0), HeartbeatAt: at(time.Minute), StalledAt: at(3 * time.Minute)},
+		},
+		statuses: []*store.SyncStatus{
+			{Source: "ofac", List: "sanctions", AgeSeconds: (72 * time.Hour).Seconds()},
+			{Source: "un", List: "sanctions", AgeSeconds: time.Hour.Seconds()},
+			// Already alerted until the source is refreshed
+			{Source: "eu", List: "sanctions", AgeSeconds: (72 * time.Hour).Seconds(), StaleAlertedAt: alerted},
+		},
+		alerted: map[string]bool{},
+	}
+	hook := newWebhook(t)
+	w := newWatchdog(t, screenings, syncs, hook.URL)
+
+	stalls, err := w.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	var got []string
+	for _, stall := range stalls {
+		got = append(got, stall.Kind+"/"+stall.ID+"/"+stall.Reason)
+		if !stall.Reclaimed {
+			t.Errorf("stall %s/%s not reclaimed", stall.Kind, stall.ID)
+		}
+	}
+	want := "screening/7/missed_heartbeat screening/8/exceeded_duration sync/un/missed_heartbeat"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Check() = %v, want %s", got, want)
+	}
+	if !screenings.reclaim {
+		t.Error("stalled screening jobs not reclaimed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	wantAlerts := "screening/7/missed_heartbeat screening/8/exceeded_duration sync/ofac/stale sync/un/missed_heartbeat"
+	if got := hook.received(); got != wantAlerts {
+		t.Errorf("alerted %s, want %s", got, wantAlerts)
+	}
+
+	// The stale source was alerted on once; the next sweep stays quiet
+	screenings.stalled, syncs.stalled = nil, nil
+	if _, err := w.Check(context.Background()); err != nil {
+		t.Fatalf("second Check() error = %v", err)
+	}
+	if err := w.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if got := hook.received(); got != wantAlerts {
+		t.Errorf("after a second sweep alerted %s", got)
+	}
+}
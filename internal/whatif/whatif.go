@@ -0,0 +1,120 @@
+// Package whatif replays recorded production checks under a proposed match
+// policy to quantify how many decisions a policy change would flip.
+package whatif
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"blacklist-check/internal/service"
+	"blacklist-check/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// Options selects the checks to replay
+type Options struct {
+	// Since is how far back to look
+	Since time.Duration
+	// Sample replays a random selection of at most this many checks; 0 replays all
+	Sample int
+}
+
+// Flip is a check whose decision differs between the current and proposed policy
+type Flip struct {
+	Entry    *store.CheckHistoryEntry
+	Current  *service.CheckResult
+	Proposed *service.CheckResult
+}
+
+// Report summarizes a what-if run
+type Report struct {
+	Checks int `json:"checks"`
+	// Flipped counts checks whose decision differs under the proposed policy
+	Flipped int `json:"flipped"`
+	// NewlyBlacklisted and Cleared split Flipped by direction; the remainder
+	// stay blacklisted under a different match type
+	NewlyBlacklisted int `json:"newly_blacklisted"`
+	Cleared          int `json:"cleared"`
+	// Drifted counts checks whose current decision no longer matches the
+	// recorded one because the list changed since; they are replayed as usual
+	Drifted int `json:"drifted"`
+	// Transitions counts flips by "<current match type> -> <proposed match type>"
+	Transitions map[string]int `json:"transitions"`
+}
+
+// Analyzer replays check history against a proposed policy
+type Analyzer struct {
+	history store.CheckHistoryStore
+	service *service.BlacklistService
+	log     *zap.Logger
+}
+
+// NewAnalyzer creates a new what-if analyzer
+func NewAnalyzer(history store.CheckHistoryStore, service *service.BlacklistService, log *zap.Logger) *Analyzer {
+	return &Analyzer{history: history, service: service, log: log}
+}
+
+// Run replays the selected checks under both the current and the proposed
+// policy, calling fn for every check whose decision would flip. Both sides are
+// evaluated against today's list so the report isolates the policy change.
+func (a *Analyzer) Run(ctx context.Context, opts Options, proposed service.MatchPolicy, fn func(Flip) error) (*Report, error) {
+	if err := proposed.Validate(); err != nil {
+		return nil, err
+	}
+
+	report := &Report{Transitions: make(map[string]int)}
+	started := time.Now()
+
+	err := a.history.Sample(ctx, started.Add(-opts.Since), opts.Sample, func(entry *store.CheckHistoryEntry) error {
+		if err := a.service.Detokenize(ctx, entry); err != nil {
+			return err
+		}
+		req := service.CheckRequest{
+			Name:       entry.Name,
+			NIK:        entry.NIK,
+			BirthPlace: entry.BirthPlace,
+			Lists:      entry.Lists,
+		}
+		if entry.BirthDate != nil {
+			req.BirthDate = *entry.BirthDate
+		}
+
+		// Replay as the tenant that made the check, against its own records
+		sim, err := a.service.Simulate(store.WithTenant(ctx, entry.Tenant), req, proposed)
+		if err != nil {
+			return fmt.Errorf("error replaying check %d: %w", entry.ID, err)
+		}
+
+		report.Checks++
+		if sim.Current.Blacklisted != entry.Blacklisted || sim.Current.MatchType != entry.MatchType {
+			report.Drifted++
+		}
+		if sim.Changed {
+			report.Flipped++
+			switch {
+			case sim.Proposed.Blacklisted && !sim.Current.Blacklisted:
+				report.NewlyBlacklisted++
+			case !sim.Proposed.Blacklisted && sim.Current.Blacklisted:
+				report.Cleared++
+			}
+			report.Transitions[sim.Current.MatchType+" -> "+sim.Proposed.MatchType]++
+			if err := fn(Flip{Entry: entry, Current: sim.Current, Proposed: sim.Proposed}); err != nil {
+				return err
+			}
+		}
+
+		if report.Checks%1000 == 0 {
+			a.log.Info("What-if progress",
+				zap.Int("checks", report.Checks),
+				zap.Int("flipped", report.Flipped),
+				zap.Duration("elapsed", time.Since(started)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error replaying check history: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_blacklist_id;
+
+ALTER TABLE blacklist ALTER COLUMN reason DROP DEFAULT;
+ALTER TABLE blacklist RENAME COLUMN reason TO description;
+ALTER TABLE blacklist DROP COLUMN IF EXISTS id;
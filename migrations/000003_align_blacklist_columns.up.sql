@@ -0,0 +1,6 @@
+-- Align the table with the columns the store reads
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS id BIGSERIAL;
+ALTER TABLE blacklist RENAME COLUMN description TO reason;
+ALTER TABLE blacklist ALTER COLUMN reason SET DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blacklist_id ON blacklist(id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS sync_quarantine;
+
+DROP INDEX IF EXISTS idx_blacklist_source;
+ALTER TABLE blacklist DROP COLUMN IF EXISTS source;
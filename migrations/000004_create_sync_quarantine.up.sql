@@ -0,0 +1,21 @@
+-- Track which list source each record came from
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS source VARCHAR(50) NOT NULL DEFAULT 'internal';
+CREATE INDEX IF NOT EXISTS idx_blacklist_source ON blacklist(source);
+
+-- Change sets held back for manual approval
+CREATE TABLE IF NOT EXISTS sync_quarantine (
+    id BIGSERIAL PRIMARY KEY,
+    source VARCHAR(50) NOT NULL,
+    change_set JSONB NOT NULL,
+    total_records INTEGER NOT NULL,
+    added_count INTEGER NOT NULL,
+    updated_count INTEGER NOT NULL,
+    deleted_count INTEGER NOT NULL,
+    change_ratio DOUBLE PRECISION NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    decided_by VARCHAR(255),
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_quarantine_status ON sync_quarantine(status);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_blacklist_name_phonetic;
+
+ALTER TABLE blacklist DROP COLUMN IF EXISTS name_phonetic;
@@ -0,0 +1,4 @@
+-- Precomputed phonetic code of the name, maintained by the application
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS name_phonetic VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_name_phonetic ON blacklist(name_phonetic);
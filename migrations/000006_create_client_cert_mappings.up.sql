@@ -0,0 +1,11 @@
+-- Map client certificate subjects/SANs to API identities for mTLS callers
+CREATE TABLE IF NOT EXISTS client_cert_mappings (
+    id BIGSERIAL PRIMARY KEY,
+    field VARCHAR(20) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    tenant VARCHAR(100) NOT NULL DEFAULT '',
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (field, value)
+);
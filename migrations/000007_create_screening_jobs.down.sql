@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS screening_subjects;
+DROP TABLE IF EXISTS screening_jobs;
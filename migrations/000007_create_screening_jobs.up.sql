@@ -0,0 +1,31 @@
+-- Bulk screening jobs processed asynchronously by background workers
+CREATE TABLE IF NOT EXISTS screening_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    total INTEGER NOT NULL,
+    processed INTEGER NOT NULL DEFAULT 0,
+    matched INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    submitted_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_screening_jobs_status ON screening_jobs(status, updated_at);
+
+-- Subjects of a job; results are filled in as workers progress
+CREATE TABLE IF NOT EXISTS screening_subjects (
+    job_id BIGINT NOT NULL REFERENCES screening_jobs(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    nik VARCHAR(16) NOT NULL DEFAULT '',
+    birth_place VARCHAR(255) NOT NULL DEFAULT '',
+    birth_date DATE,
+    blacklisted BOOLEAN,
+    match_type VARCHAR(50),
+    details TEXT,
+    processed_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (job_id, seq)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_blacklist_nik_hash;
+
+ALTER TABLE blacklist DROP COLUMN IF EXISTS nik_hash;
+ALTER TABLE blacklist DROP COLUMN IF EXISTS name_sorted;
+ALTER TABLE blacklist DROP COLUMN IF EXISTS name_normalized;
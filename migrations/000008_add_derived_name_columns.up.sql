@@ -0,0 +1,6 @@
+-- Derived matching columns, maintained by the application and backfilled with cmd/backfill
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS name_normalized VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS name_sorted VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS nik_hash CHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_nik_hash ON blacklist(nik_hash);
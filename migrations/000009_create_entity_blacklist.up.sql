@@ -0,0 +1,17 @@
+-- Corporate counterparties, screened separately from individuals
+CREATE TABLE IF NOT EXISTS entity_blacklist (
+    id BIGSERIAL PRIMARY KEY,
+    legal_name VARCHAR(255) NOT NULL,
+    name_normalized VARCHAR(255) NOT NULL,
+    registration_number VARCHAR(50) NOT NULL DEFAULT '',
+    country CHAR(2) NOT NULL DEFAULT 'ID',
+    reason TEXT NOT NULL DEFAULT '',
+    source VARCHAR(50) NOT NULL DEFAULT 'internal',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_entity_blacklist_registration
+    ON entity_blacklist(country, registration_number) WHERE registration_number <> '';
+CREATE INDEX IF NOT EXISTS idx_entity_blacklist_name_trgm
+    ON entity_blacklist USING gin (name_normalized gin_trgm_ops);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_blacklist_reason_code;
+
+ALTER TABLE blacklist DROP COLUMN IF EXISTS reason_params;
+ALTER TABLE blacklist DROP COLUMN IF EXISTS reason_code;
@@ -0,0 +1,6 @@
+-- Templated reasons: a code plus parameters, rendered per locale at response time.
+-- The free-text reason column stays as the fallback for records without a code.
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS reason_code VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS reason_params JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_reason_code ON blacklist(reason_code);
@@ -0,0 +1,14 @@
+-- Production screening decisions, kept so proposed policy changes can be
+-- replayed against real traffic before they are approved
+CREATE TABLE IF NOT EXISTS check_history (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    nik VARCHAR(16) NOT NULL DEFAULT '',
+    birth_place VARCHAR(100) NOT NULL DEFAULT '',
+    birth_date DATE,
+    blacklisted BOOLEAN NOT NULL,
+    match_type VARCHAR(50) NOT NULL,
+    checked_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_check_history_checked_at ON check_history(checked_at);
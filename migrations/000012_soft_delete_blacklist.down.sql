@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS blacklist_history_trigger ON blacklist;
+DROP FUNCTION IF EXISTS record_blacklist_history();
+DROP TABLE IF EXISTS blacklist_history;
+
+-- Soft-deleted rows would become live again, so drop them first
+DELETE FROM blacklist WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_blacklist_deleted_at;
+ALTER TABLE blacklist DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE blacklist DROP COLUMN IF EXISTS deleted_at;
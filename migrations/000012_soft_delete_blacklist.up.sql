@@ -0,0 +1,65 @@
+-- Deletions are soft so they can be reversed; live rows have deleted_at NULL
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_deleted_at ON blacklist(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Every change to a record, with full before/after snapshots for auditors
+CREATE TABLE IF NOT EXISTS blacklist_history (
+    id BIGSERIAL PRIMARY KEY,
+    nik VARCHAR(50) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    before JSONB,
+    after JSONB,
+    changed_by VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_history_nik ON blacklist_history(nik, id);
+
+-- Recorded by trigger so no write path can skip it. The actor is taken from
+-- the transaction-local app.actor setting. Updates that only recompute
+-- derived matching columns (backfills) are not changes to the record.
+CREATE OR REPLACE FUNCTION record_blacklist_history() RETURNS trigger AS $$
+DECLARE
+    derived CONSTANT TEXT[] := ARRAY['name_phonetic', 'name_normalized', 'name_sorted', 'nik_hash', 'updated_at'];
+    change_action TEXT;
+    change_nik TEXT;
+    before_row JSONB;
+    after_row JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        change_action := 'create';
+        change_nik := NEW.nik;
+        after_row := to_jsonb(NEW);
+    ELSIF TG_OP = 'DELETE' THEN
+        change_action := 'purge';
+        change_nik := OLD.nik;
+        before_row := to_jsonb(OLD);
+    ELSE
+        change_nik := NEW.nik;
+        before_row := to_jsonb(OLD);
+        after_row := to_jsonb(NEW);
+        IF (before_row - derived) = (after_row - derived) THEN
+            RETURN NEW;
+        END IF;
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_action := 'delete';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_action := 'restore';
+        ELSE
+            change_action := 'update';
+        END IF;
+    END IF;
+
+    INSERT INTO blacklist_history (nik, action, before, after, changed_by)
+    VALUES (change_nik, change_action, before_row, after_row,
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), current_user));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS blacklist_history_trigger ON blacklist;
+CREATE TRIGGER blacklist_history_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON blacklist
+    FOR EACH ROW EXECUTE FUNCTION record_blacklist_history();
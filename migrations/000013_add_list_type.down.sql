@@ -0,0 +1,8 @@
+ALTER TABLE check_history DROP COLUMN IF EXISTS lists;
+
+-- Only the internal list fits the single-list schema
+DELETE FROM blacklist WHERE list_type <> 'internal';
+
+ALTER TABLE blacklist DROP CONSTRAINT IF EXISTS blacklist_pkey;
+ALTER TABLE blacklist ADD PRIMARY KEY (nik);
+ALTER TABLE blacklist DROP COLUMN IF EXISTS list_type;
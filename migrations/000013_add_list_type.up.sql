@@ -0,0 +1,8 @@
+-- Records belong to a screening list; the same NIK may appear on several
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS list_type VARCHAR(20) NOT NULL DEFAULT 'internal';
+
+ALTER TABLE blacklist DROP CONSTRAINT IF EXISTS blacklist_pkey;
+ALTER TABLE blacklist ADD PRIMARY KEY (list_type, nik);
+
+-- Replays of recorded checks must screen the same lists; NULL means the defaults
+ALTER TABLE check_history ADD COLUMN IF NOT EXISTS lists TEXT[];
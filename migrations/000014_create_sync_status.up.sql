@@ -0,0 +1,15 @@
+-- Outcome of the latest scheduled download of each external list source. A
+-- replica claims a run by bumping last_attempt_at, so only one of them syncs
+-- a source per interval.
+CREATE TABLE IF NOT EXISTS sync_status (
+    source VARCHAR(50) PRIMARY KEY,
+    list_type VARCHAR(20) NOT NULL,
+    last_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_success_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    records INTEGER NOT NULL DEFAULT 0,
+    added_count INTEGER NOT NULL DEFAULT 0,
+    updated_count INTEGER NOT NULL DEFAULT 0,
+    deleted_count INTEGER NOT NULL DEFAULT 0,
+    quarantine_id BIGINT
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS breakglass_audit;
+DROP TABLE IF EXISTS breakglass_grants;
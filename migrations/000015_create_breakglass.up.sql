@@ -0,0 +1,23 @@
+-- Time-boxed emergency admin grants. Only a hash of each token is stored;
+-- every request made with one is recorded in breakglass_audit.
+CREATE TABLE IF NOT EXISTS breakglass_grants (
+    id BIGSERIAL PRIMARY KEY,
+    subject VARCHAR(255) NOT NULL,
+    justification TEXT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revoked_by VARCHAR(255)
+);
+
+CREATE TABLE IF NOT EXISTS breakglass_audit (
+    id BIGSERIAL PRIMARY KEY,
+    grant_id BIGINT NOT NULL REFERENCES breakglass_grants(id),
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_breakglass_audit_grant ON breakglass_audit(grant_id, id);
@@ -0,0 +1,4 @@
+-- Tokenized rows can't be narrowed back to a NIK
+DELETE FROM check_history WHERE tokenized;
+ALTER TABLE check_history DROP COLUMN IF EXISTS tokenized;
+ALTER TABLE check_history ALTER COLUMN nik TYPE VARCHAR(16);
@@ -0,0 +1,4 @@
+-- Tokens from an external tokenization service replace the NIK and name when
+-- tokenization is enabled; tokens may be longer than a NIK
+ALTER TABLE check_history ALTER COLUMN nik TYPE VARCHAR(255);
+ALTER TABLE check_history ADD COLUMN IF NOT EXISTS tokenized BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+ALTER TABLE blacklist ALTER COLUMN birth_place DROP DEFAULT;
+UPDATE blacklist SET birth_date = '0001-01-01' WHERE birth_date IS NULL;
+ALTER TABLE blacklist ALTER COLUMN birth_date SET NOT NULL;
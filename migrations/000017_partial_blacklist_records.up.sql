@@ -0,0 +1,5 @@
+-- Records may lack a birth date or birth place; sources without one used to
+-- store the zero date, which compared equal to checks without a birth date
+ALTER TABLE blacklist ALTER COLUMN birth_date DROP NOT NULL;
+UPDATE blacklist SET birth_date = NULL WHERE birth_date = '0001-01-01';
+ALTER TABLE blacklist ALTER COLUMN birth_place SET DEFAULT '';
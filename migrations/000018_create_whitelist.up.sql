@@ -0,0 +1,20 @@
+-- Cleared false positives: a check on the subject no longer matches the
+-- record until the entry expires or is revoked. Subjects are identified by
+-- NIK, or by normalized name and birth date when the entry has no NIK.
+CREATE TABLE IF NOT EXISTS whitelist (
+    id BIGSERIAL PRIMARY KEY,
+    record_id BIGINT NOT NULL REFERENCES blacklist(id),
+    nik VARCHAR(50) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    name_normalized VARCHAR(255) NOT NULL DEFAULT '',
+    birth_date DATE,
+    reason TEXT NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revoked_by VARCHAR(255)
+);
+
+CREATE INDEX IF NOT EXISTS idx_whitelist_nik ON whitelist(nik) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_whitelist_name ON whitelist(name_normalized) WHERE revoked_at IS NULL;
@@ -0,0 +1,7 @@
+UPDATE screening_jobs SET status = 'pending' WHERE status = 'stalled';
+ALTER TABLE screening_jobs DROP COLUMN IF EXISTS stalled_at;
+ALTER TABLE screening_jobs DROP COLUMN IF EXISTS started_at;
+
+ALTER TABLE sync_status DROP COLUMN IF EXISTS stalled_at;
+ALTER TABLE sync_status DROP COLUMN IF EXISTS heartbeat_at;
+ALTER TABLE sync_status DROP COLUMN IF EXISTS running_since;
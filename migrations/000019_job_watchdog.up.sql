@@ -0,0 +1,8 @@
+-- Track when background jobs started and last heartbeated, so the watchdog
+-- can tell a crashed or hung job from one that is still making progress
+ALTER TABLE screening_jobs ADD COLUMN IF NOT EXISTS started_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE screening_jobs ADD COLUMN IF NOT EXISTS stalled_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE sync_status ADD COLUMN IF NOT EXISTS running_since TIMESTAMP WITH TIME ZONE;
+ALTER TABLE sync_status ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE sync_status ADD COLUMN IF NOT EXISTS stalled_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_blacklist_updated_at_id;
+DROP INDEX IF EXISTS idx_blacklist_created_at_id;
@@ -0,0 +1,4 @@
+-- Keyset pagination of record listings walks these sort orders with id as
+-- the tie-breaker; names are already covered by idx_blacklist_name_birth_date
+CREATE INDEX IF NOT EXISTS idx_blacklist_created_at_id ON blacklist(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_blacklist_updated_at_id ON blacklist(updated_at, id);
@@ -0,0 +1,3 @@
+DROP VIEW IF EXISTS admin_activity_feed;
+DROP INDEX IF EXISTS idx_blacklist_history_changed_at;
+DROP TABLE IF EXISTS admin_activity;
@@ -0,0 +1,47 @@
+-- Admin actions that leave no trace in a table of their own, such as
+-- certificate mapping changes and exports
+CREATE TABLE IF NOT EXISTS admin_activity (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    target VARCHAR(255) NOT NULL DEFAULT '',
+    details JSONB,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_activity_occurred_at ON admin_activity(occurred_at);
+CREATE INDEX IF NOT EXISTS idx_blacklist_history_changed_at ON blacklist_history(changed_at);
+
+-- Every admin-plane event in one chronological feed. event_id is unique
+-- across the sources and breaks ties between events at the same instant.
+CREATE OR REPLACE VIEW admin_activity_feed AS
+    SELECT 'record:' || id AS event_id, 'record' AS kind, action, changed_by AS actor, nik AS target,
+        jsonb_build_object('list', COALESCE(after, before)->>'list_type') AS details, changed_at AS occurred_at
+    FROM blacklist_history
+UNION ALL
+    SELECT 'quarantine:' || id, 'quarantine', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, id::text,
+        jsonb_build_object('source', source, 'added', added_count, 'updated', updated_count, 'deleted', deleted_count),
+        decided_at
+    FROM sync_quarantine
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'breakglass:' || id || ':issue', 'breakglass', 'issue', subject, id::text,
+        jsonb_build_object('justification', justification, 'expires_at', expires_at), created_at
+    FROM breakglass_grants
+UNION ALL
+    SELECT 'breakglass:' || id || ':revoke', 'breakglass', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM breakglass_grants
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'whitelist:' || id || ':create', 'whitelist', 'create', created_by, id::text,
+        jsonb_build_object('record_id', record_id, 'reason', reason, 'expires_at', expires_at), created_at
+    FROM whitelist
+UNION ALL
+    SELECT 'whitelist:' || id || ':revoke', 'whitelist', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM whitelist
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'activity:' || id, kind, action, actor, target, details, occurred_at
+    FROM admin_activity;
@@ -0,0 +1,19 @@
+-- Responses to requests sent with an Idempotency-Key, replayed when a caller
+-- retries with the same key. A row without completed_at is a request still
+-- being served.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    caller VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status INTEGER,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    content_language VARCHAR(50) NOT NULL DEFAULT '',
+    location VARCHAR(255) NOT NULL DEFAULT '',
+    body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (caller, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE check_history DROP COLUMN IF EXISTS policy_version;
+
+DROP TABLE IF EXISTS policy_snapshots;
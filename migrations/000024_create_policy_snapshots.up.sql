@@ -0,0 +1,10 @@
+-- Every effective screening policy an instance has run with, keyed by a
+-- digest of its contents, so each recorded decision can be traced to the
+-- settings that produced it
+CREATE TABLE IF NOT EXISTS policy_snapshots (
+    version VARCHAR(64) PRIMARY KEY,
+    policy JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE check_history ADD COLUMN IF NOT EXISTS policy_version VARCHAR(64) NOT NULL DEFAULT '';
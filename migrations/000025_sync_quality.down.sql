@@ -0,0 +1,2 @@
+ALTER TABLE sync_quarantine DROP COLUMN IF EXISTS quality;
+ALTER TABLE sync_status DROP COLUMN IF EXISTS quality;
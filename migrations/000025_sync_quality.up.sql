@@ -0,0 +1,4 @@
+-- Data quality of the file behind the latest sync of a source, and of the
+-- file a quarantined change set came from
+ALTER TABLE sync_status ADD COLUMN IF NOT EXISTS quality JSONB;
+ALTER TABLE sync_quarantine ADD COLUMN IF NOT EXISTS quality JSONB;
@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS blacklist_names;
+DROP TABLE IF EXISTS blacklist_aliases;
@@ -0,0 +1,20 @@
+-- Other names a blacklisted person is known by, matched like the record's own name
+CREATE TABLE IF NOT EXISTS blacklist_aliases (
+    id BIGSERIAL PRIMARY KEY,
+    record_id BIGINT NOT NULL REFERENCES blacklist(id) ON DELETE CASCADE,
+    alias VARCHAR(255) NOT NULL,
+    alias_type VARCHAR(20) NOT NULL DEFAULT 'aka',
+    name_phonetic VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (record_id, alias)
+);
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_aliases_alias_trgm
+    ON blacklist_aliases USING gin (alias gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_blacklist_aliases_name_phonetic ON blacklist_aliases(name_phonetic);
+
+-- Every name a record is known by: its own, with an empty alias, and its aliases
+CREATE OR REPLACE VIEW blacklist_names AS
+    SELECT id AS record_id, name, name_phonetic, '' AS alias FROM blacklist
+    UNION ALL
+    SELECT record_id, alias, name_phonetic, alias FROM blacklist_aliases;
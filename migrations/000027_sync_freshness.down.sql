@@ -0,0 +1,3 @@
+ALTER TABLE sync_status DROP COLUMN IF EXISTS created_at;
+ALTER TABLE sync_status DROP COLUMN IF EXISTS stale_alerted_at;
+ALTER TABLE sync_status DROP COLUMN IF EXISTS refreshed_at;
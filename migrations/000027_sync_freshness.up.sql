@@ -0,0 +1,9 @@
+-- When a source's records were last brought up to date, by an applied sync or
+-- an approved quarantine, and whether the replicas already alerted that they
+-- have gone stale since
+ALTER TABLE sync_status ADD COLUMN IF NOT EXISTS refreshed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE sync_status ADD COLUMN IF NOT EXISTS stale_alerted_at TIMESTAMP WITH TIME ZONE;
+-- Sources never refreshed age from when they were first synced
+ALTER TABLE sync_status ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE sync_status SET refreshed_at = last_success_at WHERE quarantine_id IS NULL AND refreshed_at IS NULL;
@@ -0,0 +1,57 @@
+-- Pins hold a record in place while an investigation is open: expiry,
+-- deletion and list syncs leave a record alone while it has an active pin.
+-- A record can be pinned by several cases; closing a case releases its pins.
+CREATE TABLE IF NOT EXISTS record_pins (
+    id BIGSERIAL PRIMARY KEY,
+    record_id BIGINT NOT NULL REFERENCES blacklist(id),
+    case_id VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    pinned_by VARCHAR(255) NOT NULL,
+    pinned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    unpinned_at TIMESTAMP WITH TIME ZONE,
+    unpinned_by VARCHAR(255)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_record_pins_active ON record_pins(record_id, case_id) WHERE unpinned_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_record_pins_case ON record_pins(case_id) WHERE unpinned_at IS NULL;
+
+-- Pins and unpins join the activity feed
+CREATE OR REPLACE VIEW admin_activity_feed AS
+    SELECT 'record:' || id AS event_id, 'record' AS kind, action, changed_by AS actor, nik AS target,
+        jsonb_build_object('list', COALESCE(after, before)->>'list_type') AS details, changed_at AS occurred_at
+    FROM blacklist_history
+UNION ALL
+    SELECT 'quarantine:' || id, 'quarantine', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, id::text,
+        jsonb_build_object('source', source, 'added', added_count, 'updated', updated_count, 'deleted', deleted_count),
+        decided_at
+    FROM sync_quarantine
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'breakglass:' || id || ':issue', 'breakglass', 'issue', subject, id::text,
+        jsonb_build_object('justification', justification, 'expires_at', expires_at), created_at
+    FROM breakglass_grants
+UNION ALL
+    SELECT 'breakglass:' || id || ':revoke', 'breakglass', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM breakglass_grants
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'whitelist:' || id || ':create', 'whitelist', 'create', created_by, id::text,
+        jsonb_build_object('record_id', record_id, 'reason', reason, 'expires_at', expires_at), created_at
+    FROM whitelist
+UNION ALL
+    SELECT 'whitelist:' || id || ':revoke', 'whitelist', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM whitelist
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'pin:' || id || ':pin', 'pin', 'pin', pinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id, 'reason', reason), pinned_at
+    FROM record_pins
+UNION ALL
+    SELECT 'pin:' || id || ':unpin', 'pin', 'unpin', unpinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id), unpinned_at
+    FROM record_pins
+    WHERE unpinned_at IS NOT NULL
+UNION ALL
+    SELECT 'activity:' || id, kind, action, actor, target, details, occurred_at
+    FROM admin_activity;
@@ -0,0 +1,44 @@
+-- Restore the history trigger without the NIK protection columns
+CREATE OR REPLACE FUNCTION record_blacklist_history() RETURNS trigger AS $$
+DECLARE
+    derived CONSTANT TEXT[] := ARRAY['name_phonetic', 'name_normalized', 'name_sorted', 'nik_hash', 'updated_at', 'expiry_notified_at'];
+    change_action TEXT;
+    change_nik TEXT;
+    before_row JSONB;
+    after_row JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        change_action := 'create';
+        change_nik := NEW.nik;
+        after_row := to_jsonb(NEW);
+    ELSIF TG_OP = 'DELETE' THEN
+        change_action := 'purge';
+        change_nik := OLD.nik;
+        before_row := to_jsonb(OLD);
+    ELSE
+        change_nik := NEW.nik;
+        before_row := to_jsonb(OLD);
+        after_row := to_jsonb(NEW);
+        IF (before_row - derived) = (after_row - derived) THEN
+            RETURN NEW;
+        END IF;
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_action := 'delete';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_action := 'restore';
+        ELSE
+            change_action := 'update';
+        END IF;
+    END IF;
+
+    INSERT INTO blacklist_history (nik, action, before, after, changed_by)
+    VALUES (change_nik, change_action, before_row, after_row,
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), current_user));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_blacklist_nik_hmac;
+
+ALTER TABLE blacklist DROP COLUMN IF EXISTS nik_hmac;
+ALTER TABLE blacklist DROP COLUMN IF EXISTS nik_encrypted;
@@ -0,0 +1,47 @@
+-- NIKs are stored encrypted alongside the plaintext, with a keyed hash for
+-- exact lookups. Both are computed by the application, which holds the keys,
+-- and are empty on rows written without them until backfilled.
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS nik_encrypted TEXT NOT NULL DEFAULT '';
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS nik_hmac CHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_nik_hmac ON blacklist(list_type, nik_hmac) WHERE nik_hmac <> '';
+
+-- Encrypting and keying a NIK is not a change to the record
+CREATE OR REPLACE FUNCTION record_blacklist_history() RETURNS trigger AS $$
+DECLARE
+    derived CONSTANT TEXT[] := ARRAY['name_phonetic', 'name_normalized', 'name_sorted', 'nik_hash', 'nik_encrypted', 'nik_hmac', 'updated_at', 'expiry_notified_at'];
+    change_action TEXT;
+    change_nik TEXT;
+    before_row JSONB;
+    after_row JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        change_action := 'create';
+        change_nik := NEW.nik;
+        after_row := to_jsonb(NEW);
+    ELSIF TG_OP = 'DELETE' THEN
+        change_action := 'purge';
+        change_nik := OLD.nik;
+        before_row := to_jsonb(OLD);
+    ELSE
+        change_nik := NEW.nik;
+        before_row := to_jsonb(OLD);
+        after_row := to_jsonb(NEW);
+        IF (before_row - derived) = (after_row - derived) THEN
+            RETURN NEW;
+        END IF;
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_action := 'delete';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_action := 'restore';
+        ELSE
+            change_action := 'update';
+        END IF;
+    END IF;
+
+    INSERT INTO blacklist_history (nik, action, before, after, changed_by)
+    VALUES (change_nik, change_action, before_row, after_row,
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), current_user));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,71 @@
+-- Only the empty tenant fits the single-tenant schema
+CREATE OR REPLACE FUNCTION record_blacklist_history() RETURNS trigger AS $$
+DECLARE
+    derived CONSTANT TEXT[] := ARRAY['name_phonetic', 'name_normalized', 'name_sorted', 'nik_hash', 'nik_encrypted', 'nik_hmac', 'updated_at', 'expiry_notified_at'];
+    change_action TEXT;
+    change_nik TEXT;
+    before_row JSONB;
+    after_row JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        change_action := 'create';
+        change_nik := NEW.nik;
+        after_row := to_jsonb(NEW);
+    ELSIF TG_OP = 'DELETE' THEN
+        change_action := 'purge';
+        change_nik := OLD.nik;
+        before_row := to_jsonb(OLD);
+    ELSE
+        change_nik := NEW.nik;
+        before_row := to_jsonb(OLD);
+        after_row := to_jsonb(NEW);
+        IF (before_row - derived) = (after_row - derived) THEN
+            RETURN NEW;
+        END IF;
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_action := 'delete';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_action := 'restore';
+        ELSE
+            change_action := 'update';
+        END IF;
+    END IF;
+
+    INSERT INTO blacklist_history (nik, action, before, after, changed_by)
+    VALUES (change_nik, change_action, before_row, after_row,
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), current_user));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DELETE FROM whitelist WHERE tenant_id <> '';
+DELETE FROM screening_jobs WHERE tenant_id <> '';
+DELETE FROM entity_blacklist WHERE tenant_id <> '';
+DELETE FROM check_history WHERE tenant_id <> '';
+DELETE FROM blacklist_history WHERE tenant_id <> '';
+
+DROP INDEX IF EXISTS idx_entity_blacklist_registration;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_entity_blacklist_registration
+    ON entity_blacklist(country, registration_number) WHERE registration_number <> '';
+
+ALTER TABLE check_history DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE screening_jobs DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE entity_blacklist DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE whitelist DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_blacklist_history_nik;
+CREATE INDEX IF NOT EXISTS idx_blacklist_history_nik ON blacklist_history(nik, id);
+ALTER TABLE blacklist_history DROP COLUMN IF EXISTS list_type;
+ALTER TABLE blacklist_history DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_blacklist_nik_hmac;
+CREATE INDEX IF NOT EXISTS idx_blacklist_nik_hmac ON blacklist(list_type, nik_hmac) WHERE nik_hmac <> '';
+
+-- Tenants' records go too, along with what references them
+DELETE FROM whitelist WHERE record_id IN (SELECT id FROM blacklist WHERE tenant_id <> '');
+DELETE FROM record_pins WHERE record_id IN (SELECT id FROM blacklist WHERE tenant_id <> '');
+DELETE FROM blacklist WHERE tenant_id <> '';
+
+ALTER TABLE blacklist DROP CONSTRAINT IF EXISTS blacklist_pkey;
+ALTER TABLE blacklist ADD PRIMARY KEY (list_type, nik);
+ALTER TABLE blacklist DROP COLUMN IF EXISTS tenant_id;
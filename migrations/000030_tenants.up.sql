@@ -0,0 +1,71 @@
+-- Records, their history, whitelist entries, entities, screening jobs and
+-- check history belong to a tenant. The empty tenant holds the records of
+-- callers without one and those of the lists every tenant shares, so
+-- existing rows keep working unchanged.
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100) NOT NULL DEFAULT '';
+
+ALTER TABLE blacklist DROP CONSTRAINT IF EXISTS blacklist_pkey;
+ALTER TABLE blacklist ADD PRIMARY KEY (tenant_id, list_type, nik);
+
+DROP INDEX IF EXISTS idx_blacklist_nik_hmac;
+CREATE INDEX IF NOT EXISTS idx_blacklist_nik_hmac ON blacklist(tenant_id, list_type, nik_hmac) WHERE nik_hmac <> '';
+
+-- A record's history is read by the tenant and list it was recorded under
+ALTER TABLE blacklist_history ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE blacklist_history ADD COLUMN IF NOT EXISTS list_type VARCHAR(20) NOT NULL DEFAULT '';
+
+UPDATE blacklist_history
+SET list_type = COALESCE(COALESCE(after, before)->>'list_type', 'internal')
+WHERE list_type = '';
+
+DROP INDEX IF EXISTS idx_blacklist_history_nik;
+CREATE INDEX IF NOT EXISTS idx_blacklist_history_nik ON blacklist_history(tenant_id, nik, id);
+
+ALTER TABLE whitelist ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE entity_blacklist ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE screening_jobs ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE check_history ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100) NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS idx_entity_blacklist_registration;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_entity_blacklist_registration
+    ON entity_blacklist(tenant_id, country, registration_number) WHERE registration_number <> '';
+
+-- Record the tenant and list of each change
+CREATE OR REPLACE FUNCTION record_blacklist_history() RETURNS trigger AS $$
+DECLARE
+    derived CONSTANT TEXT[] := ARRAY['name_phonetic', 'name_normalized', 'name_sorted', 'nik_hash', 'nik_encrypted', 'nik_hmac', 'updated_at', 'expiry_notified_at'];
+    change_action TEXT;
+    change_row blacklist;
+    before_row JSONB;
+    after_row JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        change_action := 'create';
+        change_row := NEW;
+        after_row := to_jsonb(NEW);
+    ELSIF TG_OP = 'DELETE' THEN
+        change_action := 'purge';
+        change_row := OLD;
+        before_row := to_jsonb(OLD);
+    ELSE
+        change_row := NEW;
+        before_row := to_jsonb(OLD);
+        after_row := to_jsonb(NEW);
+        IF (before_row - derived) = (after_row - derived) THEN
+            RETURN NEW;
+        END IF;
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_action := 'delete';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_action := 'restore';
+        ELSE
+            change_action := 'update';
+        END IF;
+    END IF;
+
+    INSERT INTO blacklist_history (tenant_id, list_type, nik, action, before, after, changed_by)
+    VALUES (change_row.tenant_id, change_row.list_type, change_row.nik, change_action, before_row, after_row,
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), current_user));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
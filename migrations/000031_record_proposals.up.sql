@@ -0,0 +1,71 @@
+-- Record changes proposed for four-eyes approval. A proposal holds the
+-- change until a second user approves it, which applies it to the live
+-- table, or rejects it.
+CREATE TABLE IF NOT EXISTS record_proposals (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(100) NOT NULL DEFAULT '',
+    action VARCHAR(20) NOT NULL,
+    list_type VARCHAR(20) NOT NULL,
+    nik VARCHAR(50) NOT NULL,
+    record JSONB,
+    days INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    proposed_by VARCHAR(255) NOT NULL,
+    proposed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_by VARCHAR(255),
+    decided_at TIMESTAMP WITH TIME ZONE,
+    comment TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_record_proposals_status ON record_proposals(tenant_id, status, id);
+
+-- Proposals and their decisions join the activity feed
+CREATE OR REPLACE VIEW admin_activity_feed AS
+    SELECT 'record:' || id AS event_id, 'record' AS kind, action, changed_by AS actor, nik AS target,
+        jsonb_build_object('list', COALESCE(after, before)->>'list_type') AS details, changed_at AS occurred_at
+    FROM blacklist_history
+UNION ALL
+    SELECT 'quarantine:' || id, 'quarantine', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, id::text,
+        jsonb_build_object('source', source, 'added', added_count, 'updated', updated_count, 'deleted', deleted_count),
+        decided_at
+    FROM sync_quarantine
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'breakglass:' || id || ':issue', 'breakglass', 'issue', subject, id::text,
+        jsonb_build_object('justification', justification, 'expires_at', expires_at), created_at
+    FROM breakglass_grants
+UNION ALL
+    SELECT 'breakglass:' || id || ':revoke', 'breakglass', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM breakglass_grants
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'whitelist:' || id || ':create', 'whitelist', 'create', created_by, id::text,
+        jsonb_build_object('record_id', record_id, 'reason', reason, 'expires_at', expires_at), created_at
+    FROM whitelist
+UNION ALL
+    SELECT 'whitelist:' || id || ':revoke', 'whitelist', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM whitelist
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'pin:' || id || ':pin', 'pin', 'pin', pinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id, 'reason', reason), pinned_at
+    FROM record_pins
+UNION ALL
+    SELECT 'pin:' || id || ':unpin', 'pin', 'unpin', unpinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id), unpinned_at
+    FROM record_pins
+    WHERE unpinned_at IS NOT NULL
+UNION ALL
+    SELECT 'proposal:' || id || ':propose', 'proposal', 'propose', proposed_by, nik,
+        jsonb_build_object('proposal_id', id, 'action', action, 'list', list_type), proposed_at
+    FROM record_proposals
+UNION ALL
+    SELECT 'proposal:' || id || ':decide', 'proposal', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, nik,
+        jsonb_build_object('proposal_id', id, 'action', action, 'list', list_type, 'comment', comment), decided_at
+    FROM record_proposals
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'activity:' || id, kind, action, actor, target, details, occurred_at
+    FROM admin_activity;
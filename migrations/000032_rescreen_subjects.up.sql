@@ -0,0 +1,26 @@
+-- Subjects enrolled for re-screening. Each is identified by the same hash as
+-- its screening decision events. The subject's fields are only kept while it
+-- consents, and then tokenized like check history when tokenization is
+-- enabled, so that re-screening can run the check again.
+CREATE TABLE IF NOT EXISTS rescreen_subjects (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(100) NOT NULL DEFAULT '',
+    subject_hash VARCHAR(64) NOT NULL,
+    consent BOOLEAN NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    nik VARCHAR(255) NOT NULL DEFAULT '',
+    id_country VARCHAR(2) NOT NULL DEFAULT '',
+    birth_place VARCHAR(100) NOT NULL DEFAULT '',
+    birth_date DATE,
+    lists TEXT[],
+    profile VARCHAR(50) NOT NULL DEFAULT '',
+    tokenized BOOLEAN NOT NULL DEFAULT false,
+    outcome VARCHAR(20) NOT NULL,
+    match_type VARCHAR(50) NOT NULL,
+    enrolled_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    screened_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, subject_hash)
+);
+
+-- Consenting subjects last cleared, by when they were last screened
+CREATE INDEX IF NOT EXISTS idx_rescreen_subjects_due ON rescreen_subjects(screened_at) WHERE consent AND outcome = 'clear';
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS blacklist_aliases_version_delete ON blacklist_aliases;
+DROP TRIGGER IF EXISTS blacklist_aliases_version_update ON blacklist_aliases;
+DROP TRIGGER IF EXISTS blacklist_aliases_version_insert ON blacklist_aliases;
+DROP TRIGGER IF EXISTS blacklist_version_delete ON blacklist;
+DROP TRIGGER IF EXISTS blacklist_version_update ON blacklist;
+DROP TRIGGER IF EXISTS blacklist_version_insert ON blacklist;
+DROP FUNCTION IF EXISTS bump_table_version();
+DROP TABLE IF EXISTS table_versions;
@@ -0,0 +1,50 @@
+-- Change counters of the tables behind the record listings, which their
+-- ETags are computed from. Triggers bump a table's counter whenever a
+-- statement changes rows of it, whichever replica or job made the change.
+CREATE TABLE IF NOT EXISTS table_versions (
+    table_name VARCHAR(100) PRIMARY KEY,
+    version BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO table_versions (table_name) VALUES ('blacklist') ON CONFLICT DO NOTHING;
+
+-- Bumps the counter named by the trigger's argument. Statement triggers fire
+-- for updates that touch no rows, as the expiry sweeps mostly do, so only a
+-- statement whose transition table holds rows counts as a change.
+CREATE OR REPLACE FUNCTION bump_table_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM changed) THEN
+        UPDATE table_versions SET version = version + 1 WHERE table_name = TG_ARGV[0];
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- A trigger with a transition table handles a single event, so each table
+-- has one per event. Records are listed with their aliases, so alias changes
+-- bump the blacklist counter too.
+DROP TRIGGER IF EXISTS blacklist_version_insert ON blacklist;
+CREATE TRIGGER blacklist_version_insert
+    AFTER INSERT ON blacklist REFERENCING NEW TABLE AS changed
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_table_version('blacklist');
+DROP TRIGGER IF EXISTS blacklist_version_update ON blacklist;
+CREATE TRIGGER blacklist_version_update
+    AFTER UPDATE ON blacklist REFERENCING NEW TABLE AS changed
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_table_version('blacklist');
+DROP TRIGGER IF EXISTS blacklist_version_delete ON blacklist;
+CREATE TRIGGER blacklist_version_delete
+    AFTER DELETE ON blacklist REFERENCING OLD TABLE AS changed
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_table_version('blacklist');
+
+DROP TRIGGER IF EXISTS blacklist_aliases_version_insert ON blacklist_aliases;
+CREATE TRIGGER blacklist_aliases_version_insert
+    AFTER INSERT ON blacklist_aliases REFERENCING NEW TABLE AS changed
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_table_version('blacklist');
+DROP TRIGGER IF EXISTS blacklist_aliases_version_update ON blacklist_aliases;
+CREATE TRIGGER blacklist_aliases_version_update
+    AFTER UPDATE ON blacklist_aliases REFERENCING NEW TABLE AS changed
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_table_version('blacklist');
+DROP TRIGGER IF EXISTS blacklist_aliases_version_delete ON blacklist_aliases;
+CREATE TRIGGER blacklist_aliases_version_delete
+    AFTER DELETE ON blacklist_aliases REFERENCING OLD TABLE AS changed
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_table_version('blacklist');
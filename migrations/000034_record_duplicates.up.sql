@@ -0,0 +1,87 @@
+-- Pairs of live records of a list that likely describe the same person,
+-- found by the duplicate detection job: NIKs that are the same once their
+-- separators are stripped, or the same birth date and similar names. Each
+-- pair is found once; a reviewer merges it, keeping one of the records, or
+-- dismisses it, and a dismissed pair isn't found again.
+CREATE TABLE IF NOT EXISTS record_duplicates (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(100) NOT NULL DEFAULT '',
+    list_type VARCHAR(20) NOT NULL,
+    record_id BIGINT NOT NULL REFERENCES blacklist(id) ON DELETE CASCADE,
+    duplicate_id BIGINT NOT NULL REFERENCES blacklist(id) ON DELETE CASCADE,
+    reason VARCHAR(20) NOT NULL,
+    similarity REAL NOT NULL DEFAULT 1,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    found_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    kept_id BIGINT,
+    decided_by VARCHAR(255),
+    decided_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (record_id, duplicate_id),
+    CHECK (record_id < duplicate_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_record_duplicates_status ON record_duplicates(tenant_id, status, id);
+
+-- NIKs are compared by their digits, so the same NIK imported with and
+-- without separators is found
+CREATE INDEX IF NOT EXISTS idx_blacklist_nik_digits
+    ON blacklist(tenant_id, list_type, (regexp_replace(nik, '[^0-9]', '', 'g')))
+    WHERE deleted_at IS NULL;
+
+-- Merges and dismissals join the activity feed
+CREATE OR REPLACE VIEW admin_activity_feed AS
+    SELECT 'record:' || id AS event_id, 'record' AS kind, action, changed_by AS actor, nik AS target,
+        jsonb_build_object('list', COALESCE(after, before)->>'list_type') AS details, changed_at AS occurred_at
+    FROM blacklist_history
+UNION ALL
+    SELECT 'quarantine:' || id, 'quarantine', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, id::text,
+        jsonb_build_object('source', source, 'added', added_count, 'updated', updated_count, 'deleted', deleted_count),
+        decided_at
+    FROM sync_quarantine
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'breakglass:' || id || ':issue', 'breakglass', 'issue', subject, id::text,
+        jsonb_build_object('justification', justification, 'expires_at', expires_at), created_at
+    FROM breakglass_grants
+UNION ALL
+    SELECT 'breakglass:' || id || ':revoke', 'breakglass', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM breakglass_grants
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'whitelist:' || id || ':create', 'whitelist', 'create', created_by, id::text,
+        jsonb_build_object('record_id', record_id, 'reason', reason, 'expires_at', expires_at), created_at
+    FROM whitelist
+UNION ALL
+    SELECT 'whitelist:' || id || ':revoke', 'whitelist', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM whitelist
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'pin:' || id || ':pin', 'pin', 'pin', pinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id, 'reason', reason), pinned_at
+    FROM record_pins
+UNION ALL
+    SELECT 'pin:' || id || ':unpin', 'pin', 'unpin', unpinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id), unpinned_at
+    FROM record_pins
+    WHERE unpinned_at IS NOT NULL
+UNION ALL
+    SELECT 'proposal:' || id || ':propose', 'proposal', 'propose', proposed_by, nik,
+        jsonb_build_object('proposal_id', id, 'action', action, 'list', list_type), proposed_at
+    FROM record_proposals
+UNION ALL
+    SELECT 'proposal:' || id || ':decide', 'proposal', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, nik,
+        jsonb_build_object('proposal_id', id, 'action', action, 'list', list_type, 'comment', comment), decided_at
+    FROM record_proposals
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'duplicate:' || id, 'duplicate', CASE status WHEN 'merged' THEN 'merge' ELSE 'dismiss' END,
+        decided_by, id::text,
+        jsonb_build_object('list', list_type, 'record_id', record_id, 'duplicate_id', duplicate_id, 'kept_id', kept_id, 'reason', reason),
+        decided_at
+    FROM record_duplicates
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'activity:' || id, kind, action, actor, target, details, occurred_at
+    FROM admin_activity;
@@ -0,0 +1,36 @@
+-- Review cases opened by positive production checks. Each keeps a snapshot
+-- of the subject, tokenized like check history when tokenization is enabled,
+-- and the record it matched. A subject matching the same record again while
+-- its case is open adds a hit to that case instead of opening another.
+CREATE TABLE IF NOT EXISTS review_cases (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(100) NOT NULL DEFAULT '',
+    subject_hash VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    nik VARCHAR(255) NOT NULL DEFAULT '',
+    birth_place VARCHAR(100) NOT NULL DEFAULT '',
+    birth_date DATE,
+    tokenized BOOLEAN NOT NULL DEFAULT false,
+    list_type VARCHAR(20) NOT NULL,
+    record_id BIGINT NOT NULL REFERENCES blacklist(id) ON DELETE CASCADE,
+    match_type VARCHAR(50) NOT NULL,
+    score REAL NOT NULL DEFAULT 0,
+    confidence REAL NOT NULL DEFAULT 0,
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    assignee VARCHAR(255),
+    notes TEXT NOT NULL DEFAULT '',
+    hits INTEGER NOT NULL DEFAULT 1,
+    opened_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_hit_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_by VARCHAR(255),
+    updated_at TIMESTAMP WITH TIME ZONE,
+    CHECK (status IN ('open', 'cleared', 'confirmed'))
+);
+
+-- At most one open case per subject and matched record
+CREATE UNIQUE INDEX IF NOT EXISTS idx_review_cases_open
+    ON review_cases(tenant_id, subject_hash, record_id)
+    WHERE status = 'open';
+
+CREATE INDEX IF NOT EXISTS idx_review_cases_status ON review_cases(tenant_id, status, id);
@@ -0,0 +1,123 @@
+-- Rows stored without their plaintext NIK can't be keyed by it again; the
+-- primary key is only restored once none are left
+CREATE OR REPLACE FUNCTION record_blacklist_history() RETURNS trigger AS $$
+DECLARE
+    derived CONSTANT TEXT[] := ARRAY['name_phonetic', 'name_normalized', 'name_sorted', 'nik_hash', 'nik_encrypted', 'nik_hmac', 'updated_at', 'expiry_notified_at'];
+    change_action TEXT;
+    change_row blacklist;
+    before_row JSONB;
+    after_row JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        change_action := 'create';
+        change_row := NEW;
+        after_row := to_jsonb(NEW);
+    ELSIF TG_OP = 'DELETE' THEN
+        change_action := 'purge';
+        change_row := OLD;
+        before_row := to_jsonb(OLD);
+    ELSE
+        change_row := NEW;
+        before_row := to_jsonb(OLD);
+        after_row := to_jsonb(NEW);
+        IF (before_row - derived) = (after_row - derived) THEN
+            RETURN NEW;
+        END IF;
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_action := 'delete';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_action := 'restore';
+        ELSE
+            change_action := 'update';
+        END IF;
+    END IF;
+
+    INSERT INTO blacklist_history (tenant_id, list_type, nik, action, before, after, changed_by)
+    VALUES (change_row.tenant_id, change_row.list_type, change_row.nik, change_action, before_row, after_row,
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), current_user));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE VIEW admin_activity_feed AS
+    SELECT 'record:' || id AS event_id, 'record' AS kind, action, changed_by AS actor, nik AS target,
+        jsonb_build_object('list', COALESCE(after, before)->>'list_type') AS details, changed_at AS occurred_at
+    FROM blacklist_history
+UNION ALL
+    SELECT 'quarantine:' || id, 'quarantine', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, id::text,
+        jsonb_build_object('source', source, 'added', added_count, 'updated', updated_count, 'deleted', deleted_count),
+        decided_at
+    FROM sync_quarantine
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'breakglass:' || id || ':issue', 'breakglass', 'issue', subject, id::text,
+        jsonb_build_object('justification', justification, 'expires_at', expires_at), created_at
+    FROM breakglass_grants
+UNION ALL
+    SELECT 'breakglass:' || id || ':revoke', 'breakglass', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM breakglass_grants
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'whitelist:' || id || ':create', 'whitelist', 'create', created_by, id::text,
+        jsonb_build_object('record_id', record_id, 'reason', reason, 'expires_at', expires_at), created_at
+    FROM whitelist
+UNION ALL
+    SELECT 'whitelist:' || id || ':revoke', 'whitelist', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM whitelist
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'pin:' || id || ':pin', 'pin', 'pin', pinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id, 'reason', reason), pinned_at
+    FROM record_pins
+UNION ALL
+    SELECT 'pin:' || id || ':unpin', 'pin', 'unpin', unpinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id), unpinned_at
+    FROM record_pins
+    WHERE unpinned_at IS NOT NULL
+UNION ALL
+    SELECT 'proposal:' || id || ':propose', 'proposal', 'propose', proposed_by, nik,
+        jsonb_build_object('proposal_id', id, 'action', action, 'list', list_type), proposed_at
+    FROM record_proposals
+UNION ALL
+    SELECT 'proposal:' || id || ':decide', 'proposal', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, nik,
+        jsonb_build_object('proposal_id', id, 'action', action, 'list', list_type, 'comment', comment), decided_at
+    FROM record_proposals
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'duplicate:' || id, 'duplicate', CASE status WHEN 'merged' THEN 'merge' ELSE 'dismiss' END,
+        decided_by, id::text,
+        jsonb_build_object('list', list_type, 'record_id', record_id, 'duplicate_id', duplicate_id, 'kept_id', kept_id, 'reason', reason),
+        decided_at
+    FROM record_duplicates
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'activity:' || id, kind, action, actor, target, details, occurred_at
+    FROM admin_activity;
+
+ALTER TABLE review_cases DROP COLUMN IF EXISTS nik_encrypted;
+
+ALTER TABLE record_proposals DROP COLUMN IF EXISTS record_encrypted;
+ALTER TABLE record_proposals DROP COLUMN IF EXISTS nik_hmac;
+ALTER TABLE record_proposals DROP COLUMN IF EXISTS nik_encrypted;
+
+DROP INDEX IF EXISTS idx_whitelist_nik_hmac;
+ALTER TABLE whitelist DROP COLUMN IF EXISTS nik_hmac;
+ALTER TABLE whitelist DROP COLUMN IF EXISTS nik_encrypted;
+
+DROP INDEX IF EXISTS idx_blacklist_history_nik_hmac;
+ALTER TABLE blacklist_history DROP COLUMN IF EXISTS nik_hmac;
+ALTER TABLE blacklist_history DROP COLUMN IF EXISTS nik_encrypted;
+
+ALTER TABLE blacklist ADD COLUMN IF NOT EXISTS nik_hash CHAR(64) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_blacklist_nik_hash ON blacklist(nik_hash);
+
+DROP INDEX IF EXISTS idx_blacklist_nik_hmac;
+CREATE INDEX IF NOT EXISTS idx_blacklist_nik_hmac ON blacklist(tenant_id, list_type, nik_hmac) WHERE nik_hmac <> '';
+
+DROP INDEX IF EXISTS idx_blacklist_nik;
+CREATE INDEX IF NOT EXISTS idx_blacklist_nik ON blacklist(nik);
+
+ALTER TABLE blacklist DROP CONSTRAINT IF EXISTS blacklist_pkey;
+ALTER TABLE blacklist ADD PRIMARY KEY (tenant_id, list_type, nik);
@@ -0,0 +1,142 @@
+-- With NIK encryption keys the plaintext NIK is no longer stored: records,
+-- their history, whitelist entries, proposals and review cases keep it
+-- encrypted, and records, history, whitelist entries and proposals are found
+-- by its keyed hash. The nik columns keep the plaintext only on rows written
+-- without keys, until the backfill protects them.
+
+-- A record is identified by its plaintext NIK or by its keyed hash, each
+-- unique within the list of a tenant
+ALTER TABLE blacklist DROP CONSTRAINT IF EXISTS blacklist_pkey;
+ALTER TABLE blacklist ADD PRIMARY KEY (id);
+
+DROP INDEX IF EXISTS idx_blacklist_nik;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blacklist_nik ON blacklist(tenant_id, list_type, nik) WHERE nik <> '';
+
+DROP INDEX IF EXISTS idx_blacklist_nik_hmac;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blacklist_nik_hmac ON blacklist(tenant_id, list_type, nik_hmac) WHERE nik_hmac <> '';
+
+-- An unkeyed hash of a NIK is reversed by hashing every possible NIK
+DROP INDEX IF EXISTS idx_blacklist_nik_hash;
+ALTER TABLE blacklist DROP COLUMN IF EXISTS nik_hash;
+
+-- History is found by the record's keyed hash and keeps no NIK in its snapshots
+ALTER TABLE blacklist_history ADD COLUMN IF NOT EXISTS nik_encrypted TEXT NOT NULL DEFAULT '';
+ALTER TABLE blacklist_history ADD COLUMN IF NOT EXISTS nik_hmac CHAR(64) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_blacklist_history_nik_hmac ON blacklist_history(tenant_id, nik_hmac, id) WHERE nik_hmac <> '';
+
+UPDATE blacklist_history
+SET before = before - ARRAY['nik', 'nik_hash', 'nik_encrypted', 'nik_hmac'],
+    after = after - ARRAY['nik', 'nik_hash', 'nik_encrypted', 'nik_hmac']
+WHERE before ?| ARRAY['nik', 'nik_hash', 'nik_encrypted', 'nik_hmac']
+    OR after ?| ARRAY['nik', 'nik_hash', 'nik_encrypted', 'nik_hmac'];
+
+CREATE OR REPLACE FUNCTION record_blacklist_history() RETURNS trigger AS $$
+DECLARE
+    protected CONSTANT TEXT[] := ARRAY['nik', 'nik_encrypted', 'nik_hmac'];
+    derived CONSTANT TEXT[] := ARRAY['name_phonetic', 'name_normalized', 'name_sorted', 'updated_at', 'expiry_notified_at'];
+    change_action TEXT;
+    change_row blacklist;
+    before_row JSONB;
+    after_row JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        change_action := 'create';
+        change_row := NEW;
+        after_row := to_jsonb(NEW) - protected;
+    ELSIF TG_OP = 'DELETE' THEN
+        change_action := 'purge';
+        change_row := OLD;
+        before_row := to_jsonb(OLD) - protected;
+    ELSE
+        change_row := NEW;
+        before_row := to_jsonb(OLD) - protected;
+        after_row := to_jsonb(NEW) - protected;
+        -- Protecting a NIK in place is not a change to the record either
+        IF (before_row - derived) = (after_row - derived) THEN
+            RETURN NEW;
+        END IF;
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_action := 'delete';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_action := 'restore';
+        ELSE
+            change_action := 'update';
+        END IF;
+    END IF;
+
+    INSERT INTO blacklist_history (tenant_id, list_type, nik, nik_encrypted, nik_hmac, action, before, after, changed_by)
+    VALUES (change_row.tenant_id, change_row.list_type, change_row.nik, change_row.nik_encrypted, change_row.nik_hmac,
+        change_action, before_row, after_row,
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), current_user));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE whitelist ADD COLUMN IF NOT EXISTS nik_encrypted TEXT NOT NULL DEFAULT '';
+ALTER TABLE whitelist ADD COLUMN IF NOT EXISTS nik_hmac CHAR(64) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_whitelist_nik_hmac ON whitelist(nik_hmac) WHERE revoked_at IS NULL AND nik_hmac <> '';
+
+-- A proposal keeps its record encrypted in record_encrypted instead of record
+ALTER TABLE record_proposals ADD COLUMN IF NOT EXISTS nik_encrypted TEXT NOT NULL DEFAULT '';
+ALTER TABLE record_proposals ADD COLUMN IF NOT EXISTS nik_hmac CHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE record_proposals ADD COLUMN IF NOT EXISTS record_encrypted TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE review_cases ADD COLUMN IF NOT EXISTS nik_encrypted TEXT NOT NULL DEFAULT '';
+
+-- Record changes and proposals without a plaintext NIK target its keyed hash
+CREATE OR REPLACE VIEW admin_activity_feed AS
+    SELECT 'record:' || id AS event_id, 'record' AS kind, action, changed_by AS actor, COALESCE(NULLIF(nik, ''), nik_hmac) AS target,
+        jsonb_build_object('list', COALESCE(after, before)->>'list_type') AS details, changed_at AS occurred_at
+    FROM blacklist_history
+UNION ALL
+    SELECT 'quarantine:' || id, 'quarantine', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, id::text,
+        jsonb_build_object('source', source, 'added', added_count, 'updated', updated_count, 'deleted', deleted_count),
+        decided_at
+    FROM sync_quarantine
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'breakglass:' || id || ':issue', 'breakglass', 'issue', subject, id::text,
+        jsonb_build_object('justification', justification, 'expires_at', expires_at), created_at
+    FROM breakglass_grants
+UNION ALL
+    SELECT 'breakglass:' || id || ':revoke', 'breakglass', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM breakglass_grants
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'whitelist:' || id || ':create', 'whitelist', 'create', created_by, id::text,
+        jsonb_build_object('record_id', record_id, 'reason', reason, 'expires_at', expires_at), created_at
+    FROM whitelist
+UNION ALL
+    SELECT 'whitelist:' || id || ':revoke', 'whitelist', 'revoke', revoked_by, id::text, NULL, revoked_at
+    FROM whitelist
+    WHERE revoked_at IS NOT NULL
+UNION ALL
+    SELECT 'pin:' || id || ':pin', 'pin', 'pin', pinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id, 'reason', reason), pinned_at
+    FROM record_pins
+UNION ALL
+    SELECT 'pin:' || id || ':unpin', 'pin', 'unpin', unpinned_by, id::text,
+        jsonb_build_object('record_id', record_id, 'case_id', case_id), unpinned_at
+    FROM record_pins
+    WHERE unpinned_at IS NOT NULL
+UNION ALL
+    SELECT 'proposal:' || id || ':propose', 'proposal', 'propose', proposed_by, COALESCE(NULLIF(nik, ''), nik_hmac),
+        jsonb_build_object('proposal_id', id, 'action', action, 'list', list_type), proposed_at
+    FROM record_proposals
+UNION ALL
+    SELECT 'proposal:' || id || ':decide', 'proposal', CASE status WHEN 'approved' THEN 'approve' ELSE 'reject' END,
+        decided_by, COALESCE(NULLIF(nik, ''), nik_hmac),
+        jsonb_build_object('proposal_id', id, 'action', action, 'list', list_type, 'comment', comment), decided_at
+    FROM record_proposals
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'duplicate:' || id, 'duplicate', CASE status WHEN 'merged' THEN 'merge' ELSE 'dismiss' END,
+        decided_by, id::text,
+        jsonb_build_object('list', list_type, 'record_id', record_id, 'duplicate_id', duplicate_id, 'kept_id', kept_id, 'reason', reason),
+        decided_at
+    FROM record_duplicates
+    WHERE decided_at IS NOT NULL
+UNION ALL
+    SELECT 'activity:' || id, kind, action, actor, target, details, occurred_at
+    FROM admin_activity;
@@ -0,0 +1,3 @@
+-- Stored responses echo the NIKs of the requests they answer, so with NIK
+-- keys set they are kept encrypted in body_encrypted and body stays NULL
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS body_encrypted TEXT NOT NULL DEFAULT '';
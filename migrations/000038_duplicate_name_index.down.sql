@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_blacklist_name_normalized_trgm;
@@ -0,0 +1,5 @@
+-- Duplicate detection looks up the live records with a name similar to each
+-- changed record's with the % operator
+CREATE INDEX IF NOT EXISTS idx_blacklist_name_normalized_trgm
+    ON blacklist USING gin (name_normalized gin_trgm_ops)
+    WHERE deleted_at IS NULL;
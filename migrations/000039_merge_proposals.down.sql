@@ -0,0 +1,2 @@
+ALTER TABLE record_proposals DROP COLUMN IF EXISTS keep_id;
+ALTER TABLE record_proposals DROP COLUMN IF EXISTS duplicate_id;
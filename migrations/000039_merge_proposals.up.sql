@@ -0,0 +1,4 @@
+-- With approvals enabled, merging a duplicate pair is proposed like any
+-- other record change; the proposal names the pair and the record it keeps
+ALTER TABLE record_proposals ADD COLUMN IF NOT EXISTS duplicate_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE record_proposals ADD COLUMN IF NOT EXISTS keep_id BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+-- How far duplicate detection has got, shared by every replica: records
+-- changed since are still to be looked at. Detection holds the row locked
+-- while it runs, so replicas don't repeat each other's scans.
+CREATE TABLE IF NOT EXISTS duplicate_detection (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    since TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT 'epoch'
+);
+
+INSERT INTO duplicate_detection (id) VALUES (TRUE) ON CONFLICT DO NOTHING;
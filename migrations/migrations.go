@@ -0,0 +1,30 @@
+// Package migrations embeds the SQL migration files so the service can apply them on startup.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// FS holds the up and down migration files
+//
+//go:embed *.sql
+var FS embed.FS
+
+// portable holds the migrations of the MySQL and SQLite stores, one
+// directory per driver
+//
+//go:embed mysql/*.sql sqlite/*.sql
+var portable embed.FS
+
+// For returns the migrations of a DB_DRIVER
+func For(driver string) (fs.FS, error) {
+	switch driver {
+	case "postgres":
+		return FS, nil
+	case "mysql", "sqlite":
+		return fs.Sub(portable, driver)
+	}
+	return nil, fmt.Errorf("no migrations for driver %q", driver)
+}
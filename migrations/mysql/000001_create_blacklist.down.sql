@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS blacklist_history;
+DROP TABLE IF EXISTS blacklist_aliases;
+DROP TABLE IF EXISTS blacklist;
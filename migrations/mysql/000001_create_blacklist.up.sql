@@ -0,0 +1,59 @@
+-- The blacklist store's tables, as the Postgres migrations leave them. MySQL
+-- has no trigram similarity; name lookups narrow the candidates with n-gram
+-- fulltext indexes and score them in the application.
+CREATE TABLE IF NOT EXISTS blacklist (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    list_type VARCHAR(20) NOT NULL DEFAULT 'internal',
+    nik VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    birth_place VARCHAR(100) NOT NULL DEFAULT '',
+    birth_date DATE NULL,
+    reason TEXT NOT NULL,
+    reason_code VARCHAR(50) NOT NULL DEFAULT '',
+    -- JSON is kept as text; the driver sends it as a binary string, which
+    -- JSON columns refuse
+    reason_params TEXT NOT NULL,
+    source VARCHAR(50) NOT NULL DEFAULT 'internal',
+    name_phonetic VARCHAR(255) NOT NULL DEFAULT '',
+    name_normalized VARCHAR(255) NOT NULL DEFAULT '',
+    name_sorted VARCHAR(255) NOT NULL DEFAULT '',
+    nik_hash CHAR(64) NOT NULL DEFAULT '',
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    deleted_at DATETIME(6) NULL,
+    deleted_by VARCHAR(255) NULL,
+    expires_at DATETIME(6) NULL,
+    expiry_notified_at DATETIME(6) NULL,
+    UNIQUE KEY idx_blacklist_list_nik (list_type, nik),
+    KEY idx_blacklist_name_phonetic (name_phonetic),
+    KEY idx_blacklist_source (source),
+    KEY idx_blacklist_created_at_id (created_at, id),
+    KEY idx_blacklist_updated_at_id (updated_at, id),
+    KEY idx_blacklist_expires_at (expires_at),
+    FULLTEXT KEY idx_blacklist_name_ngram (name) WITH PARSER ngram
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS blacklist_aliases (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    record_id BIGINT NOT NULL,
+    alias VARCHAR(255) NOT NULL,
+    alias_type VARCHAR(20) NOT NULL DEFAULT 'aka',
+    name_phonetic VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY idx_blacklist_aliases_record_alias (record_id, alias),
+    KEY idx_blacklist_aliases_name_phonetic (name_phonetic),
+    FULLTEXT KEY idx_blacklist_aliases_alias_ngram (alias) WITH PARSER ngram,
+    CONSTRAINT fk_blacklist_aliases_record FOREIGN KEY (record_id) REFERENCES blacklist (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Written by the store alongside each change, as there is no history trigger
+CREATE TABLE IF NOT EXISTS blacklist_history (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    nik VARCHAR(50) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    `before` MEDIUMTEXT NULL,
+    `after` MEDIUMTEXT NULL,
+    changed_by VARCHAR(255) NOT NULL,
+    changed_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_blacklist_history_nik (nik, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,25 @@
+-- Change counters of the tables behind the record listings, as the Postgres
+-- migrations keep them. MySQL triggers fire per row, so a statement bumps
+-- the counter once for every row it changes. Aliases deleted along with
+-- their record by the foreign key fire no triggers, but the record's delete
+-- bumps the counter.
+CREATE TABLE IF NOT EXISTS table_versions (
+    table_name VARCHAR(100) NOT NULL PRIMARY KEY,
+    version BIGINT NOT NULL DEFAULT 0
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT IGNORE INTO table_versions (table_name) VALUES ('blacklist');
+
+CREATE TRIGGER blacklist_version_insert AFTER INSERT ON blacklist
+    FOR EACH ROW UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+CREATE TRIGGER blacklist_version_update AFTER UPDATE ON blacklist
+    FOR EACH ROW UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+CREATE TRIGGER blacklist_version_delete AFTER DELETE ON blacklist
+    FOR EACH ROW UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+
+CREATE TRIGGER blacklist_aliases_version_insert AFTER INSERT ON blacklist_aliases
+    FOR EACH ROW UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+CREATE TRIGGER blacklist_aliases_version_update AFTER UPDATE ON blacklist_aliases
+    FOR EACH ROW UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+CREATE TRIGGER blacklist_aliases_version_delete AFTER DELETE ON blacklist_aliases
+    FOR EACH ROW UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
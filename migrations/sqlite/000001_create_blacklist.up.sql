@@ -0,0 +1,58 @@
+-- The blacklist store's tables, as the Postgres migrations leave them. SQLite
+-- has no trigram similarity; name lookups score a list's records in the
+-- application.
+CREATE TABLE IF NOT EXISTS blacklist (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    list_type TEXT NOT NULL DEFAULT 'internal',
+    nik TEXT NOT NULL,
+    name TEXT NOT NULL,
+    birth_place TEXT NOT NULL DEFAULT '',
+    birth_date DATE,
+    reason TEXT NOT NULL DEFAULT '',
+    reason_code TEXT NOT NULL DEFAULT '',
+    reason_params TEXT NOT NULL DEFAULT '{}',
+    source TEXT NOT NULL DEFAULT 'internal',
+    name_phonetic TEXT NOT NULL DEFAULT '',
+    name_normalized TEXT NOT NULL DEFAULT '',
+    name_sorted TEXT NOT NULL DEFAULT '',
+    nik_hash TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    deleted_by TEXT,
+    expires_at TIMESTAMP,
+    expiry_notified_at TIMESTAMP,
+    UNIQUE (list_type, nik)
+);
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_name_phonetic ON blacklist(name_phonetic);
+CREATE INDEX IF NOT EXISTS idx_blacklist_source ON blacklist(source);
+CREATE INDEX IF NOT EXISTS idx_blacklist_created_at_id ON blacklist(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_blacklist_updated_at_id ON blacklist(updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_blacklist_expires_at ON blacklist(expires_at)
+    WHERE expires_at IS NOT NULL AND deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS blacklist_aliases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    record_id INTEGER NOT NULL REFERENCES blacklist(id) ON DELETE CASCADE,
+    alias TEXT NOT NULL,
+    alias_type TEXT NOT NULL DEFAULT 'aka',
+    name_phonetic TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (record_id, alias)
+);
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_aliases_name_phonetic ON blacklist_aliases(name_phonetic);
+
+-- Written by the store alongside each change, as there is no history trigger
+CREATE TABLE IF NOT EXISTS blacklist_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    nik TEXT NOT NULL,
+    action TEXT NOT NULL,
+    "before" TEXT,
+    "after" TEXT,
+    changed_by TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_blacklist_history_nik ON blacklist_history(nik, id);
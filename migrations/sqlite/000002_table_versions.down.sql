@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS blacklist_aliases_version_delete;
+DROP TRIGGER IF EXISTS blacklist_aliases_version_update;
+DROP TRIGGER IF EXISTS blacklist_aliases_version_insert;
+DROP TRIGGER IF EXISTS blacklist_version_delete;
+DROP TRIGGER IF EXISTS blacklist_version_update;
+DROP TRIGGER IF EXISTS blacklist_version_insert;
+DROP TABLE IF EXISTS table_versions;
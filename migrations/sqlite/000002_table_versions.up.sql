@@ -0,0 +1,35 @@
+-- Change counters of the tables behind the record listings, as the Postgres
+-- migrations keep them. SQLite triggers fire per row, so a statement bumps
+-- the counter once for every row it changes.
+CREATE TABLE IF NOT EXISTS table_versions (
+    table_name TEXT PRIMARY KEY,
+    version INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT OR IGNORE INTO table_versions (table_name) VALUES ('blacklist');
+
+CREATE TRIGGER IF NOT EXISTS blacklist_version_insert AFTER INSERT ON blacklist
+BEGIN
+    UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+END;
+CREATE TRIGGER IF NOT EXISTS blacklist_version_update AFTER UPDATE ON blacklist
+BEGIN
+    UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+END;
+CREATE TRIGGER IF NOT EXISTS blacklist_version_delete AFTER DELETE ON blacklist
+BEGIN
+    UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+END;
+
+CREATE TRIGGER IF NOT EXISTS blacklist_aliases_version_insert AFTER INSERT ON blacklist_aliases
+BEGIN
+    UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+END;
+CREATE TRIGGER IF NOT EXISTS blacklist_aliases_version_update AFTER UPDATE ON blacklist_aliases
+BEGIN
+    UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+END;
+CREATE TRIGGER IF NOT EXISTS blacklist_aliases_version_delete AFTER DELETE ON blacklist_aliases
+BEGIN
+    UPDATE table_versions SET version = version + 1 WHERE table_name = 'blacklist';
+END;
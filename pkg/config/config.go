@@ -1,65 +1,717 @@
 package config
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
+	Server      ServerConfig      `mapstructure:",squash"`
+	Database    DatabaseConfig    `mapstructure:",squash"`
+	Redis       RedisConfig       `mapstructure:",squash"`
+	Cache       CacheConfig       `mapstructure:",squash"`
+	Match       MatchConfig       `mapstructure:",squash"`
+	Sync        SyncConfig        `mapstructure:",squash"`
+	Auth        AuthConfig        `mapstructure:",squash"`
+	Screening   ScreeningConfig   `mapstructure:",squash"`
+	History     HistoryConfig     `mapstructure:",squash"`
+	Clock       ClockConfig       `mapstructure:",squash"`
+	Usage       UsageConfig       `mapstructure:",squash"`
+	BreakGlass  BreakGlassConfig  `mapstructure:",squash"`
+	NIK         NIKConfig         `mapstructure:",squash"`
+	Gazetteer   GazetteerConfig   `mapstructure:",squash"`
+	Tokenize    TokenizeConfig    `mapstructure:",squash"`
+	Whitelist   WhitelistConfig   `mapstructure:",squash"`
+	Score       ScoreConfig       `mapstructure:",squash"`
+	Watchdog    WatchdogConfig    `mapstructure:",squash"`
+	Temporary   TemporaryConfig   `mapstructure:",squash"`
+	Sandbox     SandboxConfig     `mapstructure:",squash"`
+	Tenant      TenantConfig      `mapstructure:",squash"`
+	Idempotency IdempotencyConfig `mapstructure:",squash"`
+	Events      EventsConfig      `mapstructure:",squash"`
+	Response    ResponseConfig    `mapstructure:",squash"`
+	Approval    ApprovalConfig    `mapstructure:",squash"`
+	Rescreen    RescreenConfig    `mapstructure:",squash"`
+	Dedup       DedupConfig       `mapstructure:",squash"`
+	Cases       CasesConfig       `mapstructure:",squash"`
 }
 
 type ServerConfig struct {
-	Port         int    `mapstructure:"PORT"`
-	GRPCPort     int    `mapstructure:"GRPC_PORT"`
-	Environment  string `mapstructure:"ENV"`
-	LogLevel     string `mapstructure:"LOG_LEVEL"`
+	Port           int    `mapstructure:"PORT"`
+	GRPCPort       int    `mapstructure:"GRPC_PORT"`
+	GRPCReflection bool   `mapstructure:"GRPC_REFLECTION"`
+	Environment    string `mapstructure:"ENV"`
+	InstanceID     string `mapstructure:"INSTANCE_ID"`
+	LogLevel       string `mapstructure:"LOG_LEVEL"`
+
+	LogPayloads        bool   `mapstructure:"LOG_PAYLOADS"`
+	LogPayloadMaxBytes int    `mapstructure:"LOG_PAYLOAD_MAX_BYTES"`
+	LogRedactFields    string `mapstructure:"LOG_REDACT_FIELDS"`
+
+	ReusePort       bool          `mapstructure:"SERVER_REUSE_PORT"`
+	DrainDelay      time.Duration `mapstructure:"SERVER_DRAIN_DELAY"`
+	ShutdownTimeout time.Duration `mapstructure:"SERVER_SHUTDOWN_TIMEOUT"`
+	DeadlineHintMin time.Duration `mapstructure:"SERVER_DEADLINE_HINT_MIN"`
+	DeadlineHintMax time.Duration `mapstructure:"SERVER_DEADLINE_HINT_MAX"`
+	CheckTimeout    time.Duration `mapstructure:"SERVER_CHECK_TIMEOUT"`
+	RequestTimeout  time.Duration `mapstructure:"SERVER_REQUEST_TIMEOUT"`
+
+	// CheckGetEnabled serves GET /api/v1/blacklist/check and its v2
+	// counterpart, which take the subject's PII in the URL
+	CheckGetEnabled bool `mapstructure:"SERVER_CHECK_GET_ENABLED"`
+
+	// APIV1Sunset is the date, as YYYY-MM-DD, after which /api/v1 may stop
+	// being served, announced in a Sunset header; empty announces none
+	APIV1Sunset string `mapstructure:"API_V1_SUNSET"`
+
+	// ComponentStopTimeout bounds the wait for each background component
+	// once the listeners have shut down
+	ComponentStopTimeout time.Duration `mapstructure:"SERVER_COMPONENT_STOP_TIMEOUT"`
+
+	TLSCertFile     string `mapstructure:"TLS_CERT_FILE"`
+	TLSKeyFile      string `mapstructure:"TLS_KEY_FILE"`
+	TLSClientCAFile string `mapstructure:"TLS_CLIENT_CA_FILE"`
+	// TLSMinVersion, TLSCipherSuites and TLSReloadInterval apply to both
+	// listeners; TLSCipherSuites are Go's names, empty for Go's defaults
+	TLSMinVersion     string        `mapstructure:"TLS_MIN_VERSION"`
+	TLSCipherSuites   string        `mapstructure:"TLS_CIPHER_SUITES"`
+	TLSReloadInterval time.Duration `mapstructure:"TLS_RELOAD_INTERVAL"`
+
+	// InternalPort serves the admin API, metrics and profiling apart from
+	// the check API; 0 serves everything on Port
+	InternalPort            int           `mapstructure:"INTERNAL_PORT"`
+	InternalRequestTimeout  time.Duration `mapstructure:"INTERNAL_REQUEST_TIMEOUT"`
+	InternalPprof           bool          `mapstructure:"INTERNAL_PPROF"`
+	InternalTLSCertFile     string        `mapstructure:"INTERNAL_TLS_CERT_FILE"`
+	InternalTLSKeyFile      string        `mapstructure:"INTERNAL_TLS_KEY_FILE"`
+	InternalTLSClientCAFile string        `mapstructure:"INTERNAL_TLS_CLIENT_CA_FILE"`
+	// InternalProfileDir is where profiles captured on demand are written;
+	// empty turns capturing off
+	InternalProfileDir string `mapstructure:"INTERNAL_PROFILE_DIR"`
+	// InternalAuthPolicy is the AUTH_POLICY of the internal listener; empty
+	// applies AUTH_POLICY there too
+	InternalAuthPolicy string `mapstructure:"INTERNAL_AUTH_POLICY"`
 }
 
 type DatabaseConfig struct {
+	// Driver is postgres, mysql or sqlite; for sqlite DBName is the database file
+	Driver   string `mapstructure:"DB_DRIVER"`
 	Host     string `mapstructure:"DB_HOST"`
 	Port     int    `mapstructure:"DB_PORT"`
 	User     string `mapstructure:"DB_USER"`
 	Password string `mapstructure:"DB_PASSWORD"`
 	DBName   string `mapstructure:"DB_NAME"`
 	SSLMode  string `mapstructure:"DB_SSL_MODE"`
+
+	QueryTimeout time.Duration `mapstructure:"DB_QUERY_TIMEOUT"`
+
+	MigrateOnStart bool `mapstructure:"DB_MIGRATE_ON_START"`
+
+	// ReplicaHosts are comma-separated Postgres read replicas, host or
+	// host:port, that serve check lookups; empty reads everything from the
+	// primary
+	ReplicaHosts string `mapstructure:"DB_REPLICA_HOSTS"`
+	// ReplicaMaxLag is how far a replica may trail the primary and still serve reads
+	ReplicaMaxLag time.Duration `mapstructure:"DB_REPLICA_MAX_LAG"`
+	// ReplicaCheckInterval is how often the replicas' lag is checked
+	ReplicaCheckInterval time.Duration `mapstructure:"DB_REPLICA_CHECK_INTERVAL"`
+
+	// RetryAttempts is how many times a blacklist store call is tried when it
+	// fails with a transient error; 1 doesn't retry
+	RetryAttempts int `mapstructure:"DB_RETRY_ATTEMPTS"`
+	// RetryBackoff is the most the first retry waits, doubling for each after it
+	RetryBackoff time.Duration `mapstructure:"DB_RETRY_BACKOFF"`
+	// BreakerFailures is how many attempts in a row failing to reach the
+	// database open the circuit breaker; 0 turns it off
+	BreakerFailures int `mapstructure:"DB_BREAKER_FAILURES"`
+	// BreakerCooldown is how long an open breaker fails calls before letting
+	// one through to probe the database
+	BreakerCooldown time.Duration `mapstructure:"DB_BREAKER_COOLDOWN"`
 }
 
 type RedisConfig struct {
+	// Mode is standalone, sentinel or cluster
+	Mode     string `mapstructure:"REDIS_MODE"`
 	Host     string `mapstructure:"REDIS_HOST"`
 	Port     int    `mapstructure:"REDIS_PORT"`
 	Password string `mapstructure:"REDIS_PASSWORD"`
 	DB       int    `mapstructure:"REDIS_DB"`
+
+	// Addrs are the comma-separated host:port of the sentinels, or of
+	// cluster nodes to discover the cluster from
+	Addrs            string `mapstructure:"REDIS_ADDRS"`
+	SentinelMaster   string `mapstructure:"REDIS_SENTINEL_MASTER"`
+	SentinelPassword string `mapstructure:"REDIS_SENTINEL_PASSWORD"`
+
+	TLSEnabled bool   `mapstructure:"REDIS_TLS_ENABLED"`
+	TLSCAFile  string `mapstructure:"REDIS_TLS_CA_FILE"`
+
+	Timeout time.Duration `mapstructure:"REDIS_TIMEOUT"`
+}
+
+type CacheConfig struct {
+	PositiveTTL time.Duration `mapstructure:"CACHE_POSITIVE_TTL"`
+	NegativeTTL time.Duration `mapstructure:"CACHE_NEGATIVE_TTL"`
+
+	LocalNIKEnabled bool          `mapstructure:"CACHE_LOCAL_NIK_ENABLED"`
+	LocalNIKTTL     time.Duration `mapstructure:"CACHE_LOCAL_NIK_TTL"`
+	LocalNIKSize    int           `mapstructure:"CACHE_LOCAL_NIK_SIZE"`
+
+	NIKFilterEnabled bool          `mapstructure:"CACHE_NIK_FILTER_ENABLED"`
+	NIKFilterFPRate  float64       `mapstructure:"CACHE_NIK_FILTER_FP_RATE"`
+	NIKFilterRefresh time.Duration `mapstructure:"CACHE_NIK_FILTER_REFRESH"`
+
+	InsightsEnabled     bool          `mapstructure:"CACHE_INSIGHTS_ENABLED"`
+	InsightsWindow      time.Duration `mapstructure:"CACHE_INSIGHTS_WINDOW"`
+	InsightsMaxSubjects int           `mapstructure:"CACHE_INSIGHTS_MAX_SUBJECTS"`
+	InsightsHotLookups  int           `mapstructure:"CACHE_INSIGHTS_HOT_LOOKUPS"`
+	InsightsWarmLookups int           `mapstructure:"CACHE_INSIGHTS_WARM_LOOKUPS"`
+
+	WarmupEnabled bool          `mapstructure:"CACHE_WARMUP_ENABLED"`
+	WarmupNIKs    int           `mapstructure:"CACHE_WARMUP_NIKS"`
+	WarmupTimeout time.Duration `mapstructure:"CACHE_WARMUP_TIMEOUT"`
+}
+
+type MatchConfig struct {
+	MinSimilarity  float64 `mapstructure:"MATCH_MIN_SIMILARITY"`
+	Rules          string  `mapstructure:"MATCH_RULES"`
+	Lists          string  `mapstructure:"MATCH_DEFAULT_LISTS"`
+	Profile        string  `mapstructure:"MATCH_PROFILE"`
+	CallerProfiles string  `mapstructure:"MATCH_CALLER_PROFILES"`
+
+	NameOnlySimilarity       float64 `mapstructure:"MATCH_NAME_ONLY_SIMILARITY"`
+	MissingBirthDatePenalty  float64 `mapstructure:"MATCH_MISSING_BIRTH_DATE_PENALTY"`
+	MissingBirthPlacePenalty float64 `mapstructure:"MATCH_MISSING_BIRTH_PLACE_PENALTY"`
+	BirthDateToleranceDays   int     `mapstructure:"MATCH_BIRTH_DATE_TOLERANCE_DAYS"`
+	CandidateBudget          int     `mapstructure:"MATCH_CANDIDATE_BUDGET"`
+	RerankCandidates         int     `mapstructure:"MATCH_RERANK_CANDIDATES"`
+	RerankTrigramWeight      float64 `mapstructure:"MATCH_RERANK_TRIGRAM_WEIGHT"`
+	RerankJaroWinklerWeight  float64 `mapstructure:"MATCH_RERANK_JARO_WINKLER_WEIGHT"`
+}
+
+type SyncConfig struct {
+	QuarantineThreshold  float64       `mapstructure:"SYNC_QUARANTINE_THRESHOLD"`
+	QualityMinScore      float64       `mapstructure:"SYNC_QUALITY_MIN_SCORE"`
+	DownloadDir          string        `mapstructure:"SYNC_DOWNLOAD_DIR"`
+	DownloadRetries      int           `mapstructure:"SYNC_DOWNLOAD_RETRIES"`
+	DownloadMinFreeBytes uint64        `mapstructure:"SYNC_DOWNLOAD_MIN_FREE_BYTES"`
+	RateLimit            float64       `mapstructure:"SYNC_RATE_LIMIT"`
+	RateBurst            int           `mapstructure:"SYNC_RATE_BURST"`
+	RateLimits           string        `mapstructure:"SYNC_RATE_LIMITS"`
+	MaxRetryAfter        time.Duration `mapstructure:"SYNC_MAX_RETRY_AFTER"`
+	Sources              string        `mapstructure:"SYNC_SOURCES"`
+	SourceInterval       time.Duration `mapstructure:"SYNC_SOURCE_INTERVAL"`
+	SourceRetry          time.Duration `mapstructure:"SYNC_SOURCE_RETRY"`
+	MaxAge               time.Duration `mapstructure:"SYNC_MAX_AGE"`
+	MaxAges              string        `mapstructure:"SYNC_MAX_AGES"`
+	OFACURL              string        `mapstructure:"SYNC_OFAC_URL"`
+	UNURL                string        `mapstructure:"SYNC_UN_URL"`
+	EUURL                string        `mapstructure:"SYNC_EU_URL"`
+}
+
+type AuthConfig struct {
+	Policy              string        `mapstructure:"AUTH_POLICY"`
+	APIKeys             string        `mapstructure:"AUTH_API_KEYS"`
+	CertRefreshInterval time.Duration `mapstructure:"AUTH_CERT_REFRESH_INTERVAL"`
+	HMACKeys            string        `mapstructure:"AUTH_HMAC_KEYS"`
+	HMACMaxBodyBytes    int64         `mapstructure:"AUTH_HMAC_MAX_BODY_BYTES"`
+	JWTJWKSURL          string        `mapstructure:"AUTH_JWT_JWKS_URL"`
+	JWTIssuer           string        `mapstructure:"AUTH_JWT_ISSUER"`
+	JWTAudience         string        `mapstructure:"AUTH_JWT_AUDIENCE"`
+	JWTSubjectClaim     string        `mapstructure:"AUTH_JWT_SUBJECT_CLAIM"`
+	JWTRolesClaim       string        `mapstructure:"AUTH_JWT_ROLES_CLAIM"`
+	JWTTenantClaim      string        `mapstructure:"AUTH_JWT_TENANT_CLAIM"`
+	JWTJWKSRefresh      time.Duration `mapstructure:"AUTH_JWT_JWKS_REFRESH"`
+}
+
+type ClockConfig struct {
+	SkewTolerance  time.Duration `mapstructure:"CLOCK_SKEW_TOLERANCE"`
+	DriftThreshold time.Duration `mapstructure:"CLOCK_DRIFT_THRESHOLD"`
+	DriftInterval  time.Duration `mapstructure:"CLOCK_DRIFT_INTERVAL"`
+}
+
+type ScreeningConfig struct {
+	Workers      int           `mapstructure:"SCREENING_WORKERS"`
+	BatchSize    int           `mapstructure:"SCREENING_BATCH_SIZE"`
+	PollInterval time.Duration `mapstructure:"SCREENING_POLL_INTERVAL"`
+	Lease        time.Duration `mapstructure:"SCREENING_LEASE"`
+	MaxAttempts  int           `mapstructure:"SCREENING_MAX_ATTEMPTS"`
+	MaxSubjects  int           `mapstructure:"SCREENING_MAX_SUBJECTS"`
+}
+
+type HistoryConfig struct {
+	Enabled   bool          `mapstructure:"CHECK_HISTORY_ENABLED"`
+	Retention time.Duration `mapstructure:"CHECK_HISTORY_RETENTION"`
+}
+
+type UsageConfig struct {
+	CostRates string `mapstructure:"USAGE_COST_RATES"`
+}
+
+type BreakGlassConfig struct {
+	Roles        string        `mapstructure:"BREAKGLASS_ROLES"`
+	IssuerRoles  string        `mapstructure:"BREAKGLASS_ISSUER_ROLES"`
+	DefaultTTL   time.Duration `mapstructure:"BREAKGLASS_DEFAULT_TTL"`
+	MaxTTL       time.Duration `mapstructure:"BREAKGLASS_MAX_TTL"`
+	AlertWebhook string        `mapstructure:"BREAKGLASS_ALERT_WEBHOOK"`
+}
+
+type NIKConfig struct {
+	BirthDateCheck string `mapstructure:"NIK_BIRTH_DATE_CHECK"`
+	// EncryptionKey and HMACKey are base64-encoded 32-byte keys; stored NIKs
+	// are encrypted and keyed for lookups when they are set
+	EncryptionKey string `mapstructure:"NIK_ENCRYPTION_KEY"`
+	HMACKey       string `mapstructure:"NIK_HMAC_KEY"`
+	// PreviousEncryptionKeys and PreviousHMACKeys are comma-separated keys
+	// being rotated out: NIKs protected under them are still decrypted and
+	// matched until the backfill has rewritten them under the current keys
+	PreviousEncryptionKeys string `mapstructure:"NIK_PREVIOUS_ENCRYPTION_KEYS"`
+	PreviousHMACKeys       string `mapstructure:"NIK_PREVIOUS_HMAC_KEYS"`
+}
+
+type GazetteerConfig struct {
+	Enabled bool   `mapstructure:"GAZETTEER_ENABLED"`
+	File    string `mapstructure:"GAZETTEER_FILE"`
+}
+
+type TokenizeConfig struct {
+	Provider string        `mapstructure:"TOKENIZE_PROVIDER"`
+	URL      string        `mapstructure:"TOKENIZE_URL"`
+	APIKey   string        `mapstructure:"TOKENIZE_API_KEY"`
+	Timeout  time.Duration `mapstructure:"TOKENIZE_TIMEOUT"`
+}
+
+type WhitelistConfig struct {
+	DefaultTTL time.Duration `mapstructure:"WHITELIST_DEFAULT_TTL"`
+	MaxTTL     time.Duration `mapstructure:"WHITELIST_MAX_TTL"`
+}
+
+type ScoreConfig struct {
+	Weights         string  `mapstructure:"SCORE_WEIGHTS"`
+	ReviewThreshold float64 `mapstructure:"SCORE_REVIEW_THRESHOLD"`
+	HitThreshold    float64 `mapstructure:"SCORE_HIT_THRESHOLD"`
+}
+
+type WatchdogConfig struct {
+	Interval             time.Duration `mapstructure:"WATCHDOG_INTERVAL"`
+	HeartbeatTimeout     time.Duration `mapstructure:"WATCHDOG_HEARTBEAT_TIMEOUT"`
+	ScreeningMaxDuration time.Duration `mapstructure:"WATCHDOG_SCREENING_MAX_DURATION"`
+	SyncMaxDuration      time.Duration `mapstructure:"WATCHDOG_SYNC_MAX_DURATION"`
+	Reclaim              bool          `mapstructure:"WATCHDOG_RECLAIM"`
+	AlertWebhook         string        `mapstructure:"WATCHDOG_ALERT_WEBHOOK"`
+}
+
+type TemporaryConfig struct {
+	DefaultDays   int           `mapstructure:"TEMPORARY_DEFAULT_DAYS"`
+	MaxDays       int           `mapstructure:"TEMPORARY_MAX_DAYS"`
+	NotifyBefore  time.Duration `mapstructure:"TEMPORARY_NOTIFY_BEFORE"`
+	SweepInterval time.Duration `mapstructure:"TEMPORARY_SWEEP_INTERVAL"`
+	AlertWebhook  string        `mapstructure:"TEMPORARY_ALERT_WEBHOOK"`
+}
+
+type SandboxConfig struct {
+	Enabled bool   `mapstructure:"SANDBOX_ENABLED"`
+	Tenant  string `mapstructure:"SANDBOX_TENANT"`
 }
 
+type TenantConfig struct {
+	// Isolation scopes records, whitelist entries and screening jobs to the
+	// caller's tenant
+	Isolation   bool   `mapstructure:"TENANT_ISOLATION"`
+	SharedLists string `mapstructure:"TENANT_SHARED_LISTS"`
+	// MatchThresholds and RateLimits are per-tenant overrides, as
+	// comma-separated tenant=value pairs
+	MatchThresholds string `mapstructure:"TENANT_MATCH_THRESHOLDS"`
+	RateLimits      string `mapstructure:"TENANT_RATE_LIMITS"`
+}
+
+type ApprovalConfig struct {
+	// Enabled holds record changes made through the admin API for a second
+	// user holding Role to approve
+	Enabled bool   `mapstructure:"APPROVAL_ENABLED"`
+	Role    string `mapstructure:"APPROVAL_ROLE"`
+}
+
+type RescreenConfig struct {
+	Enabled       bool          `mapstructure:"RESCREEN_ENABLED"`
+	Interval      time.Duration `mapstructure:"RESCREEN_INTERVAL"`
+	SweepInterval time.Duration `mapstructure:"RESCREEN_SWEEP_INTERVAL"`
+	BatchSize     int           `mapstructure:"RESCREEN_BATCH_SIZE"`
+	AlertWebhook  string        `mapstructure:"RESCREEN_ALERT_WEBHOOK"`
+}
+
+type DedupConfig struct {
+	Enabled  bool          `mapstructure:"DEDUP_ENABLED"`
+	Interval time.Duration `mapstructure:"DEDUP_INTERVAL"`
+	// NameSimilarity is the least trigram similarity of the names of two
+	// records born the same day for them to be taken for duplicates
+	NameSimilarity float64 `mapstructure:"DEDUP_NAME_SIMILARITY"`
+}
+
+type CasesConfig struct {
+	// Enabled opens a review case for each production check that
+	// blacklists the subject
+	Enabled bool `mapstructure:"CASES_ENABLED"`
+}
+
+type IdempotencyConfig struct {
+	Enabled      bool          `mapstructure:"IDEMPOTENCY_ENABLED"`
+	TTL          time.Duration `mapstructure:"IDEMPOTENCY_TTL"`
+	MaxBodyBytes int64         `mapstructure:"IDEMPOTENCY_MAX_BODY_BYTES"`
+}
+
+type ResponseConfig struct {
+	CallerFormats string `mapstructure:"RESPONSE_CALLER_FORMATS"`
+}
+
+type EventsConfig struct {
+	KafkaBrokers  string        `mapstructure:"EVENTS_KAFKA_BROKERS"`
+	KafkaTopic    string        `mapstructure:"EVENTS_KAFKA_TOPIC"`
+	BatchSize     int           `mapstructure:"EVENTS_BATCH_SIZE"`
+	BatchTimeout  time.Duration `mapstructure:"EVENTS_BATCH_TIMEOUT"`
+	RescreenTopic string        `mapstructure:"EVENTS_RESCREEN_TOPIC"`
+}
+
+// EnvPrefix prefixes the environment variables of the settings. BLC_DB_HOST
+// sets DB_HOST, taking precedence over an unprefixed DB_HOST, so the service
+// can share an environment with other programs without their variables
+// colliding.
+const EnvPrefix = "BLC_"
+
+// fileTypes are the settings file formats read by extension; files with any
+// other extension are read as env files
+var fileTypes = []string{"yaml", "yml", "json", "toml"}
+
+// flags holds the settings given on the command line
+var flags = struct {
+	file string
+	set  settingFlags
+}{set: settingFlags{}}
+
+// settingFlags collects repeated -set KEY=VALUE flags
+type settingFlags map[string]string
+
+func (s settingFlags) String() string {
+	pairs := make([]string, 0, len(s))
+	for key, value := range s {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (s settingFlags) Set(value string) error {
+	key, value, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("want KEY=VALUE, got %q", value)
+	}
+	s[settingKey(key)] = value
+	return nil
+}
+
+// RegisterFlags registers -config, naming the settings file in place of
+// CONFIG_FILE, and -set KEY=VALUE, which sets a setting over every other
+// source, on fs. Commands call it before parsing their flags; Load, and with
+// it every reload, then applies them.
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flags.file, "config", "", "settings file, in place of CONFIG_FILE")
+	fs.Var(flags.set, "set", "set a setting, as KEY=VALUE, over the environment and settings file (repeatable)")
+}
+
+// settingKey returns the key of a setting named in a file, flag or
+// environment variable: case-insensitive, without EnvPrefix, and with the
+// sections of nested file keys joined by underscores, so db.host is DB_HOST
+func settingKey(name string) string {
+	key := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), ".", "_"))
+	return strings.TrimPrefix(key, strings.ToLower(EnvPrefix))
+}
+
+// lookupEnv reads the environment variable of a setting, preferring its
+// EnvPrefix name and, unless prefixedOnly, falling back to the plain one
+func lookupEnv(name string, prefixedOnly bool) string {
+	if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+		return value
+	}
+	if prefixedOnly {
+		return ""
+	}
+	return os.Getenv(name)
+}
+
+// Load reads the settings. Each one is taken from the first of these to set
+// it: a -set flag, the environment, the settings file, and its default.
 func Load() (*Config, error) {
-	viper.SetConfigName(".env")
-	viper.SetConfigType("env")
-	viper.AutomaticEnv()
+	v := viper.New()
+	prefixedOnly, _ := strconv.ParseBool(lookupEnv("CONFIG_ENV_PREFIX_ONLY", false))
 
 	// Set defaults
-	viper.SetDefault("PORT", 8080)
-	viper.SetDefault("GRPC_PORT", 9090)
-	viper.SetDefault("ENV", "development")
-	viper.SetDefault("LOG_LEVEL", "info")
-	viper.SetDefault("DB_PORT", 5432)
-	viper.SetDefault("DB_SSL_MODE", "disable")
-	viper.SetDefault("REDIS_PORT", 6379)
-	viper.SetDefault("REDIS_DB", 0)
-
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, fmt.Errorf("error reading config file: %w", err)
+	v.SetDefault("PORT", 8080)
+	v.SetDefault("GRPC_PORT", 9090)
+	v.SetDefault("GRPC_REFLECTION", false)
+	v.SetDefault("ENV", "development")
+	v.SetDefault("INSTANCE_ID", "")
+	v.SetDefault("LOG_LEVEL", "info")
+	v.SetDefault("LOG_PAYLOADS", false)
+	v.SetDefault("LOG_PAYLOAD_MAX_BYTES", 16384)
+	v.SetDefault("LOG_REDACT_FIELDS", "nik=hash,name=mask,birth_place=mask,birth_date=mask,sex=mask,registration_number=hash,token=drop")
+	v.SetDefault("SERVER_REUSE_PORT", false)
+	v.SetDefault("SERVER_DRAIN_DELAY", 5*time.Second)
+	v.SetDefault("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second)
+	v.SetDefault("SERVER_COMPONENT_STOP_TIMEOUT", 10*time.Second)
+	v.SetDefault("SERVER_DEADLINE_HINT_MIN", 50*time.Millisecond)
+	v.SetDefault("SERVER_DEADLINE_HINT_MAX", 30*time.Second)
+	v.SetDefault("SERVER_CHECK_TIMEOUT", 30*time.Second)
+	v.SetDefault("SERVER_CHECK_GET_ENABLED", true)
+	v.SetDefault("API_V1_SUNSET", "")
+	v.SetDefault("SERVER_REQUEST_TIMEOUT", 60*time.Second)
+	v.SetDefault("TLS_CERT_FILE", "")
+	v.SetDefault("TLS_KEY_FILE", "")
+	v.SetDefault("TLS_CLIENT_CA_FILE", "")
+	v.SetDefault("TLS_MIN_VERSION", "1.2")
+	v.SetDefault("TLS_CIPHER_SUITES", "")
+	v.SetDefault("TLS_RELOAD_INTERVAL", time.Minute)
+	v.SetDefault("INTERNAL_PORT", 0)
+	v.SetDefault("INTERNAL_REQUEST_TIMEOUT", 60*time.Second)
+	v.SetDefault("INTERNAL_PPROF", true)
+	v.SetDefault("INTERNAL_TLS_CERT_FILE", "")
+	v.SetDefault("INTERNAL_TLS_KEY_FILE", "")
+	v.SetDefault("INTERNAL_TLS_CLIENT_CA_FILE", "")
+	v.SetDefault("INTERNAL_AUTH_POLICY", "")
+	v.SetDefault("INTERNAL_PROFILE_DIR", "")
+	v.SetDefault("DB_DRIVER", "postgres")
+	v.SetDefault("DB_HOST", "")
+	v.SetDefault("DB_PORT", 5432)
+	v.SetDefault("DB_USER", "")
+	v.SetDefault("DB_PASSWORD", "")
+	v.SetDefault("DB_NAME", "")
+	v.SetDefault("DB_SSL_MODE", "disable")
+	v.SetDefault("DB_MIGRATE_ON_START", false)
+	v.SetDefault("DB_QUERY_TIMEOUT", 5*time.Second)
+	v.SetDefault("DB_REPLICA_HOSTS", "")
+	v.SetDefault("DB_REPLICA_MAX_LAG", 5*time.Second)
+	v.SetDefault("DB_REPLICA_CHECK_INTERVAL", 5*time.Second)
+	v.SetDefault("DB_RETRY_ATTEMPTS", 3)
+	v.SetDefault("DB_RETRY_BACKOFF", 50*time.Millisecond)
+	v.SetDefault("DB_BREAKER_FAILURES", 5)
+	v.SetDefault("DB_BREAKER_COOLDOWN", 10*time.Second)
+	v.SetDefault("REDIS_MODE", "standalone")
+	v.SetDefault("REDIS_HOST", "")
+	v.SetDefault("REDIS_PORT", 6379)
+	v.SetDefault("REDIS_PASSWORD", "")
+	v.SetDefault("REDIS_DB", 0)
+	v.SetDefault("REDIS_ADDRS", "")
+	v.SetDefault("REDIS_SENTINEL_MASTER", "")
+	v.SetDefault("REDIS_SENTINEL_PASSWORD", "")
+	v.SetDefault("REDIS_TLS_ENABLED", false)
+	v.SetDefault("REDIS_TLS_CA_FILE", "")
+	v.SetDefault("REDIS_TIMEOUT", time.Second)
+	v.SetDefault("CACHE_POSITIVE_TTL", 24*time.Hour)
+	v.SetDefault("CACHE_NEGATIVE_TTL", 5*time.Minute)
+	v.SetDefault("CACHE_LOCAL_NIK_ENABLED", false)
+	v.SetDefault("CACHE_LOCAL_NIK_TTL", time.Minute)
+	v.SetDefault("CACHE_LOCAL_NIK_SIZE", 100000)
+	v.SetDefault("CACHE_NIK_FILTER_ENABLED", false)
+	v.SetDefault("CACHE_NIK_FILTER_FP_RATE", 0.01)
+	v.SetDefault("CACHE_NIK_FILTER_REFRESH", 10*time.Minute)
+	v.SetDefault("CACHE_INSIGHTS_ENABLED", true)
+	v.SetDefault("CACHE_INSIGHTS_WINDOW", time.Hour)
+	v.SetDefault("CACHE_INSIGHTS_MAX_SUBJECTS", 100000)
+	v.SetDefault("CACHE_INSIGHTS_HOT_LOOKUPS", 10)
+	v.SetDefault("CACHE_INSIGHTS_WARM_LOOKUPS", 2)
+	v.SetDefault("CACHE_WARMUP_ENABLED", false)
+	v.SetDefault("CACHE_WARMUP_NIKS", 10000)
+	v.SetDefault("CACHE_WARMUP_TIMEOUT", 30*time.Second)
+	v.SetDefault("AUTH_POLICY", "")
+	v.SetDefault("AUTH_API_KEYS", "")
+	v.SetDefault("AUTH_HMAC_KEYS", "")
+	v.SetDefault("AUTH_HMAC_MAX_BODY_BYTES", 64<<20)
+	v.SetDefault("AUTH_CERT_REFRESH_INTERVAL", time.Minute)
+	v.SetDefault("AUTH_JWT_JWKS_URL", "")
+	v.SetDefault("AUTH_JWT_ISSUER", "")
+	v.SetDefault("AUTH_JWT_AUDIENCE", "")
+	v.SetDefault("AUTH_JWT_SUBJECT_CLAIM", "sub")
+	v.SetDefault("AUTH_JWT_ROLES_CLAIM", "roles")
+	v.SetDefault("AUTH_JWT_TENANT_CLAIM", "")
+	v.SetDefault("AUTH_JWT_JWKS_REFRESH", 15*time.Minute)
+	v.SetDefault("MATCH_MIN_SIMILARITY", 0.3)
+	v.SetDefault("MATCH_RULES", "exact_nik,fuzzy_full_match,fuzzy_date_match,fuzzy_place_match,fuzzy_name_match,phonetic_match")
+	v.SetDefault("MATCH_DEFAULT_LISTS", "internal,sanctions,pep")
+	v.SetDefault("MATCH_PROFILE", "default")
+	v.SetDefault("MATCH_CALLER_PROFILES", "")
+	v.SetDefault("MATCH_NAME_ONLY_SIMILARITY", 0.8)
+	v.SetDefault("MATCH_MISSING_BIRTH_DATE_PENALTY", 0.3)
+	v.SetDefault("MATCH_MISSING_BIRTH_PLACE_PENALTY", 0.1)
+	v.SetDefault("MATCH_BIRTH_DATE_TOLERANCE_DAYS", 0)
+	v.SetDefault("MATCH_CANDIDATE_BUDGET", 1000)
+	v.SetDefault("MATCH_RERANK_CANDIDATES", 0)
+	v.SetDefault("MATCH_RERANK_TRIGRAM_WEIGHT", 0.5)
+	v.SetDefault("MATCH_RERANK_JARO_WINKLER_WEIGHT", 0.5)
+	v.SetDefault("SYNC_QUARANTINE_THRESHOLD", 0.2)
+	v.SetDefault("SYNC_QUALITY_MIN_SCORE", 0.5)
+	v.SetDefault("SYNC_DOWNLOAD_DIR", "/tmp/blacklist-sync")
+	v.SetDefault("SYNC_DOWNLOAD_RETRIES", 5)
+	v.SetDefault("SYNC_DOWNLOAD_MIN_FREE_BYTES", 512*1024*1024)
+	v.SetDefault("SYNC_RATE_LIMIT", 1.0)
+	v.SetDefault("SYNC_RATE_BURST", 5)
+	v.SetDefault("SYNC_RATE_LIMITS", "")
+	v.SetDefault("SYNC_MAX_RETRY_AFTER", 10*time.Minute)
+	v.SetDefault("SYNC_SOURCES", "")
+	v.SetDefault("SYNC_SOURCE_INTERVAL", 24*time.Hour)
+	v.SetDefault("SYNC_SOURCE_RETRY", time.Hour)
+	v.SetDefault("SYNC_MAX_AGE", 48*time.Hour)
+	v.SetDefault("SYNC_MAX_AGES", "")
+	v.SetDefault("SYNC_OFAC_URL", "")
+	v.SetDefault("SYNC_UN_URL", "")
+	v.SetDefault("SYNC_EU_URL", "")
+	v.SetDefault("SCREENING_WORKERS", 4)
+	v.SetDefault("SCREENING_BATCH_SIZE", 500)
+	v.SetDefault("SCREENING_POLL_INTERVAL", 5*time.Second)
+	v.SetDefault("SCREENING_LEASE", 5*time.Minute)
+	v.SetDefault("SCREENING_MAX_ATTEMPTS", 3)
+	v.SetDefault("SCREENING_MAX_SUBJECTS", 1000000)
+	v.SetDefault("CHECK_HISTORY_ENABLED", true)
+	v.SetDefault("CHECK_HISTORY_RETENTION", 30*24*time.Hour)
+	v.SetDefault("CLOCK_SKEW_TOLERANCE", 5*time.Minute)
+	v.SetDefault("CLOCK_DRIFT_THRESHOLD", 2*time.Second)
+	v.SetDefault("CLOCK_DRIFT_INTERVAL", 5*time.Minute)
+	v.SetDefault("USAGE_COST_RATES", "")
+	v.SetDefault("BREAKGLASS_ROLES", "admin")
+	v.SetDefault("BREAKGLASS_ISSUER_ROLES", "breakglass")
+	v.SetDefault("BREAKGLASS_DEFAULT_TTL", 15*time.Minute)
+	v.SetDefault("BREAKGLASS_MAX_TTL", time.Hour)
+	v.SetDefault("BREAKGLASS_ALERT_WEBHOOK", "")
+	v.SetDefault("NIK_BIRTH_DATE_CHECK", "flag")
+	v.SetDefault("NIK_ENCRYPTION_KEY", "")
+	v.SetDefault("NIK_HMAC_KEY", "")
+	v.SetDefault("NIK_PREVIOUS_ENCRYPTION_KEYS", "")
+	v.SetDefault("NIK_PREVIOUS_HMAC_KEYS", "")
+	v.SetDefault("GAZETTEER_ENABLED", true)
+	v.SetDefault("GAZETTEER_FILE", "")
+	v.SetDefault("TOKENIZE_PROVIDER", "none")
+	v.SetDefault("TOKENIZE_URL", "")
+	v.SetDefault("TOKENIZE_API_KEY", "")
+	v.SetDefault("TOKENIZE_TIMEOUT", 2*time.Second)
+	v.SetDefault("WHITELIST_DEFAULT_TTL", 90*24*time.Hour)
+	v.SetDefault("WHITELIST_MAX_TTL", 365*24*time.Hour)
+	v.SetDefault("SCORE_WEIGHTS", "")
+	v.SetDefault("SCORE_REVIEW_THRESHOLD", 0.4)
+	v.SetDefault("SCORE_HIT_THRESHOLD", 0.7)
+	v.SetDefault("WATCHDOG_INTERVAL", time.Minute)
+	v.SetDefault("WATCHDOG_HEARTBEAT_TIMEOUT", 15*time.Minute)
+	v.SetDefault("WATCHDOG_SCREENING_MAX_DURATION", 6*time.Hour)
+	v.SetDefault("WATCHDOG_SYNC_MAX_DURATION", time.Hour)
+	v.SetDefault("WATCHDOG_RECLAIM", true)
+	v.SetDefault("WATCHDOG_ALERT_WEBHOOK", "")
+	v.SetDefault("TEMPORARY_DEFAULT_DAYS", 14)
+	v.SetDefault("TEMPORARY_MAX_DAYS", 90)
+	v.SetDefault("TEMPORARY_NOTIFY_BEFORE", 72*time.Hour)
+	v.SetDefault("TEMPORARY_SWEEP_INTERVAL", 5*time.Minute)
+	v.SetDefault("TEMPORARY_ALERT_WEBHOOK", "")
+	v.SetDefault("SANDBOX_ENABLED", false)
+	v.SetDefault("SANDBOX_TENANT", "sandbox")
+	v.SetDefault("TENANT_ISOLATION", false)
+	v.SetDefault("TENANT_SHARED_LISTS", "sanctions,pep")
+	v.SetDefault("TENANT_MATCH_THRESHOLDS", "")
+	v.SetDefault("TENANT_RATE_LIMITS", "")
+	v.SetDefault("APPROVAL_ENABLED", false)
+	v.SetDefault("APPROVAL_ROLE", "approver")
+	v.SetDefault("RESCREEN_ENABLED", false)
+	v.SetDefault("RESCREEN_INTERVAL", 24*time.Hour)
+	v.SetDefault("RESCREEN_SWEEP_INTERVAL", 5*time.Minute)
+	v.SetDefault("RESCREEN_BATCH_SIZE", 500)
+	v.SetDefault("RESCREEN_ALERT_WEBHOOK", "")
+	v.SetDefault("DEDUP_ENABLED", false)
+	v.SetDefault("DEDUP_INTERVAL", time.Hour)
+	v.SetDefault("DEDUP_NAME_SIMILARITY", 0.85)
+	v.SetDefault("CASES_ENABLED", false)
+	v.SetDefault("IDEMPOTENCY_ENABLED", true)
+	v.SetDefault("IDEMPOTENCY_TTL", "24h")
+	v.SetDefault("IDEMPOTENCY_MAX_BODY_BYTES", 64<<20)
+	v.SetDefault("EVENTS_KAFKA_BROKERS", "")
+	v.SetDefault("EVENTS_KAFKA_TOPIC", "blacklist.screening-decisions")
+	v.SetDefault("EVENTS_BATCH_SIZE", 100)
+	v.SetDefault("EVENTS_BATCH_TIMEOUT", "1s")
+	v.SetDefault("EVENTS_RESCREEN_TOPIC", "blacklist.rescreen-alerts")
+	v.SetDefault("RESPONSE_CALLER_FORMATS", "")
+
+	// Settings are read from -config, CONFIG_FILE, or .env in the working
+	// directory
+	path := flags.file
+	if path == "" {
+		path = lookupEnv("CONFIG_FILE", prefixedOnly)
+	}
+	if err := readFile(v, path); err != nil {
+		return nil, err
+	}
+
+	// Every known setting, with a default or in the file, is bound to its
+	// variables in order of preference
+	known := make(map[string]bool)
+	for _, key := range v.AllKeys() {
+		known[key] = true
+		names := []string{key, EnvPrefix + strings.ToUpper(key)}
+		if !prefixedOnly {
+			names = append(names, strings.ToUpper(key))
+		}
+		if err := v.BindEnv(names...); err != nil {
+			return nil, fmt.Errorf("error binding %s: %w", strings.ToUpper(key), err)
 		}
 	}
 
+	for key, value := range flags.set {
+		if !known[key] {
+			return nil, fmt.Errorf("-set %s: unknown setting", strings.ToUpper(key))
+		}
+		v.Set(key, value)
+	}
+
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
-} 
\ No newline at end of file
+}
+
+// readFile merges the settings of the file at path, or of .env in the
+// working directory when path is empty, into v. A missing .env is no error.
+// YAML, JSON and TOML files are read by their extension and may nest their
+// keys; other files are env files.
+func readFile(v *viper.Viper, path string) error {
+	file := viper.New()
+	if path != "" {
+		file.SetConfigFile(path)
+		ext := strings.TrimPrefix(filepath.Ext(path), ".")
+		if !slices.Contains(fileTypes, strings.ToLower(ext)) {
+			file.SetConfigType("env")
+		}
+	} else {
+		file.SetConfigName(".env")
+		file.SetConfigType("env")
+		file.AddConfigPath(".")
+	}
+	if err := file.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return nil
+		}
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	settings := make(map[string]interface{})
+	for _, key := range file.AllKeys() {
+		settings[settingKey(key)] = file.Get(key)
+	}
+	return v.MergeConfigMap(settings)
+}
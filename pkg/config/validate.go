@@ -0,0 +1,331 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Environments are the accepted values of ENV
+var Environments = []string{"development", "test", "staging", "production"}
+
+// Drivers are the accepted values of DB_DRIVER
+var Drivers = []string{"postgres", "mysql", "sqlite"}
+
+// TLSVersions are the accepted values of TLS_MIN_VERSION
+var TLSVersions = []string{"1.2", "1.3"}
+
+// SSLModes are the accepted values of DB_SSL_MODE, as libpq defines them
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// RedisModes are the accepted values of REDIS_MODE
+var RedisModes = []string{"standalone", "sentinel", "cluster"}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the settings the service can't start without: required
+// connection settings, port ranges and enumerated values. It reports every
+// problem at once, so a misconfigured deploy fails before any connection is
+// attempted instead of on the first query.
+func (c *Config) Validate() error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// A SQLite database is a file named by DB_NAME, with no server to log in to
+	required := []struct {
+		key, value string
+		server     bool
+	}{
+		{"DB_HOST", c.Database.Host, true},
+		{"DB_USER", c.Database.User, true},
+		{"DB_NAME", c.Database.DBName, false},
+	}
+	for _, r := range required {
+		if r.server && c.Database.Driver == "sqlite" {
+			continue
+		}
+		if strings.TrimSpace(r.value) == "" {
+			fail("%s is required", r.key)
+		}
+	}
+
+	// Routes are never left open by omission, only by an explicit "none"
+	if strings.TrimSpace(c.Auth.Policy) == "" {
+		fail("AUTH_POLICY is required; /=none leaves every route open")
+	}
+
+	ports := []struct {
+		key  string
+		port int
+	}{
+		{"PORT", c.Server.Port},
+		{"GRPC_PORT", c.Server.GRPCPort},
+		{"DB_PORT", c.Database.Port},
+		{"REDIS_PORT", c.Redis.Port},
+	}
+	for _, p := range ports {
+		if p.port < 1 || p.port > 65535 {
+			fail("%s must be between 1 and 65535, got %d", p.key, p.port)
+		}
+	}
+	if c.Server.Port == c.Server.GRPCPort {
+		fail("PORT and GRPC_PORT must differ, both are %d", c.Server.Port)
+	}
+	if c.Server.InternalPort != 0 {
+		if c.Server.InternalPort < 1 || c.Server.InternalPort > 65535 {
+			fail("INTERNAL_PORT must be 0 or between 1 and 65535, got %d", c.Server.InternalPort)
+		}
+		if c.Server.InternalPort == c.Server.Port || c.Server.InternalPort == c.Server.GRPCPort {
+			fail("INTERNAL_PORT must differ from PORT and GRPC_PORT, got %d", c.Server.InternalPort)
+		}
+	} else {
+		if strings.TrimSpace(c.Server.InternalAuthPolicy) != "" {
+			fail("INTERNAL_AUTH_POLICY needs INTERNAL_PORT")
+		}
+		if c.Server.InternalProfileDir != "" {
+			fail("INTERNAL_PROFILE_DIR needs INTERNAL_PORT")
+		}
+	}
+	if c.Server.APIV1Sunset != "" {
+		if _, err := time.Parse("2006-01-02", c.Server.APIV1Sunset); err != nil {
+			fail("API_V1_SUNSET must be a date formatted as YYYY-MM-DD, got %q", c.Server.APIV1Sunset)
+		}
+	}
+	if c.Server.InternalProfileDir != "" && !c.Server.InternalPprof {
+		fail("INTERNAL_PROFILE_DIR needs INTERNAL_PPROF")
+	}
+	listeners := []struct {
+		prefix              string
+		cert, key, clientCA string
+	}{
+		{"", c.Server.TLSCertFile, c.Server.TLSKeyFile, c.Server.TLSClientCAFile},
+		{"INTERNAL_", c.Server.InternalTLSCertFile, c.Server.InternalTLSKeyFile, c.Server.InternalTLSClientCAFile},
+	}
+	for _, l := range listeners {
+		if (l.cert == "") != (l.key == "") {
+			fail("%sTLS_CERT_FILE and %sTLS_KEY_FILE must be set together", l.prefix, l.prefix)
+		}
+		if l.clientCA != "" && l.cert == "" {
+			fail("%sTLS_CLIENT_CA_FILE needs %sTLS_CERT_FILE", l.prefix, l.prefix)
+		}
+	}
+	if !oneOf(c.Server.TLSMinVersion, TLSVersions) {
+		fail("TLS_MIN_VERSION must be one of %s, got %q", strings.Join(TLSVersions, ", "), c.Server.TLSMinVersion)
+	}
+	if c.Server.TLSReloadInterval < 0 {
+		fail("TLS_RELOAD_INTERVAL must not be negative, got %s", c.Server.TLSReloadInterval)
+	}
+	if c.Redis.DB < 0 {
+		fail("REDIS_DB must not be negative, got %d", c.Redis.DB)
+	}
+	// Sentinels and clusters are found through REDIS_ADDRS rather than REDIS_HOST
+	switch c.Redis.Mode {
+	case "standalone":
+		if strings.TrimSpace(c.Redis.Host) == "" {
+			fail("REDIS_HOST is required")
+		}
+	case "sentinel":
+		if strings.TrimSpace(c.Redis.Addrs) == "" || c.Redis.SentinelMaster == "" {
+			fail("REDIS_ADDRS and REDIS_SENTINEL_MASTER are required when REDIS_MODE is sentinel")
+		}
+	case "cluster":
+		if strings.TrimSpace(c.Redis.Addrs) == "" {
+			fail("REDIS_ADDRS is required when REDIS_MODE is cluster")
+		}
+		// A cluster only has database 0
+		if c.Redis.DB != 0 {
+			fail("REDIS_DB must be 0 when REDIS_MODE is cluster, got %d", c.Redis.DB)
+		}
+	default:
+		fail("REDIS_MODE must be one of %s, got %q", strings.Join(RedisModes, ", "), c.Redis.Mode)
+	}
+	if c.Redis.TLSCAFile != "" && !c.Redis.TLSEnabled {
+		fail("REDIS_TLS_CA_FILE needs REDIS_TLS_ENABLED")
+	}
+
+	timeouts := []struct {
+		key     string
+		timeout time.Duration
+	}{
+		{"DB_QUERY_TIMEOUT", c.Database.QueryTimeout},
+		{"DB_REPLICA_MAX_LAG", c.Database.ReplicaMaxLag},
+		{"DB_REPLICA_CHECK_INTERVAL", c.Database.ReplicaCheckInterval},
+		{"DB_RETRY_BACKOFF", c.Database.RetryBackoff},
+		{"DB_BREAKER_COOLDOWN", c.Database.BreakerCooldown},
+		{"REDIS_TIMEOUT", c.Redis.Timeout},
+		{"SERVER_CHECK_TIMEOUT", c.Server.CheckTimeout},
+		{"SERVER_REQUEST_TIMEOUT", c.Server.RequestTimeout},
+		{"INTERNAL_REQUEST_TIMEOUT", c.Server.InternalRequestTimeout},
+		{"SERVER_COMPONENT_STOP_TIMEOUT", c.Server.ComponentStopTimeout},
+	}
+	for _, t := range timeouts {
+		if t.timeout <= 0 {
+			fail("%s must be positive, got %s", t.key, t.timeout)
+		}
+	}
+	// Background jobs run on a ticker, which a zero or negative interval
+	// would make panic
+	intervals := []struct {
+		key      string
+		interval time.Duration
+	}{
+		{"CLOCK_DRIFT_INTERVAL", c.Clock.DriftInterval},
+		{"WATCHDOG_INTERVAL", c.Watchdog.Interval},
+		{"TEMPORARY_SWEEP_INTERVAL", c.Temporary.SweepInterval},
+		{"AUTH_CERT_REFRESH_INTERVAL", c.Auth.CertRefreshInterval},
+		{"AUTH_JWT_JWKS_REFRESH", c.Auth.JWTJWKSRefresh},
+		{"SCREENING_POLL_INTERVAL", c.Screening.PollInterval},
+		{"CACHE_INSIGHTS_WINDOW", c.Cache.InsightsWindow},
+		{"CACHE_NIK_FILTER_REFRESH", c.Cache.NIKFilterRefresh},
+	}
+	for _, i := range intervals {
+		if i.interval <= 0 {
+			fail("%s must be positive, got %s", i.key, i.interval)
+		}
+	}
+
+	if !oneOf(c.Server.Environment, Environments) {
+		fail("ENV must be one of %s, got %q", strings.Join(Environments, ", "), c.Server.Environment)
+	}
+	if _, err := zapcore.ParseLevel(c.Server.LogLevel); err != nil {
+		fail("LOG_LEVEL must be one of debug, info, warn, error, dpanic, panic, fatal, got %q", c.Server.LogLevel)
+	}
+	if !oneOf(c.Database.Driver, Drivers) {
+		fail("DB_DRIVER must be one of %s, got %q", strings.Join(Drivers, ", "), c.Database.Driver)
+	}
+	if c.Tenant.Isolation && c.Database.Driver != "postgres" {
+		fail("TENANT_ISOLATION needs DB_DRIVER=postgres, got %q", c.Database.Driver)
+	}
+	if !oneOf(c.Database.SSLMode, SSLModes) {
+		fail("DB_SSL_MODE must be one of %s, got %q", strings.Join(SSLModes, ", "), c.Database.SSLMode)
+	}
+	if strings.TrimSpace(c.Database.ReplicaHosts) != "" && c.Database.Driver != "postgres" {
+		fail("DB_REPLICA_HOSTS needs DB_DRIVER=postgres, got %q", c.Database.Driver)
+	}
+	if c.Database.RetryAttempts < 1 {
+		fail("DB_RETRY_ATTEMPTS must be at least 1, got %d", c.Database.RetryAttempts)
+	}
+	if c.Database.BreakerFailures < 0 {
+		fail("DB_BREAKER_FAILURES must not be negative, got %d", c.Database.BreakerFailures)
+	}
+
+	if c.Auth.HMACMaxBodyBytes < 1 {
+		fail("AUTH_HMAC_MAX_BODY_BYTES must be at least 1, got %d", c.Auth.HMACMaxBodyBytes)
+	}
+	if c.Idempotency.Enabled && c.Idempotency.MaxBodyBytes < 1 {
+		fail("IDEMPOTENCY_MAX_BODY_BYTES must be at least 1, got %d", c.Idempotency.MaxBodyBytes)
+	}
+
+	if c.Approval.Enabled && c.Approval.Role == "" {
+		fail("APPROVAL_ROLE is required when APPROVAL_ENABLED is set")
+	}
+	if c.Rescreen.Enabled {
+		if c.Rescreen.Interval <= 0 || c.Rescreen.SweepInterval <= 0 {
+			fail("RESCREEN_INTERVAL and RESCREEN_SWEEP_INTERVAL must be positive, got %s and %s", c.Rescreen.Interval, c.Rescreen.SweepInterval)
+		}
+		if c.Rescreen.BatchSize < 1 {
+			fail("RESCREEN_BATCH_SIZE must be at least 1, got %d", c.Rescreen.BatchSize)
+		}
+	}
+	if c.Dedup.Enabled {
+		if c.Dedup.Interval <= 0 {
+			fail("DEDUP_INTERVAL must be positive, got %s", c.Dedup.Interval)
+		}
+		if c.Dedup.NameSimilarity <= 0 || c.Dedup.NameSimilarity > 1 {
+			fail("DEDUP_NAME_SIMILARITY must be above 0 and at most 1, got %g", c.Dedup.NameSimilarity)
+		}
+		if c.Database.Driver != "postgres" {
+			fail("DEDUP_ENABLED needs DB_DRIVER=postgres, got %q", c.Database.Driver)
+		}
+	}
+	if c.Cases.Enabled && c.Database.Driver != "postgres" {
+		fail("CASES_ENABLED needs DB_DRIVER=postgres, got %q", c.Database.Driver)
+	}
+	// Only the blacklist store runs on the other drivers, so features keeping
+	// their own tables would fail on every query there
+	if c.Database.Driver != "postgres" && oneOf(c.Database.Driver, Drivers) {
+		features := []struct {
+			key     string
+			enabled bool
+		}{
+			{"IDEMPOTENCY_ENABLED", c.Idempotency.Enabled},
+			{"CHECK_HISTORY_ENABLED", c.History.Enabled},
+			{"APPROVAL_ENABLED", c.Approval.Enabled},
+			{"RESCREEN_ENABLED", c.Rescreen.Enabled},
+		}
+		for _, f := range features {
+			if f.enabled {
+				fail("%s needs DB_DRIVER=postgres, got %q", f.key, c.Database.Driver)
+			}
+		}
+		policies := []struct{ key, policy string }{
+			{"AUTH_POLICY", c.Auth.Policy},
+			{"INTERNAL_AUTH_POLICY", c.Server.InternalAuthPolicy},
+		}
+		for _, p := range policies {
+			// Certificate mappings and break-glass grants are kept in Postgres
+			for _, method := range []string{"mtls", "breakglass"} {
+				if policyUses(p.policy, method) {
+					fail("%s method %s needs DB_DRIVER=postgres, got %q", p.key, method, c.Database.Driver)
+				}
+			}
+		}
+	}
+	if c.Cache.WarmupEnabled {
+		if c.Cache.WarmupNIKs < 1 {
+			fail("CACHE_WARMUP_NIKS must be at least 1, got %d", c.Cache.WarmupNIKs)
+		}
+		if c.Cache.WarmupTimeout <= 0 {
+			fail("CACHE_WARMUP_TIMEOUT must be positive, got %s", c.Cache.WarmupTimeout)
+		}
+	}
+
+	if c.Sync.QualityMinScore < 0 || c.Sync.QualityMinScore > 1 {
+		fail("SYNC_QUALITY_MIN_SCORE must be between 0 and 1, got %g", c.Sync.QualityMinScore)
+	}
+	if c.Sync.RateBurst < 1 {
+		fail("SYNC_RATE_BURST must be at least 1, got %d", c.Sync.RateBurst)
+	}
+	if c.Sync.MaxAge < 0 {
+		fail("SYNC_MAX_AGE must not be negative, got %s", c.Sync.MaxAge)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// policyUses reports whether any rule of an auth policy accepts method
+func policyUses(policy, method string) bool {
+	for _, entry := range strings.Split(policy, ";") {
+		_, requirement, _ := strings.Cut(entry, "=")
+		methods, _, _ := strings.Cut(requirement, "@")
+		for _, m := range strings.Split(methods, "|") {
+			if strings.TrimSpace(m) == method {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func oneOf(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig loads the defaults with the settings that have none
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_USER", "blacklist")
+	t.Setenv("DB_NAME", "blacklist")
+	t.Setenv("REDIS_HOST", "localhost")
+	t.Setenv("AUTH_POLICY", "/=none")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		// want are the problems reported, by the setting they start with
+		want []string
+	}{
+		{name: "defaults", change: func(c *Config) {}},
+		{
+			name:   "missing connection settings",
+			change: func(c *Config) { c.Database.Host, c.Database.User, c.Redis.Host = "", " ", "" },
+			want:   []string{"DB_HOST", "DB_USER", "REDIS_HOST"},
+		},
+		{
+			name: "SQLite needs no server",
+			change: func(c *Config) {
+				c.Database.Driver, c.Database.Host, c.Database.User = "sqlite", "", ""
+				c.Idempotency.Enabled, c.History.Enabled = false, false
+			},
+		},
+		{
+			name:   "missing auth policy",
+			change: func(c *Config) { c.Auth.Policy = " " },
+			want:   []string{"AUTH_POLICY"},
+		},
+		{
+			name:   "port out of range",
+			change: func(c *Config) { c.Server.Port = 70000 },
+			want:   []string{"PORT"},
+		},
+		{
+			name:   "ports collide",
+			change: func(c *Config) { c.Server.GRPCPort = c.Server.Port },
+			want:   []string{"PORT and GRPC_PORT"},
+		},
+		{
+			name:   "internal settings without internal port",
+			change: func(c *Config) { c.Server.InternalAuthPolicy = "/=none" },
+			want:   []string{"INTERNAL_AUTH_POLICY"},
+		},
+		{
+			name:   "unknown enumerated values",
+			change: func(c *Config) { c.Server.Environment, c.Database.Driver, c.Redis.Mode = "prod", "oracle", "ring" },
+			want:   []string{"REDIS_MODE", "ENV", "DB_DRIVER"},
+		},
+		{
+			name:   "TLS key without certificate",
+			change: func(c *Config) { c.Server.TLSKeyFile = "server.key" },
+			want:   []string{"TLS_CERT_FILE"},
+		},
+		{
+			name:   "non-positive timeout",
+			change: func(c *Config) { c.Database.QueryTimeout = 0 },
+			want:   []string{"DB_QUERY_TIMEOUT"},
+		},
+		{
+			name: "non-positive job intervals",
+			change: func(c *Config) {
+				c.Clock.DriftInterval, c.Watchdog.Interval, c.Temporary.SweepInterval = 0, -time.Second, 0
+				c.Auth.CertRefreshInterval, c.Auth.JWTJWKSRefresh, c.Screening.PollInterval = 0, 0, 0
+				c.Cache.InsightsWindow, c.Cache.NIKFilterRefresh = 0, 0
+			},
+			want: []string{"CLOCK_DRIFT_INTERVAL", "WATCHDOG_INTERVAL", "TEMPORARY_SWEEP_INTERVAL",
+				"AUTH_CERT_REFRESH_INTERVAL", "AUTH_JWT_JWKS_REFRESH", "SCREENING_POLL_INTERVAL",
+				"CACHE_INSIGHTS_WINDOW", "CACHE_NIK_FILTER_REFRESH"},
+		},
+		{
+			name:   "postgres-only feature on MySQL",
+			change: func(c *Config) { c.Database.Driver, c.Cases.Enabled = "mysql", true },
+			want:   []string{"CASES_ENABLED", "IDEMPOTENCY_ENABLED", "CHECK_HISTORY_ENABLED"},
+		},
+		{
+			name: "postgres-only auth methods on SQLite",
+			change: func(c *Config) {
+				c.Database.Driver, c.Idempotency.Enabled, c.History.Enabled = "sqlite", false, false
+				c.Auth.Policy = "/healthz=none;GET /api/v1/admin=api_key|breakglass@auditor;/api/v1=mtls@checker"
+			},
+			want: []string{"AUTH_POLICY method mtls", "AUTH_POLICY method breakglass"},
+		},
+		{
+			name:   "quality score out of range",
+			change: func(c *Config) { c.Sync.QualityMinScore = 1.5 },
+			want:   []string{"SYNC_QUALITY_MIN_SCORE"},
+		},
+		{
+			name:   "cluster with database",
+			change: func(c *Config) { c.Redis.Mode, c.Redis.Addrs, c.Redis.DB = "cluster", "a:6379", 1 },
+			want:   []string{"REDIS_DB"},
+		},
+		{
+			name:   "sync burst of 0",
+			change: func(c *Config) { c.Sync.RateBurst = 0 },
+			want:   []string{"SYNC_RATE_BURST"},
+		},
+		{
+			name:   "negative sync age",
+			change: func(c *Config) { c.Sync.MaxAge = -time.Hour },
+			want:   []string{"SYNC_MAX_AGE"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.change(cfg)
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+			}
+			if len(validationErr.Problems) != len(tt.want) {
+				t.Fatalf("Validate() problems = %q, want %d starting with %q", validationErr.Problems, len(tt.want), tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(validationErr.Problems[i], want+" ") {
+					t.Errorf("problem %d = %q, want it to start with %s", i, validationErr.Problems[i], want)
+				}
+			}
+		})
+	}
+}
@@ -1,22 +1,44 @@
 package log
 
 import (
+	"blacklist-check/pkg/config"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-func NewLogger(level string) (*zap.Logger, error) {
+// level is shared by the loggers NewLogger builds, so SetLevel adjusts them
+// all at runtime
+var level = zap.NewAtomicLevel()
+
+// SetLevel changes the level of every logger NewLogger built
+func SetLevel(l zapcore.Level) {
+	level.SetLevel(l)
+}
+
+// PrepareReload reads LOG_LEVEL from cfg and returns the function that
+// applies it
+func PrepareReload(cfg *config.Config) (func(), error) {
+	l, err := zapcore.ParseLevel(cfg.Server.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	return func() { SetLevel(l) }, nil
+}
+
+func NewLogger(name string) (*zap.Logger, error) {
 	var cfg zap.Config
 
 	// Parse log level
-	logLevel, err := zapcore.ParseLevel(level)
+	logLevel, err := zapcore.ParseLevel(name)
 	if err != nil {
 		return nil, err
 	}
+	level.SetLevel(logLevel)
 
 	// Configure logger
 	cfg = zap.Config{
-		Level:       zap.NewAtomicLevelAt(logLevel),
+		Level:       level,
 		Development: false,
 		Sampling: &zap.SamplingConfig{
 			Initial:    100,